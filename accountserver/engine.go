@@ -77,6 +77,10 @@ type ContainerListingRecord struct {
 	Bytes        int64    `xml:"bytes" json:"bytes"`
 	Count        int64    `xml:"count" json:"count"`
 	LastModified string   `xml:"last_modified" json:"last_modified"`
+	// StoragePolicy is the name of the container's policy, filled in by the
+	// server from StoragePolicyIndex.
+	StoragePolicy      string `xml:"storage_policy,omitempty" json:"storage_policy,omitempty"`
+	StoragePolicyIndex int    `xml:"-" json:"-"`
 }
 
 // SubdirListingRecord is the struct used for serializing subdirs in json and xml account listings.
@@ -96,6 +100,10 @@ type ContainerRecord struct {
 	BytesUsed          int64  `json:"bytes_used"`
 	Deleted            int    `json:"deleted"`
 	StoragePolicyIndex int    `json:"storage_policy_index"`
+	// LastModified is the timestamp of the last change to the container or
+	// its objects, as reported by the container servers; it's empty for
+	// containers not reported since it was added.
+	LastModified string `json:"last_modified,omitempty"`
 }
 
 // SyncRecord represents a row in the incoming_sync table.  It is used by replication.
@@ -120,8 +128,9 @@ type Account interface {
 	GetMetadata() (map[string]string, error)
 	// UpdateMetadata applies updates to the account's metadata.
 	UpdateMetadata(updates map[string][]string) error
-	// PutContainer adds a new container to the account.
-	PutContainer(name string, putTimestamp string, deleteTimestamp string, objectCount int64, bytesUsed int64, storagePolicyIndex int) error
+	// PutContainer adds a new container to the account. lastModified may be
+	// empty if the container server didn't report it.
+	PutContainer(name string, putTimestamp string, deleteTimestamp string, objectCount int64, bytesUsed int64, storagePolicyIndex int, lastModified string) error
 	// ID returns a unique identifier for the account.
 	ID() string
 	// Close frees any resources associated with the account.
@@ -80,7 +80,9 @@ type Replicator struct {
 	keyFile           string
 	runningDevices    map[string]*replicationDevice
 	reclaimAge        int64
+	deviceFilter      ring.DeviceFilter
 	logLevel          zap.AtomicLevel
+	metricsScope      tally.Scope
 	metricsCloser     io.Closer
 	traceCloser       io.Closer
 	tracer            opentracing.Tracer
@@ -126,8 +128,8 @@ func (rd *replicationDevice) sendReplicationMessage(dev *ring.Device, part uint6
 	if err != nil {
 		return 0, nil, err
 	}
-	req, err := http.NewRequest("REPLICATE", fmt.Sprintf("%s://%s:%d/%s/%d/%s", dev.Scheme,
-		dev.Ip, dev.Port, dev.Device, part, ringHash), bytes.NewBuffer(body))
+	req, err := http.NewRequest("REPLICATE", fmt.Sprintf("%s://%s:%d%s/%s/%d/%s", dev.Scheme,
+		dev.Ip, dev.Port, dev.PathPrefix, dev.Device, part, ringHash), bytes.NewBuffer(body))
 	if err != nil {
 		return 0, nil, err
 	}
@@ -171,7 +173,7 @@ func (rd *replicationDevice) rsync(dev *ring.Device, c ReplicableAccount, part u
 		return fmt.Errorf("Error opening databae: %v", err)
 	}
 	defer release()
-	req, err := http.NewRequest("PUT", fmt.Sprintf("%s://%s:%d/%s/tmp/%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, tmpFilename), fp)
+	req, err := http.NewRequest("PUT", fmt.Sprintf("%s://%s:%d%s/%s/tmp/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, tmpFilename), fp)
 	if err != nil {
 		return fmt.Errorf("creating request: %v", err)
 	}
@@ -327,7 +329,7 @@ func (rd *replicationDevice) replicateDatabase(dbFile string) error {
 		return fmt.Errorf("Bad partition: %s", parts)
 	}
 	devices, handoff := rd.r.Ring.GetJobNodes(part, rd.dev.Id)
-	moreNodes := rd.r.Ring.GetMoreNodes(part)
+	moreNodes := ring.FilterMoreNodes(rd.r.Ring.GetMoreNodes(part), rd.r.deviceFilter)
 	c, err := sqliteOpenAccount(dbFile)
 	if err != nil {
 		return err
@@ -531,8 +533,7 @@ func (server *Replicator) Background(flags *flag.FlagSet) chan struct{} {
 }
 
 func (server *Replicator) GetHandler(config conf.Config, metricsPrefix string) http.Handler {
-	var metricsScope tally.Scope
-	metricsScope, server.metricsCloser = tally.NewRootScope(tally.ScopeOptions{
+	server.metricsScope, server.metricsCloser = tally.NewRootScope(tally.ScopeOptions{
 		Prefix:         metricsPrefix,
 		Tags:           map[string]string{},
 		CachedReporter: promreporter.NewReporter(promreporter.Options{}),
@@ -548,10 +549,13 @@ func (server *Replicator) GetHandler(config conf.Config, metricsPrefix string) h
 	router.Get("/metrics", prometheus.Handler())
 	router.Get("/loglevel", server.logLevel)
 	router.Put("/loglevel", server.logLevel)
+	router.Get("/loglevel/:component", http.HandlerFunc(srv.LogLevelHandler))
+	router.Put("/loglevel/:component", http.HandlerFunc(srv.LogLevelHandler))
+	router.Get("/loglevels", http.HandlerFunc(srv.LogLevelsHandler))
 	router.Get("/healthcheck", commonHandlers.ThenFunc(server.HealthcheckHandler))
 	router.Get("/debug/pprof/:parm", http.DefaultServeMux)
 	router.Post("/debug/pprof/:parm", http.DefaultServeMux)
-	return alice.New(middleware.Metrics(metricsScope), middleware.ServerTracer(server.tracer)).Then(router)
+	return alice.New(middleware.Metrics(server.metricsScope), middleware.ServerTracer(server.tracer)).Then(router)
 }
 
 func (server *Replicator) Finalize() {
@@ -651,6 +655,15 @@ func (r *Replicator) reportStats() {
 	}
 }
 
+// deviceMetricsScope returns the scope for a device's replication counters,
+// which are labeled by device rather than named after it.
+func (r *Replicator) deviceMetricsScope(device string) tally.Scope {
+	if r.metricsScope == nil {
+		return tally.NoopScope
+	}
+	return r.metricsScope.Tagged(map[string]string{"device": device})
+}
+
 func (r *Replicator) runLoopCheck(reportTimer <-chan time.Time) {
 	select {
 	case device := <-r.checkin:
@@ -670,10 +683,12 @@ func (r *Replicator) runLoopCheck(reportTimer <-chan time.Time) {
 				rd.lifetimeStats[k] += v
 			}
 			rd.lifetimeStats["passes"]++
+			r.deviceMetricsScope(device).Counter("passes").Inc(1)
 		}
 	case update := <-r.sendStat:
 		if rd, ok := r.runningDevices[update.device]; ok {
 			rd.stats[update.stat] += update.value
+			r.deviceMetricsScope(update.device).Counter(update.stat).Inc(update.value)
 		}
 	case <-reportTimer:
 		r.reportStats()
@@ -715,6 +730,7 @@ func (r *Replicator) Run() {
 		case update := <-r.sendStat:
 			if ctx, ok := r.runningDevices[update.device]; ok {
 				ctx.stats[update.stat] += update.value
+				r.deviceMetricsScope(update.device).Counter(update.stat).Inc(update.value)
 			}
 		case <-done:
 			waitingFor--
@@ -903,6 +919,12 @@ func NewReplicator(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLo
 	if err != nil {
 		return ipPort, nil, nil, fmt.Errorf("Unable to get hash prefix and suffix: %s", err)
 	}
+	// device_filters names registered ring.DeviceFilters; handoffs they
+	// reject aren't replicated to.
+	deviceFilter, err := ring.GetDeviceFilter(serverconf.GetDefault("account-replicator", "device_filters", ""))
+	if err != nil {
+		return ipPort, nil, nil, err
+	}
 	ring, err := cnf.GetRing("account", hashPathPrefix, hashPathSuffix, 0)
 	if err != nil {
 		return ipPort, nil, nil, fmt.Errorf("Error loading account ring: %s", err)
@@ -938,7 +960,7 @@ func NewReplicator(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLo
 	}
 	c := &http.Client{
 		Timeout:   time.Minute * 15,
-		Transport: transport,
+		Transport: srv.NewSigningTransport(transport),
 	}
 	server := &Replicator{
 		runningDevices: make(map[string]*replicationDevice),
@@ -954,6 +976,7 @@ func NewReplicator(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLo
 		deviceRoot:     serverconf.GetDefault("account-replicator", "devices", "/srv/node"),
 		serverPort:     port,
 		reclaimAge:     serverconf.GetInt("account-replicator", "reclaim_age", 604800),
+		deviceFilter:   deviceFilter,
 		logger:         logger,
 		concurrencySem: make(chan struct{}, concurrency),
 		Ring:           ring,
@@ -223,7 +223,7 @@ func (f fakeDatabase) CleanupTombstones(reclaimAge int64) error {
 func (f fakeDatabase) CheckSyncLink() error {
 	return errors.New("")
 }
-func (f fakeDatabase) PutContainer(name string, putTimestamp string, deleteTimestamp string, objectCount int64, bytesUsed int64, storagePolicyIndex int) error {
+func (f fakeDatabase) PutContainer(name string, putTimestamp string, deleteTimestamp string, objectCount int64, bytesUsed int64, storagePolicyIndex int, lastModified string) error {
 	return errors.New("")
 }
 func (f fakeDatabase) DeleteObject(name string, timestamp string, storagePolicyIndex int) error {
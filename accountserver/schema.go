@@ -67,7 +67,8 @@ const (
 			object_count INTEGER,
 			bytes_used INTEGER,
 			deleted INTEGER DEFAULT 0,
-			storage_policy_index INTEGER DEFAULT 0
+			storage_policy_index INTEGER DEFAULT 0,
+			last_modified TEXT DEFAULT ''
 		);
 		CREATE INDEX ix_container_deleted_name ON container (deleted, name);
 
@@ -160,6 +161,7 @@ func schemaMigrate(db *sql.DB) (bool, error) {
 	hasPolicyStat := false
 	hasContainerCount := false
 	hasDeletedNameIndex := false
+	hasLastModified := false
 
 	tx, err := db.Begin()
 	if err != nil {
@@ -168,7 +170,7 @@ func schemaMigrate(db *sql.DB) (bool, error) {
 	defer tx.Rollback()
 
 	// We just pull the schema out of sqlite_master and look at it to get the current state of the database.
-	rows, err := tx.Query("SELECT name, sql FROM sqlite_master WHERE name in ('policy_stat', 'account_stat', 'policy_stat', 'ix_container_deleted_name')")
+	rows, err := tx.Query("SELECT name, sql FROM sqlite_master WHERE name in ('policy_stat', 'account_stat', 'policy_stat', 'ix_container_deleted_name', 'container')")
 	if err != nil {
 		return false, err
 	}
@@ -184,10 +186,12 @@ func schemaMigrate(db *sql.DB) (bool, error) {
 			hasMetadata = strings.Contains(sql, "metadata")
 		} else if name == "ix_container_deleted_name" {
 			hasDeletedNameIndex = true
+		} else if name == "container" {
+			hasLastModified = strings.Contains(sql, "last_modified")
 		}
 	}
 
-	if hasMetadata && hasPolicyStat && hasContainerCount {
+	if hasMetadata && hasPolicyStat && hasContainerCount && hasLastModified {
 		return hasDeletedNameIndex, nil
 	}
 
@@ -226,5 +230,11 @@ func schemaMigrate(db *sql.DB) (bool, error) {
 			return hasDeletedNameIndex, fmt.Errorf("Performing container-count migration: %v", err)
 		}
 	}
+	if !hasLastModified {
+		script := "ALTER TABLE container ADD COLUMN last_modified TEXT DEFAULT '';"
+		if _, err := tx.Exec(script); err != nil {
+			return hasDeletedNameIndex, fmt.Errorf("Adding last_modified column: %v", err)
+		}
+	}
 	return hasDeletedNameIndex, tx.Commit()
 }
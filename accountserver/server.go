@@ -56,6 +56,7 @@ type AccountServer struct {
 	autoCreatePrefix string
 	policyList       conf.PolicyList
 	metricsCloser    io.Closer
+	statsd           *srv.StatsdClient
 	traceCloser      io.Closer
 	tracer           opentracing.Tracer
 }
@@ -87,6 +88,7 @@ func (server *AccountServer) Finalize() {
 	if server.metricsCloser != nil {
 		server.metricsCloser.Close()
 	}
+	server.statsd.Close()
 	if server.traceCloser != nil {
 		server.traceCloser.Close()
 	}
@@ -189,6 +191,9 @@ func (server *AccountServer) AccountGetHandler(writer http.ResponseWriter, reque
 			limit = 10000
 		}
 	}
+	if !srv.ValidateListingParams(writer, request) {
+		return
+	}
 	marker := request.Form.Get("marker")
 	delimiter := request.Form.Get("delimiter")
 	endMarker := request.Form.Get("end_marker")
@@ -200,6 +205,13 @@ func (server *AccountServer) AccountGetHandler(writer http.ResponseWriter, reque
 		srv.StandardResponse(writer, http.StatusInternalServerError)
 		return
 	}
+	for _, c := range containers {
+		if cr, ok := c.(*ContainerListingRecord); ok {
+			if policy := server.policyList[cr.StoragePolicyIndex]; policy != nil {
+				cr.StoragePolicy = policy.Name
+			}
+		}
+	}
 	format := request.Form.Get("format")
 	if format == "" { /* TODO: real accept parsing */
 		accept := request.Header.Get("Accept")
@@ -387,6 +399,18 @@ func (server *AccountServer) ContainerPutHandler(writer http.ResponseWriter, req
 		return
 	}
 	deleteTimestamp := request.Header.Get("X-Delete-Timestamp")
+	// Container servers that predate X-Last-Modified-Timestamp leave the
+	// container listed by its put timestamp.
+	var lastModified string
+	if lm := request.Header.Get("X-Last-Modified-Timestamp"); lm != "" {
+		if lastModified, err = common.StandardizeTimestamp(lm); err != nil {
+			srv.StandardResponse(writer, http.StatusBadRequest)
+			return
+		}
+		if i := strings.IndexByte(lastModified, '_'); i >= 0 {
+			lastModified = lastModified[:i]
+		}
+	}
 	db, err := server.accountEngine.Get(vars)
 	if err == ErrorNoSuchAccount {
 		if strings.HasPrefix(vars["account"], server.autoCreatePrefix) {
@@ -405,7 +429,7 @@ func (server *AccountServer) ContainerPutHandler(writer http.ResponseWriter, req
 		return
 	}
 	defer server.accountEngine.Return(db)
-	if err := db.PutContainer(vars["container"], putTimestamp, deleteTimestamp, objectCount, bytesUsed, int(storagePolicyIndex)); err != nil {
+	if err := db.PutContainer(vars["container"], putTimestamp, deleteTimestamp, objectCount, bytesUsed, int(storagePolicyIndex), lastModified); err != nil {
 		srv.GetLogger(request).Error("Error adding container to account.", zap.Error(err))
 		srv.StandardResponse(writer, http.StatusInternalServerError)
 		return
@@ -447,8 +471,11 @@ func (server *AccountServer) AcquireDevice(next http.Handler) http.Handler {
 		vars := srv.GetVars(request)
 		if device, ok := vars["device"]; ok && device != "" {
 			devicePath := filepath.Join(server.driveRoot, device)
-			if server.checkMounts {
+			// X-Backend-Mount-Check: true lets a caller insist on the mount
+			// check even on servers running with mount_check = false.
+			if server.checkMounts || request.Header.Get("X-Backend-Mount-Check") == "true" {
 				if mounted, err := fs.IsMount(devicePath); err != nil || !mounted {
+					srv.GetLogger(request).Error("Device not mounted", zap.String("device", devicePath), zap.Error(err))
 					vars["Method"] = request.Method
 					srv.CustomErrorResponse(writer, 507, vars)
 					return
@@ -499,10 +526,14 @@ func (server *AccountServer) GetHandler(config conf.Config, metricsPrefix string
 		middleware.ValidateRequest,
 		server.AcquireDevice,
 	)
+	dataHandlers := commonHandlers.Append(middleware.BackendSignatures())
 	router := srv.NewRouter()
 	router.Get("/metrics", prometheus.Handler())
 	router.Get("/loglevel", server.logLevel)
 	router.Put("/loglevel", server.logLevel)
+	router.Get("/loglevel/:component", http.HandlerFunc(srv.LogLevelHandler))
+	router.Put("/loglevel/:component", http.HandlerFunc(srv.LogLevelHandler))
+	router.Get("/loglevels", http.HandlerFunc(srv.LogLevelsHandler))
 	router.Get("/healthcheck", commonHandlers.ThenFunc(server.HealthcheckHandler))
 	router.Get("/diskusage", commonHandlers.ThenFunc(server.DiskUsageHandler))
 	router.Put("/ring/*ring_path", commonHandlers.ThenFunc(middleware.RingHandler))
@@ -511,18 +542,18 @@ func (server *AccountServer) GetHandler(config conf.Config, metricsPrefix string
 	router.Delete("/recon/:device/:method/:recon_type/*item_path", commonHandlers.ThenFunc(server.ReconHandler))
 	router.Get("/debug/pprof/:parm", http.DefaultServeMux)
 	router.Post("/debug/pprof/:parm", http.DefaultServeMux)
-	router.Put("/:device/tmp/:filename", commonHandlers.ThenFunc(server.TmpUploadHandler))
-	router.Put("/:device/:partition/:account/:container", commonHandlers.ThenFunc(server.ContainerPutHandler))
-	router.Put("/:device/:partition/:account", commonHandlers.ThenFunc(server.AccountPutHandler))
-	router.Delete("/:device/:partition/:account", commonHandlers.ThenFunc(server.AccountDeleteHandler))
-	router.Get("/:device/:partition/:account", commonHandlers.ThenFunc(server.AccountGetHandler))
-	router.Head("/:device/:partition/:account", commonHandlers.ThenFunc(server.AccountGetHandler))
-	router.Post("/:device/:partition/:account", commonHandlers.ThenFunc(server.AccountPostHandler))
-	router.Replicate("/:device/:partition/:hash", commonHandlers.ThenFunc(server.AccountReplicateHandler))
+	router.Put("/:device/tmp/:filename", dataHandlers.ThenFunc(server.TmpUploadHandler))
+	router.Put("/:device/:partition/:account/:container", dataHandlers.ThenFunc(server.ContainerPutHandler))
+	router.Put("/:device/:partition/:account", dataHandlers.ThenFunc(server.AccountPutHandler))
+	router.Delete("/:device/:partition/:account", dataHandlers.ThenFunc(server.AccountDeleteHandler))
+	router.Get("/:device/:partition/:account", dataHandlers.ThenFunc(server.AccountGetHandler))
+	router.Head("/:device/:partition/:account", dataHandlers.ThenFunc(server.AccountGetHandler))
+	router.Post("/:device/:partition/:account", dataHandlers.ThenFunc(server.AccountPostHandler))
+	router.Replicate("/:device/:partition/:hash", dataHandlers.ThenFunc(server.AccountReplicateHandler))
 	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Invalid path: %s", r.URL.Path), http.StatusBadRequest)
 	})
-	return alice.New(middleware.Metrics(metricsScope), middleware.GrepObject, middleware.ServerTracer(server.tracer)).Then(router)
+	return alice.New(middleware.Metrics(metricsScope), middleware.StatsdTimings(server.statsd), middleware.GrepObject, middleware.ServerTracer(server.tracer)).Then(router)
 }
 
 // NewServer parses configs and command-line flags, returning a configured server object and the ip and port it should bind on.
@@ -554,6 +585,9 @@ func NewServer(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader
 	if server.logger, err = srv.SetupLogger("account-server", &server.logLevel, flags); err != nil {
 		return ipPort, nil, nil, fmt.Errorf("Error setting up logger: %v", err)
 	}
+	if server.statsd, err = srv.NewStatsdClientFromConfig(serverconf.GetSection("app:account-server"), "account-server"); err != nil {
+		return ipPort, nil, nil, err
+	}
 	server.accountEngine = newLRUEngine(server.driveRoot, server.hashPathPrefix, server.hashPathSuffix, 32)
 	if serverconf.HasSection("tracing") {
 		server.tracer, server.traceCloser, err = tracing.Init("accountserver", server.logger, serverconf.GetSection("tracing"))
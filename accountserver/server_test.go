@@ -16,6 +16,7 @@
 package accountserver
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -258,6 +259,71 @@ func TestAccountPutContainersGet(t *testing.T) {
 	require.Equal(t, "application/xml; charset=utf-8", rsp.Header().Get("Content-Type"))
 }
 
+func TestAccountGetPolicyAndLastModified(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "device"), 0777))
+	server := &AccountServer{
+		driveRoot:        dir,
+		hashPathPrefix:   "changeme",
+		hashPathSuffix:   "changeme",
+		logLevel:         zap.NewAtomicLevelAt(zapcore.InfoLevel),
+		logger:           zap.NewNop(),
+		accountEngine:    newLRUEngine(dir, "changeme", "changeme", 32),
+		diskInUse:        common.NewKeyedLimit(2, 2),
+		autoCreatePrefix: ".",
+		policyList:       conf.PolicyList{0: &conf.Policy{Index: 0, Name: "gold"}, 1: &conf.Policy{Index: 1, Name: "silver"}},
+	}
+	handler := server.GetHandler(*new(conf.Config), fmt.Sprintf("test_accountserver_%d", atomic.AddUint64(&makeTestServerCounter, 1)))
+
+	rsp := test.MakeCaptureResponse()
+	req, err := http.NewRequest("PUT", "/device/1/a", nil)
+	require.Nil(t, err)
+	req.Header.Set("X-Timestamp", "100000000.00001")
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 201, rsp.Status)
+
+	for i, lastModified := range []string{"", "1500000100.00000_0000000000000001"} {
+		rsp := test.MakeCaptureResponse()
+		req, err := http.NewRequest("PUT", fmt.Sprintf("/device/1/a/c%d", i), nil)
+		require.Nil(t, err)
+		req.Header.Set("X-Put-Timestamp", "1500000000.00000")
+		req.Header.Set("X-Object-Count", "1")
+		req.Header.Set("X-Bytes-Used", "1")
+		req.Header.Set("X-Backend-Storage-Policy-Index", fmt.Sprintf("%d", i))
+		if lastModified != "" {
+			req.Header.Set("X-Last-Modified-Timestamp", lastModified)
+		}
+		handler.ServeHTTP(rsp, req)
+		require.Equal(t, 201, rsp.Status)
+	}
+
+	rsp = test.MakeCaptureResponse()
+	req, err = http.NewRequest("GET", "/device/1/a?format=json", nil)
+	require.Nil(t, err)
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 200, rsp.Status)
+	var listing []map[string]interface{}
+	require.Nil(t, json.Unmarshal(rsp.Body.Bytes(), &listing))
+	require.Equal(t, 2, len(listing))
+	require.Equal(t, "gold", listing[0]["storage_policy"])
+	require.Equal(t, "2017-07-14T02:40:00.000000", listing[0]["last_modified"])
+	require.Equal(t, "silver", listing[1]["storage_policy"])
+	require.Equal(t, "2017-07-14T02:41:40.000000", listing[1]["last_modified"])
+
+	rsp = test.MakeCaptureResponse()
+	req, err = http.NewRequest("PUT", "/device/1/a/c2", nil)
+	require.Nil(t, err)
+	req.Header.Set("X-Put-Timestamp", "1500000000.00000")
+	req.Header.Set("X-Object-Count", "0")
+	req.Header.Set("X-Bytes-Used", "0")
+	req.Header.Set("X-Backend-Storage-Policy-Index", "0")
+	req.Header.Set("X-Last-Modified-Timestamp", "yesterday")
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 400, rsp.Status)
+}
+
 func TestContainerGetTextEmpty(t *testing.T) {
 	handler, cleanup, err := makeTestServer()
 	require.Nil(t, err)
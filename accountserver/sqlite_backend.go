@@ -298,10 +298,10 @@ func (db *sqliteAccount) mergeItems(records []*ContainerRecord, remoteID string)
 		batch := names[i:j]
 		query := ""
 		if db.hasDeletedNameIndex {
-			query = fmt.Sprintf("SELECT name, put_timestamp, delete_timestamp, ROWID FROM container WHERE deleted IN (0, 1) AND name IN (%s)",
+			query = fmt.Sprintf("SELECT name, put_timestamp, delete_timestamp, last_modified, ROWID FROM container WHERE deleted IN (0, 1) AND name IN (%s)",
 				strings.TrimRight(strings.Repeat("?,", len(batch)), ","))
 		} else {
-			query = fmt.Sprintf("SELECT name, put_timestamp, delete_timestamp, ROWID FROM container WHERE name IN (%s)",
+			query = fmt.Sprintf("SELECT name, put_timestamp, delete_timestamp, last_modified, ROWID FROM container WHERE name IN (%s)",
 				strings.TrimRight(strings.Repeat("?,", len(batch)), ","))
 		}
 		rows, err := tx.Query(query, batch...)
@@ -313,15 +313,15 @@ func (db *sqliteAccount) mergeItems(records []*ContainerRecord, remoteID string)
 		}
 		defer rows.Close()
 		for rows.Next() {
-			var name, putTimestamp, deleteTimestamp string
+			var name, putTimestamp, deleteTimestamp, lastModified string
 			var rowid int64
-			if err := rows.Scan(&name, &putTimestamp, &deleteTimestamp, &rowid); err != nil {
+			if err := rows.Scan(&name, &putTimestamp, &deleteTimestamp, &lastModified, &rowid); err != nil {
 				if common.IsCorruptDBError(err) {
 					return fmt.Errorf("Failed to MergeItems Scan: %v; %v", err, common.QuarantineDir(path.Dir(db.accountFile), 4, "accounts"))
 				}
 				return err
 			}
-			existing[name] = &ContainerRecord{PutTimestamp: putTimestamp, DeleteTimestamp: deleteTimestamp, Rowid: rowid}
+			existing[name] = &ContainerRecord{PutTimestamp: putTimestamp, DeleteTimestamp: deleteTimestamp, LastModified: lastModified, Rowid: rowid}
 		}
 		if err := rows.Err(); err != nil {
 			if common.IsCorruptDBError(err) {
@@ -343,8 +343,8 @@ func (db *sqliteAccount) mergeItems(records []*ContainerRecord, remoteID string)
 	}
 	defer dst.Close()
 
-	ast, err := tx.Prepare(`INSERT INTO container (name, put_timestamp, delete_timestamp, object_count, bytes_used, deleted, storage_policy_index)
-							VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	ast, err := tx.Prepare(`INSERT INTO container (name, put_timestamp, delete_timestamp, object_count, bytes_used, deleted, storage_policy_index, last_modified)
+							VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return err
 	}
@@ -368,6 +368,9 @@ func (db *sqliteAccount) mergeItems(records []*ContainerRecord, remoteID string)
 			if er.DeleteTimestamp > record.DeleteTimestamp {
 				record.DeleteTimestamp = er.DeleteTimestamp
 			}
+			if er.LastModified > record.LastModified {
+				record.LastModified = er.LastModified
+			}
 			if record.DeleteTimestamp > record.PutTimestamp {
 				record.Deleted = 1
 			} else {
@@ -375,7 +378,7 @@ func (db *sqliteAccount) mergeItems(records []*ContainerRecord, remoteID string)
 			}
 		}
 		if res, err := ast.Exec(record.Name, record.PutTimestamp, record.DeleteTimestamp, record.ObjectCount,
-			record.BytesUsed, record.Deleted, record.StoragePolicyIndex); err != nil {
+			record.BytesUsed, record.Deleted, record.StoragePolicyIndex, record.LastModified); err != nil {
 			if common.IsCorruptDBError(err) {
 				return fmt.Errorf("Failed to MergeItems INSERT: %v; %v", err, common.QuarantineDir(path.Dir(db.accountFile), 4, "accounts"))
 			}
@@ -423,7 +426,7 @@ func (db *sqliteAccount) ListContainers(limit int, marker string, endMarker stri
 	}
 	var point, pointDirection, queryTail, queryStart string
 
-	queryStart = "SELECT name, object_count, bytes_used, put_timestamp FROM container WHERE "
+	queryStart = "SELECT name, object_count, bytes_used, put_timestamp, last_modified, storage_policy_index FROM container WHERE "
 	if reverse {
 		marker, endMarker = endMarker, marker
 		queryTail = "ORDER BY name DESC LIMIT ?"
@@ -474,12 +477,18 @@ func (db *sqliteAccount) ListContainers(limit int, marker string, endMarker stri
 		for rows.Next() && len(results) < limit {
 			gotResults = true
 			record := &ContainerListingRecord{}
-			if err := rows.Scan(&record.Name, &record.Count, &record.Bytes, &record.LastModified); err != nil {
+			var lastModified string
+			if err := rows.Scan(&record.Name, &record.Count, &record.Bytes, &record.LastModified, &lastModified, &record.StoragePolicyIndex); err != nil {
 				if common.IsCorruptDBError(err) {
 					return nil, fmt.Errorf("Failed to ListContainers Scan: %v; %v", err, common.QuarantineDir(path.Dir(db.accountFile), 4, "accounts"))
 				}
 				return nil, err
 			}
+			// Containers reported with their objects' last change list that
+			// rather than the container's own put_timestamp.
+			if lastModified > record.LastModified {
+				record.LastModified = lastModified
+			}
 			if f, err := strconv.ParseFloat(record.LastModified, 64); err != nil {
 				return nil, err
 			} else {
@@ -557,7 +566,7 @@ func (db *sqliteAccount) ItemsSince(start int64, count int) ([]*ContainerRecord,
 	db.flush()
 	records := []*ContainerRecord{}
 	rows, err := db.Query(`SELECT ROWID, name, put_timestamp, delete_timestamp, object_count,
-						   bytes_used, deleted, storage_policy_index
+						   bytes_used, deleted, storage_policy_index, last_modified
 						   FROM container WHERE ROWID > ? ORDER BY ROWID ASC LIMIT ?`, start, count)
 	if err != nil {
 		if common.IsCorruptDBError(err) {
@@ -568,7 +577,7 @@ func (db *sqliteAccount) ItemsSince(start int64, count int) ([]*ContainerRecord,
 	defer rows.Close()
 	for rows.Next() {
 		r := &ContainerRecord{}
-		if err := rows.Scan(&r.Rowid, &r.Name, &r.PutTimestamp, &r.DeleteTimestamp, &r.ObjectCount, &r.BytesUsed, &r.Deleted, &r.StoragePolicyIndex); err != nil {
+		if err := rows.Scan(&r.Rowid, &r.Name, &r.PutTimestamp, &r.DeleteTimestamp, &r.ObjectCount, &r.BytesUsed, &r.Deleted, &r.StoragePolicyIndex, &r.LastModified); err != nil {
 			if common.IsCorruptDBError(err) {
 				return nil, fmt.Errorf("Failed to ItemsSince Scan: %v; %v", err, common.QuarantineDir(path.Dir(db.accountFile), 4, "accounts"))
 			}
@@ -996,6 +1005,10 @@ func (db *sqliteAccount) flush() error {
 		rec.Deleted = int(deleted)
 		spi, casts[6] = int64MaybeStringified(record[6])
 		rec.StoragePolicyIndex = int(spi)
+		// Records pended before last_modified was added don't have it.
+		if len(record) > 7 {
+			rec.LastModified, _ = record[7].(string)
+		}
 		for i := 0; i < 7; i++ {
 			if !casts[i] {
 				return fmt.Errorf("Invalid commit pending record")
@@ -1022,7 +1035,7 @@ func int64MaybeStringified(i interface{}) (int64, bool) {
 }
 
 // PutContainer adds a container to the account, by way of pending file.
-func (db *sqliteAccount) PutContainer(name string, putTimestamp string, deleteTimestamp string, objectCount int64, bytesUsed int64, storagePolicyIndex int) error {
+func (db *sqliteAccount) PutContainer(name string, putTimestamp string, deleteTimestamp string, objectCount int64, bytesUsed int64, storagePolicyIndex int, lastModified string) error {
 	lock, err := fs.LockPath(filepath.Dir(db.accountFile), dirLockTimeout)
 	if err != nil {
 		return err
@@ -1032,7 +1045,7 @@ func (db *sqliteAccount) PutContainer(name string, putTimestamp string, deleteTi
 	if deleteTimestamp > putTimestamp {
 		deleted = 1
 	}
-	tuple := []interface{}{name, putTimestamp, deleteTimestamp, objectCount, bytesUsed, deleted, storagePolicyIndex}
+	tuple := []interface{}{name, putTimestamp, deleteTimestamp, objectCount, bytesUsed, deleted, storagePolicyIndex, lastModified}
 	file, err := os.OpenFile(db.accountFile+".pending", os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
 	if err != nil {
 		return err
@@ -16,6 +16,7 @@
 package accountserver
 
 import (
+	"database/sql"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -121,6 +122,19 @@ func TestContainerDelimiter(t *testing.T) {
 	require.Equal(t, "a", records[1].(*ContainerListingRecord).Name)
 }
 
+func TestContainerMultiCharDelimiter(t *testing.T) {
+	db, _, cleanup, err := createTestDatabase("100000000.00000")
+	require.Nil(t, err)
+	defer cleanup()
+	require.Nil(t, mergeItemsByName(db, []string{"a", "a::b", "a::c", "a:d"}))
+	records, err := db.ListContainers(10000, "", "", "", "::", false)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(records))
+	require.Equal(t, "a", records[0].(*ContainerListingRecord).Name)
+	require.Equal(t, "a::", records[1].(*SubdirListingRecord).Name)
+	require.Equal(t, "a:d", records[2].(*ContainerListingRecord).Name)
+}
+
 func TestNewID(t *testing.T) {
 	db, _, cleanup, err := createTestDatabase("200000000.00000")
 	require.Nil(t, err)
@@ -133,6 +147,61 @@ func TestNewID(t *testing.T) {
 	require.NotEqual(t, oldID, info.ID)
 }
 
+func TestContainerListingLastModified(t *testing.T) {
+	db, _, cleanup, err := createTestDatabase("100000000.00000")
+	require.Nil(t, err)
+	defer cleanup()
+	require.Nil(t, db.PutContainer("a", "1500000000.00000", "0", 1, 1, 1, ""))
+	require.Nil(t, db.PutContainer("b", "1500000000.00000", "0", 1, 1, 0, "1500000100.00000"))
+	require.Nil(t, db.flush())
+	records, err := db.ListContainers(10000, "", "", "", "", false)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(records))
+	require.Equal(t, "2017-07-14T02:40:00.000000", records[0].(*ContainerListingRecord).LastModified)
+	require.Equal(t, 1, records[0].(*ContainerListingRecord).StoragePolicyIndex)
+	require.Equal(t, "2017-07-14T02:41:40.000000", records[1].(*ContainerListingRecord).LastModified)
+
+	// an older report doesn't move last_modified back
+	require.Nil(t, db.MergeItems([]*ContainerRecord{{Name: "b", PutTimestamp: "1500000000.00000", DeleteTimestamp: "0", LastModified: "1500000050.00000"}}, ""))
+	records, err = db.ListContainers(10000, "", "", "", "", false)
+	require.Nil(t, err)
+	require.Equal(t, "2017-07-14T02:41:40.000000", records[1].(*ContainerListingRecord).LastModified)
+}
+
+func TestLastModifiedMigration(t *testing.T) {
+	db, dbFile, cleanup, err := createTestDatabase("100000000.00000")
+	require.Nil(t, err)
+	defer cleanup()
+	require.Nil(t, db.Close())
+	raw, err := sql.Open("sqlite3_account", "file:"+dbFile+"?mode=rw")
+	require.Nil(t, err)
+	_, err = raw.Exec(`DROP TABLE container;
+		CREATE TABLE container (
+			ROWID INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT,
+			put_timestamp TEXT,
+			delete_timestamp TEXT,
+			object_count INTEGER,
+			bytes_used INTEGER,
+			deleted INTEGER DEFAULT 0,
+			storage_policy_index INTEGER DEFAULT 0
+		);
+		INSERT INTO container (name, put_timestamp, delete_timestamp, object_count, bytes_used)
+			VALUES ('a', '1500000000.00000', '0', 0, 0);`)
+	require.Nil(t, err)
+	require.Nil(t, raw.Close())
+
+	records, err := db.ListContainers(10000, "", "", "", "", false)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "2017-07-14T02:40:00.000000", records[0].(*ContainerListingRecord).LastModified)
+	require.Nil(t, db.PutContainer("a", "1500000000.00000", "0", 1, 1, 0, "1500000100.00000"))
+	require.Nil(t, db.flush())
+	records, err = db.ListContainers(10000, "", "", "", "", false)
+	require.Nil(t, err)
+	require.Equal(t, "2017-07-14T02:41:40.000000", records[0].(*ContainerListingRecord).LastModified)
+}
+
 func TestItemsSince(t *testing.T) {
 	db, _, cleanup, err := createTestDatabase("200000000.00000")
 	require.Nil(t, err)
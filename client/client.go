@@ -24,22 +24,52 @@ type RequestClient interface {
 	SetContainerInfo(ctx context.Context, account string, container string, resp *http.Response) (*ContainerInfo, error)
 	HeadContainer(ctx context.Context, account string, container string, headers http.Header) *http.Response
 	DeleteContainer(ctx context.Context, account string, container string, headers http.Header) *http.Response
+	// RestoreContainer undeletes a container deleted within the container
+	// servers' restore_window.
+	RestoreContainer(ctx context.Context, account string, container string, headers http.Header) *http.Response
 	PutObject(ctx context.Context, account string, container string, obj string, headers http.Header, src io.Reader) *http.Response
+	// AppendObject adds src to the end of an existing object, if its policy
+	// allows it.
+	AppendObject(ctx context.Context, account string, container string, obj string, headers http.Header, src io.Reader) *http.Response
 	PostObject(ctx context.Context, account string, container string, obj string, headers http.Header) *http.Response
 	GetObject(ctx context.Context, account string, container string, obj string, headers http.Header) *http.Response
 	HeadObject(ctx context.Context, account string, container string, obj string, headers http.Header) *http.Response
 	DeleteObject(ctx context.Context, account string, container string, obj string, headers http.Header) *http.Response
+	// GetObjectInPolicy and DeleteObjectInPolicy go to the given storage
+	// policy rather than the container's, for moving objects that ended up
+	// in the wrong one.
+	GetObjectInPolicy(ctx context.Context, policy int, account string, container string, obj string, headers http.Header) *http.Response
+	DeleteObjectInPolicy(ctx context.Context, policy int, account string, container string, obj string, headers http.Header) *http.Response
+	// ObjectReplicaMetadata asks every primary node, and as many handoffs,
+	// what it has for the object; it's meant for debugging and consistency
+	// tools rather than serving requests.
+	ObjectReplicaMetadata(ctx context.Context, account string, container string, obj string) ([]*ReplicaMetadata, *http.Response)
 	// ObjectRingFor returns the object ring for the given account/container or
 	// a response as to why the ring could not be returned.
 	ObjectRingFor(ctx context.Context, account string, container string) (ring.Ring, *http.Response)
 	ContainerRing() ring.Ring
 	AccountRing() ring.Ring
 	SetUserAgent(string)
+	// SetPriority sets the common.PriorityHeader of backend requests that
+	// don't already have one, for daemons whose work shouldn't compete with
+	// users'.
+	SetPriority(string)
+}
+
+// ObjectWritePredialer is implemented by RequestClients that can start
+// connecting to the backends for an object write before it's made.
+type ObjectWritePredialer interface {
+	PredialObjectWrite(account string, container string, obj string)
 }
 
 // ProxyClient is the factory for RequestClients, and manages any persistent/shared client resources.
 type ProxyClient interface {
 	NewRequestClient(mc ring.MemcacheRing, lc map[string]*ContainerInfo, logger srv.LowLevelLogger) RequestClient
+	// DeviceHealth lists the backend devices currently being avoided.
+	DeviceHealth() []DeviceHealthEntry
+	// Policies lists the storage policies as configured, with the state of
+	// each one's object ring.
+	Policies() []PolicyStatus
 	Close() error
 }
 
@@ -53,4 +83,20 @@ type ContainerInfo struct {
 	Metadata           map[string]string
 	SysMetadata        map[string]string
 	StoragePolicyIndex int
+	// Timestamp is the container's X-Timestamp, when it was created.
+	Timestamp string
+}
+
+// ReplicaMetadata is a single backend device's view of an object.
+type ReplicaMetadata struct {
+	Device  *ring.Device
+	Handoff bool
+	// StatusCode is 0 if the request couldn't be made; Err will say why.
+	StatusCode       int
+	Timestamp        string
+	DurableTimestamp string
+	DataTimestamp    string
+	FragIndex        string
+	Headers          http.Header
+	Err              error
 }
@@ -0,0 +1,775 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+// Package clienttest provides an in-memory fake of client.ProxyClient and
+// client.RequestClient for unit tests of code that talks to a cluster.
+//
+// Accounts, containers and objects are kept in maps in a Store, and requests
+// can be made to fail with Store.Fail. For code written against a
+// nectar.Client, NewClient wraps the same fake:
+//
+//	s := clienttest.NewStore()
+//	c := clienttest.NewClient(s, "AUTH_test")
+//	c.PutContainer("c", nil)
+//	s.Fail(clienttest.Failure{Method: "GET", Path: "AUTH_test/c/", StatusCode: 503, Count: 1})
+package clienttest
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/troubling/hummingbird/client"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/common/test"
+	"github.com/troubling/nectar"
+	"github.com/troubling/nectar/nectarutil"
+)
+
+// Failure makes matching requests fail with StatusCode instead of being
+// handled.
+type Failure struct {
+	// Method matches any method if empty.
+	Method string
+	// Path is matched as a prefix of the request's "account/container/object"
+	// path; the empty string matches every request.
+	Path       string
+	StatusCode int
+	// Count is how many requests fail before the Failure is used up; 0 means
+	// it never is.
+	Count int
+}
+
+type object struct {
+	headers   http.Header
+	data      []byte
+	timestamp time.Time
+}
+
+type container struct {
+	headers http.Header
+	objects map[string]*object
+}
+
+type account struct {
+	headers    http.Header
+	containers map[string]*container
+	// deleted holds deleted containers for RestoreContainer.
+	deleted map[string]*container
+}
+
+// Store holds the fake cluster's contents, shared by every client made from
+// it.
+type Store struct {
+	lock     sync.Mutex
+	accounts map[string]*account
+	failures []*Failure
+	// Requests counts the requests made, keyed by method, including ones
+	// that failed. Read it only when no requests are in progress.
+	Requests map[string]int
+}
+
+// NewStore returns an empty Store.
+func NewStore() *Store {
+	return &Store{accounts: map[string]*account{}, Requests: map[string]int{}}
+}
+
+// Fail adds a Failure; the earliest added matching Failure is used.
+func (s *Store) Fail(f Failure) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failures = append(s.failures, &f)
+}
+
+// ClearFailures removes all Failures.
+func (s *Store) ClearFailures() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failures = nil
+}
+
+// begin locks the store and returns the status of a matching Failure, or 0
+// if the request should be handled. The caller must unlock the store.
+func (s *Store) begin(method, path string) int {
+	s.lock.Lock()
+	s.Requests[method]++
+	for i, f := range s.failures {
+		if (f.Method == "" || f.Method == method) && strings.HasPrefix(path, f.Path) {
+			if f.Count > 0 {
+				if f.Count--; f.Count == 0 {
+					s.failures = append(s.failures[:i], s.failures[i+1:]...)
+				}
+			}
+			return f.StatusCode
+		}
+	}
+	return 0
+}
+
+func (s *Store) getContainer(a, c string) *container {
+	if acct := s.accounts[a]; acct != nil {
+		return acct.containers[c]
+	}
+	return nil
+}
+
+// copyHeaders copies the headers in src with one of prefixes, or any of
+// names, into dst.
+func copyHeaders(dst, src http.Header, prefixes []string, names ...string) {
+	for k, v := range src {
+		k = http.CanonicalHeaderKey(k)
+		keep := common.StringInSlice(k, names)
+		for _, p := range prefixes {
+			keep = keep || strings.HasPrefix(k, p)
+		}
+		if keep {
+			dst[k] = v
+		}
+	}
+}
+
+// updateMeta sets the metadata from a PUT or POST, where an empty value
+// removes the key.
+func updateMeta(dst, src http.Header, prefixes []string, names ...string) {
+	copyHeaders(dst, src, prefixes, names...)
+	for k, v := range dst {
+		if len(v) == 0 || v[0] == "" {
+			delete(dst, k)
+		}
+	}
+}
+
+var (
+	accountMeta   = []string{"X-Account-Meta-", "X-Account-Sysmeta-"}
+	containerMeta = []string{"X-Container-Meta-", "X-Container-Sysmeta-"}
+	containerACLs = []string{"X-Container-Read", "X-Container-Write", "X-Container-Sync-Key", "X-Versions-Location", "X-History-Location"}
+	objectMeta    = []string{"X-Object-Meta-", "X-Object-Sysmeta-"}
+	objectHeaders = []string{"Content-Type", "Content-Encoding", "Content-Disposition", "X-Delete-At"}
+)
+
+func stub(status int, headers http.Header) *http.Response {
+	resp := nectarutil.ResponseStub(status, "")
+	for k, v := range headers {
+		resp.Header[k] = v
+	}
+	return resp
+}
+
+func jsonResponse(v interface{}, headers http.Header) *http.Response {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
+	}
+	resp := nectarutil.ResponseStub(http.StatusOK, string(body))
+	for k, v := range headers {
+		resp.Header[k] = v
+	}
+	resp.Header.Set("Content-Type", "application/json; charset=utf-8")
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return resp
+}
+
+// listNames applies a listing's options to names, returning the names to
+// list and whether each is a subdir rolled up by the delimiter.
+func listNames(names []string, options map[string]string) ([]string, map[string]bool) {
+	prefix, delimiter := options["prefix"], options["delimiter"]
+	marker, endMarker := options["marker"], options["end_marker"]
+	reverse := common.LooksTrue(options["reverse"])
+	limit := 10000
+	if l, err := strconv.Atoi(options["limit"]); err == nil && l > 0 && l < limit {
+		limit = l
+	}
+	sort.Strings(names)
+	if reverse {
+		for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+			names[i], names[j] = names[j], names[i]
+		}
+	}
+	var listed []string
+	subdirs := map[string]bool{}
+	for _, name := range names {
+		if len(listed) >= limit {
+			break
+		}
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if marker != "" && ((!reverse && name <= marker) || (reverse && name >= marker)) {
+			continue
+		}
+		if endMarker != "" && ((!reverse && name >= endMarker) || (reverse && name <= endMarker)) {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(name[len(prefix):], delimiter); i >= 0 {
+				subdir := name[:len(prefix)+i+len(delimiter)]
+				if !subdirs[subdir] {
+					subdirs[subdir] = true
+					listed = append(listed, subdir)
+				}
+				continue
+			}
+		}
+		listed = append(listed, name)
+	}
+	return listed, subdirs
+}
+
+type proxyClient struct {
+	store *Store
+}
+
+var _ client.ProxyClient = &proxyClient{}
+
+// NewProxyClient returns a client.ProxyClient whose RequestClients all use
+// the store.
+func NewProxyClient(store *Store) client.ProxyClient {
+	return &proxyClient{store: store}
+}
+
+func (p *proxyClient) NewRequestClient(mc ring.MemcacheRing, lc map[string]*client.ContainerInfo, logger srv.LowLevelLogger) client.RequestClient {
+	return NewRequestClient(p.store)
+}
+
+func (p *proxyClient) DeviceHealth() []client.DeviceHealthEntry {
+	return nil
+}
+
+func (p *proxyClient) Policies() []client.PolicyStatus {
+	return nil
+}
+
+func (p *proxyClient) Close() error {
+	return nil
+}
+
+// RequestClient is a client.RequestClient backed by a Store. UserAgent and
+// Priority are what were last set on it, for tests that check them.
+type RequestClient struct {
+	store     *Store
+	UserAgent string
+	Priority  string
+}
+
+var _ client.RequestClient = &RequestClient{}
+
+// NewRequestClient returns a RequestClient using the store.
+func NewRequestClient(store *Store) *RequestClient {
+	return &RequestClient{store: store}
+}
+
+// NewClient returns a nectar.Client for the account using the store.
+func NewClient(store *Store, account string) nectar.Client {
+	return client.NewRequestDirectClient(NewRequestClient(store), account)
+}
+
+func (c *RequestClient) PutAccount(ctx context.Context, a string, headers http.Header) *http.Response {
+	s := c.store
+	defer s.lock.Unlock()
+	if status := s.begin("PUT", a); status != 0 {
+		return stub(status, nil)
+	}
+	acct := s.accounts[a]
+	if acct == nil {
+		acct = &account{headers: http.Header{}, containers: map[string]*container{}}
+		s.accounts[a] = acct
+		updateMeta(acct.headers, headers, accountMeta)
+		return stub(http.StatusCreated, nil)
+	}
+	updateMeta(acct.headers, headers, accountMeta)
+	return stub(http.StatusAccepted, nil)
+}
+
+func (c *RequestClient) PostAccount(ctx context.Context, a string, headers http.Header) *http.Response {
+	s := c.store
+	defer s.lock.Unlock()
+	if status := s.begin("POST", a); status != 0 {
+		return stub(status, nil)
+	}
+	acct := s.accounts[a]
+	if acct == nil {
+		return stub(http.StatusNotFound, nil)
+	}
+	updateMeta(acct.headers, headers, accountMeta)
+	return stub(http.StatusNoContent, nil)
+}
+
+func (s *Store) accountHeaders(acct *account) http.Header {
+	h := http.Header{}
+	var objects, used int64
+	for _, con := range acct.containers {
+		for _, o := range con.objects {
+			objects++
+			used += int64(len(o.data))
+		}
+	}
+	copyHeaders(h, acct.headers, accountMeta)
+	h.Set("X-Account-Container-Count", strconv.Itoa(len(acct.containers)))
+	h.Set("X-Account-Object-Count", strconv.FormatInt(objects, 10))
+	h.Set("X-Account-Bytes-Used", strconv.FormatInt(used, 10))
+	return h
+}
+
+func (c *RequestClient) GetAccountRaw(ctx context.Context, a string, options map[string]string, headers http.Header) *http.Response {
+	s := c.store
+	defer s.lock.Unlock()
+	if status := s.begin("GET", a); status != 0 {
+		return stub(status, nil)
+	}
+	acct := s.accounts[a]
+	if acct == nil {
+		return stub(http.StatusNotFound, nil)
+	}
+	var names []string
+	for name := range acct.containers {
+		names = append(names, name)
+	}
+	listed, subdirs := listNames(names, options)
+	listing := []interface{}{}
+	for _, name := range listed {
+		if subdirs[name] {
+			listing = append(listing, map[string]string{"subdir": name})
+			continue
+		}
+		record := &nectar.ContainerRecord{Name: name}
+		for _, o := range acct.containers[name].objects {
+			record.Count++
+			record.Bytes += int64(len(o.data))
+		}
+		listing = append(listing, record)
+	}
+	return jsonResponse(listing, s.accountHeaders(acct))
+}
+
+func (c *RequestClient) HeadAccount(ctx context.Context, a string, headers http.Header) *http.Response {
+	s := c.store
+	defer s.lock.Unlock()
+	if status := s.begin("HEAD", a); status != 0 {
+		return stub(status, nil)
+	}
+	acct := s.accounts[a]
+	if acct == nil {
+		return stub(http.StatusNotFound, nil)
+	}
+	return stub(http.StatusNoContent, s.accountHeaders(acct))
+}
+
+func (c *RequestClient) DeleteAccount(ctx context.Context, a string, headers http.Header) *http.Response {
+	s := c.store
+	defer s.lock.Unlock()
+	if status := s.begin("DELETE", a); status != 0 {
+		return stub(status, nil)
+	}
+	if s.accounts[a] == nil {
+		return stub(http.StatusNotFound, nil)
+	}
+	delete(s.accounts, a)
+	return stub(http.StatusNoContent, nil)
+}
+
+func (c *RequestClient) PutContainer(ctx context.Context, a string, cn string, headers http.Header) *http.Response {
+	s := c.store
+	defer s.lock.Unlock()
+	if status := s.begin("PUT", a+"/"+cn); status != 0 {
+		return stub(status, nil)
+	}
+	// Like the proxy with account_autocreate on.
+	acct := s.accounts[a]
+	if acct == nil {
+		acct = &account{headers: http.Header{}, containers: map[string]*container{}}
+		s.accounts[a] = acct
+	}
+	con := acct.containers[cn]
+	if con == nil {
+		con = &container{headers: http.Header{}, objects: map[string]*object{}}
+		acct.containers[cn] = con
+		delete(acct.deleted, cn)
+		updateMeta(con.headers, headers, containerMeta, containerACLs...)
+		return stub(http.StatusCreated, nil)
+	}
+	updateMeta(con.headers, headers, containerMeta, containerACLs...)
+	return stub(http.StatusAccepted, nil)
+}
+
+func (c *RequestClient) PostContainer(ctx context.Context, a string, cn string, headers http.Header) *http.Response {
+	s := c.store
+	defer s.lock.Unlock()
+	if status := s.begin("POST", a+"/"+cn); status != 0 {
+		return stub(status, nil)
+	}
+	con := s.getContainer(a, cn)
+	if con == nil {
+		return stub(http.StatusNotFound, nil)
+	}
+	updateMeta(con.headers, headers, containerMeta, containerACLs...)
+	return stub(http.StatusNoContent, nil)
+}
+
+func (s *Store) containerHeaders(con *container) http.Header {
+	h := http.Header{}
+	var used int64
+	for _, o := range con.objects {
+		used += int64(len(o.data))
+	}
+	copyHeaders(h, con.headers, containerMeta, containerACLs...)
+	h.Set("X-Container-Object-Count", strconv.Itoa(len(con.objects)))
+	h.Set("X-Container-Bytes-Used", strconv.FormatInt(used, 10))
+	h.Set("X-Backend-Storage-Policy-Index", "0")
+	return h
+}
+
+// objectListingRecord is a container listing entry as the container server
+// writes it.
+type objectListingRecord struct {
+	Name         string `json:"name"`
+	Hash         string `json:"hash"`
+	Bytes        int64  `json:"bytes"`
+	ContentType  string `json:"content_type"`
+	LastModified string `json:"last_modified"`
+}
+
+func (c *RequestClient) GetContainerRaw(ctx context.Context, a string, cn string, options map[string]string, headers http.Header) *http.Response {
+	s := c.store
+	defer s.lock.Unlock()
+	if status := s.begin("GET", a+"/"+cn+"/"); status != 0 {
+		return stub(status, nil)
+	}
+	con := s.getContainer(a, cn)
+	if con == nil {
+		return stub(http.StatusNotFound, nil)
+	}
+	var names []string
+	for name := range con.objects {
+		names = append(names, name)
+	}
+	listed, subdirs := listNames(names, options)
+	listing := []interface{}{}
+	for _, name := range listed {
+		if subdirs[name] {
+			listing = append(listing, map[string]string{"subdir": name})
+			continue
+		}
+		o := con.objects[name]
+		listing = append(listing, &objectListingRecord{
+			Name:         name,
+			Hash:         o.headers.Get("Etag"),
+			Bytes:        int64(len(o.data)),
+			ContentType:  o.headers.Get("Content-Type"),
+			LastModified: o.timestamp.UTC().Format("2006-01-02T15:04:05.000000"),
+		})
+	}
+	return jsonResponse(listing, s.containerHeaders(con))
+}
+
+// GetContainerInfo builds the info from a HEAD, without caching it, so tests
+// see their changes right away.
+func (c *RequestClient) GetContainerInfo(ctx context.Context, a string, cn string) (*client.ContainerInfo, error) {
+	resp := c.HeadContainer(ctx, a, cn, nil)
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, client.ContainerNotFound
+	} else if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%d error retrieving info for container %s/%s", resp.StatusCode, a, cn)
+	}
+	return c.SetContainerInfo(ctx, a, cn, resp)
+}
+
+func (c *RequestClient) SetContainerInfo(ctx context.Context, a string, cn string, resp *http.Response) (*client.ContainerInfo, error) {
+	ci := &client.ContainerInfo{
+		Metadata:    map[string]string{},
+		SysMetadata: map[string]string{},
+		ReadACL:     resp.Header.Get("X-Container-Read"),
+		WriteACL:    resp.Header.Get("X-Container-Write"),
+		SyncKey:     resp.Header.Get("X-Container-Sync-Key"),
+		Timestamp:   resp.Header.Get("X-Timestamp"),
+	}
+	ci.ObjectCount, _ = strconv.ParseInt(resp.Header.Get("X-Container-Object-Count"), 10, 64)
+	ci.ObjectBytes, _ = strconv.ParseInt(resp.Header.Get("X-Container-Bytes-Used"), 10, 64)
+	ci.StoragePolicyIndex, _ = strconv.Atoi(resp.Header.Get("X-Backend-Storage-Policy-Index"))
+	for k := range resp.Header {
+		if strings.HasPrefix(k, "X-Container-Meta-") {
+			ci.Metadata[k[17:]] = resp.Header.Get(k)
+		} else if strings.HasPrefix(k, "X-Container-Sysmeta-") {
+			ci.SysMetadata[k[20:]] = resp.Header.Get(k)
+		}
+	}
+	return ci, nil
+}
+
+func (c *RequestClient) HeadContainer(ctx context.Context, a string, cn string, headers http.Header) *http.Response {
+	s := c.store
+	defer s.lock.Unlock()
+	if status := s.begin("HEAD", a+"/"+cn); status != 0 {
+		return stub(status, nil)
+	}
+	con := s.getContainer(a, cn)
+	if con == nil {
+		return stub(http.StatusNotFound, nil)
+	}
+	return stub(http.StatusNoContent, s.containerHeaders(con))
+}
+
+func (c *RequestClient) DeleteContainer(ctx context.Context, a string, cn string, headers http.Header) *http.Response {
+	s := c.store
+	defer s.lock.Unlock()
+	if status := s.begin("DELETE", a+"/"+cn); status != 0 {
+		return stub(status, nil)
+	}
+	con := s.getContainer(a, cn)
+	if con == nil {
+		return stub(http.StatusNotFound, nil)
+	} else if len(con.objects) > 0 {
+		return nectarutil.ResponseStub(http.StatusConflict, "There was a conflict when trying to complete your request.")
+	}
+	acct := s.accounts[a]
+	delete(acct.containers, cn)
+	if acct.deleted == nil {
+		acct.deleted = map[string]*container{}
+	}
+	acct.deleted[cn] = con
+	return stub(http.StatusNoContent, nil)
+}
+
+// RestoreContainer brings back a deleted container as if the container
+// servers' restore_window never ran out.
+func (c *RequestClient) RestoreContainer(ctx context.Context, a string, cn string, headers http.Header) *http.Response {
+	s := c.store
+	defer s.lock.Unlock()
+	if status := s.begin("RESTORE", a+"/"+cn); status != 0 {
+		return stub(status, nil)
+	}
+	acct := s.accounts[a]
+	if acct == nil {
+		return stub(http.StatusNotFound, nil)
+	} else if acct.containers[cn] != nil {
+		return stub(http.StatusConflict, nil)
+	}
+	con := acct.deleted[cn]
+	if con == nil {
+		return stub(http.StatusNotFound, nil)
+	}
+	delete(acct.deleted, cn)
+	acct.containers[cn] = con
+	return stub(http.StatusCreated, nil)
+}
+
+func (c *RequestClient) writeObject(method string, a string, cn string, obj string, headers http.Header, src io.Reader, appending bool) *http.Response {
+	// Read the body before locking, so a slow reader doesn't hold up the
+	// store.
+	data, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
+	}
+	s := c.store
+	defer s.lock.Unlock()
+	if status := s.begin(method, a+"/"+cn+"/"+obj); status != 0 {
+		return stub(status, nil)
+	}
+	con := s.getContainer(a, cn)
+	if con == nil {
+		return stub(http.StatusNotFound, nil)
+	}
+	existing := con.objects[obj]
+	if headers.Get("If-None-Match") == "*" && existing != nil {
+		return stub(http.StatusPreconditionFailed, nil)
+	}
+	if appending {
+		if existing == nil {
+			return stub(http.StatusNotFound, nil)
+		}
+		data = append(append([]byte{}, existing.data...), data...)
+	}
+	sum := md5.Sum(data)
+	etag := hex.EncodeToString(sum[:])
+	if expected := strings.Trim(headers.Get("Etag"), "\""); expected != "" && !appending && expected != etag {
+		return stub(http.StatusUnprocessableEntity, nil)
+	}
+	o := &object{headers: http.Header{}, data: data, timestamp: time.Now()}
+	if appending {
+		copyHeaders(o.headers, existing.headers, objectMeta, objectHeaders...)
+	} else {
+		copyHeaders(o.headers, headers, objectMeta, objectHeaders...)
+	}
+	if o.headers.Get("Content-Type") == "" {
+		o.headers.Set("Content-Type", "application/octet-stream")
+	}
+	o.headers.Set("Etag", etag)
+	con.objects[obj] = o
+	return stub(http.StatusCreated, http.Header{"Etag": {etag}})
+}
+
+func (c *RequestClient) PutObject(ctx context.Context, a string, cn string, obj string, headers http.Header, src io.Reader) *http.Response {
+	return c.writeObject("PUT", a, cn, obj, headers, src, false)
+}
+
+func (c *RequestClient) AppendObject(ctx context.Context, a string, cn string, obj string, headers http.Header, src io.Reader) *http.Response {
+	return c.writeObject("PUT", a, cn, obj, headers, src, true)
+}
+
+func (c *RequestClient) PostObject(ctx context.Context, a string, cn string, obj string, headers http.Header) *http.Response {
+	s := c.store
+	defer s.lock.Unlock()
+	if status := s.begin("POST", a+"/"+cn+"/"+obj); status != 0 {
+		return stub(status, nil)
+	}
+	con := s.getContainer(a, cn)
+	if con == nil || con.objects[obj] == nil {
+		return stub(http.StatusNotFound, nil)
+	}
+	// A POST replaces all of the object's metadata.
+	o := con.objects[obj]
+	for k := range o.headers {
+		if strings.HasPrefix(k, "X-Object-Meta-") || k == "X-Delete-At" {
+			delete(o.headers, k)
+		}
+	}
+	copyHeaders(o.headers, headers, objectMeta, objectHeaders...)
+	o.timestamp = time.Now()
+	return stub(http.StatusAccepted, nil)
+}
+
+// objectResponse returns the object's response headers, or a response if the
+// request's conditions mean it shouldn't be served.
+func (s *Store) objectResponse(o *object, headers http.Header) (http.Header, *http.Response) {
+	etag := o.headers.Get("Etag")
+	if m := headers.Get("If-Match"); m != "" && m != "*" && strings.Trim(m, "\"") != etag {
+		return nil, stub(http.StatusPreconditionFailed, nil)
+	}
+	if m := headers.Get("If-None-Match"); m != "" && (m == "*" || strings.Trim(m, "\"") == etag) {
+		return nil, stub(http.StatusNotModified, nil)
+	}
+	h := http.Header{}
+	copyHeaders(h, o.headers, objectMeta, objectHeaders...)
+	h.Set("Etag", etag)
+	h.Set("Content-Length", strconv.Itoa(len(o.data)))
+	h.Set("Last-Modified", o.timestamp.UTC().Format(time.RFC1123))
+	h.Set("X-Timestamp", common.CanonicalTimestampFromTime(o.timestamp))
+	return h, nil
+}
+
+func (c *RequestClient) GetObject(ctx context.Context, a string, cn string, obj string, headers http.Header) *http.Response {
+	s := c.store
+	defer s.lock.Unlock()
+	if status := s.begin("GET", a+"/"+cn+"/"+obj); status != 0 {
+		return stub(status, nil)
+	}
+	con := s.getContainer(a, cn)
+	if con == nil || con.objects[obj] == nil {
+		return stub(http.StatusNotFound, nil)
+	}
+	o := con.objects[obj]
+	h, resp := s.objectResponse(o, headers)
+	if resp != nil {
+		return resp
+	}
+	status, data := http.StatusOK, o.data
+	if rangeHeader := headers.Get("Range"); rangeHeader != "" {
+		ranges, err := common.ParseRange(rangeHeader, int64(len(data)))
+		if err != nil {
+			return stub(http.StatusRequestedRangeNotSatisfiable, nil)
+		}
+		// Multiple ranges would need a multipart body; the whole object is
+		// served instead, as the spec allows.
+		if len(ranges) == 1 {
+			r := ranges[0]
+			h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", r.Start, r.End-1, len(data)))
+			h.Set("Content-Length", strconv.FormatInt(r.End-r.Start, 10))
+			status, data = http.StatusPartialContent, data[r.Start:r.End]
+		}
+	}
+	resp = stub(status, h)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	return resp
+}
+
+func (c *RequestClient) HeadObject(ctx context.Context, a string, cn string, obj string, headers http.Header) *http.Response {
+	s := c.store
+	defer s.lock.Unlock()
+	if status := s.begin("HEAD", a+"/"+cn+"/"+obj); status != 0 {
+		return stub(status, nil)
+	}
+	con := s.getContainer(a, cn)
+	if con == nil || con.objects[obj] == nil {
+		return stub(http.StatusNotFound, nil)
+	}
+	h, resp := s.objectResponse(con.objects[obj], headers)
+	if resp != nil {
+		return resp
+	}
+	return stub(http.StatusOK, h)
+}
+
+func (c *RequestClient) DeleteObject(ctx context.Context, a string, cn string, obj string, headers http.Header) *http.Response {
+	s := c.store
+	defer s.lock.Unlock()
+	if status := s.begin("DELETE", a+"/"+cn+"/"+obj); status != 0 {
+		return stub(status, nil)
+	}
+	con := s.getContainer(a, cn)
+	if con == nil || con.objects[obj] == nil {
+		return stub(http.StatusNotFound, nil)
+	}
+	delete(con.objects, obj)
+	return stub(http.StatusNoContent, nil)
+}
+
+// GetObjectInPolicy ignores the policy, since the store only has one.
+func (c *RequestClient) GetObjectInPolicy(ctx context.Context, policy int, a string, cn string, obj string, headers http.Header) *http.Response {
+	return c.GetObject(ctx, a, cn, obj, headers)
+}
+
+// DeleteObjectInPolicy ignores the policy, since the store only has one.
+func (c *RequestClient) DeleteObjectInPolicy(ctx context.Context, policy int, a string, cn string, obj string, headers http.Header) *http.Response {
+	return c.DeleteObject(ctx, a, cn, obj, headers)
+}
+
+// ObjectReplicaMetadata isn't supported; the store has no replicas.
+func (c *RequestClient) ObjectReplicaMetadata(ctx context.Context, a string, cn string, obj string) ([]*client.ReplicaMetadata, *http.Response) {
+	return nil, nectarutil.ResponseStub(http.StatusNotImplemented, "")
+}
+
+func (c *RequestClient) ObjectRingFor(ctx context.Context, a string, cn string) (ring.Ring, *http.Response) {
+	return &test.FakeRing{}, nil
+}
+
+func (c *RequestClient) ContainerRing() ring.Ring {
+	return &test.FakeRing{}
+}
+
+func (c *RequestClient) AccountRing() ring.Ring {
+	return &test.FakeRing{}
+}
+
+func (c *RequestClient) SetUserAgent(v string) {
+	c.UserAgent = v
+}
+
+func (c *RequestClient) SetPriority(v string) {
+	c.Priority = v
+}
@@ -0,0 +1,121 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package clienttest
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/client"
+)
+
+func TestRequestClient(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	c := NewProxyClient(s).NewRequestClient(nil, nil, nil)
+
+	resp := c.PutObject(ctx, "a", "c", "o", http.Header{}, bytes.NewBufferString("hello"))
+	require.Equal(t, 404, resp.StatusCode)
+	_, err := c.GetContainerInfo(ctx, "a", "c")
+	require.Equal(t, client.ContainerNotFound, err)
+
+	resp = c.PutContainer(ctx, "a", "c", http.Header{"X-Container-Meta-Color": {"blue"}, "X-Container-Sysmeta-Thing": {"x"}, "X-Container-Read": {".r:*"}})
+	require.Equal(t, 201, resp.StatusCode)
+	resp = c.PutObject(ctx, "a", "c", "o", http.Header{"Etag": {"nope"}}, bytes.NewBufferString("hello"))
+	require.Equal(t, 422, resp.StatusCode)
+	resp = c.PutObject(ctx, "a", "c", "o", http.Header{"Content-Type": {"text/plain"}, "X-Object-Meta-Size": {"small"}}, bytes.NewBufferString("hello"))
+	require.Equal(t, 201, resp.StatusCode)
+	require.Equal(t, "5d41402abc4b2a76b9719d911017c592", resp.Header.Get("Etag"))
+	resp = c.PutObject(ctx, "a", "c", "o", http.Header{"If-None-Match": {"*"}}, bytes.NewBufferString("hello"))
+	require.Equal(t, 412, resp.StatusCode)
+	resp = c.AppendObject(ctx, "a", "c", "o", http.Header{}, bytes.NewBufferString(" world"))
+	require.Equal(t, 201, resp.StatusCode)
+
+	resp = c.GetObject(ctx, "a", "c", "o", http.Header{"Range": {"bytes=6-"}})
+	require.Equal(t, 206, resp.StatusCode)
+	body, _ := ioutil.ReadAll(resp.Body)
+	require.Equal(t, "world", string(body))
+	require.Equal(t, "bytes 6-10/11", resp.Header.Get("Content-Range"))
+	require.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+	require.Equal(t, "small", resp.Header.Get("X-Object-Meta-Size"))
+
+	resp = c.PostObject(ctx, "a", "c", "o", http.Header{"X-Object-Meta-Shape": {"round"}})
+	require.Equal(t, 202, resp.StatusCode)
+	resp = c.HeadObject(ctx, "a", "c", "o", http.Header{})
+	require.Equal(t, 200, resp.StatusCode)
+	require.Equal(t, "", resp.Header.Get("X-Object-Meta-Size"))
+	require.Equal(t, "round", resp.Header.Get("X-Object-Meta-Shape"))
+	require.Equal(t, "11", resp.Header.Get("Content-Length"))
+
+	ci, err := c.GetContainerInfo(ctx, "a", "c")
+	require.Nil(t, err)
+	require.Equal(t, int64(1), ci.ObjectCount)
+	require.Equal(t, int64(11), ci.ObjectBytes)
+	require.Equal(t, map[string]string{"Color": "blue"}, ci.Metadata)
+	require.Equal(t, map[string]string{"Thing": "x"}, ci.SysMetadata)
+	require.Equal(t, ".r:*", ci.ReadACL)
+
+	require.Equal(t, 409, c.DeleteContainer(ctx, "a", "c", nil).StatusCode)
+	require.Equal(t, 204, c.DeleteObject(ctx, "a", "c", "o", nil).StatusCode)
+	require.Equal(t, 404, c.HeadObject(ctx, "a", "c", "o", nil).StatusCode)
+	require.Equal(t, 204, c.DeleteContainer(ctx, "a", "c", nil).StatusCode)
+}
+
+func TestFailures(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	c := NewRequestClient(s)
+	require.Equal(t, 201, c.PutContainer(ctx, "a", "c", nil).StatusCode)
+	s.Fail(Failure{Method: "HEAD", Path: "a/c/", StatusCode: 503, Count: 2})
+	s.Fail(Failure{Path: "a/d", StatusCode: 507})
+	require.Equal(t, 204, c.HeadContainer(ctx, "a", "c", nil).StatusCode)
+	require.Equal(t, 503, c.HeadObject(ctx, "a", "c", "o", nil).StatusCode)
+	require.Equal(t, 503, c.HeadObject(ctx, "a", "c", "o", nil).StatusCode)
+	require.Equal(t, 404, c.HeadObject(ctx, "a", "c", "o", nil).StatusCode)
+	for i := 0; i < 3; i++ {
+		require.Equal(t, 507, c.PutContainer(ctx, "a", "d", nil).StatusCode)
+	}
+	s.ClearFailures()
+	require.Equal(t, 201, c.PutContainer(ctx, "a", "d", nil).StatusCode)
+	require.Equal(t, 4, s.Requests["HEAD"])
+	require.Equal(t, 5, s.Requests["PUT"])
+}
+
+func TestNectarClient(t *testing.T) {
+	s := NewStore()
+	c := NewClient(s, "a")
+	require.Equal(t, 201, c.PutContainer("c", nil).StatusCode)
+	for _, name := range []string{"d/2", "a", "d/1", "b"} {
+		require.Equal(t, 201, c.PutObject("c", name, nil, bytes.NewBufferString(name)).StatusCode)
+	}
+	listing, resp := c.GetContainer("c", "a", "", 2, "", "", false, nil)
+	require.Equal(t, 200, resp.StatusCode)
+	require.Equal(t, 2, len(listing))
+	require.Equal(t, "b", listing[0].Name)
+	require.Equal(t, int64(3), listing[1].Bytes)
+	listing, _ = c.GetContainer("c", "", "", 0, "", "/", true, nil)
+	require.Equal(t, 3, len(listing))
+	require.Equal(t, "d/", listing[0].Subdir)
+	require.Equal(t, "a", listing[2].Name)
+	containers, resp := c.GetAccount("", "", 0, "", "", false, nil)
+	require.Equal(t, 200, resp.StatusCode)
+	require.Equal(t, 1, len(containers))
+	require.Equal(t, int64(4), containers[0].Count)
+}
@@ -0,0 +1,118 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/srv"
+	"go.uber.org/zap"
+)
+
+// DeviceHealthEntry describes a device the proxy is currently avoiding.
+type DeviceHealthEntry struct {
+	Device string    `json:"device"`
+	State  string    `json:"state"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+}
+
+type deviceState struct {
+	since time.Time
+	until time.Time
+}
+
+// deviceHealth tracks devices that have answered 507 Insufficient Storage.
+// Those devices are considered full for fullPeriod and writes are sent to
+// other primaries or handoffs instead.
+type deviceHealth struct {
+	lock       sync.Mutex
+	full       map[string]*deviceState
+	fullPeriod time.Duration
+	logger     srv.LowLevelLogger
+	now        func() time.Time
+}
+
+func newDeviceHealth(fullPeriod time.Duration, logger srv.LowLevelLogger) *deviceHealth {
+	return &deviceHealth{full: map[string]*deviceState{}, fullPeriod: fullPeriod, logger: logger, now: time.Now}
+}
+
+func deviceHealthKey(dev *ring.Device) string {
+	return fmt.Sprintf("%s:%d/%s", dev.Ip, dev.Port, dev.Device)
+}
+
+// markFull records a 507 from dev. A capacity alert is logged the first time
+// a device is seen full, not on every request it rejects.
+func (h *deviceHealth) markFull(dev *ring.Device) {
+	if h == nil || h.fullPeriod <= 0 {
+		return
+	}
+	key := deviceHealthKey(dev)
+	now := h.now()
+	h.lock.Lock()
+	state := h.full[key]
+	alert := state == nil || !now.Before(state.until)
+	if alert {
+		state = &deviceState{since: now}
+		h.full[key] = state
+	}
+	state.until = now.Add(h.fullPeriod)
+	h.lock.Unlock()
+	if alert && h.logger != nil {
+		h.logger.Error("Device full", zap.String("device", key), zap.Duration("avoidFor", h.fullPeriod))
+	}
+}
+
+func (h *deviceHealth) isFull(dev *ring.Device) bool {
+	if h == nil {
+		return false
+	}
+	key := deviceHealthKey(dev)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	state := h.full[key]
+	if state == nil {
+		return false
+	}
+	if !h.now().Before(state.until) {
+		delete(h.full, key)
+		return false
+	}
+	return true
+}
+
+func (h *deviceHealth) entries() []DeviceHealthEntry {
+	entries := []DeviceHealthEntry{}
+	if h == nil {
+		return entries
+	}
+	now := h.now()
+	h.lock.Lock()
+	for key, state := range h.full {
+		if !now.Before(state.until) {
+			delete(h.full, key)
+			continue
+		}
+		entries = append(entries, DeviceHealthEntry{Device: key, State: "full", Since: state.since, Until: state.until})
+	}
+	h.lock.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Device < entries[j].Device })
+	return entries
+}
@@ -0,0 +1,98 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/test"
+)
+
+func TestDeviceHealthFullExpires(t *testing.T) {
+	now := time.Unix(1000, 0)
+	h := newDeviceHealth(time.Minute, nil)
+	h.now = func() time.Time { return now }
+	dev := &ring.Device{Ip: "127.0.0.1", Port: 6000, Device: "sda"}
+	require.False(t, h.isFull(dev))
+	h.markFull(dev)
+	require.True(t, h.isFull(dev))
+	entries := h.entries()
+	require.Equal(t, 1, len(entries))
+	require.Equal(t, "127.0.0.1:6000/sda", entries[0].Device)
+	require.Equal(t, "full", entries[0].State)
+	require.Equal(t, now.Add(time.Minute), entries[0].Until)
+	now = now.Add(time.Minute)
+	require.False(t, h.isFull(dev))
+	require.Equal(t, 0, len(h.entries()))
+}
+
+func TestDeviceHealthDisabled(t *testing.T) {
+	h := newDeviceHealth(0, nil)
+	dev := &ring.Device{Ip: "127.0.0.1", Port: 6000, Device: "sda"}
+	h.markFull(dev)
+	require.False(t, h.isFull(dev))
+	var nilHealth *deviceHealth
+	nilHealth.markFull(dev)
+	require.False(t, nilHealth.isFull(dev))
+}
+
+func TestWriteNodesSkipFullDevices(t *testing.T) {
+	r := &fakeRing{
+		FakeRing: &test.FakeRing{
+			MockMoreNodes: &ring.Device{Id: 3, Ip: "127.0.0.4", Port: 6000, Device: "sdd"},
+		},
+		nodes: []*ring.Device{
+			{Id: 0, Ip: "127.0.0.1", Port: 6000, Device: "sda"},
+			{Id: 1, Ip: "127.0.0.2", Port: 6000, Device: "sdb"},
+			{Id: 2, Ip: "127.0.0.3", Port: 6000, Device: "sdc"},
+		},
+	}
+	a := newClientRingFilter(r, "", "", "", 0)
+	a.health = newDeviceHealth(time.Minute, nil)
+	a.health.markFull(r.nodes[1])
+	devs, _ := a.getWriteNodes(1)
+	require.Equal(t, 3, len(devs))
+	require.Equal(t, 0, devs[0].Id)
+	require.Equal(t, 2, devs[1].Id)
+	require.Equal(t, 3, devs[2].Id)
+}
+
+type noMoreNodes struct{}
+
+func (noMoreNodes) Next() *ring.Device {
+	return nil
+}
+
+func TestWriteNodesFullDevicesLast(t *testing.T) {
+	r := &fakeRing{
+		FakeRing: &test.FakeRing{MockGetMoreNodes: noMoreNodes{}},
+		nodes: []*ring.Device{
+			{Id: 0, Ip: "127.0.0.1", Port: 6000, Device: "sda"},
+			{Id: 1, Ip: "127.0.0.2", Port: 6000, Device: "sdb"},
+		},
+	}
+	a := newClientRingFilter(r, "", "", "", 3)
+	a.health = newDeviceHealth(time.Minute, nil)
+	a.health.markFull(r.nodes[0])
+	devs, more := a.getWriteNodes(1)
+	require.Equal(t, 2, len(devs))
+	require.Equal(t, 1, devs[0].Id)
+	require.Equal(t, 0, devs[1].Id)
+	require.Nil(t, more.Next())
+}
@@ -2,7 +2,6 @@ package client
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -21,6 +20,14 @@ type directClient struct {
 }
 
 var _ nectar.Client = &directClient{}
+var _ ListingClient = &directClient{}
+
+// ListingClient is implemented by the clients from NewDirectClient, for
+// callers that want to stream listings rather than hold them in memory.
+type ListingClient interface {
+	GetAccountListing(marker string, endMarker string, limit int, prefix string, delimiter string, reverse bool, headers map[string]string) (*ContainerListing, *http.Response)
+	GetContainerListing(container string, marker string, endMarker string, limit int, prefix string, delimiter string, reverse bool, headers map[string]string) (*ObjectListing, *http.Response)
+}
 
 func NewDirectClient(account string, cnf srv.ConfigLoader, certFile, keyFile string, logger srv.LowLevelLogger) (nectar.Client, error) {
 	policies, err := cnf.GetPolicies()
@@ -31,7 +38,16 @@ func NewDirectClient(account string, cnf srv.ConfigLoader, certFile, keyFile str
 	if err != nil {
 		return nil, fmt.Errorf("Could not make client: %v", err)
 	}
-	return &directClient{pc: pdc.NewRequestClient(nil, nil, logger), account: account}, nil
+	pc := pdc.NewRequestClient(nil, nil, logger)
+	// Only daemons talk to the backends directly.
+	pc.SetPriority(common.PriorityBackground)
+	return &directClient{pc: pc, account: account}, nil
+}
+
+// NewRequestDirectClient wraps pc in a nectar.Client for the account, for
+// callers that already have a RequestClient, such as a fake in tests.
+func NewRequestDirectClient(pc RequestClient, account string) nectar.Client {
+	return &directClient{pc: pc, account: account}
 }
 
 func (c *directClient) SetUserAgent(v string) {
@@ -51,20 +67,31 @@ func (c *directClient) PostAccount(headers map[string]string) *http.Response {
 }
 
 func (c *directClient) GetAccount(marker string, endMarker string, limit int, prefix string, delimiter string, reverse bool, headers map[string]string) ([]*nectar.ContainerRecord, *http.Response) {
-	resp := c.GetAccountRaw(marker, endMarker, limit, prefix, delimiter, reverse, headers)
-	if resp.StatusCode/100 != 2 {
+	listing, resp := c.GetAccountListing(marker, endMarker, limit, prefix, delimiter, reverse, headers)
+	if listing == nil {
 		return nil, resp
 	}
 	var accountListing []*nectar.ContainerRecord
-	if err := json.NewDecoder(resp.Body).Decode(&accountListing); err != nil {
-		resp.Body.Close()
+	for listing.Next() {
+		accountListing = append(accountListing, listing.Record())
+	}
+	if err := listing.Err(); err != nil {
 		// FIXME. Log something.
 		return nil, nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
 	}
-	resp.Body.Close()
 	return accountListing, resp
 }
 
+// GetAccountListing is like GetAccount, but the containers are decoded as
+// they're read from the response instead of all at once.
+func (c *directClient) GetAccountListing(marker string, endMarker string, limit int, prefix string, delimiter string, reverse bool, headers map[string]string) (*ContainerListing, *http.Response) {
+	resp := c.GetAccountRaw(marker, endMarker, limit, prefix, delimiter, reverse, headers)
+	if resp.StatusCode/100 != 2 {
+		return nil, resp
+	}
+	return NewContainerListing(resp), resp
+}
+
 func (c *directClient) GetAccountRaw(marker string, endMarker string, limit int, prefix string, delimiter string, reverse bool, headers map[string]string) *http.Response {
 	options := map[string]string{
 		"format":     "json",
@@ -99,20 +126,31 @@ func (c *directClient) PostContainer(container string, headers map[string]string
 }
 
 func (c *directClient) GetContainer(container string, marker string, endMarker string, limit int, prefix string, delimiter string, reverse bool, headers map[string]string) ([]*nectar.ObjectRecord, *http.Response) {
-	resp := c.GetContainerRaw(container, marker, endMarker, limit, prefix, delimiter, reverse, headers)
-	if resp.StatusCode/100 != 2 {
+	listing, resp := c.GetContainerListing(container, marker, endMarker, limit, prefix, delimiter, reverse, headers)
+	if listing == nil {
 		return nil, resp
 	}
 	var containerListing []*nectar.ObjectRecord
-	if err := json.NewDecoder(resp.Body).Decode(&containerListing); err != nil {
-		resp.Body.Close()
+	for listing.Next() {
+		containerListing = append(containerListing, listing.Record())
+	}
+	if err := listing.Err(); err != nil {
 		// FIXME. Log something.
 		return nil, nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
 	}
-	resp.Body.Close()
 	return containerListing, resp
 }
 
+// GetContainerListing is like GetContainer, but the objects are decoded as
+// they're read from the response instead of all at once.
+func (c *directClient) GetContainerListing(container string, marker string, endMarker string, limit int, prefix string, delimiter string, reverse bool, headers map[string]string) (*ObjectListing, *http.Response) {
+	resp := c.GetContainerRaw(container, marker, endMarker, limit, prefix, delimiter, reverse, headers)
+	if resp.StatusCode/100 != 2 {
+		return nil, resp
+	}
+	return NewObjectListing(resp), resp
+}
+
 func (c *directClient) GetContainerRaw(container string, marker string, endMarker string, limit int, prefix string, delimiter string, reverse bool, headers map[string]string) *http.Response {
 	options := map[string]string{
 		"format":     "json",
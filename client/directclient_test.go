@@ -61,4 +61,12 @@ func TestAddUpdateHeaders(t *testing.T) {
 	addUpdateHeaders("X-Container", headers, devices, 3, 4)
 	require.Equal(t, "", headers.Get("X-Container-Host"))
 	require.Equal(t, "", headers.Get("X-Container-Device"))
+
+	require.Equal(t, "", headers.Get("X-Container-Path-Prefix"))
+
+	devices[1].PathPrefix = "/storage"
+	headers = make(http.Header)
+	addUpdateHeaders("X-Container", headers, devices, 0, 1)
+	require.Equal(t, "127.0.0.1:1212,127.0.0.2:2345,127.0.0.3:6789", headers.Get("X-Container-Host"))
+	require.Equal(t, ",/storage,", headers.Get("X-Container-Path-Prefix"))
 }
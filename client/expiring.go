@@ -0,0 +1,66 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/tracing"
+	"go.uber.org/zap"
+)
+
+// queueExpiring adds an object just written with an X-Delete-At to the
+// expiring objects queue. The entry's etag is the object's timestamp, as
+// with the misplaced objects queue. A failure is only logged; the object
+// will still 404 once it expires, and the auditor removes it eventually.
+func (c *requestClient) queueExpiring(ctx context.Context, account, container, obj string, headers http.Header) {
+	deleteAt, err := strconv.ParseInt(headers.Get("X-Delete-At"), 10, 64)
+	if err != nil {
+		return
+	}
+	timestamp := headers.Get("X-Timestamp")
+	if timestamp == "" {
+		timestamp = common.GetTimestamp()
+	}
+	queueContainer := common.ExpiringQueueContainer(deleteAt)
+	entry := common.ExpiringQueueEntry(deleteAt, account, container, obj)
+	partition := c.pdc.ContainerRing.GetPartition(common.ExpiringObjectsAccount, queueContainer, "")
+	resp := c.pdc.quorumResponse(c.pdc.ContainerRing, partition, func(i int, dev *ring.Device) (*http.Request, error) {
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
+			common.Urlencode(common.ExpiringObjectsAccount), common.Urlencode(queueContainer), common.Urlencode(entry))
+		req, err := http.NewRequest("PUT", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", c.pdc.userAgent)
+		req = req.WithContext(tracing.CopySpanFromContext(ctx))
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Size", "0")
+		req.Header.Set("X-Content-Type", "text/plain")
+		req.Header.Set("X-Etag", timestamp)
+		req.Header.Set("X-Backend-Storage-Policy-Index", "0")
+		return req, nil
+	})
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		c.Logger.Error("Unable to queue expiring object", zap.String("entry", entry), zap.Int("status", resp.StatusCode))
+	}
+}
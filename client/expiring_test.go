@@ -0,0 +1,70 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/test"
+	"go.uber.org/zap"
+)
+
+func TestQueueExpiring(t *testing.T) {
+	r := &fakeRing{
+		FakeRing: &test.FakeRing{MockGetMoreNodes: &handoffNodes{}},
+		nodes: []*ring.Device{
+			{Id: 0, Scheme: "http", Ip: "127.0.0.1", Port: 6001, Device: "sda"},
+			{Id: 1, Scheme: "http", Ip: "127.0.0.2", Port: 6001, Device: "sdb"},
+			{Id: 2, Scheme: "http", Ip: "127.0.0.3", Port: 6001, Device: "sdc"},
+		},
+	}
+	var lock sync.Mutex
+	var queued []*http.Request
+	record := RequestInterceptor(func(req *http.Request, next common.HTTPClient) (*http.Response, error) {
+		lock.Lock()
+		queued = append(queued, req)
+		lock.Unlock()
+		return &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})
+	c := &requestClient{pdc: &proxyClient{client: &interceptClient{interceptor: record}, Logger: zap.NewNop(),
+		ContainerRing: newClientRingFilter(r, "", "", "", 0)}, Logger: zap.NewNop()}
+	c.queueExpiring(context.Background(), "a", "c", "some/obj", http.Header{"X-Delete-At": {"1500000000"}, "X-Timestamp": {"1499990000.00000"}})
+	lock.Lock()
+	defer lock.Unlock()
+	require.Equal(t, 3, len(queued))
+	for _, req := range queued {
+		require.Equal(t, "PUT", req.Method)
+		require.True(t, strings.HasSuffix(req.URL.Path, "/.expiring_objects/1499997600/1500000000-a/c/some/obj"), req.URL.Path)
+		require.Equal(t, "1499990000.00000", req.Header.Get("X-Timestamp"))
+		require.Equal(t, "1499990000.00000", req.Header.Get("X-Etag"))
+		require.Equal(t, "0", req.Header.Get("X-Size"))
+	}
+
+	// Without a usable X-Delete-At there's nothing to queue.
+	queued = nil
+	lock.Unlock()
+	c.queueExpiring(context.Background(), "a", "c", "o", http.Header{"X-Delete-At": {"soon"}})
+	lock.Lock()
+	require.Nil(t, queued)
+}
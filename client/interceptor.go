@@ -0,0 +1,191 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/troubling/hummingbird/common"
+)
+
+// RequestInterceptor sees each backend request a ProxyClient makes. It can
+// pass the request on with next, change the response, or answer it itself,
+// for testing how the client copes with misbehaving backends.
+type RequestInterceptor func(req *http.Request, next common.HTTPClient) (*http.Response, error)
+
+// interceptClient runs backend requests through the proxyClient's
+// RequestInterceptor, if it has one.
+type interceptClient struct {
+	common.HTTPClient
+	lock        sync.RWMutex
+	interceptor RequestInterceptor
+}
+
+func (ic *interceptClient) Do(req *http.Request) (*http.Response, error) {
+	ic.lock.RLock()
+	interceptor := ic.interceptor
+	ic.lock.RUnlock()
+	if interceptor == nil {
+		return ic.HTTPClient.Do(req)
+	}
+	return interceptor(req, ic.HTTPClient)
+}
+
+// SetRequestInterceptor has the ProxyClient, which must be one from
+// NewProxyClient, send its backend requests through ri; a nil ri removes it.
+func SetRequestInterceptor(pc ProxyClient, ri RequestInterceptor) error {
+	c, ok := pc.(*proxyClient)
+	if !ok || c.intercept == nil {
+		return errors.New("ProxyClient doesn't support request interceptors")
+	}
+	c.intercept.lock.Lock()
+	c.intercept.interceptor = ri
+	c.intercept.lock.Unlock()
+	return nil
+}
+
+// ErrInjectedFault is the error requests fail with for a Fault with Fail set
+// and no Err of its own.
+var ErrInjectedFault = errors.New("injected fault")
+
+// Fault is one kind of misbehavior for a FaultInjector. The empty string
+// matches anything for Host, Device and Method.
+type Fault struct {
+	// Host is the backend's ip:port.
+	Host   string
+	Device string
+	Method string
+	// Probability is the chance, from 0 to 1, that a matching request gets
+	// the fault; 0 is taken as 1.
+	Probability float64
+	// Delay is how long to wait before doing the rest.
+	Delay time.Duration
+	// Fail makes the request fail with Err, or with StatusCode as the
+	// response if that's set.
+	Fail       bool
+	Err        error
+	StatusCode int
+	// Corrupt flips bits in the response's body.
+	Corrupt bool
+}
+
+// FaultInjector makes backend requests fail, stall or return corrupt data
+// according to its Faults; its Intercept method is a RequestInterceptor. Each
+// request gets the first of the Faults that matches it and whose Probability
+// comes up.
+type FaultInjector struct {
+	Faults []Fault
+	lock   sync.Mutex
+	rand   *rand.Rand
+}
+
+// NewFaultInjector returns a FaultInjector whose choices are repeatable for
+// the same seed.
+func NewFaultInjector(seed int64, faults ...Fault) *FaultInjector {
+	return &FaultInjector{Faults: faults, rand: rand.New(rand.NewSource(seed))}
+}
+
+// requestDevice finds the device name in a backend request's path, which is
+// the part before the partition number.
+func requestDevice(req *http.Request) string {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	for i := 1; i < len(parts); i++ {
+		if _, err := strconv.ParseUint(parts[i], 10, 64); err == nil {
+			return parts[i-1]
+		}
+	}
+	return ""
+}
+
+func (f *FaultInjector) choose(req *http.Request) *Fault {
+	device := requestDevice(req)
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for i := range f.Faults {
+		fault := &f.Faults[i]
+		if (fault.Host != "" && fault.Host != req.URL.Host) || (fault.Device != "" && fault.Device != device) || (fault.Method != "" && fault.Method != req.Method) {
+			continue
+		}
+		if fault.Probability > 0 && f.rand.Float64() >= fault.Probability {
+			continue
+		}
+		return fault
+	}
+	return nil
+}
+
+// Intercept applies the fault chosen for req, if any.
+func (f *FaultInjector) Intercept(req *http.Request, next common.HTTPClient) (*http.Response, error) {
+	fault := f.choose(req)
+	if fault == nil {
+		return next.Do(req)
+	}
+	if fault.Delay > 0 {
+		select {
+		case <-time.After(fault.Delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	if fault.Fail {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		if fault.StatusCode != 0 {
+			return &http.Response{
+				StatusCode: fault.StatusCode,
+				Status:     http.StatusText(fault.StatusCode),
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+				Request:    req,
+			}, nil
+		} else if fault.Err != nil {
+			return nil, fault.Err
+		}
+		return nil, ErrInjectedFault
+	}
+	resp, err := next.Do(req)
+	if err == nil && fault.Corrupt {
+		resp.Body = &corruptReader{ReadCloser: resp.Body}
+	}
+	return resp, err
+}
+
+// corruptReader flips the low bit of every 512th byte, so the length is
+// right but checksums aren't.
+type corruptReader struct {
+	io.ReadCloser
+	offset int64
+}
+
+func (c *corruptReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	for i := 0; i < n; i++ {
+		if (c.offset+int64(i))%512 == 0 {
+			p[i] ^= 1
+		}
+	}
+	c.offset += int64(n)
+	return n, err
+}
@@ -0,0 +1,122 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/test"
+	"go.uber.org/zap"
+)
+
+type bodyBackend string
+
+func (b bodyBackend) Do(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: 200, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(string(b)))}, nil
+}
+
+func TestFaultInjector(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://127.0.0.1:6000/sdb/12/a/c/o", nil)
+	require.Nil(t, err)
+	require.Equal(t, "sdb", requestDevice(req))
+
+	backend := bodyBackend("hello")
+	boom := errors.New("boom")
+	fi := NewFaultInjector(1,
+		Fault{Device: "sda", Fail: true, StatusCode: 507},
+		Fault{Method: "PUT", Fail: true, Err: boom},
+		Fault{Host: "127.0.0.1:6000", Corrupt: true},
+	)
+	resp, err := fi.Intercept(req, backend)
+	require.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	require.Equal(t, "iello", string(body))
+
+	req.Method = "PUT"
+	_, err = fi.Intercept(req, backend)
+	require.Equal(t, boom, err)
+
+	req, _ = http.NewRequest("GET", "http://127.0.0.2:6000/sda/12/a/c/o", nil)
+	resp, err = fi.Intercept(req, backend)
+	require.Nil(t, err)
+	require.Equal(t, 507, resp.StatusCode)
+
+	req, _ = http.NewRequest("GET", "http://127.0.0.2:6000/sdb/12/a/c/o", nil)
+	resp, err = fi.Intercept(req, backend)
+	require.Nil(t, err)
+	body, _ = ioutil.ReadAll(resp.Body)
+	require.Equal(t, "hello", string(body))
+
+	// About half of the requests fail with a probability of 0.5.
+	fi = NewFaultInjector(1, Fault{Probability: 0.5, Fail: true})
+	failed := 0
+	for i := 0; i < 1000; i++ {
+		if _, err := fi.Intercept(req, backend); err == ErrInjectedFault {
+			failed++
+		}
+	}
+	require.True(t, failed > 400 && failed < 600)
+
+	fi = NewFaultInjector(1, Fault{Delay: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = fi.Intercept(req.WithContext(ctx), backend)
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestRequestInterceptorHandoff(t *testing.T) {
+	backends := fakeBackends{}
+	var handoffs handoffNodes
+	var primaries []*ring.Device
+	for i := 0; i < 4; i++ {
+		dev := &ring.Device{Id: i, Region: 1, Zone: i + 1, Ip: fmt.Sprintf("127.0.0.%d", i+1), Port: 6000, Device: fmt.Sprintf("sd%c", 'a'+i)}
+		if i < 3 {
+			primaries = append(primaries, dev)
+		} else {
+			handoffs = append(handoffs, dev)
+		}
+		backends[fmt.Sprintf("127.0.0.%d:6000", i+1)] = fakeBackend{http.StatusOK, "0000000100.00000"}
+	}
+	c := &proxyClient{Logger: zap.NewNop(), intercept: &interceptClient{HTTPClient: backends}}
+	c.client = c.intercept
+	require.Nil(t, SetRequestInterceptor(c, NewFaultInjector(1,
+		Fault{Device: "sda", Fail: true, StatusCode: 503},
+		Fault{Device: "sdb", Fail: true},
+		Fault{Device: "sdc", Fail: true},
+	).Intercept))
+	r := &fakeRing{FakeRing: &test.FakeRing{MockGetMoreNodes: &handoffs}, nodes: primaries}
+	// Only the handoff isn't failing.
+	resp := c.firstResponse(newClientRingFilter(r, "", "", "", 0), 0, func(dev *ring.Device) (*http.Request, error) {
+		return http.NewRequest("GET", fmt.Sprintf("http://%s:%d/%s/0/a/c/o", dev.Ip, dev.Port, dev.Device), nil)
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NotNil(t, SetRequestInterceptor(&proxyClient{}, nil))
+	require.Nil(t, SetRequestInterceptor(c, nil))
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:6000/sda/0/a/c/o", nil)
+	resp, err := c.client.Do(req)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,171 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/troubling/nectar"
+	"github.com/troubling/nectar/nectarutil"
+)
+
+// ErrListingTooLarge is the body of the error response to a listing bigger
+// than the proxy's max_listing_bytes.
+var ErrListingTooLarge = errors.New("Listing too large")
+
+// limitListing replaces a listing response bigger than limit bytes with a
+// 500 error. A listing without a Content-Length, as the container servers
+// stream them, is read into memory first, up to limit bytes, so it can still
+// be refused rather than cut off partway through.
+func limitListing(resp *http.Response, limit int64) *http.Response {
+	if limit <= 0 || resp.StatusCode/100 != 2 {
+		return resp
+	}
+	if length, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil {
+		if length > limit {
+			resp.Body.Close()
+			return nectarutil.ResponseStub(http.StatusInternalServerError, ErrListingTooLarge.Error())
+		}
+		return resp
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	resp.Body.Close()
+	if err != nil {
+		return nectarutil.ResponseStub(http.StatusServiceUnavailable, "")
+	} else if int64(len(body)) > limit {
+		return nectarutil.ResponseStub(http.StatusInternalServerError, ErrListingTooLarge.Error())
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return resp
+}
+
+// listingDecoder reads the records of a JSON listing one at a time, so only
+// the record being decoded has to be held in memory and the body is only
+// read as fast as the caller consumes it.
+type listingDecoder struct {
+	body    io.ReadCloser
+	dec     *json.Decoder
+	started bool
+	done    bool
+	err     error
+}
+
+func newListingDecoder(body io.ReadCloser) *listingDecoder {
+	return &listingDecoder{body: body, dec: json.NewDecoder(body)}
+}
+
+func (l *listingDecoder) next(v interface{}) bool {
+	if l.done {
+		return false
+	}
+	if !l.started {
+		l.started = true
+		if t, err := l.dec.Token(); err != nil {
+			return l.fail(err)
+		} else if d, ok := t.(json.Delim); !ok || d != '[' {
+			return l.fail(fmt.Errorf("Listing isn't a JSON array"))
+		}
+	}
+	if !l.dec.More() {
+		if _, err := l.dec.Token(); err != nil {
+			return l.fail(err)
+		}
+		l.Close()
+		return false
+	}
+	if err := l.dec.Decode(v); err != nil {
+		return l.fail(err)
+	}
+	return true
+}
+
+func (l *listingDecoder) fail(err error) bool {
+	l.err = err
+	l.Close()
+	return false
+}
+
+// Err returns the error, if any, that stopped the listing early.
+func (l *listingDecoder) Err() error {
+	return l.err
+}
+
+// Close releases the listing's response body; it only needs to be called if
+// the listing isn't read to the end.
+func (l *listingDecoder) Close() error {
+	if l.done {
+		return nil
+	}
+	l.done = true
+	return l.body.Close()
+}
+
+// ContainerListing iterates over the containers in an account listing.
+type ContainerListing struct {
+	*listingDecoder
+	record *nectar.ContainerRecord
+}
+
+// NewContainerListing returns an iterator over the JSON account listing in
+// resp's body.
+func NewContainerListing(resp *http.Response) *ContainerListing {
+	return &ContainerListing{listingDecoder: newListingDecoder(resp.Body)}
+}
+
+// Next advances to the next container, returning false at the end of the
+// listing or on an error.
+func (l *ContainerListing) Next() bool {
+	l.record = &nectar.ContainerRecord{}
+	return l.next(l.record)
+}
+
+// Record returns the container Next advanced to.
+func (l *ContainerListing) Record() *nectar.ContainerRecord {
+	return l.record
+}
+
+// ObjectListing iterates over the objects in a container listing.
+type ObjectListing struct {
+	*listingDecoder
+	record *nectar.ObjectRecord
+}
+
+// NewObjectListing returns an iterator over the JSON container listing in
+// resp's body.
+func NewObjectListing(resp *http.Response) *ObjectListing {
+	return &ObjectListing{listingDecoder: newListingDecoder(resp.Body)}
+}
+
+// Next advances to the next object, returning false at the end of the
+// listing or on an error.
+func (l *ObjectListing) Next() bool {
+	l.record = &nectar.ObjectRecord{}
+	return l.next(l.record)
+}
+
+// Record returns the object Next advanced to.
+func (l *ObjectListing) Record() *nectar.ObjectRecord {
+	return l.record
+}
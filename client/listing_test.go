@@ -0,0 +1,81 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func listingResponse(body string) *http.Response {
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body))}
+}
+
+func TestObjectListing(t *testing.T) {
+	listing := NewObjectListing(listingResponse(`[{"name": "a", "bytes": 1}, {"subdir": "b/"}]`))
+	require.True(t, listing.Next())
+	require.Equal(t, "a", listing.Record().Name)
+	require.Equal(t, int64(1), listing.Record().Bytes)
+	require.True(t, listing.Next())
+	require.Equal(t, "b/", listing.Record().Subdir)
+	require.False(t, listing.Next())
+	require.Nil(t, listing.Err())
+}
+
+func TestContainerListingBadJSON(t *testing.T) {
+	listing := NewContainerListing(listingResponse(`{"name": "a"}`))
+	require.False(t, listing.Next())
+	require.NotNil(t, listing.Err())
+
+	listing = NewContainerListing(listingResponse(`[{"name": "a"}, {"name"`))
+	require.True(t, listing.Next())
+	require.Equal(t, "a", listing.Record().Name)
+	require.False(t, listing.Next())
+	require.NotNil(t, listing.Err())
+}
+
+func TestLimitListing(t *testing.T) {
+	resp := listingResponse(`[{"name": "a"}, {"name": "b"}]`)
+	resp.Header.Set("Content-Length", "30")
+	require.Equal(t, http.StatusInternalServerError, limitListing(resp, 10).StatusCode)
+
+	resp = limitListing(listingResponse(`[{"name": "a"}, {"name": "b"}]`), 30)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "30", resp.Header.Get("Content-Length"))
+
+	// Without a Content-Length, the listing is still refused before any of
+	// it is sent on.
+	resp = limitListing(listingResponse(`[{"name": "a"}, {"name": "b"}]`), 20)
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, ErrListingTooLarge.Error(), string(body))
+
+	resp = limitListing(listingResponse(`[{"name": "a"}, {"name": "b"}]`), 100)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "30", resp.Header.Get("Content-Length"))
+	listing := NewContainerListing(resp)
+	count := 0
+	for listing.Next() {
+		count++
+	}
+	require.Nil(t, listing.Err())
+	require.Equal(t, 2, count)
+}
@@ -25,8 +25,12 @@ type writeNodeIter struct {
 	mutex        sync.Mutex
 	devs         []*ring.Device
 	nonPreferred []*ring.Device
+	full         []*ring.Device
+	filtered     []*ring.Device
+	filter       ring.DeviceFilter
 	more         ring.MoreNodes
-	waffRegion   int
+	health       *deviceHealth
+	waff         func(*ring.Device) bool
 	waffCount    int
 	limit        int
 }
@@ -42,11 +46,26 @@ func (wni *writeNodeIter) next() *ring.Device {
 			if dev = wni.more.Next(); dev == nil {
 				if len(wni.nonPreferred) > 0 {
 					dev, wni.nonPreferred = wni.nonPreferred[0], wni.nonPreferred[1:]
+				} else if len(wni.full) > 0 {
+					dev, wni.full = wni.full[0], wni.full[1:]
+				} else if len(wni.filtered) > 0 {
+					dev, wni.filtered = wni.filtered[0], wni.filtered[1:]
 				}
 				return dev
 			}
 		}
-		if wni.waffCount <= 0 || wni.waffRegion == -1 || dev.Region == wni.waffRegion {
+		// Devices the deployment's device filters reject are a last resort.
+		if wni.filter != nil && !wni.filter(dev) {
+			wni.filtered = append(wni.filtered, dev)
+			continue
+		}
+		// Devices recently reporting 507 are only used once everything else
+		// has been tried.
+		if wni.health.isFull(dev) {
+			wni.full = append(wni.full, dev)
+			continue
+		}
+		if wni.waffCount <= 0 || wni.waff == nil || wni.waff(dev) {
 			wni.waffCount--
 			return dev
 		}
@@ -66,17 +85,29 @@ func (wni *writeNodeIter) Next() *ring.Device {
 }
 
 type readAffSection struct {
-	zone   int
-	region int
-	weight float64
+	zone     int
+	region   int
+	tagKey   string
+	tagValue string
+	weight   float64
+}
+
+func (af *readAffSection) matches(dev *ring.Device) bool {
+	if af.tagKey != "" {
+		return dev.HasTag(af.tagKey, af.tagValue)
+	}
+	return af.region == dev.Region && (af.zone == dev.Zone || af.zone == -1)
 }
 
 type clientRingFilter struct {
 	ring.Ring
 	raffs       []readAffSection
-	waffRegion  int
+	waff        func(*ring.Device) bool
 	waffCount   int
 	deviceLimit int
+	health      *deviceHealth
+	filter      ring.DeviceFilter
+	sorter      ring.DeviceSorter
 }
 
 func (a *clientRingFilter) ring() ring.Ring {
@@ -88,7 +119,7 @@ func (a *clientRingFilter) getReadNodes(partition uint64) ([]*ring.Device, ring.
 	d2a := make(map[*ring.Device]int, len(devs))
 	for i, af := range a.raffs {
 		for _, dev := range devs {
-			if _, in := d2a[dev]; !in && (af.region == dev.Region) && (af.zone == dev.Zone || af.zone == -1) {
+			if _, in := d2a[dev]; !in && af.matches(dev) {
 				d2a[dev] = i
 			}
 		}
@@ -100,7 +131,15 @@ func (a *clientRingFilter) getReadNodes(partition uint64) ([]*ring.Device, ring.
 	}
 	rand.Shuffle(len(devs), func(i, j int) { devs[i], devs[j] = devs[j], devs[i] })
 	sort.SliceStable(devs, func(i, j int) bool { return d2a[devs[i]] < d2a[devs[j]] })
-	return devs, a.Ring.GetMoreNodes(partition)
+	if a.sorter != nil {
+		a.sorter(devs)
+	}
+	if a.filter != nil {
+		// Filtered primaries are still read from, but only after the others,
+		// since they may have the only copies.
+		sort.SliceStable(devs, func(i, j int) bool { return a.filter(devs[i]) && !a.filter(devs[j]) })
+	}
+	return devs, ring.FilterMoreNodes(a.Ring.GetMoreNodes(partition), a.filter)
 }
 
 func (a *clientRingFilter) getWriteNodes(partition uint64) ([]*ring.Device, ring.MoreNodes) {
@@ -110,11 +149,13 @@ func (a *clientRingFilter) getWriteNodes(partition uint64) ([]*ring.Device, ring
 		a.deviceLimit = len(devs)
 	}
 	more := &writeNodeIter{
-		devs:       devs,
-		more:       a.GetMoreNodes(partition),
-		waffRegion: a.waffRegion,
-		waffCount:  a.waffCount,
-		limit:      a.deviceLimit,
+		devs:      devs,
+		more:      a.GetMoreNodes(partition),
+		waff:      a.waff,
+		waffCount: a.waffCount,
+		limit:     a.deviceLimit,
+		health:    a.health,
+		filter:    a.filter,
 	}
 	if a.deviceLimit < len(devs) {
 		ndevs = make([]*ring.Device, a.deviceLimit)
@@ -128,8 +169,15 @@ func (a *clientRingFilter) getWriteNodes(partition uint64) ([]*ring.Device, ring
 }
 
 func newClientRingFilter(r ring.Ring, readAff, writeAff, waffCount string, deviceLimit int) *clientRingFilter {
+	// write_affinity is either a region, like r1, or a device tag, like
+	// rack:r12.
+	var waff func(*ring.Device) bool
 	waffRegion := -1
-	fmt.Sscanf(writeAff, "r%d", &waffRegion)
+	if key, value, ok := ring.ParseTagSelector(strings.TrimSpace(writeAff)); ok {
+		waff = func(dev *ring.Device) bool { return dev.HasTag(key, value) }
+	} else if fmt.Sscanf(writeAff, "r%d", &waffRegion); waffRegion != -1 {
+		waff = func(dev *ring.Device) bool { return dev.Region == waffRegion }
+	}
 
 	wc := 0
 	var f float64
@@ -146,7 +194,9 @@ func newClientRingFilter(r ring.Ring, readAff, writeAff, waffCount string, devic
 	for i := range sections {
 		var weight float64
 		var zone, region int
-		if n, err := fmt.Sscanf(strings.TrimSpace(sections[i]), "r%dz%d=%f", &region, &zone, &weight); err == nil && n == 3 {
+		if key, value, ok := parseTagAffinity(strings.TrimSpace(sections[i]), &weight); ok {
+			raffs = append(raffs, readAffSection{tagKey: key, tagValue: value, weight: weight})
+		} else if n, err := fmt.Sscanf(strings.TrimSpace(sections[i]), "r%dz%d=%f", &region, &zone, &weight); err == nil && n == 3 {
 			raffs = append(raffs, readAffSection{zone: zone, region: region, weight: weight})
 		} else if n, err := fmt.Sscanf(strings.TrimSpace(sections[i]), "r%d=%f", &region, &weight); err == nil && n == 2 {
 			raffs = append(raffs, readAffSection{zone: -1, region: region, weight: weight})
@@ -156,8 +206,26 @@ func newClientRingFilter(r ring.Ring, readAff, writeAff, waffCount string, devic
 	return &clientRingFilter{
 		Ring:        r,
 		raffs:       raffs,
-		waffRegion:  waffRegion,
+		waff:        waff,
 		waffCount:   wc,
 		deviceLimit: deviceLimit,
 	}
 }
+
+// parseTagAffinity parses a read_affinity section naming a device tag, such as
+// rack:r12=100.
+func parseTagAffinity(section string, weight *float64) (key, value string, ok bool) {
+	i := strings.LastIndex(section, "=")
+	if i < 0 {
+		return "", "", false
+	}
+	if key, value, ok = ring.ParseTagSelector(section[:i]); !ok {
+		return "", "", false
+	}
+	w, err := strconv.ParseFloat(strings.TrimSpace(section[i+1:]), 64)
+	if err != nil {
+		return "", "", false
+	}
+	*weight = w
+	return key, value, true
+}
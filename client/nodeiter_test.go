@@ -1,7 +1,9 @@
 package client
 
 import (
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/troubling/hummingbird/common/ring"
@@ -32,7 +34,12 @@ func TestAffinityReadOrder(t *testing.T) {
 
 type fakeRing struct {
 	*test.FakeRing
-	nodes []*ring.Device
+	nodes   []*ring.Device
+	modTime time.Time
+}
+
+func (fr *fakeRing) ModTime() time.Time {
+	return fr.modTime
 }
 
 func (fr *fakeRing) GetNodes(partition uint64) []*ring.Device {
@@ -148,3 +155,90 @@ func TestWriteAffinityNonPreferred(t *testing.T) {
 	require.Equal(t, 4, more.Next().Id)
 	require.Equal(t, 5, more.Next().Id)
 }
+
+func TestTagAffinity(t *testing.T) {
+	r := &fakeRing{
+		FakeRing: &test.FakeRing{
+			MockMoreNodes: &ring.Device{Id: 3, Region: 2, Zone: 1, Device: "sdd", Tags: map[string]string{"rack": "r12"}},
+		},
+		nodes: []*ring.Device{
+			{Id: 0, Region: 1, Zone: 1, Device: "sda", Tags: map[string]string{"rack": "r11"}},
+			{Id: 1, Region: 1, Zone: 1, Device: "sdb", Tags: map[string]string{"rack": "r12"}},
+			{Id: 2, Region: 1, Zone: 1, Device: "sdc"},
+		},
+	}
+
+	a := newClientRingFilter(r, "rack:r12=100, rack:r11=200", "", "", 0)
+	devs, _ := a.getReadNodes(1)
+	require.Equal(t, "sdb", devs[0].Device)
+	require.Equal(t, "sda", devs[1].Device)
+	require.Equal(t, "sdc", devs[2].Device)
+
+	a = newClientRingFilter(r, "", "rack:r12", "2", 3)
+	devs, more := a.getWriteNodes(1)
+	require.Equal(t, 3, len(devs))
+	require.Equal(t, 1, devs[0].Id)
+	require.Equal(t, 3, devs[1].Id)
+	require.Equal(t, 0, devs[2].Id)
+	require.Equal(t, 2, more.Next().Id)
+}
+
+type sliceMoreNodes struct {
+	devs []*ring.Device
+}
+
+func (m *sliceMoreNodes) Next() *ring.Device {
+	if len(m.devs) == 0 {
+		return nil
+	}
+	dev := m.devs[0]
+	m.devs = m.devs[1:]
+	return dev
+}
+
+func TestDeviceFilterAndSorter(t *testing.T) {
+	ring.RegisterDeviceSorter("test-id-descending", func(devs []*ring.Device) {
+		sort.Slice(devs, func(i, j int) bool { return devs[i].Id > devs[j].Id })
+	})
+	filter, err := ring.GetDeviceFilter("maintenance")
+	require.Nil(t, err)
+	sorter, err := ring.GetDeviceSorter("test-id-descending")
+	require.Nil(t, err)
+	newRing := func() *fakeRing {
+		return &fakeRing{
+			FakeRing: &test.FakeRing{
+				MockGetMoreNodes: &sliceMoreNodes{devs: []*ring.Device{
+					{Id: 3, Device: "sdd", Meta: "maintenance"},
+					{Id: 4, Device: "sde"},
+				}},
+			},
+			nodes: []*ring.Device{
+				{Id: 0, Device: "sda"},
+				{Id: 1, Device: "sdb", Meta: "path_prefix=/x maintenance=yes"},
+				{Id: 2, Device: "sdc"},
+			},
+		}
+	}
+
+	a := newClientRingFilter(newRing(), "", "", "", 0)
+	a.filter, a.sorter = filter, sorter
+	devs, more := a.getReadNodes(1)
+	require.Equal(t, 3, len(devs))
+	require.Equal(t, 2, devs[0].Id)
+	require.Equal(t, 0, devs[1].Id)
+	require.Equal(t, 1, devs[2].Id)
+	require.Equal(t, 4, more.Next().Id)
+	require.Nil(t, more.Next())
+
+	a = newClientRingFilter(newRing(), "", "", "", 0)
+	a.filter = filter
+	devs, more = a.getWriteNodes(1)
+	require.Equal(t, 3, len(devs))
+	require.Equal(t, 0, devs[0].Id)
+	require.Equal(t, 2, devs[1].Id)
+	require.Equal(t, 4, devs[2].Id)
+	// Filtered devices are only handed out once nothing else is left.
+	require.Equal(t, 1, more.Next().Id)
+	require.Equal(t, 3, more.Next().Id)
+	require.Nil(t, more.Next())
+}
@@ -1,13 +1,17 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"net/http"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/troubling/hummingbird/common"
@@ -20,11 +24,13 @@ import (
 
 type proxyObjectClient interface {
 	putObject(ctx context.Context, account, container, obj string, headers http.Header, src io.Reader) *http.Response
+	appendObject(ctx context.Context, account, container, obj string, headers http.Header, src io.Reader) *http.Response
 	postObject(ctx context.Context, account, container, obj string, headers http.Header) *http.Response
 	getObject(ctx context.Context, account, container, obj string, headers http.Header) *http.Response
 	grepObject(ctx context.Context, account, container, obj string, search string) *http.Response
 	headObject(ctx context.Context, account, container, obj string, headers http.Header) *http.Response
 	deleteObject(ctx context.Context, account, container, obj string, headers http.Header) *http.Response
+	replicaMetadata(ctx context.Context, account, container, obj string) ([]*ReplicaMetadata, *http.Response)
 	ring() (ring.Ring, *http.Response)
 }
 
@@ -36,6 +42,9 @@ type erroringObjectClient struct {
 func (oc *erroringObjectClient) putObject(ctx context.Context, account, container, obj string, headers http.Header, src io.Reader) *http.Response {
 	return nectarutil.ResponseStub(oc.status, oc.body)
 }
+func (oc *erroringObjectClient) appendObject(ctx context.Context, account, container, obj string, headers http.Header, src io.Reader) *http.Response {
+	return nectarutil.ResponseStub(oc.status, oc.body)
+}
 func (oc *erroringObjectClient) postObject(ctx context.Context, account, container, obj string, headers http.Header) *http.Response {
 	return nectarutil.ResponseStub(oc.status, oc.body)
 }
@@ -51,6 +60,9 @@ func (oc *erroringObjectClient) headObject(ctx context.Context, account, contain
 func (oc *erroringObjectClient) deleteObject(ctx context.Context, account, container, obj string, headers http.Header) *http.Response {
 	return nectarutil.ResponseStub(oc.status, oc.body)
 }
+func (oc *erroringObjectClient) replicaMetadata(ctx context.Context, account, container, obj string) ([]*ReplicaMetadata, *http.Response) {
+	return nil, nectarutil.ResponseStub(oc.status, oc.body)
+}
 func (oc *erroringObjectClient) ring() (ring.Ring, *http.Response) {
 	return nil, nectarutil.ResponseStub(oc.status, oc.body)
 }
@@ -95,7 +107,62 @@ func (p *putReader) Read(b []byte) (int, error) {
 	}
 }
 
+// trailerReader is an object body with trailers to send after it.
+type trailerReader struct {
+	io.Reader
+	trailer http.Header
+}
+
+// WithTrailer makes a PUT of src send the keys in trailer to the object
+// servers as trailers, with whatever values trailer has once src has been
+// read to the end; an incoming request's Body and Trailer work that way.
+func WithTrailer(src io.Reader, trailer http.Header) io.Reader {
+	return &trailerReader{Reader: src, trailer: trailer}
+}
+
 func (oc *standardObjectClient) putObject(ctx context.Context, account, container, obj string, headers http.Header, src io.Reader) *http.Response {
+	return oc.writeObject(ctx, "PUT", account, container, obj, headers, src)
+}
+
+// appendObject appends src to the newest version of the object on every
+// replica, refusing if that version is X-Append-Offset bytes long and the
+// header was set.
+func (oc *standardObjectClient) appendObject(ctx context.Context, account, container, obj string, headers http.Header, src io.Reader) *http.Response {
+	replicas, resp := oc.replicaMetadata(ctx, account, container, obj)
+	if resp != nil {
+		return resp
+	}
+	var newest *ReplicaMetadata
+	for _, replica := range replicas {
+		if replica.StatusCode != http.StatusOK && replica.StatusCode != http.StatusNotFound {
+			continue
+		}
+		if newest == nil || newest.Timestamp == "" {
+			newest = replica
+		} else if cmp, err := common.CompareTimestamps(replica.Timestamp, newest.Timestamp); err == nil && cmp > 0 {
+			newest = replica
+		}
+	}
+	if newest == nil {
+		return nectarutil.ResponseStub(http.StatusServiceUnavailable, "The service is currently unavailable.")
+	} else if newest.StatusCode != http.StatusOK {
+		return nectarutil.ResponseStub(http.StatusNotFound, "")
+	}
+	if offset := headers.Get("X-Append-Offset"); offset != "" && offset != newest.Headers.Get("Content-Length") {
+		return nectarutil.ResponseStub(http.StatusConflict, fmt.Sprintf("The object is %s bytes long", newest.Headers.Get("Content-Length")))
+	}
+	appendHeaders := http.Header{}
+	for key := range headers {
+		appendHeaders.Set(key, headers.Get(key))
+	}
+	appendHeaders.Del("X-Append-Offset")
+	appendHeaders.Set("X-Backend-Append-Timestamp", newest.Timestamp)
+	return oc.writeObject(ctx, "APPEND", account, container, obj, appendHeaders, src)
+}
+
+// writeObject sends src to each of the object's nodes in a PUT or APPEND
+// request.
+func (oc *standardObjectClient) writeObject(ctx context.Context, method, account, container, obj string, headers http.Header, src io.Reader) *http.Response {
 	objectPartition := oc.objectRing.GetPartition(account, container, obj)
 	containerPartition := oc.pdc.ContainerRing.GetPartition(account, container, "")
 	containerDevices := oc.pdc.ContainerRing.GetNodes(containerPartition)
@@ -105,40 +172,105 @@ func (oc *standardObjectClient) putObject(ctx context.Context, account, containe
 	responsec := make(chan *http.Response)
 	devs, more := oc.objectRing.getWriteNodes(objectPartition)
 	objectReplicaCount := len(devs)
+	// Track which device each writer goes to, so slow ones can be evicted.
+	var writerLock sync.Mutex
+	writerDevs := map[io.WriteCloser]*ring.Device{}
+	writerIndexes := map[io.WriteCloser]int{}
+	writerTrailers := map[io.WriteCloser]http.Header{}
+	evicted := make([]int32, objectReplicaCount)
+	trailerSrc, _ := src.(*trailerReader)
+
+	// A small enough PUT is read into memory and sent to every node without
+	// waiting for a 100 Continue, saving a round trip. Those requests never
+	// come through the ready channel, so only their responses are awaited.
+	var smallBody []byte
+	if method == "PUT" && oc.pdc.putSmallObjectSize > 0 && trailerSrc == nil {
+		if size, err := strconv.ParseInt(headers.Get("Content-Length"), 10, 64); err == nil && size >= 0 && size <= oc.pdc.putSmallObjectSize {
+			body, err := ioutil.ReadAll(io.LimitReader(src, size+1))
+			if err != nil || int64(len(body)) != size {
+				return nectarutil.ResponseStub(http.StatusServiceUnavailable, "The service is currently unavailable.")
+			}
+			smallBody = body
+		}
+	}
 
 	devToRequest := func(index int, dev *ring.Device) (*http.Request, error) {
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, objectPartition,
+			common.Urlencode(account), common.Urlencode(container), common.Urlencode(obj))
+		setHeaders := func(req *http.Request) *http.Request {
+			req.Header.Set("User-Agent", oc.pdc.userAgent)
+			req = req.WithContext(tracing.CopySpanFromContext(ctx))
+			req.Header.Set("Content-Type", "application/octet-stream")
+			for key := range headers {
+				req.Header.Set(key, headers.Get(key))
+			}
+			req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(oc.policy))
+			req.Header.Set("X-Container-Partition", strconv.FormatUint(containerPartition, 10))
+			addUpdateHeaders("X-Container", req.Header, containerDevices, index, objectReplicaCount)
+			return req
+		}
+		if smallBody != nil {
+			req, err := http.NewRequest(method, url, bytes.NewReader(smallBody))
+			if err != nil {
+				return nil, err
+			}
+			return setHeaders(req), nil
+		}
 		trp, wp := io.Pipe()
+		writerLock.Lock()
+		writerDevs[wp] = dev
+		writerIndexes[wp] = index
+		writerLock.Unlock()
 		rp := &putReader{Reader: trp, cancel: cancel, w: wp, ready: ready}
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, objectPartition,
-			common.Urlencode(account), common.Urlencode(container), common.Urlencode(obj))
-		req, err := http.NewRequest("PUT", url, rp)
+		req, err := http.NewRequest(method, url, rp)
 		if err != nil {
 			return nil, err
 		}
-		req.Header.Set("User-Agent", oc.pdc.userAgent)
-		req = req.WithContext(tracing.CopySpanFromContext(ctx))
-		req.Header.Set("Content-Type", "application/octet-stream")
-		for key := range headers {
-			req.Header.Set(key, headers.Get(key))
-		}
-		req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(oc.policy))
-		req.Header.Set("X-Container-Partition", strconv.FormatUint(containerPartition, 10))
-		addUpdateHeaders("X-Container", req.Header, containerDevices, index, objectReplicaCount)
+		req = setHeaders(req)
 		req.Header.Set("Expect", "100-continue")
+		if trailerSrc != nil {
+			req.Trailer = http.Header{}
+			for key := range trailerSrc.trailer {
+				req.Trailer[key] = nil
+			}
+			writerLock.Lock()
+			writerTrailers[wp] = req.Trailer
+			writerLock.Unlock()
+		}
 		return req, nil
 	}
 
+	// Every write past the primaries is a handoff, and they all count
+	// against the limit.
+	var handoffsUsed int32
+	withHandoffs := func(resp *http.Response) *http.Response {
+		if used := atomic.LoadInt32(&handoffsUsed); used > 0 {
+			resp.Header.Set("X-Backend-Handoff-Used", strconv.Itoa(int(used)))
+		}
+		return resp
+	}
 	for i := 0; i < objectReplicaCount; i++ {
 		go func(index int) {
 			var resp *http.Response
 			for dev := devs[index]; dev != nil; dev = more.Next() {
+				if dev != devs[index] {
+					if used := atomic.AddInt32(&handoffsUsed, 1); oc.pdc.putMaxHandoffs >= 0 && int(used) > oc.pdc.putMaxHandoffs {
+						atomic.AddInt32(&handoffsUsed, -1)
+						oc.Logger.Info("Object write reached its handoff limit", zap.Int("limit", oc.pdc.putMaxHandoffs),
+							zap.String("path", fmt.Sprintf("/%s/%s/%s", account, container, obj)))
+						break
+					}
+				}
 				if req, err := devToRequest(index, dev); err != nil {
-					oc.Logger.Error("unable create PUT request", zap.Error(err))
+					oc.Logger.Error(fmt.Sprintf("unable create %s request", method), zap.Error(err))
 					resp = nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
 				} else if r, err := oc.pdc.client.Do(req); err != nil {
-					oc.Logger.Error("unable to PUT object", zap.Error(err))
+					oc.Logger.Error(fmt.Sprintf("unable to %s object", method), zap.Error(err))
 					resp = nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
 				} else {
+					if r.StatusCode == http.StatusInsufficientStorage {
+						oc.pdc.health.markFull(dev)
+					}
 					resp = nectarutil.StubResponse(r)
 					if r.StatusCode >= 200 && r.StatusCode < 500 {
 						break
@@ -149,10 +281,14 @@ func (oc *standardObjectClient) putObject(ctx context.Context, account, containe
 					return
 				default:
 				}
+				if atomic.LoadInt32(&evicted[index]) != 0 {
+					// The replicators will fill in for it later.
+					break
+				}
 			}
 			if resp == nil {
 				err := fmt.Errorf("no more nodes to try")
-				oc.Logger.Error("unable to PUT object", zap.Error(err))
+				oc.Logger.Error(fmt.Sprintf("unable to %s object", method), zap.Error(err))
 				resp = nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
 			}
 			select {
@@ -168,6 +304,8 @@ func (oc *standardObjectClient) putObject(ctx context.Context, account, containe
 	cWriters := make([]io.WriteCloser, 0)
 	responseCount := 0
 	written := false
+	var readyTimeout <-chan time.Time
+	readyTimedOut := false
 	for {
 		select {
 		case resp := <-responsec:
@@ -181,24 +319,88 @@ func (oc *standardObjectClient) putObject(ctx context.Context, account, containe
 						case <-responsec:
 							responseCount++
 						case <-timeout:
-							return resp
+							return withHandoffs(resp)
 						}
 					}
-					return resp
+					return withHandoffs(resp)
 				} else if responseCount == objectReplicaCount {
-					return nectarutil.ResponseStub(http.StatusServiceUnavailable, "The service is currently unavailable.")
+					return withHandoffs(nectarutil.ResponseStub(http.StatusServiceUnavailable, "The service is currently unavailable."))
 				}
 			}
 		case w := <-ready:
+			if written {
+				// It's too late to send this one the object; fail its
+				// request rather than let it end the body early.
+				writerLock.Lock()
+				atomic.StoreInt32(&evicted[writerIndexes[w]], 1)
+				writerLock.Unlock()
+				if pw, ok := w.(*io.PipeWriter); ok {
+					pw.CloseWithError(errors.New("Writer ready too late"))
+				}
+				continue
+			}
 			defer w.Close()
 			writers = append(writers, w)
 			cWriters = append(cWriters, w)
+		case <-readyTimeout:
+			readyTimedOut = true
+		}
+		if !written && len(writers) >= quorum && readyTimeout == nil && oc.pdc.putReadyTimeout > 0 {
+			readyTimeout = time.After(oc.pdc.putReadyTimeout)
 		}
-		if !written && len(writers) >= quorum && len(writers)+responseCount == objectReplicaCount {
+		if !written && len(writers) >= quorum && (len(writers)+responseCount == objectReplicaCount || readyTimedOut) {
 			written = true
-			if _, err := common.CopyQuorum(src, quorum, writers...); err != nil {
+			if len(writers)+responseCount < objectReplicaCount {
+				// Rather than hold the ready connections idle any longer,
+				// go ahead without the stragglers; they won't be retried on
+				// handoffs, and replication will fill in for them.
+				isReady := map[int]bool{}
+				writerLock.Lock()
+				for _, w := range cWriters {
+					isReady[writerIndexes[w]] = true
+				}
+				writerLock.Unlock()
+				for index := range evicted {
+					if !isReady[index] {
+						atomic.StoreInt32(&evicted[index], 1)
+					}
+				}
+				oc.Logger.Info("Object PUT going ahead without writers that weren't ready",
+					zap.Int("ready", len(writers)), zap.Int("replicas", objectReplicaCount),
+					zap.String("path", fmt.Sprintf("/%s/%s/%s", account, container, obj)))
+			}
+			var err error
+			if oc.pdc.putWriterBuffer > 0 {
+				_, err = common.CopyQuorumBuffered(src, quorum, oc.pdc.putWriterBuffer, oc.pdc.putWriterMaxWait, func(i int) {
+					w := cWriters[i]
+					writerLock.Lock()
+					dev, index := writerDevs[w], writerIndexes[w]
+					writerLock.Unlock()
+					atomic.StoreInt32(&evicted[index], 1)
+					if pw, ok := w.(*io.PipeWriter); ok {
+						pw.CloseWithError(errors.New("Evicted slow writer"))
+					}
+					oc.Logger.Info("Evicted slow object PUT writer", zap.String("device", deviceHealthKey(dev)),
+						zap.Uint64("partition", objectPartition), zap.Int("policy", oc.policy),
+						zap.String("path", fmt.Sprintf("/%s/%s/%s", account, container, obj)))
+				}, writers...)
+			} else {
+				_, err = common.CopyQuorum(src, quorum, writers...)
+			}
+			if err != nil {
 				return nectarutil.ResponseStub(http.StatusServiceUnavailable, "The service is currently unavailable.")
 			}
+			if trailerSrc != nil {
+				// The source's trailers are filled in now it's been read,
+				// and the backend requests send theirs once the pipes close.
+				writerLock.Lock()
+				for _, w := range cWriters {
+					for key := range writerTrailers[w] {
+						writerTrailers[w].Set(key, trailerSrc.trailer.Get(key))
+					}
+				}
+				writerLock.Unlock()
+			}
 			for _, w := range cWriters {
 				w.Close()
 			}
@@ -213,7 +415,7 @@ func (oc *standardObjectClient) postObject(ctx context.Context, account, contain
 	devs, _ := oc.objectRing.getWriteNodes(partition)
 	objectReplicaCount := len(devs)
 	return oc.pdc.quorumResponse(oc.objectRing, partition, func(i int, dev *ring.Device) (*http.Request, error) {
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, partition,
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
 			common.Urlencode(account), common.Urlencode(container), common.Urlencode(obj))
 		req, err := http.NewRequest("POST", url, nil)
 		if err != nil {
@@ -231,10 +433,26 @@ func (oc *standardObjectClient) postObject(ctx context.Context, account, contain
 	})
 }
 
+// fixContentLength makes sure a successful object GET or HEAD response has a
+// Content-Length, falling back to the size the object server stored for the
+// object if the response was chunked somewhere along the way.
+func fixContentLength(resp *http.Response) *http.Response {
+	size := resp.Header.Get("X-Backend-Content-Length")
+	resp.Header.Del("X-Backend-Content-Length")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Length") != "" {
+		return resp
+	}
+	if contentLength, err := strconv.ParseInt(size, 10, 64); err == nil {
+		resp.Header.Set("Content-Length", size)
+		resp.ContentLength = contentLength
+	}
+	return resp
+}
+
 func (oc *standardObjectClient) getObject(ctx context.Context, account, container, obj string, headers http.Header) *http.Response {
 	partition := oc.objectRing.GetPartition(account, container, obj)
-	return oc.pdc.firstResponse(oc.objectRing, partition, func(dev *ring.Device) (*http.Request, error) {
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, partition,
+	return fixContentLength(oc.pdc.firstResponse(oc.objectRing, partition, func(dev *ring.Device) (*http.Request, error) {
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
 			common.Urlencode(account), common.Urlencode(container), common.Urlencode(obj))
 		req, err := http.NewRequest("GET", url, nil)
 		if err != nil {
@@ -247,13 +465,13 @@ func (oc *standardObjectClient) getObject(ctx context.Context, account, containe
 		}
 		req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(oc.policy))
 		return req, nil
-	})
+	}))
 }
 
 func (oc *standardObjectClient) grepObject(ctx context.Context, account, container, obj string, search string) *http.Response {
 	partition := oc.objectRing.GetPartition(account, container, obj)
 	return oc.pdc.firstResponse(oc.objectRing, partition, func(dev *ring.Device) (*http.Request, error) {
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s/%s/%s?e=%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, partition,
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s/%s?e=%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
 			common.Urlencode(account), common.Urlencode(container), common.Urlencode(obj), common.Urlencode(search))
 		req, err := http.NewRequest("GREP", url, nil)
 		if err != nil {
@@ -268,8 +486,8 @@ func (oc *standardObjectClient) grepObject(ctx context.Context, account, contain
 
 func (oc *standardObjectClient) headObject(ctx context.Context, account, container, obj string, headers http.Header) *http.Response {
 	partition := oc.objectRing.GetPartition(account, container, obj)
-	return oc.pdc.firstResponse(oc.objectRing, partition, func(dev *ring.Device) (*http.Request, error) {
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, partition,
+	return fixContentLength(oc.pdc.firstResponse(oc.objectRing, partition, func(dev *ring.Device) (*http.Request, error) {
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
 			common.Urlencode(account), common.Urlencode(container), common.Urlencode(obj))
 		req, err := http.NewRequest("HEAD", url, nil)
 		if err != nil {
@@ -282,7 +500,7 @@ func (oc *standardObjectClient) headObject(ctx context.Context, account, contain
 		}
 		req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(oc.policy))
 		return req, nil
-	})
+	}))
 }
 
 func (oc *standardObjectClient) deleteObject(ctx context.Context, account, container, obj string, headers http.Header) *http.Response {
@@ -292,7 +510,7 @@ func (oc *standardObjectClient) deleteObject(ctx context.Context, account, conta
 	devs, _ := oc.objectRing.getWriteNodes(partition)
 	objectReplicaCount := len(devs)
 	return oc.pdc.quorumResponse(oc.objectRing, partition, func(i int, dev *ring.Device) (*http.Request, error) {
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, partition,
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
 			common.Urlencode(account), common.Urlencode(container), common.Urlencode(obj))
 		req, err := http.NewRequest("DELETE", url, nil)
 		if err != nil {
@@ -313,6 +531,60 @@ func (oc *standardObjectClient) deleteObject(ctx context.Context, account, conta
 	})
 }
 
+func (oc *standardObjectClient) replicaMetadata(ctx context.Context, account, container, obj string) ([]*ReplicaMetadata, *http.Response) {
+	partition := oc.objectRing.GetPartition(account, container, obj)
+	var replicas []*ReplicaMetadata
+	for _, dev := range oc.objectRing.GetNodes(partition) {
+		replicas = append(replicas, &ReplicaMetadata{Device: dev})
+	}
+	more := oc.objectRing.ring().GetMoreNodes(partition)
+	for i := len(replicas); i > 0; i-- {
+		dev := more.Next()
+		if dev == nil {
+			break
+		}
+		replicas = append(replicas, &ReplicaMetadata{Device: dev, Handoff: true})
+	}
+	wg := sync.WaitGroup{}
+	for _, replica := range replicas {
+		wg.Add(1)
+		go func(replica *ReplicaMetadata) {
+			defer wg.Done()
+			dev := replica.Device
+			url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
+				common.Urlencode(account), common.Urlencode(container), common.Urlencode(obj))
+			req, err := http.NewRequest("HEAD", url, nil)
+			if err != nil {
+				replica.Err = err
+				return
+			}
+			req.Header.Set("User-Agent", oc.pdc.userAgent)
+			req = req.WithContext(tracing.CopySpanFromContext(ctx))
+			req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(oc.policy))
+			resp, err := oc.pdc.client.Do(req)
+			if err != nil {
+				replica.Err = err
+				return
+			}
+			resp.Body.Close()
+			replica.StatusCode = resp.StatusCode
+			replica.Headers = resp.Header
+			replica.Timestamp = resp.Header.Get("X-Timestamp")
+			if replica.Timestamp == "" {
+				replica.Timestamp = resp.Header.Get("X-Backend-Timestamp")
+			}
+			replica.DurableTimestamp = resp.Header.Get("X-Backend-Durable-Timestamp")
+			replica.DataTimestamp = resp.Header.Get("X-Backend-Data-Timestamp")
+			replica.FragIndex = resp.Header.Get("X-Object-Sysmeta-Ec-Frag-Index")
+			if replica.FragIndex == "" {
+				replica.FragIndex = resp.Header.Get("X-Backend-Ec-Frag-Index")
+			}
+		}(replica)
+	}
+	wg.Wait()
+	return replicas, nil
+}
+
 func (oc *standardObjectClient) ring() (ring.Ring, *http.Response) {
 	return oc.objectRing.ring(), nil
 }
@@ -0,0 +1,83 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/troubling/hummingbird/common/ring"
+)
+
+// PolicyStatus describes a storage policy as a proxy has it configured, so
+// deployment tooling can check that all the proxies agree.
+type PolicyStatus struct {
+	Index      int               `json:"index"`
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	Aliases    []string          `json:"aliases"`
+	Default    bool              `json:"default"`
+	Deprecated bool              `json:"deprecated"`
+	Config     map[string]string `json:"config"`
+	// The rest describe the policy's object ring; Error is set instead if
+	// the ring isn't usable.
+	ReplicaCount   uint64    `json:"replica_count"`
+	PartitionCount uint64    `json:"partition_count"`
+	Devices        int       `json:"devices"`
+	RingModTime    time.Time `json:"ring_mtime"`
+	Error          string    `json:"error,omitempty"`
+}
+
+func (c *proxyClient) Policies() []PolicyStatus {
+	statuses := make([]PolicyStatus, 0, len(c.policyList))
+	for _, policy := range c.policyList {
+		status := PolicyStatus{
+			Index:      policy.Index,
+			Name:       policy.Name,
+			Type:       policy.Type,
+			Aliases:    policy.Aliases,
+			Default:    policy.Default,
+			Deprecated: policy.Deprecated,
+			Config:     policy.Config,
+		}
+		oc := c.objectClients[policy.Index]
+		if oc == nil {
+			status.Error = "No object client for policy"
+			statuses = append(statuses, status)
+			continue
+		}
+		r, resp := oc.ring()
+		if resp != nil {
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			status.Error = string(body)
+			statuses = append(statuses, status)
+			continue
+		}
+		status.ReplicaCount = r.ReplicaCount()
+		status.PartitionCount = r.PartitionCount()
+		for _, dev := range r.AllDevices() {
+			if dev.Active() {
+				status.Devices++
+			}
+		}
+		status.RingModTime = ring.ModTime(r)
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Index < statuses[j].Index })
+	return statuses
+}
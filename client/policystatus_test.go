@@ -0,0 +1,68 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/test"
+	"go.uber.org/zap"
+)
+
+type devicesRing struct {
+	*test.FakeRing
+	devs []*ring.Device
+}
+
+func (r *devicesRing) AllDevices() []*ring.Device {
+	return r.devs
+}
+
+func TestPolicies(t *testing.T) {
+	r := &devicesRing{FakeRing: &test.FakeRing{}, devs: []*ring.Device{
+		{Id: 0, Device: "sda", Weight: 1},
+		nil,
+		{Id: 2, Device: "sdc", Weight: 1},
+	}}
+	c := &proxyClient{
+		policyList: conf.PolicyList{
+			1: {Index: 1, Name: "ec", Type: "hec", Config: map[string]string{"data_shards": "4"}},
+			0: {Index: 0, Name: "gold", Type: "replication", Default: true},
+		},
+		Logger: zap.NewNop(),
+	}
+	c.objectClients = map[int]proxyObjectClient{
+		0: &standardObjectClient{pdc: c, policy: 0, objectRing: newClientRingFilter(r, "", "", "", 0), Logger: zap.NewNop()},
+		1: &erroringObjectClient{status: http.StatusInternalServerError, body: "Error loading ring"},
+	}
+	policies := c.Policies()
+	require.Equal(t, 2, len(policies))
+	require.Equal(t, 0, policies[0].Index)
+	require.Equal(t, "gold", policies[0].Name)
+	require.True(t, policies[0].Default)
+	require.Equal(t, uint64(3), policies[0].ReplicaCount)
+	require.Equal(t, uint64(64), policies[0].PartitionCount)
+	require.Equal(t, 2, policies[0].Devices)
+	require.True(t, policies[0].RingModTime.IsZero())
+	require.Equal(t, "", policies[0].Error)
+	require.Equal(t, 1, policies[1].Index)
+	require.Equal(t, "4", policies[1].Config["data_shards"])
+	require.Equal(t, "Error loading ring", policies[1].Error)
+}
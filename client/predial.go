@@ -0,0 +1,116 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// predialer is the backend transport's Dial when put_predial is on. It can
+// open a connection to an address before any request needs one, and hands
+// that connection to the transport the next time it dials the address.
+//
+// It only predials addresses the transport has no connections to, since
+// otherwise an idle keep-alive connection would most likely be reused and
+// the predialed one wasted.
+type predialer struct {
+	dial func(network, addr string) (net.Conn, error)
+	// timeout is how long a predialed connection waits to be used before
+	// it's closed.
+	timeout time.Duration
+	lock    sync.Mutex
+	// open counts the transport's connections to each address.
+	open map[string]int
+	// ready has the predialed connection for each address, or nil while
+	// it's still being dialed.
+	ready map[string]net.Conn
+}
+
+func newPredialer(dial func(network, addr string) (net.Conn, error), timeout time.Duration) *predialer {
+	return &predialer{dial: dial, timeout: timeout, open: map[string]int{}, ready: map[string]net.Conn{}}
+}
+
+// Dial returns the connection predialed to addr, if there is one, or a new
+// connection.
+func (p *predialer) Dial(network, addr string) (net.Conn, error) {
+	var conn net.Conn
+	p.lock.Lock()
+	if network == "tcp" && p.ready[addr] != nil {
+		conn = p.ready[addr]
+		delete(p.ready, addr)
+	}
+	p.lock.Unlock()
+	if conn == nil {
+		var err error
+		if conn, err = p.dial(network, addr); err != nil {
+			return nil, err
+		}
+	}
+	p.lock.Lock()
+	p.open[addr]++
+	p.lock.Unlock()
+	return &predialerConn{Conn: conn, closed: func() {
+		p.lock.Lock()
+		if p.open[addr]--; p.open[addr] <= 0 {
+			delete(p.open, addr)
+		}
+		p.lock.Unlock()
+	}}, nil
+}
+
+// predial starts a connection to addr in the background, unless there's
+// already one open or on the way.
+func (p *predialer) predial(addr string) {
+	p.lock.Lock()
+	if _, ok := p.ready[addr]; ok || p.open[addr] > 0 {
+		p.lock.Unlock()
+		return
+	}
+	p.ready[addr] = nil
+	p.lock.Unlock()
+	go func() {
+		conn, err := p.dial("tcp", addr)
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		if err != nil {
+			delete(p.ready, addr)
+			return
+		}
+		p.ready[addr] = conn
+		time.AfterFunc(p.timeout, func() {
+			p.lock.Lock()
+			defer p.lock.Unlock()
+			if p.ready[addr] == conn {
+				delete(p.ready, addr)
+				conn.Close()
+			}
+		})
+	}()
+}
+
+// predialerConn tells the predialer when the transport closes it.
+type predialerConn struct {
+	net.Conn
+	once   sync.Once
+	closed func()
+}
+
+func (c *predialerConn) Close() error {
+	c.once.Do(c.closed)
+	return c.Conn.Close()
+}
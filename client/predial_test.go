@@ -0,0 +1,107 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type countingDialer struct {
+	lock  sync.Mutex
+	dials map[string]int
+}
+
+func (d *countingDialer) dial(network, addr string) (net.Conn, error) {
+	d.lock.Lock()
+	d.dials[addr]++
+	d.lock.Unlock()
+	c, _ := net.Pipe()
+	return c, nil
+}
+
+func (d *countingDialer) count(addr string) int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.dials[addr]
+}
+
+func waitForPredial(p *predialer, addr string) bool {
+	for i := 0; i < 100; i++ {
+		p.lock.Lock()
+		ready := p.ready[addr] != nil
+		p.lock.Unlock()
+		if ready {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
+
+func TestPredialer(t *testing.T) {
+	d := &countingDialer{dials: map[string]int{}}
+	p := newPredialer(d.dial, time.Minute)
+
+	p.predial("1.2.3.4:6000")
+	require.True(t, waitForPredial(p, "1.2.3.4:6000"))
+	require.Equal(t, 1, d.count("1.2.3.4:6000"))
+	// Asking again while one is ready doesn't dial another.
+	p.predial("1.2.3.4:6000")
+	require.Equal(t, 1, d.count("1.2.3.4:6000"))
+
+	// The transport gets the predialed connection.
+	conn, err := p.Dial("tcp", "1.2.3.4:6000")
+	require.Nil(t, err)
+	require.Equal(t, 1, d.count("1.2.3.4:6000"))
+	// With that connection open, there's no predialing.
+	p.predial("1.2.3.4:6000")
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, 1, d.count("1.2.3.4:6000"))
+	// The next dial is a new connection.
+	conn2, err := p.Dial("tcp", "1.2.3.4:6000")
+	require.Nil(t, err)
+	require.Equal(t, 2, d.count("1.2.3.4:6000"))
+
+	conn.Close()
+	conn.Close()
+	p.predial("1.2.3.4:6000")
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, 2, d.count("1.2.3.4:6000"))
+	conn2.Close()
+	p.predial("1.2.3.4:6000")
+	require.True(t, waitForPredial(p, "1.2.3.4:6000"))
+	require.Equal(t, 3, d.count("1.2.3.4:6000"))
+}
+
+func TestPredialerTimeout(t *testing.T) {
+	d := &countingDialer{dials: map[string]int{}}
+	p := newPredialer(d.dial, 10*time.Millisecond)
+	p.predial("1.2.3.4:6000")
+	require.True(t, waitForPredial(p, "1.2.3.4:6000"))
+	time.Sleep(50 * time.Millisecond)
+	p.lock.Lock()
+	_, ok := p.ready["1.2.3.4:6000"]
+	p.lock.Unlock()
+	require.False(t, ok)
+	_, err := p.Dial("tcp", "1.2.3.4:6000")
+	require.Nil(t, err)
+	require.Equal(t, 2, d.count("1.2.3.4:6000"))
+}
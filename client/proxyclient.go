@@ -27,19 +27,30 @@ const PostQuorumTimeoutMs = 100
 const postPutTimeout = time.Second * 30
 const firstResponseFinalTimeout = time.Second * 30
 
+// firstResponseCompareTimeout is how long firstResponse waits on the other
+// requests in flight once it has a good response, to check none is newer.
+var firstResponseCompareTimeout = time.Duration(PostQuorumTimeoutMs) * time.Millisecond
+
 func addUpdateHeaders(prefix string, headers http.Header, devices []*ring.Device, i, replicas int) {
 	if i < len(devices) {
 		host := ""
 		device := ""
 		scheme := ""
+		var pathPrefixes []string
+		hasPathPrefix := false
 		for ; i < len(devices); i += replicas {
 			host += fmt.Sprintf("%s:%d,", devices[i].Ip, devices[i].Port)
 			device += devices[i].Device + ","
 			scheme += devices[i].Scheme + ","
+			pathPrefixes = append(pathPrefixes, devices[i].PathPrefix)
+			hasPathPrefix = hasPathPrefix || devices[i].PathPrefix != ""
 		}
 		headers.Set(prefix+"-Scheme", strings.TrimRight(scheme, ","))
 		headers.Set(prefix+"-Host", strings.TrimRight(host, ","))
 		headers.Set(prefix+"-Device", strings.TrimRight(device, ","))
+		if hasPathPrefix {
+			headers.Set(prefix+"-Path-Prefix", strings.Join(pathPrefixes, ","))
+		}
 	}
 }
 
@@ -52,20 +63,58 @@ type proxyClient struct {
 	Logger            srv.LowLevelLogger
 	ClientTraceCloser io.Closer
 	userAgent         string
+	priority          string
+	health            *deviceHealth
+	intercept         *interceptClient
+	maxListingBytes   int64
+	putWriterBuffer   int64
+	putWriterMaxWait  time.Duration
+	putReadyTimeout   time.Duration
+	putMaxHandoffs    int
+	// putSmallObjectSize is the largest PUT that's buffered and sent without
+	// waiting for 100 Continue.
+	putSmallObjectSize int64
+	// rebalanceReadHandoffs is how many more handoffs a read tries when
+	// every node it asked answered 404 without a tombstone, within
+	// rebalanceReadWindow of the ring changing.
+	rebalanceReadHandoffs int
+	rebalanceReadWindow   time.Duration
+	// predialer is nil unless put_predial is on.
+	predialer *predialer
 }
 
 var _ ProxyClient = &proxyClient{}
 
 func NewProxyClient(policyList conf.PolicyList, cnf srv.ConfigLoader, logger srv.LowLevelLogger, certFile, keyFile, readAffinity, writeAffinity, writeAffinityCount string, serverconf conf.Config) (ProxyClient, error) {
+	dial := (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: time.Duration(serverconf.GetInt("app:proxy-server", "backend_keepalive", 5)) * time.Second,
+	}).Dial
+	readBuffer := int(serverconf.GetInt("app:proxy-server", "backend_network_read_buffer", 0))
+	writeBuffer := int(serverconf.GetInt("app:proxy-server", "backend_network_write_buffer", 0))
+	if readBuffer > 0 || writeBuffer > 0 {
+		netDial := dial
+		dial = func(network, address string) (net.Conn, error) {
+			conn, err := netDial(network, address)
+			if err == nil {
+				srv.SetSocketBuffers(conn, readBuffer, writeBuffer)
+			}
+			return conn, err
+		}
+	}
+	var pd *predialer
+	// With put_predial set, object PUTs start connecting to their primaries
+	// while the container's info is still being looked up.
+	if serverconf.GetBool("app:proxy-server", "put_predial", false) {
+		pd = newPredialer(dial, 5*time.Second)
+		dial = pd.Dial
+	}
 	var xport http.RoundTripper = &http.Transport{
-		MaxIdleConnsPerHost: 100,
-		MaxIdleConns:        0,
-		IdleConnTimeout:     5 * time.Second,
-		DisableCompression:  true,
-		Dial: (&net.Dialer{
-			Timeout:   10 * time.Second,
-			KeepAlive: 5 * time.Second,
-		}).Dial,
+		MaxIdleConnsPerHost:   100,
+		MaxIdleConns:          0,
+		IdleConnTimeout:       5 * time.Second,
+		DisableCompression:    true,
+		Dial:                  dial,
 		ExpectContinueTimeout: 10 * time.Minute, // TODO: this should probably be like infinity.
 	}
 	if certFile != "" && keyFile != "" {
@@ -79,7 +128,7 @@ func NewProxyClient(policyList conf.PolicyList, cnf srv.ConfigLoader, logger srv
 		}
 	}
 	httpClient := &http.Client{
-		Transport: xport,
+		Transport: srv.NewSigningTransport(xport),
 		Timeout:   120 * time.Minute,
 	}
 	// Debug hook to auto-close responses and report on it. See debug.go
@@ -87,8 +136,30 @@ func NewProxyClient(policyList conf.PolicyList, cnf srv.ConfigLoader, logger srv
 	c := &proxyClient{
 		policyList: policyList,
 		client:     httpClient,
+		predialer:  pd,
 		Logger:     logger,
 		userAgent:  "Proxy",
+		health:     newDeviceHealth(time.Duration(serverconf.GetInt("app:proxy-server", "device_full_period", 300))*time.Second, logger),
+		// max_listing_bytes of 0 leaves listing responses unlimited.
+		maxListingBytes: serverconf.GetInt("app:proxy-server", "max_listing_bytes", 0),
+		// With put_writer_buffer set, object PUTs buffer that much for each
+		// backend and drop those that fall behind by more than that.
+		putWriterBuffer:  serverconf.GetInt("app:proxy-server", "put_writer_buffer", 0),
+		putWriterMaxWait: time.Duration(serverconf.GetInt("app:proxy-server", "put_writer_max_wait_ms", 1000)) * time.Millisecond,
+		// With put_ready_timeout_ms set, object PUTs stop waiting that long
+		// after a quorum of backends is ready for the body, so their
+		// connections don't sit idle waiting on the rest.
+		putReadyTimeout: time.Duration(serverconf.GetInt("app:proxy-server", "put_ready_timeout_ms", 0)) * time.Millisecond,
+		// put_max_handoffs caps the handoffs a single object write may use;
+		// -1 leaves it unlimited.
+		putMaxHandoffs: int(serverconf.GetInt("app:proxy-server", "put_max_handoffs", -1)),
+		// PUTs with a Content-Length of up to put_small_object_size bytes
+		// skip Expect: 100-continue; 0 turns that off.
+		putSmallObjectSize: serverconf.GetInt("app:proxy-server", "put_small_object_size", 0),
+		// rebalance_read_handoffs of 0 gives up after twice the replica
+		// count's nodes, as before.
+		rebalanceReadHandoffs: int(serverconf.GetInt("app:proxy-server", "rebalance_read_handoffs", 3)),
+		rebalanceReadWindow:   time.Duration(serverconf.GetInt("app:proxy-server", "rebalance_read_window", 3600)) * time.Second,
 	}
 	if serverconf.HasSection("tracing") {
 		clientTracer, clientTraceCloser, err := tracing.Init("proxydirect-client", logger, serverconf.GetSection("tracing"))
@@ -102,6 +173,8 @@ func NewProxyClient(policyList conf.PolicyList, cnf srv.ConfigLoader, logger srv
 			return nil, fmt.Errorf("Error setting up tracing client: %v", err)
 		}
 	}
+	c.intercept = &interceptClient{HTTPClient: c.client}
+	c.client = &priorityClient{HTTPClient: &timelineClient{c.intercept}, pdc: c}
 
 	if c.policyList == nil {
 		policyList, err := cnf.GetPolicies()
@@ -114,16 +187,33 @@ func NewProxyClient(policyList conf.PolicyList, cnf srv.ConfigLoader, logger srv
 	if err != nil {
 		return nil, err
 	}
+	// device_filters names registered ring.DeviceFilters, such as
+	// "maintenance", whose rejected devices are only used as a last resort;
+	// device_sorter names a ring.DeviceSorter to order reads with.
+	deviceFilter, err := ring.GetDeviceFilter(serverconf.GetDefault("app:proxy-server", "device_filters", ""))
+	if err != nil {
+		return nil, err
+	}
+	deviceSorter, err := ring.GetDeviceSorter(serverconf.GetDefault("app:proxy-server", "device_sorter", ""))
+	if err != nil {
+		return nil, err
+	}
 	containerRing, err := cnf.GetRing("container", hashPathPrefix, hashPathSuffix, 0)
 	if err != nil {
 		return nil, err
 	}
-	c.ContainerRing = newClientRingFilter(containerRing, readAffinity, "", "", 0)
+	containerRingFilter := newClientRingFilter(containerRing, readAffinity, "", "", 0)
+	containerRingFilter.health = c.health
+	containerRingFilter.filter, containerRingFilter.sorter = deviceFilter, deviceSorter
+	c.ContainerRing = containerRingFilter
 	accountRing, err := cnf.GetRing("account", hashPathPrefix, hashPathSuffix, 0)
 	if err != nil {
 		return nil, err
 	}
-	c.AccountRing = newClientRingFilter(accountRing, readAffinity, "", "", 0)
+	accountRingFilter := newClientRingFilter(accountRing, readAffinity, "", "", 0)
+	accountRingFilter.health = c.health
+	accountRingFilter.filter, accountRingFilter.sorter = deviceFilter, deviceSorter
+	c.AccountRing = accountRingFilter
 	c.objectClients = make(map[int]proxyObjectClient)
 	for _, policy := range c.policyList {
 		// TODO: the intention is to (if it becomes necessary) have a policy type to object client
@@ -152,10 +242,13 @@ func NewProxyClient(policyList conf.PolicyList, cnf srv.ConfigLoader, logger srv
 				deviceLimit = 3
 			}
 		}
+		objectRing := newClientRingFilter(ring, policyReadAffinity, policyWriteAffinity, policyWriteAffinityCount, deviceLimit)
+		objectRing.health = c.health
+		objectRing.filter, objectRing.sorter = deviceFilter, deviceSorter
 		client := &standardObjectClient{
 			pdc:        c,
 			policy:     policy.Index,
-			objectRing: newClientRingFilter(ring, policyReadAffinity, policyWriteAffinity, policyWriteAffinityCount, deviceLimit),
+			objectRing: objectRing,
 			Logger:     logger,
 		}
 		c.objectClients[policy.Index] = client
@@ -163,10 +256,32 @@ func NewProxyClient(policyList conf.PolicyList, cnf srv.ConfigLoader, logger srv
 	return c, nil
 }
 
+func (c *proxyClient) DeviceHealth() []DeviceHealthEntry {
+	return c.health.entries()
+}
+
 func (c *proxyClient) SetUserAgent(v string) {
 	c.userAgent = v
 }
 
+func (c *proxyClient) SetPriority(v string) {
+	c.priority = v
+}
+
+// priorityClient marks backend requests with the proxyClient's priority,
+// unless they were given their own.
+type priorityClient struct {
+	common.HTTPClient
+	pdc *proxyClient
+}
+
+func (pc *priorityClient) Do(req *http.Request) (*http.Response, error) {
+	if pc.pdc.priority != "" && req.Header.Get(common.PriorityHeader) == "" {
+		req.Header.Set(common.PriorityHeader, pc.pdc.priority)
+	}
+	return pc.HTTPClient.Do(req)
+}
+
 // quorumResponse returns with a response representative of a quorum of nodes.
 //
 // This is analogous to swift's best_response function.
@@ -187,6 +302,9 @@ func (c *proxyClient) quorumResponse(r ringFilter, partition uint64, devToReques
 					c.Logger.Error("unable to get response", zap.Error(err))
 					resp = nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
 				} else {
+					if r.StatusCode == http.StatusInsufficientStorage {
+						c.health.markFull(dev)
+					}
 					resp = nectarutil.StubResponse(r)
 				}
 				if firstResp == nil {
@@ -232,6 +350,18 @@ func (c *proxyClient) quorumResponse(r ringFilter, partition uint64, devToReques
 	return nectarutil.ResponseStub(http.StatusServiceUnavailable, "Unknown State")
 }
 
+// backendTimestamp returns the X-Backend-Timestamp of resp, falling back to
+// its X-Timestamp, or the zero time if it has neither.
+func backendTimestamp(resp *http.Response) time.Time {
+	ts, err := common.ParseDate(resp.Header.Get("X-Backend-Timestamp"))
+	if err != nil {
+		if ts, err = common.ParseDate(resp.Header.Get("X-Timestamp")); err != nil {
+			return time.Time{}
+		}
+	}
+	return ts
+}
+
 func (c *proxyClient) firstResponse(r ringFilter, partition uint64, devToRequest func(*ring.Device) (*http.Request, error)) (resp *http.Response) {
 	receivedResponses := make(chan *http.Response)
 	alreadyFoundGoodResponse := make(chan struct{})
@@ -240,9 +370,25 @@ func (c *proxyClient) firstResponse(r ringFilter, partition uint64, devToRequest
 	internalErrors := 0
 	notFounds := 0
 	backendHeaders := map[string]string{}
+	requestsPending := 0
+	// newestTombstone is the newest X-Backend-Timestamp seen on a 404, so a
+	// replica that missed a delete can't resurrect the object.
+	var newestTombstone time.Time
+	deletedSince := func(resp *http.Response) bool {
+		if newestTombstone.IsZero() || resp.StatusCode/100 != 2 {
+			return false
+		}
+		ts := backendTimestamp(resp)
+		return !ts.IsZero() && ts.Before(newestTombstone)
+	}
 	interpretResponse := func(resp *http.Response) *http.Response {
 		if resp != nil && (resp.StatusCode/100 == 2 || resp.StatusCode == http.StatusPreconditionFailed ||
 			resp.StatusCode == http.StatusNotModified || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable) {
+			if deletedSince(resp) {
+				resp.Body.Close()
+				notFounds++
+				return nil
+			}
 			resp.Header.Set("Accept-Ranges", "bytes")
 			if etag := resp.Header.Get("Etag"); etag != "" {
 				resp.Header.Set("Etag", strings.Trim(etag, "\""))
@@ -251,40 +397,56 @@ func (c *proxyClient) firstResponse(r ringFilter, partition uint64, devToRequest
 		}
 		if resp != nil {
 			resp.Body.Close()
-			for k := range resp.Header {
-				if strings.HasPrefix(k, "X-Backend") {
-					backendHeaders[k] = resp.Header.Get(k)
-				}
-			}
+			tombstone := false
 			if resp.StatusCode == http.StatusNotFound {
 				notFounds++
+				if ts := backendTimestamp(resp); ts.After(newestTombstone) {
+					newestTombstone = ts
+					tombstone = true
+					backendHeaders = map[string]string{}
+				}
 			} else {
 				internalErrors++
 			}
+			if tombstone || newestTombstone.IsZero() {
+				for k := range resp.Header {
+					if strings.HasPrefix(k, "X-Backend") {
+						backendHeaders[k] = resp.Header.Get(k)
+					}
+				}
+			}
 		} else {
 			internalErrors++
 		}
 		return nil
 	}
-	maxRequests := int(r.ReplicaCount()) * 2
-	requestsPending := 0
-	for requestCount := 0; requestCount < maxRequests; requestCount++ {
-		var dev *ring.Device
-		if requestCount < len(devs) {
-			dev = devs[requestCount]
-		} else {
-			dev = more.Next()
-			if dev == nil {
-				break
+	// newestResponse waits up to firstResponseCompareTimeout for the other
+	// requests in flight before settling on resp, preferring the newest
+	// success and giving up on resp if a newer tombstone turns up.
+	newestResponse := func(resp *http.Response) *http.Response {
+		timeout := time.After(firstResponseCompareTimeout)
+		for done := false; !done && requestsPending > 0; {
+			select {
+			case other := <-receivedResponses:
+				requestsPending--
+				if other = interpretResponse(other); other != nil {
+					if other.StatusCode/100 == 2 && backendTimestamp(other).After(backendTimestamp(resp)) {
+						resp, other = other, resp
+					}
+					other.Body.Close()
+				}
+			case <-timeout:
+				done = true
 			}
 		}
-		req, err := devToRequest(dev)
-		if err != nil {
-			c.Logger.Error("firstResponse devToRequest error", zap.Error(err))
-			internalErrors++
-			continue
+		if deletedSince(resp) {
+			resp.Body.Close()
+			notFounds++
+			return nil
 		}
-
+		return resp
+	}
+	send := func(req *http.Request) {
 		requestsPending++
 		go func(r *http.Request) {
 			response, err := c.client.Do(r)
@@ -303,29 +465,77 @@ func (c *proxyClient) firstResponse(r ringFilter, partition uint64, devToRequest
 				}
 			}
 		}(req)
+	}
+	// wait returns the first good response of those still pending, or nil
+	// once they've all failed or firstResponseFinalTimeout passes.
+	wait := func() *http.Response {
+		giveUp := time.After(firstResponseFinalTimeout)
+		for requestsPending > 0 {
+			select {
+			case resp := <-receivedResponses:
+				requestsPending--
+				if resp = interpretResponse(resp); resp != nil {
+					if resp = newestResponse(resp); resp != nil {
+						return resp
+					}
+				}
+			case <-giveUp:
+				internalErrors += requestsPending
+				requestsPending = 0
+			}
+		}
+		return nil
+	}
+	maxRequests := int(r.ReplicaCount()) * 2
+	for requestCount := 0; requestCount < maxRequests; requestCount++ {
+		var dev *ring.Device
+		if requestCount < len(devs) {
+			dev = devs[requestCount]
+		} else {
+			dev = more.Next()
+			if dev == nil {
+				break
+			}
+		}
+		req, err := devToRequest(dev)
+		if err != nil {
+			c.Logger.Error("firstResponse devToRequest error", zap.Error(err))
+			internalErrors++
+			continue
+		}
 
+		send(req)
 		select {
 		case resp = <-receivedResponses:
 			requestsPending--
-			resp = interpretResponse(resp)
-			if resp != nil {
-				return resp
+			if resp = interpretResponse(resp); resp != nil {
+				if resp = newestResponse(resp); resp != nil {
+					return resp
+				}
 			}
 		case <-time.After(time.Second):
 		}
 	}
-	giveUp := time.After(firstResponseFinalTimeout)
-	for requestsPending > 0 {
-		select {
-		case resp = <-receivedResponses:
-			requestsPending--
-			resp = interpretResponse(resp)
-			if resp != nil {
-				return resp
+	if resp = wait(); resp != nil {
+		return resp
+	}
+	if notFounds > 0 && internalErrors == 0 && newestTombstone.IsZero() &&
+		time.Since(ring.ModTime(r.ring())) < c.rebalanceReadWindow {
+		// Nobody has so much as a tombstone, which is also what primaries
+		// that were just given the partition by a rebalance look like, so
+		// with the ring recently changed check a few more handoffs for
+		// wherever it used to be.
+		for i := 0; i < c.rebalanceReadHandoffs; i++ {
+			dev := more.Next()
+			if dev == nil {
+				break
+			}
+			if req, err := devToRequest(dev); err == nil {
+				send(req)
 			}
-		case <-giveUp:
-			internalErrors += requestsPending
-			requestsPending = 0
+		}
+		if resp = wait(); resp != nil {
+			return resp
 		}
 	}
 	if notFounds > internalErrors {
@@ -368,6 +578,10 @@ func (c *requestClient) SetUserAgent(v string) {
 	c.pdc.SetUserAgent(v)
 }
 
+func (c *requestClient) SetPriority(v string) {
+	c.pdc.SetPriority(v)
+}
+
 func (c *requestClient) getObjectClient(ctx context.Context, account string, container string, mc ring.MemcacheRing, lc map[string]*ContainerInfo) proxyObjectClient {
 	ci, err := c.GetContainerInfo(ctx, account, container)
 	if err != nil {
@@ -380,6 +594,39 @@ func (c *requestClient) getObjectClient(ctx context.Context, account string, con
 	return c.pdc.objectClients[ci.StoragePolicyIndex]
 }
 
+// PredialObjectWrite starts connections to the primaries a PUT of the object
+// would go to, if put_predial is on. It's meant to be called once the PUT is
+// authorized, with the container's info already looked up; without the info
+// it guesses the container is in the default storage policy.
+func (c *requestClient) PredialObjectWrite(account, container, obj string) {
+	if c.pdc.predialer == nil {
+		return
+	}
+	policy := c.pdc.policyList.Default()
+	if c.lc != nil {
+		c.lcm.RLock()
+		if ci := c.lc[fmt.Sprintf("container/%s/%s", account, container)]; ci != nil {
+			policy = ci.StoragePolicyIndex
+		}
+		c.lcm.RUnlock()
+	}
+	oc, ok := c.pdc.objectClients[policy].(*standardObjectClient)
+	if !ok {
+		return
+	}
+	devs, _ := oc.objectRing.getWriteNodes(oc.objectRing.GetPartition(account, container, obj))
+	for _, dev := range devs {
+		c.pdc.predialer.predial(fmt.Sprintf("%s:%d", dev.Ip, dev.Port))
+	}
+}
+
+func (c *requestClient) getPolicyObjectClient(policy int) proxyObjectClient {
+	if oc, ok := c.pdc.objectClients[policy]; ok && oc != nil {
+		return oc
+	}
+	return &erroringObjectClient{http.StatusBadRequest, fmt.Sprintf("Unknown storage policy %d", policy)}
+}
+
 func (c *requestClient) invalidateContainerInfo(ctx context.Context, account string, container string) {
 	key := fmt.Sprintf("container/%s/%s", account, container)
 	if c.lc != nil {
@@ -393,7 +640,7 @@ func (c *requestClient) invalidateContainerInfo(ctx context.Context, account str
 func (c *requestClient) PutAccount(ctx context.Context, account string, headers http.Header) *http.Response {
 	partition := c.pdc.AccountRing.GetPartition(account, "", "")
 	return c.pdc.quorumResponse(c.pdc.AccountRing, partition, func(i int, dev *ring.Device) (*http.Request, error) {
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, partition, common.Urlencode(account))
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition, common.Urlencode(account))
 		req, err := http.NewRequest("PUT", url, nil)
 		if err != nil {
 			return nil, err
@@ -410,7 +657,7 @@ func (c *requestClient) PutAccount(ctx context.Context, account string, headers
 func (c *requestClient) PostAccount(ctx context.Context, account string, headers http.Header) *http.Response {
 	partition := c.pdc.AccountRing.GetPartition(account, "", "")
 	return c.pdc.quorumResponse(c.pdc.AccountRing, partition, func(i int, dev *ring.Device) (*http.Request, error) {
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, partition, common.Urlencode(account))
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition, common.Urlencode(account))
 		req, err := http.NewRequest("POST", url, nil)
 		if err != nil {
 			return nil, err
@@ -427,8 +674,8 @@ func (c *requestClient) PostAccount(ctx context.Context, account string, headers
 func (c *requestClient) GetAccountRaw(ctx context.Context, account string, options map[string]string, headers http.Header) *http.Response {
 	partition := c.pdc.AccountRing.GetPartition(account, "", "")
 	query := nectarutil.Mkquery(options)
-	return c.pdc.firstResponse(c.pdc.AccountRing, partition, func(dev *ring.Device) (*http.Request, error) {
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, partition,
+	return limitListing(c.pdc.firstResponse(c.pdc.AccountRing, partition, func(dev *ring.Device) (*http.Request, error) {
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
 			common.Urlencode(account), query)
 		req, err := http.NewRequest("GET", url, nil)
 		if err != nil {
@@ -440,13 +687,13 @@ func (c *requestClient) GetAccountRaw(ctx context.Context, account string, optio
 			req.Header.Set(key, headers.Get(key))
 		}
 		return req, nil
-	})
+	}), c.pdc.maxListingBytes)
 }
 
 func (c *requestClient) HeadAccount(ctx context.Context, account string, headers http.Header) *http.Response {
 	partition := c.pdc.AccountRing.GetPartition(account, "", "")
 	return c.pdc.firstResponse(c.pdc.AccountRing, partition, func(dev *ring.Device) (*http.Request, error) {
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, partition,
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
 			common.Urlencode(account))
 		req, err := http.NewRequest("HEAD", url, nil)
 		if err != nil {
@@ -464,7 +711,7 @@ func (c *requestClient) HeadAccount(ctx context.Context, account string, headers
 func (c *requestClient) DeleteAccount(ctx context.Context, account string, headers http.Header) *http.Response {
 	partition := c.pdc.AccountRing.GetPartition(account, "", "")
 	return c.pdc.quorumResponse(c.pdc.AccountRing, partition, func(i int, dev *ring.Device) (*http.Request, error) {
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, partition, common.Urlencode(account))
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition, common.Urlencode(account))
 		req, err := http.NewRequest("DELETE", url, nil)
 		if err != nil {
 			return nil, err
@@ -496,7 +743,7 @@ func (c *requestClient) PutContainer(ctx context.Context, account string, contai
 	}
 	containerReplicaCount := int(c.pdc.ContainerRing.ReplicaCount())
 	return c.pdc.quorumResponse(c.pdc.ContainerRing, partition, func(i int, dev *ring.Device) (*http.Request, error) {
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, partition,
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
 			common.Urlencode(account), common.Urlencode(container))
 		req, err := http.NewRequest("PUT", url, nil)
 		if err != nil {
@@ -521,7 +768,7 @@ func (c *requestClient) PostContainer(ctx context.Context, account string, conta
 	defer c.invalidateContainerInfo(ctx, account, container)
 	partition := c.pdc.ContainerRing.GetPartition(account, container, "")
 	return c.pdc.quorumResponse(c.pdc.ContainerRing, partition, func(i int, dev *ring.Device) (*http.Request, error) {
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, partition,
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
 			common.Urlencode(account), common.Urlencode(container))
 		req, err := http.NewRequest("POST", url, nil)
 		if err != nil {
@@ -539,8 +786,8 @@ func (c *requestClient) PostContainer(ctx context.Context, account string, conta
 func (c *requestClient) GetContainerRaw(ctx context.Context, account string, container string, options map[string]string, headers http.Header) *http.Response {
 	partition := c.pdc.ContainerRing.GetPartition(account, container, "")
 	query := nectarutil.Mkquery(options)
-	return c.pdc.firstResponse(c.pdc.ContainerRing, partition, func(dev *ring.Device) (*http.Request, error) {
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s/%s%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, partition,
+	return limitListing(c.pdc.firstResponse(c.pdc.ContainerRing, partition, func(dev *ring.Device) (*http.Request, error) {
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
 			common.Urlencode(account), common.Urlencode(container), query)
 		req, err := http.NewRequest("GET", url, nil)
 		if err != nil {
@@ -552,7 +799,7 @@ func (c *requestClient) GetContainerRaw(ctx context.Context, account string, con
 			req.Header.Set(key, headers.Get(key))
 		}
 		return req, nil
-	})
+	}), c.pdc.maxListingBytes)
 }
 
 func (c *requestClient) GetContainerInfo(ctx context.Context, account string, container string) (*ContainerInfo, error) {
@@ -612,6 +859,7 @@ func (c *requestClient) SetContainerInfo(ctx context.Context, account string, co
 	ci := &ContainerInfo{
 		Metadata:    make(map[string]string),
 		SysMetadata: make(map[string]string),
+		Timestamp:   resp.Header.Get("X-Timestamp"),
 	}
 	var err error
 	if ci.ObjectCount, err = strconv.ParseInt(resp.Header.Get("X-Container-Object-Count"), 10, 64); err != nil {
@@ -650,7 +898,7 @@ func (c *requestClient) SetContainerInfo(ctx context.Context, account string, co
 func (c *requestClient) HeadContainer(ctx context.Context, account string, container string, headers http.Header) *http.Response {
 	partition := c.pdc.ContainerRing.GetPartition(account, container, "")
 	return c.pdc.firstResponse(c.pdc.ContainerRing, partition, func(dev *ring.Device) (*http.Request, error) {
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, partition,
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
 			common.Urlencode(account), common.Urlencode(container))
 		req, err := http.NewRequest("HEAD", url, nil)
 		if err != nil {
@@ -672,7 +920,7 @@ func (c *requestClient) DeleteContainer(ctx context.Context, account string, con
 	accountDevices := c.pdc.AccountRing.GetNodes(accountPartition)
 	containerReplicaCount := int(c.pdc.ContainerRing.ReplicaCount())
 	return c.pdc.quorumResponse(c.pdc.ContainerRing, partition, func(i int, dev *ring.Device) (*http.Request, error) {
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, partition,
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
 			common.Urlencode(account), common.Urlencode(container))
 		req, err := http.NewRequest("DELETE", url, nil)
 		if err != nil {
@@ -689,12 +937,48 @@ func (c *requestClient) DeleteContainer(ctx context.Context, account string, con
 	})
 }
 
+func (c *requestClient) RestoreContainer(ctx context.Context, account string, container string, headers http.Header) *http.Response {
+	defer c.invalidateContainerInfo(ctx, account, container)
+	partition := c.pdc.ContainerRing.GetPartition(account, container, "")
+	accountPartition := c.pdc.AccountRing.GetPartition(account, "", "")
+	accountDevices := c.pdc.AccountRing.GetNodes(accountPartition)
+	containerReplicaCount := int(c.pdc.ContainerRing.ReplicaCount())
+	return c.pdc.quorumResponse(c.pdc.ContainerRing, partition, func(i int, dev *ring.Device) (*http.Request, error) {
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
+			common.Urlencode(account), common.Urlencode(container))
+		req, err := http.NewRequest("RESTORE", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", c.pdc.userAgent)
+		req = req.WithContext(tracing.CopySpanFromContext(ctx))
+		for key := range headers {
+			req.Header.Set(key, headers.Get(key))
+		}
+		req.Header.Set("X-Account-Partition", strconv.FormatUint(accountPartition, 10))
+		addUpdateHeaders("X-Account", req.Header, accountDevices, i, containerReplicaCount)
+		return req, nil
+	})
+}
+
 func (c *requestClient) PutObject(ctx context.Context, account string, container string, obj string, headers http.Header, src io.Reader) *http.Response {
-	return c.getObjectClient(ctx, account, container, c.mc, c.lc).putObject(ctx, account, container, obj, headers, src)
+	resp := c.getObjectClient(ctx, account, container, c.mc, c.lc).putObject(ctx, account, container, obj, headers, src)
+	if resp.StatusCode/100 == 2 && headers.Get("X-Delete-At") != "" {
+		c.queueExpiring(ctx, account, container, obj, headers)
+	}
+	return resp
+}
+
+func (c *requestClient) AppendObject(ctx context.Context, account string, container string, obj string, headers http.Header, src io.Reader) *http.Response {
+	return c.getObjectClient(ctx, account, container, c.mc, c.lc).appendObject(ctx, account, container, obj, headers, src)
 }
 
 func (c *requestClient) PostObject(ctx context.Context, account string, container string, obj string, headers http.Header) *http.Response {
-	return c.getObjectClient(ctx, account, container, c.mc, c.lc).postObject(ctx, account, container, obj, headers)
+	resp := c.getObjectClient(ctx, account, container, c.mc, c.lc).postObject(ctx, account, container, obj, headers)
+	if resp.StatusCode/100 == 2 && headers.Get("X-Delete-At") != "" {
+		c.queueExpiring(ctx, account, container, obj, headers)
+	}
+	return resp
 }
 
 func (c *requestClient) GetObject(ctx context.Context, account string, container string, obj string, headers http.Header) *http.Response {
@@ -709,6 +993,18 @@ func (c *requestClient) DeleteObject(ctx context.Context, account string, contai
 	return c.getObjectClient(ctx, account, container, c.mc, c.lc).deleteObject(ctx, account, container, obj, headers)
 }
 
+func (c *requestClient) GetObjectInPolicy(ctx context.Context, policy int, account string, container string, obj string, headers http.Header) *http.Response {
+	return c.getPolicyObjectClient(policy).getObject(ctx, account, container, obj, headers)
+}
+
+func (c *requestClient) DeleteObjectInPolicy(ctx context.Context, policy int, account string, container string, obj string, headers http.Header) *http.Response {
+	return c.getPolicyObjectClient(policy).deleteObject(ctx, account, container, obj, headers)
+}
+
+func (c *requestClient) ObjectReplicaMetadata(ctx context.Context, account string, container string, obj string) ([]*ReplicaMetadata, *http.Response) {
+	return c.getObjectClient(ctx, account, container, c.mc, c.lc).replicaMetadata(ctx, account, container, obj)
+}
+
 func (c *requestClient) ObjectRingFor(ctx context.Context, account string, container string) (ring.Ring, *http.Response) {
 	return c.getObjectClient(ctx, account, container, c.mc, c.lc).ring()
 }
@@ -0,0 +1,383 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/test"
+	"go.uber.org/zap"
+)
+
+// fakeBackends answers each request with the status and X-Backend-Timestamp
+// configured for the request's host.
+type fakeBackends map[string]fakeBackend
+
+type fakeBackend struct {
+	status    int
+	timestamp string
+}
+
+func (f fakeBackends) Do(req *http.Request) (*http.Response, error) {
+	b := f[req.URL.Host]
+	resp := &http.Response{StatusCode: b.status, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}
+	resp.Header.Set("X-Backend-Timestamp", b.timestamp)
+	return resp, nil
+}
+
+func tombstoneTestResponse(backends fakeBackends) *http.Response {
+	r := &fakeRing{
+		FakeRing: &test.FakeRing{MockGetMoreNodes: noMoreNodes{}},
+		nodes: []*ring.Device{
+			{Id: 0, Region: 1, Zone: 1, Ip: "127.0.0.1", Port: 6000, Device: "sda"},
+			{Id: 1, Region: 1, Zone: 2, Ip: "127.0.0.2", Port: 6000, Device: "sdb"},
+			{Id: 2, Region: 1, Zone: 3, Ip: "127.0.0.3", Port: 6000, Device: "sdc"},
+		},
+	}
+	c := &proxyClient{client: backends, Logger: zap.NewNop()}
+	// read affinity makes the devices get asked in order
+	filter := newClientRingFilter(r, "r1z1=100,r1z2=200,r1z3=300", "", "", 0)
+	return c.firstResponse(filter, 0, func(dev *ring.Device) (*http.Request, error) {
+		return http.NewRequest("GET", fmt.Sprintf("http://%s:%d/%s/0/a/c/o", dev.Ip, dev.Port, dev.Device), nil)
+	})
+}
+
+func TestFirstResponseTombstoneWins(t *testing.T) {
+	resp := tombstoneTestResponse(fakeBackends{
+		"127.0.0.1:6000": {http.StatusNotFound, "0000000200.00000"},
+		"127.0.0.2:6000": {http.StatusOK, "0000000100.00000"},
+		"127.0.0.3:6000": {http.StatusOK, "0000000100.00000"},
+	})
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.Equal(t, "0000000200.00000", resp.Header.Get("X-Backend-Timestamp"))
+}
+
+func TestFirstResponseOlderTombstone(t *testing.T) {
+	resp := tombstoneTestResponse(fakeBackends{
+		"127.0.0.1:6000": {http.StatusNotFound, "0000000050.00000"},
+		"127.0.0.2:6000": {http.StatusOK, "0000000100.00000"},
+		"127.0.0.3:6000": {http.StatusOK, "0000000100.00000"},
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "0000000100.00000", resp.Header.Get("X-Backend-Timestamp"))
+}
+
+type slowBackends struct {
+	fakeBackends
+	delays map[string]time.Duration
+}
+
+func (s slowBackends) Do(req *http.Request) (*http.Response, error) {
+	time.Sleep(s.delays[req.URL.Host])
+	return s.fakeBackends.Do(req)
+}
+
+func TestFirstResponseWaitsForNewer(t *testing.T) {
+	defer func(timeout time.Duration) { firstResponseCompareTimeout = timeout }(firstResponseCompareTimeout)
+	firstResponseCompareTimeout = time.Second
+	r := &fakeRing{
+		FakeRing: &test.FakeRing{MockGetMoreNodes: noMoreNodes{}},
+		nodes: []*ring.Device{
+			{Id: 0, Region: 1, Zone: 1, Ip: "127.0.0.1", Port: 6000, Device: "sda"},
+			{Id: 1, Region: 1, Zone: 2, Ip: "127.0.0.2", Port: 6000, Device: "sdb"},
+		},
+	}
+	for _, newer := range []fakeBackend{{http.StatusOK, "0000000200.00000"}, {http.StatusNotFound, "0000000200.00000"}} {
+		// The first device is slow enough that the second, stale one is
+		// asked too and answers first.
+		backends := slowBackends{
+			fakeBackends: fakeBackends{"127.0.0.1:6000": newer, "127.0.0.2:6000": {http.StatusOK, "0000000100.00000"}},
+			delays:       map[string]time.Duration{"127.0.0.1:6000": 1200 * time.Millisecond},
+		}
+		c := &proxyClient{client: backends, Logger: zap.NewNop()}
+		resp := c.firstResponse(newClientRingFilter(r, "r1z1=100,r1z2=200", "", "", 0), 0, func(dev *ring.Device) (*http.Request, error) {
+			return http.NewRequest("GET", fmt.Sprintf("http://%s:%d/%s/0/a/c/o", dev.Ip, dev.Port, dev.Device), nil)
+		})
+		require.Equal(t, newer.status, resp.StatusCode)
+		require.Equal(t, "0000000200.00000", resp.Header.Get("X-Backend-Timestamp"))
+	}
+}
+
+type handoffNodes []*ring.Device
+
+func (h *handoffNodes) Next() *ring.Device {
+	if len(*h) == 0 {
+		return nil
+	}
+	dev := (*h)[0]
+	*h = (*h)[1:]
+	return dev
+}
+
+func TestFirstResponseRebalanceHandoffs(t *testing.T) {
+	backends := fakeBackends{}
+	var handoffs handoffNodes
+	var primaries []*ring.Device
+	for i := 0; i < 7; i++ {
+		dev := &ring.Device{Id: i, Ip: fmt.Sprintf("127.0.0.%d", i+1), Port: 6000, Device: "sda"}
+		if i < 3 {
+			primaries = append(primaries, dev)
+		} else {
+			handoffs = append(handoffs, dev)
+		}
+		backends[fmt.Sprintf("127.0.0.%d:6000", i+1)] = fakeBackend{http.StatusNotFound, ""}
+	}
+	// Only the last handoff, past twice the replica count, has the object.
+	backends["127.0.0.7:6000"] = fakeBackend{http.StatusOK, "0000000100.00000"}
+	for _, tc := range []struct {
+		extra   int
+		changed time.Duration
+		status  int
+	}{
+		{0, time.Minute, http.StatusNotFound},
+		{3, time.Minute, http.StatusOK},
+		// Long after the ring changed, a 404 is just a 404.
+		{3, 2 * time.Hour, http.StatusNotFound},
+	} {
+		more := append(handoffNodes{}, handoffs...)
+		r := &fakeRing{FakeRing: &test.FakeRing{MockGetMoreNodes: &more}, nodes: primaries, modTime: time.Now().Add(-tc.changed)}
+		c := &proxyClient{client: backends, Logger: zap.NewNop(), rebalanceReadHandoffs: tc.extra, rebalanceReadWindow: time.Hour}
+		resp := c.firstResponse(newClientRingFilter(r, "", "", "", 0), 0, func(dev *ring.Device) (*http.Request, error) {
+			return http.NewRequest("GET", fmt.Sprintf("http://%s:%d/%s/0/a/c/o", dev.Ip, dev.Port, dev.Device), nil)
+		})
+		require.Equal(t, tc.status, resp.StatusCode)
+	}
+}
+
+func TestObjectReplicaMetadata(t *testing.T) {
+	r := &fakeRing{
+		FakeRing: &test.FakeRing{MockGetMoreNodes: &handoffNodes{
+			{Id: 3, Region: 1, Zone: 4, Ip: "127.0.0.4", Port: 6000, Device: "sdd", Scheme: "http"},
+		}},
+		nodes: []*ring.Device{
+			{Id: 0, Region: 1, Zone: 1, Ip: "127.0.0.1", Port: 6000, Device: "sda", Scheme: "http"},
+			{Id: 1, Region: 1, Zone: 2, Ip: "127.0.0.2", Port: 6000, Device: "sdb", Scheme: "http"},
+		},
+	}
+	backends := fakeBackends{
+		"127.0.0.1:6000": {http.StatusOK, "0000000100.00000"},
+		"127.0.0.2:6000": {http.StatusNotFound, "0000000200.00000"},
+		"127.0.0.4:6000": {http.StatusNotFound, ""},
+	}
+	c := &proxyClient{client: backends, Logger: zap.NewNop()}
+	oc := &standardObjectClient{pdc: c, policy: 1, objectRing: newClientRingFilter(r, "", "", "", 0), Logger: zap.NewNop()}
+	replicas, resp := oc.replicaMetadata(context.Background(), "a", "c", "o")
+	require.Nil(t, resp)
+	require.Equal(t, 3, len(replicas))
+	require.Equal(t, "sda", replicas[0].Device.Device)
+	require.False(t, replicas[0].Handoff)
+	require.Equal(t, http.StatusOK, replicas[0].StatusCode)
+	require.Equal(t, "0000000100.00000", replicas[0].Timestamp)
+	require.Equal(t, http.StatusNotFound, replicas[1].StatusCode)
+	require.Equal(t, "0000000200.00000", replicas[1].Timestamp)
+	require.Equal(t, "sdd", replicas[2].Device.Device)
+	require.True(t, replicas[2].Handoff)
+	require.Equal(t, "", replicas[2].Timestamp)
+}
+
+func TestFixContentLength(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"X-Backend-Content-Length": {"12"}}, ContentLength: -1}
+	resp = fixContentLength(resp)
+	require.Equal(t, "12", resp.Header.Get("Content-Length"))
+	require.Equal(t, int64(12), resp.ContentLength)
+	require.Equal(t, "", resp.Header.Get("X-Backend-Content-Length"))
+
+	resp = &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Length": {"5"}, "X-Backend-Content-Length": {"12"}}}
+	resp = fixContentLength(resp)
+	require.Equal(t, "5", resp.Header.Get("Content-Length"))
+
+	resp = &http.Response{StatusCode: http.StatusPartialContent, Header: http.Header{"X-Backend-Content-Length": {"12"}}}
+	resp = fixContentLength(resp)
+	require.Equal(t, "", resp.Header.Get("Content-Length"))
+}
+
+// slowPutBackends reads object PUT bodies, except that the backend at slow
+// doesn't start reading until release is closed.
+type slowPutBackends struct {
+	slow    string
+	release chan struct{}
+	lock    sync.Mutex
+	bodies  map[string]string
+}
+
+func (b *slowPutBackends) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == b.slow {
+		<-b.release
+	}
+	status := http.StatusCreated
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		status = 499
+	} else {
+		b.lock.Lock()
+		b.bodies[req.URL.Host] = string(body)
+		b.lock.Unlock()
+	}
+	return &http.Response{StatusCode: status, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+}
+
+func TestPutReadyTimeout(t *testing.T) {
+	r := &fakeRing{
+		FakeRing: &test.FakeRing{MockGetMoreNodes: noMoreNodes{}},
+		nodes: []*ring.Device{
+			{Id: 0, Ip: "127.0.0.1", Port: 6000, Device: "sda", Scheme: "http"},
+			{Id: 1, Ip: "127.0.0.2", Port: 6000, Device: "sdb", Scheme: "http"},
+			{Id: 2, Ip: "127.0.0.3", Port: 6000, Device: "sdc", Scheme: "http"},
+		},
+	}
+	backends := &slowPutBackends{slow: "127.0.0.3:6000", release: make(chan struct{}), bodies: map[string]string{}}
+	defer close(backends.release)
+	c := &proxyClient{client: backends, Logger: zap.NewNop(), ContainerRing: newClientRingFilter(r, "", "", "", 0), putReadyTimeout: 10 * time.Millisecond}
+	oc := &standardObjectClient{pdc: c, policy: 0, objectRing: newClientRingFilter(r, "", "", "", 0), Logger: zap.NewNop()}
+	done := make(chan *http.Response)
+	go func() {
+		done <- oc.putObject(context.Background(), "a", "c", "o", http.Header{}, strings.NewReader("some data"))
+	}()
+	select {
+	case resp := <-done:
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	case <-time.After(5 * time.Second):
+		t.Fatal("PUT waited on the backend that wasn't ready")
+	}
+	backends.lock.Lock()
+	defer backends.lock.Unlock()
+	require.Equal(t, map[string]string{"127.0.0.1:6000": "some data", "127.0.0.2:6000": "some data"}, backends.bodies)
+}
+
+// statusPutBackends answers object PUTs with the status configured for the
+// request's host, reading the body only for a success, as an object server
+// turns a PUT away before sending 100 Continue.
+type statusPutBackends struct {
+	statuses map[string]int
+	lock     sync.Mutex
+	bodies   map[string]string
+}
+
+func (b *statusPutBackends) Do(req *http.Request) (*http.Response, error) {
+	status := b.statuses[req.URL.Host]
+	if status/100 == 2 {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		b.lock.Lock()
+		b.bodies[req.URL.Host] = string(body)
+		b.lock.Unlock()
+	}
+	return &http.Response{StatusCode: status, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+}
+
+func TestPutMaxHandoffs(t *testing.T) {
+	r := &fakeRing{
+		FakeRing: &test.FakeRing{MockGetMoreNodes: &handoffNodes{
+			{Id: 3, Ip: "127.0.0.4", Port: 6000, Device: "sdd", Scheme: "http"},
+			{Id: 4, Ip: "127.0.0.5", Port: 6000, Device: "sde", Scheme: "http"},
+		}},
+		nodes: []*ring.Device{
+			{Id: 0, Ip: "127.0.0.1", Port: 6000, Device: "sda", Scheme: "http"},
+			{Id: 1, Ip: "127.0.0.2", Port: 6000, Device: "sdb", Scheme: "http"},
+			{Id: 2, Ip: "127.0.0.3", Port: 6000, Device: "sdc", Scheme: "http"},
+		},
+	}
+	backends := &statusPutBackends{statuses: map[string]int{
+		"127.0.0.1:6000": http.StatusCreated,
+		"127.0.0.2:6000": http.StatusServiceUnavailable,
+		"127.0.0.3:6000": http.StatusServiceUnavailable,
+		"127.0.0.4:6000": http.StatusCreated,
+		"127.0.0.5:6000": http.StatusCreated,
+	}, bodies: map[string]string{}}
+	c := &proxyClient{client: backends, Logger: zap.NewNop(), ContainerRing: newClientRingFilter(r, "", "", "", 0), putMaxHandoffs: 1}
+	oc := &standardObjectClient{pdc: c, policy: 0, objectRing: newClientRingFilter(r, "", "", "", 0), Logger: zap.NewNop()}
+	resp := oc.putObject(context.Background(), "a", "c", "o", http.Header{}, strings.NewReader("some data"))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, "1", resp.Header.Get("X-Backend-Handoff-Used"))
+	backends.lock.Lock()
+	defer backends.lock.Unlock()
+	// The first primary and just one of the two handoffs got the object.
+	require.Equal(t, 2, len(backends.bodies))
+	require.Equal(t, "some data", backends.bodies["127.0.0.1:6000"])
+	handoffs := 0
+	for _, host := range []string{"127.0.0.4:6000", "127.0.0.5:6000"} {
+		if backends.bodies[host] == "some data" {
+			handoffs++
+		}
+	}
+	require.Equal(t, 1, handoffs)
+}
+
+func TestPutSmallObject(t *testing.T) {
+	r := &fakeRing{
+		FakeRing: &test.FakeRing{MockGetMoreNodes: &handoffNodes{
+			{Id: 3, Ip: "127.0.0.4", Port: 6000, Device: "sdd", Scheme: "http"},
+		}},
+		nodes: []*ring.Device{
+			{Id: 0, Ip: "127.0.0.1", Port: 6000, Device: "sda", Scheme: "http"},
+			{Id: 1, Ip: "127.0.0.2", Port: 6000, Device: "sdb", Scheme: "http"},
+			{Id: 2, Ip: "127.0.0.3", Port: 6000, Device: "sdc", Scheme: "http"},
+		},
+	}
+	var expects []string
+	var lock sync.Mutex
+	backends := &statusPutBackends{statuses: map[string]int{
+		"127.0.0.1:6000": http.StatusCreated,
+		"127.0.0.2:6000": http.StatusCreated,
+		"127.0.0.3:6000": http.StatusServiceUnavailable,
+		"127.0.0.4:6000": http.StatusCreated,
+	}, bodies: map[string]string{}}
+	recordExpect := RequestInterceptor(func(req *http.Request, next common.HTTPClient) (*http.Response, error) {
+		lock.Lock()
+		expects = append(expects, req.Header.Get("Expect"))
+		lock.Unlock()
+		return next.Do(req)
+	})
+	c := &proxyClient{client: &interceptClient{HTTPClient: backends, interceptor: recordExpect}, Logger: zap.NewNop(),
+		ContainerRing: newClientRingFilter(r, "", "", "", 0), putSmallObjectSize: 9, putMaxHandoffs: -1}
+	oc := &standardObjectClient{pdc: c, policy: 0, objectRing: newClientRingFilter(r, "", "", "", 0), Logger: zap.NewNop()}
+	resp := oc.putObject(context.Background(), "a", "c", "o", http.Header{"Content-Length": {"9"}}, strings.NewReader("some data"))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	backends.lock.Lock()
+	require.Equal(t, map[string]string{"127.0.0.1:6000": "some data", "127.0.0.2:6000": "some data", "127.0.0.4:6000": "some data"}, backends.bodies)
+	backends.lock.Unlock()
+	lock.Lock()
+	require.Equal(t, []string{"", "", "", ""}, expects)
+	expects = nil
+	lock.Unlock()
+
+	// A body shorter than its Content-Length fails without sending anything.
+	resp = oc.putObject(context.Background(), "a", "c", "o", http.Header{"Content-Length": {"9"}}, strings.NewReader("some"))
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	lock.Lock()
+	require.Nil(t, expects)
+	lock.Unlock()
+
+	// Bigger objects still wait for 100 Continue; the handoff was used up
+	// above, so the third primary just fails.
+	resp = oc.putObject(context.Background(), "a", "c", "o", http.Header{"Content-Length": {"10"}}, strings.NewReader("some data!"))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	lock.Lock()
+	require.Equal(t, []string{"100-continue", "100-continue", "100-continue"}, expects)
+	lock.Unlock()
+}
@@ -0,0 +1,113 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+var (
+	// ErrNotFound is returned when the account, container or object doesn't
+	// exist.
+	ErrNotFound = errors.New("not found")
+	// ErrQuorumFailed is returned when not enough backend servers could
+	// handle the request for it to count.
+	ErrQuorumFailed = errors.New("quorum not reached")
+	// ErrConflict is returned for requests that conflict with the current
+	// state, such as deleting a container that isn't empty.
+	ErrConflict = errors.New("conflict")
+	// ErrPreconditionFailed is returned when an If-Match or If-None-Match
+	// condition didn't hold.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrUnauthorized is returned when the request's credentials weren't
+	// accepted or don't allow it.
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
+// maxErrorBody is how much of an error response's body StatusError keeps.
+const maxErrorBody = 1024
+
+// StatusError is returned for failed requests that don't have one of the
+// error values above.
+type StatusError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Body is the start of the response's body, which usually explains the
+	// status.
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body != "" {
+		return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("%s %s: %d", e.Method, e.Path, e.StatusCode)
+}
+
+// StatusCode returns the HTTP status behind err, or 0 if it isn't one of
+// this package's request errors.
+func StatusCode(err error) int {
+	switch err {
+	case ErrNotFound:
+		return http.StatusNotFound
+	case ErrQuorumFailed:
+		return http.StatusServiceUnavailable
+	case ErrConflict:
+		return http.StatusConflict
+	case ErrPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case ErrUnauthorized:
+		return http.StatusUnauthorized
+	}
+	if e, ok := err.(*StatusError); ok {
+		return e.StatusCode
+	}
+	return 0
+}
+
+// checkResponse returns nil for 2xx responses. Otherwise it closes the
+// response's body and returns the error for its status.
+func checkResponse(resp *http.Response, method, path string) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusServiceUnavailable:
+		return ErrQuorumFailed
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return &StatusError{Method: method, Path: path, StatusCode: resp.StatusCode, Body: string(body)}
+}
+
+// discard closes a response whose body isn't wanted.
+func discard(resp *http.Response) {
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxErrorBody))
+	resp.Body.Close()
+}
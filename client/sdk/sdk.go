@@ -0,0 +1,505 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+// Package sdk wraps the hummingbird and nectar clients in typed calls, so
+// applications get errors such as ErrNotFound instead of having to check the
+// status of every *http.Response.
+//
+//	c, err := sdk.NewDirect("AUTH_test", srv.DefaultConfigLoader{}, "", "", logger)
+//	if err != nil { ... }
+//	info, err := c.HeadObject("photos", "cat.jpg")
+//	if err == sdk.ErrNotFound { ... }
+package sdk
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/troubling/hummingbird/client"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/nectar"
+)
+
+// Client makes typed requests through a nectar.Client for a single account.
+type Client struct {
+	c nectar.Client
+}
+
+// New wraps c, which may be any nectar.Client, such as the ones from
+// client.NewDirectClient or nectar.NewClient.
+func New(c nectar.Client) *Client {
+	return &Client{c: c}
+}
+
+// NewDirect returns a Client that talks to the backend servers directly,
+// without a proxy, as the account.
+func NewDirect(account string, cnf srv.ConfigLoader, certFile, keyFile string, logger srv.LowLevelLogger) (*Client, error) {
+	c, err := client.NewDirectClient(account, cnf, certFile, keyFile, logger)
+	if err != nil {
+		return nil, err
+	}
+	return New(c), nil
+}
+
+// Raw returns the wrapped client, for requests this package doesn't cover.
+func (c *Client) Raw() nectar.Client {
+	return c.c
+}
+
+// Info is what a HEAD returns for an account, container or object.
+type Info struct {
+	// Metadata is the user metadata, keyed by name without the
+	// X-<Type>-Meta- prefix. Values that were RFC 2047 or RFC 2231 encoded
+	// are decoded to UTF-8.
+	Metadata map[string]string
+	// Header is the whole response header.
+	Header http.Header
+}
+
+func newInfo(header http.Header, metaPrefix string) Info {
+	info := Info{Metadata: map[string]string{}, Header: header}
+	for k := range header {
+		if strings.HasPrefix(k, metaPrefix) {
+			info.Metadata[k[len(metaPrefix):]] = common.DecodeMetaValue(header.Get(k))
+		}
+	}
+	return info
+}
+
+func headerInt(header http.Header, name string) int64 {
+	v, _ := strconv.ParseInt(header.Get(name), 10, 64)
+	return v
+}
+
+// AccountInfo describes an account.
+type AccountInfo struct {
+	Info
+	ContainerCount int64
+	ObjectCount    int64
+	BytesUsed      int64
+}
+
+// ContainerInfo describes a container.
+type ContainerInfo struct {
+	Info
+	ObjectCount int64
+	BytesUsed   int64
+	ReadACL     string
+	WriteACL    string
+}
+
+// ObjectInfo describes an object.
+type ObjectInfo struct {
+	Info
+	ContentType  string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	// DeleteAt is when the object expires, or the zero time if it doesn't.
+	DeleteAt time.Time
+}
+
+func newObjectInfo(resp *http.Response) *ObjectInfo {
+	info := &ObjectInfo{
+		Info:        newInfo(resp.Header, "X-Object-Meta-"),
+		ContentType: resp.Header.Get("Content-Type"),
+		Size:        headerInt(resp.Header, "Content-Length"),
+		ETag:        strings.Trim(resp.Header.Get("Etag"), "\""),
+	}
+	if t, err := common.ParseDate(resp.Header.Get("Last-Modified")); err == nil {
+		info.LastModified = t
+	}
+	if at := headerInt(resp.Header, "X-Delete-At"); at > 0 {
+		info.DeleteAt = time.Unix(at, 0)
+	}
+	return info
+}
+
+// ContainerOptions are the settings for PutContainer and PostContainer; any
+// left empty are left as they are.
+type ContainerOptions struct {
+	Metadata map[string]string
+	ReadACL  string
+	WriteACL string
+	// StoragePolicy names the policy for a new container.
+	StoragePolicy string
+	// Headers are sent as they are, after the settings above.
+	Headers map[string]string
+}
+
+func (o *ContainerOptions) headers() map[string]string {
+	h := map[string]string{}
+	if o == nil {
+		return h
+	}
+	for k, v := range o.Metadata {
+		h["X-Container-Meta-"+k] = v
+	}
+	if o.ReadACL != "" {
+		h["X-Container-Read"] = o.ReadACL
+	}
+	if o.WriteACL != "" {
+		h["X-Container-Write"] = o.WriteACL
+	}
+	if o.StoragePolicy != "" {
+		h["X-Storage-Policy"] = o.StoragePolicy
+	}
+	for k, v := range o.Headers {
+		h[k] = v
+	}
+	return h
+}
+
+// PutOptions are the settings for PutObject and PostObject.
+type PutOptions struct {
+	ContentType string
+	Metadata    map[string]string
+	// DeleteAt makes the object expire at the given time.
+	DeleteAt time.Time
+	// IfNoneMatch "*" only creates the object if it doesn't already exist.
+	IfNoneMatch string
+	// ETag is the MD5 the object's content must have.
+	ETag string
+	// Headers are sent as they are, after the settings above.
+	Headers map[string]string
+}
+
+func (o *PutOptions) headers() map[string]string {
+	h := map[string]string{}
+	if o == nil {
+		return h
+	}
+	if o.ContentType != "" {
+		h["Content-Type"] = o.ContentType
+	}
+	for k, v := range o.Metadata {
+		h["X-Object-Meta-"+k] = v
+	}
+	if !o.DeleteAt.IsZero() {
+		h["X-Delete-At"] = strconv.FormatInt(o.DeleteAt.Unix(), 10)
+	}
+	if o.IfNoneMatch != "" {
+		h["If-None-Match"] = o.IfNoneMatch
+	}
+	if o.ETag != "" {
+		h["Etag"] = o.ETag
+	}
+	for k, v := range o.Headers {
+		h[k] = v
+	}
+	return h
+}
+
+// GetOptions are the settings for GetObject.
+type GetOptions struct {
+	// Offset and Length select part of the object; a Length of 0 reads to
+	// the end.
+	Offset int64
+	Length int64
+	// IfMatch and IfNoneMatch make the GET conditional on the object's ETag.
+	IfMatch     string
+	IfNoneMatch string
+	// Headers are sent as they are, after the settings above.
+	Headers map[string]string
+}
+
+func (o *GetOptions) headers() map[string]string {
+	h := map[string]string{}
+	if o == nil {
+		return h
+	}
+	if o.Length > 0 {
+		h["Range"] = fmt.Sprintf("bytes=%d-%d", o.Offset, o.Offset+o.Length-1)
+	} else if o.Offset > 0 {
+		h["Range"] = fmt.Sprintf("bytes=%d-", o.Offset)
+	}
+	if o.IfMatch != "" {
+		h["If-Match"] = o.IfMatch
+	}
+	if o.IfNoneMatch != "" {
+		h["If-None-Match"] = o.IfNoneMatch
+	}
+	for k, v := range o.Headers {
+		h[k] = v
+	}
+	return h
+}
+
+// ListOptions select and order the entries of a listing.
+type ListOptions struct {
+	Prefix    string
+	Delimiter string
+	Marker    string
+	EndMarker string
+	// Limit is the most entries a single listing request returns; the
+	// server's maximum if 0.
+	Limit   int
+	Reverse bool
+}
+
+func (o *ListOptions) orDefault() *ListOptions {
+	if o == nil {
+		return &ListOptions{}
+	}
+	return o
+}
+
+func objectPath(container, object string) string {
+	return container + "/" + object
+}
+
+// HeadAccount returns the account's usage and metadata.
+func (c *Client) HeadAccount() (*AccountInfo, error) {
+	resp := c.c.HeadAccount(nil)
+	if err := checkResponse(resp, "HEAD", ""); err != nil {
+		return nil, err
+	}
+	discard(resp)
+	return &AccountInfo{
+		Info:           newInfo(resp.Header, "X-Account-Meta-"),
+		ContainerCount: headerInt(resp.Header, "X-Account-Container-Count"),
+		ObjectCount:    headerInt(resp.Header, "X-Account-Object-Count"),
+		BytesUsed:      headerInt(resp.Header, "X-Account-Bytes-Used"),
+	}, nil
+}
+
+// PostAccount sets the account's metadata; an empty value removes an item.
+func (c *Client) PostAccount(metadata map[string]string) error {
+	h := map[string]string{}
+	for k, v := range metadata {
+		h["X-Account-Meta-"+k] = v
+	}
+	resp := c.c.PostAccount(h)
+	if err := checkResponse(resp, "POST", ""); err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+// PutContainer creates the container, or updates it if it already exists.
+func (c *Client) PutContainer(container string, opts *ContainerOptions) error {
+	resp := c.c.PutContainer(container, opts.headers())
+	if err := checkResponse(resp, "PUT", container); err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+// PostContainer updates the container's metadata and ACLs.
+func (c *Client) PostContainer(container string, opts *ContainerOptions) error {
+	resp := c.c.PostContainer(container, opts.headers())
+	if err := checkResponse(resp, "POST", container); err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+// HeadContainer returns the container's usage, ACLs and metadata.
+func (c *Client) HeadContainer(container string) (*ContainerInfo, error) {
+	resp := c.c.HeadContainer(container, nil)
+	if err := checkResponse(resp, "HEAD", container); err != nil {
+		return nil, err
+	}
+	discard(resp)
+	return &ContainerInfo{
+		Info:        newInfo(resp.Header, "X-Container-Meta-"),
+		ObjectCount: headerInt(resp.Header, "X-Container-Object-Count"),
+		BytesUsed:   headerInt(resp.Header, "X-Container-Bytes-Used"),
+		ReadACL:     resp.Header.Get("X-Container-Read"),
+		WriteACL:    resp.Header.Get("X-Container-Write"),
+	}, nil
+}
+
+// DeleteContainer deletes the container, returning ErrConflict if it isn't
+// empty.
+func (c *Client) DeleteContainer(container string) error {
+	resp := c.c.DeleteContainer(container, nil)
+	if err := checkResponse(resp, "DELETE", container); err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+// PutObject uploads src as the object, returning its ETag.
+func (c *Client) PutObject(container, object string, src io.Reader, opts *PutOptions) (string, error) {
+	resp := c.c.PutObject(container, object, opts.headers(), src)
+	if err := checkResponse(resp, "PUT", objectPath(container, object)); err != nil {
+		return "", err
+	}
+	discard(resp)
+	return strings.Trim(resp.Header.Get("Etag"), "\""), nil
+}
+
+// PostObject replaces the object's metadata with the options' Metadata,
+// ContentType and DeleteAt.
+func (c *Client) PostObject(container, object string, opts *PutOptions) error {
+	resp := c.c.PostObject(container, object, opts.headers())
+	if err := checkResponse(resp, "POST", objectPath(container, object)); err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+// HeadObject returns the object's size, type and metadata.
+func (c *Client) HeadObject(container, object string) (*ObjectInfo, error) {
+	resp := c.c.HeadObject(container, object, nil)
+	if err := checkResponse(resp, "HEAD", objectPath(container, object)); err != nil {
+		return nil, err
+	}
+	discard(resp)
+	return newObjectInfo(resp), nil
+}
+
+// GetObject returns a stream of the object's content, which the caller must
+// close, along with its information. For ranged reads, Size is the length
+// of the range.
+func (c *Client) GetObject(container, object string, opts *GetOptions) (io.ReadCloser, *ObjectInfo, error) {
+	resp := c.c.GetObject(container, object, opts.headers())
+	if err := checkResponse(resp, "GET", objectPath(container, object)); err != nil {
+		return nil, nil, err
+	}
+	return resp.Body, newObjectInfo(resp), nil
+}
+
+// Download copies the object's content to w, returning the number of bytes
+// copied.
+func (c *Client) Download(container, object string, w io.Writer) (int64, error) {
+	body, _, err := c.GetObject(container, object, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	return io.Copy(w, body)
+}
+
+// DeleteObject deletes the object.
+func (c *Client) DeleteObject(container, object string) error {
+	resp := c.c.DeleteObject(container, object, nil)
+	if err := checkResponse(resp, "DELETE", objectPath(container, object)); err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+// ListObjects returns a streaming listing of one page of the container's
+// objects; use EachObject to go through all of them.
+func (c *Client) ListObjects(container string, opts *ListOptions) (*client.ObjectListing, error) {
+	o := opts.orDefault()
+	if lc, ok := c.c.(client.ListingClient); ok {
+		listing, resp := lc.GetContainerListing(container, o.Marker, o.EndMarker, o.Limit, o.Prefix, o.Delimiter, o.Reverse, nil)
+		if err := checkResponse(resp, "GET", container); err != nil {
+			return nil, err
+		}
+		return listing, nil
+	}
+	resp := c.c.GetContainerRaw(container, o.Marker, o.EndMarker, o.Limit, o.Prefix, o.Delimiter, o.Reverse, nil)
+	if err := checkResponse(resp, "GET", container); err != nil {
+		return nil, err
+	}
+	return client.NewObjectListing(resp), nil
+}
+
+// EachObject calls f for each of the container's objects, page by page,
+// stopping early if f returns an error, which it then returns.
+func (c *Client) EachObject(container string, opts *ListOptions, f func(*nectar.ObjectRecord) error) error {
+	o := *opts.orDefault()
+	for {
+		listing, err := c.ListObjects(container, &o)
+		if err != nil {
+			return err
+		}
+		count := 0
+		for listing.Next() {
+			r := listing.Record()
+			count++
+			if r.Subdir != "" {
+				o.Marker = r.Subdir
+			} else {
+				o.Marker = r.Name
+			}
+			if err := f(r); err != nil {
+				listing.Close()
+				return err
+			}
+		}
+		if err := listing.Err(); err != nil {
+			return err
+		}
+		if count == 0 || (o.Limit > 0 && count < o.Limit) {
+			return nil
+		}
+	}
+}
+
+// ListContainers returns a streaming listing of one page of the account's
+// containers; use EachContainer to go through all of them.
+func (c *Client) ListContainers(opts *ListOptions) (*client.ContainerListing, error) {
+	o := opts.orDefault()
+	if lc, ok := c.c.(client.ListingClient); ok {
+		listing, resp := lc.GetAccountListing(o.Marker, o.EndMarker, o.Limit, o.Prefix, o.Delimiter, o.Reverse, nil)
+		if err := checkResponse(resp, "GET", ""); err != nil {
+			return nil, err
+		}
+		return listing, nil
+	}
+	resp := c.c.GetAccountRaw(o.Marker, o.EndMarker, o.Limit, o.Prefix, o.Delimiter, o.Reverse, nil)
+	if err := checkResponse(resp, "GET", ""); err != nil {
+		return nil, err
+	}
+	return client.NewContainerListing(resp), nil
+}
+
+// EachContainer calls f for each of the account's containers, page by page,
+// stopping early if f returns an error, which it then returns.
+func (c *Client) EachContainer(opts *ListOptions, f func(*nectar.ContainerRecord) error) error {
+	o := *opts.orDefault()
+	for {
+		listing, err := c.ListContainers(&o)
+		if err != nil {
+			return err
+		}
+		count := 0
+		for listing.Next() {
+			r := listing.Record()
+			count++
+			if r.Subdir != "" {
+				o.Marker = r.Subdir
+			} else {
+				o.Marker = r.Name
+			}
+			if err := f(r); err != nil {
+				listing.Close()
+				return err
+			}
+		}
+		if err := listing.Err(); err != nil {
+			return err
+		}
+		if count == 0 || (o.Limit > 0 && count < o.Limit) {
+			return nil
+		}
+	}
+}
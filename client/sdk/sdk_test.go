@@ -0,0 +1,151 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/nectar"
+	"github.com/troubling/nectar/nectarutil"
+)
+
+type sdkTestClient struct {
+	nectar.Client
+	headers map[string]string
+	status  int
+	objects []string
+}
+
+func (c *sdkTestClient) HeadObject(container string, obj string, headers map[string]string) *http.Response {
+	resp := nectarutil.ResponseStub(c.status, "")
+	resp.Header.Set("Content-Type", "text/plain")
+	resp.Header.Set("Content-Length", "5")
+	resp.Header.Set("Etag", "\"abc\"")
+	resp.Header.Set("Last-Modified", "Thu, 05 Jul 2018 18:16:09 GMT")
+	resp.Header.Set("X-Delete-At", "1600000000")
+	resp.Header.Set("X-Object-Meta-Color", "blue")
+	resp.Header.Set("X-Object-Meta-Name", "=?UTF-8?B?Y2Fmw6k=?=")
+	return resp
+}
+
+func (c *sdkTestClient) GetObject(container string, obj string, headers map[string]string) *http.Response {
+	c.headers = headers
+	return nectarutil.ResponseStub(c.status, "hello")
+}
+
+func (c *sdkTestClient) PutObject(container string, obj string, headers map[string]string, src io.Reader) *http.Response {
+	c.headers = headers
+	resp := nectarutil.ResponseStub(c.status, "")
+	resp.Header.Set("Etag", "5d41402abc4b2a76b9719d911017c592")
+	return resp
+}
+
+func (c *sdkTestClient) DeleteContainer(container string, headers map[string]string) *http.Response {
+	return nectarutil.ResponseStub(c.status, "There was a conflict")
+}
+
+func (c *sdkTestClient) GetContainerRaw(container string, marker string, endMarker string, limit int, prefix string, delimiter string, reverse bool, headers map[string]string) *http.Response {
+	var page []*nectar.ObjectRecord
+	for _, name := range c.objects {
+		if name > marker && len(page) < limit {
+			page = append(page, &nectar.ObjectRecord{Name: name})
+		}
+	}
+	body, _ := json.Marshal(page)
+	if page == nil {
+		body = []byte("[]")
+	}
+	return nectarutil.ResponseStub(http.StatusOK, string(body))
+}
+
+func TestErrors(t *testing.T) {
+	fc := &sdkTestClient{}
+	c := New(fc)
+	for status, expected := range map[int]error{404: ErrNotFound, 503: ErrQuorumFailed, 409: ErrConflict, 412: ErrPreconditionFailed, 403: ErrUnauthorized} {
+		fc.status = status
+		_, err := c.HeadObject("c", "o")
+		require.Equal(t, expected, err)
+	}
+	fc.status = 400
+	err := c.DeleteContainer("c")
+	require.Equal(t, &StatusError{Method: "DELETE", Path: "c", StatusCode: 400, Body: "There was a conflict"}, err)
+	require.Equal(t, 400, StatusCode(err))
+	require.Equal(t, 404, StatusCode(ErrNotFound))
+	require.Equal(t, 0, StatusCode(errors.New("other")))
+}
+
+func TestObjects(t *testing.T) {
+	fc := &sdkTestClient{status: 200}
+	c := New(fc)
+	info, err := c.HeadObject("c", "o")
+	require.Nil(t, err)
+	require.Equal(t, "text/plain", info.ContentType)
+	require.Equal(t, int64(5), info.Size)
+	require.Equal(t, "abc", info.ETag)
+	require.Equal(t, int64(1530814569), info.LastModified.Unix())
+	require.Equal(t, int64(1600000000), info.DeleteAt.Unix())
+	require.Equal(t, map[string]string{"Color": "blue", "Name": "café"}, info.Metadata)
+
+	body, _, err := c.GetObject("c", "o", &GetOptions{Offset: 10, Length: 5, IfMatch: "abc"})
+	require.Nil(t, err)
+	data, err := ioutil.ReadAll(body)
+	require.Nil(t, err)
+	require.Equal(t, "hello", string(data))
+	require.Equal(t, "bytes=10-14", fc.headers["Range"])
+	require.Equal(t, "abc", fc.headers["If-Match"])
+
+	var buf bytes.Buffer
+	n, err := c.Download("c", "o", &buf)
+	require.Nil(t, err)
+	require.Equal(t, int64(5), n)
+	require.Equal(t, "", fc.headers["Range"])
+
+	fc.status = 201
+	etag, err := c.PutObject("c", "o", bytes.NewBufferString("hello"), &PutOptions{ContentType: "text/plain", Metadata: map[string]string{"Color": "red"}})
+	require.Nil(t, err)
+	require.Equal(t, "5d41402abc4b2a76b9719d911017c592", etag)
+	require.Equal(t, "red", fc.headers["X-Object-Meta-Color"])
+	require.Equal(t, "text/plain", fc.headers["Content-Type"])
+}
+
+func TestEachObject(t *testing.T) {
+	fc := &sdkTestClient{objects: []string{"a", "b", "c", "d", "e"}}
+	c := New(fc)
+	var names []string
+	require.Nil(t, c.EachObject("c", &ListOptions{Limit: 2}, func(r *nectar.ObjectRecord) error {
+		names = append(names, r.Name)
+		return nil
+	}))
+	require.Equal(t, fc.objects, names)
+
+	stop := errors.New("stop")
+	names = nil
+	require.Equal(t, stop, c.EachObject("c", &ListOptions{Limit: 2, Marker: "a"}, func(r *nectar.ObjectRecord) error {
+		names = append(names, r.Name)
+		if r.Name == "c" {
+			return stop
+		}
+		return nil
+	}))
+	require.Equal(t, []string{"b", "c"}, names)
+}
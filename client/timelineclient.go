@@ -0,0 +1,45 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package client
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/tracing"
+)
+
+// timelineClient records each backend request in the tracing.Timeline of
+// the request's context, if it has one.
+type timelineClient struct {
+	common.HTTPClient
+}
+
+func (tc *timelineClient) Do(req *http.Request) (*http.Response, error) {
+	t := tracing.TimelineFromContext(req.Context())
+	if t == nil {
+		return tc.HTTPClient.Do(req)
+	}
+	start := time.Now()
+	resp, err := tc.HTTPClient.Do(req)
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	t.Add(req.Method, req.URL.String(), status, err, start)
+	return resp, err
+}
@@ -0,0 +1,237 @@
+package client
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/nectar"
+)
+
+const (
+	defaultUploadSegmentSize = 100 * 1024 * 1024
+	defaultUploadConcurrency = 4
+)
+
+// UploadBigOptions controls how UploadBig splits and uploads an object.
+type UploadBigOptions struct {
+	// Client is used for all requests and is required.
+	Client nectar.Client
+	// SegmentContainer holds the segments; it defaults to container+"_segments"
+	// and will be created if it doesn't exist.
+	SegmentContainer string
+	// SegmentSize is the size of every segment but the last; defaults to 100MiB.
+	SegmentSize int64
+	// Concurrency is the number of segments uploaded at once; defaults to 4.
+	// Each in flight segment is buffered in memory.
+	Concurrency int
+	// Checkpoint is the path of a file recording the segments uploaded so
+	// far. If it already exists, the upload resumes from it. It is removed
+	// once the manifest has been written.
+	Checkpoint string
+	// Headers are sent with the manifest PUT.
+	Headers map[string]string
+}
+
+type uploadCheckpointSegment struct {
+	Path      string `json:"path"`
+	Etag      string `json:"etag"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+type uploadCheckpoint struct {
+	Container        string                           `json:"container"`
+	Object           string                           `json:"object"`
+	SegmentContainer string                           `json:"segment_container"`
+	SegmentSize      int64                            `json:"segment_size"`
+	UploadID         string                           `json:"upload_id"`
+	Segments         map[int]*uploadCheckpointSegment `json:"segments"`
+}
+
+func loadUploadCheckpoint(path string) (*uploadCheckpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cp := &uploadCheckpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint file %s: %v", path, err)
+	}
+	if cp.Segments == nil {
+		cp.Segments = map[int]*uploadCheckpointSegment{}
+	}
+	return cp, nil
+}
+
+func (cp *uploadCheckpoint) save(path string) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+type uploadSegment struct {
+	index int
+	data  []byte
+	etag  string
+}
+
+// UploadBig uploads src as a static large object. The data is split into
+// segments which are uploaded concurrently through PutObject, and once all of
+// them are stored the SLO manifest is written to container/obj.
+//
+// If opts.Checkpoint is set, progress is recorded there and a failed upload
+// may be retried with the same options and source to continue where it left
+// off. Segments already recorded are still read from src, but are only
+// uploaded again if their content changed.
+func UploadBig(container, obj string, src io.Reader, opts *UploadBigOptions) error {
+	if opts == nil || opts.Client == nil {
+		return fmt.Errorf("UploadBig requires a client")
+	}
+	c := opts.Client
+	segSize := opts.SegmentSize
+	if segSize <= 0 {
+		segSize = defaultUploadSegmentSize
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultUploadConcurrency
+	}
+	segContainer := opts.SegmentContainer
+	if segContainer == "" {
+		segContainer = container + "_segments"
+	}
+
+	cp := &uploadCheckpoint{
+		Container:        container,
+		Object:           obj,
+		SegmentContainer: segContainer,
+		SegmentSize:      segSize,
+		UploadID:         common.GetTimestamp(),
+		Segments:         map[int]*uploadCheckpointSegment{},
+	}
+	if opts.Checkpoint != "" {
+		if old, err := loadUploadCheckpoint(opts.Checkpoint); err == nil {
+			if old.Container != container || old.Object != obj || old.SegmentContainer != segContainer || old.SegmentSize != segSize {
+				return fmt.Errorf("checkpoint %s is for a different upload", opts.Checkpoint)
+			}
+			cp = old
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	resp := c.PutContainer(segContainer, nil)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unable to create segment container %s: %d", segContainer, resp.StatusCode)
+	}
+
+	var cpLock sync.Mutex
+	var firstErr error
+	setErr := func(err error) {
+		cpLock.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		cpLock.Unlock()
+	}
+	failed := func() bool {
+		cpLock.Lock()
+		defer cpLock.Unlock()
+		return firstErr != nil
+	}
+
+	segments := make(chan *uploadSegment, concurrency)
+	wg := sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for seg := range segments {
+				if failed() {
+					continue
+				}
+				path := fmt.Sprintf("%s/%s/%08d", obj, cp.UploadID, seg.index)
+				resp := c.PutObject(segContainer, path, map[string]string{"Etag": seg.etag}, bytes.NewReader(seg.data))
+				resp.Body.Close()
+				if resp.StatusCode/100 != 2 {
+					setErr(fmt.Errorf("unable to upload segment %s/%s: %d", segContainer, path, resp.StatusCode))
+					continue
+				}
+				cpLock.Lock()
+				cp.Segments[seg.index] = &uploadCheckpointSegment{
+					Path:      segContainer + "/" + path,
+					Etag:      seg.etag,
+					SizeBytes: int64(len(seg.data)),
+				}
+				if opts.Checkpoint != "" {
+					if err := cp.save(opts.Checkpoint); err != nil && firstErr == nil {
+						firstErr = err
+					}
+				}
+				cpLock.Unlock()
+			}
+		}()
+	}
+
+	count := 0
+	for ; !failed(); count++ {
+		data := make([]byte, segSize)
+		n, err := io.ReadFull(src, data)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			setErr(err)
+			break
+		}
+		if n == 0 && count > 0 {
+			break
+		}
+		seg := &uploadSegment{index: count, data: data[:n], etag: fmt.Sprintf("%x", md5.Sum(data[:n]))}
+		cpLock.Lock()
+		done := cp.Segments[count]
+		cpLock.Unlock()
+		if done == nil || done.Etag != seg.etag || done.SizeBytes != int64(n) {
+			segments <- seg
+		}
+		if err != nil {
+			count++
+			break
+		}
+	}
+	close(segments)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	manifest := make([]*uploadCheckpointSegment, 0, count)
+	for i := 0; i < count; i++ {
+		manifest = append(manifest, cp.Segments[i])
+	}
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	resp = c.Raw("PUT", fmt.Sprintf("%s/%s?multipart-manifest=put", common.Urlencode(container), common.Urlencode(obj)), opts.Headers, bytes.NewReader(body))
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unable to write manifest %s/%s: %d", container, obj, resp.StatusCode)
+	}
+	if opts.Checkpoint != "" {
+		if err := os.Remove(opts.Checkpoint); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/nectar"
+	"github.com/troubling/nectar/nectarutil"
+)
+
+type uploadTestClient struct {
+	nectar.Client
+	lock     sync.Mutex
+	objects  map[string][]byte
+	puts     int
+	failPuts map[string]bool
+	manifest []map[string]interface{}
+}
+
+func newUploadTestClient() *uploadTestClient {
+	return &uploadTestClient{objects: map[string][]byte{}, failPuts: map[string]bool{}}
+}
+
+func (c *uploadTestClient) PutContainer(container string, headers map[string]string) *http.Response {
+	return nectarutil.ResponseStub(http.StatusCreated, "")
+}
+
+func (c *uploadTestClient) PutObject(container string, obj string, headers map[string]string, src io.Reader) *http.Response {
+	data, _ := ioutil.ReadAll(src)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.puts++
+	for suffix := range c.failPuts {
+		if strings.HasSuffix(obj, suffix) {
+			return nectarutil.ResponseStub(http.StatusServiceUnavailable, "")
+		}
+	}
+	c.objects[container+"/"+obj] = data
+	return nectarutil.ResponseStub(http.StatusCreated, "")
+}
+
+func (c *uploadTestClient) Raw(method, urlAfterAccount string, headers map[string]string, body io.Reader) *http.Response {
+	if method != "PUT" || urlAfterAccount != "c/o?multipart-manifest=put" {
+		return nectarutil.ResponseStub(http.StatusBadRequest, "")
+	}
+	if err := json.NewDecoder(body).Decode(&c.manifest); err != nil {
+		return nectarutil.ResponseStub(http.StatusBadRequest, "")
+	}
+	return nectarutil.ResponseStub(http.StatusCreated, "")
+}
+
+func (c *uploadTestClient) assemble() []byte {
+	var buf bytes.Buffer
+	for _, seg := range c.manifest {
+		buf.Write(c.objects[seg["path"].(string)])
+	}
+	return buf.Bytes()
+}
+
+func TestUploadBig(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 105)
+	c := newUploadTestClient()
+	require.Nil(t, UploadBig("c", "o", bytes.NewReader(data), &UploadBigOptions{Client: c, SegmentSize: 100, Concurrency: 3}))
+	require.Equal(t, 11, len(c.manifest))
+	require.Equal(t, float64(50), c.manifest[10]["size_bytes"])
+	require.True(t, strings.HasPrefix(c.manifest[0]["path"].(string), "c_segments/o/"))
+	require.Equal(t, data, c.assemble())
+}
+
+func TestUploadBigResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	checkpoint := filepath.Join(dir, "checkpoint")
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	c := newUploadTestClient()
+	c.failPuts["/00000005"] = true
+	opts := &UploadBigOptions{Client: c, SegmentSize: 100, Concurrency: 1, Checkpoint: checkpoint}
+	require.NotNil(t, UploadBig("c", "o", bytes.NewReader(data), opts))
+	require.Nil(t, c.manifest)
+	cp, err := loadUploadCheckpoint(checkpoint)
+	require.Nil(t, err)
+	require.Equal(t, 5, len(cp.Segments))
+
+	delete(c.failPuts, "/00000005")
+	c.puts = 0
+	require.Nil(t, UploadBig("c", "o", bytes.NewReader(data), opts))
+	require.Equal(t, 5, c.puts)
+	require.Equal(t, data, c.assemble())
+	_, err = os.Stat(checkpoint)
+	require.True(t, os.IsNotExist(err))
+
+	require.Nil(t, cp.save(checkpoint))
+	require.NotNil(t, UploadBig("c", "other", bytes.NewReader(data), opts))
+}
@@ -163,6 +163,12 @@ func initCommand(args []string) error {
 	print(`# tempauth_enabled = false`)
 	print(``)
 	print(`[filter:tempauth]`)
+	print(`# Accounts under any of the reseller prefixes are handled by this auth;`)
+	print(`# tokens and storage URLs use the first. '' matches any other account.`)
+	print(`# reseller_prefix = AUTH, SERVICE`)
+	print(`# Requests to SERVICE_ accounts also need an X-Service-Token from a`)
+	print(`# user in this group.`)
+	print(`# SERVICE_require_group = .service`)
 	print(`user_admin_admin = admin .admin .reseller_admin`)
 	print(`user_test_tester = testing .admin`)
 	print(`user_test2_tester2 = testing2 .admin`)
@@ -185,10 +191,10 @@ func initCommand(args []string) error {
 	print(`# delay_auth_decision = True`)
 	print(`# `)
 	print(`# [filter:keystoneauth]`)
-	print(`# reseller_prefix = AUTH`)
+	print(`# reseller_prefix = AUTH, SERVICE`)
 	print(`# operator_roles = admin, swiftoperator`)
 	print(`# reseller_admin_role = ResellerAdmin`)
-	print(`# GLANCE_service_roles = service`)
+	print(`# SERVICE_service_roles = service`)
 	print(`# default_domain_id = default`)
 	print(`# allow_names_in_acls = false`)
 	print(``)
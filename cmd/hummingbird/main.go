@@ -238,6 +238,7 @@ func main() {
 	proxyFlags.String("c", findConfig("proxy"), "Config file/directory to use")
 	proxyFlags.String("l", "stdout", "Log location")
 	proxyFlags.String("e", "stderr", "Error log location")
+	proxyFlags.String("log-format", "json", "Log format: json or console")
 	proxyFlags.Usage = func() {
 		fmt.Fprintln(os.Stderr, "hummingbird proxy [ARGS]")
 		fmt.Fprintln(os.Stderr, "  Run proxy server")
@@ -248,6 +249,7 @@ func main() {
 	objectFlags.String("c", findConfig("object"), "Config file/directory to use")
 	objectFlags.String("l", "stdout", "Log location")
 	objectFlags.String("e", "stderr", "Error log location")
+	objectFlags.String("log-format", "json", "Log format: json or console")
 	objectFlags.Usage = func() {
 		fmt.Fprintln(os.Stderr, "hummingbird object [ARGS]")
 		fmt.Fprintln(os.Stderr, "  Run object server")
@@ -259,6 +261,7 @@ func main() {
 	objectReplicatorFlags.String("c", findConfig("object"), "Config file/directory to use")
 	objectReplicatorFlags.String("l", "stdout", "Log location")
 	objectReplicatorFlags.String("e", "stderr", "Error log location")
+	objectReplicatorFlags.String("log-format", "json", "Log format: json or console")
 	objectReplicatorFlags.Bool("once", false, "Run one pass of the replicator")
 	objectReplicatorFlags.String("devices", "", "Replicate only given devices. Comma-separated list.")
 	objectReplicatorFlags.String("partitions", "", "Replicate only given partitions. Comma-separated list.")
@@ -272,6 +275,7 @@ func main() {
 	containerFlags.String("c", findConfig("container"), "Config file/directory to use")
 	containerFlags.String("l", "stdout", "Log location")
 	containerFlags.String("e", "stderr", "Error log location")
+	containerFlags.String("log-format", "json", "Log format: json or console")
 	containerFlags.Usage = func() {
 		fmt.Fprintln(os.Stderr, "hummingbird container [ARGS]")
 		fmt.Fprintln(os.Stderr, "  Run container server")
@@ -282,6 +286,7 @@ func main() {
 	containerReplicatorFlags.String("c", findConfig("container"), "Config file/directory to use")
 	containerReplicatorFlags.String("l", "stdout", "Log location")
 	containerReplicatorFlags.String("e", "stderr", "Error log location")
+	containerReplicatorFlags.String("log-format", "json", "Log format: json or console")
 	containerReplicatorFlags.Bool("once", false, "Run one pass of the replicator")
 	containerReplicatorFlags.Usage = func() {
 		fmt.Fprintln(os.Stderr, "hummingbird container-replicator [ARGS]")
@@ -293,6 +298,7 @@ func main() {
 	accountFlags.String("c", findConfig("account"), "Config file/directory to use")
 	accountFlags.String("l", "stdout", "Log location")
 	accountFlags.String("e", "stderr", "Error log location")
+	accountFlags.String("log-format", "json", "Log format: json or console")
 	accountFlags.Usage = func() {
 		fmt.Fprintln(os.Stderr, "hummingbird account [ARGS]")
 		fmt.Fprintln(os.Stderr, "  Run account server")
@@ -303,6 +309,7 @@ func main() {
 	accountReplicatorFlags.String("c", findConfig("account"), "Config file/directory to use")
 	accountReplicatorFlags.String("l", "stdout", "Log location")
 	accountReplicatorFlags.String("e", "stderr", "Error log location")
+	accountReplicatorFlags.String("log-format", "json", "Log format: json or console")
 	accountReplicatorFlags.Bool("once", false, "Run one pass of the replicator")
 	accountReplicatorFlags.Usage = func() {
 		fmt.Fprintln(os.Stderr, "hummingbird account-replicator [ARGS]")
@@ -317,10 +324,10 @@ func main() {
 		fmt.Fprintf(os.Stderr, "hummingbird ring <builder_file> command\n")
 		fmt.Fprintf(os.Stderr, "  Builds a swift style ring.  Commands are:\n")
 		fmt.Fprintf(os.Stderr, "    create <part_power> <replicas> <min_part_hours> (create a new ring)\n")
-		fmt.Fprintf(os.Stderr, "    add <device> <weight> (add a new device to the ring)\n")
+		fmt.Fprintf(os.Stderr, "    add <device> <weight> [-ramp <rebalances>] (add a new device to the ring)\n")
 		fmt.Fprintf(os.Stderr, "    rebalance [-dryrun] (rebalance the ring)\n")
 		fmt.Fprintf(os.Stderr, "    search <search_flags> (search for devices in the ring)\n")
-		fmt.Fprintf(os.Stderr, "    set_weight <search_flags> [-yes] <weight> (change the weight of 1 or more devices)\n")
+		fmt.Fprintf(os.Stderr, "    set_weight <search_flags> [-yes] [-ramp <rebalances>] <weight> (change the weight of 1 or more devices)\n")
 		fmt.Fprintf(os.Stderr, "    remove <search_flags> [-yes] (remove device from the ring)\n")
 		fmt.Fprintf(os.Stderr, "    set_info <search_flags> [-yes] <change_flags> (change device information)\n")
 		fmt.Fprintf(os.Stderr, "    info (display ring info)\n")
@@ -328,10 +335,11 @@ func main() {
 		fmt.Fprintf(os.Stderr, "    validate (validate ring)\n")
 		fmt.Fprintf(os.Stderr, "    write_ring (write the ring file)\n")
 		fmt.Fprintf(os.Stderr, "    pretend_min_part_hours_passed (reset min_part_hours)\n")
+		fmt.Fprintf(os.Stderr, "    increase_partition_power (double the number of partitions)\n")
 		fmt.Fprintf(os.Stderr, "  <device> is of the form: [r<region>]z<zone>[s<scheme>]-<ip>:<port>[R<r_ip>:<r_port>]/<device_name>_<meta>\n")
 		fmt.Fprintf(os.Stderr, "  <scheme> can be either http or https\n")
 		fmt.Fprintf(os.Stderr, "  <search_flags> is at least one of: -region, -zone, -scheme, -ip, -port, -replication-ip, replication-port, -device, -meta, -weight\n")
-		fmt.Fprintf(os.Stderr, "  <change_flags> is at least one of: -change-ip, -change-port, -change-replication-ip, -change-replication-port, -change-device, -change-meta, -change-scheme\n")
+		fmt.Fprintf(os.Stderr, "  <change_flags> is at least one of: -change-ip, -change-port, -change-replication-ip, -change-replication-port, -change-device, -change-meta, -change-scheme, -change-tags\n")
 		ringBuilderFlags.PrintDefaults()
 	}
 
@@ -351,10 +359,20 @@ func main() {
 		nodesFlags.PrintDefaults()
 	}
 
+	hashFlags := flag.NewFlagSet("", flag.ExitOnError)
+	hashFlags.String("d", "/srv/node", "Directory the object servers' devices are mounted under")
+	hashFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "hummingbird hash [-d devices] <account> <container> <object>\n")
+		fmt.Fprintf(os.Stderr, "hummingbird hash [-d devices] <account>/<container>/<object>\n")
+		fmt.Fprintf(os.Stderr, "  Shows an object's hash, and its partition and on-disk path in every storage policy\n")
+		hashFlags.PrintDefaults()
+	}
+
 	andrewdFlags := flag.NewFlagSet("andrewd", flag.ExitOnError)
 	andrewdFlags.String("c", findConfig("andrewd"), "Config file to use")
 	andrewdFlags.String("l", "stdout", "Log location")
 	andrewdFlags.String("e", "stderr", "Error log location")
+	andrewdFlags.String("log-format", "json", "Log format: json or console")
 	andrewdFlags.Bool("once", false, "Run one pass of the tools")
 	andrewdFlags.Usage = func() {
 		fmt.Fprintln(os.Stderr, "hummingbird andrewd [ARGS]")
@@ -380,6 +398,8 @@ func main() {
 	reconFlags.Bool("progress", false, "Show andrewd progress report; state of internal processes")
 	reconFlags.Bool("md5", false, "Get md5sum of servers ring and compare to local copy")
 	reconFlags.Bool("time", false, "Check time synchronization")
+	reconFlags.Float64("max-skew", 1, "Seconds a server's clock may differ from this one's before -time fails")
+	reconFlags.String("time-hosts", "", "Comma separated scheme://ip:port[/obfuscated_prefix] URLs of servers outside the rings, such as proxies and andrewd, to include in -time")
 	reconFlags.Bool("q", false, "Get cluster quarantine stats")
 	reconFlags.Bool("qd", false, "Get cluster quarantine detailed report")
 	reconFlags.Bool("a", false, "Get cluster async pending stats")
@@ -399,6 +419,31 @@ func main() {
 		reconFlags.PrintDefaults()
 	}
 
+	ringManifestFlags := flag.NewFlagSet("", flag.ExitOnError)
+	ringManifestFlags.String("d", "/etc/hummingbird", "Directory with the rings to list")
+	ringManifestFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "hummingbird ring-manifest [ARGS]\n")
+		fmt.Fprintf(os.Stderr, "  Prints the signed rings.json for distributing the rings in a directory.\n")
+		ringManifestFlags.PrintDefaults()
+	}
+
+	topologyFlags := flag.NewFlagSet("", flag.ExitOnError)
+	topologyFlags.String("format", "json", "Output format: json or dot")
+	topologyFlags.Bool("norecon", false, "Don't query the servers' recon for device usage")
+	topologyFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "hummingbird topology [ARGS]\n")
+		fmt.Fprintf(os.Stderr, "  Dumps the rings' regions, zones, servers and devices with their weights and fill levels.\n")
+		topologyFlags.PrintDefaults()
+	}
+
+	verifyConfigFlags := flag.NewFlagSet("", flag.ExitOnError)
+	verifyConfigFlags.String("d", "/etc/hummingbird", "Directory with the server configs to check")
+	verifyConfigFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "hummingbird verify-config [ARGS]\n")
+		fmt.Fprintf(os.Stderr, "  Checks the server configs, storage policies and rings against each other.\n")
+		verifyConfigFlags.PrintDefaults()
+	}
+
 	/* main flag parser, which doesn't do much */
 
 	flag.Usage = func() {
@@ -427,6 +472,12 @@ func main() {
 		fmt.Fprintln(os.Stderr, "hummingbird restoredevice [ip] [device-name]")
 		fmt.Fprintln(os.Stderr, "  Reconstruct a device from its peers")
 		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "hummingbird relinker [-cleanup] [new partition power]")
+		fmt.Fprintln(os.Stderr, "  Relink objects for a ring whose partition power was increased")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "hummingbird reclaim [-c config] [-reclaim_age seconds] [-dry_run]")
+		fmt.Fprintln(os.Stderr, "  Remove old tombstones and empty hash directories from object devices")
+		fmt.Fprintln(os.Stderr)
 		fmt.Fprintln(os.Stderr, "hummingbird bench CONFIG")
 		fmt.Fprintln(os.Stderr, "  Run bench tool")
 		fmt.Fprintln(os.Stderr)
@@ -453,11 +504,19 @@ func main() {
 		fmt.Fprintln(os.Stderr)
 		nodesFlags.Usage()
 		fmt.Fprintln(os.Stderr)
+		hashFlags.Usage()
+		fmt.Fprintln(os.Stderr)
 		andrewdFlags.Usage()
 		fmt.Fprintln(os.Stderr)
 		objectInfoFlags.Usage()
 		fmt.Fprintln(os.Stderr)
 		reconFlags.Usage()
+		fmt.Fprintln(os.Stderr)
+		verifyConfigFlags.Usage()
+		fmt.Fprintln(os.Stderr)
+		topologyFlags.Usage()
+		fmt.Fprintln(os.Stderr)
+		ringManifestFlags.Usage()
 	}
 
 	flag.Parse()
@@ -515,12 +574,19 @@ func main() {
 		objectserver.MoveParts(flag.Args()[1:], srv.DefaultConfigLoader{})
 	case "restoredevice":
 		objectserver.RestoreDevice(flag.Args()[1:], srv.DefaultConfigLoader{})
+	case "relinker":
+		objectserver.Relink(flag.Args()[1:], srv.DefaultConfigLoader{})
+	case "reclaim":
+		objectserver.Reclaim(flag.Args()[1:], findConfig("object"), srv.DefaultConfigLoader{})
 	case "ring":
 		ringBuilderFlags.Parse(flag.Args()[1:])
 		tools.RingBuildCmd(ringBuilderFlags)
 	case "nodes":
 		nodesFlags.Parse(flag.Args()[1:])
 		tools.Nodes(nodesFlags, srv.DefaultConfigLoader{})
+	case "hash":
+		hashFlags.Parse(flag.Args()[1:])
+		tools.Hash(hashFlags, srv.DefaultConfigLoader{})
 	case "andrewd":
 		andrewdFlags.Parse(flag.Args()[1:])
 		srv.RunServers(tools.NewAdmin, andrewdFlags)
@@ -532,6 +598,21 @@ func main() {
 		if pass := tools.ReconClient(reconFlags, srv.DefaultConfigLoader{}); !pass {
 			os.Exit(1)
 		}
+	case "verify-config":
+		verifyConfigFlags.Parse(flag.Args()[1:])
+		if pass := tools.VerifyConfig(verifyConfigFlags, srv.DefaultConfigLoader{}); !pass {
+			os.Exit(1)
+		}
+	case "topology":
+		topologyFlags.Parse(flag.Args()[1:])
+		if pass := tools.Topology(topologyFlags, srv.DefaultConfigLoader{}); !pass {
+			os.Exit(1)
+		}
+	case "ring-manifest":
+		ringManifestFlags.Parse(flag.Args()[1:])
+		if pass := tools.RingManifest(ringManifestFlags); !pass {
+			os.Exit(1)
+		}
 	case "init":
 		if err := initCommand(flag.Args()[1:]); err != nil {
 			fmt.Fprintln(os.Stderr, "init error:", err)
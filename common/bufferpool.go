@@ -0,0 +1,84 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package common
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultCopyBufferSize is the size of the buffers Copy and friends use
+// unless SetCopyBufferSize says otherwise.
+const DefaultCopyBufferSize = 64 * 1024
+
+// BufferPool hands out reusable byte slices of a fixed size, so streaming
+// objects through doesn't allocate a new buffer for every request.
+type BufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+// NewBufferPool returns a BufferPool of size byte buffers.
+func NewBufferPool(size int) *BufferPool {
+	bp := &BufferPool{size: size}
+	bp.pool.New = func() interface{} {
+		buf := make([]byte, bp.size)
+		return &buf
+	}
+	return bp
+}
+
+// Size returns the length of the buffers in the pool.
+func (bp *BufferPool) Size() int {
+	return bp.size
+}
+
+// Get returns a buffer of Size() bytes.
+func (bp *BufferPool) Get() []byte {
+	return *bp.pool.Get().(*[]byte)
+}
+
+// Put returns a buffer from Get to the pool. Buffers of any other size are
+// left for the garbage collector.
+func (bp *BufferPool) Put(buf []byte) {
+	if cap(buf) != bp.size {
+		return
+	}
+	buf = buf[:bp.size]
+	bp.pool.Put(&buf)
+}
+
+var copyBuffers atomic.Value
+
+func init() {
+	copyBuffers.Store(NewBufferPool(DefaultCopyBufferSize))
+}
+
+// CopyBuffers returns the pool Copy, CopyN and CopyQuorum take their buffers
+// from.
+func CopyBuffers() *BufferPool {
+	return copyBuffers.Load().(*BufferPool)
+}
+
+// SetCopyBufferSize changes the size of the buffers Copy, CopyN and
+// CopyQuorum use. Larger buffers mean fewer, bigger reads and writes per
+// object at the cost of memory per stream. Sizes below 4k are ignored.
+func SetCopyBufferSize(size int) {
+	if size < 4096 || size == CopyBuffers().Size() {
+		return
+	}
+	copyBuffers.Store(NewBufferPool(size))
+}
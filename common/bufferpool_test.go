@@ -0,0 +1,54 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferPool(t *testing.T) {
+	bp := NewBufferPool(8192)
+	buf := bp.Get()
+	require.Equal(t, 8192, len(buf))
+	bp.Put(buf[:10])
+	require.Equal(t, 8192, len(bp.Get()))
+	// buffers of the wrong size are dropped rather than handed out again
+	bp.Put(make([]byte, 4096))
+	require.Equal(t, 8192, len(bp.Get()))
+}
+
+func TestSetCopyBufferSize(t *testing.T) {
+	defer SetCopyBufferSize(DefaultCopyBufferSize)
+	SetCopyBufferSize(100)
+	require.Equal(t, DefaultCopyBufferSize, CopyBuffers().Size())
+	SetCopyBufferSize(16 * 1024)
+	require.Equal(t, 16*1024, CopyBuffers().Size())
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), 16*1024)
+	var a, b bytes.Buffer
+	n, err := Copy(bytes.NewReader(data), &a, &b)
+	require.Nil(t, err)
+	require.Equal(t, int64(len(data)), n)
+	require.Equal(t, data, a.Bytes())
+	require.Equal(t, data, b.Bytes())
+	_, err = CopyQuorumBuffered(bytes.NewReader(data), 2, 64*1024, 10*time.Millisecond, func(i int) {}, &a, &b)
+	require.Nil(t, err)
+	require.Equal(t, 2*len(data), a.Len())
+}
@@ -0,0 +1,140 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package common
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ChecksumHeaderPrefix prefixes the headers and metadata keys that digests
+// computed alongside an object's MD5 ETag are stored and returned under, as
+// in X-Object-Checksum-Sha256.
+const ChecksumHeaderPrefix = "X-Object-Checksum-"
+
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"Crc32":  func() hash.Hash { return crc32.NewIEEE() },
+	"Crc32c": func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) },
+	"Sha1":   sha1.New,
+	"Sha256": sha256.New,
+}
+
+// ParseChecksumAlgorithms parses a comma separated list of checksum
+// algorithms (crc32, crc32c, sha1, sha256) into their header suffixes.
+func ParseChecksumAlgorithms(list string) ([]string, error) {
+	var algorithms []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		algorithm := http.CanonicalHeaderKey(strings.ToLower(name))
+		if _, ok := checksumAlgorithms[algorithm]; !ok {
+			return nil, fmt.Errorf("Unknown checksum algorithm %q", name)
+		}
+		algorithms = append(algorithms, algorithm)
+	}
+	return algorithms, nil
+}
+
+// NewChecksums returns a hash for each of the given algorithms, along with
+// any the request asks to have verified by sending an X-Object-Checksum-*
+// header or trailer.
+func NewChecksums(algorithms []string, headers ...http.Header) map[string]hash.Hash {
+	checksums := map[string]hash.Hash{}
+	for _, algorithm := range algorithms {
+		checksums[algorithm] = checksumAlgorithms[algorithm]()
+	}
+	for _, header := range headers {
+		for key := range header {
+			if algorithm := strings.TrimPrefix(key, ChecksumHeaderPrefix); algorithm != key {
+				if newHash, ok := checksumAlgorithms[algorithm]; ok && checksums[algorithm] == nil {
+					checksums[algorithm] = newHash()
+				}
+			}
+		}
+	}
+	return checksums
+}
+
+// EncodeChecksum returns h's digest in the base64 form it's stored and
+// returned in.
+func EncodeChecksum(h hash.Hash) string {
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// ErrContentMD5Mismatch is the error a ContentMD5Reader fails with at the end
+// of a body that doesn't match its Content-MD5.
+var ErrContentMD5Mismatch = errors.New("Content-MD5 did not match the body")
+
+// ContentMD5Etag returns the hex ETag for a request's base64 Content-MD5.
+func ContentMD5Etag(contentMD5 string) (string, error) {
+	sum, err := base64.StdEncoding.DecodeString(contentMD5)
+	if err != nil || len(sum) != md5.Size {
+		return "", fmt.Errorf("Invalid Content-MD5 %q", contentMD5)
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// ContentMD5Reader checks a request body against its Content-MD5 as it's
+// read, so a mismatch can fail the request before the end of the body is
+// passed on.
+type ContentMD5Reader struct {
+	io.Reader
+	hash     hash.Hash
+	etag     string
+	mismatch bool
+}
+
+// NewContentMD5Reader returns a ContentMD5Reader for body, or an error if
+// contentMD5 isn't a valid Content-MD5.
+func NewContentMD5Reader(body io.Reader, contentMD5 string) (*ContentMD5Reader, error) {
+	etag, err := ContentMD5Etag(contentMD5)
+	if err != nil {
+		return nil, err
+	}
+	return &ContentMD5Reader{Reader: body, hash: md5.New(), etag: etag}, nil
+}
+
+func (c *ContentMD5Reader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(c.hash.Sum(nil)) != c.etag {
+		c.mismatch = true
+		return n, ErrContentMD5Mismatch
+	}
+	return n, err
+}
+
+// Etag returns the ETag the body is expected to have.
+func (c *ContentMD5Reader) Etag() string {
+	return c.etag
+}
+
+// Mismatch returns whether the body was read to the end and didn't match.
+func (c *ContentMD5Reader) Mismatch() bool {
+	return c.mismatch
+}
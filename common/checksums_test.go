@@ -0,0 +1,77 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package common
+
+import (
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChecksumAlgorithms(t *testing.T) {
+	algorithms, err := ParseChecksumAlgorithms("SHA256, crc32c,")
+	require.Nil(t, err)
+	require.Equal(t, []string{"Sha256", "Crc32c"}, algorithms)
+	algorithms, err = ParseChecksumAlgorithms("")
+	require.Nil(t, err)
+	require.Empty(t, algorithms)
+	_, err = ParseChecksumAlgorithms("sha256,md4")
+	require.NotNil(t, err)
+}
+
+func TestNewChecksums(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Object-Checksum-Crc32c", "MZiXzQ==")
+	header.Set("X-Object-Checksum-Md4", "bogus")
+	checksums := NewChecksums([]string{"Sha256"}, header)
+	var algorithms []string
+	for algorithm, h := range checksums {
+		algorithms = append(algorithms, algorithm)
+		h.Write([]byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ"))
+	}
+	sort.Strings(algorithms)
+	require.Equal(t, []string{"Crc32c", "Sha256"}, algorithms)
+	require.Equal(t, "MZiXzQ==", EncodeChecksum(checksums["Crc32c"]))
+	require.Equal(t, "1uxomN6H3axuWzYRcIp6ocLSmCkzScwabCmaHbcUnTg=", EncodeChecksum(checksums["Sha256"]))
+}
+
+func TestContentMD5Reader(t *testing.T) {
+	etag, err := ContentMD5Etag("XUFAKrxLKna5cZ2REBfFkg==")
+	require.Nil(t, err)
+	require.Equal(t, "5d41402abc4b2a76b9719d911017c592", etag)
+	_, err = ContentMD5Etag("XUFAKrxLKna5")
+	require.NotNil(t, err)
+	_, err = NewContentMD5Reader(strings.NewReader("hello"), "not base64!")
+	require.NotNil(t, err)
+
+	r, err := NewContentMD5Reader(strings.NewReader("hello"), "XUFAKrxLKna5cZ2REBfFkg==")
+	require.Nil(t, err)
+	require.Equal(t, "5d41402abc4b2a76b9719d911017c592", r.Etag())
+	data, err := ioutil.ReadAll(r)
+	require.Nil(t, err)
+	require.Equal(t, "hello", string(data))
+	require.False(t, r.Mismatch())
+
+	r, err = NewContentMD5Reader(strings.NewReader("hellO"), "XUFAKrxLKna5cZ2REBfFkg==")
+	require.Nil(t, err)
+	_, err = ioutil.ReadAll(r)
+	require.Equal(t, ErrContentMD5Mismatch, err)
+	require.True(t, r.Mismatch())
+}
@@ -250,6 +250,45 @@ func normalGetHashPrefixAndSuffix() (prefix string, suffix string, err error) {
 	return "", "", fmt.Errorf("No conf found; looked for %s", configLocations)
 }
 
+// GetBackendDefaults returns the scheme and path prefix that requests to
+// backend servers should use for ring devices that don't set their own, from
+// the [backend] section of hummingbird.conf.
+func GetBackendDefaults() (scheme string, pathPrefix string) {
+	for _, loc := range configLocations {
+		if conf, err := LoadConfig(loc); err == nil {
+			return conf.GetDefault("backend", "scheme", ""), conf.GetDefault("backend", "path_prefix", "")
+		}
+	}
+	return "", ""
+}
+
+// GetBackendSigning returns the [backend] settings for signed requests
+// between the cluster's services: the shared key requests are signed with,
+// whether storage servers refuse unsigned requests, and how far, in seconds,
+// a signature's time may be from theirs.
+func GetBackendSigning() (key string, required bool, maxSkew int64) {
+	for _, loc := range configLocations {
+		if conf, err := LoadConfig(loc); err == nil {
+			return conf.GetDefault("backend", "signing_key", ""), conf.GetBool("backend", "signing_required", false),
+				conf.GetInt("backend", "signing_max_skew", 60)
+		}
+	}
+	return "", false, 60
+}
+
+// GetRingDistribution returns the [ring-distribution] settings from
+// hummingbird.conf: the URL rings are fetched from, the key their manifest is
+// signed with, and how often, in seconds, to check for new ones.
+func GetRingDistribution() (source string, key string, interval int64) {
+	for _, loc := range configLocations {
+		if conf, err := LoadConfig(loc); err == nil {
+			return conf.GetDefault("ring-distribution", "source", ""), conf.GetDefault("ring-distribution", "key", ""),
+				conf.GetInt("ring-distribution", "interval", 60)
+		}
+	}
+	return "", "", 60
+}
+
 func ReadResellerOptions(conf Section, defaults map[string][]string) ([]string, map[string]map[string][]string) {
 	resellerPrefixOpt := conf.GetDefault("reseller_prefix", "AUTH")
 	s := []string{}
@@ -294,6 +333,21 @@ func ReadResellerOptions(conf Section, defaults map[string][]string) ([]string,
 	return resellerPrefix, associatedOptions
 }
 
+// ResellerPrefix returns which of the reseller prefixes, as from
+// ReadResellerOptions, the account belongs to. The empty prefix matches any
+// account, so it's only used if none of the others do.
+func ResellerPrefix(prefixes []string, account string) (string, bool) {
+	empty := false
+	for _, prefix := range prefixes {
+		if prefix == "" {
+			empty = true
+		} else if strings.HasPrefix(account, prefix) {
+			return prefix, true
+		}
+	}
+	return "", empty
+}
+
 func ReadPrefixedOptions(conf Section, prefixName string, defaults map[string][]string) map[string][]string {
 	params := make(map[string][]string)
 	for optionName := range defaults {
@@ -281,3 +281,14 @@ func TestReadResellerOptions(t *testing.T) {
 		}
 	}
 }
+
+func TestResellerPrefix(t *testing.T) {
+	prefixes := []string{"AUTH_", "", "SERVICE_"}
+	for account, expected := range map[string]string{"AUTH_test": "AUTH_", "SERVICE_test": "SERVICE_", "test": ""} {
+		prefix, ok := ResellerPrefix(prefixes, account)
+		require.True(t, ok)
+		require.Equal(t, expected, prefix)
+	}
+	_, ok := ResellerPrefix([]string{"AUTH_", "SERVICE_"}, "test")
+	require.False(t, ok)
+}
@@ -60,6 +60,60 @@ func (p Policy) GetDbSubDirs() (int, error) {
 	return subdirs, nil
 }
 
+// PolicyAPIs are the API features a policy's allowed_apis may list.
+var PolicyAPIs = []string{"swift", "s3", "slo", "dlo"}
+
+// PolicyConstraints limit what may be stored in a policy. Zero values mean
+// no limit, and no AllowedAPIs means all of them.
+type PolicyConstraints struct {
+	MinSegmentSize int64
+	MaxObjectSize  int64
+	AllowedAPIs    []string
+}
+
+// AllowsAPI returns whether objects in the policy may be used through api,
+// one of PolicyAPIs.
+func (c PolicyConstraints) AllowsAPI(api string) bool {
+	if len(c.AllowedAPIs) == 0 {
+		return true
+	}
+	for _, a := range c.AllowedAPIs {
+		if a == api {
+			return true
+		}
+	}
+	return false
+}
+
+// GetConstraints parses the policy's min_segment_size, max_object_size and
+// allowed_apis settings.
+func (p Policy) GetConstraints() (PolicyConstraints, error) {
+	var c PolicyConstraints
+	for key, dst := range map[string]*int64{"min_segment_size": &c.MinSegmentSize, "max_object_size": &c.MaxObjectSize} {
+		if v := p.Config[key]; v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return c, fmt.Errorf("Could not parse %s value %q", key, v)
+			}
+			*dst = n
+		}
+	}
+	for _, api := range strings.Split(p.Config["allowed_apis"], ",") {
+		if api = strings.ToLower(strings.TrimSpace(api)); api == "" {
+			continue
+		}
+		known := false
+		for _, a := range PolicyAPIs {
+			known = known || a == api
+		}
+		if !known {
+			return c, fmt.Errorf("Unknown API %q in allowed_apis", api)
+		}
+		c.AllowedAPIs = append(c.AllowedAPIs, api)
+	}
+	return c, nil
+}
+
 type PolicyList map[int]*Policy
 
 func (p PolicyList) Default() int {
@@ -99,6 +153,17 @@ func (p PolicyList) GetPolicyInfo() []map[string]interface{} {
 			pol["default"] = v.Default
 		}
 		pol["aliases"] = strings.Join(v.Aliases, ", ")
+		if c, err := v.GetConstraints(); err == nil {
+			if c.MinSegmentSize > 0 {
+				pol["min_segment_size"] = c.MinSegmentSize
+			}
+			if c.MaxObjectSize > 0 {
+				pol["max_object_size"] = c.MaxObjectSize
+			}
+			if len(c.AllowedAPIs) > 0 {
+				pol["allowed_apis"] = c.AllowedAPIs
+			}
+		}
 		policyInfo = append(policyInfo, pol)
 	}
 	return policyInfo
@@ -147,6 +212,9 @@ func GetPolicies() (PolicyList, error) {
 		if policy.Default {
 			defaultFound = true
 		}
+		if _, err := policy.GetConstraints(); err != nil {
+			return nil, fmt.Errorf("storage-policy:%d: %v", policy.Index, err)
+		}
 	}
 	if !defaultFound {
 		policies[0].Default = true
@@ -91,3 +91,21 @@ func TestNoPolicies(t *testing.T) {
 	require.Equal(t, policyList[0].Default, true)
 	require.Equal(t, policyList[0].Deprecated, false)
 }
+
+func TestPolicyConstraints(t *testing.T) {
+	c, err := Policy{}.GetConstraints()
+	require.Nil(t, err)
+	require.True(t, c.AllowsAPI("dlo"))
+	c, err = Policy{Config: map[string]string{"min_segment_size": "1048576", "max_object_size": "1073741824", "allowed_apis": "Swift, slo"}}.GetConstraints()
+	require.Nil(t, err)
+	require.Equal(t, PolicyConstraints{MinSegmentSize: 1048576, MaxObjectSize: 1073741824, AllowedAPIs: []string{"swift", "slo"}}, c)
+	require.True(t, c.AllowsAPI("slo"))
+	require.False(t, c.AllowsAPI("s3"))
+	_, err = Policy{Config: map[string]string{"max_object_size": "big"}}.GetConstraints()
+	require.NotNil(t, err)
+	_, err = Policy{Config: map[string]string{"allowed_apis": "swift, ftp"}}.GetConstraints()
+	require.NotNil(t, err)
+
+	policyInfo := PolicyList{0: {Name: "ec", Config: map[string]string{"min_segment_size": "1048576", "allowed_apis": "swift"}}}.GetPolicyInfo()
+	require.Equal(t, []map[string]interface{}{{"name": "ec", "aliases": "", "min_segment_size": int64(1048576), "allowed_apis": []string{"swift"}}}, policyInfo)
+}
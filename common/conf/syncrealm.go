@@ -15,7 +15,14 @@
 
 package conf
 
-import "strings"
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
 
 type SyncRealm struct {
 	Name     string
@@ -50,6 +57,45 @@ func (l SyncRealmList) ValidateSyncTo(syncHeader string) bool {
 	return true
 }
 
+// SyncSig returns the signature for an X-Container-Sync-Auth header; it's
+// keyed with the realm key and covers the destination's container sync key,
+// the same as Swift's.
+func SyncSig(method, path, timestamp, nonce, realmKey, userKey string) string {
+	mac := hmac.New(sha1.New, []byte(realmKey))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", method, path, timestamp, nonce, userKey)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SyncAuth returns an X-Container-Sync-Auth header value for a request to
+// another cluster in realm, signed with the realm's primary key.
+func (l SyncRealmList) SyncAuth(realm, method, path, timestamp, userKey string) (string, error) {
+	if l[realm].Key1 == "" {
+		return "", fmt.Errorf("No key for realm %q", realm)
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	nonce := hex.EncodeToString(b)
+	return fmt.Sprintf("%s %s %s", realm, nonce, SyncSig(method, path, timestamp, nonce, l[realm].Key1, userKey)), nil
+}
+
+// ValidSyncAuth checks an X-Container-Sync-Auth header value against either
+// of its realm's keys, so keys can be rotated one at a time.
+func (l SyncRealmList) ValidSyncAuth(auth, method, path, timestamp, userKey string) bool {
+	parts := strings.Fields(auth)
+	if len(parts) != 3 || userKey == "" {
+		return false
+	}
+	realm, nonce, sig := l[parts[0]], parts[1], parts[2]
+	for _, key := range []string{realm.Key1, realm.Key2} {
+		if key != "" && hmac.Equal([]byte(sig), []byte(SyncSig(method, path, timestamp, nonce, key, userKey))) {
+			return true
+		}
+	}
+	return false
+}
+
 var syncRealmConfigLocations = []string{"/etc/hummingbird/container-sync-realms.conf", "/etc/swift/container-sync-realms.conf"}
 
 func GetSyncRealms() (SyncRealmList, error) {
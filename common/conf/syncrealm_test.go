@@ -0,0 +1,53 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package conf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncSig(t *testing.T) {
+	// Matches Swift's signature for the same inputs.
+	require.Equal(t, "5a6eb486eb7b44ae1b1f014187a94529c3f9c8f9",
+		SyncSig("GET", "/some/path", "1387212345.67890", "my_nonce", "realm_key", "user_key"))
+}
+
+func TestSyncAuth(t *testing.T) {
+	realms := SyncRealmList{
+		"US": {Name: "US", Key1: "key1", Key2: "key2", Clusters: map[string]string{"dfw1": "http://dfw1/v1/"}},
+	}
+	auth, err := realms.SyncAuth("US", "PUT", "/v1/AUTH_a/c/o", "1387212345.67890", "secret")
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(auth, "US "))
+	require.True(t, realms.ValidSyncAuth(auth, "PUT", "/v1/AUTH_a/c/o", "1387212345.67890", "secret"))
+	require.False(t, realms.ValidSyncAuth(auth, "DELETE", "/v1/AUTH_a/c/o", "1387212345.67890", "secret"))
+	require.False(t, realms.ValidSyncAuth(auth, "PUT", "/v1/AUTH_a/c/o2", "1387212345.67890", "secret"))
+	require.False(t, realms.ValidSyncAuth(auth, "PUT", "/v1/AUTH_a/c/o", "1387212345.67891", "secret"))
+	require.False(t, realms.ValidSyncAuth(auth, "PUT", "/v1/AUTH_a/c/o", "1387212345.67890", "other"))
+	require.False(t, realms.ValidSyncAuth(auth, "PUT", "/v1/AUTH_a/c/o", "1387212345.67890", ""))
+
+	// Signatures made with the secondary key are accepted too.
+	sig := SyncSig("PUT", "/v1/AUTH_a/c/o", "1387212345.67890", "nonce", "key2", "secret")
+	require.True(t, realms.ValidSyncAuth("US nonce "+sig, "PUT", "/v1/AUTH_a/c/o", "1387212345.67890", "secret"))
+	require.False(t, realms.ValidSyncAuth("EU nonce "+sig, "PUT", "/v1/AUTH_a/c/o", "1387212345.67890", "secret"))
+	require.False(t, realms.ValidSyncAuth("US "+sig, "PUT", "/v1/AUTH_a/c/o", "1387212345.67890", "secret"))
+
+	_, err = realms.SyncAuth("EU", "PUT", "/v1/AUTH_a/c/o", "1387212345.67890", "secret")
+	require.NotNil(t, err)
+}
@@ -146,6 +146,9 @@ func CheckObjPost(req *http.Request, objectName string) (int, string) {
 	if status, msg := handleObjDeleteHeaders(req); status != http.StatusOK {
 		return status, msg
 	}
+	if strings.Contains(req.Header.Get("Content-Type"), "\x00") {
+		return http.StatusBadRequest, "Invalid Content-Type"
+	}
 	return CheckMetadata(req, "Object")
 }
 
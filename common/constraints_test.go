@@ -45,6 +45,17 @@ func TestBadTransferEncoding(t *testing.T) {
 	require.Equal(t, status, http.StatusLengthRequired)
 }
 
+func TestPostContentType(t *testing.T) {
+	req, err := http.NewRequest("POST", "/v1/a/c/o", nil)
+	require.Nil(t, err)
+	req.Header.Set("Content-Type", "text/plain")
+	status, _ := CheckObjPost(req, "o")
+	require.Equal(t, http.StatusOK, status)
+	req.Header.Set("Content-Type", "text/plain\x00")
+	status, _ = CheckObjPost(req, "o")
+	require.Equal(t, http.StatusBadRequest, status)
+}
+
 func TestLengthOnCopyFrom(t *testing.T) {
 	req, err := http.NewRequest("PUT", "/v1/a/c/o", nil)
 	require.Nil(t, err)
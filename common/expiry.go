@@ -0,0 +1,82 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenExpiredHeader asks the proxy for an object whose X-Delete-At has
+// passed but which hasn't been removed from disk yet.
+const OpenExpiredHeader = "X-Open-Expired"
+
+// BackendOpenExpiredHeader tells object servers to serve expired objects
+// they still have; proxies only set it for clients allowed to open them.
+const BackendOpenExpiredHeader = "X-Backend-Open-Expired"
+
+// DeleteAtPassed reports whether an object with the given X-Delete-At has
+// expired.  Objects without one never do.
+func DeleteAtPassed(deleteAt string) bool {
+	if deleteAt == "" {
+		return false
+	}
+	deleteTime, err := ParseDate(deleteAt)
+	return err == nil && deleteTime.Before(time.Now())
+}
+
+// ExpiringObjectsAccount holds the queue of objects given an X-Delete-At,
+// with a container for each hour of X-Delete-Ats. Proxies add to the queue as
+// objects are written and the expirer in andrewd works through it, deleting
+// each object once its time comes. Entries aren't removed when an object's
+// X-Delete-At changes or it's deleted early; the expirer's conditional
+// DELETE finds them stale and drops them.
+const ExpiringObjectsAccount = ".expiring_objects"
+
+// expiringContainerSpan is how many seconds of X-Delete-Ats share a queue
+// container.
+const expiringContainerSpan = 3600
+
+// ExpiringQueueContainer returns the queue container for an X-Delete-At.
+func ExpiringQueueContainer(deleteAt int64) string {
+	return strconv.FormatInt(deleteAt/expiringContainerSpan*expiringContainerSpan, 10)
+}
+
+// ExpiringQueueEntry names the queue entry for an object. Entries start with
+// the zero padded X-Delete-At, so a queue container lists them in the order
+// they're due.
+func ExpiringQueueEntry(deleteAt int64, account, container, obj string) string {
+	return fmt.Sprintf("%010d-%s/%s/%s", deleteAt, account, container, obj)
+}
+
+// ParseExpiringQueueEntry splits a queue entry's name back into the
+// X-Delete-At and the object's path.
+func ParseExpiringQueueEntry(name string) (deleteAt int64, account, container, obj string, err error) {
+	parts := strings.SplitN(name, "-", 2)
+	if len(parts) != 2 {
+		return 0, "", "", "", fmt.Errorf("Invalid expiring object entry %q", name)
+	}
+	if deleteAt, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return 0, "", "", "", fmt.Errorf("Invalid expiring object entry %q", name)
+	}
+	path := strings.SplitN(parts[1], "/", 3)
+	if len(path) != 3 || path[0] == "" || path[1] == "" || path[2] == "" {
+		return 0, "", "", "", fmt.Errorf("Invalid expiring object entry %q", name)
+	}
+	return deleteAt, path[0], path[1], path[2], nil
+}
@@ -0,0 +1,44 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpiringQueueContainer(t *testing.T) {
+	require.Equal(t, "1499997600", ExpiringQueueContainer(1500000000))
+	require.Equal(t, "1500001200", ExpiringQueueContainer(1500003599))
+	require.Equal(t, "1500004800", ExpiringQueueContainer(1500004800))
+}
+
+func TestExpiringQueueEntry(t *testing.T) {
+	name := ExpiringQueueEntry(1500000000, "AUTH_a-b", "c", "some/obj-1")
+	require.Equal(t, "1500000000-AUTH_a-b/c/some/obj-1", name)
+	require.True(t, ExpiringQueueEntry(999999999, "a", "c", "o") < ExpiringQueueEntry(1000000000, "a", "c", "o"))
+	deleteAt, account, container, obj, err := ParseExpiringQueueEntry(name)
+	require.Nil(t, err)
+	require.Equal(t, int64(1500000000), deleteAt)
+	require.Equal(t, "AUTH_a-b", account)
+	require.Equal(t, "c", container)
+	require.Equal(t, "some/obj-1", obj)
+	for _, bad := range []string{"", "1500000000", "x-a/c/o", "1500000000-a/c", "1500000000-/c/o", "1500000000-a/c/"} {
+		_, _, _, _, err = ParseExpiringQueueEntry(bad)
+		require.NotNil(t, err, bad)
+	}
+}
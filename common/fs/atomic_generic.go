@@ -76,7 +76,7 @@ func (o *TempFile) Finalize(dst string) error {
 }
 
 // Preallocate pre-allocates space for the file.
-func (o *TempFile) Preallocate(size int64, reserve int64) error {
+func (o *TempFile) Preallocate(size int64, reserve Reserve) error {
 	// TODO: this could be done for most non-linux operating systems, but it hasn't been important.
 	return nil
 }
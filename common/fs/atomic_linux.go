@@ -18,7 +18,6 @@
 package fs
 
 import (
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -150,13 +149,13 @@ func (o *TempFile) Finalize(dst string) error {
 }
 
 // Preallocate pre-allocates space for the file.
-func (o *TempFile) Preallocate(size int64, reserve int64) error {
+func (o *TempFile) Preallocate(size int64, reserve Reserve) error {
 	var st syscall.Statfs_t
-	if reserve > 0 {
+	if !reserve.IsZero() {
 		if err := syscall.Fstatfs(int(o.Fd()), &st); err == nil {
 			freeSpace := int64(st.Frsize) * int64(st.Bavail)
-			if freeSpace-size < reserve {
-				return errors.New("Not enough reserve space on disk.")
+			if !reserve.allows(size, freeSpace, int64(st.Frsize)*int64(st.Blocks)) {
+				return ErrNotEnoughReserve
 			}
 		}
 	}
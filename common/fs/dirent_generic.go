@@ -0,0 +1,44 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+// +build !linux
+
+package fs
+
+import (
+	"os"
+	"sort"
+)
+
+// ReadDirEntries lists the entries in path, sorted by name.
+func ReadDirEntries(path string) ([]DirEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = DirEntry{Name: info.Name(), IsDir: info.IsDir()}
+	}
+	if len(entries) > 1 {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	}
+	return entries, nil
+}
@@ -0,0 +1,88 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+// +build linux
+
+package fs
+
+import (
+	"bytes"
+	"os"
+	"sort"
+	"syscall"
+	"unsafe"
+)
+
+// Values of a linux_dirent64's d_type.
+const (
+	dtUnknown = 0
+	dtDir     = 4
+)
+
+// direntBufSize is how much of a directory each getdents call reads.
+const direntBufSize = 64 * 1024
+
+// ReadDirEntries lists the entries in path, sorted by name. The entries' types
+// come from the directory itself with batched getdents calls, rather than a
+// stat of each entry, unless the filesystem doesn't report them.
+func ReadDirEntries(path string) ([]DirEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fd := int(f.Fd())
+	buf := make([]byte, direntBufSize)
+	var entries []DirEntry
+	for {
+		n, err := syscall.Getdents(fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, os.NewSyscallError("getdents", err)
+		}
+		if n <= 0 {
+			break
+		}
+		for off := 0; off < n; {
+			dirent := (*syscall.Dirent)(unsafe.Pointer(&buf[off]))
+			off += int(dirent.Reclen)
+			if dirent.Ino == 0 {
+				continue
+			}
+			nameBytes := (*[len(dirent.Name)]byte)(unsafe.Pointer(&dirent.Name[0]))
+			nameLen := bytes.IndexByte(nameBytes[:], 0)
+			if nameLen < 0 {
+				nameLen = len(nameBytes)
+			}
+			name := string(nameBytes[:nameLen])
+			if name == "." || name == ".." {
+				continue
+			}
+			entry := DirEntry{Name: name, IsDir: dirent.Type == dtDir}
+			if dirent.Type == dtUnknown {
+				if fi, err := os.Lstat(path + "/" + name); err == nil {
+					entry.IsDir = fi.IsDir()
+				}
+			}
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) > 1 {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	}
+	return entries, nil
+}
@@ -0,0 +1,28 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package fs
+
+// Advice describes how a file's data is about to be read, for Fadvise.
+type Advice int
+
+const (
+	// AdviceNormal restores the kernel's default readahead.
+	AdviceNormal Advice = iota
+	// AdviceSequential reads ahead further than usual.
+	AdviceSequential
+	// AdviceRandom turns readahead off.
+	AdviceRandom
+)
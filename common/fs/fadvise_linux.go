@@ -0,0 +1,45 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+// +build linux
+
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+/*
+#define _FILE_OFFSET_BITS 64
+#include <fcntl.h>
+*/
+import "C"
+
+var fadviseAdvice = map[Advice]C.int{
+	AdviceNormal:     C.POSIX_FADV_NORMAL,
+	AdviceSequential: C.POSIX_FADV_SEQUENTIAL,
+	AdviceRandom:     C.POSIX_FADV_RANDOM,
+}
+
+// Fadvise tells the kernel how the length bytes of f from offset are about
+// to be read. Linux applies sequential and random advice to the whole open
+// file, not just the range.
+func Fadvise(f *os.File, offset, length int64, advice Advice) error {
+	if rv := C.posix_fadvise(C.int(f.Fd()), C.off_t(offset), C.off_t(length), fadviseAdvice[advice]); rv != 0 {
+		return syscall.Errno(rv)
+	}
+	return nil
+}
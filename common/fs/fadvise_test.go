@@ -13,37 +13,25 @@
 //  See the License for the specific language governing permissions and
 //  limitations under the License.
 
-package common
+package fs
 
 import (
-	"sync/atomic"
-	"unsafe"
-)
-
-type FreePool []*unsafe.Pointer
+	"io/ioutil"
+	"os"
+	"testing"
 
-func (a FreePool) Get() interface{} {
-	for _, p := range a {
-		if v := atomic.SwapPointer(p, nil); v != nil {
-			return *(*interface{})(v)
-		}
-	}
-	return nil
-}
+	"github.com/stretchr/testify/require"
+)
 
-func (a FreePool) Put(v interface{}) {
-	vp := unsafe.Pointer(&v)
-	for _, p := range a {
-		if atomic.CompareAndSwapPointer(p, nil, vp) {
-			return
-		}
-	}
-}
+func TestFadvise(t *testing.T) {
+	fp, err := ioutil.TempFile("", "")
+	require.Nil(t, err)
+	defer fp.Close()
+	defer os.RemoveAll(fp.Name())
+	_, err = fp.Write(make([]byte, 65536))
+	require.Nil(t, err)
 
-func NewFreePool(size int) FreePool {
-	a := make(FreePool, size)
-	for i := range a {
-		a[i] = new(unsafe.Pointer)
-	}
-	return a
+	require.Nil(t, Fadvise(fp, 0, 65536, AdviceSequential))
+	require.Nil(t, Fadvise(fp, 4096, 4096, AdviceRandom))
+	require.Nil(t, Fadvise(fp, 0, 0, AdviceNormal))
 }
@@ -0,0 +1,36 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+// +build !linux
+
+package fs
+
+import "os"
+
+// Without syncfs, files are only gathered together; each is still synced on
+// its own.
+func fileDevice(f *os.File) (uint64, error) {
+	return 0, nil
+}
+
+func syncFiles(files []*TempFile) error {
+	var firstErr error
+	for _, f := range files {
+		if err := f.File.Sync(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,46 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+// +build linux
+
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+/*
+#define _GNU_SOURCE
+#include <unistd.h>
+*/
+import "C"
+
+func fileDevice(f *os.File) (uint64, error) {
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(f.Fd()), &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Dev), nil
+}
+
+// syncFiles flushes the filesystem the files are all on, which covers them
+// all with one call.
+func syncFiles(files []*TempFile) error {
+	if rv, err := C.syncfs(C.int(files[0].Fd())); rv != 0 {
+		return err
+	}
+	return nil
+}
@@ -34,8 +34,8 @@ type AtomicFileWriter interface {
 	Save(string) error
 	// Abandon removes any resources associated with this file.
 	Abandon() error
-	// Preallocate pre-allocates space on disk, given the expected file size and disk reserve size.
-	Preallocate(int64, int64) error
+	// Preallocate pre-allocates space on disk, given the expected file size and disk reserve.
+	Preallocate(int64, Reserve) error
 	// syncs file to disk (1st half of Save)
 	Sync() error
 	// links synced file to correct place in filesystem (2nd half of Save)
@@ -115,6 +115,13 @@ func ReadDirNames(path string) ([]string, error) {
 	return list, nil
 }
 
+// DirEntry is a name listed by ReadDirEntries and whether it's a directory.
+// Symlinks aren't followed, so a link to a directory isn't one.
+type DirEntry struct {
+	Name  string
+	IsDir bool
+}
+
 func Exists(file string) bool {
 	if _, err := os.Stat(file); os.IsNotExist(err) {
 		return false
@@ -56,6 +56,24 @@ func TestReadDirNames(t *testing.T) {
 	assert.Equal(t, fileNames, []string{"X", "Y", "Z"})
 }
 
+func TestReadDirEntries(t *testing.T) {
+	tempDir, _ := ioutil.TempDir("", "RDE")
+	defer os.RemoveAll(tempDir)
+	ioutil.WriteFile(tempDir+"/Z", []byte{}, 0666)
+	os.Mkdir(tempDir+"/X", 0777)
+	os.Symlink(tempDir+"/X", tempDir+"/Y")
+	entries, err := ReadDirEntries(tempDir)
+	assert.Nil(t, err)
+	assert.Equal(t, []DirEntry{{"X", true}, {"Y", false}, {"Z", false}}, entries)
+	entries, err = ReadDirEntries(tempDir + "/X")
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+	_, err = ReadDirEntries(tempDir + "/Z")
+	assert.True(t, IsNotDir(err))
+	_, err = ReadDirEntries(tempDir + "/nope")
+	assert.True(t, os.IsNotExist(err))
+}
+
 func TestLockPath(t *testing.T) {
 	tempDir, err := ioutil.TempDir("", "")
 	defer os.RemoveAll(tempDir)
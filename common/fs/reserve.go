@@ -0,0 +1,80 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNotEnoughReserve is returned by Preallocate when a write would leave
+// less free space on the device than its Reserve.
+var ErrNotEnoughReserve = errors.New("Not enough reserve space on disk.")
+
+// Reserve is the space to keep free on a device, either as a number of bytes
+// or as a percentage of the device's size.
+type Reserve struct {
+	Bytes   int64
+	Percent float64
+}
+
+// ParseReserve parses a fallocate_reserve setting, which like Swift is either
+// a byte count or a percentage such as "1%".
+func ParseReserve(value string) (Reserve, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Reserve{}, nil
+	}
+	if strings.HasSuffix(value, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, "%")), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return Reserve{}, fmt.Errorf("invalid reserve percentage %q", value)
+		}
+		return Reserve{Percent: percent}, nil
+	}
+	bytes, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || bytes < 0 {
+		return Reserve{}, fmt.Errorf("invalid reserve %q", value)
+	}
+	return Reserve{Bytes: bytes}, nil
+}
+
+// IsZero is true when no space is reserved.
+func (r Reserve) IsZero() bool {
+	return r.Bytes <= 0 && r.Percent <= 0
+}
+
+// allows reports whether writing size bytes to a device with free of total
+// bytes available would still leave the reserve untouched.
+func (r Reserve) allows(size, free, total int64) bool {
+	left := free - size
+	if r.Bytes > 0 && left < r.Bytes {
+		return false
+	}
+	if r.Percent > 0 && float64(left) < float64(total)*r.Percent/100 {
+		return false
+	}
+	return true
+}
+
+func (r Reserve) String() string {
+	if r.Percent > 0 {
+		return strconv.FormatFloat(r.Percent, 'f', -1, 64) + "%"
+	}
+	return strconv.FormatInt(r.Bytes, 10)
+}
@@ -0,0 +1,47 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package fs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReserve(t *testing.T) {
+	r, err := ParseReserve("")
+	require.Nil(t, err)
+	require.True(t, r.IsZero())
+	r, err = ParseReserve("1048576")
+	require.Nil(t, err)
+	require.Equal(t, Reserve{Bytes: 1048576}, r)
+	r, err = ParseReserve(" 2.5% ")
+	require.Nil(t, err)
+	require.Equal(t, Reserve{Percent: 2.5}, r)
+	require.Equal(t, "2.5%", r.String())
+	for _, bad := range []string{"-1", "lots", "101%", "x%"} {
+		_, err = ParseReserve(bad)
+		require.NotNil(t, err, bad)
+	}
+}
+
+func TestReserveAllows(t *testing.T) {
+	require.True(t, Reserve{}.allows(100, 100, 1000))
+	require.True(t, Reserve{Bytes: 50}.allows(50, 100, 1000))
+	require.False(t, Reserve{Bytes: 50}.allows(51, 100, 1000))
+	require.True(t, Reserve{Percent: 5}.allows(50, 100, 1000))
+	require.False(t, Reserve{Percent: 5}.allows(51, 100, 1000))
+}
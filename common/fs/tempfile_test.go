@@ -16,10 +16,13 @@
 package fs
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -110,3 +113,34 @@ func TestTempFileReplaceSyncFinalize(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, []byte("some crap"), data)
 }
+
+func TestGroupSyncerSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	g := NewGroupSyncer(50 * time.Millisecond)
+	start := time.Now()
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			f, err := NewAtomicFileWriter(dir, dir)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			f.Write([]byte("some crap"))
+			errs[i] = g.Save(f, filepath.Join(dir, fmt.Sprintf("file%d", i)))
+		}(i)
+	}
+	wg.Wait()
+	// The saves were synced together, not one window after another.
+	require.True(t, time.Since(start) < 200*time.Millisecond)
+	for i, err := range errs {
+		require.Nil(t, err)
+		require.True(t, Exists(filepath.Join(dir, fmt.Sprintf("file%d", i))))
+	}
+	require.Empty(t, g.groups)
+}
@@ -0,0 +1,69 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package common
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Metadata is stored as UTF-8, but not every client can put that in a header,
+// so values may also arrive as RFC 2047 encoded-words (=?UTF-8?B?...?=, which
+// is what S3 clients use) or in the RFC 2231 form UTF-8''percent%20encoded.
+
+var metaWordDecoder = &mime.WordDecoder{}
+
+var rfc2231Value = regexp.MustCompile(`^(?i)(utf-8|us-ascii)'[a-z0-9-]*'(.*)$`)
+
+// DecodeMetaValue returns the UTF-8 form of a metadata value, which is the
+// value itself unless it was encoded as RFC 2047 encoded-words or an RFC 2231
+// extended value. Values that don't decode cleanly are returned unchanged.
+func DecodeMetaValue(v string) string {
+	if strings.Contains(v, "=?") && strings.Contains(v, "?=") {
+		if d, err := metaWordDecoder.DecodeHeader(v); err == nil {
+			return d
+		}
+	} else if m := rfc2231Value.FindStringSubmatch(v); m != nil {
+		if d, err := url.PathUnescape(m[2]); err == nil {
+			return d
+		}
+	}
+	return v
+}
+
+// EncodeMetaValue returns v as RFC 2047 encoded-words if it has anything
+// besides printable ASCII and tabs, and v itself otherwise.
+func EncodeMetaValue(v string) string {
+	return mime.BEncoding.Encode("UTF-8", v)
+}
+
+// DecodeMetaHeaders decodes, in place, the values of any headers that start
+// with one of the prefixes, such as X-Object-Meta-.
+func DecodeMetaHeaders(header http.Header, prefixes ...string) {
+	for k, vs := range header {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(k, prefix) {
+				for i := range vs {
+					vs[i] = DecodeMetaValue(vs[i])
+				}
+				break
+			}
+		}
+	}
+}
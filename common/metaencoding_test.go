@@ -0,0 +1,65 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package common
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeMetaValue(t *testing.T) {
+	for v, expected := range map[string]string{
+		"plain":                         "plain",
+		"héllo":                         "héllo",
+		"=?UTF-8?B?aMOpbGxv?=":          "héllo",
+		"=?utf-8?q?h=C3=A9llo?= world":  "héllo world",
+		"=?ISO-8859-1?Q?h=E9llo?=":      "héllo",
+		"UTF-8''h%C3%A9llo%20there":     "héllo there",
+		"utf-8'en'caf%C3%A9":            "café",
+		"=?UTF-8?B?not base64!?=":       "=?UTF-8?B?not base64!?=",
+		"UTF-8''bad%zz":                 "UTF-8''bad%zz",
+		"it's just an apostrophe'd one": "it's just an apostrophe'd one",
+	} {
+		require.Equal(t, expected, DecodeMetaValue(v), v)
+	}
+}
+
+func TestEncodeMetaValue(t *testing.T) {
+	require.Equal(t, "plain\tvalue", EncodeMetaValue("plain\tvalue"))
+	for _, v := range []string{"héllo", "日本語のメタデータ", "line\nbreak", strings.Repeat("ü", 100)} {
+		encoded := EncodeMetaValue(v)
+		require.NotEqual(t, v, encoded)
+		for i := 0; i < len(encoded); i++ {
+			require.True(t, encoded[i] >= ' ' && encoded[i] <= '~', encoded)
+		}
+		require.Equal(t, v, DecodeMetaValue(encoded))
+	}
+}
+
+func TestDecodeMetaHeaders(t *testing.T) {
+	header := http.Header{
+		"X-Object-Meta-Name": {"=?UTF-8?B?aMOpbGxv?="},
+		"X-Object-Meta-Raw":  {"héllo"},
+		"Content-Type":       {"=?UTF-8?B?aMOpbGxv?="},
+	}
+	DecodeMetaHeaders(header, "X-Account-Meta-", "X-Object-Meta-")
+	require.Equal(t, "héllo", header.Get("X-Object-Meta-Name"))
+	require.Equal(t, "héllo", header.Get("X-Object-Meta-Raw"))
+	require.Equal(t, "=?UTF-8?B?aMOpbGxv?=", header.Get("Content-Type"))
+}
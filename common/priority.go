@@ -0,0 +1,54 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package common
+
+import (
+	"net/http"
+	"strings"
+)
+
+// PriorityHeader carries a backend request's priority class, so servers can
+// keep background work from crowding out requests users are waiting on.
+const PriorityHeader = "X-Backend-Priority"
+
+const (
+	// PriorityInteractive is for requests users are waiting on; requests
+	// without a priority are interactive.
+	PriorityInteractive = "interactive"
+	// PriorityReplication is for moving data between devices, like
+	// replication and rebuilds.
+	PriorityReplication = "replication"
+	// PriorityBackground is for other daemons' work, like reconciling and
+	// auditing.
+	PriorityBackground = "background"
+)
+
+// Priorities lists the priority classes, highest first.
+var Priorities = []string{PriorityInteractive, PriorityReplication, PriorityBackground}
+
+// ValidPriority reports whether p names a priority class.
+func ValidPriority(p string) bool {
+	return StringInSlice(p, Priorities)
+}
+
+// RequestPriority returns the priority class given in h, or
+// PriorityInteractive if it has none or an unknown one.
+func RequestPriority(h http.Header) string {
+	if p := strings.ToLower(h.Get(PriorityHeader)); ValidPriority(p) {
+		return p
+	}
+	return PriorityInteractive
+}
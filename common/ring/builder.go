@@ -149,7 +149,14 @@ type RingBuilderDevice struct {
 	ReplicationIp   string  `pickle:"replication_ip"`
 	Parts           int64   `pickle:"parts"`
 	Id              int64   `pickle:"id"`
-	tiers           [4]string
+	// TargetWeight and RampSteps describe a scheduled weight change: each
+	// rebalance moves Weight an equal share of the way to TargetWeight until
+	// RampSteps reaches zero.
+	TargetWeight float64 `pickle:"target_weight"`
+	RampSteps    int64   `pickle:"ramp_steps"`
+	// Tags are copied to the ring's Device.Tags.
+	Tags  map[string]string `pickle:"tags"`
+	tiers [4]string
 }
 
 type RingBuilder struct {
@@ -369,12 +376,49 @@ func (b *RingBuilder) SetDevWeight(devId int64, weight float64) error {
 		}
 	}
 	b.Devs[devId].Weight = weight
+	b.Devs[devId].RampSteps = 0
 	b.DevsChanged = true
 	b.Version += 1
 
 	return nil
 }
 
+// SetDevWeightRamp schedules the weight of a device to reach targetWeight over
+// the next steps rebalances, rather than all at once, so the replication
+// caused by adding (or draining) a large device is spread out.
+func (b *RingBuilder) SetDevWeightRamp(devId int64, targetWeight float64, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("Ramp steps must be at least 1 (was %d)", steps)
+	}
+	for _, dev := range b.removedDevs {
+		if devId == dev.Id {
+			return fmt.Errorf("Can not set weight of devId %d because it is marked for removal", devId)
+		}
+	}
+	b.Devs[devId].TargetWeight = targetWeight
+	b.Devs[devId].RampSteps = int64(steps)
+	b.DevsChanged = true
+	b.Version += 1
+
+	return nil
+}
+
+// stepWeightRamps moves every ramping device one step closer to its target
+// weight.
+func (b *RingBuilder) stepWeightRamps() {
+	for next, dev := devIterator(b.Devs); dev != nil; dev = next() {
+		if dev.RampSteps <= 0 {
+			continue
+		}
+		dev.Weight += (dev.TargetWeight - dev.Weight) / float64(dev.RampSteps)
+		dev.RampSteps--
+		if dev.RampSteps == 0 {
+			dev.Weight = dev.TargetWeight
+		}
+		b.DevsChanged = true
+	}
+}
+
 // Remove a device from the ring.
 func (b *RingBuilder) RemoveDev(devId int64, purge bool) {
 	if purge {
@@ -1388,6 +1432,7 @@ func (b *RingBuilder) GetBalance() float64 {
 //
 // The proces doesn't always perfectly assign partitions (that'd take a lot more analysis and therefore a lot more time.  Because of this, it keeps rebalancing until the device skew (number of partitions a device wants compared to what it has) gets below 1% or doesn't change by more than 1% (only happens with a ring that can't be balanced no matter what).
 func (b *RingBuilder) Rebalance() (int, float64, int, error) {
+	b.stepWeightRamps()
 	numDevices := 0
 	for next, dev := devIterator(b.Devs); dev != nil; dev = next() {
 		// NOTE: original ringbuilder added a tiers thing, not sure if needed yet
@@ -1514,7 +1559,7 @@ func (b *RingBuilder) SearchDevs(region, zone int64, ip string, port int64, repI
 	return foundDevs
 }
 
-func (b *RingBuilder) UpdateDevInfo(devId int64, newIp string, newPort int64, newRepIp string, newRepPort int64, newDevice, newMeta, newScheme string) error {
+func (b *RingBuilder) UpdateDevInfo(devId int64, newIp string, newPort int64, newRepIp string, newRepPort int64, newDevice, newMeta, newScheme string, newTags map[string]string) error {
 	// first check to make sure another device doesn't have the ip/port/device
 	if newIp == "" {
 		newIp = b.Devs[devId].Ip
@@ -1548,6 +1593,23 @@ func (b *RingBuilder) UpdateDevInfo(devId int64, newIp string, newPort int64, ne
 		}
 		b.Devs[devId].Scheme = newScheme
 	}
+	if len(newTags) > 0 {
+		tags := copyTags(b.Devs[devId].Tags)
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		for k, v := range newTags {
+			if v == "" {
+				delete(tags, k)
+			} else {
+				tags[k] = v
+			}
+		}
+		if len(tags) == 0 {
+			tags = nil
+		}
+		b.Devs[devId].Tags = tags
+	}
 	return nil
 }
 
@@ -1589,6 +1651,7 @@ func (b *RingBuilder) GetRing() *hashRing {
 				ReplicationPort: int(b.Devs[i].ReplicationPort),
 				Weight:          b.Devs[i].Weight,
 				Zone:            int(b.Devs[i].Zone),
+				Tags:            copyTags(b.Devs[i].Tags),
 			})
 		} else {
 			data.Devs = append(data.Devs, nil)
@@ -1778,6 +1841,21 @@ func SetWeight(builderPath string, devs []*RingBuilderDevice, weight float64) er
 	return builder.Save(builderPath)
 }
 
+// RampWeight schedules devs to reach weight over the next steps rebalances.
+// Note that no locking is done here, you should call LockBuilderPath first.
+func RampWeight(builderPath string, devs []*RingBuilderDevice, weight float64, steps int) error {
+	builder, err := NewRingBuilderFromFile(builderPath, false)
+	if err != nil {
+		return err
+	}
+	for _, dev := range devs {
+		if err := builder.SetDevWeightRamp(dev.Id, weight, steps); err != nil {
+			return err
+		}
+	}
+	return builder.Save(builderPath)
+}
+
 // Note that no locking is done here, you should call LockBuilderPath first.
 func RemoveDevs(builderPath string, devs []*RingBuilderDevice, purge bool) error {
 	builder, err := NewRingBuilderFromFile(builderPath, false)
@@ -1791,13 +1869,13 @@ func RemoveDevs(builderPath string, devs []*RingBuilderDevice, purge bool) error
 }
 
 // Note that no locking is done here, you should call LockBuilderPath first.
-func SetInfo(builderPath string, devs []*RingBuilderDevice, newIp string, newPort int64, newRepIp string, newRepPort int64, newDevice, newMeta string, newScheme string) error {
+func SetInfo(builderPath string, devs []*RingBuilderDevice, newIp string, newPort int64, newRepIp string, newRepPort int64, newDevice, newMeta string, newScheme string, newTags map[string]string) error {
 	builder, err := NewRingBuilderFromFile(builderPath, false)
 	if err != nil {
 		return err
 	}
 	for _, dev := range devs {
-		err := builder.UpdateDevInfo(dev.Id, newIp, newPort, newRepIp, newRepPort, newDevice, newMeta, newScheme)
+		err := builder.UpdateDevInfo(dev.Id, newIp, newPort, newRepIp, newRepPort, newDevice, newMeta, newScheme, newTags)
 		if err != nil {
 			return err
 		}
@@ -1846,6 +1924,60 @@ func PretendMinPartHoursPassed(builderPath string) error {
 	return builder.Save(builderPath)
 }
 
+// IncreasePartPower doubles the number of partitions. Partition p becomes
+// partitions 2p and 2p+1, both assigned to p's devices, so no data has to move
+// between devices; objects only need relinking into their new partition
+// directories (see the relinker).
+func (b *RingBuilder) IncreasePartPower() error {
+	if b.PartPower >= 32 {
+		return fmt.Errorf("Part Power is already at the maximum of 32")
+	}
+	// Splitting needs every partition assigned, which only a rebalance does.
+	if len(b.replica2Part2Dev) == 0 || len(b.replica2Part2Dev[0]) != b.Parts {
+		return fmt.Errorf("The ring has no partition assignments; rebalance before increasing the part power")
+	}
+	for _, part2Dev := range b.replica2Part2Dev {
+		for _, dev := range part2Dev {
+			if dev == NONE_DEV {
+				return fmt.Errorf("The ring has unassigned partitions; rebalance before increasing the part power")
+			}
+		}
+	}
+	for r, part2Dev := range b.replica2Part2Dev {
+		doubled := make([]uint, 0, 2*len(part2Dev))
+		for _, dev := range part2Dev {
+			doubled = append(doubled, dev, dev)
+		}
+		b.replica2Part2Dev[r] = doubled
+	}
+	lastPartMoves := make([]byte, 0, 2*len(b.lastPartMoves))
+	for _, moved := range b.lastPartMoves {
+		lastPartMoves = append(lastPartMoves, moved, moved)
+	}
+	b.lastPartMoves = lastPartMoves
+	b.PartPower++
+	b.Parts *= 2
+	b.partMovedBitmap = make([]byte, maxInt(int(math.Exp2(float64(b.PartPower-3))), 1))
+	for next, dev := devIterator(b.Devs); dev != nil; dev = next() {
+		dev.Parts *= 2
+		dev.PartsWanted *= 2
+	}
+	b.Version++
+	return nil
+}
+
+// Note that no locking is done here, you should call LockBuilderPath first.
+func IncreasePartPower(builderPath string) error {
+	builder, err := NewRingBuilderFromFile(builderPath, false)
+	if err != nil {
+		return err
+	}
+	if err = builder.IncreasePartPower(); err != nil {
+		return err
+	}
+	return builder.Save(builderPath)
+}
+
 // Note that no locking is done here, you should call LockBuilderPath first.
 func Validate(builderPath string) error {
 	builder, err := NewRingBuilderFromFile(builderPath, false)
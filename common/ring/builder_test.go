@@ -0,0 +1,93 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package ring
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncreasePartPower(t *testing.T) {
+	b, err := NewRingBuilder(4, 3, 0, false)
+	require.Nil(t, err)
+	for i := 0; i < 4; i++ {
+		_, err := b.AddDev(&RingBuilderDevice{Id: -1, Region: 1, Zone: int64(i), Ip: "127.0.0.1", Port: 6010, Device: fmt.Sprintf("sda%d", i), Weight: 1, Scheme: "http"})
+		require.Nil(t, err)
+	}
+	_, _, _, err = b.Rebalance()
+	require.Nil(t, err)
+	oldRing := b.GetRing()
+
+	require.Nil(t, b.IncreasePartPower())
+	require.Nil(t, b.Validate())
+	require.Equal(t, 5, b.PartPower)
+	require.Equal(t, 32, b.Parts)
+	newRing := b.GetRing()
+	require.Equal(t, uint64(32), newRing.PartitionCount())
+
+	for _, hsh := range []string{"00000000000000000000000000000000", "0fffffff000000000000000000000000", "8c3e2d70000000000000000000000000", "ffffffff000000000000000000000000"} {
+		oldPart, err := oldRing.PartitionForHash(hsh)
+		require.Nil(t, err)
+		newPart, err := newRing.PartitionForHash(hsh)
+		require.Nil(t, err)
+		require.Equal(t, oldPart, newPart/2)
+		for i, dev := range newRing.GetNodes(newPart) {
+			require.Equal(t, oldRing.GetNodes(oldPart)[i].Id, dev.Id)
+		}
+	}
+}
+
+func TestIncreasePartPowerUnbalanced(t *testing.T) {
+	b, err := NewRingBuilder(4, 3, 0, false)
+	require.Nil(t, err)
+	require.NotNil(t, b.IncreasePartPower())
+}
+
+func TestWeightRamp(t *testing.T) {
+	b, err := NewRingBuilder(6, 3, 0, false)
+	require.Nil(t, err)
+	for i := 0; i < 4; i++ {
+		_, err := b.AddDev(&RingBuilderDevice{Id: -1, Region: 1, Zone: int64(i), Ip: "127.0.0.1", Port: 6010, Device: fmt.Sprintf("sda%d", i), Weight: 100, Scheme: "http"})
+		require.Nil(t, err)
+	}
+	_, _, _, err = b.Rebalance()
+	require.Nil(t, err)
+	id, err := b.AddDev(&RingBuilderDevice{Id: -1, Region: 1, Zone: 4, Ip: "127.0.0.1", Port: 6010, Device: "sdb", Weight: 0, Scheme: "http"})
+	require.Nil(t, err)
+	require.NotNil(t, b.SetDevWeightRamp(id, 100, 0))
+	require.Nil(t, b.SetDevWeightRamp(id, 100, 4))
+
+	var weights []float64
+	for i := 0; i < 5; i++ {
+		b.PretendMinPartHoursPassed()
+		_, _, _, err = b.Rebalance()
+		require.Nil(t, err)
+		require.Nil(t, b.Validate())
+		weights = append(weights, b.Devs[id].Weight)
+	}
+	require.Equal(t, []float64{25, 50, 75, 100, 100}, weights)
+	require.Equal(t, int64(0), b.Devs[id].RampSteps)
+	require.True(t, b.Devs[id].Parts > 0)
+
+	// setting the weight outright cancels a ramp in progress
+	require.Nil(t, b.SetDevWeightRamp(id, 0, 2))
+	require.Nil(t, b.SetDevWeight(id, 50))
+	_, _, _, err = b.Rebalance()
+	require.Nil(t, err)
+	require.Equal(t, float64(50), b.Devs[id].Weight)
+}
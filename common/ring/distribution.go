@@ -0,0 +1,276 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package ring
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/troubling/hummingbird/common"
+)
+
+// RingManifestName is the file a ring source lists its rings in, next to
+// the rings themselves.
+const RingManifestName = "rings.json"
+
+var ringFileName = regexp.MustCompile(`^(account|container|object(-[0-9]+)?)\.ring\.gz$`)
+
+// ValidRingFileName returns whether name is a ring file, like object-1.ring.gz,
+// that may be distributed.
+func ValidRingFileName(name string) bool {
+	return ringFileName.MatchString(name)
+}
+
+// RingManifestEntry describes one ring file a source distributes. Version
+// only ever goes up for a given file, so a fetcher never installs a ring
+// older than the one it has.
+type RingManifestEntry struct {
+	Version   int64  `json:"version"`
+	MD5       string `json:"md5"`
+	Signature string `json:"signature"`
+}
+
+// RingManifest is the list of rings a source distributes, by file name.
+type RingManifest map[string]RingManifestEntry
+
+// ringSignature signs everything a fetcher trusts about a ring file with
+// the key shared by the source and the fetchers.
+func ringSignature(key, name string, version int64, md5 string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%s\n%d\n%s", name, version, md5)
+	return fmt.Sprintf("%x", mac.Sum(nil))
+}
+
+func fileMD5(path string) (string, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer fp.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, fp); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// BuildRingManifest lists and signs the ring files in dir, using each file's
+// modification time as its version.
+func BuildRingManifest(dir, key string) (RingManifest, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	manifest := RingManifest{}
+	for _, fi := range fis {
+		if fi.IsDir() || !ValidRingFileName(fi.Name()) {
+			continue
+		}
+		sum, err := fileMD5(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		version := fi.ModTime().UnixNano()
+		manifest[fi.Name()] = RingManifestEntry{Version: version, MD5: sum, Signature: ringSignature(key, fi.Name(), version, sum)}
+	}
+	return manifest, nil
+}
+
+// RingFetcher keeps the rings in a directory up to date with a source that
+// serves a RingManifest and the rings it lists, such as andrewd's /rings/ or
+// a public container. What it has installed is recorded in the directory's
+// own rings.json, so restarts and other processes sharing the directory
+// don't fetch the same rings again.
+type RingFetcher struct {
+	source string
+	key    string
+	dir    string
+	prefix string
+	suffix string
+	client common.HTTPClient
+}
+
+// NewRingFetcher returns a RingFetcher installing rings from the source URL
+// into dir. prefix and suffix are the hash path prefix and suffix, used to
+// check a fetched ring loads before it's installed.
+func NewRingFetcher(source, key, dir, prefix, suffix string, client common.HTTPClient) *RingFetcher {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &RingFetcher{source: strings.TrimSuffix(source, "/"), key: key, dir: dir, prefix: prefix, suffix: suffix, client: client}
+}
+
+func (f *RingFetcher) get(name string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", f.source+"/"+name, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s/%s: %d", f.source, name, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+func (f *RingFetcher) installedManifest() RingManifest {
+	manifest := RingManifest{}
+	if data, err := ioutil.ReadFile(filepath.Join(f.dir, RingManifestName)); err == nil {
+		json.Unmarshal(data, &manifest)
+	}
+	return manifest
+}
+
+// writeTemp writes a synced temp file in f.dir, to be renamed over the real
+// one so readers only ever see the old or the new contents.
+func (f *RingFetcher) writeTemp(write func(io.Writer) error) (string, error) {
+	fp, err := ioutil.TempFile(f.dir, ".ringfetch-")
+	if err != nil {
+		return "", err
+	}
+	tmp := fp.Name()
+	if err = write(fp); err == nil {
+		err = fp.Sync()
+	}
+	if cerr := fp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0644)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return tmp, nil
+}
+
+// install fetches one ring into a temp file, checks it against its manifest
+// entry and that it loads, backs up the current ring the way the ring
+// builder does, and renames the new one into place.
+func (f *RingFetcher) install(name string, entry RingManifestEntry) error {
+	body, err := f.get(name)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	tmp, err := f.writeTemp(func(w io.Writer) error {
+		_, err := io.Copy(w, body)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	r, err := LoadRingMD5(tmp, f.prefix, f.suffix)
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("%s doesn't load: %v", name, err)
+	}
+	if r.MD5() != entry.MD5 {
+		os.Remove(tmp)
+		return fmt.Errorf("%s has md5 %s, not %s", name, r.MD5(), entry.MD5)
+	}
+	path := filepath.Join(f.dir, name)
+	if _, err := os.Stat(path); err == nil {
+		backups := filepath.Join(f.dir, "backups")
+		if err := os.MkdirAll(backups, 0755); err == nil {
+			os.Link(path, filepath.Join(backups, fmt.Sprintf("%d.%s", time.Now().UnixNano(), name)))
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// FetchOnce installs any ring in the source's manifest that's newer than the
+// one in f.dir, returning the names of the rings it installed. Rings that
+// fail their checks are left alone and reported in the error; the rest are
+// still installed.
+func (f *RingFetcher) FetchOnce() ([]string, error) {
+	body, err := f.get(RingManifestName)
+	if err != nil {
+		return nil, err
+	}
+	var manifest RingManifest
+	err = json.NewDecoder(body).Decode(&manifest)
+	body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("invalid ring manifest: %v", err)
+	}
+	installed := f.installedManifest()
+	var names []string
+	for name := range manifest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var updated, problems []string
+	changed := false
+	for _, name := range names {
+		entry := manifest[name]
+		if !ValidRingFileName(name) {
+			problems = append(problems, fmt.Sprintf("%s isn't a ring file name", name))
+			continue
+		}
+		if !hmac.Equal([]byte(entry.Signature), []byte(ringSignature(f.key, name, entry.Version, entry.MD5))) {
+			problems = append(problems, fmt.Sprintf("%s has a bad signature", name))
+			continue
+		}
+		if current, ok := installed[name]; ok && current.Version >= entry.Version {
+			continue
+		}
+		if sum, err := fileMD5(filepath.Join(f.dir, name)); err != nil || sum != entry.MD5 {
+			if err := f.install(name, entry); err != nil {
+				problems = append(problems, err.Error())
+				continue
+			}
+			updated = append(updated, name)
+		}
+		installed[name] = entry
+		changed = true
+	}
+	if changed {
+		tmp, err := f.writeTemp(func(w io.Writer) error {
+			return json.NewEncoder(w).Encode(installed)
+		})
+		if err == nil {
+			err = os.Rename(tmp, filepath.Join(f.dir, RingManifestName))
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("unable to record installed rings: %v", err))
+		}
+	}
+	if len(problems) > 0 {
+		return updated, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return updated, nil
+}
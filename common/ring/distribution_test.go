@@ -0,0 +1,120 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package ring
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRingFetcher(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(srcDir)
+	dstDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(dstDir)
+
+	writeRing := func(dir, name string, deviceCount int) {
+		fp, err := os.Create(filepath.Join(dir, name))
+		require.Nil(t, err)
+		require.Nil(t, writeARing(fp, deviceCount, 2, 29, -1))
+		require.Nil(t, fp.Close())
+	}
+	writeRing(srcDir, "object.ring.gz", 4)
+	writeRing(srcDir, "object-1.ring.gz", 3)
+	writeRing(dstDir, "object.ring.gz", 2)
+	require.Nil(t, ioutil.WriteFile(filepath.Join(srcDir, "object.builder"), []byte("not a ring"), 0644))
+
+	var manifest RingManifest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rings/"+RingManifestName {
+			json.NewEncoder(w).Encode(manifest)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join(srcDir, filepath.Base(r.URL.Path)))
+	}))
+	defer ts.Close()
+
+	manifest, err = BuildRingManifest(srcDir, "secret")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(manifest))
+	f := NewRingFetcher(ts.URL+"/rings/", "secret", dstDir, "prefix", "suffix", nil)
+	updated, err := f.FetchOnce()
+	require.Nil(t, err)
+	require.Equal(t, []string{"object-1.ring.gz", "object.ring.gz"}, updated)
+	r, err := LoadRingMD5(filepath.Join(dstDir, "object.ring.gz"), "prefix", "suffix")
+	require.Nil(t, err)
+	require.Equal(t, manifest["object.ring.gz"].MD5, r.MD5())
+	backups, err := ioutil.ReadDir(filepath.Join(dstDir, "backups"))
+	require.Nil(t, err)
+	require.Equal(t, 1, len(backups))
+
+	// nothing newer, nothing to do
+	updated, err = f.FetchOnce()
+	require.Nil(t, err)
+	require.Equal(t, 0, len(updated))
+
+	// an older version is never installed, even if it's different
+	writeRing(srcDir, "object.ring.gz", 5)
+	old := manifest["object.ring.gz"].Version - int64(time.Second)
+	require.Nil(t, os.Chtimes(filepath.Join(srcDir, "object.ring.gz"), time.Unix(0, old), time.Unix(0, old)))
+	manifest, err = BuildRingManifest(srcDir, "secret")
+	require.Nil(t, err)
+	updated, err = f.FetchOnce()
+	require.Nil(t, err)
+	require.Equal(t, 0, len(updated))
+
+	// nor is a ring whose signature doesn't check out
+	newer := time.Now().Add(time.Hour)
+	require.Nil(t, os.Chtimes(filepath.Join(srcDir, "object.ring.gz"), newer, newer))
+	manifest, err = BuildRingManifest(srcDir, "wrong")
+	require.Nil(t, err)
+	updated, err = f.FetchOnce()
+	require.NotNil(t, err)
+	require.Equal(t, 0, len(updated))
+
+	// or whose contents don't match its manifest
+	manifest, err = BuildRingManifest(srcDir, "secret")
+	require.Nil(t, err)
+	entry := manifest["object.ring.gz"]
+	entry.MD5 = "0123456789abcdef0123456789abcdef"
+	entry.Signature = ringSignature("secret", "object.ring.gz", entry.Version, entry.MD5)
+	manifest["object.ring.gz"] = entry
+	updated, err = f.FetchOnce()
+	require.NotNil(t, err)
+	require.Equal(t, 0, len(updated))
+	r, err = LoadRingMD5(filepath.Join(dstDir, "object.ring.gz"), "prefix", "suffix")
+	require.Nil(t, err)
+	require.Equal(t, 4, len(r.AllDevices()))
+
+	manifest, err = BuildRingManifest(srcDir, "secret")
+	require.Nil(t, err)
+	updated, err = f.FetchOnce()
+	require.Nil(t, err)
+	require.Equal(t, []string{"object.ring.gz"}, updated)
+	r, err = LoadRingMD5(filepath.Join(dstDir, "object.ring.gz"), "prefix", "suffix")
+	require.Nil(t, err)
+	require.Equal(t, 5, len(r.AllDevices()))
+}
@@ -0,0 +1,141 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package ring
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/troubling/hummingbird/common"
+)
+
+// DeviceFilter reports whether a device should be used. Callers pass over the
+// devices a filter rejects in favor of others, such as handoffs.
+type DeviceFilter func(dev *Device) bool
+
+// DeviceSorter reorders devices in place, most preferred first.
+type DeviceSorter func(devs []*Device)
+
+var placementLock sync.RWMutex
+var deviceFilters = map[string]DeviceFilter{
+	"maintenance": notInMaintenance,
+}
+var deviceSorters = map[string]DeviceSorter{}
+
+// RegisterDeviceFilter makes a DeviceFilter available by name, so it can be
+// listed in the device_filters setting of the proxy and replicators. It's
+// meant to be called from an init function.
+func RegisterDeviceFilter(name string, filter DeviceFilter) {
+	placementLock.Lock()
+	deviceFilters[name] = filter
+	placementLock.Unlock()
+}
+
+// RegisterDeviceSorter makes a DeviceSorter available by name, so it can be
+// given as the proxy's device_sorter setting. It's meant to be called from an
+// init function.
+func RegisterDeviceSorter(name string, sorter DeviceSorter) {
+	placementLock.Lock()
+	deviceSorters[name] = sorter
+	placementLock.Unlock()
+}
+
+// GetDeviceFilter returns a DeviceFilter that passes the devices passed by
+// every filter in the comma separated list of names, or nil if names is
+// empty.
+func GetDeviceFilter(names string) (DeviceFilter, error) {
+	placementLock.RLock()
+	defer placementLock.RUnlock()
+	var filters []DeviceFilter
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		filter, ok := deviceFilters[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown device filter %q", name)
+		}
+		filters = append(filters, filter)
+	}
+	switch len(filters) {
+	case 0:
+		return nil, nil
+	case 1:
+		return filters[0], nil
+	}
+	return func(dev *Device) bool {
+		for _, filter := range filters {
+			if !filter(dev) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// GetDeviceSorter returns the named DeviceSorter, or nil if name is empty.
+func GetDeviceSorter(name string) (DeviceSorter, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+	placementLock.RLock()
+	defer placementLock.RUnlock()
+	sorter, ok := deviceSorters[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown device sorter %q", name)
+	}
+	return sorter, nil
+}
+
+// notInMaintenance rejects devices with "maintenance" or a true
+// "maintenance=" field in their meta, or a true maintenance tag.
+func notInMaintenance(dev *Device) bool {
+	if v, ok := dev.Tags["maintenance"]; ok && common.LooksTrue(v) {
+		return false
+	}
+	for _, field := range strings.Fields(dev.Meta) {
+		if field == "maintenance" || (strings.HasPrefix(field, "maintenance=") && common.LooksTrue(strings.TrimPrefix(field, "maintenance="))) {
+			return false
+		}
+	}
+	return true
+}
+
+type filteredMoreNodes struct {
+	more   MoreNodes
+	filter DeviceFilter
+}
+
+func (m *filteredMoreNodes) Next() *Device {
+	for {
+		dev := m.more.Next()
+		if dev == nil || m.filter(dev) {
+			return dev
+		}
+	}
+}
+
+// FilterMoreNodes returns a MoreNodes that skips the devices filter rejects.
+// A nil filter returns more as is.
+func FilterMoreNodes(more MoreNodes, filter DeviceFilter) MoreNodes {
+	if filter == nil {
+		return more
+	}
+	return &filteredMoreNodes{more: more, filter: filter}
+}
@@ -0,0 +1,88 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type listMoreNodes struct {
+	devs []*Device
+}
+
+func (m *listMoreNodes) Next() *Device {
+	if len(m.devs) == 0 {
+		return nil
+	}
+	dev := m.devs[0]
+	m.devs = m.devs[1:]
+	return dev
+}
+
+func TestGetDeviceFilter(t *testing.T) {
+	filter, err := GetDeviceFilter("")
+	require.Nil(t, err)
+	require.Nil(t, filter)
+	_, err = GetDeviceFilter("maintenance, nonexistent")
+	require.NotNil(t, err)
+
+	filter, err = GetDeviceFilter("maintenance")
+	require.Nil(t, err)
+	require.True(t, filter(&Device{}))
+	require.True(t, filter(&Device{Meta: "path_prefix=/maintenance maintenance=no"}))
+	require.False(t, filter(&Device{Meta: "maintenance"}))
+	require.False(t, filter(&Device{Meta: "path_prefix=/x maintenance=true"}))
+
+	RegisterDeviceFilter("test-not-sdb", func(dev *Device) bool { return dev.Device != "sdb" })
+	filter, err = GetDeviceFilter("maintenance,test-not-sdb")
+	require.Nil(t, err)
+	require.True(t, filter(&Device{Device: "sda"}))
+	require.False(t, filter(&Device{Device: "sdb"}))
+	require.False(t, filter(&Device{Device: "sda", Meta: "maintenance"}))
+}
+
+func TestGetDeviceSorter(t *testing.T) {
+	sorter, err := GetDeviceSorter("")
+	require.Nil(t, err)
+	require.Nil(t, sorter)
+	_, err = GetDeviceSorter("nonexistent")
+	require.NotNil(t, err)
+
+	RegisterDeviceSorter("test-reverse", func(devs []*Device) {
+		for i, j := 0, len(devs)-1; i < j; i, j = i+1, j-1 {
+			devs[i], devs[j] = devs[j], devs[i]
+		}
+	})
+	sorter, err = GetDeviceSorter("test-reverse")
+	require.Nil(t, err)
+	devs := []*Device{{Id: 0}, {Id: 1}, {Id: 2}}
+	sorter(devs)
+	require.Equal(t, 2, devs[0].Id)
+	require.Equal(t, 0, devs[2].Id)
+}
+
+func TestFilterMoreNodes(t *testing.T) {
+	more := &listMoreNodes{devs: []*Device{{Id: 0}, {Id: 1, Meta: "maintenance"}, {Id: 2}}}
+	require.Equal(t, more, FilterMoreNodes(more, nil))
+	filter, err := GetDeviceFilter("maintenance")
+	require.Nil(t, err)
+	filtered := FilterMoreNodes(more, filter)
+	require.Equal(t, 0, filtered.Next().Id)
+	require.Equal(t, 2, filtered.Next().Id)
+	require.Nil(t, filtered.Next())
+}
@@ -31,6 +31,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/troubling/hummingbird/common/conf"
 )
 
 const reloadTime = 15 * time.Second
@@ -57,12 +59,16 @@ type Device struct {
 	Scheme          string  `json:"scheme"`
 	Ip              string  `json:"ip"`
 	Meta            string  `json:"meta"`
+	PathPrefix      string  `json:"path_prefix"`
 	Port            int     `json:"port"`
 	Region          int     `json:"region"`
 	ReplicationIp   string  `json:"replication_ip"`
 	ReplicationPort int     `json:"replication_port"`
 	Weight          float64 `json:"weight"`
 	Zone            int     `json:"zone"`
+	// Tags are free-form key/value labels for the device, such as rack=r12
+	// or disk=ssd, that affinity and throttle settings can refer to.
+	Tags map[string]string `json:"tags,omitempty"`
 }
 
 type RingMD5 interface {
@@ -81,6 +87,7 @@ type ringData struct {
 	replica2part2devId                  [][]uint16
 	regionCount, zoneCount, ipPortCount int
 	md5                                 string
+	mtime                               time.Time
 }
 
 type hashRing struct {
@@ -340,6 +347,10 @@ func (r *hashRing) Reload() error {
 	regionCount := make(map[int]bool)
 	zoneCount := make(map[regionZone]bool)
 	ipPortCount := make(map[ipPort]bool)
+	defaultScheme, defaultPathPrefix := conf.GetBackendDefaults()
+	if defaultScheme == "" {
+		defaultScheme = "http"
+	}
 	for _, d := range data.Devs {
 		if !d.Active() {
 			continue
@@ -351,8 +362,15 @@ func (r *hashRing) Reload() error {
 			d.ReplicationPort = d.Port + 500
 		}
 		if d.Scheme == "" {
-			d.Scheme = "http"
+			d.Scheme = defaultScheme
+		}
+		if d.PathPrefix == "" {
+			d.PathPrefix = metaPathPrefix(d.Meta)
+		}
+		if d.PathPrefix == "" {
+			d.PathPrefix = defaultPathPrefix
 		}
+		d.PathPrefix = normalizePathPrefix(d.PathPrefix)
 		regionCount[d.Region] = true
 		zoneCount[regionZone{d.Region, d.Zone}] = true
 		ipPortCount[ipPort{d.Region, d.Zone, d.Port, d.Ip}] = true
@@ -361,10 +379,45 @@ func (r *hashRing) Reload() error {
 	data.zoneCount = len(zoneCount)
 	data.ipPortCount = len(ipPortCount)
 	r.mtime = fi.ModTime()
+	data.mtime = r.mtime
 	r.data.Store(data)
 	return nil
 }
 
+// ModTime returns the modification time of the ring file r was last loaded
+// from, or the zero time if r wasn't loaded from a file. Rings that aren't
+// loaded from files may report their own with a ModTime method.
+func ModTime(r Ring) time.Time {
+	if hr, ok := r.(*hashRing); ok {
+		return hr.getData().mtime
+	}
+	if mr, ok := r.(interface{ ModTime() time.Time }); ok {
+		return mr.ModTime()
+	}
+	return time.Time{}
+}
+
+// metaPathPrefix returns the path_prefix=/some/path setting from a device's
+// meta string, if it has one.
+func metaPathPrefix(meta string) string {
+	for _, field := range strings.Fields(meta) {
+		if strings.HasPrefix(field, "path_prefix=") {
+			return strings.TrimPrefix(field, "path_prefix=")
+		}
+	}
+	return ""
+}
+
+// normalizePathPrefix gives a path prefix a leading slash and no trailing
+// one, so it can go directly between the host:port and the rest of a URL.
+func normalizePathPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
+
 func (r *hashRing) reloader() error {
 	for {
 		time.Sleep(reloadTime)
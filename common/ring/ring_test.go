@@ -165,6 +165,9 @@ func TestRingReload(t *testing.T) {
 	require.Equal(t, 5, len(ring.getData().Devs))
 	require.Equal(t, 3, ring.getData().ReplicaCount)
 	require.Equal(t, uint64(30), ring.getData().PartShift)
+	fi, err := os.Stat(fp.Name())
+	require.Nil(t, err)
+	require.Equal(t, fi.ModTime(), ModTime(r))
 }
 
 func TestCounts(t *testing.T) {
@@ -177,3 +180,12 @@ func TestCounts(t *testing.T) {
 	require.Equal(t, uint64(2), r.ReplicaCount())
 	require.Equal(t, uint64(8), r.PartitionCount())
 }
+
+func TestMetaPathPrefix(t *testing.T) {
+	require.Equal(t, "", metaPathPrefix(""))
+	require.Equal(t, "", metaPathPrefix("ssd rack2"))
+	require.Equal(t, "/store1", metaPathPrefix("ssd path_prefix=/store1 rack2"))
+	require.Equal(t, "", normalizePathPrefix("/"))
+	require.Equal(t, "/store1", normalizePathPrefix("store1/"))
+	require.Equal(t, "/a/b", normalizePathPrefix("/a/b"))
+}
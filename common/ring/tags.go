@@ -0,0 +1,79 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package ring
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HasTag reports whether the device is tagged key=value.
+func (d *Device) HasTag(key, value string) bool {
+	v, ok := d.Tags[key]
+	return ok && v == value
+}
+
+// ParseTags parses a comma separated list of key=value tags, such as
+// "rack=r12,disk=ssd". An empty value, as in "rack=", is kept so callers can
+// use it to remove a tag.
+func ParseTags(s string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || key == "" {
+			return nil, fmt.Errorf("Invalid tag %q, should be key=value", item)
+		}
+		tags[key] = strings.TrimSpace(parts[1])
+	}
+	return tags, nil
+}
+
+// FormatTags returns tags in the form ParseTags accepts, sorted by key.
+func FormatTags(tags map[string]string) string {
+	items := make([]string, 0, len(tags))
+	for k, v := range tags {
+		items = append(items, k+"="+v)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// ParseTagSelector parses a "key:value" reference to a device tag, as used in
+// affinity and throttle settings. ok is false if s isn't of that form.
+func ParseTagSelector(s string) (key, value string, ok bool) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	c := make(map[string]string, len(tags))
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,72 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package ring
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTags(t *testing.T) {
+	tags, err := ParseTags(" rack=r12, disk=ssd ,chassis=")
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"rack": "r12", "disk": "ssd", "chassis": ""}, tags)
+	tags, err = ParseTags("")
+	require.Nil(t, err)
+	require.Equal(t, 0, len(tags))
+	_, err = ParseTags("rack")
+	require.NotNil(t, err)
+	_, err = ParseTags("=r12")
+	require.NotNil(t, err)
+	require.Equal(t, "disk=ssd,rack=r12", FormatTags(map[string]string{"rack": "r12", "disk": "ssd"}))
+}
+
+func TestParseTagSelector(t *testing.T) {
+	key, value, ok := ParseTagSelector("rack:r12")
+	require.True(t, ok)
+	require.Equal(t, "rack", key)
+	require.Equal(t, "r12", value)
+	_, _, ok = ParseTagSelector("r1")
+	require.False(t, ok)
+	_, _, ok = ParseTagSelector("rack:")
+	require.False(t, ok)
+}
+
+func TestDeviceTags(t *testing.T) {
+	b, err := NewRingBuilder(8, 3, 1, false)
+	require.Nil(t, err)
+	id, err := b.AddDev(&RingBuilderDevice{Region: 0, Zone: 0, Ip: "127.0.0.1", Port: 6000, Device: "sda", Weight: 1})
+	require.Nil(t, err)
+	require.Nil(t, b.UpdateDevInfo(id, "", -1, "", -1, "", "", "", map[string]string{"rack": "r12", "disk": "ssd"}))
+	require.Nil(t, b.UpdateDevInfo(id, "", -1, "", -1, "", "", "", map[string]string{"disk": ""}))
+	require.Equal(t, map[string]string{"rack": "r12"}, b.Devs[id].Tags)
+	dir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	require.Nil(t, b.Save(filepath.Join(dir, "object.builder")))
+	b, err = NewRingBuilderFromFile(filepath.Join(dir, "object.builder"), false)
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"rack": "r12"}, b.Devs[id].Tags)
+	dev := b.GetRing().getData().Devs[id]
+	require.True(t, dev.HasTag("rack", "r12"))
+	require.False(t, dev.HasTag("disk", "ssd"))
+	require.False(t, notInMaintenance(&Device{Tags: map[string]string{"maintenance": "yes"}}))
+	require.True(t, notInMaintenance(&Device{Tags: map[string]string{"maintenance": "no"}}))
+}
@@ -0,0 +1,58 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package srv
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// AdminHandler serves profiling and log level endpoints meant only for
+// operators:
+//
+//	/debug/pprof/...        the standard net/http/pprof handlers
+//	/loglevels              see LogLevelsHandler
+//	/loglevel/<component>   see LogLevelHandler
+func AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/loglevels", LogLevelsHandler)
+	mux.HandleFunc("/loglevel/", func(writer http.ResponseWriter, request *http.Request) {
+		component := strings.TrimPrefix(request.URL.Path, "/loglevel/")
+		LogLevelHandler(writer, SetVars(request, map[string]string{"component": component}))
+	})
+	return mux
+}
+
+// ServeAdmin serves AdminHandler on ip:port, which should not be reachable
+// by clients. It is enabled with admin_port (and optionally admin_ip, which
+// defaults to 127.0.0.1) in the [DEFAULT] section of a server's config.
+func ServeAdmin(ip string, port int, logger LowLevelLogger) {
+	addr := net.JoinHostPort(ip, strconv.Itoa(port))
+	logger.Info("Admin server started", zap.String("addr", addr))
+	if err := http.ListenAndServe(addr, AdminHandler()); err != nil {
+		logger.Error("Admin server failed", zap.String("addr", addr), zap.Error(err))
+	}
+}
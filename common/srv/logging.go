@@ -0,0 +1,112 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package srv
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Every logger made by SetupLogger registers its level here under its
+// component name, so levels can be changed per component at runtime with
+// LogLevelHandler, or all at once with SIGUSR1 (debug) and SIGUSR2 (back to
+// the configured levels).
+var (
+	logLevelsLock   sync.Mutex
+	logLevels       = map[string]*zap.AtomicLevel{}
+	savedLogLevels  map[string]zapcore.Level
+	logSignalsSetup sync.Once
+)
+
+func registerLogLevel(component string, level *zap.AtomicLevel) {
+	logLevelsLock.Lock()
+	logLevels[component] = level
+	logLevelsLock.Unlock()
+	logSignalsSetup.Do(func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGUSR1, syscall.SIGUSR2)
+		go func() {
+			for s := range c {
+				if s == syscall.SIGUSR1 {
+					debugLogLevels()
+				} else {
+					restoreLogLevels()
+				}
+			}
+		}()
+	})
+}
+
+// debugLogLevels switches every component to debug logging, remembering the
+// levels they had so restoreLogLevels can put them back.
+func debugLogLevels() {
+	logLevelsLock.Lock()
+	defer logLevelsLock.Unlock()
+	if savedLogLevels == nil {
+		savedLogLevels = map[string]zapcore.Level{}
+		for component, level := range logLevels {
+			savedLogLevels[component] = level.Level()
+		}
+	}
+	for _, level := range logLevels {
+		level.SetLevel(zapcore.DebugLevel)
+	}
+}
+
+func restoreLogLevels() {
+	logLevelsLock.Lock()
+	defer logLevelsLock.Unlock()
+	for component, saved := range savedLogLevels {
+		if level, ok := logLevels[component]; ok {
+			level.SetLevel(saved)
+		}
+	}
+	savedLogLevels = nil
+}
+
+// LogLevelHandler gets or sets the level of the component named by the
+// :component route variable, e.g. PUT /loglevel/object-auditor with a body of
+// {"level":"debug"}.
+func LogLevelHandler(writer http.ResponseWriter, request *http.Request) {
+	logLevelsLock.Lock()
+	level := logLevels[GetVars(request)["component"]]
+	logLevelsLock.Unlock()
+	if level == nil {
+		SimpleErrorResponse(writer, http.StatusNotFound, "Unknown log component")
+		return
+	}
+	level.ServeHTTP(writer, request)
+}
+
+// LogLevelsHandler lists the current level of every component.
+func LogLevelsHandler(writer http.ResponseWriter, request *http.Request) {
+	levels := map[string]string{}
+	logLevelsLock.Lock()
+	for component, level := range logLevels {
+		levels[component] = level.Level().String()
+	}
+	logLevelsLock.Unlock()
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusOK)
+	json.NewEncoder(writer).Encode(levels)
+}
@@ -0,0 +1,72 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package srv
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestComponentLogLevels(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.String("log-format", "console", "")
+	serverLevel := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	_, err := SetupLogger("test-server", &serverLevel, flags)
+	require.Nil(t, err)
+	clientLevel := zap.NewAtomicLevelAt(zapcore.WarnLevel)
+	_, err = SetupLogger("test-client", &clientLevel, flags)
+	require.Nil(t, err)
+
+	req, _ := http.NewRequest("PUT", "/loglevel/test-client", strings.NewReader(`{"level":"error"}`))
+	req = SetVars(req, map[string]string{"component": "test-client"})
+	w := httptest.NewRecorder()
+	LogLevelHandler(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, zapcore.ErrorLevel, clientLevel.Level())
+	require.Equal(t, zapcore.InfoLevel, serverLevel.Level())
+
+	req, _ = http.NewRequest("GET", "/loglevel/nope", nil)
+	req = SetVars(req, map[string]string{"component": "nope"})
+	w = httptest.NewRecorder()
+	LogLevelHandler(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	debugLogLevels()
+	require.Equal(t, zapcore.DebugLevel, clientLevel.Level())
+	require.Equal(t, zapcore.DebugLevel, serverLevel.Level())
+	restoreLogLevels()
+	require.Equal(t, zapcore.ErrorLevel, clientLevel.Level())
+	require.Equal(t, zapcore.InfoLevel, serverLevel.Level())
+
+	w = httptest.NewRecorder()
+	LogLevelsHandler(w, nil)
+	require.Contains(t, w.Body.String(), `"test-client":"error"`)
+}
+
+func TestSetupLoggerBadFormat(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.String("log-format", "xml", "")
+	level := zap.NewAtomicLevel()
+	_, err := SetupLogger("test-server", &level, flags)
+	require.NotNil(t, err)
+}
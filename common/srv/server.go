@@ -44,6 +44,7 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"golang.org/x/net/http2"
+	"golang.org/x/net/netutil"
 )
 
 var responseTemplate = "<html><h1>%s</h1><p>%s</p></html>"
@@ -96,6 +97,102 @@ type IpPort struct {
 	Ip                string
 	Port              int
 	CertFile, KeyFile string
+	// ExtraBinds are more addresses to serve the same handler on, each with
+	// its own listener.
+	ExtraBinds []*IpPort
+	// Wrap, if set, wraps the server's handler for this listener only.
+	Wrap func(http.Handler) http.Handler
+	// Limits, if set, replaces the default connection limits and timeouts.
+	Limits *ServerLimits
+	// TLSConfig, if set, is served instead of CertFile and KeyFile; it should
+	// carry its own certificates.
+	TLSConfig *tls.Config
+}
+
+// ServerLimits bound what each client connection can hold on to, so slow or
+// idle clients can't tie up a server.
+type ServerLimits struct {
+	// MaxClients is how many connections are served at once; more wait to
+	// be accepted. 0 is unlimited.
+	MaxClients        int
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// MaxHeaderBytes of 0 uses net/http's default of 1MB.
+	MaxHeaderBytes int
+	// NetworkReadBuffer and NetworkWriteBuffer are the socket buffer sizes
+	// for accepted connections; 0 leaves the OS default.
+	NetworkReadBuffer  int
+	NetworkWriteBuffer int
+}
+
+// DefaultServerLimits are used for servers without their own Limits.
+var DefaultServerLimits = ServerLimits{
+	ReadTimeout:  24 * time.Hour,
+	WriteTimeout: 24 * time.Hour,
+}
+
+// GetServerLimits reads max_clients, client_read_timeout,
+// client_header_timeout, client_write_timeout, client_idle_timeout (all in
+// seconds), max_header_size, network_read_buffer and network_write_buffer
+// from section.
+func GetServerLimits(config conf.Config, section string) *ServerLimits {
+	seconds := func(key string, dfl time.Duration) time.Duration {
+		return time.Duration(config.GetFloat(section, key, dfl.Seconds()) * float64(time.Second))
+	}
+	return &ServerLimits{
+		MaxClients:         int(config.GetInt(section, "max_clients", 0)),
+		ReadTimeout:        seconds("client_read_timeout", DefaultServerLimits.ReadTimeout),
+		ReadHeaderTimeout:  seconds("client_header_timeout", time.Minute),
+		WriteTimeout:       seconds("client_write_timeout", DefaultServerLimits.WriteTimeout),
+		IdleTimeout:        seconds("client_idle_timeout", 0),
+		MaxHeaderBytes:     int(config.GetInt(section, "max_header_size", 0)),
+		NetworkReadBuffer:  int(config.GetInt(section, "network_read_buffer", 0)),
+		NetworkWriteBuffer: int(config.GetInt(section, "network_write_buffer", 0)),
+	}
+}
+
+// SetSocketBuffers sets conn's socket read and write buffer sizes, leaving
+// either alone if it's 0 or conn isn't TCP.
+func SetSocketBuffers(conn net.Conn, read, write int) {
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	if read > 0 {
+		tc.SetReadBuffer(read)
+	}
+	if write > 0 {
+		tc.SetWriteBuffer(write)
+	}
+}
+
+// socketBufferListener applies socket buffer sizes to each connection it
+// accepts.
+type socketBufferListener struct {
+	net.Listener
+	read, write int
+}
+
+func (l *socketBufferListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		SetSocketBuffers(conn, l.read, l.write)
+	}
+	return conn, err
+}
+
+// newHTTPServer returns an http.Server for handler with limits applied.
+func newHTTPServer(handler http.Handler, limits *ServerLimits) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadTimeout:       limits.ReadTimeout,
+		ReadHeaderTimeout: limits.ReadHeaderTimeout,
+		WriteTimeout:      limits.WriteTimeout,
+		IdleTimeout:       limits.IdleTimeout,
+		MaxHeaderBytes:    limits.MaxHeaderBytes,
+	}
 }
 
 func (w *customWriter) WriteHeader(status int) {
@@ -106,6 +203,10 @@ func (w *customWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return w.ResponseWriter.(http.Hijacker).Hijack()
 }
 
+func (w *customWriter) Flush() {
+	Flush(w.ResponseWriter)
+}
+
 // NewCustomWriter creates an http.ResponseWriter wrapper that calls your function on WriteHeader.
 func NewCustomWriter(w http.ResponseWriter, f func(w http.ResponseWriter, status int) int) http.ResponseWriter {
 	return &customWriter{ResponseWriter: w, f: f}
@@ -130,6 +231,17 @@ func (w WebWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return w.ResponseWriter.(http.Hijacker).Hijack()
 }
 
+func (w *WebWriter) Flush() {
+	Flush(w.ResponseWriter)
+}
+
+// Flush sends anything w has buffered to the client, if w can.
+func Flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func (w *WebWriter) Response() (time.Time, int) {
 	return w.ResponseStarted, w.Status
 }
@@ -233,6 +345,19 @@ func ValidateRequest(w http.ResponseWriter, r *http.Request) bool {
 	return true
 }
 
+// ValidateListingParams makes sure the prefix, delimiter, markers and path of a
+// listing request are valid UTF8 without NULLs. Delimiters may be any number
+// of characters. If not, it writes its own response and returns false.
+func ValidateListingParams(w http.ResponseWriter, r *http.Request) bool {
+	for _, param := range []string{"prefix", "delimiter", "marker", "end_marker", "path"} {
+		if v := r.Form.Get(param); !utf8.ValidString(v) || strings.Contains(v, "\x00") {
+			SimpleErrorResponse(w, 412, "Invalid UTF8 or contains NULL")
+			return false
+		}
+	}
+	return true
+}
+
 type LowLevelLogger interface {
 	Error(msg string, fields ...zapcore.Field)
 	Info(msg string, fields ...zapcore.Field)
@@ -310,7 +435,21 @@ func SetupLogger(prefix string, atomicLevel *zap.AtomicLevel, flags *flag.FlagSe
 	infos := zapcore.AddSync(lowPrioFile)
 	errors := zapcore.AddSync(highPrioFile)
 
-	encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	var encoder zapcore.Encoder
+	format := "json"
+	if fFlag := flags.Lookup("log-format"); fFlag != nil {
+		format = fFlag.Value.(flag.Getter).Get().(string)
+	}
+	switch format {
+	case "json", "":
+		encoder = zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	case "console":
+		encoderConfig := zap.NewProductionEncoderConfig()
+		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, fmt.Errorf("Unknown log format: %s", format)
+	}
 
 	core := zapcore.NewTee(
 		zapcore.NewCore(encoder, infos, lowPriority),
@@ -319,6 +458,7 @@ func SetupLogger(prefix string, atomicLevel *zap.AtomicLevel, flags *flag.FlagSe
 
 	baseLogger := zap.New(core)
 	logger := baseLogger.With(zap.String("name", prefix))
+	registerLogLevel(prefix, atomicLevel)
 	return logger, nil
 }
 
@@ -529,6 +669,107 @@ type Server interface {
 	Finalize() // This is called before stoping gracefully so that a server can clean up before closing
 }
 
+// serve starts an http server for handler listening on bind, using the TLS
+// settings from ipPort and bind's limits, or ipPort's if bind has none.
+func serve(bind *IpPort, ipPort *IpPort, handler http.Handler, serverType string, logger LowLevelLogger, finalize func()) *HummingbirdServer {
+	sock, err := RetryListen(bind.Ip, bind.Port)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listening: %v\n", err)
+		logger.Error("Error listening", zap.Error(err))
+		os.Exit(1)
+	}
+	limits := bind.Limits
+	if limits == nil {
+		limits = ipPort.Limits
+	}
+	if limits == nil {
+		limits = &DefaultServerLimits
+	}
+	if limits.NetworkReadBuffer > 0 || limits.NetworkWriteBuffer > 0 {
+		sock = &socketBufferListener{Listener: sock, read: limits.NetworkReadBuffer, write: limits.NetworkWriteBuffer}
+	}
+	if limits.MaxClients > 0 {
+		sock = netutil.LimitListener(sock, limits.MaxClients)
+	}
+	var srv HummingbirdServer
+	if ipPort.TLSConfig != nil || (ipPort.CertFile != "" && ipPort.KeyFile != "") {
+		certFile, keyFile := ipPort.CertFile, ipPort.KeyFile
+		tlsConf := ipPort.TLSConfig
+		if tlsConf != nil {
+			tlsConf = tlsConf.Clone()
+			certFile, keyFile = "", ""
+		} else {
+			tlsConf = &tls.Config{
+				PreferServerCipherSuites: true,
+				MinVersion:               tls.VersionTLS12,
+			}
+			if serverType != "proxy" {
+				tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+		}
+		httpServer := newHTTPServer(handler, limits)
+		httpServer.TLSConfig = tlsConf
+		err := http2.ConfigureServer(httpServer, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error enabling http2 on server: %v\n", err)
+			logger.Error("Error enabling http2 on server", zap.Error(err))
+			os.Exit(1)
+		}
+		srv = HummingbirdServer{
+			Server:   httpServer,
+			logger:   logger,
+			finalize: finalize,
+		}
+		go srv.ServeTLS(sock, certFile, keyFile)
+	} else {
+		srv = HummingbirdServer{
+			Server:   newHTTPServer(handler, limits),
+			logger:   logger,
+			finalize: finalize,
+		}
+		go srv.Serve(sock)
+	}
+	return &srv
+}
+
+// fetchRings keeps the rings in /etc/hummingbird up to date from a ring
+// distribution source; each loaded ring reloads itself once its file changes.
+func fetchRings(source, key string, interval time.Duration, logger LowLevelLogger) {
+	if key == "" {
+		logger.Error("Not fetching rings without a [ring-distribution] key to check them with", zap.String("source", source))
+		return
+	}
+	prefix, suffix, err := conf.GetHashPrefixAndSuffix()
+	if err != nil {
+		logger.Error("Not fetching rings without the hash path prefix and suffix", zap.Error(err))
+		return
+	}
+	fetcher := ring.NewRingFetcher(source, key, "/etc/hummingbird", prefix, suffix, nil)
+	for {
+		updated, err := fetcher.FetchOnce()
+		for _, name := range updated {
+			logger.Info("Installed ring", zap.String("ring", name), zap.String("source", source))
+		}
+		if err != nil {
+			logger.Error("Error fetching rings", zap.String("source", source), zap.Error(err))
+		}
+		time.Sleep(interval)
+	}
+}
+
+// adminAddr returns the admin_ip and admin_port for ServeAdmin from the first
+// of configs that sets admin_port, or a port of 0 if none do. The admin
+// endpoints cover the whole process, so they're served once for all of its
+// configs.
+func adminAddr(configs []conf.Config) (string, int) {
+	for _, config := range configs {
+		if adminPort := int(config.GetInt("DEFAULT", "admin_port", 0)); adminPort > 0 {
+			return config.GetDefault("DEFAULT", "admin_ip", "127.0.0.1"), adminPort
+		}
+	}
+	return "", 0
+}
+
 func RunServers(getServer func(conf.Config, *flag.FlagSet, ConfigLoader) (*IpPort, Server, LowLevelLogger, error), flags *flag.FlagSet) {
 	var servers []*HummingbirdServer
 
@@ -543,6 +784,7 @@ func RunServers(getServer func(conf.Config, *flag.FlagSet, ConfigLoader) (*IpPor
 		return
 	}
 	var wg *sync.WaitGroup
+	var fetchLogger LowLevelLogger
 
 	for _, config := range configs {
 		ipPort, server, logger, err := getServer(config, flags, DefaultConfigLoader{})
@@ -558,50 +800,19 @@ func RunServers(getServer func(conf.Config, *flag.FlagSet, ConfigLoader) (*IpPor
 		}
 		metricsPrefix = strings.Replace(metricsPrefix, "-", "_", -1)
 		metricsPrefix = strings.Replace(metricsPrefix, ".", "_", -1)
-		sock, err := RetryListen(ipPort.Ip, ipPort.Port)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error listening: %v\n", err)
-			logger.Error("Error listening", zap.Error(err))
-			os.Exit(1)
-		}
-		var srv HummingbirdServer
-		if ipPort.CertFile != "" && ipPort.KeyFile != "" {
-			tlsConf := &tls.Config{
-				PreferServerCipherSuites: true,
-				MinVersion:               tls.VersionTLS12,
+		handler := server.GetHandler(config, metricsPrefix)
+		for i, bind := range append([]*IpPort{ipPort}, ipPort.ExtraBinds...) {
+			// Only the primary listener finalizes the server on shutdown.
+			finalize := server.Finalize
+			if i > 0 {
+				finalize = func() {}
 			}
-			if server.Type() != "proxy" {
-				tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+			bindHandler := handler
+			if bind.Wrap != nil {
+				bindHandler = bind.Wrap(handler)
 			}
-			httpServer := http.Server{
-				Handler:      server.GetHandler(config, metricsPrefix),
-				ReadTimeout:  24 * time.Hour,
-				WriteTimeout: 24 * time.Hour,
-				TLSConfig:    tlsConf,
-			}
-			err := http2.ConfigureServer(&httpServer, nil)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error enabling http2 on server: %v\n", err)
-				logger.Error("Error enabling http2 on server", zap.Error(err))
-				os.Exit(1)
-			}
-			srv = HummingbirdServer{
-				Server:   &httpServer,
-				logger:   logger,
-				finalize: server.Finalize,
-			}
-			go srv.ServeTLS(sock, ipPort.CertFile, ipPort.KeyFile)
-		} else {
-			srv = HummingbirdServer{
-				Server: &http.Server{
-					Handler:      server.GetHandler(config, metricsPrefix),
-					ReadTimeout:  24 * time.Hour,
-					WriteTimeout: 24 * time.Hour,
-				},
-				logger:   logger,
-				finalize: server.Finalize,
-			}
-			go srv.Serve(sock)
+			servers = append(servers, serve(bind, ipPort, bindHandler, server.Type(), logger, finalize))
+			logger.Info("Server started", zap.String("ip", bind.Ip), zap.Int("port", bind.Port))
 		}
 		ch := server.Background(flags)
 		if ch != nil {
@@ -614,8 +825,15 @@ func RunServers(getServer func(conf.Config, *flag.FlagSet, ConfigLoader) (*IpPor
 				<-ch2
 			}(ch)
 		}
-		servers = append(servers, &srv)
-		logger.Info("Server started", zap.Int("port", ipPort.Port))
+		fetchLogger = logger
+	}
+
+	if adminIP, adminPort := adminAddr(configs); adminPort > 0 && fetchLogger != nil {
+		go ServeAdmin(adminIP, adminPort, fetchLogger)
+	}
+
+	if source, key, interval := conf.GetRingDistribution(); source != "" && fetchLogger != nil {
+		go fetchRings(source, key, time.Duration(interval)*time.Second, fetchLogger)
 	}
 
 	if wg != nil {
@@ -0,0 +1,70 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package srv
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common/conf"
+)
+
+func TestGetServerLimits(t *testing.T) {
+	config, err := conf.StringConfig("[app:proxy-server]\nmax_clients=100\nclient_header_timeout=2.5\nclient_idle_timeout=30\nmax_header_size=16384\nnetwork_write_buffer=1048576\n")
+	require.Nil(t, err)
+	limits := GetServerLimits(config, "app:proxy-server")
+	require.Equal(t, 100, limits.MaxClients)
+	require.Equal(t, 24*time.Hour, limits.ReadTimeout)
+	require.Equal(t, 2500*time.Millisecond, limits.ReadHeaderTimeout)
+	require.Equal(t, 24*time.Hour, limits.WriteTimeout)
+	require.Equal(t, 30*time.Second, limits.IdleTimeout)
+	require.Equal(t, 16384, limits.MaxHeaderBytes)
+	require.Equal(t, 0, limits.NetworkReadBuffer)
+	require.Equal(t, 1048576, limits.NetworkWriteBuffer)
+
+	s := newHTTPServer(http.NotFoundHandler(), limits)
+	require.Equal(t, 2500*time.Millisecond, s.ReadHeaderTimeout)
+	require.Equal(t, 30*time.Second, s.IdleTimeout)
+	require.Equal(t, 16384, s.MaxHeaderBytes)
+
+	config, err = conf.StringConfig("[app:proxy-server]\n")
+	require.Nil(t, err)
+	limits = GetServerLimits(config, "app:proxy-server")
+	require.Equal(t, 0, limits.MaxClients)
+	require.Equal(t, time.Minute, limits.ReadHeaderTimeout)
+	require.Equal(t, time.Duration(0), limits.IdleTimeout)
+}
+
+func TestAdminAddr(t *testing.T) {
+	without, err := conf.StringConfig("[DEFAULT]\n")
+	require.Nil(t, err)
+	with, err := conf.StringConfig("[DEFAULT]\nadmin_port=6060\n")
+	require.Nil(t, err)
+	other, err := conf.StringConfig("[DEFAULT]\nadmin_ip=10.0.0.1\nadmin_port=6061\n")
+	require.Nil(t, err)
+
+	_, port := adminAddr([]conf.Config{without})
+	require.Equal(t, 0, port)
+	// Several configs in one process share the one admin listener.
+	ip, port := adminAddr([]conf.Config{without, with, other})
+	require.Equal(t, "127.0.0.1", ip)
+	require.Equal(t, 6060, port)
+	ip, port = adminAddr([]conf.Config{other, with})
+	require.Equal(t, "10.0.0.1", ip)
+	require.Equal(t, 6061, port)
+}
@@ -0,0 +1,134 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package srv
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/troubling/hummingbird/common/conf"
+)
+
+// BackendSignatureHeader carries the signature one of the cluster's services
+// puts on its requests to another: the Unix time it signed the request at and
+// a hex HMAC-SHA256, keyed with the [backend] signing_key, of the request's
+// method, path, query string, X-Timestamp and X-Backend- headers and that
+// time. The body isn't covered.
+const BackendSignatureHeader = "X-Backend-Signature"
+
+// ErrUnsigned is returned by CheckBackendSignature for a request that carries
+// no signature at all.
+var ErrUnsigned = errors.New("request is not signed")
+
+// signedHeader returns whether a header is covered by backend signatures.
+func signedHeader(key string) bool {
+	key = http.CanonicalHeaderKey(key)
+	return key == "X-Timestamp" || (strings.HasPrefix(key, "X-Backend-") && key != BackendSignatureHeader)
+}
+
+func backendSignature(key string, request *http.Request, ts int64) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%d\n", request.Method, request.URL.Path, request.URL.RawQuery, ts)
+	headers := map[string][]string{}
+	var names []string
+	for k, v := range request.Header {
+		if signedHeader(k) {
+			name := strings.ToLower(k)
+			if _, ok := headers[name]; !ok {
+				names = append(names, name)
+			}
+			headers[name] = append(headers[name], v...)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(mac, "%s:%s\n", name, strings.Join(headers[name], ","))
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignBackendRequest signs request with key as of now. Anything the signature
+// covers mustn't change after it's signed.
+func SignBackendRequest(request *http.Request, key string, now time.Time) {
+	ts := now.Unix()
+	request.Header.Set(BackendSignatureHeader, fmt.Sprintf("%d %s", ts, backendSignature(key, request, ts)))
+}
+
+// CheckBackendSignature returns nil if request carries a signature made with
+// key no more than maxSkew from now, ErrUnsigned if it carries none, or an
+// error saying what's wrong with the one it has.
+func CheckBackendSignature(request *http.Request, key string, maxSkew time.Duration, now time.Time) error {
+	header := request.Header.Get(BackendSignatureHeader)
+	if header == "" {
+		return ErrUnsigned
+	}
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 {
+		return errors.New("malformed signature")
+	}
+	ts, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return errors.New("malformed signature time")
+	}
+	if skew := now.Sub(time.Unix(ts, 0)); skew > maxSkew || skew < -maxSkew {
+		return fmt.Errorf("signature time is %s off", skew)
+	}
+	if !hmac.Equal([]byte(parts[1]), []byte(backendSignature(key, request, ts))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+type signingTransport struct {
+	http.RoundTripper
+	key string
+}
+
+func (t *signingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	// A RoundTripper mustn't change the request it's given, so the signature
+	// goes on a copy.
+	signed := new(http.Request)
+	*signed = *request
+	signed.Header = make(http.Header, len(request.Header)+1)
+	for k, v := range request.Header {
+		signed.Header[k] = v
+	}
+	SignBackendRequest(signed, t.key, time.Now())
+	return t.RoundTripper.RoundTrip(signed)
+}
+
+func newSigningTransport(transport http.RoundTripper, key string) http.RoundTripper {
+	if key == "" {
+		return transport
+	}
+	return &signingTransport{RoundTripper: transport, key: key}
+}
+
+// NewSigningTransport wraps transport so the requests it sends are signed
+// with the [backend] signing_key from hummingbird.conf. With no key set,
+// transport is returned as is.
+func NewSigningTransport(transport http.RoundTripper) http.RoundTripper {
+	key, _, _ := conf.GetBackendSigning()
+	return newSigningTransport(transport, key)
+}
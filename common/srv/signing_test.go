@@ -0,0 +1,96 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package srv
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendSignature(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	req := httptest.NewRequest("PUT", "/sda/1/a/c/o", nil)
+	require.Equal(t, ErrUnsigned, CheckBackendSignature(req, "secret", time.Minute, now))
+
+	SignBackendRequest(req, "secret", now)
+	require.Nil(t, CheckBackendSignature(req, "secret", time.Minute, now))
+	require.Nil(t, CheckBackendSignature(req, "secret", time.Minute, now.Add(59*time.Second)))
+	require.NotNil(t, CheckBackendSignature(req, "secret", time.Minute, now.Add(61*time.Second)))
+	require.NotNil(t, CheckBackendSignature(req, "secret", time.Minute, now.Add(-61*time.Second)))
+	require.NotNil(t, CheckBackendSignature(req, "other", time.Minute, now))
+
+	// the signature only holds for the method and path it was made for
+	moved := httptest.NewRequest("PUT", "/sda/1/a/c/other", nil)
+	moved.Header.Set(BackendSignatureHeader, req.Header.Get(BackendSignatureHeader))
+	require.NotNil(t, CheckBackendSignature(moved, "secret", time.Minute, now))
+	moved = httptest.NewRequest("DELETE", "/sda/1/a/c/o", nil)
+	moved.Header.Set(BackendSignatureHeader, req.Header.Get(BackendSignatureHeader))
+	require.NotNil(t, CheckBackendSignature(moved, "secret", time.Minute, now))
+
+	// nor for other queries or backend headers
+	moved = httptest.NewRequest("PUT", "/sda/1/a/c/o?multipart-manifest=get", nil)
+	moved.Header.Set(BackendSignatureHeader, req.Header.Get(BackendSignatureHeader))
+	require.NotNil(t, CheckBackendSignature(moved, "secret", time.Minute, now))
+	req.Header.Set("X-Backend-Storage-Policy-Index", "1")
+	require.NotNil(t, CheckBackendSignature(req, "secret", time.Minute, now))
+	req.Header.Del("X-Backend-Storage-Policy-Index")
+	req.Header.Set("X-Timestamp", "1500000000.00000")
+	require.NotNil(t, CheckBackendSignature(req, "secret", time.Minute, now))
+	req.Header.Del("X-Timestamp")
+	// other headers can change
+	req.Header.Set("User-Agent", "something else")
+	require.Nil(t, CheckBackendSignature(req, "secret", time.Minute, now))
+
+	req = httptest.NewRequest("PUT", "/sda/1/a/c/o?x=1", nil)
+	req.Header.Set("X-Timestamp", "1500000000.00000")
+	req.Header["x-backend-lowercase"] = []string{"a"}
+	SignBackendRequest(req, "secret", now)
+	require.Nil(t, CheckBackendSignature(req, "secret", time.Minute, now))
+	req.Header["x-backend-lowercase"] = []string{"b"}
+	require.NotNil(t, CheckBackendSignature(req, "secret", time.Minute, now))
+
+	req.Header.Set(BackendSignatureHeader, "garbage")
+	require.NotNil(t, CheckBackendSignature(req, "secret", time.Minute, now))
+}
+
+func TestSigningTransport(t *testing.T) {
+	var sigErr error
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sigErr = CheckBackendSignature(r, "secret", time.Minute, time.Now())
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL+"/sda/1/a%20b/c?format=json&marker=a%2Fb", nil)
+	require.Nil(t, err)
+	req.Header.Set("X-Timestamp", "1500000000.00000")
+	req.Header.Set("X-Backend-Storage-Policy-Index", "1")
+	client := &http.Client{Transport: newSigningTransport(&http.Transport{}, "secret")}
+	resp, err := client.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Nil(t, sigErr)
+	require.Equal(t, "", req.Header.Get(BackendSignatureHeader))
+
+	client = &http.Client{Transport: newSigningTransport(&http.Transport{}, "")}
+	resp, err = client.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, ErrUnsigned, sigErr)
+}
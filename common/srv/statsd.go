@@ -0,0 +1,105 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package srv
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/troubling/hummingbird/common/conf"
+)
+
+// StatsdClient emits metrics over UDP in the statsd line format, using the
+// same log_statsd_* settings and metric names as Swift so existing dashboards
+// and alerts keep working. A nil *StatsdClient is valid and sends nothing.
+type StatsdClient struct {
+	conn       net.Conn
+	prefix     string
+	sampleRate float64
+}
+
+// NewStatsdClient returns a client sending to host:port, with every metric
+// name prefixed by prefix and a dot.
+func NewStatsdClient(host string, port int, prefix string, sampleRate float64) (*StatsdClient, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" {
+		prefix += "."
+	}
+	return &StatsdClient{conn: conn, prefix: prefix, sampleRate: sampleRate}, nil
+}
+
+// NewStatsdClientFromConfig reads log_statsd_host, log_statsd_port,
+// log_statsd_default_sample_rate and log_statsd_metric_prefix from the
+// section. Like Swift, serverName (e.g. "proxy-server") is appended to the
+// metric prefix. It returns nil if log_statsd_host isn't set.
+func NewStatsdClientFromConfig(config conf.Section, serverName string) (*StatsdClient, error) {
+	host := config.GetDefault("log_statsd_host", "")
+	if host == "" {
+		return nil, nil
+	}
+	prefix := serverName
+	if metricPrefix := config.GetDefault("log_statsd_metric_prefix", ""); metricPrefix != "" {
+		prefix = metricPrefix + "." + serverName
+	}
+	client, err := NewStatsdClient(host, int(config.GetInt("log_statsd_port", 8125)), prefix, config.GetFloat("log_statsd_default_sample_rate", 1.0))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to set up statsd client: %v", err)
+	}
+	return client, nil
+}
+
+func (c *StatsdClient) send(metric string, value string, kind string) {
+	if c == nil {
+		return
+	}
+	line := c.prefix + metric + ":" + value + "|" + kind
+	if c.sampleRate < 1 {
+		if rand.Float64() >= c.sampleRate {
+			return
+		}
+		line += "|@" + strconv.FormatFloat(c.sampleRate, 'f', -1, 64)
+	}
+	c.conn.Write([]byte(line))
+}
+
+// Increment adds one to a counter.
+func (c *StatsdClient) Increment(metric string) {
+	c.send(metric, "1", "c")
+}
+
+// UpdateStats adds value to a counter.
+func (c *StatsdClient) UpdateStats(metric string, value int64) {
+	c.send(metric, strconv.FormatInt(value, 10), "c")
+}
+
+// Timing records a duration in milliseconds.
+func (c *StatsdClient) Timing(metric string, d time.Duration) {
+	c.send(metric, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), "ms")
+}
+
+// Close releases the client's socket.
+func (c *StatsdClient) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
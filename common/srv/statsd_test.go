@@ -0,0 +1,61 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package srv
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common/conf"
+)
+
+func TestStatsdClient(t *testing.T) {
+	sock, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer sock.Close()
+	port := sock.LocalAddr().(*net.UDPAddr).Port
+	config, err := conf.StringConfig(fmt.Sprintf("[DEFAULT]\nlog_statsd_host = 127.0.0.1\nlog_statsd_port = %d\nlog_statsd_metric_prefix = hb\n[app:object-server]\n", port))
+	require.Nil(t, err)
+	c, err := NewStatsdClientFromConfig(config.GetSection("app:object-server"), "object-server")
+	require.Nil(t, err)
+	defer c.Close()
+	read := func() string {
+		buf := make([]byte, 1024)
+		sock.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := sock.ReadFrom(buf)
+		require.Nil(t, err)
+		return string(buf[:n])
+	}
+	c.Timing("PUT.timing", 1500*time.Microsecond)
+	require.Equal(t, "hb.object-server.PUT.timing:1.5|ms", read())
+	c.Increment("async_pendings")
+	require.Equal(t, "hb.object-server.async_pendings:1|c", read())
+	c.UpdateStats("object.GET.200.xfer", 1024)
+	require.Equal(t, "hb.object-server.object.GET.200.xfer:1024|c", read())
+}
+
+func TestStatsdClientNotConfigured(t *testing.T) {
+	config, err := conf.StringConfig("[app:object-server]\n")
+	require.Nil(t, err)
+	c, err := NewStatsdClientFromConfig(config.GetSection("app:object-server"), "object-server")
+	require.Nil(t, err)
+	require.Nil(t, c)
+	c.Timing("PUT.timing", time.Second)
+	require.Nil(t, c.Close())
+}
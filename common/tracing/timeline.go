@@ -0,0 +1,76 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type timelineKey struct{}
+
+// TimelineEvent is one backend request made while serving a client request.
+// Start is relative to when the timeline was created.
+type TimelineEvent struct {
+	Method   string        `json:"method"`
+	URL      string        `json:"url"`
+	Status   int           `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Start    time.Duration `json:"start"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Timeline collects the backend fan-out of a single client request, so slow
+// requests can be logged along with where their time went.
+type Timeline struct {
+	start  time.Time
+	lock   sync.Mutex
+	events []TimelineEvent
+}
+
+func NewTimeline() *Timeline {
+	return &Timeline{start: time.Now()}
+}
+
+// Add records a backend request that started at start and finished now.
+func (t *Timeline) Add(method, url string, status int, err error, start time.Time) {
+	ev := TimelineEvent{Method: method, URL: url, Status: status, Start: start.Sub(t.start), Duration: time.Since(start)}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	t.lock.Lock()
+	t.events = append(t.events, ev)
+	t.lock.Unlock()
+}
+
+// Events returns a copy of the events recorded so far.
+func (t *Timeline) Events() []TimelineEvent {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]TimelineEvent(nil), t.events...)
+}
+
+func ContextWithTimeline(ctx context.Context, t *Timeline) context.Context {
+	return context.WithValue(ctx, timelineKey{}, t)
+}
+
+// TimelineFromContext returns the context's Timeline, or nil if there isn't
+// one.
+func TimelineFromContext(ctx context.Context) *Timeline {
+	t, _ := ctx.Value(timelineKey{}).(*Timeline)
+	return t
+}
@@ -21,11 +21,16 @@ import (
 	opentracing "github.com/opentracing/opentracing-go"
 )
 
+// CopySpanFromContext returns a new context carrying only the tracing span
+// and request Timeline of ctx, without its deadline or cancellation.
 func CopySpanFromContext(ctx context.Context) context.Context {
 	newCtx := context.Background()
 	span := opentracing.SpanFromContext(ctx)
 	if span != nil {
 		newCtx = opentracing.ContextWithSpan(newCtx, span)
 	}
+	if t := TimelineFromContext(ctx); t != nil {
+		newCtx = ContextWithTimeline(newCtx, t)
+	}
 	return newCtx
 }
@@ -32,6 +32,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -138,11 +139,18 @@ func GetTimestamp() string {
 	return CanonicalTimestampFromTime(time.Now())
 }
 
-func FormatLastModified(lastModified time.Time) string {
-	if lastModified.Nanosecond() > 0 { // for some reason, Last-Modified is ceil(X-Timestamp)
-		lastModified = lastModified.Truncate(time.Second).Add(time.Second)
+// LastModifiedTime returns the time a Last-Modified header for an X-Timestamp
+// of t shows, which is t rounded up to the second, so conditional requests
+// can be compared against what clients actually saw.
+func LastModifiedTime(t time.Time) time.Time {
+	if t.Nanosecond() > 0 { // for some reason, Last-Modified is ceil(X-Timestamp)
+		t = t.Truncate(time.Second).Add(time.Second)
 	}
-	return lastModified.In(GMT).Format(time.RFC1123)
+	return t
+}
+
+func FormatLastModified(lastModified time.Time) string {
+	return LastModifiedTime(lastModified).In(GMT).Format(time.RFC1123)
 }
 
 func GetTransactionId() string {
@@ -250,6 +258,39 @@ func StandardizeTimestamp(timestamp string) (string, error) {
 	return timestamp, nil
 }
 
+// OffsetTimestamp returns timestamp with its offset increased by n, giving a
+// timestamp that sorts just after it but before any later time.
+func OffsetTimestamp(timestamp string, n int64) (string, error) {
+	timestamp, err := StandardizeTimestamp(timestamp)
+	if err != nil {
+		return "", err
+	}
+	var offset int64
+	if i := strings.Index(timestamp, "_"); i >= 0 {
+		if offset, err = strconv.ParseInt(timestamp[i+1:], 16, 64); err != nil {
+			return "", err
+		}
+		timestamp = timestamp[:i]
+	}
+	return fmt.Sprintf("%s_%016x", timestamp, offset+n), nil
+}
+
+// CompareTimestamps compares two X-Timestamp values, returning -1, 0 or 1 as a
+// is older than, the same as or newer than b. Timestamps with the same time
+// are ordered by their offsets, so "1234567890.12345_0000000000000001" is
+// newer than "1234567890.12345".
+func CompareTimestamps(a, b string) (int, error) {
+	sa, err := StandardizeTimestamp(a)
+	if err != nil {
+		return 0, err
+	}
+	sb, err := StandardizeTimestamp(b)
+	if err != nil {
+		return 0, err
+	}
+	return strings.Compare(strings.TrimSuffix(sa, "_0000000000000000"), strings.TrimSuffix(sb, "_0000000000000000")), nil
+}
+
 // will split out url path the proxy would receive and return map
 // with keys: "vrs", "account", "container", "object"
 func ParseProxyPath(pth string) (pathMap map[string]string, err error) {
@@ -272,17 +313,13 @@ func ParseProxyPath(pth string) (pathMap map[string]string, err error) {
 	return pathMap, err
 }
 
-var buf64kpool = NewFreePool(128)
-
 // CopyQuorum copies data from src to dsts.
 // It behaves mostly like a Copy to a MultiWriter, but it doesn't return an error when a single dst has a write error,
 // only after the number of working dsts drops below quorum.
 func CopyQuorum(src io.Reader, quorum int, dsts ...io.Writer) (int64, error) {
-	buf, ok := buf64kpool.Get().([]byte)
-	if !ok {
-		buf = make([]byte, 64*1024)
-	}
-	defer buf64kpool.Put(buf)
+	pool := CopyBuffers()
+	buf := pool.Get()
+	defer pool.Put(buf)
 
 	var written int64
 	for {
@@ -312,14 +349,145 @@ func CopyQuorum(src io.Reader, quorum int, dsts ...io.Writer) (int64, error) {
 	}
 }
 
-func Copy(src io.Reader, dsts ...io.Writer) (written int64, err error) {
-	var buf []byte
-	var ok bool
-	if buf, ok = buf64kpool.Get().([]byte); !ok {
-		buf = make([]byte, 64*1024)
+// bufferedChunk is one read of CopyQuorumBuffered's src, shared by the dsts
+// it's queued for and returned to its pool once the last of them is done.
+type bufferedChunk struct {
+	buf  []byte
+	n    int
+	refs int32
+	pool *BufferPool
+}
+
+func (c *bufferedChunk) release() {
+	if atomic.AddInt32(&c.refs, -1) == 0 {
+		c.pool.Put(c.buf)
+	}
+}
+
+// bufferedDst is one destination of CopyQuorumBuffered, written to by its own
+// goroutine from the chunks queued for it.
+type bufferedDst struct {
+	chunks chan *bufferedChunk
+	done   chan struct{}
+	failed int32
+	gone   bool
+}
+
+func (d *bufferedDst) drop() {
+	atomic.StoreInt32(&d.failed, 1)
+	d.gone = true
+	close(d.chunks)
+}
+
+// CopyQuorumBuffered is like CopyQuorum, but each dst is written to from its
+// own goroutine through a buffer of about maxBuffered bytes, so one slow dst
+// doesn't hold back the rest. A dst whose buffer stays full for maxWait is
+// dropped, as long as that leaves quorum dsts, and evict is called with its
+// index; if it would break quorum the copy waits for it instead. evict should
+// make any write the dst is blocked in fail. It returns once every remaining
+// dst has been fully written to.
+func CopyQuorumBuffered(src io.Reader, quorum int, maxBuffered int64, maxWait time.Duration, evict func(int), dsts ...io.Writer) (int64, error) {
+	pool := CopyBuffers()
+	slots := int(maxBuffered / int64(pool.Size()))
+	if slots < 1 {
+		slots = 1
+	}
+	bds := make([]*bufferedDst, len(dsts))
+	for i, w := range dsts {
+		bd := &bufferedDst{chunks: make(chan *bufferedChunk, slots), done: make(chan struct{})}
+		bds[i] = bd
+		go func(w io.Writer) {
+			defer close(bd.done)
+			for chunk := range bd.chunks {
+				if atomic.LoadInt32(&bd.failed) == 0 {
+					if n, err := w.Write(chunk.buf[:chunk.n]); err != nil || n != chunk.n {
+						atomic.StoreInt32(&bd.failed, 1)
+					}
+				}
+				chunk.release()
+			}
+		}(w)
+	}
+	working := func() int {
+		count := 0
+		for _, bd := range bds {
+			if atomic.LoadInt32(&bd.failed) == 0 {
+				count++
+			}
+		}
+		return count
+	}
+	finish := func() {
+		for _, bd := range bds {
+			if !bd.gone {
+				bd.gone = true
+				close(bd.chunks)
+			}
+		}
+		for _, bd := range bds {
+			<-bd.done
+		}
+	}
+
+	var written int64
+	for {
+		// Each read gets its own buffer, since the dsts may still be writing
+		// earlier ones.
+		// One ref is held here until the chunk's been queued everywhere.
+		chunk := &bufferedChunk{buf: pool.Get(), refs: 1, pool: pool}
+		nr, rerr := src.Read(chunk.buf)
+		chunk.n = nr
+		if nr > 0 {
+			for i, bd := range bds {
+				if bd.gone {
+					continue
+				}
+				atomic.AddInt32(&chunk.refs, 1)
+				select {
+				case bd.chunks <- chunk:
+					continue
+				default:
+				}
+				if working()-1 < quorum {
+					bd.chunks <- chunk
+					continue
+				}
+				timer := time.NewTimer(maxWait)
+				select {
+				case bd.chunks <- chunk:
+				case <-timer.C:
+					chunk.release()
+					bd.drop()
+					evict(i)
+				}
+				timer.Stop()
+			}
+		}
+		chunk.release()
+		if nr > 0 && working() < quorum {
+			finish()
+			return written, errors.New("Too many writers failed.")
+		}
+		if rerr == io.EOF {
+			finish()
+			// Writers may also have failed while draining their buffers.
+			if working() < quorum {
+				return written, errors.New("Too many writers failed.")
+			}
+			return written, nil
+		} else if rerr != nil {
+			finish()
+			return written, rerr
+		}
+		written += int64(nr)
 	}
+}
+
+func Copy(src io.Reader, dsts ...io.Writer) (written int64, err error) {
+	pool := CopyBuffers()
+	buf := pool.Get()
 	written, err = io.CopyBuffer(io.MultiWriter(dsts...), src, buf)
-	buf64kpool.Put(buf)
+	pool.Put(buf)
 	return
 }
 
@@ -369,6 +537,24 @@ func (k *KeyedLimit) Acquire(key string, force bool) int64 {
 	}
 }
 
+// AcquireReserving is like Acquire, but also fails while taking a slot would
+// leave fewer than reserve of the key's limit free, keeping those for callers
+// using Acquire.
+func (k *KeyedLimit) AcquireReserving(key string, reserve int64) int64 {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if k.locked[key] {
+		return -1
+	}
+	v := k.inUse[key]
+	if (k.limitPerKey > 0 && v+reserve >= k.limitPerKey) || (k.totalLimit > 0 && k.totalUse > k.totalLimit) {
+		return v
+	}
+	k.inUse[key] += 1
+	k.totalUse += 1
+	return 0
+}
+
 func (k *KeyedLimit) Release(key string) {
 	k.lock.Lock()
 	k.inUse[key] -= 1
@@ -500,11 +686,14 @@ func IsOriginAllowed(allowedOriginHeader, requestOrigin string) bool {
 	return false
 }
 
+// ParseIfMatch returns the etags in an If-Match or If-None-Match header,
+// without quotes. Weak etags are included as their strong equivalents, since
+// the proxy may hand out either form of the same etag.
 func ParseIfMatch(s string) map[string]bool {
 	r := make(map[string]bool)
 	if len(strings.Trim(s, " ")) > 0 {
 		for _, ss := range strings.Split(s, ",") {
-			if sst := strings.Trim(ss, " "); sst != "" {
+			if sst := strings.TrimPrefix(strings.Trim(ss, " "), "W/"); sst != "" {
 				if sst[0] == '"' && sst[len(sst)-1] == '"' {
 					r[sst[1:len(sst)-1]] = true
 				} else {
@@ -17,6 +17,7 @@ package common
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -133,6 +134,39 @@ func TestParseDate(t *testing.T) {
 
 }
 
+func TestLastModified(t *testing.T) {
+	ts, err := ParseDate("1136214245.1234")
+	require.Nil(t, err)
+	assert.Equal(t, int64(1136214246), LastModifiedTime(ts).Unix())
+	assert.Equal(t, "Mon, 02 Jan 2006 15:04:06 GMT", FormatLastModified(ts))
+	ts, err = ParseDate("1136214245")
+	require.Nil(t, err)
+	assert.Equal(t, ts, LastModifiedTime(ts))
+	assert.Equal(t, "Mon, 02 Jan 2006 15:04:05 GMT", FormatLastModified(ts))
+}
+
+func TestParseIfMatch(t *testing.T) {
+	assert.Equal(t, map[string]bool{}, ParseIfMatch(" "))
+	assert.Equal(t, map[string]bool{"abc": true, "def": true, "*": true}, ParseIfMatch(`"abc", W/"def", *`))
+}
+
+func TestKeyedLimitAcquireReserving(t *testing.T) {
+	k := NewKeyedLimit(3, 0)
+	assert.Equal(t, int64(0), k.AcquireReserving("sda", 1))
+	assert.Equal(t, int64(0), k.AcquireReserving("sda", 1))
+	assert.Equal(t, int64(2), k.AcquireReserving("sda", 1))
+	assert.Equal(t, int64(0), k.Acquire("sda", false))
+	k.Release("sda")
+	k.Lock("sdb")
+	assert.Equal(t, int64(-1), k.AcquireReserving("sdb", 0))
+}
+
+func TestRequestPriority(t *testing.T) {
+	assert.Equal(t, PriorityInteractive, RequestPriority(http.Header{}))
+	assert.Equal(t, PriorityBackground, RequestPriority(http.Header{PriorityHeader: {"Background"}}))
+	assert.Equal(t, PriorityInteractive, RequestPriority(http.Header{PriorityHeader: {"urgent"}}))
+}
+
 func TestStandardizeTimestamp(t *testing.T) {
 	//Setup tests with individual data
 	tests := []struct {
@@ -153,6 +187,41 @@ func TestStandardizeTimestamp(t *testing.T) {
 
 }
 
+func TestOffsetTimestamp(t *testing.T) {
+	ts, err := OffsetTimestamp("12345.12345", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "0000012345.12345_0000000000000001", ts)
+	ts, err = OffsetTimestamp(ts, 15)
+	assert.Nil(t, err)
+	assert.Equal(t, "0000012345.12345_0000000000000010", ts)
+	cmp, err := CompareTimestamps(ts, "12345.12346")
+	assert.Nil(t, err)
+	assert.Equal(t, -1, cmp)
+	_, err = OffsetTimestamp("invalid", 1)
+	assert.NotNil(t, err)
+}
+
+func TestCompareTimestamps(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"12345.12345", "12345.12345", 0},
+		{"12345.12345", "12345.12346", -1},
+		{"12345.12345_0000000000000001", "12345.12345", 1},
+		{"12345.12345_0000000000000000", "12345.12345", 0},
+		{"12345.12345_000000000000000a", "12345.12345_0000000000000002", 1},
+		{"12345.12345_00000000000000ff", "12345.12346", -1},
+	}
+	for _, test := range tests {
+		result, err := CompareTimestamps(test.a, test.b)
+		require.Nil(t, err)
+		require.Equal(t, test.expected, result, "%s vs %s", test.a, test.b)
+	}
+	_, err := CompareTimestamps("invalidTimestamp", "12345.12345")
+	require.NotNil(t, err)
+}
+
 func TestStandardizeTimestamp_invalidTimestamp(t *testing.T) {
 	//Setup test data
 	tests := []struct {
@@ -339,3 +408,57 @@ func TestGetTransactionId(t *testing.T) {
 	require.Nil(t, err)
 	require.True(t, matched)
 }
+
+type stuckWriter struct {
+	release chan struct{}
+}
+
+func (w *stuckWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return 0, errors.New("evicted")
+}
+
+type slowWriter struct {
+	bytes.Buffer
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	return w.Buffer.Write(p)
+}
+
+type failingWriter struct{}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("failed")
+}
+
+func TestCopyQuorumBuffered(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+
+	// A stuck writer is evicted rather than holding up the others.
+	var a, b bytes.Buffer
+	stuck := &stuckWriter{release: make(chan struct{})}
+	evicted := []int{}
+	_, err := CopyQuorumBuffered(bytes.NewReader(data), 2, 64*1024, 10*time.Millisecond, func(i int) {
+		evicted = append(evicted, i)
+		close(stuck.release)
+	}, &a, stuck, &b)
+	require.Nil(t, err)
+	require.Equal(t, []int{1}, evicted)
+	require.Equal(t, data, a.Bytes())
+	require.Equal(t, data, b.Bytes())
+
+	// Slow writers are waited for when evicting them would break quorum.
+	var c bytes.Buffer
+	slow := &slowWriter{}
+	_, err = CopyQuorumBuffered(bytes.NewReader(data), 2, 64*1024, 10*time.Millisecond, func(i int) {
+		t.Fatalf("evicted %d", i)
+	}, &c, slow)
+	require.Nil(t, err)
+	require.Equal(t, data, c.Bytes())
+	require.Equal(t, data, slow.Bytes())
+
+	_, err = CopyQuorumBuffered(bytes.NewReader(data), 2, 64*1024, 10*time.Millisecond, func(i int) {}, &bytes.Buffer{}, failingWriter{}, failingWriter{})
+	require.NotNil(t, err)
+}
@@ -35,8 +35,14 @@ var (
 	ErrorInvalidMetadata = fmt.Errorf("Invalid metadata value")
 	// ErrorPolicyConflict is returned when an operation conflicts with the container's existing policy.
 	ErrorPolicyConflict = fmt.Errorf("Policy conflicts with existing value")
+	// ErrorNotDeleted is returned when restoring a container that isn't deleted.
+	ErrorNotDeleted = fmt.Errorf("Container is not deleted")
 )
 
+// AllPolicies is the storagePolicyIndex for ListObjects to list every
+// policy's objects.
+const AllPolicies = -1
+
 // ContainerInfo represents the container_info database record - basic information about the container.
 type ContainerInfo struct {
 	Account                 string              `json:"account"`
@@ -51,6 +57,7 @@ type ContainerInfo struct {
 	ReportedDeleteTimestamp string              `json:"-"`
 	ReportedObjectCount     int64               `json:"-"`
 	ReportedBytesUsed       int64               `json:"-"`
+	ReportedLastModified    string              `json:"-"`
 	Hash                    string              `json:"hash"`
 	ID                      string              `json:"id"`
 	XContainerSyncPoint1    string              `json:"-"`
@@ -59,6 +66,7 @@ type ContainerInfo struct {
 	RawMetadata             string              `json:"metadata"`
 	Metadata                map[string][]string `json:"-"`
 	MaxRow                  int64               `json:"max_row"`
+	LastModified            string              `json:"-"`
 	invalid                 bool
 	updated                 time.Time
 	// This row isn't populated by GetInfo, it only exists for the times this is
@@ -74,6 +82,10 @@ type ObjectListingRecord struct {
 	Size         int64    `xml:"bytes" json:"bytes"`
 	ContentType  string   `xml:"content_type" json:"content_type"`
 	ETag         string   `xml:"hash" json:"hash"`
+	// StoragePolicyIndex and State are only set in backend listings, which
+	// cover all of a container's policies for admin tools.
+	StoragePolicyIndex *int   `xml:"storage_policy_index,omitempty" json:"storage_policy_index,omitempty"`
+	State              string `xml:"state,omitempty" json:"state,omitempty"`
 }
 
 // SubdirListingRecord is the struct used for serializing subdirs in json and xml container listings.
@@ -94,6 +106,7 @@ type ObjectRecord struct {
 	Deleted            int     `json:"deleted"`
 	StoragePolicyIndex int     `json:"storage_policy_index"`
 	Expires            *string `json:"expires"`
+	State              *string `json:"state,omitempty"`
 }
 
 // SyncRecord represents a row in the incoming_sync table.  It is used by replication.
@@ -108,16 +121,27 @@ type Container interface {
 	GetInfo() (*ContainerInfo, error)
 	// IsDeleted returns true if the container has been deleted.
 	IsDeleted() (bool, error)
-	// Delete deletes the container.
-	Delete(timestamp string) error
-	// ListObjects lists the container's object entries.
+	// Delete deletes the container. With keepMetadata, the values of the
+	// metadata it tombstones are kept for Restore.
+	Delete(timestamp string, keepMetadata bool) error
+	// Restore undeletes a container deleted no earlier than deletedSince,
+	// bringing back the metadata its Delete kept.
+	Restore(timestamp string, deletedSince string) error
+	// ListObjects lists the container's object entries. A storagePolicyIndex
+	// of AllPolicies lists the entries for every policy, each with its policy
+	// and state.
 	ListObjects(limit int, marker string, endMarker string, prefix string, delimiter string, path *string, reverse bool, storagePolicyIndex int) ([]interface{}, error)
+	// StreamObjects is ListObjects, passing each entry to emit as it's read
+	// instead of returning them all at once. An error from emit stops the
+	// listing and is returned.
+	StreamObjects(limit int, marker string, endMarker string, prefix string, delimiter string, path *string, reverse bool, storagePolicyIndex int, emit func(record interface{}) error) error
 	// GetMetadata returns the container's current metadata.
 	GetMetadata() (map[string]string, error)
 	// UpdateMetadata applies updates to the container's metadata.
 	UpdateMetadata(updates map[string][]string, timestamp string) error
-	// PutObject adds a new object to the container.
-	PutObject(name string, timestamp string, size int64, contentType string, etag string, storagePolicyIndex int, expires string) error
+	// PutObject adds a new object to the container. The state is whatever
+	// the object server reported about how the object is stored, if anything.
+	PutObject(name string, timestamp string, size int64, contentType string, etag string, storagePolicyIndex int, expires string, state string) error
 	// DeleteObject deletes an object from the container.
 	DeleteObject(name string, timestamp string, storagePolicyIndex int) error
 	// ID returns a unique identifier for the container.
@@ -150,7 +174,11 @@ type ReplicableContainer interface {
 	// RingHash returns the container's ring hash.
 	RingHash() string
 	// Reported records the information as having been reported to an account database.
-	Reported(putTimestamp, deleteTimestamp string, objectCount, bytesUsed int64) error
+	Reported(putTimestamp, deleteTimestamp string, objectCount, bytesUsed int64, lastModified string) error
+	// ReconcilerSyncPoint returns the last ROWID checked for objects in the wrong storage policy.
+	ReconcilerSyncPoint() (int64, error)
+	// SetReconcilerSyncPoint records the last ROWID checked for objects in the wrong storage policy.
+	SetReconcilerSyncPoint(point int64) error
 }
 
 // ContainerEngine is the interface of an object that creates and returns containers.
@@ -123,12 +123,18 @@ func (f fakeDatabase) GetInfo() (*ContainerInfo, error) {
 func (f fakeDatabase) IsDeleted() (bool, error) {
 	return false, errors.New("")
 }
-func (f fakeDatabase) Delete(timestamp string) error {
+func (f fakeDatabase) Delete(timestamp string, keepMetadata bool) error {
+	return errors.New("")
+}
+func (f fakeDatabase) Restore(timestamp string, deletedSince string) error {
 	return errors.New("")
 }
 func (f fakeDatabase) ListObjects(limit int, marker string, endMarker string, prefix string, delimiter string, path *string, reverse bool, storagePolicyIndex int) ([]interface{}, error) {
 	return nil, errors.New("")
 }
+func (f fakeDatabase) StreamObjects(limit int, marker string, endMarker string, prefix string, delimiter string, path *string, reverse bool, storagePolicyIndex int, emit func(record interface{}) error) error {
+	return errors.New("")
+}
 func (f fakeDatabase) GetMetadata() (map[string]string, error) {
 	return nil, errors.New("")
 }
@@ -171,14 +177,20 @@ func (f fakeDatabase) CleanupTombstones(reclaimAge int64) error {
 func (f fakeDatabase) CheckSyncLink() error {
 	return errors.New("")
 }
-func (f fakeDatabase) PutObject(name string, timestamp string, size int64, contentType string, etag string, storagePolicyIndex int, expires string) error {
+func (f fakeDatabase) PutObject(name string, timestamp string, size int64, contentType string, etag string, storagePolicyIndex int, expires string, state string) error {
 	return errors.New("")
 }
 func (f fakeDatabase) DeleteObject(name string, timestamp string, storagePolicyIndex int) error {
 	return errors.New("")
 }
 
-func (f fakeDatabase) Reported(putTimestamp, deleteTimestamp string, objectCount, bytesUsed int64) error {
+func (f fakeDatabase) Reported(putTimestamp, deleteTimestamp string, objectCount, bytesUsed int64, lastModified string) error {
+	return errors.New("")
+}
+func (f fakeDatabase) ReconcilerSyncPoint() (int64, error) {
+	return 0, errors.New("")
+}
+func (f fakeDatabase) SetReconcilerSyncPoint(point int64) error {
 	return errors.New("")
 }
 
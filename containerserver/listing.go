@@ -0,0 +1,130 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package containerserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+)
+
+// listingWriter writes a container listing to a response as its records are
+// read from the database, so even a listing of millions of objects isn't
+// held in memory. Nothing is written until the first record, so an error
+// before then can still be sent as an error status.
+type listingWriter struct {
+	writer    http.ResponseWriter
+	format    string
+	container string
+	count     int
+}
+
+func (lw *listingWriter) header() string {
+	switch lw.format {
+	case "json":
+		return "["
+	case "xml":
+		var name bytes.Buffer
+		xml.EscapeText(&name, []byte(lw.container))
+		return "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<container name=\"" + name.String() + "\">"
+	}
+	return ""
+}
+
+func (lw *listingWriter) footer() string {
+	switch lw.format {
+	case "json":
+		return "]"
+	case "xml":
+		return "</container>"
+	}
+	return ""
+}
+
+func (lw *listingWriter) contentType() string {
+	switch lw.format {
+	case "json":
+		return "application/json; charset=utf-8"
+	case "xml":
+		return "application/xml; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// Write sends one ObjectListingRecord or SubdirListingRecord.
+func (lw *listingWriter) Write(record interface{}) error {
+	var output []byte
+	var err error
+	switch lw.format {
+	case "json":
+		output, err = json.Marshal(record)
+	case "xml":
+		output, err = xml.Marshal(record)
+	default:
+		if or, ok := record.(*ObjectListingRecord); ok {
+			output = []byte(or.Name + "\n")
+		} else if sr, ok := record.(*SubdirListingRecord); ok {
+			output = []byte(sr.Name + "\n")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if lw.count == 0 {
+		lw.writer.Header().Set("Content-Type", lw.contentType())
+		lw.writer.WriteHeader(http.StatusOK)
+		if _, err := lw.writer.Write([]byte(lw.header())); err != nil {
+			return err
+		}
+	} else if lw.format == "json" {
+		output = append([]byte{','}, output...)
+	}
+	lw.count++
+	_, err = lw.writer.Write(output)
+	return err
+}
+
+// Close finishes the listing. An empty listing is sent whole, with its
+// Content-Length; a text listing with nothing in it is a 204.
+func (lw *listingWriter) Close() {
+	if lw.count > 0 {
+		lw.writer.Write([]byte(lw.footer()))
+		return
+	}
+	lw.writer.Header().Set("Content-Type", lw.contentType())
+	if lw.format == "text" {
+		lw.writer.Header().Set("Content-Length", "0")
+		lw.writer.WriteHeader(http.StatusNoContent)
+		return
+	}
+	output := lw.header() + lw.footer()
+	lw.writer.Header().Set("Content-Length", strconv.Itoa(len(output)))
+	lw.writer.WriteHeader(http.StatusOK)
+	lw.writer.Write([]byte(output))
+}
+
+// Abort cuts off a listing that failed partway through. The status has
+// already been sent, so the connection is closed to keep the client from
+// mistaking what it got for the whole listing.
+func (lw *listingWriter) Abort() {
+	if hijacker, ok := lw.writer.(http.Hijacker); ok {
+		if conn, _, err := hijacker.Hijack(); err == nil {
+			conn.Close()
+		}
+	}
+}
@@ -0,0 +1,72 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package containerserver
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListingWriter(t *testing.T) {
+	records := []interface{}{
+		&ObjectListingRecord{Name: "a<b", LastModified: "2018-01-01T00:00:00.000000", Size: 2, ContentType: "text/plain", ETag: "abc"},
+		&SubdirListingRecord{Name2: "d/", Name: "d/"},
+	}
+	write := func(format string, records []interface{}) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		lw := &listingWriter{writer: w, format: format, container: `c"1`}
+		for _, record := range records {
+			require.Nil(t, lw.Write(record))
+		}
+		lw.Close()
+		return w
+	}
+
+	w := write("json", records)
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, "", w.Header().Get("Content-Length"))
+	expected, err := json.Marshal(records)
+	require.Nil(t, err)
+	require.Equal(t, string(expected), w.Body.String())
+
+	w = write("xml", records)
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, "application/xml; charset=utf-8", w.Header().Get("Content-Type"))
+	type container struct {
+		XMLName xml.Name `xml:"container"`
+		Name    string   `xml:"name,attr"`
+		Objects []interface{}
+	}
+	expected, err = xml.Marshal(&container{Name: `c"1`, Objects: records})
+	require.Nil(t, err)
+	require.Equal(t, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"+string(expected), w.Body.String())
+
+	w = write("text", records)
+	require.Equal(t, "a<b\nd/\n", w.Body.String())
+
+	w = write("json", nil)
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, "2", w.Header().Get("Content-Length"))
+	require.Equal(t, "[]", w.Body.String())
+
+	w = write("text", nil)
+	require.Equal(t, 204, w.Code)
+	require.Equal(t, "", w.Body.String())
+}
@@ -0,0 +1,153 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package containerserver
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/troubling/hummingbird/common"
+)
+
+// MisplacedObjectsAccount holds the queue of object rows found in a
+// container under another storage policy than the container's own, which
+// happens when replicas of a container are created with different policies.
+// Container replicators add to the queue and the reconciler in andrewd works
+// through it, moving the objects into their container's policy.
+const MisplacedObjectsAccount = ".misplaced_objects"
+
+// The content types of queue entries, saying whether the misplaced row was an
+// object or a tombstone. Each entry's etag is the row's timestamp.
+const (
+	MisplacedPut    = "application/x-put"
+	MisplacedDelete = "application/x-delete"
+)
+
+// misplacedContainerSpan is how many seconds of object timestamps share a
+// queue container.
+const misplacedContainerSpan = 3600
+
+// MisplacedQueueContainer returns the queue container for an object row with
+// the given timestamp.
+func MisplacedQueueContainer(timestamp string) string {
+	ts, err := strconv.ParseFloat(strings.SplitN(timestamp, "_", 2)[0], 64)
+	if err != nil {
+		ts = 0
+	}
+	return strconv.FormatInt(int64(ts)/misplacedContainerSpan*misplacedContainerSpan, 10)
+}
+
+// MisplacedQueueEntry names the queue entry for an object found in the given
+// policy.
+func MisplacedQueueEntry(policy int, account, container, obj string) string {
+	return fmt.Sprintf("%d:/%s/%s/%s", policy, account, container, obj)
+}
+
+// ParseMisplacedQueueEntry splits a queue entry's name back into the policy
+// the object was found in and its path.
+func ParseMisplacedQueueEntry(name string) (policy int, account, container, obj string, err error) {
+	parts := strings.SplitN(name, ":", 2)
+	if len(parts) != 2 {
+		return 0, "", "", "", fmt.Errorf("Invalid misplaced object entry %q", name)
+	}
+	if policy, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, "", "", "", fmt.Errorf("Invalid misplaced object entry %q", name)
+	}
+	path := strings.SplitN(parts[1], "/", 4)
+	if len(path) != 4 || path[0] != "" || path[1] == "" || path[2] == "" || path[3] == "" {
+		return 0, "", "", "", fmt.Errorf("Invalid misplaced object entry %q", name)
+	}
+	return policy, path[1], path[2], path[3], nil
+}
+
+// queueMisplaced adds the container's rows that aren't in its storage policy
+// to the misplaced objects queue, picking up after the last row it queued.
+func (rd *replicationDevice) queueMisplaced(c ReplicableContainer) error {
+	info, err := c.GetInfo()
+	if err != nil {
+		return err
+	}
+	if info.Account == MisplacedObjectsAccount {
+		return nil
+	}
+	point, err := c.ReconcilerSyncPoint()
+	if err != nil {
+		return err
+	}
+	for {
+		records, err := c.ItemsSince(point, int(rd.r.perUsync))
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		for _, record := range records {
+			if record.StoragePolicyIndex != info.StoragePolicyIndex {
+				if err := rd.queueMisplacedRecord(info, record); err != nil {
+					return err
+				}
+				rd.i.incrementStat("misplaced")
+			}
+			point = record.Rowid
+		}
+		if err := c.SetReconcilerSyncPoint(point); err != nil {
+			return err
+		}
+	}
+}
+
+func (rd *replicationDevice) queueMisplacedRecord(info *ContainerInfo, record *ObjectRecord) error {
+	container := MisplacedQueueContainer(record.CreatedAt)
+	obj := MisplacedQueueEntry(record.StoragePolicyIndex, info.Account, info.Container, record.Name)
+	contentType := MisplacedPut
+	if record.Deleted == 1 {
+		contentType = MisplacedDelete
+	}
+	partition := rd.r.Ring.GetPartition(MisplacedObjectsAccount, container, "")
+	nodes := rd.r.Ring.GetNodes(partition)
+	successes := 0
+	for _, dev := range nodes {
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
+			common.Urlencode(MisplacedObjectsAccount), common.Urlencode(container), common.Urlencode(obj))
+		req, err := http.NewRequest("PUT", url, nil)
+		if err != nil {
+			continue
+		}
+		req.Header.Set("X-Timestamp", record.CreatedAt)
+		req.Header.Set("X-Size", "0")
+		req.Header.Set("X-Content-Type", contentType)
+		req.Header.Set("X-Etag", record.CreatedAt)
+		req.Header.Set("X-Backend-Storage-Policy-Index", "0")
+		resp, err := rd.r.client.Do(req)
+		if err != nil {
+			continue
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode/100 == 2 {
+			successes++
+		}
+	}
+	if successes < len(nodes)/2+1 {
+		return fmt.Errorf("Unable to queue misplaced object %s", obj)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package containerserver
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/test"
+)
+
+func TestMisplacedQueueContainer(t *testing.T) {
+	require.Equal(t, "1499997600", MisplacedQueueContainer("1500000000.00000"))
+	require.Equal(t, "1500001200", MisplacedQueueContainer("1500003599.99999_0000000000000001"))
+	require.Equal(t, "1500004800", MisplacedQueueContainer("1500004800.00000"))
+}
+
+func TestMisplacedQueueEntry(t *testing.T) {
+	name := MisplacedQueueEntry(2, "a", "c", "some/obj")
+	require.Equal(t, "2:/a/c/some/obj", name)
+	policy, account, container, obj, err := ParseMisplacedQueueEntry(name)
+	require.Nil(t, err)
+	require.Equal(t, 2, policy)
+	require.Equal(t, "a", account)
+	require.Equal(t, "c", container)
+	require.Equal(t, "some/obj", obj)
+	for _, bad := range []string{"", "2", "x:/a/c/o", "2:a/c/o", "2:/a/c", "2://c/o", "2:/a/c/"} {
+		_, _, _, _, err = ParseMisplacedQueueEntry(bad)
+		require.NotNil(t, err, bad)
+	}
+}
+
+func TestQueueMisplaced(t *testing.T) {
+	db, _, cleanup, err := createTestDatabase("1500000000.00000")
+	require.Nil(t, err)
+	defer cleanup()
+	require.Nil(t, db.MergeItems([]*ObjectRecord{
+		{Name: "o1", CreatedAt: "1500000001.00000", StoragePolicyIndex: 0},
+		{Name: "o2", CreatedAt: "1500000002.00000", StoragePolicyIndex: 1},
+		{Name: "o3", CreatedAt: "1500000003.00000", StoragePolicyIndex: 1, Deleted: 1},
+	}, ""))
+
+	var lock sync.Mutex
+	var queued []*http.Request
+	dev, cleanupServer := testServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		queued = append(queued, r)
+		lock.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer cleanupServer()
+	r := &Replicator{client: http.DefaultClient, perUsync: 2, Ring: &test.FakeRing{MockDevices: []*ring.Device{dev, dev, dev}}}
+	rd := newTestReplicationDevice(&ring.Device{}, r)
+	require.Nil(t, rd.rd.queueMisplaced(db))
+	require.Equal(t, 6, len(queued))
+	require.Equal(t, "/sdb/0/.misplaced_objects/1499997600/1:/a/c/o2", queued[0].URL.Path)
+	require.Equal(t, "1500000002.00000", queued[0].Header.Get("X-Timestamp"))
+	require.Equal(t, "1500000002.00000", queued[0].Header.Get("X-Etag"))
+	require.Equal(t, MisplacedPut, queued[0].Header.Get("X-Content-Type"))
+	require.Equal(t, "/sdb/0/.misplaced_objects/1499997600/1:/a/c/o3", queued[3].URL.Path)
+	require.Equal(t, MisplacedDelete, queued[3].Header.Get("X-Content-Type"))
+	point, err := db.ReconcilerSyncPoint()
+	require.Nil(t, err)
+	require.Equal(t, int64(3), point)
+
+	// Rows already checked aren't queued again.
+	queued = nil
+	require.Nil(t, rd.rd.queueMisplaced(db))
+	require.Equal(t, 0, len(queued))
+}
+
+func TestQueueMisplacedFailure(t *testing.T) {
+	db, _, cleanup, err := createTestDatabase("1500000000.00000")
+	require.Nil(t, err)
+	defer cleanup()
+	require.Nil(t, db.MergeItems([]*ObjectRecord{
+		{Name: "o1", CreatedAt: "1500000001.00000", StoragePolicyIndex: 1},
+	}, ""))
+	dev, cleanupServer := testServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer cleanupServer()
+	r := &Replicator{client: http.DefaultClient, perUsync: 2, Ring: &test.FakeRing{MockDevices: []*ring.Device{dev, dev, dev}}}
+	rd := newTestReplicationDevice(&ring.Device{}, r)
+	require.NotNil(t, rd.rd.queueMisplaced(db))
+	point, err := db.ReconcilerSyncPoint()
+	require.Nil(t, err)
+	require.Equal(t, int64(-1), point)
+}
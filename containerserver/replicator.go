@@ -78,7 +78,9 @@ type Replicator struct {
 	client            common.HTTPClient
 	runningDevices    map[string]*replicationDevice
 	reclaimAge        int64
+	deviceFilter      ring.DeviceFilter
 	logLevel          zap.AtomicLevel
+	metricsScope      tally.Scope
 	metricsCloser     io.Closer
 	traceCloser       io.Closer
 	tracer            opentracing.Tracer
@@ -119,8 +121,8 @@ func (rd *replicationDevice) sendReplicationMessage(dev *ring.Device, part uint6
 	if err != nil {
 		return 0, nil, err
 	}
-	req, err := http.NewRequest("REPLICATE", fmt.Sprintf("%s://%s:%d/%s/%d/%s", dev.Scheme,
-		dev.Ip, dev.Port, dev.Device, part, ringHash), bytes.NewBuffer(body))
+	req, err := http.NewRequest("REPLICATE", fmt.Sprintf("%s://%s:%d%s/%s/%d/%s", dev.Scheme,
+		dev.Ip, dev.Port, dev.PathPrefix, dev.Device, part, ringHash), bytes.NewBuffer(body))
 	if err != nil {
 		return 0, nil, err
 	}
@@ -164,7 +166,7 @@ func (rd *replicationDevice) rsync(dev *ring.Device, c ReplicableContainer, part
 		return fmt.Errorf("Error opening databae: %v", err)
 	}
 	defer release()
-	req, err := http.NewRequest("PUT", fmt.Sprintf("%s://%s:%d/%s/tmp/%s", dev.Scheme, dev.Ip, dev.Port, dev.Device, tmpFilename), fp)
+	req, err := http.NewRequest("PUT", fmt.Sprintf("%s://%s:%d%s/%s/tmp/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, tmpFilename), fp)
 	if err != nil {
 		return fmt.Errorf("creating request: %v", err)
 	}
@@ -246,7 +248,8 @@ func (rd *replicationDevice) replicateDatabaseToDevice(dev *ring.Device, c Repli
 		if info.PutTimestamp > info.ReportedPutTimestamp ||
 			info.DeleteTimestamp > info.ReportedDeleteTimestamp ||
 			info.ObjectCount != info.ReportedObjectCount ||
-			info.BytesUsed != info.ReportedBytesUsed {
+			info.BytesUsed != info.ReportedBytesUsed ||
+			info.LastModified > info.ReportedLastModified {
 
 			accountPartition := rd.r.accountRing.GetPartition(info.Account, "", "")
 			accountNodes := rd.r.accountRing.GetNodes(accountPartition)
@@ -256,6 +259,7 @@ func (rd *replicationDevice) replicateDatabaseToDevice(dev *ring.Device, c Repli
 				info,
 				accountNode.Scheme,
 				fmt.Sprintf("%s:%d", accountNode.Ip, accountNode.Port),
+				accountNode.PathPrefix,
 				accountNode.Device,
 				fmt.Sprintf("%d", accountPartition),
 				info.Account,
@@ -264,7 +268,7 @@ func (rd *replicationDevice) replicateDatabaseToDevice(dev *ring.Device, c Repli
 				false,
 				rd.r.client,
 			) == nil {
-				if err = c.Reported(info.PutTimestamp, info.DeleteTimestamp, info.ObjectCount, info.BytesUsed); err != nil {
+				if err = c.Reported(info.PutTimestamp, info.DeleteTimestamp, info.ObjectCount, info.BytesUsed, info.LastModified); err != nil {
 					rd.r.logger.Error("Could not update reported info", zap.Error(err), zap.String("RingHash", c.RingHash()))
 				}
 			}
@@ -307,7 +311,7 @@ func (rd *replicationDevice) replicateDatabase(dbFile string) error {
 		return fmt.Errorf("Bad partition: %s", parts)
 	}
 	devices, handoff := rd.r.Ring.GetJobNodes(part, rd.dev.Id)
-	moreNodes := rd.r.Ring.GetMoreNodes(part)
+	moreNodes := ring.FilterMoreNodes(rd.r.Ring.GetMoreNodes(part), rd.r.deviceFilter)
 	c, err := sqliteOpenContainer(dbFile)
 	if err != nil {
 		return err
@@ -319,6 +323,11 @@ func (rd *replicationDevice) replicateDatabase(dbFile string) error {
 	if err := c.CheckSyncLink(); err != nil {
 		return err
 	}
+	if !handoff {
+		if err := rd.queueMisplaced(c); err != nil {
+			rd.r.logger.Error("Error queueing misplaced objects.", zap.String("dbFile", dbFile), zap.Error(err))
+		}
+	}
 	successes := 0
 	for i := 0; i < len(devices); i++ {
 		if err := rd.i.replicateDatabaseToDevice(devices[i], c, part, i); err == nil {
@@ -506,8 +515,7 @@ func (server *Replicator) Background(flags *flag.FlagSet) chan struct{} {
 }
 
 func (server *Replicator) GetHandler(config conf.Config, metricsPrefix string) http.Handler {
-	var metricsScope tally.Scope
-	metricsScope, server.metricsCloser = tally.NewRootScope(tally.ScopeOptions{
+	server.metricsScope, server.metricsCloser = tally.NewRootScope(tally.ScopeOptions{
 		Prefix:         metricsPrefix,
 		Tags:           map[string]string{},
 		CachedReporter: promreporter.NewReporter(promreporter.Options{}),
@@ -523,10 +531,13 @@ func (server *Replicator) GetHandler(config conf.Config, metricsPrefix string) h
 	router.Get("/metrics", prometheus.Handler())
 	router.Get("/loglevel", server.logLevel)
 	router.Put("/loglevel", server.logLevel)
+	router.Get("/loglevel/:component", http.HandlerFunc(srv.LogLevelHandler))
+	router.Put("/loglevel/:component", http.HandlerFunc(srv.LogLevelHandler))
+	router.Get("/loglevels", http.HandlerFunc(srv.LogLevelsHandler))
 	router.Get("/healthcheck", commonHandlers.ThenFunc(server.HealthcheckHandler))
 	router.Get("/debug/pprof/:parm", http.DefaultServeMux)
 	router.Post("/debug/pprof/:parm", http.DefaultServeMux)
-	return alice.New(middleware.Metrics(metricsScope), middleware.ServerTracer(server.tracer)).Then(router)
+	return alice.New(middleware.Metrics(server.metricsScope), middleware.ServerTracer(server.tracer)).Then(router)
 }
 
 func (server *Replicator) Finalize() {
@@ -619,6 +630,15 @@ func (r *Replicator) reportStats() {
 	}
 }
 
+// deviceMetricsScope returns the scope for a device's replication counters,
+// which are labeled by device rather than named after it.
+func (r *Replicator) deviceMetricsScope(device string) tally.Scope {
+	if r.metricsScope == nil {
+		return tally.NoopScope
+	}
+	return r.metricsScope.Tagged(map[string]string{"device": device})
+}
+
 func (r *Replicator) runLoopCheck(reportTimer <-chan time.Time) {
 	select {
 	case device := <-r.checkin:
@@ -634,10 +654,12 @@ func (r *Replicator) runLoopCheck(reportTimer <-chan time.Time) {
 				rd.lifetimeStats[k] += v
 			}
 			rd.lifetimeStats["passes"]++
+			r.deviceMetricsScope(device).Counter("passes").Inc(1)
 		}
 	case update := <-r.sendStat:
 		if rd, ok := r.runningDevices[update.device]; ok {
 			rd.stats[update.stat] += update.value
+			r.deviceMetricsScope(update.device).Counter(update.stat).Inc(update.value)
 		}
 	case <-reportTimer:
 		r.reportStats()
@@ -678,6 +700,7 @@ func (r *Replicator) Run() {
 		case update := <-r.sendStat:
 			if ctx, ok := r.runningDevices[update.device]; ok {
 				ctx.stats[update.stat] += update.value
+				r.deviceMetricsScope(update.device).Counter(update.stat).Inc(update.value)
 			}
 		case <-done:
 			waitingFor--
@@ -698,6 +721,12 @@ func NewReplicator(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLo
 	if err != nil {
 		return ipPort, nil, nil, fmt.Errorf("Unable to get hash prefix and suffix: %s", err)
 	}
+	// device_filters names registered ring.DeviceFilters; handoffs they
+	// reject aren't replicated to.
+	deviceFilter, err := ring.GetDeviceFilter(serverconf.GetDefault("container-replicator", "device_filters", ""))
+	if err != nil {
+		return ipPort, nil, nil, err
+	}
 	ring, err := cnf.GetRing("container", hashPathPrefix, hashPathSuffix, 0)
 	if err != nil {
 		return ipPort, nil, nil, fmt.Errorf("Error loading container ring: %s", err)
@@ -737,7 +766,7 @@ func NewReplicator(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLo
 	}
 	c := &http.Client{
 		Timeout:   time.Minute * 15,
-		Transport: transport,
+		Transport: srv.NewSigningTransport(transport),
 	}
 	server := &Replicator{
 		runningDevices: make(map[string]*replicationDevice),
@@ -751,6 +780,7 @@ func NewReplicator(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLo
 		deviceRoot:     serverconf.GetDefault("container-replicator", "devices", "/srv/node"),
 		serverPort:     port,
 		reclaimAge:     serverconf.GetInt("container-replicator", "reclaim_age", 604800),
+		deviceFilter:   deviceFilter,
 		logger:         logger,
 		concurrencySem: make(chan struct{}, concurrency),
 		Ring:           ring,
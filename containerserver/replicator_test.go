@@ -37,6 +37,7 @@ import (
 	"github.com/troubling/hummingbird/common/ring"
 	"github.com/troubling/hummingbird/common/srv"
 	"github.com/troubling/hummingbird/common/test"
+	"github.com/uber-go/tally"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
@@ -601,6 +602,7 @@ func TestReportStats(t *testing.T) {
 func TestRunLoop(t *testing.T) {
 	obs, logs := observer.New(zap.InfoLevel)
 	logger := zap.New(obs)
+	scope := tally.NewTestScope("", nil)
 	r := &Replicator{
 		logger: logger,
 		Ring: &localDevicesRing{
@@ -629,9 +631,10 @@ func TestRunLoop(t *testing.T) {
 				lifetimeStats: map[string]int64{},
 			},
 		},
-		sendStat: make(chan statUpdate, 1),
-		startRun: make(chan string, 1),
-		checkin:  make(chan string, 1),
+		sendStat:     make(chan statUpdate, 1),
+		startRun:     make(chan string, 1),
+		checkin:      make(chan string, 1),
+		metricsScope: scope,
 	}
 	rpTimer := make(chan time.Time, 1)
 	rpTimer <- time.Now()
@@ -641,10 +644,12 @@ func TestRunLoop(t *testing.T) {
 	r.sendStat <- statUpdate{"sda", "attempted", 1}
 	r.runLoopCheck(rpTimer)
 	require.Equal(t, int64(1), r.runningDevices["sda"].stats["attempted"])
+	require.Equal(t, int64(1), scope.Snapshot().Counters()["attempted+device=sda"].Value())
 
 	r.startRun <- "sda"
 	r.runLoopCheck(rpTimer)
 	require.Equal(t, int64(0), r.runningDevices["sda"].stats["attempted"])
+	require.Equal(t, int64(1), scope.Snapshot().Counters()["passes+device=sda"].Value())
 	lastCheckin := r.runningDevices["sda"].lastCheckin
 
 	r.checkin <- "sda"
@@ -70,6 +70,7 @@ const (
 			reported_delete_timestamp TEXT DEFAULT '0',
 			reported_object_count INTEGER DEFAULT 0,
 			reported_bytes_used INTEGER DEFAULT 0,
+			reported_last_modified TEXT DEFAULT '',
 			hash TEXT default '00000000000000000000000000000000',
 			id TEXT,
 			status TEXT DEFAULT '',
@@ -86,7 +87,8 @@ const (
 		AS SELECT ci.account, ci.container, ci.created_at,
 			ci.put_timestamp, ci.delete_timestamp,
 			ci.reported_put_timestamp, ci.reported_delete_timestamp,
-			ci.reported_object_count, ci.reported_bytes_used, ci.hash,
+			ci.reported_object_count, ci.reported_bytes_used,
+			ci.reported_last_modified, ci.hash,
 			ci.id, ci.status, ci.status_changed_at, ci.metadata,
 			ci.x_container_sync_point1, ci.x_container_sync_point2,
 			ci.reconciler_sync_point,
@@ -108,6 +110,7 @@ const (
 				reported_delete_timestamp = NEW.reported_delete_timestamp,
 				reported_object_count = NEW.reported_object_count,
 				reported_bytes_used = NEW.reported_bytes_used,
+				reported_last_modified = NEW.reported_last_modified,
 				hash = NEW.hash,
 				id = NEW.id,
 				status = NEW.status,
@@ -129,7 +132,8 @@ const (
 				etag TEXT,
 				deleted INTEGER DEFAULT 0,
 				storage_policy_index INTEGER DEFAULT 0,
-				expires INTEGER DEFAULT NULL
+				expires INTEGER DEFAULT NULL,
+				state TEXT DEFAULT NULL
 			);
 		CREATE INDEX ix_object_deleted_name ON object (deleted, name);
 		CREATE INDEX ix_object_expires ON object(expires) WHERE expires IS NOT NULL;
@@ -207,6 +211,16 @@ const (
 	xExpireMigrateScript = `
 		ALTER TABLE object ADD COLUMN expires INTEGER DEFAULT NULL;
 		CREATE INDEX ix_object_expires ON object(expires) WHERE expires IS NOT NULL;`
+
+	// state is what the object server last reported about how the object is
+	// stored, such as "nursery" for one not yet erasure coded.
+	objectStateMigrateScript = "ALTER TABLE object ADD COLUMN state TEXT DEFAULT NULL;"
+
+	// reported_last_modified is the last-modified time last sent to the
+	// account; the container_stat view is rebuilt to include it.
+	reportedLastModifiedMigrateScript = "ALTER TABLE container_info ADD COLUMN reported_last_modified TEXT DEFAULT '';" +
+		"DROP VIEW container_stat;" +
+		containerStatViewScript
 )
 
 func schemaMigrate(db *sql.DB) (bool, error) {
@@ -215,6 +229,8 @@ func schemaMigrate(db *sql.DB) (bool, error) {
 	hasMetadata := false
 	hasPolicyStat := false
 	hasExpireColumn := false
+	hasStateColumn := false
+	hasReportedLastModified := false
 
 	tx, err := db.Begin()
 	if err != nil {
@@ -223,7 +239,7 @@ func schemaMigrate(db *sql.DB) (bool, error) {
 	defer tx.Rollback()
 
 	// We just pull the schema out of sqlite_master and look at it to get the current state of the database.
-	rows, err := tx.Query("SELECT name, sql FROM sqlite_master WHERE name in ('policy_stat', 'ix_object_deleted_name', 'container_stat')")
+	rows, err := tx.Query("SELECT name, sql FROM sqlite_master WHERE name in ('policy_stat', 'ix_object_deleted_name', 'ix_object_expires', 'container_stat', 'object')")
 	if err != nil {
 		return false, err
 	}
@@ -239,8 +255,11 @@ func schemaMigrate(db *sql.DB) (bool, error) {
 		} else if name == "container_stat" {
 			hasSyncPoints = strings.Contains(sql, "x_container_sync_point1")
 			hasMetadata = strings.Contains(sql, "metadata")
+			hasReportedLastModified = strings.Contains(sql, "reported_last_modified")
 		} else if name == "ix_object_expires" {
 			hasExpireColumn = true
+		} else if name == "object" {
+			hasStateColumn = strings.Contains(sql, "state TEXT")
 		}
 	}
 	if err := rows.Err(); err != nil {
@@ -250,7 +269,7 @@ func schemaMigrate(db *sql.DB) (bool, error) {
 		return hasDeletedNameIndex, err
 	}
 
-	if hasSyncPoints && hasMetadata && hasPolicyStat {
+	if hasSyncPoints && hasMetadata && hasPolicyStat && hasStateColumn && hasReportedLastModified {
 		return hasDeletedNameIndex, nil
 	}
 
@@ -274,5 +293,16 @@ func schemaMigrate(db *sql.DB) (bool, error) {
 			return hasDeletedNameIndex, fmt.Errorf("Performing expires migration: %v", err)
 		}
 	}
+	if !hasStateColumn {
+		if _, err = tx.Exec(objectStateMigrateScript); err != nil {
+			return hasDeletedNameIndex, fmt.Errorf("Adding state column: %v", err)
+		}
+	}
+	// The policy migration makes a container_stat view that already has it.
+	if !hasReportedLastModified && hasPolicyStat {
+		if _, err = tx.Exec(reportedLastModifiedMigrateScript); err != nil {
+			return hasDeletedNameIndex, fmt.Errorf("Adding reported_last_modified column: %v", err)
+		}
+	}
 	return hasDeletedNameIndex, tx.Commit()
 }
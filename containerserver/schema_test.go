@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -169,6 +170,38 @@ func TestMigrateCruftyDatabase(t *testing.T) {
 			require.True(t, columnNames[column])
 		}
 	}
-	ensureColumnsExist("object", []string{"storage_policy_index"})
-	ensureColumnsExist("container_stat", []string{"metadata", "x_container_sync_point1", "x_container_sync_point2"})
+	ensureColumnsExist("object", []string{"storage_policy_index", "expires", "state"})
+	ensureColumnsExist("container_stat", []string{"metadata", "x_container_sync_point1", "x_container_sync_point2", "reported_last_modified"})
+}
+
+func TestMigrateReportedLastModified(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	dbFile := filepath.Join(dir, "db.db")
+	dbConn, err := sql.Open("sqlite3_hummingbird", dbFile)
+	require.Nil(t, err)
+	// The schema from before containers kept what last modified time they'd reported.
+	old := func(script string) string {
+		script = strings.Replace(script, "reported_last_modified TEXT DEFAULT '',", "", 1)
+		script = strings.Replace(script, "ci.reported_last_modified,", "", 1)
+		return strings.Replace(script, "reported_last_modified = NEW.reported_last_modified,", "", 1)
+	}
+	_, err = dbConn.Exec(objectTableScript + policyStatTableScript + policyStatTriggerScript +
+		old(containerInfoTableScript) + old(containerStatViewScript) + syncTableScript)
+	require.Nil(t, err)
+	_, err = dbConn.Exec(`INSERT INTO container_info (account, container, created_at, id, put_timestamp, status_changed_at)
+						  VALUES ("a", "c", "100000000.00000", "some database", "100000000.00000", "100000000.00000");
+						  INSERT INTO policy_stat (storage_policy_index) VALUES (0);`)
+	require.Nil(t, err)
+	require.Nil(t, dbConn.Close())
+
+	c, err := sqliteOpenContainer(dbFile)
+	require.Nil(t, err)
+	defer c.Close()
+	require.Nil(t, c.Reported("100000000.00000", "0", 0, 0, "100000001.00000"))
+	info, err := c.GetInfo()
+	require.Nil(t, err)
+	require.Equal(t, "some database", info.ID)
+	require.Equal(t, "100000001.00000", info.ReportedLastModified)
 }
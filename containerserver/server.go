@@ -17,7 +17,6 @@ package containerserver
 
 import (
 	"encoding/json"
-	"encoding/xml"
 	"flag"
 	"fmt"
 	"io"
@@ -63,7 +62,10 @@ type ContainerServer struct {
 	syncRealms              conf.SyncRealmList
 	defaultPolicy           int
 	policyList              conf.PolicyList
+	listingLimit            int64
+	restoreWindow           time.Duration
 	metricsCloser           io.Closer
+	statsd                  *srv.StatsdClient
 	traceCloser             io.Closer
 	tracer                  opentracing.Tracer
 }
@@ -104,6 +106,7 @@ func (server *ContainerServer) Finalize() {
 	if server.metricsCloser != nil {
 		server.metricsCloser.Close()
 	}
+	server.statsd.Close()
 	if server.traceCloser != nil {
 		server.traceCloser.Close()
 	}
@@ -187,17 +190,62 @@ func (server *ContainerServer) ContainerGetHandler(writer http.ResponseWriter, r
 		writer.Write([]byte(""))
 		return
 	}
-	limit := int64(10000)
+	// container_listing_limit lets operators allow larger listings, for
+	// exports and the like, than the 10000 given when no limit is asked for.
+	maxLimit := server.listingLimit
+	if maxLimit <= 0 {
+		maxLimit = common.CONTAINER_LISTING_LIMIT
+	}
+	limit := int64(common.CONTAINER_LISTING_LIMIT)
+	if limit > maxLimit {
+		limit = maxLimit
+	}
 	limitStr := request.FormValue("limit")
 	if limitStr != "" {
-		limit, _ = strconv.ParseInt(limitStr, 10, 64)
-		if limit > 10000 {
+		requested, _ := strconv.ParseInt(limitStr, 10, 64)
+		if requested > maxLimit {
 			srv.StandardResponse(writer, http.StatusPreconditionFailed)
 			return
-		} else if limit < 0 {
-			limit = 10000
+		} else if requested >= 0 {
+			limit = requested
 		}
 	}
+	// changes_since lists the rows added since that ROWID, in order, for the
+	// proxy's container watches. ROWIDs are only meaningful to this replica,
+	// so its ID and latest ROWID are sent along.
+	if v := request.Form.Get("changes_since"); v != "" {
+		since, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			srv.StandardResponse(writer, http.StatusBadRequest)
+			return
+		}
+		rc, ok := db.(ReplicableContainer)
+		if !ok {
+			srv.StandardResponse(writer, http.StatusNotImplemented)
+			return
+		}
+		records, err := rc.ItemsSince(since, int(limit))
+		if err != nil {
+			srv.GetLogger(request).Error("Unable to list changes.", zap.Error(err))
+			srv.StandardResponse(writer, http.StatusInternalServerError)
+			return
+		}
+		output, err := json.Marshal(records)
+		if err != nil {
+			srv.StandardResponse(writer, http.StatusInternalServerError)
+			return
+		}
+		headers.Set("X-Backend-Container-Id", info.ID)
+		headers.Set("X-Backend-Max-Row", strconv.FormatInt(info.MaxRow, 10))
+		headers.Set("Content-Type", "application/json; charset=utf-8")
+		headers.Set("Content-Length", strconv.Itoa(len(output)))
+		writer.WriteHeader(http.StatusOK)
+		writer.Write(output)
+		return
+	}
+	if !srv.ValidateListingParams(writer, request) {
+		return
+	}
 	marker := request.Form.Get("marker")
 	delimiter := request.Form.Get("delimiter")
 	endMarker := request.Form.Get("end_marker")
@@ -210,13 +258,10 @@ func (server *ContainerServer) ContainerGetHandler(writer http.ResponseWriter, r
 	if err != nil {
 		policyIndex = info.StoragePolicyIndex
 	}
-	reverse := common.LooksTrue(request.Form.Get("reverse"))
-	objects, err := db.ListObjects(int(limit), marker, endMarker, prefix, delimiter, path, reverse, policyIndex)
-	if err != nil {
-		srv.GetLogger(request).Error("Unable to list objects.", zap.Error(err))
-		srv.StandardResponse(writer, http.StatusInternalServerError)
-		return
+	if request.Form.Get("verbose") == "backend" {
+		policyIndex = AllPolicies
 	}
+	reverse := common.LooksTrue(request.Form.Get("reverse"))
 	format := request.Form.Get("format")
 	if format == "" { /* TODO: real accept parsing */
 		accept := request.Header.Get("Accept")
@@ -228,49 +273,17 @@ func (server *ContainerServer) ContainerGetHandler(writer http.ResponseWriter, r
 			format = "text"
 		}
 	}
-	if format == "text" {
-		response := ""
-		for _, obj := range objects {
-			if or, ok := obj.(*ObjectListingRecord); ok {
-				response += or.Name + "\n"
-			} else if sr, ok := obj.(*SubdirListingRecord); ok {
-				response += sr.Name + "\n"
-			}
-		}
-		headers.Set("Content-Type", "text/plain; charset=utf-8")
-		if len(response) > 0 {
-			headers.Set("Content-Length", strconv.Itoa(len(response)))
-			writer.WriteHeader(200)
-			writer.Write([]byte(response))
-		} else {
-			headers.Set("Content-Length", "0")
-			writer.WriteHeader(204)
-			writer.Write([]byte(""))
-		}
-	} else if format == "json" {
-		output, err := json.Marshal(objects)
-		if err != nil {
+	lw := &listingWriter{writer: writer, format: format, container: vars["container"]}
+	if err := db.StreamObjects(int(limit), marker, endMarker, prefix, delimiter, path, reverse, policyIndex, lw.Write); err != nil {
+		srv.GetLogger(request).Error("Unable to list objects.", zap.Error(err), zap.Int("sent", lw.count))
+		if lw.count == 0 {
 			srv.StandardResponse(writer, http.StatusInternalServerError)
-			return
-		}
-		headers.Set("Content-Type", "application/json; charset=utf-8")
-		headers.Set("Content-Length", strconv.Itoa(len(output)))
-		writer.WriteHeader(200)
-		writer.Write(output)
-	} else if format == "xml" {
-		type Container struct {
-			XMLName xml.Name `xml:"container"`
-			Name    string   `xml:"name,attr"`
-			Objects []interface{}
+		} else {
+			lw.Abort()
 		}
-		container := &Container{Name: vars["container"], Objects: objects}
-		writer.Header().Set("Content-Type", "application/xml; charset=utf-8")
-		output, _ := xml.Marshal(container)
-		headers.Set("Content-Length", strconv.Itoa(len(output)+39))
-		writer.WriteHeader(200)
-		writer.Write([]byte("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"))
-		writer.Write(output)
+		return
 	}
+	lw.Close()
 }
 
 // ContainerPutHandler handles PUT requests for a container.
@@ -312,6 +325,11 @@ func (server *ContainerServer) ContainerPutHandler(writer http.ResponseWriter, r
 	}
 	defer server.containerEngine.Return(db)
 	if info, err := db.GetInfo(); err == nil {
+		if info.DeleteTimestamp > info.PutTimestamp {
+			// The container was deleted after this PUT was issued.
+			srv.StandardResponse(writer, http.StatusConflict)
+			return
+		}
 		server.accountUpdate(writer, request, vars, info, srv.GetLogger(request))
 	} else {
 		srv.GetLogger(request).Error("could not GetInfo on cont create.", zap.Error(err))
@@ -357,7 +375,7 @@ func (server *ContainerServer) ContainerDeleteHandler(writer http.ResponseWriter
 		srv.StandardResponse(writer, http.StatusNotFound)
 		return
 	}
-	if err = db.Delete(timestamp); err != nil {
+	if err = db.Delete(timestamp, server.restoreWindow > 0); err != nil {
 		srv.GetLogger(request).Error("Unable to delete database.", zap.Error(err))
 		srv.StandardResponse(writer, http.StatusInternalServerError)
 		return
@@ -374,6 +392,51 @@ func (server *ContainerServer) ContainerDeleteHandler(writer http.ResponseWriter
 	writer.Write([]byte(""))
 }
 
+// ContainerRestoreHandler handles RESTORE requests, which undelete a container
+// deleted less than restore_window ago along with the metadata it had.
+func (server *ContainerServer) ContainerRestoreHandler(writer http.ResponseWriter, request *http.Request) {
+	vars := srv.GetVars(request)
+	timestamp, err := common.StandardizeTimestamp(request.Header.Get("X-Timestamp"))
+	if err != nil {
+		srv.StandardResponse(writer, http.StatusBadRequest)
+		return
+	}
+	if server.restoreWindow <= 0 {
+		srv.StandardResponse(writer, http.StatusNotFound)
+		return
+	}
+	db, err := server.containerEngine.Get(vars)
+	if err == ErrorNoSuchContainer {
+		srv.StandardResponse(writer, http.StatusNotFound)
+		return
+	} else if err != nil {
+		srv.GetLogger(request).Error("Unable to get container.", zap.Error(err))
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	defer server.containerEngine.Return(db)
+	deletedSince := common.CanonicalTimestamp(float64(time.Now().Add(-server.restoreWindow).UnixNano()) / 1000000000.0)
+	if err = db.Restore(timestamp, deletedSince); err == ErrorNoSuchContainer {
+		srv.StandardResponse(writer, http.StatusNotFound)
+		return
+	} else if err == ErrorNotDeleted {
+		srv.StandardResponse(writer, http.StatusConflict)
+		return
+	} else if err != nil {
+		srv.GetLogger(request).Error("Unable to restore container.", zap.Error(err))
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	if info, err := db.GetInfo(); err == nil {
+		server.accountUpdate(writer, request, vars, info, srv.GetLogger(request))
+	} else {
+		srv.GetLogger(request).Error("could not GetInfo on cont restore.", zap.Error(err))
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	srv.StandardResponse(writer, http.StatusCreated)
+}
+
 // ContainerPostHandler handles POST requests for a container.
 func (server *ContainerServer) ContainerPostHandler(writer http.ResponseWriter, request *http.Request) {
 	vars := srv.GetVars(request)
@@ -460,7 +523,8 @@ func (server *ContainerServer) ObjPutHandler(writer http.ResponseWriter, request
 	}
 	defer server.containerEngine.Return(db)
 	expires := request.Header.Get("X-Delete-At")
-	if err := db.PutObject(vars["obj"], timestamp, size, contentType, etag, policyIndex, expires); err != nil {
+	state := request.Header.Get("X-Backend-Object-State")
+	if err := db.PutObject(vars["obj"], timestamp, size, contentType, etag, policyIndex, expires, state); err != nil {
 		srv.GetLogger(request).Error("Error adding object to container.", zap.Error(err))
 		srv.StandardResponse(writer, http.StatusInternalServerError)
 		return
@@ -547,8 +611,11 @@ func (server *ContainerServer) AcquireDevice(next http.Handler) http.Handler {
 		vars := srv.GetVars(request)
 		if device, ok := vars["device"]; ok && device != "" {
 			devicePath := filepath.Join(server.driveRoot, device)
-			if server.checkMounts {
+			// X-Backend-Mount-Check: true lets a caller insist on the mount
+			// check even on servers running with mount_check = false.
+			if server.checkMounts || request.Header.Get("X-Backend-Mount-Check") == "true" {
 				if mounted, err := fs.IsMount(devicePath); err != nil || !mounted {
+					srv.GetLogger(request).Error("Device not mounted", zap.String("device", devicePath), zap.Error(err))
 					vars["Method"] = request.Method
 					srv.CustomErrorResponse(writer, 507, vars)
 					return
@@ -599,10 +666,14 @@ func (server *ContainerServer) GetHandler(config conf.Config, metricsPrefix stri
 		middleware.ValidateRequest,
 		server.AcquireDevice,
 	)
+	dataHandlers := commonHandlers.Append(middleware.BackendSignatures())
 	router := srv.NewRouter()
 	router.Get("/metrics", prometheus.Handler())
 	router.Get("/loglevel", server.logLevel)
 	router.Put("/loglevel", server.logLevel)
+	router.Get("/loglevel/:component", http.HandlerFunc(srv.LogLevelHandler))
+	router.Put("/loglevel/:component", http.HandlerFunc(srv.LogLevelHandler))
+	router.Get("/loglevels", http.HandlerFunc(srv.LogLevelsHandler))
 	router.Get("/healthcheck", commonHandlers.ThenFunc(server.HealthcheckHandler))
 	router.Get("/diskusage", commonHandlers.ThenFunc(server.DiskUsageHandler))
 	router.Put("/ring/*ring_path", commonHandlers.ThenFunc(middleware.RingHandler))
@@ -611,20 +682,21 @@ func (server *ContainerServer) GetHandler(config conf.Config, metricsPrefix stri
 	router.Get("/recon/:method/:recon_type", commonHandlers.ThenFunc(server.ReconHandler))
 	router.Get("/recon/:method", commonHandlers.ThenFunc(server.ReconHandler))
 	router.Delete("/recon/:device/:method/:recon_type/*item_path", commonHandlers.ThenFunc(server.ReconHandler))
-	router.Put("/:device/tmp/:filename", commonHandlers.ThenFunc(server.ContainerTmpUploadHandler))
-	router.Put("/:device/:partition/:account/:container/*obj", commonHandlers.ThenFunc(server.ObjPutHandler))
-	router.Delete("/:device/:partition/:account/:container/*obj", commonHandlers.ThenFunc(server.ObjDeleteHandler))
-	router.Put("/:device/:partition/:account/:container", commonHandlers.ThenFunc(server.ContainerPutHandler))
-	router.Get("/:device/:partition/:account/:container", commonHandlers.ThenFunc(server.ContainerGetHandler))
-	router.Head("/:device/:partition/:account/:container", commonHandlers.ThenFunc(server.ContainerGetHandler))
-	router.Delete("/:device/:partition/:account/:container", commonHandlers.ThenFunc(server.ContainerDeleteHandler))
-	router.Post("/:device/:partition/:account/:container", commonHandlers.ThenFunc(server.ContainerPostHandler))
-	router.Replicate("/:device/:partition/:hash", commonHandlers.ThenFunc(server.ContainerReplicateHandler))
+	router.Put("/:device/tmp/:filename", dataHandlers.ThenFunc(server.ContainerTmpUploadHandler))
+	router.Put("/:device/:partition/:account/:container/*obj", dataHandlers.ThenFunc(server.ObjPutHandler))
+	router.Delete("/:device/:partition/:account/:container/*obj", dataHandlers.ThenFunc(server.ObjDeleteHandler))
+	router.Put("/:device/:partition/:account/:container", dataHandlers.ThenFunc(server.ContainerPutHandler))
+	router.Get("/:device/:partition/:account/:container", dataHandlers.ThenFunc(server.ContainerGetHandler))
+	router.Head("/:device/:partition/:account/:container", dataHandlers.ThenFunc(server.ContainerGetHandler))
+	router.Delete("/:device/:partition/:account/:container", dataHandlers.ThenFunc(server.ContainerDeleteHandler))
+	router.Post("/:device/:partition/:account/:container", dataHandlers.ThenFunc(server.ContainerPostHandler))
+	router.Handle("RESTORE", "/:device/:partition/:account/:container", dataHandlers.ThenFunc(server.ContainerRestoreHandler))
+	router.Replicate("/:device/:partition/:hash", dataHandlers.ThenFunc(server.ContainerReplicateHandler))
 	router.Options("/", commonHandlers.ThenFunc(server.OptionsHandler))
 	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Invalid path: %s", r.URL.Path), http.StatusBadRequest)
 	})
-	return alice.New(middleware.Metrics(metricsScope), middleware.GrepObject, middleware.ServerTracer(server.tracer)).Then(router)
+	return alice.New(middleware.Metrics(metricsScope), middleware.StatsdTimings(server.statsd), middleware.GrepObject, middleware.ServerTracer(server.tracer)).Then(router)
 }
 
 // NewServer parses configs and command-line flags, returning a configured server object and the ip and port it should bind on.
@@ -650,6 +722,10 @@ func NewServer(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader
 	server.autoCreatePrefix = serverconf.GetDefault("app:container-server", "auto_create_account_prefix", ".")
 	server.driveRoot = serverconf.GetDefault("app:container-server", "devices", "/srv/node")
 	server.checkMounts = serverconf.GetBool("app:container-server", "mount_check", true)
+	server.listingLimit = serverconf.GetInt("app:container-server", "container_listing_limit", common.CONTAINER_LISTING_LIMIT)
+	// With restore_window set, in seconds, deleted containers keep their
+	// metadata and can be restored for that long.
+	server.restoreWindow = time.Duration(serverconf.GetInt("app:container-server", "restore_window", 0)) * time.Second
 
 	logLevelString := serverconf.GetDefault("app:container-server", "log_level", "INFO")
 	server.logLevel = zap.NewAtomicLevel()
@@ -658,6 +734,9 @@ func NewServer(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader
 	if server.logger, err = srv.SetupLogger("container-server", &server.logLevel, flags); err != nil {
 		return ipPort, nil, nil, fmt.Errorf("Error setting up logger: %v", err)
 	}
+	if server.statsd, err = srv.NewStatsdClientFromConfig(serverconf.GetSection("app:container-server"), "container-server"); err != nil {
+		return ipPort, nil, nil, err
+	}
 	server.diskInUse = common.NewKeyedLimit(serverconf.GetLimit("app:container-server", "disk_limit", 0, 0))
 	bindIP := serverconf.GetDefault("app:container-server", "bind_ip", "0.0.0.0")
 	bindPort := int(serverconf.GetInt("app:container-server", "bind_port", common.DefaultContainerServerPort))
@@ -685,7 +764,7 @@ func NewServer(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader
 	}
 	c := &http.Client{
 		Timeout:   nodeTimeout,
-		Transport: transport,
+		Transport: srv.NewSigningTransport(transport),
 	}
 	server.updateClient = c
 	if serverconf.HasSection("tracing") {
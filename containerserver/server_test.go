@@ -18,8 +18,10 @@ package containerserver
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/troubling/hummingbird/common"
@@ -88,6 +90,58 @@ func TestContainerDeleteDeleted(t *testing.T) {
 	require.Equal(t, 404, rsp.Status)
 }
 
+func TestContainerPutRecreate(t *testing.T) {
+	handler, cleanup, err := makeTestServer()
+	require.Nil(t, err)
+	defer cleanup()
+
+	rsp := test.MakeCaptureResponse()
+	req, err := http.NewRequest("PUT", "/device/1/a/c", nil)
+	require.Nil(t, err)
+	req.Header.Set("X-Timestamp", "1000000000.00001")
+	req.Header.Set("X-Backend-Storage-Policy-Index", "2")
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 201, rsp.Status)
+
+	rsp = test.MakeCaptureResponse()
+	req, err = http.NewRequest("DELETE", "/device/1/a/c", nil)
+	require.Nil(t, err)
+	req.Header.Set("X-Timestamp", "1000000000.00003")
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 204, rsp.Status)
+
+	// A PUT that raced the delete and lost.
+	rsp = test.MakeCaptureResponse()
+	req, err = http.NewRequest("PUT", "/device/1/a/c", nil)
+	require.Nil(t, err)
+	req.Header.Set("X-Timestamp", "1000000000.00002")
+	req.Header.Set("X-Backend-Storage-Policy-Index", "0")
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 409, rsp.Status)
+
+	rsp = test.MakeCaptureResponse()
+	req, err = http.NewRequest("HEAD", "/device/1/a/c", nil)
+	require.Nil(t, err)
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 404, rsp.Status)
+
+	rsp = test.MakeCaptureResponse()
+	req, err = http.NewRequest("PUT", "/device/1/a/c", nil)
+	require.Nil(t, err)
+	req.Header.Set("X-Timestamp", "1000000000.00004")
+	req.Header.Set("X-Backend-Storage-Policy-Index", "0")
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 201, rsp.Status)
+
+	rsp = test.MakeCaptureResponse()
+	req, err = http.NewRequest("HEAD", "/device/1/a/c", nil)
+	require.Nil(t, err)
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 204, rsp.Status)
+	require.Equal(t, "0", rsp.Header().Get("X-Backend-Storage-Policy-Index"))
+	require.Equal(t, "1000000000.00004", rsp.Header().Get("X-Put-Timestamp"))
+}
+
 func TestContainerPostDeleted(t *testing.T) {
 	handler, cleanup, err := makeTestServer()
 	require.Nil(t, err)
@@ -399,6 +453,111 @@ func TestContainerPutObjectsFails(t *testing.T) {
 	require.Equal(t, 500, rsp.Status)
 }
 
+func TestContainerGetBackendVerbose(t *testing.T) {
+	handler, cleanup, err := makeTestServer()
+	require.Nil(t, err)
+	defer cleanup()
+
+	rsp := test.MakeCaptureResponse()
+	req, err := http.NewRequest("PUT", "/device/1/a/c", nil)
+	require.Nil(t, err)
+	req.Header.Set("X-Timestamp", "100000000.00001")
+	req.Header.Set("X-Backend-Storage-Policy-Index", "0")
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 201, rsp.Status)
+
+	for _, policy := range []string{"0", "1"} {
+		rsp := test.MakeCaptureResponse()
+		req, err := http.NewRequest("PUT", "/device/1/a/c/o"+policy, nil)
+		require.Nil(t, err)
+		req.Header.Set("X-Timestamp", common.GetTimestamp())
+		req.Header.Set("X-Content-Type", "application/octet-stream")
+		req.Header.Set("X-Size", "2")
+		req.Header.Set("X-Etag", "d41d8cd98f00b204e9800998ecf8427e")
+		req.Header.Set("X-Backend-Storage-Policy-Index", policy)
+		req.Header.Set("X-Backend-Object-State", "nursery")
+		handler.ServeHTTP(rsp, req)
+		require.Equal(t, 201, rsp.Status)
+	}
+
+	rsp = test.MakeCaptureResponse()
+	req, err = http.NewRequest("GET", "/device/1/a/c?format=json", nil)
+	require.Nil(t, err)
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 200, rsp.Status)
+	require.NotContains(t, rsp.Body.String(), "nursery")
+	var data []ObjectListingRecord
+	require.Nil(t, json.Unmarshal(rsp.Body.Bytes(), &data))
+	require.Equal(t, 1, len(data))
+
+	rsp = test.MakeCaptureResponse()
+	req, err = http.NewRequest("GET", "/device/1/a/c?format=json&verbose=backend", nil)
+	require.Nil(t, err)
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 200, rsp.Status)
+	data = nil
+	require.Nil(t, json.Unmarshal(rsp.Body.Bytes(), &data))
+	require.Equal(t, 2, len(data))
+	require.Equal(t, "o1", data[1].Name)
+	require.Equal(t, 1, *data[1].StoragePolicyIndex)
+	require.Equal(t, "nursery", data[1].State)
+}
+
+func TestContainerGetChangesSince(t *testing.T) {
+	handler, cleanup, err := makeTestServer()
+	require.Nil(t, err)
+	defer cleanup()
+
+	rsp := test.MakeCaptureResponse()
+	req, err := http.NewRequest("PUT", "/device/1/a/c", nil)
+	require.Nil(t, err)
+	req.Header.Set("X-Timestamp", "100000000.00001")
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 201, rsp.Status)
+
+	for _, name := range []string{"b", "a", "c"} {
+		rsp := test.MakeCaptureResponse()
+		req, err := http.NewRequest("PUT", "/device/1/a/c/"+name, nil)
+		require.Nil(t, err)
+		req.Header.Set("X-Timestamp", common.GetTimestamp())
+		req.Header.Set("X-Content-Type", "text/plain")
+		req.Header.Set("X-Size", "2")
+		req.Header.Set("X-Etag", "d41d8cd98f00b204e9800998ecf8427e")
+		handler.ServeHTTP(rsp, req)
+		require.Equal(t, 201, rsp.Status)
+	}
+
+	changes := func(query string) ([]*ObjectRecord, http.Header) {
+		rsp := test.MakeCaptureResponse()
+		req, err := http.NewRequest("GET", "/device/1/a/c?"+query, nil)
+		require.Nil(t, err)
+		handler.ServeHTTP(rsp, req)
+		require.Equal(t, 200, rsp.Status)
+		var records []*ObjectRecord
+		require.Nil(t, json.Unmarshal(rsp.Body.Bytes(), &records))
+		return records, rsp.Header()
+	}
+	records, headers := changes("changes_since=0&limit=0")
+	require.Equal(t, 0, len(records))
+	require.NotEqual(t, "", headers.Get("X-Backend-Container-Id"))
+	require.Equal(t, "3", headers.Get("X-Backend-Max-Row"))
+
+	// Rows come in the order they were added, not by name.
+	records, _ = changes("changes_since=0")
+	require.Equal(t, 3, len(records))
+	require.Equal(t, "b", records[0].Name)
+	require.Equal(t, "c", records[2].Name)
+	records, _ = changes(fmt.Sprintf("changes_since=%d&limit=1", records[0].Rowid))
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "a", records[0].Name)
+
+	rsp = test.MakeCaptureResponse()
+	req, err = http.NewRequest("GET", "/device/1/a/c?changes_since=x", nil)
+	require.Nil(t, err)
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 400, rsp.Status)
+}
+
 func TestContainerGetTextEmpty(t *testing.T) {
 	handler, cleanup, err := makeTestServer()
 	require.Nil(t, err)
@@ -419,6 +578,60 @@ func TestContainerGetTextEmpty(t *testing.T) {
 	require.Equal(t, 204, rsp.Status)
 }
 
+func TestContainerGetListingLimit(t *testing.T) {
+	server, handler, cleanup, err := makeTestServer2()
+	require.Nil(t, err)
+	defer cleanup()
+
+	rsp := test.MakeCaptureResponse()
+	req, err := http.NewRequest("PUT", "/device/1/a/c", nil)
+	require.Nil(t, err)
+	req.Header.Set("X-Timestamp", "100000000.00001")
+	req.Header.Set("X-Backend-Storage-Policy-Index", "0")
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 201, rsp.Status)
+
+	rsp = test.MakeCaptureResponse()
+	req, err = http.NewRequest("GET", "/device/1/a/c?limit=10001", nil)
+	require.Nil(t, err)
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 412, rsp.Status)
+
+	server.listingLimit = 1000000
+	rsp = test.MakeCaptureResponse()
+	req, err = http.NewRequest("GET", "/device/1/a/c?limit=1000000&format=json", nil)
+	require.Nil(t, err)
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 200, rsp.Status)
+	require.Equal(t, "[]", rsp.Body.String())
+}
+
+func TestContainerGetInvalidDelimiter(t *testing.T) {
+	handler, cleanup, err := makeTestServer()
+	require.Nil(t, err)
+	defer cleanup()
+
+	rsp := test.MakeCaptureResponse()
+	req, err := http.NewRequest("PUT", "/device/1/a/c", nil)
+	require.Nil(t, err)
+	req.Header.Set("X-Timestamp", "100000000.00001")
+	req.Header.Set("X-Backend-Storage-Policy-Index", "0")
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 201, rsp.Status)
+
+	rsp = test.MakeCaptureResponse()
+	req, err = http.NewRequest("GET", "/device/1/a/c?delimiter=%FF", nil)
+	require.Nil(t, err)
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 412, rsp.Status)
+
+	rsp = test.MakeCaptureResponse()
+	req, err = http.NewRequest("GET", "/device/1/a/c?delimiter=%E2%86%92%E2%86%92", nil)
+	require.Nil(t, err)
+	handler.ServeHTTP(rsp, req)
+	require.Equal(t, 204, rsp.Status)
+}
+
 func TestContainerPutObjectBadRequests(t *testing.T) {
 	handler, cleanup, err := makeTestServer()
 	require.Nil(t, err)
@@ -582,6 +795,41 @@ func TestContainerDelete(t *testing.T) {
 	require.Equal(t, 404, rsp.Status)
 }
 
+func TestContainerRestore(t *testing.T) {
+	server, handler, cleanup, err := makeTestServer2()
+	require.Nil(t, err)
+	defer cleanup()
+	do := func(method, path string, header map[string]string) *test.CaptureResponse {
+		rsp := test.MakeCaptureResponse()
+		req, err := http.NewRequest(method, path, nil)
+		require.Nil(t, err)
+		req.Header.Set("X-Timestamp", common.GetTimestamp())
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		handler.ServeHTTP(rsp, req)
+		return rsp
+	}
+
+	// off by default
+	require.Equal(t, 201, do("PUT", "/device/1/a/c1", nil).Status)
+	require.Equal(t, 204, do("DELETE", "/device/1/a/c1", nil).Status)
+	require.Equal(t, 404, do("RESTORE", "/device/1/a/c1", nil).Status)
+
+	server.restoreWindow = time.Hour
+	require.Equal(t, 201, do("PUT", "/device/1/a/c2", map[string]string{"X-Container-Meta-First": "1", "X-Container-Read": ".r:*"}).Status)
+	require.Equal(t, 409, do("RESTORE", "/device/1/a/c2", nil).Status)
+	require.Equal(t, 204, do("DELETE", "/device/1/a/c2", nil).Status)
+	require.Equal(t, 404, do("HEAD", "/device/1/a/c2", nil).Status)
+	require.Equal(t, 201, do("RESTORE", "/device/1/a/c2", nil).Status)
+	rsp := do("HEAD", "/device/1/a/c2", nil)
+	require.Equal(t, 204, rsp.Status)
+	require.Equal(t, "1", rsp.Header().Get("X-Container-Meta-First"))
+	require.Equal(t, ".r:*", rsp.Header().Get("X-Container-Read"))
+	require.Equal(t, 409, do("RESTORE", "/device/1/a/c2", nil).Status)
+	require.Equal(t, 404, do("RESTORE", "/device/1/a/c3", nil).Status)
+}
+
 func TestHealthcheck(t *testing.T) {
 	handler, cleanup, err := makeTestServer()
 	require.Nil(t, err)
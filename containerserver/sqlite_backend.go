@@ -148,21 +148,30 @@ func (db *sqliteContainer) GetInfo() (*ContainerInfo, error) {
 							cs.delete_timestamp, cs.status_changed_at,
 							cs.object_count, cs.bytes_used,
 							cs.reported_put_timestamp, cs.reported_delete_timestamp,
-							cs.reported_object_count, cs.reported_bytes_used, cs.hash,
+							cs.reported_object_count, cs.reported_bytes_used,
+							cs.reported_last_modified, cs.hash,
 							cs.id, cs.x_container_sync_point1, cs.x_container_sync_point2,
-							cs.storage_policy_index, cs.metadata, maxrowid.max
+							cs.storage_policy_index, cs.metadata, maxrowid.max,
+							IFNULL((SELECT MAX(created_at) FROM object), '')
 						FROM container_stat cs, maxrowid`)
 	if err := row.Scan(&info.Account, &info.Container, &info.CreatedAt, &info.PutTimestamp,
 		&info.DeleteTimestamp, &info.StatusChangedAt, &info.ObjectCount,
 		&info.BytesUsed, &info.ReportedPutTimestamp, &info.ReportedDeleteTimestamp,
-		&info.ReportedObjectCount, &info.ReportedBytesUsed, &info.Hash,
+		&info.ReportedObjectCount, &info.ReportedBytesUsed,
+		&info.ReportedLastModified, &info.Hash,
 		&info.ID, &info.XContainerSyncPoint1, &info.XContainerSyncPoint2,
-		&info.StoragePolicyIndex, &info.RawMetadata, &info.MaxRow); err != nil {
+		&info.StoragePolicyIndex, &info.RawMetadata, &info.MaxRow, &info.LastModified); err != nil {
 		if common.IsCorruptDBError(err) {
 			return nil, fmt.Errorf("Failed to GetInfo: %v; %v", err, common.QuarantineDir(path.Dir(db.containerFile), 4, "containers"))
 		}
 		return nil, err
 	}
+	// The newest object row is the container's last change, unless the
+	// container itself was put again since. Replication can merge in rows
+	// older than ones already there, so it's the newest by timestamp.
+	if info.PutTimestamp > info.LastModified {
+		info.LastModified = info.PutTimestamp
+	}
 	if info.RawMetadata == "" {
 		info.Metadata = make(map[string][]string)
 	} else if err := json.Unmarshal([]byte(info.RawMetadata), &info.Metadata); err != nil {
@@ -186,8 +195,9 @@ func (db *sqliteContainer) IsDeleted() (bool, error) {
 }
 
 // Delete sets the container's deleted timestamp and tombstones any metadata older than that timestamp.
-// This may or may not make the container "deleted".
-func (db *sqliteContainer) Delete(timestamp string) error {
+// This may or may not make the container "deleted". With keepMetadata, each tombstone keeps the value it
+// replaced as a third element, until Restore puts it back or CleanupTombstones reclaims it.
+func (db *sqliteContainer) Delete(timestamp string, keepMetadata bool) error {
 	if err := db.connect(); err != nil {
 		return err
 	}
@@ -209,7 +219,11 @@ func (db *sqliteContainer) Delete(timestamp string) error {
 	}
 	for key, value := range metadata {
 		if value[1] < timestamp {
-			metadata[key] = []string{"", timestamp}
+			if keepMetadata && value[0] != "" {
+				metadata[key] = []string{"", timestamp, value[0]}
+			} else {
+				metadata[key] = []string{"", timestamp}
+			}
 		}
 	}
 	serializedMetadata, err := json.Marshal(metadata)
@@ -232,6 +246,62 @@ func (db *sqliteContainer) Delete(timestamp string) error {
 	return nil
 }
 
+// Restore undeletes a container deleted no earlier than deletedSince by moving its put timestamp to
+// timestamp and bringing back the metadata values its Delete kept. It returns ErrorNotDeleted if the
+// container isn't deleted and ErrorNoSuchContainer if it was deleted before deletedSince or after timestamp.
+func (db *sqliteContainer) Restore(timestamp string, deletedSince string) error {
+	if err := db.connect(); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var putTimestamp, deleteTimestamp, metastr string
+	if err := tx.QueryRow("SELECT put_timestamp, delete_timestamp, metadata FROM container_info").Scan(&putTimestamp, &deleteTimestamp, &metastr); err != nil {
+		if common.IsCorruptDBError(err) {
+			return fmt.Errorf("Failed to Restore SELECT: %v; %v", err, common.QuarantineDir(path.Dir(db.containerFile), 4, "containers"))
+		}
+		return err
+	}
+	if deleteTimestamp <= putTimestamp {
+		return ErrorNotDeleted
+	}
+	if deleteTimestamp < deletedSince || deleteTimestamp >= timestamp {
+		return ErrorNoSuchContainer
+	}
+	metadata := map[string][]string{}
+	if metastr != "" {
+		if err := json.Unmarshal([]byte(metastr), &metadata); err != nil {
+			return err
+		}
+	}
+	for key, value := range metadata {
+		if len(value) > 2 && value[0] == "" && value[1] == deleteTimestamp {
+			metadata[key] = []string{value[2], timestamp}
+		}
+	}
+	serializedMetadata, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec("UPDATE container_info SET put_timestamp = ?, status_changed_at = ?, metadata = ?", timestamp, timestamp, string(serializedMetadata)); err != nil {
+		if common.IsCorruptDBError(err) {
+			return fmt.Errorf("Failed to Restore UPDATE: %v; %v", err, common.QuarantineDir(path.Dir(db.containerFile), 4, "containers"))
+		}
+		return err
+	}
+	defer db.invalidateCache()
+	if err := tx.Commit(); err != nil {
+		if common.IsCorruptDBError(err) {
+			return fmt.Errorf("Failed to Restore Commit: %v; %v", err, common.QuarantineDir(path.Dir(db.containerFile), 4, "containers"))
+		}
+		return err
+	}
+	return nil
+}
+
 // MergeItems merges ObjectRecords into the container.  If a remote id is provided (incoming replication), the incoming_sync table is updated.
 func (db *sqliteContainer) MergeItems(records []*ObjectRecord, remoteID string) error {
 	if err := db.connect(); err != nil {
@@ -244,6 +314,9 @@ func (db *sqliteContainer) MergeItems(records []*ObjectRecord, remoteID string)
 	names := make([]interface{}, len(records))
 	existing := make(map[recordID]*ObjectRecord)
 	toAdd := make(map[recordID]*ObjectRecord)
+	// Rows are inserted in the order their records came in, so changes
+	// feeds see them in the order they were added.
+	var addOrder []recordID
 	tx, err := db.Begin()
 	if err != nil {
 		return err
@@ -305,7 +378,7 @@ func (db *sqliteContainer) MergeItems(records []*ObjectRecord, remoteID string)
 	}
 	defer dst.Close()
 
-	ast, err := tx.Prepare("INSERT INTO object (name, created_at, size, content_type, etag, deleted, storage_policy_index, expires) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	ast, err := tx.Prepare("INSERT INTO object (name, created_at, size, content_type, etag, deleted, storage_policy_index, expires, state) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return err
 	}
@@ -327,15 +400,22 @@ func (db *sqliteContainer) MergeItems(records []*ObjectRecord, remoteID string)
 					return err
 				}
 				delete(existing, rid)
+				if !ok {
+					addOrder = append(addOrder, rid)
+				}
 				toAdd[rid] = record
 			} else if !inExisting {
+				if !ok {
+					addOrder = append(addOrder, rid)
+				}
 				toAdd[rid] = record
 			}
 		}
 	}
 
-	for _, record := range toAdd {
-		if _, err := ast.Exec(record.Name, record.CreatedAt, record.Size, record.ContentType, record.ETag, record.Deleted, record.StoragePolicyIndex, record.Expires); err != nil {
+	for _, rid := range addOrder {
+		record := toAdd[rid]
+		if _, err := ast.Exec(record.Name, record.CreatedAt, record.Size, record.ContentType, record.ETag, record.Deleted, record.StoragePolicyIndex, record.Expires, record.State); err != nil {
 			if common.IsCorruptDBError(err) {
 				return fmt.Errorf("Failed to MergeItems INSERT: %v; %v", err, common.QuarantineDir(path.Dir(db.containerFile), 4, "containers"))
 			}
@@ -373,7 +453,8 @@ func indexAfter(s, sep string, after int) int {
 }
 
 func updateRecord(rec *ObjectListingRecord) error {
-	f, err := strconv.ParseFloat(rec.LastModified, 64)
+	// Updates that only change an object's state have an offset timestamp.
+	f, err := strconv.ParseFloat(strings.SplitN(rec.LastModified, "_", 2)[0], 64)
 	if err != nil {
 		return err
 	}
@@ -388,9 +469,26 @@ func updateRecord(rec *ObjectListingRecord) error {
 // ListObjects implements object listings.  Path is a string pointer because behavior is different for empty and missing path query parameters.
 func (db *sqliteContainer) ListObjects(limit int, marker string, endMarker string, prefix string, delimiter string,
 	pth *string, reverse bool, storagePolicyIndex int) ([]interface{}, error) {
-	if err := db.connect(); err != nil {
+	results := []interface{}{}
+	if err := db.StreamObjects(limit, marker, endMarker, prefix, delimiter, pth, reverse, storagePolicyIndex, func(record interface{}) error {
+		results = append(results, record)
+		return nil
+	}); err != nil {
 		return nil, err
 	}
+	return results, nil
+}
+
+// listingBatchSize is how many rows StreamObjects reads at a time.
+var listingBatchSize = 1000
+
+// StreamObjects is ListObjects, passing each entry to emit a batch of
+// listingBatchSize rows at a time rather than collecting them all.
+func (db *sqliteContainer) StreamObjects(limit int, marker string, endMarker string, prefix string, delimiter string,
+	pth *string, reverse bool, storagePolicyIndex int, emit func(record interface{}) error) error {
+	if err := db.connect(); err != nil {
+		return err
+	}
 	var point, pointDirection, queryTail, queryStart string
 
 	if pth != nil {
@@ -401,10 +499,15 @@ func (db *sqliteContainer) ListObjects(limit int, marker string, endMarker strin
 		delimiter = "/"
 		prefix = *pth
 	}
+	columns := "name, created_at, size, content_type, etag"
+	allPolicies := storagePolicyIndex == AllPolicies
+	if allPolicies {
+		columns += ", storage_policy_index, state"
+	}
 	if db.hasDeletedNameIndex {
-		queryStart = "SELECT name, created_at, size, content_type, etag FROM object WHERE deleted = 0 AND"
+		queryStart = "SELECT " + columns + " FROM object WHERE deleted = 0 AND"
 	} else {
-		queryStart = "SELECT name, created_at, size, content_type, etag FROM object WHERE +deleted = 0 AND"
+		queryStart = "SELECT " + columns + " FROM object WHERE +deleted = 0 AND"
 	}
 	if reverse {
 		marker, endMarker = endMarker, marker
@@ -415,14 +518,18 @@ func (db *sqliteContainer) ListObjects(limit int, marker string, endMarker strin
 		pointDirection = "name > ?"
 	}
 
-	results := []interface{}{}
+	count := 0
 	queryArgs := make([]interface{}, 8)
 	wheres := make([]string, 8)
 	gotResults := true
 
-	for len(results) < limit && gotResults {
-		wheres := append(wheres[:0], "storage_policy_index == ?")
-		queryArgs := append(queryArgs[:0], storagePolicyIndex)
+	for count < limit && gotResults {
+		wheres := append(wheres[:0], "1")
+		queryArgs := queryArgs[:0]
+		if !allPolicies {
+			wheres[0] = "storage_policy_index == ?"
+			queryArgs = append(queryArgs, storagePolicyIndex)
+		}
 		if prefix != "" {
 			wheres = append(wheres, "name BETWEEN ? AND ?")
 			queryArgs = append(queryArgs, prefix, prefix+"\xFF")
@@ -439,23 +546,37 @@ func (db *sqliteContainer) ListObjects(limit int, marker string, endMarker strin
 			wheres = append(wheres, pointDirection)
 			queryArgs = append(queryArgs, point)
 		}
+		batchLimit := limit - count
+		if batchLimit > listingBatchSize {
+			batchLimit = listingBatchSize
+		}
 		rows, err := db.Query(queryStart+" "+strings.Join(wheres, " AND ")+" "+queryTail,
-			append(queryArgs, limit-len(results))...)
+			append(queryArgs, batchLimit)...)
 		if err != nil {
 			if common.IsCorruptDBError(err) {
-				return nil, fmt.Errorf("Failed to ListObjects SELECT: %v; %v", err, common.QuarantineDir(path.Dir(db.containerFile), 4, "containers"))
+				return fmt.Errorf("Failed to ListObjects SELECT: %v; %v", err, common.QuarantineDir(path.Dir(db.containerFile), 4, "containers"))
 			}
-			return nil, err
+			return err
 		}
 		gotResults = false
-		for rows.Next() && len(results) < limit {
+		scanned := 0
+		batch := make([]interface{}, 0, batchLimit)
+		for rows.Next() && count+len(batch) < limit {
 			gotResults = true
+			scanned++
 			record := &ObjectListingRecord{}
-			if err := rows.Scan(&record.Name, &record.LastModified, &record.Size, &record.ContentType, &record.ETag); err != nil {
+			dest := []interface{}{&record.Name, &record.LastModified, &record.Size, &record.ContentType, &record.ETag}
+			var policy int
+			var state sql.NullString
+			if allPolicies {
+				dest = append(dest, &policy, &state)
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
 				if common.IsCorruptDBError(err) {
-					return nil, fmt.Errorf("Failed to ListObjects Scan: %v; %v", err, common.QuarantineDir(path.Dir(db.containerFile), 4, "containers"))
+					return fmt.Errorf("Failed to ListObjects Scan: %v; %v", err, common.QuarantineDir(path.Dir(db.containerFile), 4, "containers"))
 				}
-				return nil, err
+				return err
 			}
 			point = record.Name
 			if delimiter != "" {
@@ -463,7 +584,7 @@ func (db *sqliteContainer) ListObjects(limit int, marker string, endMarker strin
 					continue
 				}
 				end := indexAfter(record.Name, delimiter, len(prefix))
-				if end >= 0 && (pth == nil || len(record.Name) > end+1) {
+				if end >= 0 && (pth == nil || len(record.Name) > end+len(delimiter)) {
 					dirName := record.Name[:end] + delimiter
 					if reverse {
 						point = record.Name[:end+len(delimiter)]
@@ -471,28 +592,41 @@ func (db *sqliteContainer) ListObjects(limit int, marker string, endMarker strin
 						point = dirName + "\xFF"
 					}
 					if pth == nil && dirName != marker {
-						results = append(results, &SubdirListingRecord{Name2: dirName, Name: dirName})
+						batch = append(batch, &SubdirListingRecord{Name2: dirName, Name: dirName})
 					}
 					break
 				}
 			}
 			if err := updateRecord(record); err != nil {
-				return nil, err
+				rows.Close()
+				return err
 			}
-			results = append(results, record)
+			if allPolicies {
+				record.StoragePolicyIndex, record.State = &policy, state.String
+			}
+			batch = append(batch, record)
 		}
 		if err := rows.Err(); err != nil {
+			rows.Close()
 			if common.IsCorruptDBError(err) {
-				return nil, fmt.Errorf("Failed to ListObjects Err: %v; %v", err, common.QuarantineDir(path.Dir(db.containerFile), 4, "containers"))
+				return fmt.Errorf("Failed to ListObjects Err: %v; %v", err, common.QuarantineDir(path.Dir(db.containerFile), 4, "containers"))
 			}
-			return nil, err
+			return err
 		}
+		// The rows are closed before anything is written, so a slow client
+		// doesn't hold one of the database's few connections.
 		rows.Close()
-		if delimiter == "" && pth == nil {
+		for _, record := range batch {
+			if err := emit(record); err != nil {
+				return err
+			}
+			count++
+		}
+		if delimiter == "" && pth == nil && scanned < batchLimit {
 			break
 		}
 	}
-	return results, nil
+	return nil
 }
 
 // NewID sets the container's ID to a new, random string.
@@ -521,7 +655,7 @@ func (db *sqliteContainer) NewID() error {
 func (db *sqliteContainer) ItemsSince(start int64, count int) ([]*ObjectRecord, error) {
 	db.flush()
 	records := []*ObjectRecord{}
-	rows, err := db.Query(`SELECT ROWID, name, created_at, size, content_type, etag, deleted, storage_policy_index, expires
+	rows, err := db.Query(`SELECT ROWID, name, created_at, size, content_type, etag, deleted, storage_policy_index, expires, state
 						   FROM object WHERE ROWID > ? ORDER BY ROWID ASC LIMIT ?`, start, count)
 	if err != nil {
 		if common.IsCorruptDBError(err) {
@@ -531,7 +665,7 @@ func (db *sqliteContainer) ItemsSince(start int64, count int) ([]*ObjectRecord,
 	}
 	for rows.Next() {
 		r := &ObjectRecord{}
-		if err := rows.Scan(&r.Rowid, &r.Name, &r.CreatedAt, &r.Size, &r.ContentType, &r.ETag, &r.Deleted, &r.StoragePolicyIndex, &r.Expires); err != nil {
+		if err := rows.Scan(&r.Rowid, &r.Name, &r.CreatedAt, &r.Size, &r.ContentType, &r.ETag, &r.Deleted, &r.StoragePolicyIndex, &r.Expires, &r.State); err != nil {
 			if common.IsCorruptDBError(err) {
 				return nil, fmt.Errorf("Failed to ItemsSince Scan: %v; %v", err, common.QuarantineDir(path.Dir(db.containerFile), 4, "containers"))
 			}
@@ -999,7 +1133,7 @@ func (db *sqliteContainer) flush() error {
 	return db.flushAlreadyLocked()
 }
 
-func (db *sqliteContainer) addObject(name string, timestamp string, size int64, contentType string, etag string, deleted int, storagePolicyIndex int, expires string) error {
+func (db *sqliteContainer) addObject(name string, timestamp string, size int64, contentType string, etag string, deleted int, storagePolicyIndex int, expires string, state string) error {
 	lock, err := fs.LockPath(filepath.Dir(db.containerFile), 10*time.Second)
 	if err != nil {
 		return err
@@ -1018,6 +1152,9 @@ func (db *sqliteContainer) addObject(name string, timestamp string, size int64,
 	if expires == "" {
 		rec.Expires = nil
 	}
+	if state != "" {
+		rec.State = &state
+	}
 	file, err := os.OpenFile(db.containerFile+".pending", os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
 	if err != nil {
 		return err
@@ -1037,13 +1174,13 @@ func (db *sqliteContainer) addObject(name string, timestamp string, size int64,
 }
 
 // PutObject adds an object to the container, by way of pending file.
-func (db *sqliteContainer) PutObject(name string, timestamp string, size int64, contentType string, etag string, storagePolicyIndex int, expires string) error {
-	return db.addObject(name, timestamp, size, contentType, etag, 0, storagePolicyIndex, expires)
+func (db *sqliteContainer) PutObject(name string, timestamp string, size int64, contentType string, etag string, storagePolicyIndex int, expires string, state string) error {
+	return db.addObject(name, timestamp, size, contentType, etag, 0, storagePolicyIndex, expires, state)
 }
 
 // DeleteObject removes an object from the container, by way of pending file.
 func (db *sqliteContainer) DeleteObject(name string, timestamp string, storagePolicyIndex int) error {
-	return db.addObject(name, timestamp, 0, "", "", 1, storagePolicyIndex, "")
+	return db.addObject(name, timestamp, 0, "", "", 1, storagePolicyIndex, "", "")
 }
 
 // Close closes the underlying sqlite database connection.
@@ -1070,6 +1207,9 @@ func sqliteCreateExistingContainer(db Container, putTimestamp string, newMetadat
 	if err := cdb.connect(); err != nil {
 		return false, err
 	}
+	if err := cdb.flush(); err != nil {
+		return false, err
+	}
 	tx, err := cdb.Begin()
 	if err != nil {
 		return false, err
@@ -1084,16 +1224,38 @@ func sqliteCreateExistingContainer(db Container, putTimestamp string, newMetadat
 		}
 		return false, err
 	}
+	// Replicas can see a container's PUTs and DELETEs in any order, so the
+	// put_timestamp only ever moves forward and a PUT only recreates a deleted
+	// container if it's at least as new as the delete.
+	newPutTimestamp := cPutTimestamp
+	if putTimestamp > newPutTimestamp {
+		newPutTimestamp = putTimestamp
+	}
+	statusChangedAt := ""
 	if cDeleteTimestamp <= cPutTimestamp { // not deleted
 		if policyIndex < 0 {
 			policyIndex = cPolicyIndex
 		} else if cPolicyIndex != policyIndex {
 			return false, ErrorPolicyConflict
 		}
-	} else { // deleted
+	} else if cDeleteTimestamp > newPutTimestamp { // deleted, and this PUT is older than the delete
+		policyIndex = cPolicyIndex
+	} else { // recreated
 		if policyIndex < 0 {
 			policyIndex = defaultPolicyIndex
 		}
+		if policyIndex != cPolicyIndex {
+			// Objects that made it into the old container after its delete
+			// would be stranded in the old policy.
+			var objectCount int64
+			if err := tx.QueryRow("SELECT object_count FROM policy_stat WHERE storage_policy_index = ?", cPolicyIndex).Scan(&objectCount); err != nil && err != sql.ErrNoRows {
+				return false, err
+			}
+			if objectCount > 0 {
+				return false, ErrorPolicyConflict
+			}
+		}
+		statusChangedAt = putTimestamp
 	}
 	var existingMetadata map[string][]string
 	if cMetadata == "" {
@@ -1105,8 +1267,8 @@ func sqliteCreateExistingContainer(db Container, putTimestamp string, newMetadat
 	if err != nil {
 		return false, err
 	}
-	if _, err := tx.Exec("UPDATE container_info SET put_timestamp = ?, storage_policy_index = ?, metadata = ?",
-		putTimestamp, policyIndex, metastr); err != nil {
+	if _, err := tx.Exec("UPDATE container_info SET put_timestamp = ?, storage_policy_index = ?, metadata = ?, status_changed_at = COALESCE(NULLIF(?, ''), status_changed_at)",
+		newPutTimestamp, policyIndex, metastr, statusChangedAt); err != nil {
 		if common.IsCorruptDBError(err) {
 			return false, fmt.Errorf("Failed to sqliteCreateExistingContainer UPDATE: %v; %v", err, common.QuarantineDir(path.Dir(cdb.containerFile), 4, "containers"))
 		}
@@ -1119,7 +1281,7 @@ func sqliteCreateExistingContainer(db Container, putTimestamp string, newMetadat
 		}
 		return false, err
 	}
-	return (cDeleteTimestamp > cPutTimestamp && putTimestamp > cDeleteTimestamp), nil
+	return statusChangedAt != "", nil
 }
 
 func sqliteCreateContainer(containerFile string, account string, container string, putTimestamp string,
@@ -1200,7 +1362,7 @@ func sqliteOpenContainer(containerFile string) (ReplicableContainer, error) {
 	return db, nil
 }
 
-func (db *sqliteContainer) Reported(putTimestamp, deleteTimestamp string, objectCount, bytesUsed int64) error {
+func (db *sqliteContainer) Reported(putTimestamp, deleteTimestamp string, objectCount, bytesUsed int64, lastModified string) error {
 	if err := db.connect(); err != nil {
 		return err
 	}
@@ -1209,7 +1371,8 @@ func (db *sqliteContainer) Reported(putTimestamp, deleteTimestamp string, object
 		return err
 	}
 	defer tx.Rollback()
-	if _, err = tx.Exec("UPDATE container_info SET reported_put_timestamp = ?, reported_delete_timestamp = ?, reported_object_count = ?, reported_bytes_used = ?", putTimestamp, deleteTimestamp, objectCount, bytesUsed); err != nil {
+	if _, err = tx.Exec("UPDATE container_info SET reported_put_timestamp = ?, reported_delete_timestamp = ?, reported_object_count = ?, reported_bytes_used = ?, reported_last_modified = ?",
+		putTimestamp, deleteTimestamp, objectCount, bytesUsed, lastModified); err != nil {
 		if common.IsCorruptDBError(err) {
 			return fmt.Errorf("Failed to Reported UPDATE: %v; %v", err, common.QuarantineDir(path.Dir(db.containerFile), 4, "containers"))
 		}
@@ -1224,3 +1387,32 @@ func (db *sqliteContainer) Reported(putTimestamp, deleteTimestamp string, object
 	}
 	return nil
 }
+
+// ReconcilerSyncPoint returns the last ROWID the replicator checked for objects in the wrong storage policy.
+func (db *sqliteContainer) ReconcilerSyncPoint() (int64, error) {
+	if err := db.connect(); err != nil {
+		return 0, err
+	}
+	var point int64
+	if err := db.QueryRow("SELECT reconciler_sync_point FROM container_info").Scan(&point); err != nil {
+		if common.IsCorruptDBError(err) {
+			return 0, fmt.Errorf("Failed to ReconcilerSyncPoint SELECT: %v; %v", err, common.QuarantineDir(path.Dir(db.containerFile), 4, "containers"))
+		}
+		return 0, err
+	}
+	return point, nil
+}
+
+// SetReconcilerSyncPoint records the last ROWID the replicator checked for objects in the wrong storage policy.
+func (db *sqliteContainer) SetReconcilerSyncPoint(point int64) error {
+	if err := db.connect(); err != nil {
+		return err
+	}
+	if _, err := db.Exec("UPDATE container_info SET reconciler_sync_point = ?", point); err != nil {
+		if common.IsCorruptDBError(err) {
+			return fmt.Errorf("Failed to SetReconcilerSyncPoint UPDATE: %v; %v", err, common.QuarantineDir(path.Dir(db.containerFile), 4, "containers"))
+		}
+		return err
+	}
+	return nil
+}
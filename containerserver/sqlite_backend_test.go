@@ -79,6 +79,48 @@ func TestContainerListings(t *testing.T) {
 	require.Equal(t, "c", records[2].(*ObjectListingRecord).Name)
 }
 
+func TestContainerListingsAllPolicies(t *testing.T) {
+	db, _, cleanup, err := createTestDatabase("100000000.00000")
+	require.Nil(t, err)
+	defer cleanup()
+	nursery, stable := "nursery", "stable"
+	require.Nil(t, db.MergeItems([]*ObjectRecord{
+		{Name: "a", CreatedAt: "10000000.00001", State: &nursery},
+		{Name: "b", CreatedAt: "10000000.00001", StoragePolicyIndex: 1},
+		{Name: "c", CreatedAt: "10000000.00001_0000000000000001", State: &stable},
+	}, ""))
+	records, err := db.ListObjects(10000, "", "", "", "", nil, false, 0)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(records))
+	require.Nil(t, records[0].(*ObjectListingRecord).StoragePolicyIndex)
+	require.Equal(t, "", records[0].(*ObjectListingRecord).State)
+
+	records, err = db.ListObjects(10000, "", "", "", "", nil, false, AllPolicies)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(records))
+	for i, expected := range []struct {
+		name   string
+		policy int
+		state  string
+	}{{"a", 0, "nursery"}, {"b", 1, ""}, {"c", 0, "stable"}} {
+		rec := records[i].(*ObjectListingRecord)
+		require.Equal(t, expected.name, rec.Name)
+		require.Equal(t, expected.policy, *rec.StoragePolicyIndex)
+		require.Equal(t, expected.state, rec.State)
+	}
+	require.Equal(t, "1970-04-26T17:46:40", records[2].(*ObjectListingRecord).LastModified[:19])
+
+	items, err := db.ItemsSince(-1, 10)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(items))
+	states := map[string]*string{}
+	for _, item := range items {
+		states[item.Name] = item.State
+	}
+	require.Equal(t, "nursery", *states["a"])
+	require.Nil(t, states["b"])
+}
+
 func TestContainerUpdateRecord(t *testing.T) {
 	rec := &ObjectListingRecord{Name: "a", ContentType: "text/plain; swift_bytes=100", LastModified: "1.0"}
 	require.Nil(t, updateRecord(rec))
@@ -254,6 +296,73 @@ func TestContainerListingsDelimiter(t *testing.T) {
 	require.Equal(t, "test", records[1].(*ObjectListingRecord).Name)
 }
 
+func TestContainerStreamObjectsBatches(t *testing.T) {
+	defer func(size int) { listingBatchSize = size }(listingBatchSize)
+	listingBatchSize = 2
+	db, _, cleanup, err := createTestDatabase("100000000.00000")
+	require.Nil(t, err)
+	defer cleanup()
+	require.Nil(t, db.connect())
+	db.SetMaxOpenConns(1)
+	require.Nil(t, mergeItemsByName(db, []string{"a", "b", "c", "d-1", "d-2", "e"}))
+	for _, tc := range []struct {
+		delimiter string
+		limit     int
+		names     []string
+	}{
+		{"", 10000, []string{"a", "b", "c", "d-1", "d-2", "e"}},
+		{"", 3, []string{"a", "b", "c"}},
+		{"-", 10000, []string{"a", "b", "c", "d-", "e"}},
+	} {
+		var names []string
+		require.Nil(t, db.StreamObjects(tc.limit, "", "", "", tc.delimiter, nil, false, 0, func(record interface{}) error {
+			// With a single connection this would block if the rows
+			// were still open.
+			var one int
+			require.Nil(t, db.QueryRow("SELECT 1").Scan(&one))
+			switch r := record.(type) {
+			case *ObjectListingRecord:
+				names = append(names, r.Name)
+			case *SubdirListingRecord:
+				names = append(names, r.Name)
+			}
+			return nil
+		}))
+		require.Equal(t, tc.names, names)
+	}
+}
+
+func TestContainerListingsMultiCharDelimiter(t *testing.T) {
+	db, _, cleanup, err := createTestDatabase("100000000.00000")
+	require.Nil(t, err)
+	defer cleanup()
+	require.Nil(t, mergeItemsByName(db, []string{"a-b", "a--b", "a--c--d", "b--"}))
+	records, err := db.ListObjects(10000, "", "", "", "--", nil, false, 0)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(records))
+	require.Equal(t, "a--", records[0].(*SubdirListingRecord).Name)
+	require.Equal(t, "a-b", records[1].(*ObjectListingRecord).Name)
+	require.Equal(t, "b--", records[2].(*SubdirListingRecord).Name)
+
+	records, err = db.ListObjects(10000, "", "", "a--", "--", nil, true, 0)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(records))
+	require.Equal(t, "a--c--", records[0].(*SubdirListingRecord).Name)
+	require.Equal(t, "a--b", records[1].(*ObjectListingRecord).Name)
+}
+
+func TestContainerListingsUnicodeDelimiter(t *testing.T) {
+	db, _, cleanup, err := createTestDatabase("100000000.00000")
+	require.Nil(t, err)
+	defer cleanup()
+	require.Nil(t, mergeItemsByName(db, []string{"caf\u00e9\u2192menu", "caf\u00e9\u2192\u65e5\u672c", "caf\u00e9s", "cafe\u2192x"}))
+	records, err := db.ListObjects(10000, "", "", "caf\u00e9", "\u2192", nil, false, 0)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(records))
+	require.Equal(t, "caf\u00e9s", records[0].(*ObjectListingRecord).Name)
+	require.Equal(t, "caf\u00e9\u2192", records[1].(*SubdirListingRecord).Name)
+}
+
 func TestContainerListingsPaths(t *testing.T) {
 	files := []string{
 		"/file1",
@@ -367,6 +476,21 @@ func TestCreateAndGetInfo(t *testing.T) {
 	require.Equal(t, int64(0), info.BytesUsed)
 	require.Equal(t, metadata, info.Metadata)
 	require.Equal(t, 2, info.StoragePolicyIndex)
+	require.Equal(t, "100000000.00000", info.LastModified)
+}
+
+func TestGetInfoLastModified(t *testing.T) {
+	db, _, cleanup, err := createTestDatabase("200000000.00000")
+	require.Nil(t, err)
+	defer cleanup()
+	require.Nil(t, db.PutObject("o", "200000002.00000", 1, "text/plain", "d41d8cd98f00b204e9800998ecf8427e", 0, "", ""))
+	require.Nil(t, db.DeleteObject("o", "200000003.00000", 0))
+	// A row merged in later can still be older.
+	require.Nil(t, db.PutObject("p", "200000001.00000", 1, "text/plain", "d41d8cd98f00b204e9800998ecf8427e", 0, "", ""))
+	info, err := db.GetInfo()
+	require.Nil(t, err)
+	require.Equal(t, int64(1), info.ObjectCount)
+	require.Equal(t, "200000003.00000", info.LastModified)
 }
 
 func TestReported(t *testing.T) {
@@ -385,13 +509,28 @@ func TestReported(t *testing.T) {
 	require.Equal(t, "0", info.ReportedDeleteTimestamp)
 	require.Equal(t, int64(0), info.ReportedObjectCount)
 	require.Equal(t, int64(0), info.ReportedBytesUsed)
-	db.Reported("456000000.00000", "123000000.00000", 789, 101112)
+	require.Equal(t, "", info.ReportedLastModified)
+	db.Reported("456000000.00000", "123000000.00000", 789, 101112, "456000001.00000")
 	info, err = db.GetInfo()
 	require.Nil(t, err)
 	require.Equal(t, "456000000.00000", info.ReportedPutTimestamp)
 	require.Equal(t, "123000000.00000", info.ReportedDeleteTimestamp)
 	require.Equal(t, int64(789), info.ReportedObjectCount)
 	require.Equal(t, int64(101112), info.ReportedBytesUsed)
+	require.Equal(t, "456000001.00000", info.ReportedLastModified)
+}
+
+func TestReconcilerSyncPoint(t *testing.T) {
+	db, _, cleanup, err := createTestDatabase("100000000.00000")
+	require.Nil(t, err)
+	defer cleanup()
+	point, err := db.ReconcilerSyncPoint()
+	require.Nil(t, err)
+	require.Equal(t, int64(-1), point)
+	require.Nil(t, db.SetReconcilerSyncPoint(12))
+	point, err = db.ReconcilerSyncPoint()
+	require.Nil(t, err)
+	require.Equal(t, int64(12), point)
 }
 
 func TestDeleteIsDeleted(t *testing.T) {
@@ -399,17 +538,55 @@ func TestDeleteIsDeleted(t *testing.T) {
 	require.Nil(t, err)
 	defer cleanup()
 
-	require.Nil(t, db.Delete("100000001.00000"))
+	require.Nil(t, db.Delete("100000001.00000", false))
 	deleted, err := db.IsDeleted()
 	require.Nil(t, err)
 	require.False(t, deleted)
 
-	require.Nil(t, db.Delete("200000001.00000"))
+	require.Nil(t, db.Delete("200000001.00000", false))
 	deleted, err = db.IsDeleted()
 	require.Nil(t, err)
 	require.True(t, deleted)
 }
 
+func TestDeleteRestore(t *testing.T) {
+	db, _, cleanup, err := createTestDatabase("200000000.00000")
+	require.Nil(t, err)
+	defer cleanup()
+	require.Nil(t, db.UpdateMetadata(map[string][]string{
+		"X-Container-Meta-Hi": {"There", "200000000.00001"},
+		"X-Container-Read":    {".r:*", "200000000.00001"},
+	}, "200000000.00001"))
+
+	require.Equal(t, ErrorNotDeleted, db.Restore("200000000.00002", "0"))
+	require.Nil(t, db.Delete("200000001.00000", true))
+	m, err := db.GetMetadata()
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{}, m)
+
+	// deleted before the window, or after the restore
+	require.Equal(t, ErrorNoSuchContainer, db.Restore("200000002.00000", "200000001.00001"))
+	require.Equal(t, ErrorNoSuchContainer, db.Restore("200000000.50000", "0"))
+
+	require.Nil(t, db.Restore("200000002.00000", "200000000.00000"))
+	deleted, err := db.IsDeleted()
+	require.Nil(t, err)
+	require.False(t, deleted)
+	info, err := db.GetInfo()
+	require.Nil(t, err)
+	require.Equal(t, "200000002.00000", info.PutTimestamp)
+	require.Equal(t, []string{"There", "200000002.00000"}, info.Metadata["X-Container-Meta-Hi"])
+	require.Equal(t, []string{".r:*", "200000002.00000"}, info.Metadata["X-Container-Read"])
+	require.Equal(t, ErrorNotDeleted, db.Restore("200000003.00000", "0"))
+
+	// without keepMetadata, only the container comes back
+	require.Nil(t, db.Delete("200000004.00000", false))
+	require.Nil(t, db.Restore("200000005.00000", "0"))
+	m, err = db.GetMetadata()
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{}, m)
+}
+
 func TestMergeItems(t *testing.T) {
 	db, _, cleanup, err := createTestDatabase("200000000.00000")
 	require.Nil(t, err)
@@ -649,6 +826,67 @@ func TestCreateExistingNoPolicy(t *testing.T) {
 	})
 }
 
+func TestCreateExistingDeleted(t *testing.T) {
+	db, _, cleanup, err := createTestDatabase("200000000.00000")
+	require.Nil(t, err)
+	defer cleanup()
+	require.Nil(t, db.Delete("200000002.00000", false))
+
+	// A PUT issued before the delete leaves the container deleted and its
+	// policy alone.
+	c, err := sqliteCreateExistingContainer(db, "200000001.00000", map[string][]string{}, 3, 0)
+	require.Nil(t, err)
+	require.False(t, c)
+	info, err := db.GetInfo()
+	require.Nil(t, err)
+	require.Equal(t, "200000001.00000", info.PutTimestamp)
+	require.Equal(t, 0, info.StoragePolicyIndex)
+	deleted, err := db.IsDeleted()
+	require.Nil(t, err)
+	require.True(t, deleted)
+
+	c, err = sqliteCreateExistingContainer(db, "200000003.00000", map[string][]string{}, 3, 0)
+	require.Nil(t, err)
+	require.True(t, c)
+	info, err = db.GetInfo()
+	require.Nil(t, err)
+	require.Equal(t, "200000003.00000", info.PutTimestamp)
+	require.Equal(t, "200000003.00000", info.StatusChangedAt)
+	require.Equal(t, 3, info.StoragePolicyIndex)
+
+	// Retrying the same PUT, or one that arrives late, doesn't move the
+	// put_timestamp backwards.
+	c, err = sqliteCreateExistingContainer(db, "200000003.00000", map[string][]string{}, 3, 0)
+	require.Nil(t, err)
+	require.False(t, c)
+	c, err = sqliteCreateExistingContainer(db, "200000001.00000", map[string][]string{}, 3, 0)
+	require.Nil(t, err)
+	require.False(t, c)
+	info, err = db.GetInfo()
+	require.Nil(t, err)
+	require.Equal(t, "200000003.00000", info.PutTimestamp)
+}
+
+func TestCreateExistingDeletedWithObjects(t *testing.T) {
+	db, _, cleanup, err := createTestDatabase("200000000.00000")
+	require.Nil(t, err)
+	defer cleanup()
+	require.Nil(t, db.Delete("200000001.00000", false))
+	// An object that landed on this replica after it saw the delete.
+	require.Nil(t, db.PutObject("o", "200000002.00000", 1, "text/plain", "d41d8cd98f00b204e9800998ecf8427e", 0, "", ""))
+
+	_, err = sqliteCreateExistingContainer(db, "200000003.00000", map[string][]string{}, 3, 0)
+	require.Equal(t, ErrorPolicyConflict, err)
+
+	c, err := sqliteCreateExistingContainer(db, "200000003.00000", map[string][]string{}, -1, 0)
+	require.Nil(t, err)
+	require.True(t, c)
+	info, err := db.GetInfo()
+	require.Nil(t, err)
+	require.Equal(t, 0, info.StoragePolicyIndex)
+	require.Equal(t, int64(1), info.ObjectCount)
+}
+
 func TestCleanupTombstones(t *testing.T) {
 	db, _, cleanup, err := createTestDatabase("200000000.00000")
 	require.Nil(t, err)
@@ -681,7 +919,7 @@ func TestDeleteRemovesMetadata(t *testing.T) {
 	db.UpdateMetadata(map[string][]string{
 		"X-Container-Meta-Key": {"Value", "200000000.00001"},
 	}, "10000000.00001")
-	require.Nil(t, db.Delete("200000001.00000"))
+	require.Nil(t, db.Delete("200000001.00000", false))
 	deleted, err := db.IsDeleted()
 	require.Nil(t, err)
 	require.True(t, deleted)
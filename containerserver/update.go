@@ -62,9 +62,13 @@ func (server *ContainerServer) accountUpdate(writer http.ResponseWriter, request
 		for len(schemes) < len(hosts) {
 			schemes = append(schemes, "http")
 		}
+		pathPrefixes := splitHeader(request.Header.Get("X-Account-Path-Prefix"))
+		for len(pathPrefixes) < len(hosts) {
+			pathPrefixes = append(pathPrefixes, "")
+		}
 		ctx := tracing.CopySpanFromContext(request.Context())
 		for index, host := range hosts {
-			if err := accountUpdateHelper(ctx, info, schemes[index], host, devices[index], accpartition, vars["account"], vars["container"], request.Header.Get("X-Trans-Id"), request.Header.Get("X-Account-Override-Deleted") == "yes", server.updateClient); err != nil {
+			if err := accountUpdateHelper(ctx, info, schemes[index], host, pathPrefixes[index], devices[index], accpartition, vars["account"], vars["container"], request.Header.Get("X-Trans-Id"), request.Header.Get("X-Account-Override-Deleted") == "yes", server.updateClient); err != nil {
 				logger.Error(
 					"Account update failed:", zap.Error(err),
 					zap.String("schemes[index]", schemes[index]),
@@ -80,8 +84,8 @@ func (server *ContainerServer) accountUpdate(writer http.ResponseWriter, request
 	}
 }
 
-func accountUpdateHelper(ctx context.Context, info *ContainerInfo, scheme, host, device, accpartition, account, container, transID string, accountOverrideDeleted bool, updateClient common.HTTPClient) error {
-	url := fmt.Sprintf("%s://%s/%s/%s/%s/%s", scheme, host, device, accpartition,
+func accountUpdateHelper(ctx context.Context, info *ContainerInfo, scheme, host, pathPrefix, device, accpartition, account, container, transID string, accountOverrideDeleted bool, updateClient common.HTTPClient) error {
+	url := fmt.Sprintf("%s://%s%s/%s/%s/%s/%s", scheme, host, pathPrefix, device, accpartition,
 		common.Urlencode(account), common.Urlencode(container))
 	req, err := http.NewRequest("PUT", url, nil)
 	if err != nil {
@@ -95,6 +99,7 @@ func accountUpdateHelper(ctx context.Context, info *ContainerInfo, scheme, host,
 	req.Header.Add("X-Bytes-Used", strconv.FormatInt(info.BytesUsed, 10))
 	req.Header.Add("X-Trans-Id", transID)
 	req.Header.Add("X-Backend-Storage-Policy-Index", strconv.Itoa(info.StoragePolicyIndex))
+	req.Header.Add("X-Last-Modified-Timestamp", info.LastModified)
 	if accountOverrideDeleted {
 		req.Header.Add("X-Account-Override-Deleted", "yes")
 	}
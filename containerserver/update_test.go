@@ -35,6 +35,7 @@ func TestAccountUpdate(t *testing.T) {
 		ObjectCount:        1,
 		BytesUsed:          1024,
 		StoragePolicyIndex: 2,
+		LastModified:       "lastmodified",
 	}
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		require.Equal(t, "/adevice/3/a/c", r.URL.Path)
@@ -44,6 +45,7 @@ func TestAccountUpdate(t *testing.T) {
 		require.Equal(t, "1024", r.Header.Get("X-Bytes-Used"))
 		require.Equal(t, "atxid", r.Header.Get("X-Trans-Id"))
 		require.Equal(t, "2", r.Header.Get("X-Backend-Storage-Policy-Index"))
+		require.Equal(t, "lastmodified", r.Header.Get("X-Last-Modified-Timestamp"))
 	}))
 	defer ts.Close()
 	url, err := url.Parse(ts.URL)
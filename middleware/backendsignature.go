@@ -0,0 +1,51 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"go.uber.org/zap"
+)
+
+// BackendSignatures returns a middleware that checks the signatures requests
+// carry against the [backend] signing_key in hummingbird.conf. Requests with
+// a bad signature get a 401; unsigned ones are let through unless
+// signing_required is set, so a cluster can start signing before it starts
+// enforcing it.
+func BackendSignatures() func(http.Handler) http.Handler {
+	key, required, maxSkew := conf.GetBackendSigning()
+	return backendSignatures(key, required, time.Duration(maxSkew)*time.Second)
+}
+
+func backendSignatures(key string, required bool, maxSkew time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if key == "" {
+			return next
+		}
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if err := srv.CheckBackendSignature(request, key, maxSkew, time.Now()); err != nil && (err != srv.ErrUnsigned || required) {
+				srv.GetLogger(request).Info("Rejecting request with a bad backend signature", zap.String("txn", request.Header.Get("X-Trans-Id")), zap.Error(err))
+				srv.StandardResponse(writer, http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
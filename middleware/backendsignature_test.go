@@ -0,0 +1,56 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common/srv"
+	"go.uber.org/zap"
+)
+
+func TestBackendSignatures(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	status := func(h http.Handler, sign string) int {
+		req := httptest.NewRequest("PUT", "/sda/1/a/c/o", nil)
+		req = srv.SetLogger(req, zap.NewNop())
+		if sign != "" {
+			srv.SignBackendRequest(req, sign, time.Now())
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// no key, no checking
+	h := backendSignatures("", true, time.Minute)(ok)
+	require.Equal(t, http.StatusOK, status(h, ""))
+	require.Equal(t, http.StatusOK, status(h, "other"))
+
+	h = backendSignatures("secret", false, time.Minute)(ok)
+	require.Equal(t, http.StatusOK, status(h, "secret"))
+	require.Equal(t, http.StatusOK, status(h, ""))
+	require.Equal(t, http.StatusUnauthorized, status(h, "other"))
+
+	h = backendSignatures("secret", true, time.Minute)(ok)
+	require.Equal(t, http.StatusOK, status(h, "secret"))
+	require.Equal(t, http.StatusUnauthorized, status(h, ""))
+	require.Equal(t, http.StatusUnauthorized, status(h, "other"))
+}
@@ -23,6 +23,12 @@ func (mw *recordStatusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return mw.ResponseWriter.(http.Hijacker).Hijack()
 }
 
+func (mw *recordStatusWriter) Flush() {
+	if f, ok := mw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func Metrics(metricsScope tally.Scope) func(http.Handler) http.Handler {
 	requestsMetric := metricsScope.Counter("requests")
 	return func(next http.Handler) http.Handler {
@@ -0,0 +1,163 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"github.com/uber-go/tally"
+)
+
+// DaemonProgressKey is the recon cache key holding each daemon's
+// DaemonProgress, by daemon name.
+const DaemonProgressKey = "daemon_progress"
+
+// DaemonProgress is the standard report of how far a background daemon is
+// through its current cycle and how long its last one took. Times are unix
+// seconds; Total and ETA are 0 when they aren't known.
+type DaemonProgress struct {
+	CycleStart    float64 `json:"cycle_start"`
+	Processed     int64   `json:"processed"`
+	Failures      int64   `json:"failures"`
+	Total         int64   `json:"total"`
+	ETA           float64 `json:"eta"`
+	LastCycleTime float64 `json:"last_cycle_time"`
+	LastCycleEnd  float64 `json:"last_cycle_end"`
+	Updated       float64 `json:"updated"`
+}
+
+// ProgressReporter keeps a daemon's DaemonProgress, saving it to the recon
+// cache under DaemonProgressKey and setting progress_* gauges in its metrics
+// scope each time Report is called. It's safe for concurrent use.
+type ProgressReporter struct {
+	reconCachePath string
+	source         string
+	name           string
+	lock           sync.Mutex
+	progress       DaemonProgress
+	processed      tally.Gauge
+	failures       tally.Gauge
+	total          tally.Gauge
+	eta            tally.Gauge
+	lastCycleTime  tally.Gauge
+	now            func() time.Time
+}
+
+// NewProgressReporter returns a ProgressReporter for the named daemon that
+// saves to the source ("account", "container" or "object") recon cache. A nil
+// scope leaves the progress out of the metrics.
+func NewProgressReporter(reconCachePath, source, name string, scope tally.Scope) *ProgressReporter {
+	if scope == nil {
+		scope = tally.NoopScope
+	}
+	scope = scope.Tagged(map[string]string{"daemon": name})
+	return &ProgressReporter{
+		reconCachePath: reconCachePath,
+		source:         source,
+		name:           name,
+		processed:      scope.Gauge("progress_processed"),
+		failures:       scope.Gauge("progress_failures"),
+		total:          scope.Gauge("progress_total"),
+		eta:            scope.Gauge("progress_eta_seconds"),
+		lastCycleTime:  scope.Gauge("progress_last_cycle_seconds"),
+		now:            time.Now,
+	}
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+// StartCycle resets the counts for a new cycle expected to handle total
+// items, or an unknown number if total is 0.
+func (p *ProgressReporter) StartCycle(total int64) {
+	p.lock.Lock()
+	p.progress.CycleStart = unixSeconds(p.now())
+	p.progress.Processed = 0
+	p.progress.Failures = 0
+	p.progress.Total = total
+	p.lock.Unlock()
+}
+
+// SetTotal sets the number of items the current cycle expects to handle,
+// for daemons that only know it once the cycle is under way.
+func (p *ProgressReporter) SetTotal(total int64) {
+	p.lock.Lock()
+	p.progress.Total = total
+	p.lock.Unlock()
+}
+
+// Processed counts n items handled, failed or not.
+func (p *ProgressReporter) Processed(n int64) {
+	p.lock.Lock()
+	p.progress.Processed += n
+	p.lock.Unlock()
+}
+
+// Failed counts n items that couldn't be handled; they should also be
+// counted with Processed.
+func (p *ProgressReporter) Failed(n int64) {
+	p.lock.Lock()
+	p.progress.Failures += n
+	p.lock.Unlock()
+}
+
+// Progress returns the progress so far, estimating when the cycle will
+// finish from the rate so far if the total is known, or from how long the
+// last cycle took if not.
+func (p *ProgressReporter) Progress() DaemonProgress {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	now := unixSeconds(p.now())
+	progress := p.progress
+	progress.Updated = now
+	progress.ETA = 0
+	if progress.Total > 0 && progress.Processed > 0 {
+		elapsed := now - progress.CycleStart
+		progress.ETA = progress.CycleStart + elapsed/float64(progress.Processed)*float64(progress.Total)
+	} else if progress.LastCycleTime > 0 {
+		progress.ETA = progress.CycleStart + progress.LastCycleTime
+	}
+	return progress
+}
+
+// Report saves the progress so far.
+func (p *ProgressReporter) Report() error {
+	progress := p.Progress()
+	p.processed.Update(float64(progress.Processed))
+	p.failures.Update(float64(progress.Failures))
+	p.total.Update(float64(progress.Total))
+	if progress.ETA > 0 {
+		p.eta.Update(progress.ETA - progress.Updated)
+	} else {
+		p.eta.Update(0)
+	}
+	p.lastCycleTime.Update(progress.LastCycleTime)
+	return DumpReconCache(p.reconCachePath, p.source, map[string]interface{}{
+		DaemonProgressKey: map[string]interface{}{p.name: progress},
+	})
+}
+
+// EndCycle records the cycle as complete and saves the final progress.
+func (p *ProgressReporter) EndCycle() error {
+	p.lock.Lock()
+	end := unixSeconds(p.now())
+	p.progress.LastCycleTime = end - p.progress.CycleStart
+	p.progress.LastCycleEnd = end
+	p.lock.Unlock()
+	return p.Report()
+}
@@ -0,0 +1,81 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/uber-go/tally"
+)
+
+func TestProgressReporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	scope := tally.NewTestScope("", nil)
+	p := NewProgressReporter(dir, "object", "object-auditor-all", scope)
+	now := time.Unix(1000, 0)
+	p.now = func() time.Time { return now }
+
+	p.StartCycle(0)
+	p.Processed(5)
+	p.Failed(1)
+	progress := p.Progress()
+	require.Equal(t, float64(1000), progress.CycleStart)
+	require.Equal(t, int64(5), progress.Processed)
+	require.Equal(t, int64(1), progress.Failures)
+	require.Equal(t, float64(0), progress.ETA)
+
+	now = time.Unix(1100, 0)
+	require.Nil(t, p.EndCycle())
+	require.Equal(t, float64(100), p.Progress().LastCycleTime)
+	require.Equal(t, float64(1100), p.Progress().LastCycleEnd)
+
+	// With no total the estimate comes from the last cycle.
+	p.StartCycle(0)
+	require.Equal(t, int64(0), p.Progress().Processed)
+	require.Equal(t, float64(1200), p.Progress().ETA)
+
+	// With a total it comes from the rate so far.
+	p.StartCycle(40)
+	now = time.Unix(1110, 0)
+	p.Processed(10)
+	require.Nil(t, p.Report())
+	require.Equal(t, float64(1140), p.Progress().ETA)
+	gauges := scope.Snapshot().Gauges()
+	require.Equal(t, float64(10), gauges["progress_processed+daemon=object-auditor-all"].Value())
+	require.Equal(t, float64(30), gauges["progress_eta_seconds+daemon=object-auditor-all"].Value())
+	require.Equal(t, float64(100), gauges["progress_last_cycle_seconds+daemon=object-auditor-all"].Value())
+
+	r, _ := http.NewRequest("GET", "/recon/progress/object", nil)
+	r = srv.SetVars(r, map[string]string{"method": "progress", "recon_type": "object"})
+	w := &testWriter{make(http.Header), bytes.NewBuffer(nil), 0}
+	ReconHandler("", dir, false, w, r)
+	require.Equal(t, 200, w.s)
+	var v map[string]map[string]DaemonProgress
+	require.Nil(t, json.Unmarshal(w.f.Bytes(), &v))
+	require.Equal(t, int64(10), v[DaemonProgressKey]["object-auditor-all"].Processed)
+	require.Equal(t, int64(40), v[DaemonProgressKey]["object-auditor-all"].Total)
+	require.Equal(t, float64(1140), v[DaemonProgressKey]["object-auditor-all"].ETA)
+}
@@ -27,6 +27,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -287,6 +288,42 @@ func getTotalAsyncs(driveRoot, reconCachePath string) (interface{}, error) {
 	return asyncs, nil
 }
 
+// FullDevice is what the "full" recon method reports for a device that has
+// rejected writes for lack of space.
+type FullDevice struct {
+	Last  int64 `json:"last"`
+	Count int64 `json:"count"`
+}
+
+var (
+	fullDevicesLock sync.Mutex
+	fullDevices     = map[string]*FullDevice{}
+)
+
+// MarkDeviceFull records that a request to device was answered with 507
+// Insufficient Storage because the device was out of space.
+func MarkDeviceFull(device string) {
+	fullDevicesLock.Lock()
+	defer fullDevicesLock.Unlock()
+	fd := fullDevices[device]
+	if fd == nil {
+		fd = &FullDevice{}
+		fullDevices[device] = fd
+	}
+	fd.Last = time.Now().Unix()
+	fd.Count++
+}
+
+func getFullDevices() map[string]FullDevice {
+	fullDevicesLock.Lock()
+	defer fullDevicesLock.Unlock()
+	devices := make(map[string]FullDevice, len(fullDevices))
+	for device, fd := range fullDevices {
+		devices[device] = *fd
+	}
+	return devices
+}
+
 func ListDevices(driveRoot string) (map[string][]string, error) {
 	fileInfo, err := ioutil.ReadDir(driveRoot)
 	if err != nil {
@@ -578,6 +615,14 @@ func diskUsage(driveRoot string, mountCheck bool) ([]map[string]interface{}, err
 	return devices, nil
 }
 
+// TimeHandler serves /recon/hummingbirdtime for servers without the rest of
+// recon, like the proxy and andrewd, so their clocks can be checked too.
+func TimeHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.WriteHeader(200)
+	serialized, _ := json.MarshalIndent(map[string]time.Time{"time": time.Now()}, "", "  ")
+	writer.Write(serialized)
+}
+
 func ReconHandler(driveRoot string, reconCachePath string, mountCheck bool, writer http.ResponseWriter, request *http.Request) {
 	var content interface{} = nil
 
@@ -644,6 +689,18 @@ func ReconHandler(driveRoot string, reconCachePath string, mountCheck bool, writ
 			srv.SimpleErrorResponse(writer, http.StatusInternalServerError, err.Error())
 			return
 		}
+	case "progress":
+		source := vars["recon_type"]
+		if source == "" {
+			source = "object"
+		}
+		content, err = fromReconCache(reconCachePath, source, DaemonProgressKey)
+		if err != nil {
+			srv.SimpleErrorResponse(writer, http.StatusInternalServerError, err.Error())
+			return
+		}
+	case "full":
+		content = getFullDevices()
 	case "mounted":
 		content = getMounts()
 	case "unmounted":
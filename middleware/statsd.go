@@ -0,0 +1,51 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/troubling/hummingbird/common/srv"
+)
+
+// StatsdTimings sends Swift style backend request timings, like
+// object-server.PUT.timing, or object-server.PUT.errors.timing when the
+// response is a 5xx other than 507.  Only requests for a device path are
+// timed, so operator endpoints such as /recon and /metrics are skipped.
+func StatsdTimings(statsd *srv.StatsdClient) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if statsd == nil {
+			return next
+		}
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			parts := strings.SplitN(strings.Trim(request.URL.Path, "/"), "/", 3)
+			if len(parts) < 3 || parts[0] == "recon" || parts[0] == "ring" || parts[0] == "debug" {
+				next.ServeHTTP(writer, request)
+				return
+			}
+			start := time.Now()
+			w := &recordStatusWriter{ResponseWriter: writer, status: http.StatusOK}
+			next.ServeHTTP(w, request)
+			if w.status/100 == 5 && w.status != http.StatusInsufficientStorage {
+				statsd.Timing(request.Method+".errors.timing", time.Since(start))
+			} else {
+				statsd.Timing(request.Method+".timing", time.Since(start))
+			}
+		})
+	}
+}
@@ -36,6 +36,7 @@ import (
 	"github.com/troubling/hummingbird/common/ring"
 	"github.com/troubling/hummingbird/common/srv"
 	"github.com/troubling/hummingbird/middleware"
+	"github.com/uber-go/tally"
 	"go.uber.org/zap"
 )
 
@@ -57,6 +58,8 @@ type AuditorDaemon struct {
 	reconCachePath    string
 	hashPathPrefix    string
 	hashPathSuffix    string
+	reclaimAge        int64
+	metricsScope      tally.Scope
 }
 
 // Auditor keeps track of general audit data.
@@ -70,6 +73,8 @@ type Auditor struct {
 	bytesProcessed, totalBytes    int64
 	quarantines, totalQuarantines int64
 	errors, totalErrors           int64
+	reclaims, totalReclaims       int64
+	progress                      *middleware.ProgressReporter
 }
 
 func slowCopyMd5(file *os.File, bps int64) (int64, string, error) {
@@ -375,7 +380,7 @@ func (a *Auditor) auditDB(devPath string, objRing ring.Ring, policy *conf.Policy
 		a.logger.Error("No auditor set policy", zap.String("policy-type", policy.Type), zap.Int("policy-index", policy.Index))
 		return
 	}
-	db, err := NewIndexDB(dbpath, path, temppath, ringPartPower, int(dbPartPower), subdirs, 0, zapLogger, a.idbAuditors[policy.Index])
+	db, err := NewIndexDB(dbpath, path, temppath, ringPartPower, int(dbPartPower), subdirs, fs.Reserve{}, zapLogger, a.idbAuditors[policy.Index])
 	if err != nil {
 		a.errors++
 		a.totalErrors++
@@ -400,6 +405,7 @@ func (a *Auditor) auditDB(devPath string, objRing ring.Ring, policy *conf.Policy
 			}
 			a.passes++
 			a.totalPasses++
+			a.progress.Processed(1)
 			var bytesPerSecond int64
 			if a.auditorType != "ZBF" {
 				bytesPerSecond = a.bytesPerSecond
@@ -416,6 +422,7 @@ func (a *Auditor) auditDB(devPath string, objRing ring.Ring, policy *conf.Policy
 					}
 					a.quarantines++
 					a.totalQuarantines++
+					a.progress.Failed(1)
 				}
 			}
 			a.bytesProcessed += bytes
@@ -436,22 +443,28 @@ func (a *Auditor) auditDB(devPath string, objRing ring.Ring, policy *conf.Policy
 
 // auditSuffix directory.  Lists hash dirs, calls auditHash() for each, and quarantines any with errors.
 func (a *Auditor) auditSuffix(suffixDir string) {
-	hashes, err := fs.ReadDirNames(suffixDir)
+	hashes, err := fs.ReadDirEntries(suffixDir)
 	if err != nil {
 		a.errors++
 		a.totalErrors++
 		a.logger.Error("Error reading suffix dir", zap.String("suffixDir", suffixDir), zap.Error(err))
 		return
 	}
-	for _, hash := range hashes {
-		_, hexErr := hex.DecodeString(hash)
-		hashDir := filepath.Join(suffixDir, hash)
-		if finfo, err := os.Stat(hashDir); err != nil || len(hash) != 32 || hexErr != nil || !finfo.Mode().IsDir() {
-			a.logger.Error("Skipping invalid file in suffix", zap.String("hashDir", hashDir), zap.Error(err))
+	for _, entry := range hashes {
+		_, hexErr := hex.DecodeString(entry.Name)
+		hashDir := filepath.Join(suffixDir, entry.Name)
+		if len(entry.Name) != 32 || hexErr != nil || !entry.IsDir {
+			a.logger.Error("Skipping invalid file in suffix", zap.String("hashDir", hashDir))
 			continue
 		}
 		a.passes++
 		a.totalPasses++
+		a.progress.Processed(1)
+		if files, err := fs.ReadDirNames(hashDir); err == nil && ReclaimHashDir(hashDir, files, a.reclaimAge) {
+			a.reclaims++
+			a.totalReclaims++
+			continue
+		}
 		var bps int64
 		if a.auditorType != "ZBF" {
 			bps = a.bytesPerSecond
@@ -472,27 +485,29 @@ func (a *Auditor) auditSuffix(suffixDir string) {
 			InvalidateHash(hashDir)
 			a.quarantines++
 			a.totalQuarantines++
+			a.progress.Failed(1)
 		}
 	}
 }
 
 // auditPartition directory.  Lists suffixes in the partition and calls auditSuffix() for each.
 func (a *Auditor) auditPartition(partitionDir string) {
-	suffixes, err := fs.ReadDirNames(partitionDir)
+	suffixes, err := fs.ReadDirEntries(partitionDir)
 	if err != nil {
 		a.errors++
 		a.totalErrors++
 		a.logger.Error("Error reading partition dir ", zap.String("partitionDir", partitionDir), zap.Error(err))
 		return
 	}
-	for _, suffix := range suffixes {
+	for _, entry := range suffixes {
+		suffix := entry.Name
 		suffixDir := filepath.Join(partitionDir, suffix)
 		if suffix == ".lock" || suffix == "hashes.pkl" || suffix == "hashes.invalid" {
 			continue
 		}
 		_, hexErr := strconv.ParseInt(suffix, 16, 64)
-		if finfo, err := os.Stat(suffixDir); err != nil || len(suffix) != 3 || hexErr != nil || !finfo.Mode().IsDir() {
-			a.logger.Error("Skipping invalid file in partition.", zap.String("suffixDir", suffixDir), zap.Error(err))
+		if len(suffix) != 3 || hexErr != nil || !entry.IsDir {
+			a.logger.Error("Skipping invalid file in partition.", zap.String("suffixDir", suffixDir))
 			continue
 		}
 		a.auditSuffix(suffixDir)
@@ -514,7 +529,7 @@ func (a *Auditor) auditDevice(devPath string) {
 	for _, policy := range a.policies {
 		if policy.Type == "replication" {
 			objPath := filepath.Join(devPath, PolicyDir(policy.Index))
-			partitions, err := fs.ReadDirNames(objPath)
+			partitions, err := fs.ReadDirEntries(objPath)
 			if err != nil {
 				if !os.IsNotExist(err) {
 					a.errors++
@@ -523,12 +538,12 @@ func (a *Auditor) auditDevice(devPath string) {
 				}
 				continue
 			}
-			for _, partition := range partitions {
-				_, intErr := strconv.ParseInt(partition, 10, 64)
-				partitionDir := filepath.Join(objPath, partition)
-				if finfo, err := os.Stat(partitionDir); err != nil || intErr != nil || !finfo.Mode().IsDir() {
+			for _, entry := range partitions {
+				_, intErr := strconv.ParseInt(entry.Name, 10, 64)
+				partitionDir := filepath.Join(objPath, entry.Name)
+				if intErr != nil || !entry.IsDir {
 					a.logger.Error("Skipping invalid file in objects directory",
-						zap.String("partitionDir", partitionDir), zap.Error(err))
+						zap.String("partitionDir", partitionDir))
 					continue
 				}
 				a.auditPartition(partitionDir)
@@ -563,20 +578,24 @@ func (a *Auditor) statsReport() {
 		zap.Float64("bytes/sec", brate),
 		zap.Float64("Total time", total),
 		zap.Float64("Auditing Time", audit),
-		zap.Float64("Auditing Rate", audit_rate))
+		zap.Float64("Auditing Rate", audit_rate),
+		zap.Int64("Locally reclaimed", a.reclaims))
 
 	middleware.DumpReconCache(a.reconCachePath, "object",
 		map[string]interface{}{"object_auditor_stats_" + a.auditorType: map[string]interface{}{
 			"errors":          a.errors,
 			"passes":          a.passes,
 			"quarantined":     a.quarantines,
+			"reclaimed":       a.reclaims,
 			"bytes_processed": a.bytesProcessed,
 			"start_time":      float64(a.passStart.UnixNano()) / float64(time.Second), //???
 			"audit_time":      audit,
 		}})
+	a.progress.Report()
 	a.passes = 0
 	a.quarantines = 0
 	a.errors = 0
+	a.reclaims = 0
 	a.bytesProcessed = 0
 	a.lastLog = now
 }
@@ -597,22 +616,26 @@ func (a *Auditor) finalLog() {
 		zap.Float64("Total files/sec", frate),
 		zap.Float64("Total bytes/sec", brate),
 		zap.Float64("Auditing time", audit),
-		zap.Float64("Auditing rate", audit_rate))
+		zap.Float64("Auditing rate", audit_rate),
+		zap.Int64("Total reclaimed", a.totalReclaims))
 }
 
 // run audit passes of the whole server until c is closed.
 func (a *Auditor) run(c <-chan time.Time) {
 	for a.passStart = range c {
+		a.progress.StartCycle(0)
 		middleware.DumpReconCache(a.reconCachePath, "object",
 			map[string]interface{}{"object_auditor_stats_" + a.auditorType: nil})
 		a.passes = 0
 		a.bytesProcessed = 0
 		a.quarantines = 0
 		a.errors = 0
+		a.reclaims = 0
 		a.totalPasses = 0
 		a.totalBytes = 0
 		a.totalQuarantines = 0
 		a.totalErrors = 0
+		a.totalReclaims = 0
 		a.logger.Info("Begin object audit",
 			zap.String("mode", a.mode),
 			zap.String("auditorType", a.auditorType),
@@ -623,9 +646,31 @@ func (a *Auditor) run(c <-chan time.Time) {
 			continue
 		}
 		for _, dev := range devices {
+			passes, bytes, quarantines, errors, reclaims := a.totalPasses, a.totalBytes, a.totalQuarantines, a.totalErrors, a.totalReclaims
 			a.auditDevice(filepath.Join(a.driveRoot, dev))
+			scope := a.metricsScope.Tagged(map[string]string{"device": dev, "auditor_type": a.auditorType})
+			scope.Counter("passes").Inc(a.totalPasses - passes)
+			scope.Counter("bytes_processed").Inc(a.totalBytes - bytes)
+			scope.Counter("quarantines").Inc(a.totalQuarantines - quarantines)
+			scope.Counter("errors").Inc(a.totalErrors - errors)
+			scope.Counter("reclaims").Inc(a.totalReclaims - reclaims)
 		}
 		a.finalLog()
+		if err := a.progress.EndCycle(); err != nil {
+			a.logger.Error("Error saving audit progress", zap.String("auditorType", a.auditorType), zap.Error(err))
+		}
+	}
+}
+
+// newAuditor returns an Auditor of the given type, reporting its progress as
+// object-auditor-all or object-auditor-zbf.
+func (d *AuditorDaemon) newAuditor(auditorType, mode string, filesPerSecond int64) *Auditor {
+	return &Auditor{
+		AuditorDaemon:  d,
+		auditorType:    auditorType,
+		mode:           mode,
+		filesPerSecond: filesPerSecond,
+		progress:       middleware.NewProgressReporter(d.reconCachePath, "object", "object-auditor-"+strings.ToLower(auditorType), d.metricsScope),
 	}
 }
 
@@ -635,12 +680,12 @@ func (d *AuditorDaemon) Run() {
 	if d.zbFilesPerSecond > 0 {
 		wg.Add(1)
 		go func() {
-			zba := Auditor{AuditorDaemon: d, auditorType: "ZBF", mode: "once", filesPerSecond: d.zbFilesPerSecond}
+			zba := d.newAuditor("ZBF", "once", d.zbFilesPerSecond)
 			zba.run(OneTimeChan())
 			wg.Done()
 		}()
 	}
-	reg := Auditor{AuditorDaemon: d, auditorType: "ALL", mode: "once", filesPerSecond: d.regFilesPerSecond}
+	reg := d.newAuditor("ALL", "once", d.regFilesPerSecond)
 	reg.run(OneTimeChan())
 	wg.Wait()
 }
@@ -648,10 +693,10 @@ func (d *AuditorDaemon) Run() {
 // RunForever triggering audit passes every time AuditForeverInterval has passed.
 func (d *AuditorDaemon) RunForever() {
 	if d.zbFilesPerSecond > 0 {
-		zba := Auditor{AuditorDaemon: d, auditorType: "ZBF", mode: "forever", filesPerSecond: d.zbFilesPerSecond}
+		zba := d.newAuditor("ZBF", "forever", d.zbFilesPerSecond)
 		go zba.run(time.Tick(AuditForeverInterval))
 	}
-	reg := Auditor{AuditorDaemon: d, auditorType: "ALL", mode: "forever", filesPerSecond: d.regFilesPerSecond}
+	reg := d.newAuditor("ALL", "forever", d.regFilesPerSecond)
 	reg.run(time.Tick(AuditForeverInterval))
 }
 
@@ -661,7 +706,7 @@ func NewAuditorDaemon(serverconf conf.Config, flags *flag.FlagSet, cnf srv.Confi
 	if !serverconf.HasSection("object-auditor") {
 		return nil, fmt.Errorf("Unable to find object-auditor config section")
 	}
-	d := &AuditorDaemon{}
+	d := &AuditorDaemon{metricsScope: tally.NoopScope}
 	if d.policies, err = cnf.GetPolicies(); err != nil {
 		return nil, err
 	}
@@ -692,5 +737,6 @@ func NewAuditorDaemon(serverconf conf.Config, flags *flag.FlagSet, cnf srv.Confi
 	d.zbFilesPerSecond = serverconf.GetInt("object-auditor", "zero_byte_files_per_second", 50)
 	d.reconCachePath = serverconf.GetDefault("object-auditor", "recon_cache_path", "/var/cache/swift")
 	d.logTime = serverconf.GetInt("object-auditor", "log_time", 3600)
+	d.reclaimAge = objectReclaimAge(serverconf)
 	return d, nil
 }
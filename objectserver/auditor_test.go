@@ -28,9 +28,11 @@ import (
 
 	"github.com/troubling/hummingbird/common"
 	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/fs"
 	"github.com/troubling/hummingbird/common/pickle"
 	"github.com/troubling/hummingbird/common/srv"
 	"github.com/troubling/hummingbird/common/test"
+	"github.com/uber-go/tally"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
@@ -236,7 +238,7 @@ func makeAuditor(t *testing.T, confLoader *srv.TestConfigLoader, settings ...str
 	require.Nil(t, err)
 	obs, logs = observer.New(zap.InfoLevel)
 	auditorDaemon.logger = zap.New(obs)
-	a := &Auditor{AuditorDaemon: auditorDaemon, filesPerSecond: 1}
+	a := auditorDaemon.newAuditor("", "", 1)
 	a.idbAuditors = map[int]IndexDBAuditor{0: ecAuditor{}}
 	return a
 }
@@ -310,6 +312,24 @@ func TestAuditSuffixSkipsBad(t *testing.T) {
 	assert.Equal(t, logs.TakeAll()[0].Message, "Skipping invalid file in suffix")
 }
 
+func TestAuditSuffixReclaims(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+	hashDir := filepath.Join(dir, "objects", "1", "abc", "fffffffffffffffffffffffffffffabc")
+	os.MkdirAll(hashDir, 0777)
+	f, _ := os.Create(filepath.Join(hashDir, "12345.ts"))
+	defer f.Close()
+	common.SwiftObjectWriteMetadata(f.Fd(), map[string]string{"name": "somename", "X-Timestamp": "12345"})
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	auditor := makeAuditor(t, confLoader)
+	auditor.auditSuffix(filepath.Join(dir, "objects", "1", "abc"))
+	assert.Equal(t, int64(1), auditor.totalReclaims)
+	assert.Equal(t, int64(0), auditor.totalQuarantines)
+	_, err := os.Stat(hashDir)
+	assert.True(t, os.IsNotExist(err))
+}
+
 func TestAuditPartitionNotDir(t *testing.T) {
 	testRing := &test.FakeRing{}
 	confLoader := srv.NewTestConfigLoader(testRing)
@@ -434,6 +454,7 @@ func TestFinalLog(t *testing.T) {
 	auditor.passStart = time.Now().Add(-60 * time.Second)
 	auditor.totalQuarantines = 5
 	auditor.totalErrors = 3
+	auditor.totalReclaims = 7
 	auditor.totalPasses = 120
 	auditor.totalBytes = 120000
 	auditor.auditorType = "ALL"
@@ -449,7 +470,8 @@ func TestFinalLog(t *testing.T) {
 			zap.Float64("Total files/sec", 2.00),
 			zap.Float64("Total bytes/sec", 2000.00),
 			zap.Float64("Auditing time", 0.00),
-			zap.Float64("Auditing rate", 0.00)},
+			zap.Float64("Auditing rate", 0.00),
+			zap.Int64("Total reclaimed", 7)},
 	}}
 	obslog := logs.AllUntimed()[0]
 	require.Equal(t, want[0].Message, obslog.Message)
@@ -463,6 +485,7 @@ func TestFinalLog(t *testing.T) {
 	//require.Equal(t, want[0].Context[6], obslog.Context[6])
 	require.Equal(t, want[0].Context[7], obslog.Context[7])
 	require.Equal(t, want[0].Context[8], obslog.Context[8])
+	require.Equal(t, want[0].Context[9], obslog.Context[9])
 }
 
 func TestAuditRun(t *testing.T) {
@@ -493,6 +516,7 @@ func TestStatReport(t *testing.T) {
 	auditor.bytesProcessed = 120000
 	auditor.quarantines = 17
 	auditor.errors = 41
+	auditor.reclaims = 9
 	auditor.statsReport()
 	want := []observer.LoggedEntry{{
 		Entry: zapcore.Entry{Level: zap.InfoLevel, Message: "statsReport"},
@@ -505,7 +529,8 @@ func TestStatReport(t *testing.T) {
 			zap.Float64("bytes/sec", 2),
 			zap.Float64("Total time", 3),
 			zap.Float64("Auditing Time", 0.00),
-			zap.Float64("Auditing Rate", 0.00)},
+			zap.Float64("Auditing Rate", 0.00),
+			zap.Int64("Locally reclaimed", 9)},
 	}}
 	obslog := logs.AllUntimed()[0]
 	require.Equal(t, want[0].Message, obslog.Message)
@@ -520,6 +545,7 @@ func TestStatReport(t *testing.T) {
 	//require.Equal(t, want[0].Context[7], obslog.Context[7])
 	require.Equal(t, want[0].Context[8], obslog.Context[8])
 	require.Equal(t, want[0].Context[9], obslog.Context[9])
+	require.Equal(t, want[0].Context[10], obslog.Context[10])
 }
 
 func TestAuditDB(t *testing.T) {
@@ -535,7 +561,7 @@ func TestAuditDB(t *testing.T) {
 	policydir := filepath.Join(dir, "objects-2")
 	dbdir := filepath.Join(policydir, "hec.db")
 	hecdir := filepath.Join(policydir, "hec")
-	db, err := NewIndexDB(dbdir, hecdir, dir, 2, 1, 32, 0, zap.L(), fakeIndexDBAuditor{})
+	db, err := NewIndexDB(dbdir, hecdir, dir, 2, 1, 32, fs.Reserve{}, zap.L(), fakeIndexDBAuditor{})
 	assert.Nil(t, err)
 	body := "some shard content nonsense"
 	shardHash := "d3ac5112fe464b81184352ccba743001"
@@ -696,7 +722,7 @@ func TestQuarantineShard(t *testing.T) {
 	policydir := filepath.Join(dir, "objects")
 	dbdir := filepath.Join(policydir, "hec.db")
 	hecdir := filepath.Join(policydir, "hec")
-	db, err := NewIndexDB(dbdir, hecdir, dir, 2, 1, 32, 0, zap.L(), fakeIndexDBAuditor{})
+	db, err := NewIndexDB(dbdir, hecdir, dir, 2, 1, 32, fs.Reserve{}, zap.L(), fakeIndexDBAuditor{})
 	timestamp := time.Now().UnixNano()
 	hash := "00000000000000000000000000000000"
 	body := "nonsense"
@@ -730,3 +756,27 @@ func TestQuarantineShard(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Nil(t, dbitem)
 }
+
+func TestAuditRunMetrics(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "sda", "objects", "1", "abc", "fffffffffffffffffffffffffffffabc"), 0777)
+	f, _ := os.Create(filepath.Join(dir, "sda", "objects", "1", "abc", "fffffffffffffffffffffffffffffabc", "12345.data"))
+	defer f.Close()
+	common.SwiftObjectWriteMetadata(f.Fd(), map[string]string{"Content-Length": "12", "ETag": "d3ac5112fe464b81184352ccba743001", "name": "", "Content-Type": "", "X-Timestamp": ""})
+	f.Write([]byte("testcontents"))
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	auditor := makeAuditor(t, confLoader, "mount_check", "false")
+	auditor.driveRoot = dir
+	auditor.auditorType = "ALL"
+	scope := tally.NewTestScope("", nil)
+	auditor.metricsScope = scope
+	auditor.run(OneTimeChan())
+	counters := scope.Snapshot().Counters()
+	require.Equal(t, int64(1), counters["passes+auditor_type=ALL,device=sda"].Value())
+	require.Equal(t, int64(12), counters["bytes_processed+auditor_type=ALL,device=sda"].Value())
+	require.Equal(t, int64(0), counters["errors+auditor_type=ALL,device=sda"].Value())
+	require.Equal(t, int64(1), auditor.progress.Progress().Processed)
+	require.Equal(t, int64(0), auditor.progress.Progress().Failures)
+}
@@ -36,6 +36,21 @@ flow looks like:
 		}
 	}
 
+The replicator asks for protocol extensions in an X-Repconn-Features header on
+the REPCONN request, and the server answers with the ones it agrees to:
+
+	persistent: after the replicator's final SyncFileRequest{Done: true}, the
+	connection waits for another BeginReplicationRequest, so the next partition
+	between the same devices can reuse it.
+	batch: a SyncFileRequest{Batch []SyncFileRequest} checks many small files
+	at once.  The server saves those without data, like tombstones, right away,
+	and responds with a SyncBatchResponse{Files []SyncFileResponse}.  The
+	replicator then sends the bodies of the files it was told to go ahead with,
+	back to back, and the server responds with a
+	FileUploadBatchResponse{Files []FileUploadResponse}.
+	compress: messages may be flate compressed, marked by the top bit of their
+	length prefix.
+
 The replicator limits concurrency per-device and overall.  When the server
 gets a BeginReplicationRequest, it'll wait up to 60 seconds for a slot to open
 up before rejecting it.
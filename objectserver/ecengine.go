@@ -49,7 +49,7 @@ type ecEngine struct {
 	driveRoot       string
 	hashPathPrefix  string
 	hashPathSuffix  string
-	reserve         int64
+	reserve         fs.Reserve
 	policy          int
 	ring            ring.Ring
 	idbs            map[string]*IndexDB
@@ -279,7 +279,10 @@ func (f *ecEngine) ecNurseryPutHandler(writer http.ResponseWriter, request *http
 	var atm fs.AtomicFileWriter
 	if !deletion {
 		atm, err = idb.TempFile(vars["hash"], 0, timestamp, 0, true)
-		if err != nil {
+		if err == fs.ErrNotEnoughReserve {
+			srv.StandardResponse(writer, http.StatusInsufficientStorage)
+			return
+		} else if err != nil {
 			srv.GetLogger(request).Error("Error opening file for writing", zap.Error(err))
 			srv.StandardResponse(writer, http.StatusInternalServerError)
 			return
@@ -378,8 +381,9 @@ func (f *ecEngine) GetObjectsToReplicate(prirep PriorityRepJob, c chan ObjectSta
 	if len(items) == 0 {
 		return
 	}
-	url := fmt.Sprintf("%s://%s:%d/ec-partition/%s/%d", prirep.ToDevice.Scheme, prirep.ToDevice.Ip, prirep.ToDevice.Port, prirep.ToDevice.Device, prirep.Partition)
+	url := fmt.Sprintf("%s://%s:%d%s/ec-partition/%s/%d", prirep.ToDevice.Scheme, prirep.ToDevice.Ip, prirep.ToDevice.Port, prirep.ToDevice.PathPrefix, prirep.ToDevice.Device, prirep.Partition)
 	req, err := http.NewRequest("GET", url, nil)
+	req.Header.Set(common.PriorityHeader, common.PriorityReplication)
 	req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(prirep.Policy))
 	req.Header.Set("User-Agent", "nursery-stabilizer")
 	resp, err := f.client.Do(req)
@@ -539,7 +543,10 @@ func (f *ecEngine) GetObjectsToStabilize(device string, c chan ObjectStabilizer,
 // ecEngineConstructor creates a ecEngine given the object server configs.
 func ecEngineConstructor(config conf.Config, policy *conf.Policy, flags *flag.FlagSet) (ObjectEngine, error) {
 	driveRoot := config.GetDefault("app:object-server", "devices", "/srv/node")
-	reserve := config.GetInt("app:object-server", "fallocate_reserve", 0)
+	reserve, err := fs.ParseReserve(config.GetDefault("app:object-server", "fallocate_reserve", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid fallocate_reserve: %v", err)
+	}
 	hashPathPrefix, hashPathSuffix, err := conf.GetHashPrefixAndSuffix()
 	if err != nil {
 		return nil, errors.New("Unable to load hashpath prefix and suffix")
@@ -584,7 +591,7 @@ func ecEngineConstructor(config conf.Config, policy *conf.Policy, flags *flag.Fl
 	logLevel.UnmarshalText([]byte(strings.ToLower(logLevelString)))
 	httpClient := &http.Client{
 		Timeout:   120 * time.Minute,
-		Transport: transport,
+		Transport: srv.NewSigningTransport(transport),
 	}
 	engine := &ecEngine{
 		driveRoot:      driveRoot,
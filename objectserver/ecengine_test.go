@@ -32,6 +32,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/troubling/hummingbird/common"
 	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/fs"
 	"github.com/troubling/hummingbird/common/ring"
 	"github.com/troubling/hummingbird/common/srv"
 	"github.com/troubling/hummingbird/common/test"
@@ -79,7 +80,7 @@ func getTestEce() (*ecEngine, string, error) {
 		driveRoot:      driveRoot,
 		hashPathPrefix: "a",
 		hashPathSuffix: "b",
-		reserve:        0,
+		reserve:        fs.Reserve{},
 		policy:         0,
 		logger:         logger,
 		ring:           testRing,
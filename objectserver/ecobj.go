@@ -47,7 +47,7 @@ type ecObject struct {
 	metadata        map[string]string
 	ring            ring.Ring
 	logger          srv.LowLevelLogger
-	reserve         int64
+	reserve         fs.Reserve
 	dataShards      int
 	parityShards    int
 	chunkSize       int
@@ -130,6 +130,22 @@ func (o *ecObject) Copy(dsts ...io.Writer) (written int64, err error) {
 		return 0, fmt.Errorf("Not enough nodes (%d) for scheme (%d)", len(nodes), dataShards+parityShards)
 	}
 
+	bodies, closeBodies, err := o.getShards(nodes, dataShards, parityShards, "")
+	if err != nil {
+		return 0, err
+	}
+	defer closeBodies()
+	return contentLength, ecGlue(dataShards, parityShards, bodies, chunkSize, contentLength, dsts...)
+}
+
+// getShards requests the object's data shards from their nodes at once,
+// requesting a parity shard whenever one of those fails or a little time
+// passes without enough of them answering, until dataShards shards are
+// available to glue together. A node answering with some other shard than
+// the one asked for counts as a failure, so the bodies are always indexed by
+// the shard they hold. rangeHeader, if set, is sent along to read the same
+// part of each shard. The returned func closes the bodies.
+func (o *ecObject) getShards(nodes []*ring.Device, dataShards, parityShards int, rangeHeader string) ([]io.Reader, func(), error) {
 	type bod struct {
 		i   int
 		bod io.ReadCloser
@@ -138,7 +154,7 @@ func (o *ecObject) Copy(dsts ...io.Writer) (written int64, err error) {
 	errs := make(chan error)
 	done := make(chan struct{})
 	grabShard := func(i int, node *ring.Device) {
-		req, err := http.NewRequest("GET", fmt.Sprintf("%s://%s:%d/ec-shard/%s/%s/%d", node.Scheme, node.Ip, node.Port, node.Device, o.Hash, i), nil)
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s://%s:%d%s/ec-shard/%s/%s/%d", node.Scheme, node.Ip, node.Port, node.PathPrefix, node.Device, o.Hash, i), nil)
 		if err != nil {
 			select {
 			case errs <- err:
@@ -149,17 +165,27 @@ func (o *ecObject) Copy(dsts ...io.Writer) (written int64, err error) {
 		req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(o.policy))
 		req.Header.Set("X-Shard-Timestamp", strconv.FormatInt(o.Timestamp, 10))
 		req.Header.Set("X-Trans-Id", o.txnId)
-		if resp, err := o.client.Do(req); err == nil && resp.StatusCode == http.StatusOK {
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		resp, err := o.client.Do(req)
+		if err == nil {
+			if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+				err = fmt.Errorf("Bad status code %d", resp.StatusCode)
+			} else if index := resp.Header.Get("Ec-Shard-Index"); index != "" && index != strconv.Itoa(i) {
+				err = fmt.Errorf("Asked for shard %d, got shard %s", i, index)
+			}
+			if err != nil {
+				resp.Body.Close()
+			}
+		}
+		if err == nil {
 			select {
 			case bods <- &bod{i: i, bod: resp.Body}:
 			case <-done:
 				resp.Body.Close()
 			}
 		} else {
-			if err == nil {
-				err = fmt.Errorf("Bad status code %d", resp.StatusCode)
-				resp.Body.Close()
-			}
 			select {
 			case errs <- err:
 			case <-done:
@@ -167,6 +193,12 @@ func (o *ecObject) Copy(dsts ...io.Writer) (written int64, err error) {
 		}
 	}
 	bodies := make([]io.Reader, len(nodes))
+	var closers []io.Closer
+	closeBodies := func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}
 	bodcount := 0
 	errcount := 0
 	// launch requests for the object's data shards
@@ -179,18 +211,20 @@ func (o *ecObject) Copy(dsts ...io.Writer) (written int64, err error) {
 	for {
 		select {
 		case b := <-bods:
-			defer b.bod.Close()
+			closers = append(closers, b.bod)
 			bodies[b.i] = b.bod
 			bodcount++
 			if bodcount >= dataShards {
 				close(done)
-				return contentLength, ecGlue(dataShards, parityShards, bodies, chunkSize, contentLength, dsts...)
+				return bodies, closeBodies, nil
 			}
 		// if we get an error or a little time passes, request a parity shard.
 		case err := <-errs:
+			o.logger.Debug("Unable to get EC shard", zap.String("hash", o.Hash), zap.Error(err))
 			if errcount++; errcount > parityShards {
 				close(done)
-				return 0, fmt.Errorf("Unable to retrieve enough shards to reconstruct: %v", err)
+				closeBodies()
+				return nil, nil, fmt.Errorf("Unable to retrieve enough shards to reconstruct: %v", err)
 			} else if nodeI < len(nodes) {
 				go grabShard(nodeI, nodes[nodeI])
 				nodeI++
@@ -236,33 +270,19 @@ func (o *ecObject) CopyRange(w io.Writer, start int64, end int64) (int64, error)
 	if len(nodes) < dataShards+parityShards {
 		return 0, fmt.Errorf("Not enough nodes (%d) for scheme (%d)", len(nodes), dataShards+parityShards)
 	}
-	// round the range start(down) and end(up) to chunk boundaries
-	shardStart, shardEnd := rangeChunkAlign(start, end, int64(chunkSize), dataShards)
-	if shardEnd > contentLength {
-		shardEnd = contentLength
+	shardStart, shardEnd, glueLength, skip := ecRangePlan(start, end, contentLength, int64(chunkSize), dataShards)
+	if glueLength <= 0 {
+		return 0, nil
 	}
-	bodies := make([]io.Reader, len(nodes))
-	// TODO: This could be parallelized, and we can probably stop looking once we have dataShards bodies available.
-	for i, node := range nodes {
-		req, err := http.NewRequest("GET", fmt.Sprintf("%s://%s:%d/ec-shard/%s/%s/%d", node.Scheme, node.Ip, node.Port, node.Device, o.Hash, i), nil)
-		if err != nil {
-			continue
-		}
-		req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(o.policy))
-		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", shardStart, shardEnd))
-		req.Header.Set("X-Trans-Id", o.txnId)
-		resp, err := o.client.Do(req)
-		if err != nil {
-			continue
-		}
-		defer resp.Body.Close()
-		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-			continue
-		}
-		bodies[i] = resp.Body
+	bodies, closeBodies, err := o.getShards(nodes, dataShards, parityShards, fmt.Sprintf("bytes=%d-%d", shardStart, shardEnd-1))
+	if err != nil {
+		return 0, err
+	}
+	defer closeBodies()
+	if err := ecGlue(dataShards, parityShards, bodies, chunkSize, glueLength,
+		&rangeBytesWriter{startOffset: skip, length: end - start, writer: w}); err != nil {
+		return 0, err
 	}
-	err = ecGlue(dataShards, parityShards, bodies, chunkSize, shardEnd-shardStart,
-		&rangeBytesWriter{startOffset: start % int64(chunkSize), length: end - start, writer: w})
 	return end - start, nil
 }
 
@@ -273,7 +293,9 @@ func (o *ecObject) Repr() string {
 func (o *ecObject) SetData(size int64) (io.Writer, error) {
 	var err error
 	o.Close()
-	if o.afw, err = o.idb.TempFile(o.Hash, 0, math.MaxInt64, size, true); err != nil {
+	if o.afw, err = o.idb.TempFile(o.Hash, 0, math.MaxInt64, size, true); err == fs.ErrNotEnoughReserve {
+		return nil, DriveFullError
+	} else if err != nil {
 		return nil, fmt.Errorf("Error creating temp file: %v", err)
 	}
 	if err := o.afw.Preallocate(size, o.reserve); err != nil {
@@ -346,7 +368,7 @@ func (o *ecObject) Reconstruct() error {
 	readFails := 0
 	failed := make([]*ring.Device, len(nodes))
 	for i, node := range nodes {
-		url := fmt.Sprintf("%s://%s:%d/ec-shard/%s/%s/%d", node.Scheme, node.Ip, node.Port, node.Device, o.Hash, i)
+		url := fmt.Sprintf("%s://%s:%d%s/ec-shard/%s/%s/%d", node.Scheme, node.Ip, node.Port, node.PathPrefix, node.Device, o.Hash, i)
 		req, err := http.NewRequest("GET", url, nil)
 		if err != nil {
 			o.logger.Error("NewRequest failed", zap.String("url", url))
@@ -354,6 +376,7 @@ func (o *ecObject) Reconstruct() error {
 			readFails++
 			continue
 		}
+		req.Header.Set(common.PriorityHeader, common.PriorityReplication)
 		req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(o.policy))
 		req.Header.Set("X-Trans-Id", o.txnId)
 		resp, err := o.client.Do(req)
@@ -393,7 +416,7 @@ func (o *ecObject) Reconstruct() error {
 		rp, wp := io.Pipe()
 		defer wp.Close()
 		defer rp.Close()
-		url := fmt.Sprintf("%s://%s:%d/ec-shard/%s/%s/%d", node.Scheme, node.Ip, node.Port, node.Device, o.Hash, i)
+		url := fmt.Sprintf("%s://%s:%d%s/ec-shard/%s/%s/%d", node.Scheme, node.Ip, node.Port, node.PathPrefix, node.Device, o.Hash, i)
 		req, err := http.NewRequest("PUT", url, rp)
 		if err != nil {
 			nodeFails++
@@ -401,6 +424,7 @@ func (o *ecObject) Reconstruct() error {
 			continue
 		}
 		req.ContentLength = ecShardLength(o.ContentLength(), o.dataShards)
+		req.Header.Set(common.PriorityHeader, common.PriorityReplication)
 		req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(o.policy))
 		req.Header.Set("X-Trans-Id", o.txnId)
 		req.Header.Set("Meta-Ec-Scheme", fmt.Sprintf("reedsolomon/%d/%d/%d", o.dataShards, o.parityShards, o.chunkSize))
@@ -460,11 +484,12 @@ func (o *ecObject) Replicate(prirep PriorityRepJob) error {
 			return err
 		}
 		defer fp.Close()
-		req, err := http.NewRequest("PUT", fmt.Sprintf("%s://%s:%d/ec-shard/%s/%s/%d", prirep.ToDevice.Scheme, prirep.ToDevice.Ip, prirep.ToDevice.Port, prirep.ToDevice.Device, o.Hash, o.Shard), fp)
+		req, err := http.NewRequest("PUT", fmt.Sprintf("%s://%s:%d%s/ec-shard/%s/%s/%d", prirep.ToDevice.Scheme, prirep.ToDevice.Ip, prirep.ToDevice.Port, prirep.ToDevice.PathPrefix, prirep.ToDevice.Device, o.Hash, o.Shard), fp)
 		if err != nil {
 			return err
 		}
 		req.ContentLength = ecShardLength(o.ContentLength(), o.dataShards)
+		req.Header.Set(common.PriorityHeader, common.PriorityReplication)
 		req.Header.Set("X-Timestamp", o.metadata["X-Timestamp"])
 		req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(o.policy))
 		req.Header.Set("X-Trans-Id", o.txnId)
@@ -511,11 +536,12 @@ func (o *ecObject) nurseryReplicate(partition uint64, dev *ring.Device) error {
 		defer rp.Close()
 		defer wp.Close()
 		wrs = append(wrs, wp)
-		req, err := http.NewRequest("PUT", fmt.Sprintf("%s://%s:%d/ec-nursery/%s/%s",
-			node.Scheme, node.ReplicationIp, node.ReplicationPort, node.Device, o.Hash), rp)
+		req, err := http.NewRequest("PUT", fmt.Sprintf("%s://%s:%d%s/ec-nursery/%s/%s",
+			node.Scheme, node.ReplicationIp, node.ReplicationPort, node.PathPrefix, node.Device, o.Hash), rp)
 		if err != nil {
 			return err
 		}
+		req.Header.Set(common.PriorityHeader, common.PriorityReplication)
 		req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(o.policy))
 		req.Header.Set("X-Trans-Id", o.txnId)
 		req.Header.Set("User-Agent", "nursery-stabilizer")
@@ -582,10 +608,11 @@ func (o *ecObject) restabilize(dev *ring.Device) error {
 		return fmt.Errorf("Ring doesn't match EC scheme (%d != %d).", len(nodes), o.dataShards+o.parityShards)
 	}
 	for i, node := range nodes {
-		req, err := http.NewRequest("POST", fmt.Sprintf("%s://%s:%d/ec-shard/%s/%s/%d", node.Scheme, node.Ip, node.Port, node.Device, o.Hash, i), nil)
+		req, err := http.NewRequest("POST", fmt.Sprintf("%s://%s:%d%s/ec-shard/%s/%s/%d", node.Scheme, node.Ip, node.Port, node.PathPrefix, node.Device, o.Hash, i), nil)
 		if err != nil {
 			return err
 		}
+		req.Header.Set(common.PriorityHeader, common.PriorityReplication)
 		req.Header.Set("X-Timestamp", o.metadata["X-Timestamp"])
 		req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(o.policy))
 		req.Header.Set("X-Trans-Id", o.txnId)
@@ -632,8 +659,8 @@ func (o *ecObject) Stabilize(dev *ring.Device) error {
 		defer rp.Close()
 		defer wp.Close()
 		wrs[i] = wp
-		url := fmt.Sprintf("%s://%s:%d/ec-shard/%s/%s/%d", node.Scheme, node.ReplicationIp,
-			node.ReplicationPort, node.Device, o.Hash, i)
+		url := fmt.Sprintf("%s://%s:%d%s/ec-shard/%s/%s/%d", node.Scheme, node.ReplicationIp,
+			node.ReplicationPort, node.PathPrefix, node.Device, o.Hash, i)
 		method := "PUT"
 		if o.Deletion {
 			method = "DELETE"
@@ -645,6 +672,7 @@ func (o *ecObject) Stabilize(dev *ring.Device) error {
 		if !o.Deletion {
 			req.ContentLength = ecShardLength(o.ContentLength(), o.dataShards)
 		}
+		req.Header.Set(common.PriorityHeader, common.PriorityReplication)
 		req.Header.Set("X-Timestamp", o.metadata["X-Timestamp"])
 		req.Header.Set("Deletion", strconv.FormatBool(o.Deletion)) //TODO: this can be removed right?
 		req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(o.policy))
@@ -729,6 +757,27 @@ func rangeChunkAlign(start, end, chunkSize int64, dataShards int) (int64, int64)
 	return start, end
 }
 
+// ecRangePlan works out how to read bytes start through end (exclusive) of
+// an object of contentLength bytes: the range of each shard to request, how
+// many bytes of the object gluing those shard ranges together gives, and how
+// far into those the requested range begins. Every shard holds chunkSize of
+// each full stripe of chunkSize*dataShards bytes, and an even share of the
+// last, partial stripe.
+func ecRangePlan(start, end, contentLength, chunkSize int64, dataShards int) (shardStart, shardEnd, glueLength, skip int64) {
+	stripeSize := chunkSize * int64(dataShards)
+	if end > contentLength {
+		end = contentLength
+	}
+	shardStart, shardEnd = rangeChunkAlign(start, end, chunkSize, dataShards)
+	objStart := shardStart / chunkSize * stripeSize
+	objEnd := shardEnd / chunkSize * stripeSize
+	if objEnd > contentLength {
+		objEnd = contentLength
+		shardEnd = contentLength/stripeSize*chunkSize + ecShardLength(contentLength%stripeSize, dataShards)
+	}
+	return shardStart, shardEnd, objEnd - objStart, start - objStart
+}
+
 // rangeBytesWriter proxies a range of its received bytes to the underlying writer, discarding anything before `start` or after `length`
 type rangeBytesWriter struct {
 	startOffset int64
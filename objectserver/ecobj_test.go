@@ -28,6 +28,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -377,3 +378,97 @@ func TestRangeChunkAlign(t *testing.T) {
 		require.Equal(t, tc.expectedEnd, shardEnd)
 	}
 }
+
+func TestECRangePlan(t *testing.T) {
+	// 3 data shards of 10 byte chunks: full stripes are 30 bytes, and the
+	// last 15 bytes of a 75 byte object are split 5 to a shard.
+	shardStart, shardEnd, glueLength, skip := ecRangePlan(35, 70, 75, 10, 3)
+	require.Equal(t, int64(10), shardStart)
+	require.Equal(t, int64(25), shardEnd)
+	require.Equal(t, int64(45), glueLength)
+	require.Equal(t, int64(5), skip)
+	shardStart, shardEnd, glueLength, skip = ecRangePlan(0, 30, 75, 10, 3)
+	require.Equal(t, int64(0), shardStart)
+	require.Equal(t, int64(10), shardEnd)
+	require.Equal(t, int64(30), glueLength)
+	require.Equal(t, int64(0), skip)
+}
+
+type ecNodesRing struct {
+	*test.FakeRing
+	nodes []*ring.Device
+}
+
+func (r *ecNodesRing) GetNodes(partition uint64) []*ring.Device {
+	return r.nodes
+}
+
+type shardWriter struct {
+	bytes.Buffer
+}
+
+func (w *shardWriter) Close() error {
+	return nil
+}
+
+func TestECCopyRange(t *testing.T) {
+	data := make([]byte, 75)
+	for i := range data {
+		data[i] = byte('a' + i%26)
+	}
+	shards := make([]*shardWriter, 5)
+	writers := make([]io.WriteCloser, 5)
+	for i := range shards {
+		shards[i] = &shardWriter{}
+		writers[i] = shards[i]
+	}
+	require.Nil(t, ecSplit(3, 2, bytes.NewReader(data), 10, int64(len(data)), writers))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		index, err := strconv.Atoi(parts[len(parts)-1])
+		require.Nil(t, err)
+		switch parts[2] {
+		case "sda": // lost its shard
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case "sdb": // holds some other shard than the ring says
+			index = 3
+		}
+		w.Header().Set("Ec-Shard-Index", strconv.Itoa(index))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(shards[index].Bytes()))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.Nil(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.Nil(t, err)
+	rng := &ecNodesRing{FakeRing: &test.FakeRing{}}
+	for _, dev := range []string{"sda", "sdb", "sdc", "sdd", "sde"} {
+		rng.nodes = append(rng.nodes, &ring.Device{Scheme: u.Scheme, Ip: u.Hostname(), Port: port, Device: dev})
+	}
+	obj := &ecObject{
+		IndexDBItem: IndexDBItem{Hash: "00000011111122222233333344444455", Path: "/some/path"},
+		client:      http.DefaultClient,
+		ring:        rng,
+		logger:      zap.NewNop(),
+		metadata: map[string]string{
+			"Content-Length": "75",
+			"Ec-Scheme":      "reedsolomon/3/2/10",
+		},
+	}
+
+	b := &bytes.Buffer{}
+	written, err := obj.Copy(b)
+	require.Nil(t, err)
+	require.Equal(t, int64(75), written)
+	require.Equal(t, data, b.Bytes())
+
+	for _, r := range [][2]int64{{0, 75}, {35, 70}, {61, 75}, {29, 31}, {5, 6}} {
+		b := &bytes.Buffer{}
+		written, err := obj.CopyRange(b, r[0], r[1])
+		require.Nil(t, err)
+		require.Equal(t, r[1]-r[0], written)
+		require.Equal(t, string(data[r[0]:r[1]]), b.String(), "range %d-%d", r[0], r[1])
+	}
+}
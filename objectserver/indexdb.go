@@ -65,7 +65,7 @@ type IndexDB struct {
 	dbPartPower   uint
 	subdirs       int
 	temppath      string
-	reserve       int64
+	reserve       fs.Reserve
 	dbs           []*sql.DB
 	logger        srv.LowLevelLogger
 	auditor       IndexDBAuditor
@@ -78,7 +78,7 @@ type IndexDB struct {
 // databases are created (e.g. dbPartPower = 6 gives 64 databases). The
 // subdirs value will define how many subdirectories are created where object
 // content files are placed.
-func NewIndexDB(dbpath, filepath, temppath string, ringPartPower, dbPartPower, subdirs int, reserve int64, logger srv.LowLevelLogger, auditor IndexDBAuditor) (*IndexDB, error) {
+func NewIndexDB(dbpath, filepath, temppath string, ringPartPower, dbPartPower, subdirs int, reserve fs.Reserve, logger srv.LowLevelLogger, auditor IndexDBAuditor) (*IndexDB, error) {
 	if ringPartPower <= dbPartPower {
 		return nil, fmt.Errorf("ringPartPower must be greater than dbPartPower: %d is not greater than %d", ringPartPower, dbPartPower)
 	}
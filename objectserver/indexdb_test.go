@@ -31,7 +31,7 @@ func md5hash(data string) string {
 
 func newTestIndexDB(t *testing.T, pth string) *IndexDB {
 	t.Helper()
-	ot, err := NewIndexDB(pth, pth, pth, 2, 1, 1, 0, zap.L(), fakeIndexDBAuditor{})
+	ot, err := NewIndexDB(pth, pth, pth, 2, 1, 1, fs.Reserve{}, zap.L(), fakeIndexDBAuditor{})
 	errnil(t, err)
 	return ot
 }
@@ -578,7 +578,7 @@ func TestIndexDB_ListDefaults(t *testing.T) {
 func TestIndexDB_RingPartRange(t *testing.T) {
 	pth, _ := ioutil.TempDir("", "")
 	defer os.RemoveAll(pth)
-	ot, err := NewIndexDB(pth, pth, pth, 4, 1, 1, 0, zap.L(), fakeIndexDBAuditor{})
+	ot, err := NewIndexDB(pth, pth, pth, 4, 1, 1, fs.Reserve{}, zap.L(), fakeIndexDBAuditor{})
 	errnil(t, err)
 	defer ot.Close()
 	startHash, stopHash := ot.RingPartRange(0)
@@ -602,7 +602,7 @@ func TestIndexDB_RingPartRange(t *testing.T) {
 	if stopHash != "ffffffffffffffffffffffffffffffff" {
 		t.Fatal(stopHash)
 	}
-	ot, err = NewIndexDB(pth, pth, pth, 8, 1, 1, 0, zap.L(), fakeIndexDBAuditor{})
+	ot, err = NewIndexDB(pth, pth, pth, 8, 1, 1, fs.Reserve{}, zap.L(), fakeIndexDBAuditor{})
 	errnil(t, err)
 	defer ot.Close()
 	startHash, stopHash = ot.RingPartRange(0)
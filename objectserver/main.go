@@ -55,6 +55,8 @@ type ObjectServer struct {
 	checkEtags         bool
 	checkMounts        bool
 	allowedHeaders     map[string]bool
+	checksums          map[int][]string
+	appendPolicies     map[int]bool
 	logger             srv.LowLevelLogger
 	logLevel           zap.AtomicLevel
 	diskInUse          *common.KeyedLimit
@@ -65,9 +67,16 @@ type ObjectServer struct {
 	updateTimeout      time.Duration
 	asyncWG            sync.WaitGroup // Used to wait on async goroutines
 	metricsCloser      io.Closer
+	metricsScope       tally.Scope
+	statsd             *srv.StatsdClient
 	traceCloser        io.Closer
 	tracer             opentracing.Tracer
 	updateClientCloser io.Closer
+	// priorityDiskInUse has the separate per-device limits of non-interactive
+	// priority classes, which also never take the last diskReserve slots of
+	// diskInUse.
+	priorityDiskInUse map[string]*common.KeyedLimit
+	diskReserve       int64
 }
 
 func (server *ObjectServer) Type() string {
@@ -80,9 +89,11 @@ func (server *ObjectServer) Background(flags *flag.FlagSet) chan struct{} {
 
 func (server *ObjectServer) Finalize() {
 	server.asyncWG.Wait()
+	batchedInvalidator.flush()
 	if server.metricsCloser != nil {
 		server.metricsCloser.Close()
 	}
+	server.statsd.Close()
 	if server.traceCloser != nil {
 		server.traceCloser.Close()
 	}
@@ -91,11 +102,16 @@ func (server *ObjectServer) Finalize() {
 	}
 }
 
-func (server *ObjectServer) newObject(req *http.Request, vars map[string]string, needData bool) (Object, error) {
+func requestPolicy(req *http.Request) int {
 	policy, err := strconv.Atoi(req.Header.Get("X-Backend-Storage-Policy-Index"))
 	if err != nil {
-		policy = 0
+		return 0
 	}
+	return policy
+}
+
+func (server *ObjectServer) newObject(req *http.Request, vars map[string]string, needData bool) (Object, error) {
+	policy := requestPolicy(req)
 	engine, ok := server.objEngines[policy]
 	if !ok {
 		return nil, fmt.Errorf("Engine for policy index %d not found.", policy)
@@ -103,6 +119,12 @@ func (server *ObjectServer) newObject(req *http.Request, vars map[string]string,
 	return engine.New(vars, needData, &server.asyncWG)
 }
 
+// openExpired returns whether the proxy asked for expired objects that are
+// still on disk to be served anyway.
+func openExpired(request *http.Request) bool {
+	return common.LooksTrue(request.Header.Get(common.BackendOpenExpiredHeader))
+}
+
 func resolveEtag(req *http.Request, metadata map[string]string) string {
 	etag := metadata["ETag"]
 	for _, ph := range strings.Split(req.Header.Get("X-Backend-Etag-Is-At"), ",") {
@@ -142,7 +164,7 @@ func (server *ObjectServer) ObjGetHandler(writer http.ResponseWriter, request *h
 	}
 	etag := resolveEtag(request, metadata)
 
-	if Expired(metadata) {
+	if Expired(metadata) && !openExpired(request) {
 		srv.StandardResponse(writer, http.StatusNotFound)
 		return
 	}
@@ -166,7 +188,9 @@ func (server *ObjectServer) ObjGetHandler(writer http.ResponseWriter, request *h
 	for key, value := range metadata {
 		if allowed, ok := server.allowedHeaders[key]; (ok && allowed) ||
 			strings.HasPrefix(key, "X-Object-Meta-") ||
-			strings.HasPrefix(key, "X-Object-Sysmeta-") {
+			strings.HasPrefix(key, "X-Object-Sysmeta-") ||
+			strings.HasPrefix(key, "X-Object-Transient-Sysmeta-") ||
+			strings.HasPrefix(key, common.ChecksumHeaderPrefix) {
 			headers.Set(key, value)
 		}
 	}
@@ -181,12 +205,12 @@ func (server *ObjectServer) ObjGetHandler(writer http.ResponseWriter, request *h
 		return
 	}
 
-	if ius, err := common.ParseDate(request.Header.Get("If-Unmodified-Since")); err == nil && lastModified.After(ius) {
+	if ius, err := common.ParseDate(request.Header.Get("If-Unmodified-Since")); err == nil && common.LastModifiedTime(lastModified).After(ius) {
 		srv.StandardResponse(writer, http.StatusPreconditionFailed)
 		return
 	}
 
-	if ims, err := common.ParseDate(request.Header.Get("If-Modified-Since")); err == nil && lastModified.Before(ims) {
+	if ims, err := common.ParseDate(request.Header.Get("If-Modified-Since")); err == nil && !common.LastModifiedTime(lastModified).After(ims) {
 		writer.WriteHeader(http.StatusNotModified)
 		return
 	}
@@ -194,6 +218,7 @@ func (server *ObjectServer) ObjGetHandler(writer http.ResponseWriter, request *h
 	headers.Set("Accept-Ranges", "bytes")
 	headers.Set("Content-Type", metadata["Content-Type"])
 	headers.Set("Content-Length", metadata["Content-Length"])
+	headers.Set("X-Backend-Content-Length", metadata["Content-Length"])
 
 	if rangeHeader := request.Header.Get("Range"); rangeHeader != "" {
 		ranges, err := common.ParseRange(rangeHeader, obj.ContentLength())
@@ -287,12 +312,16 @@ func (server *ObjectServer) ObjPutHandler(writer http.ResponseWriter, request *h
 			return
 		}
 		metadata := obj.Metadata()
-		if requestTime, err := common.ParseDate(requestTimestamp); err == nil {
-			if lastModified, err := common.ParseDate(metadata["X-Timestamp"]); err == nil && !requestTime.After(lastModified) {
-				outHeaders.Set("X-Backend-Timestamp", metadata["X-Timestamp"])
-				srv.StandardResponse(writer, http.StatusConflict)
-				return
-			}
+		// A PUT only has to be newer than the data. A newer .meta is kept and
+		// applies to the new data, as its metadata was set later.
+		dataTimestamp := metadata["X-Backend-Data-Timestamp"]
+		if dataTimestamp == "" {
+			dataTimestamp = metadata["X-Timestamp"]
+		}
+		if cmp, err := common.CompareTimestamps(requestTimestamp, dataTimestamp); err == nil && cmp <= 0 {
+			outHeaders.Set("X-Backend-Timestamp", metadata["X-Timestamp"])
+			srv.StandardResponse(writer, http.StatusConflict)
+			return
 		}
 		if inm := request.Header.Get("If-None-Match"); inm != "*" && strings.Contains(inm, metadata["ETag"]) {
 			srv.StandardResponse(writer, http.StatusPreconditionFailed)
@@ -303,6 +332,7 @@ func (server *ObjectServer) ObjPutHandler(writer http.ResponseWriter, request *h
 	tempFile, err := obj.SetData(request.ContentLength)
 	if err == DriveFullError {
 		srv.GetLogger(request).Debug("Not enough space available")
+		middleware.MarkDeviceFull(vars["device"])
 		srv.CustomErrorResponse(writer, 507, vars)
 		return
 	} else if err != nil {
@@ -312,7 +342,12 @@ func (server *ObjectServer) ObjPutHandler(writer http.ResponseWriter, request *h
 	}
 
 	hash := md5.New()
-	totalSize, err := common.Copy(request.Body, tempFile, hash)
+	checksums := common.NewChecksums(server.checksums[requestPolicy(request)], request.Header, request.Trailer)
+	dsts := []io.Writer{tempFile, hash}
+	for _, h := range checksums {
+		dsts = append(dsts, h)
+	}
+	totalSize, err := common.Copy(request.Body, dsts...)
 	if err == io.ErrUnexpectedEOF || (request.ContentLength >= 0 && totalSize != request.ContentLength) {
 		srv.StandardResponse(writer, 499)
 		return
@@ -331,15 +366,40 @@ func (server *ObjectServer) ObjPutHandler(writer http.ResponseWriter, request *h
 	for key := range request.Header {
 		if allowed, ok := server.allowedHeaders[key]; (ok && allowed) ||
 			strings.HasPrefix(key, "X-Object-Meta-") ||
-			strings.HasPrefix(key, "X-Object-Sysmeta-") {
+			strings.HasPrefix(key, "X-Object-Sysmeta-") ||
+			strings.HasPrefix(key, "X-Object-Transient-Sysmeta-") {
 			metadata[key] = request.Header.Get(key)
 		}
 	}
+	// Writers that only know an object's ETag, checksums or sysmeta once
+	// they've sent it can send them as trailers, which have been read now
+	// the body has.
+	for key := range request.Trailer {
+		if strings.HasPrefix(key, "X-Object-Sysmeta-") {
+			metadata[key] = request.Trailer.Get(key)
+		}
+	}
 	requestEtag := strings.Trim(strings.ToLower(request.Header.Get("ETag")), "\"")
+	if etag := request.Trailer.Get("X-Object-Etag"); etag != "" {
+		requestEtag = strings.Trim(strings.ToLower(etag), "\"")
+	}
 	if requestEtag != "" && requestEtag != metadata["ETag"] {
 		http.Error(writer, "Unprocessable Entity", 422)
 		return
 	}
+	for algorithm, h := range checksums {
+		key := common.ChecksumHeaderPrefix + algorithm
+		metadata[key] = common.EncodeChecksum(h)
+		requestChecksum := request.Header.Get(key)
+		if v := request.Trailer.Get(key); v != "" {
+			requestChecksum = v
+		}
+		if requestChecksum != "" && requestChecksum != metadata[key] {
+			http.Error(writer, "Unprocessable Entity", 422)
+			return
+		}
+		outHeaders.Set(key, metadata[key])
+	}
 	outHeaders.Set("ETag", metadata["ETag"])
 
 	if err := obj.Commit(metadata); err != nil {
@@ -350,8 +410,17 @@ func (server *ObjectServer) ObjPutHandler(writer http.ResponseWriter, request *h
 	srv.StandardResponse(writer, http.StatusCreated)
 }
 
-func (server *ObjectServer) ObjPostHandler(writer http.ResponseWriter, request *http.Request) {
+// unappendableHeaders mark objects whose stored data isn't their content,
+// so appending to it would corrupt them.
+var unappendableHeaders = []string{"X-Static-Large-Object", "X-Object-Manifest", "X-Object-Sysmeta-Compression"}
+
+// ObjAppendHandler writes a new version of an object that's the existing
+// data followed by the request body, keeping the object's metadata. If
+// X-Backend-Append-Timestamp is set, the existing object must have that
+// timestamp, so every replica appends to the same data.
+func (server *ObjectServer) ObjAppendHandler(writer http.ResponseWriter, request *http.Request) {
 	vars := srv.GetVars(request)
+	outHeaders := writer.Header()
 
 	requestTimestamp, err := common.StandardizeTimestamp(request.Header.Get("X-Timestamp"))
 	if err != nil {
@@ -363,8 +432,12 @@ func (server *ObjectServer) ObjPostHandler(writer http.ResponseWriter, request *
 		http.Error(writer, fmt.Sprintf("Invalid path: %s", request.URL.Path), http.StatusBadRequest)
 		return
 	}
+	if !server.appendPolicies[requestPolicy(request)] {
+		http.Error(writer, "Appends aren't supported by this policy", http.StatusMethodNotAllowed)
+		return
+	}
 
-	obj, err := server.newObject(request, vars, false)
+	obj, err := server.newObject(request, vars, true)
 	if err != nil {
 		srv.GetLogger(request).Error("Error getting obj", zap.Error(err))
 		srv.StandardResponse(writer, http.StatusInternalServerError)
@@ -375,36 +448,167 @@ func (server *ObjectServer) ObjPostHandler(writer http.ResponseWriter, request *
 		srv.StandardResponse(writer, http.StatusNotFound)
 		return
 	}
-
 	origMetadata := obj.Metadata()
-	if requestTime, err := common.ParseDate(requestTimestamp); err == nil {
-		if origLastModified, err := common.ParseDate(origMetadata["X-Timestamp"]); err == nil && !requestTime.After(origLastModified) {
-			writer.Header().Set("X-Backend-Timestamp", origMetadata["X-Timestamp"])
+	if Expired(origMetadata) && !openExpired(request) {
+		srv.StandardResponse(writer, http.StatusNotFound)
+		return
+	}
+	if cmp, err := common.CompareTimestamps(requestTimestamp, origMetadata["X-Timestamp"]); err == nil && cmp <= 0 {
+		outHeaders.Set("X-Backend-Timestamp", origMetadata["X-Timestamp"])
+		srv.StandardResponse(writer, http.StatusConflict)
+		return
+	}
+	if base := request.Header.Get("X-Backend-Append-Timestamp"); base != "" {
+		if cmp, err := common.CompareTimestamps(base, origMetadata["X-Timestamp"]); err != nil || cmp != 0 {
+			outHeaders.Set("X-Backend-Timestamp", origMetadata["X-Timestamp"])
 			srv.StandardResponse(writer, http.StatusConflict)
 			return
 		}
 	}
-	if t := request.Header.Get("X-Delete-At"); t != "" && t != origMetadata["X-Delete-At"] {
-		http.Error(writer, fmt.Sprintf("X-Delete-At may not be sent with object POST: %q", t), http.StatusConflict)
+	for _, key := range unappendableHeaders {
+		if _, ok := origMetadata[key]; ok {
+			http.Error(writer, fmt.Sprintf("Objects with %s can't be appended to", key), http.StatusConflict)
+			return
+		}
+	}
+
+	size := int64(-1)
+	if request.ContentLength >= 0 {
+		size = obj.ContentLength() + request.ContentLength
+	}
+	tempFile, err := obj.SetData(size)
+	if err == DriveFullError {
+		srv.GetLogger(request).Debug("Not enough space available")
+		middleware.MarkDeviceFull(vars["device"])
+		srv.CustomErrorResponse(writer, 507, vars)
+		return
+	} else if err != nil {
+		srv.GetLogger(request).Error("Error making new file", zap.Error(err))
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+
+	// Keep every checksum the object already has, along with the policy's.
+	checksumHeaders := http.Header{}
+	for key := range origMetadata {
+		if strings.HasPrefix(key, common.ChecksumHeaderPrefix) {
+			checksumHeaders.Set(key, "")
+		}
+	}
+	hash := md5.New()
+	checksums := common.NewChecksums(server.checksums[requestPolicy(request)], checksumHeaders)
+	dsts := []io.Writer{tempFile, hash}
+	for _, h := range checksums {
+		dsts = append(dsts, h)
+	}
+	origSize, err := obj.Copy(dsts...)
+	if err != nil {
+		srv.GetLogger(request).Error("Error copying existing data", zap.String("obj", obj.Repr()), zap.Error(err))
+		srv.StandardResponse(writer, http.StatusInternalServerError)
 		return
 	}
-	if t := request.Header.Get("Content-Type"); t != "" && t != origMetadata["Content-Type"] {
-		http.Error(writer, fmt.Sprintf("Content-Type may not be sent with object POST: %q", t), http.StatusConflict)
+	appendSize, err := common.Copy(request.Body, dsts...)
+	if err == io.ErrUnexpectedEOF || (request.ContentLength >= 0 && appendSize != request.ContentLength) {
+		srv.StandardResponse(writer, 499)
+		return
+	} else if err != nil {
+		srv.GetLogger(request).Error("Error writing to file", zap.Error(err))
+		srv.StandardResponse(writer, http.StatusInternalServerError)
 		return
 	}
+	metadata := map[string]string{}
+	for key, value := range origMetadata {
+		if !strings.HasPrefix(key, "X-Backend-") && !strings.HasPrefix(key, "X-Object-Sysmeta-Container-Update-Override-") {
+			metadata[key] = value
+		}
+	}
+	metadata["X-Timestamp"] = requestTimestamp
+	metadata["Content-Length"] = strconv.FormatInt(origSize+appendSize, 10)
+	metadata["ETag"] = hex.EncodeToString(hash.Sum(nil))
+	for algorithm, h := range checksums {
+		key := common.ChecksumHeaderPrefix + algorithm
+		metadata[key] = common.EncodeChecksum(h)
+		outHeaders.Set(key, metadata[key])
+	}
+	outHeaders.Set("ETag", metadata["ETag"])
 
+	if err := obj.Commit(metadata); err != nil {
+		srv.ErrorResponse(writer, err)
+		return
+	}
+	server.containerUpdates(writer, request, metadata, metadata["X-Delete-At"], vars, srv.GetLogger(request))
+	srv.StandardResponse(writer, http.StatusCreated)
+}
+
+func (server *ObjectServer) ObjPostHandler(writer http.ResponseWriter, request *http.Request) {
+	vars := srv.GetVars(request)
+
+	requestTimestamp, err := common.StandardizeTimestamp(request.Header.Get("X-Timestamp"))
+	if err != nil {
+		srv.GetLogger(request).Error("Error standardizing request X-Timestamp", zap.Error(err))
+		http.Error(writer, "Invalid X-Timestamp header", http.StatusBadRequest)
+		return
+	}
+	if vars["obj"] == "" {
+		http.Error(writer, fmt.Sprintf("Invalid path: %s", request.URL.Path), http.StatusBadRequest)
+		return
+	}
+
+	obj, err := server.newObject(request, vars, false)
+	if err != nil {
+		srv.GetLogger(request).Error("Error getting obj", zap.Error(err))
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	defer obj.Close()
+	if !obj.Exists() {
+		srv.StandardResponse(writer, http.StatusNotFound)
+		return
+	}
+
+	origMetadata := obj.Metadata()
+	if Expired(origMetadata) && !openExpired(request) {
+		srv.StandardResponse(writer, http.StatusNotFound)
+		return
+	}
+	if cmp, err := common.CompareTimestamps(requestTimestamp, origMetadata["X-Timestamp"]); err == nil && cmp <= 0 {
+		writer.Header().Set("X-Backend-Timestamp", origMetadata["X-Timestamp"])
+		srv.StandardResponse(writer, http.StatusConflict)
+		return
+	}
+	if t := request.Header.Get("X-Delete-At"); t != "" && t != origMetadata["X-Delete-At"] {
+		http.Error(writer, fmt.Sprintf("X-Delete-At may not be sent with object POST: %q", t), http.StatusConflict)
+		return
+	}
+
+	// User metadata and the allowed headers are replaced as a set by each
+	// POST; what describes the data is carried over, and the Content-Type
+	// is kept unless the POST changes it.
 	metadata := make(map[string]string)
+	if t := request.Header.Get("Content-Type"); t != "" {
+		metadata["Content-Type"] = t
+	} else if t, ok := origMetadata["Content-Type"]; ok {
+		metadata["Content-Type"] = t
+	}
 	if v, ok := origMetadata["X-Static-Large-Object"]; ok {
 		metadata["X-Static-Large-Object"] = v
 	}
 	if v, ok := origMetadata["Ec-Scheme"]; ok {
 		metadata["Ec-Scheme"] = v
 	}
-	copyHdrs := map[string]bool{"Content-Disposition": true, "Content-Encoding": true, "X-Delete-At": true, "X-Object-Manifest": true, "X-Static-Large-Object": true}
+	for key, value := range origMetadata {
+		if strings.HasPrefix(key, common.ChecksumHeaderPrefix) {
+			metadata[key] = value
+		}
+	}
+	copyHdrs := map[string]bool{"Content-Disposition": true, "Content-Encoding": true, "X-Delete-At": true, "X-Object-Manifest": true}
 	for _, v := range strings.Fields(request.Header.Get("X-Backend-Replication-Headers")) {
 		copyHdrs[v] = true
 	}
 	for key := range request.Header {
+		if isDataFileMetadata(key) {
+			continue
+		}
 		if allowed, ok := server.allowedHeaders[key]; (ok && allowed) ||
 			copyHdrs[key] ||
 			strings.HasPrefix(key, "X-Object-Meta-") ||
@@ -420,6 +624,16 @@ func (server *ObjectServer) ObjPostHandler(writer http.ResponseWriter, request *
 		srv.StandardResponse(writer, http.StatusInternalServerError)
 		return
 	}
+	// Container listings show the Content-Type, so a POST changing it
+	// relists the object with the rest of its listing unchanged.
+	if metadata["Content-Type"] != origMetadata["Content-Type"] {
+		listing := make(map[string]string, len(origMetadata))
+		for key, value := range origMetadata {
+			listing[key] = value
+		}
+		listing["Content-Type"] = metadata["Content-Type"]
+		server.containerUpdates(writer, request, listing, metadata["X-Delete-At"], vars, srv.GetLogger(request))
+	}
 	srv.StandardResponse(writer, http.StatusAccepted)
 }
 
@@ -486,6 +700,7 @@ func (server *ObjectServer) ObjDeleteHandler(writer http.ResponseWriter, request
 	}
 	if err := obj.Delete(metadata); err == DriveFullError {
 		srv.GetLogger(request).Debug("Not enough space available")
+		middleware.MarkDeviceFull(vars["device"])
 		srv.CustomErrorResponse(writer, 507, vars)
 		return
 	} else if err != nil {
@@ -536,8 +751,11 @@ func (server *ObjectServer) AcquireDevice(next http.Handler) http.Handler {
 		vars := srv.GetVars(request)
 		if device, ok := vars["device"]; ok && device != "" {
 			devicePath := filepath.Join(server.driveRoot, device)
-			if server.checkMounts {
-				if mounted, err := fs.IsMount(devicePath); err != nil || mounted != true {
+			// X-Backend-Mount-Check: true lets a caller insist on the mount
+			// check even on servers running with mount_check = false.
+			if server.checkMounts || request.Header.Get("X-Backend-Mount-Check") == "true" {
+				if mounted, err := fs.IsMount(devicePath); err != nil || !mounted {
+					srv.GetLogger(request).Error("Device not mounted", zap.String("device", devicePath), zap.Error(err))
 					vars["Method"] = request.Method
 					srv.CustomErrorResponse(writer, 507, vars)
 					return
@@ -545,7 +763,22 @@ func (server *ObjectServer) AcquireDevice(next http.Handler) http.Handler {
 			}
 
 			forceAcquire := request.Header.Get("X-Force-Acquire") == "true"
-			if concRequests := server.diskInUse.Acquire(device, forceAcquire); concRequests != 0 {
+			priority := common.RequestPriority(request.Header)
+			if limit := server.priorityDiskInUse[priority]; limit != nil {
+				if concRequests := limit.Acquire(device, forceAcquire); concRequests != 0 {
+					writer.Header().Set("X-Disk-Usage", strconv.FormatInt(concRequests, 10))
+					srv.StandardResponse(writer, 503)
+					return
+				}
+				defer limit.Release(device)
+			}
+			var concRequests int64
+			if priority == common.PriorityInteractive || forceAcquire {
+				concRequests = server.diskInUse.Acquire(device, forceAcquire)
+			} else {
+				concRequests = server.diskInUse.AcquireReserving(device, server.diskReserve)
+			}
+			if concRequests != 0 {
 				writer.Header().Set("X-Disk-Usage", strconv.FormatInt(concRequests, 10))
 				srv.StandardResponse(writer, 503)
 				return
@@ -589,6 +822,7 @@ func (server *ObjectServer) GetHandler(config conf.Config, metricsPrefix string)
 		CachedReporter: promreporter.NewReporter(promreporter.Options{}),
 		Separator:      promreporter.DefaultSeparator,
 	}, time.Second)
+	server.metricsScope = metricsScope
 	commonHandlers := alice.New(
 		middleware.NewDebugResponses(config.GetBool("debug", "debug_x_source_code", false)),
 		server.LogRequest,
@@ -596,21 +830,26 @@ func (server *ObjectServer) GetHandler(config conf.Config, metricsPrefix string)
 		middleware.ValidateRequest,
 		server.AcquireDevice,
 	)
+	dataHandlers := commonHandlers.Append(middleware.BackendSignatures())
 	router := srv.NewRouter()
 	router.Get("/metrics", prometheus.Handler())
 	router.Get("/loglevel", server.logLevel)
 	router.Put("/loglevel", server.logLevel)
+	router.Get("/loglevel/:component", http.HandlerFunc(srv.LogLevelHandler))
+	router.Put("/loglevel/:component", http.HandlerFunc(srv.LogLevelHandler))
+	router.Get("/loglevels", http.HandlerFunc(srv.LogLevelsHandler))
 	router.Get("/healthcheck", commonHandlers.ThenFunc(server.HealthcheckHandler))
 	router.Get("/diskusage", commonHandlers.ThenFunc(server.DiskUsageHandler))
 	router.Put("/ring/*ring_path", commonHandlers.ThenFunc(middleware.RingHandler))
 	router.Get("/recon/:method/:recon_type", commonHandlers.ThenFunc(server.ReconHandler))
 	router.Get("/recon/:method", commonHandlers.ThenFunc(server.ReconHandler))
 	router.Delete("/recon/:device/:method/:recon_type/*item_path", commonHandlers.ThenFunc(server.ReconHandler))
-	router.Get("/:device/:partition/:account/:container/*obj", commonHandlers.ThenFunc(server.ObjGetHandler))
-	router.Head("/:device/:partition/:account/:container/*obj", commonHandlers.ThenFunc(server.ObjGetHandler))
-	router.Put("/:device/:partition/:account/:container/*obj", commonHandlers.ThenFunc(server.ObjPutHandler))
-	router.Post("/:device/:partition/:account/:container/*obj", commonHandlers.ThenFunc(server.ObjPostHandler))
-	router.Delete("/:device/:partition/:account/:container/*obj", commonHandlers.ThenFunc(server.ObjDeleteHandler))
+	router.Get("/:device/:partition/:account/:container/*obj", dataHandlers.ThenFunc(server.ObjGetHandler))
+	router.Head("/:device/:partition/:account/:container/*obj", dataHandlers.ThenFunc(server.ObjGetHandler))
+	router.Put("/:device/:partition/:account/:container/*obj", dataHandlers.ThenFunc(server.ObjPutHandler))
+	router.Post("/:device/:partition/:account/:container/*obj", dataHandlers.ThenFunc(server.ObjPostHandler))
+	router.Handle("APPEND", "/:device/:partition/:account/:container/*obj", dataHandlers.ThenFunc(server.ObjAppendHandler))
+	router.Delete("/:device/:partition/:account/:container/*obj", dataHandlers.ThenFunc(server.ObjDeleteHandler))
 	router.Options("/", commonHandlers.ThenFunc(server.OptionsHandler))
 	router.Get("/debug/pprof/:parm", http.DefaultServeMux)
 	router.Post("/debug/pprof/:parm", http.DefaultServeMux)
@@ -620,11 +859,31 @@ func (server *ObjectServer) GetHandler(config conf.Config, metricsPrefix string)
 	for policy, objEngine := range server.objEngines {
 		if rhoe, ok := objEngine.(PolicyHandlerRegistrator); ok {
 			rhoe.RegisterHandlers(func(method, path string, handler http.HandlerFunc) {
-				router.HandlePolicy(method, path, policy, commonHandlers.ThenFunc(handler))
+				router.HandlePolicy(method, path, policy, dataHandlers.ThenFunc(handler))
 			})
 		}
 	}
-	return alice.New(middleware.Metrics(metricsScope), middleware.GrepObject, middleware.ServerTracer(server.tracer)).Then(router)
+	return alice.New(middleware.Metrics(metricsScope), middleware.StatsdTimings(server.statsd), middleware.GrepObject, middleware.ServerTracer(server.tracer)).Then(router)
+}
+
+// policyChecksums returns the extra checksum algorithms to compute for each
+// policy's objects, from the policy's checksums setting or, failing that, the
+// object server's.
+func policyChecksums(serverconf conf.Config, policies conf.PolicyList) (map[int][]string, error) {
+	defaults, err := common.ParseChecksumAlgorithms(serverconf.GetDefault("app:object-server", "checksums", ""))
+	if err != nil {
+		return nil, err
+	}
+	checksums := map[int][]string{}
+	for _, policy := range policies {
+		checksums[policy.Index] = defaults
+		if list, ok := policy.Config["checksums"]; ok {
+			if checksums[policy.Index], err = common.ParseChecksumAlgorithms(list); err != nil {
+				return nil, fmt.Errorf("Policy %d: %v", policy.Index, err)
+			}
+		}
+	}
+	return checksums, nil
 }
 
 func NewServer(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader) (*srv.IpPort, srv.Server, srv.LowLevelLogger, error) {
@@ -637,7 +896,10 @@ func NewServer(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader
 			"X-Delete-At":           true,
 			"X-Object-Manifest":     true,
 			"X-Static-Large-Object": true,
+			"Cache-Control":         true,
+			"Expires":               true,
 		},
+		metricsScope: tally.NoopScope,
 	}
 	server.hashPathPrefix, server.hashPathSuffix, err = cnf.GetHashPrefixAndSuffix()
 	if err != nil {
@@ -647,13 +909,45 @@ func NewServer(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader
 		return ipPort, nil, nil, err
 	}
 
+	policies, err := cnf.GetPolicies()
+	if err != nil {
+		return ipPort, nil, nil, err
+	}
+	if server.checksums, err = policyChecksums(serverconf, policies); err != nil {
+		return ipPort, nil, nil, err
+	}
+	server.appendPolicies = map[int]bool{}
+	for _, policy := range policies {
+		// Replicated objects are stored whole, so an append can rewrite
+		// them; erasure coded ones would need re-encoding.
+		server.appendPolicies[policy.Index] = policy.Type == "replication" || policy.Type == "replication-nursery"
+	}
+
 	server.driveRoot = serverconf.GetDefault("app:object-server", "devices", "/srv/node")
 	server.reconCachePath = serverconf.GetDefault("app:object-server", "recon_cache_path", "/var/cache/swift")
 	server.checkMounts = serverconf.GetBool("app:object-server", "mount_check", true)
 	server.checkEtags = serverconf.GetBool("app:object-server", "check_etags", false)
-	server.diskInUse = common.NewKeyedLimit(serverconf.GetLimit("app:object-server", "disk_limit", 25, 0))
+	diskLimit, diskTotalLimit := serverconf.GetLimit("app:object-server", "disk_limit", 25, 0)
+	server.diskInUse = common.NewKeyedLimit(diskLimit, diskTotalLimit)
+	// Requests marked with a replication or background X-Backend-Priority
+	// leave interactive_disk_reserve of each device's disk_limit for user
+	// requests, and may be limited further with <priority>_disk_limit.
+	server.diskReserve = serverconf.GetInt("app:object-server", "interactive_disk_reserve", 5)
+	if diskLimit > 0 && server.diskReserve >= diskLimit {
+		server.diskReserve = diskLimit - 1
+	}
+	server.priorityDiskInUse = map[string]*common.KeyedLimit{}
+	for _, priority := range []string{common.PriorityReplication, common.PriorityBackground} {
+		if limit, totalLimit := serverconf.GetLimit("app:object-server", priority+"_disk_limit", 0, 0); limit > 0 || totalLimit > 0 {
+			server.priorityDiskInUse[priority] = common.NewKeyedLimit(limit, totalLimit)
+		}
+	}
 	server.accountDiskInUse = common.NewKeyedLimit(serverconf.GetLimit("app:object-server", "account_rate_limit", 0, 0))
 	server.expiringDivisor = serverconf.GetInt("app:object-server", "expiring_objects_container_divisor", 86400)
+	// disk_chunk_size is the size of the reads and writes object data is
+	// streamed to and from disk in.
+	common.SetCopyBufferSize(int(serverconf.GetInt("app:object-server", "disk_chunk_size",
+		serverconf.GetInt("app:object-server", "copy_buffer_size", common.DefaultCopyBufferSize))))
 	bindIP := serverconf.GetDefault("app:object-server", "bind_ip", "0.0.0.0")
 	bindPort := int(serverconf.GetInt("app:object-server", "bind_port", common.DefaultObjectServerPort))
 	certFile := serverconf.GetDefault("app:object-server", "cert_file", "")
@@ -670,6 +964,9 @@ func NewServer(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader
 	if server.logger, err = srv.SetupLogger("object-server", &server.logLevel, flags); err != nil {
 		return ipPort, nil, nil, fmt.Errorf("Error setting up logger: %v", err)
 	}
+	if server.statsd, err = srv.NewStatsdClientFromConfig(serverconf.GetSection("app:object-server"), "object-server"); err != nil {
+		return ipPort, nil, nil, err
+	}
 	server.updateTimeout = time.Duration(serverconf.GetFloat("app:object-server", "container_update_timeout", 0.25) * float64(time.Second))
 	connTimeout := time.Duration(serverconf.GetFloat("app:object-server", "conn_timeout", 1.0) * float64(time.Second))
 	nodeTimeout := time.Duration(serverconf.GetFloat("app:object-server", "node_timeout", 10.0) * float64(time.Second))
@@ -692,7 +989,7 @@ func NewServer(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader
 	}
 	httpClient := &http.Client{
 		Timeout:   nodeTimeout,
-		Transport: transport,
+		Transport: srv.NewSigningTransport(transport),
 	}
 	server.updateClient = httpClient
 	if serverconf.HasSection("tracing") {
@@ -717,6 +1014,123 @@ func NewServer(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader
 	if deviceLockUpdateSeconds > 0 {
 		go server.updateDeviceLocks(deviceLockUpdateSeconds)
 	}
-	ipPort = &srv.IpPort{Ip: bindIP, Port: bindPort, CertFile: certFile, KeyFile: keyFile}
+	bindIPs := strings.Split(bindIP, ",")
+	for i := range bindIPs {
+		bindIPs[i] = strings.TrimSpace(bindIPs[i])
+	}
+	ipPort = &srv.IpPort{Ip: bindIPs[0], Port: bindPort, CertFile: certFile, KeyFile: keyFile}
+	readBuffer := serverconf.GetInt("app:object-server", "network_read_buffer", 0)
+	writeBuffer := serverconf.GetInt("app:object-server", "network_write_buffer", 0)
+	if readBuffer > 0 || writeBuffer > 0 {
+		limits := srv.DefaultServerLimits
+		limits.NetworkReadBuffer, limits.NetworkWriteBuffer = int(readBuffer), int(writeBuffer)
+		ipPort.Limits = &limits
+	}
+	for _, ip := range bindIPs[1:] {
+		ipPort.ExtraBinds = append(ipPort.ExtraBinds, &srv.IpPort{Ip: ip, Port: bindPort, Limits: ipPort.Limits})
+	}
+	if serverconf.GetInt("app:object-server", "servers_per_port", 0) > 0 {
+		binds, err := ringBinds(cnf, server.hashPathPrefix, server.hashPathSuffix, bindIPs)
+		if err != nil {
+			return ipPort, nil, nil, fmt.Errorf("Error finding local devices for servers_per_port: %v", err)
+		}
+		if len(binds) > 0 {
+			// The ring's addresses replace bind_ip and bind_port, so a
+			// wildcard bind_ip never collides with a device's port.
+			allDevices := map[string]bool{}
+			for _, bind := range binds {
+				for device := range bind.devices {
+					allDevices[device] = true
+				}
+			}
+			ipPort = &srv.IpPort{CertFile: certFile, KeyFile: keyFile, Limits: ipPort.Limits}
+			for i, bind := range binds {
+				wrap := portDevicesOnly(bind.devices, allDevices)
+				if i == 0 {
+					ipPort.Ip, ipPort.Port, ipPort.Wrap = bind.ip, bind.port, wrap
+				} else {
+					ipPort.ExtraBinds = append(ipPort.ExtraBinds, &srv.IpPort{Ip: bind.ip, Port: bind.port, Wrap: wrap, Limits: ipPort.Limits})
+				}
+			}
+		}
+	}
 	return ipPort, server, server.logger, nil
 }
+
+// portBind is one address and port from the object rings, and the local
+// devices the rings put there.
+type portBind struct {
+	ip      string
+	port    int
+	devices map[string]bool
+}
+
+// ringBinds returns the address of every device in the object rings that is
+// on this machine and within bindIPs, unless one of those is a wildcard.
+// With servers_per_port each device (or group of devices) has its own port
+// in the ring, and a listener is started for each one so requests to a
+// struggling disk queue up on its own port.
+func ringBinds(cnf srv.ConfigLoader, prefix, suffix string, bindIPs []string) ([]*portBind, error) {
+	localIPs := map[string]bool{}
+	wildcard := false
+	for _, ip := range bindIPs {
+		switch ip {
+		case "", "0.0.0.0", "::", "[::]":
+			wildcard = true
+		default:
+			localIPs[strings.Trim(ip, "[]")] = true
+		}
+	}
+	if wildcard {
+		addrs, err := net.InterfaceAddrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			localIPs[strings.Split(addr.String(), "/")[0]] = true
+		}
+	}
+	policies, err := cnf.GetPolicies()
+	if err != nil {
+		return nil, err
+	}
+	var binds []*portBind
+	seen := map[string]*portBind{}
+	for _, policy := range policies {
+		objRing, err := cnf.GetRing("object", prefix, suffix, policy.Index)
+		if err != nil {
+			return nil, err
+		}
+		for _, dev := range objRing.AllDevices() {
+			if dev == nil || !dev.Active() || !localIPs[dev.Ip] {
+				continue
+			}
+			key := fmt.Sprintf("%s:%d", dev.Ip, dev.Port)
+			bind := seen[key]
+			if bind == nil {
+				bind = &portBind{ip: dev.Ip, port: dev.Port, devices: map[string]bool{}}
+				seen[key] = bind
+				binds = append(binds, bind)
+			}
+			bind.devices[dev.Device] = true
+		}
+	}
+	return binds, nil
+}
+
+// portDevicesOnly keeps a servers_per_port listener to its own devices: a
+// request for one of this server's devices that the rings put on another
+// port is refused, so a slow disk can only tie up its own port's
+// connections (max_clients applies to each listener).
+func portDevicesOnly(devices, allDevices map[string]bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			device := strings.SplitN(strings.TrimPrefix(request.URL.Path, "/"), "/", 2)[0]
+			if allDevices[device] && !devices[device] {
+				srv.StandardResponse(writer, http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
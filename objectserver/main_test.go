@@ -36,6 +36,7 @@ import (
 	"github.com/troubling/hummingbird/common"
 	"github.com/troubling/hummingbird/common/conf"
 	"github.com/troubling/hummingbird/common/pickle"
+	"github.com/troubling/hummingbird/common/ring"
 	"github.com/troubling/hummingbird/common/srv"
 	"github.com/troubling/hummingbird/common/test"
 
@@ -169,6 +170,29 @@ func TestBasicPutGet(t *testing.T) {
 	assert.Equal(t, "9", resp.Header.Get("Content-Length"))
 }
 
+func TestChunkedPutHead(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	ts, err := makeObjectServer(confLoader)
+	assert.Nil(t, err)
+	defer ts.Close()
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), ioutil.NopCloser(bytes.NewBuffer([]byte("SOME DATA"))))
+	assert.Nil(t, err)
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-Timestamp", common.GetTimestamp())
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	resp, err = ts.Do("HEAD", "/sda/0/a/c/o", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "9", resp.Header.Get("Content-Length"))
+	assert.Equal(t, "9", resp.Header.Get("X-Backend-Content-Length"))
+}
+
 func TestBasicPutDelete(t *testing.T) {
 	testRing := &test.FakeRing{}
 	confLoader := srv.NewTestConfigLoader(testRing)
@@ -197,6 +221,62 @@ func TestBasicPutDelete(t *testing.T) {
 	resp, err = ts.Do("GET", "/sda/0/a/c/o", nil)
 	assert.Nil(t, err)
 	assert.Equal(t, 404, resp.StatusCode)
+	assert.Equal(t, timestamp, resp.Header.Get("X-Backend-Timestamp"))
+
+	resp, err = ts.Do("HEAD", "/sda/0/a/c/o", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.Equal(t, timestamp, resp.Header.Get("X-Backend-Timestamp"))
+}
+
+func TestPostTimestampOffset(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	ts, err := makeObjectServer(confLoader)
+	require.Nil(t, err)
+	defer ts.Close()
+	do := func(method string, headers map[string]string, body string) *http.Response {
+		req, err := http.NewRequest(method, fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), bytes.NewBufferString(body))
+		require.Nil(t, err)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		return resp
+	}
+
+	timestamp := common.GetTimestamp()
+	resp := do("PUT", map[string]string{"Content-Type": "text/plain", "X-Timestamp": timestamp}, "SOME DATA")
+	require.Equal(t, 201, resp.StatusCode)
+
+	// a POST with the same time as the PUT but a higher offset is newer
+	postTimestamp := timestamp + "_0000000000000002"
+	resp = do("POST", map[string]string{"X-Timestamp": postTimestamp, "X-Object-Meta-Offset": "2"}, "")
+	require.Equal(t, 202, resp.StatusCode)
+	resp = do("POST", map[string]string{"X-Timestamp": postTimestamp, "X-Object-Meta-Offset": "again"}, "")
+	require.Equal(t, 409, resp.StatusCode)
+
+	resp = do("HEAD", nil, "")
+	require.Equal(t, 200, resp.StatusCode)
+	require.Equal(t, "2", resp.Header.Get("X-Object-Meta-Offset"))
+	require.Equal(t, timestamp, resp.Header.Get("X-Backend-Data-Timestamp"))
+	require.Equal(t, postTimestamp, resp.Header.Get("X-Backend-Meta-Timestamp"))
+
+	// a PUT only has to be newer than the data, and the newer .meta still
+	// applies to it
+	resp = do("PUT", map[string]string{"Content-Type": "text/plain", "X-Timestamp": timestamp}, "SOME DATA")
+	require.Equal(t, 409, resp.StatusCode)
+	putTimestamp := timestamp + "_0000000000000001"
+	resp = do("PUT", map[string]string{"Content-Type": "text/plain", "X-Timestamp": putTimestamp}, "NEW DATA")
+	require.Equal(t, 201, resp.StatusCode)
+	resp = do("GET", nil, "")
+	require.Equal(t, 200, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, "NEW DATA", string(body))
+	require.Equal(t, "2", resp.Header.Get("X-Object-Meta-Offset"))
+	require.Equal(t, putTimestamp, resp.Header.Get("X-Backend-Data-Timestamp"))
 }
 
 func TestBasicPutPostGet(t *testing.T) {
@@ -239,6 +319,25 @@ func TestPostContentType(t *testing.T) {
 	ts, err := makeObjectServer(confLoader)
 	assert.Nil(t, err)
 	defer ts.Close()
+	var updates []*http.Request
+	cs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		updates = append(updates, r)
+		w.WriteHeader(201)
+	}))
+	defer cs.Close()
+	u, err := url.Parse(cs.URL)
+	assert.Nil(t, err)
+	post := func(header http.Header) {
+		req, err := http.NewRequest("POST", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), nil)
+		assert.Nil(t, err)
+		req.Header = header
+		req.Header.Set("X-Container-Partition", "1")
+		req.Header.Set("X-Container-Host", u.Host)
+		req.Header.Set("X-Container-Device", "sdb")
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		assert.Equal(t, 202, resp.StatusCode)
+	}
 
 	timestamp := common.GetTimestamp()
 	req, err := http.NewRequest("PUT", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), bytes.NewBuffer([]byte("SOME DATA")))
@@ -250,14 +349,92 @@ func TestPostContentType(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, 201, resp.StatusCode)
 
+	timestamp = common.GetTimestamp()
+	post(http.Header{"Content-Type": {"any/thing"}, "X-Timestamp": {timestamp}})
+	// The container listing is updated with the new Content-Type.
+	assert.Equal(t, 1, len(updates))
+	assert.Equal(t, "PUT", updates[0].Method)
+	assert.Equal(t, "/sdb/1/a/c/o", updates[0].URL.Path)
+	assert.Equal(t, "any/thing", updates[0].Header.Get("X-Content-Type"))
+	assert.Equal(t, "9", updates[0].Header.Get("X-Size"))
+	assert.Equal(t, timestamp, updates[0].Header.Get("X-Timestamp"))
+
+	resp, err = ts.Do("GET", "/sda/0/a/c/o", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "any/thing", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "9", resp.Header.Get("Content-Length"))
+
+	// A later POST without a Content-Type keeps the changed one.
+	post(http.Header{"X-Object-Meta-Color": {"blue"}, "X-Timestamp": {common.GetTimestamp()}})
+	// Which leaves the listing alone.
+	assert.Equal(t, 1, len(updates))
+
+	resp, err = ts.Do("GET", "/sda/0/a/c/o", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "any/thing", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "blue", resp.Header.Get("X-Object-Meta-Color"))
+}
+
+func TestPostReplacesMetadata(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	ts, err := makeObjectServer(confLoader)
+	assert.Nil(t, err)
+	defer ts.Close()
+
+	timestamp := common.GetTimestamp()
+	req, err := http.NewRequest("PUT", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), bytes.NewBuffer([]byte("SOME DATA")))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Length", "9")
+	req.Header.Set("X-Object-Meta-First", "1")
+	req.Header.Set("X-Object-Sysmeta-Thing", "kept")
+	req.Header.Set("X-Timestamp", timestamp)
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
 	timestamp = common.GetTimestamp()
 	req, err = http.NewRequest("POST", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), nil)
 	assert.Nil(t, err)
-	req.Header.Set("Content-Type", "any/thing")
+	req.Header.Set("X-Object-Meta-Second", "2")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Content-Disposition", "attachment")
+	req.Header.Set("X-Static-Large-Object", "True")
+	req.Header.Set("X-Object-Sysmeta-Thing", "changed")
 	req.Header.Set("X-Timestamp", timestamp)
 	resp, err = http.DefaultClient.Do(req)
 	assert.Nil(t, err)
-	assert.Equal(t, 409, resp.StatusCode)
+	assert.Equal(t, 202, resp.StatusCode)
+
+	resp, err = ts.Do("HEAD", "/sda/0/a/c/o", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "", resp.Header.Get("X-Object-Meta-First"))
+	assert.Equal(t, "2", resp.Header.Get("X-Object-Meta-Second"))
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "attachment", resp.Header.Get("Content-Disposition"))
+	assert.Equal(t, "", resp.Header.Get("X-Static-Large-Object"))
+	assert.Equal(t, "kept", resp.Header.Get("X-Object-Sysmeta-Thing"))
+	assert.Equal(t, "application/octet-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "9", resp.Header.Get("Content-Length"))
+
+	timestamp = common.GetTimestamp()
+	req, err = http.NewRequest("POST", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), nil)
+	assert.Nil(t, err)
+	req.Header.Set("X-Timestamp", timestamp)
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 202, resp.StatusCode)
+
+	resp, err = ts.Do("HEAD", "/sda/0/a/c/o", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "", resp.Header.Get("X-Object-Meta-Second"))
+	assert.Equal(t, "", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "", resp.Header.Get("Content-Disposition"))
 }
 
 func TestPostNotFound(t *testing.T) {
@@ -327,6 +504,48 @@ func TestGetRanges(t *testing.T) {
 	assert.Equal(t, 2, strings.Count(string(body), "UVWXYZ"))
 }
 
+func TestConditionalGet(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	ts, err := makeObjectServer(confLoader)
+	assert.Nil(t, err)
+	defer ts.Close()
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), bytes.NewBuffer([]byte("SOME DATA")))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Length", "9")
+	req.Header.Set("Cache-Control", "max-age=300")
+	req.Header.Set("X-Timestamp", "1500000000.12345")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	resp, err = ts.Do("GET", "/sda/0/a/c/o", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "max-age=300", resp.Header.Get("Cache-Control"))
+	lastModified := resp.Header.Get("Last-Modified")
+	assert.Equal(t, "Fri, 14 Jul 2017 02:40:01 GMT", lastModified)
+	etag := resp.Header.Get("Etag")
+
+	conditionalGet := func(header, value string) int {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), nil)
+		assert.Nil(t, err)
+		req.Header.Set(header, value)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	// The Last-Modified a client was given counts as not modified since.
+	assert.Equal(t, 304, conditionalGet("If-Modified-Since", lastModified))
+	assert.Equal(t, 200, conditionalGet("If-Modified-Since", "Fri, 14 Jul 2017 02:40:00 GMT"))
+	assert.Equal(t, 200, conditionalGet("If-Unmodified-Since", lastModified))
+	assert.Equal(t, 412, conditionalGet("If-Unmodified-Since", "Fri, 14 Jul 2017 02:40:00 GMT"))
+	assert.Equal(t, 304, conditionalGet("If-None-Match", "W/"+etag))
+}
+
 func TestBadEtag(t *testing.T) {
 	testRing := &test.FakeRing{}
 	confLoader := srv.NewTestConfigLoader(testRing)
@@ -367,6 +586,180 @@ func TestCorrectEtag(t *testing.T) {
 	assert.Equal(t, etag, resp.Header.Get("Etag"))
 }
 
+func TestChecksums(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	ts, err := makeObjectServer(confLoader, "checksums", "sha256")
+	assert.Nil(t, err)
+	defer ts.Close()
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port),
+		bytes.NewBuffer([]byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ")))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Length", "26")
+	req.Header.Set("X-Object-Checksum-Crc32c", "MZiXzQ==")
+	req.Header.Set("X-Timestamp", common.GetTimestamp())
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, "1uxomN6H3axuWzYRcIp6ocLSmCkzScwabCmaHbcUnTg=", resp.Header.Get("X-Object-Checksum-Sha256"))
+	assert.Equal(t, "MZiXzQ==", resp.Header.Get("X-Object-Checksum-Crc32c"))
+
+	req, err = http.NewRequest("POST", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), nil)
+	assert.Nil(t, err)
+	req.Header.Set("X-Object-Meta-Color", "Blue")
+	req.Header.Set("X-Timestamp", common.GetTimestamp())
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 202, resp.StatusCode)
+
+	req, err = http.NewRequest("HEAD", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), nil)
+	assert.Nil(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "1uxomN6H3axuWzYRcIp6ocLSmCkzScwabCmaHbcUnTg=", resp.Header.Get("X-Object-Checksum-Sha256"))
+	assert.Equal(t, "MZiXzQ==", resp.Header.Get("X-Object-Checksum-Crc32c"))
+
+	req, err = http.NewRequest("PUT", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port),
+		bytes.NewBuffer([]byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ")))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Length", "26")
+	req.Header.Set("X-Object-Checksum-Sha256", "MZiXzQ==")
+	req.Header.Set("X-Timestamp", common.GetTimestamp())
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 422, resp.StatusCode)
+}
+
+func TestAppend(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	ts, err := makeObjectServer(confLoader)
+	assert.Nil(t, err)
+	defer ts.Close()
+
+	putTimestamp := common.GetTimestamp()
+	req, err := http.NewRequest("PUT", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port),
+		bytes.NewBuffer([]byte("ABCDEFGHIJKLM")))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Content-Length", "13")
+	req.Header.Set("X-Object-Meta-Color", "Blue")
+	req.Header.Set("X-Timestamp", putTimestamp)
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	req, err = http.NewRequest("APPEND", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port),
+		bytes.NewBuffer([]byte("NOPQRSTUVWXYZ")))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Length", "13")
+	req.Header.Set("X-Backend-Append-Timestamp", "1000000000.00000")
+	req.Header.Set("X-Timestamp", common.GetTimestamp())
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 409, resp.StatusCode)
+
+	req.Body = ioutil.NopCloser(bytes.NewBuffer([]byte("NOPQRSTUVWXYZ")))
+	req.Header.Set("X-Backend-Append-Timestamp", putTimestamp)
+	req.Header.Set("X-Timestamp", common.GetTimestamp())
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, "437bba8e0bf58337674f4539e75186ac", resp.Header.Get("Etag"))
+
+	req, err = http.NewRequest("GET", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), nil)
+	assert.Nil(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "ABCDEFGHIJKLMNOPQRSTUVWXYZ", string(body))
+	assert.Equal(t, "26", resp.Header.Get("Content-Length"))
+	assert.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "Blue", resp.Header.Get("X-Object-Meta-Color"))
+
+	req, err = http.NewRequest("APPEND", fmt.Sprintf("http://%s:%d/sda/0/a/c/o2", ts.host, ts.port),
+		bytes.NewBuffer([]byte("ABC")))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Length", "3")
+	req.Header.Set("X-Timestamp", common.GetTimestamp())
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+func TestAppendManifest(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	ts, err := makeObjectServer(confLoader)
+	assert.Nil(t, err)
+	defer ts.Close()
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), bytes.NewBuffer(nil))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Content-Length", "0")
+	req.Header.Set("X-Object-Manifest", "c/o-")
+	req.Header.Set("X-Timestamp", common.GetTimestamp())
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	req, err = http.NewRequest("APPEND", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port),
+		bytes.NewBuffer([]byte("ABC")))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Length", "3")
+	req.Header.Set("X-Timestamp", common.GetTimestamp())
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 409, resp.StatusCode)
+}
+
+func TestEtagTrailer(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	ts, err := makeObjectServer(confLoader)
+	assert.Nil(t, err)
+	defer ts.Close()
+
+	put := func(trailer http.Header) *http.Response {
+		req, err := http.NewRequest("PUT", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port),
+			ioutil.NopCloser(bytes.NewBuffer([]byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ"))))
+		assert.Nil(t, err)
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("X-Timestamp", common.GetTimestamp())
+		req.Trailer = trailer
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		return resp
+	}
+	resp := put(http.Header{"X-Object-Etag": {"11111111111111111111111111111111"}})
+	assert.Equal(t, 422, resp.StatusCode)
+	resp = put(http.Header{"X-Object-Checksum-Crc32c": {"AAAAAA=="}})
+	assert.Equal(t, 422, resp.StatusCode)
+	resp = put(http.Header{
+		"X-Object-Etag":            {"437bba8e0bf58337674f4539e75186ac"},
+		"X-Object-Checksum-Crc32c": {"MZiXzQ=="},
+		"X-Object-Sysmeta-Test":    {"trailing"},
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, "MZiXzQ==", resp.Header.Get("X-Object-Checksum-Crc32c"))
+
+	req, err := http.NewRequest("HEAD", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), nil)
+	assert.Nil(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "26", resp.Header.Get("Content-Length"))
+	assert.Equal(t, "trailing", resp.Header.Get("X-Object-Sysmeta-Test"))
+}
+
 func TestUppercaseEtag(t *testing.T) {
 	testRing := &test.FakeRing{}
 	confLoader := srv.NewTestConfigLoader(testRing)
@@ -566,6 +959,41 @@ func TestBasicPutDeleteAt(t *testing.T) {
 	assert.Equal(t, 200, resp.StatusCode)
 }
 
+func TestOpenExpired(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	ts, err := makeObjectServer(confLoader)
+	require.Nil(t, err)
+	defer ts.Close()
+	do := func(method string, body io.Reader, headers map[string]string) *http.Response {
+		req, err := http.NewRequest(method, fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), body)
+		require.Nil(t, err)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	deleteAt := fmt.Sprintf("%.2f", float64(time.Now().UnixNano())/float64(time.Second)+0.2)
+	resp := do("PUT", bytes.NewBuffer([]byte("SOME DATA")), map[string]string{
+		"Content-Type": "application/octet-stream", "X-Timestamp": common.GetTimestamp(), "X-Delete-At": deleteAt})
+	require.Equal(t, 201, resp.StatusCode)
+	time.Sleep(300 * time.Millisecond)
+
+	for _, method := range []string{"GET", "HEAD"} {
+		require.Equal(t, 404, do(method, nil, nil).StatusCode)
+		resp = do(method, nil, map[string]string{"X-Backend-Open-Expired": "true"})
+		require.Equal(t, 200, resp.StatusCode)
+		require.Equal(t, deleteAt, resp.Header.Get("X-Delete-At"))
+	}
+	require.Equal(t, 404, do("POST", nil, map[string]string{"X-Timestamp": common.GetTimestamp()}).StatusCode)
+	resp = do("POST", nil, map[string]string{"X-Timestamp": common.GetTimestamp(), "X-Backend-Open-Expired": "true"})
+	require.Equal(t, 202, resp.StatusCode)
+}
+
 type slowReader struct {
 	readChan chan int
 	id       int
@@ -643,6 +1071,67 @@ func TestAcquireDevice(t *testing.T) {
 	<-done1
 }
 
+func TestAcquireDevicePriority(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	ts, err := makeObjectServer(confLoader, "disk_limit", "2/0", "interactive_disk_reserve", "1", "replication_disk_limit", "1/0")
+	require.Nil(t, err)
+	defer ts.Close()
+
+	put := func(obj, priority string) *http.Response {
+		req, err := http.NewRequest("PUT", fmt.Sprintf("http://%s:%d/sda/0/a/c/%s", ts.host, ts.port, obj), bytes.NewBuffer([]byte("SOME DATA")))
+		require.Nil(t, err)
+		req.Header.Set("Content-Type", "text")
+		req.Header.Set("X-Timestamp", common.GetTimestamp())
+		if priority != "" {
+			req.Header.Set(common.PriorityHeader, priority)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	require.Equal(t, 201, put("o1", common.PriorityBackground).StatusCode)
+
+	// With one slot in use, the last one is kept for interactive requests.
+	require.Equal(t, int64(0), ts.objServer.diskInUse.Acquire("sda", false))
+	require.Equal(t, 503, put("o2", common.PriorityBackground).StatusCode)
+	require.Equal(t, 201, put("o3", "").StatusCode)
+	ts.objServer.diskInUse.Release("sda")
+
+	require.Equal(t, int64(0), ts.objServer.priorityDiskInUse[common.PriorityReplication].Acquire("sda", false))
+	resp := put("o4", common.PriorityReplication)
+	require.Equal(t, 503, resp.StatusCode)
+	require.Equal(t, "1", resp.Header.Get("X-Disk-Usage"))
+	require.Equal(t, 201, put("o5", common.PriorityBackground).StatusCode)
+}
+
+func TestMountCheckHeader(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	ts, err := makeObjectServer(confLoader)
+	require.Nil(t, err)
+	defer ts.Close()
+
+	timestamp := common.GetTimestamp()
+	req, err := http.NewRequest("PUT", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), bytes.NewBuffer([]byte("SOME DATA")))
+	require.Nil(t, err)
+	req.Header.Set("Content-Type", "text")
+	req.Header.Set("X-Timestamp", timestamp)
+	req.Header.Set("X-Backend-Mount-Check", "true")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	require.Equal(t, 507, resp.StatusCode)
+
+	req, err = http.NewRequest("PUT", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), bytes.NewBuffer([]byte("SOME DATA")))
+	require.Nil(t, err)
+	req.Header.Set("Content-Type", "text")
+	req.Header.Set("X-Timestamp", timestamp)
+	resp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	require.Equal(t, 201, resp.StatusCode)
+}
+
 func TestAccountAcquireDevice(t *testing.T) {
 	testRing := &test.FakeRing{}
 	confLoader := srv.NewTestConfigLoader(testRing)
@@ -698,3 +1187,84 @@ func TestAccountAcquireDevice(t *testing.T) {
 	//1 exiting goroutine
 	<-done1
 }
+
+type allDevicesRing struct {
+	*test.FakeRing
+}
+
+func (r *allDevicesRing) AllDevices() []*ring.Device {
+	return r.MockDevices
+}
+
+func TestRingBinds(t *testing.T) {
+	testRing := &allDevicesRing{&test.FakeRing{MockDevices: []*ring.Device{
+		{Id: 0, Device: "sda", Ip: "127.0.0.1", Port: 6010, Weight: 1},
+		{Id: 1, Device: "sdb", Ip: "127.0.0.1", Port: 6020, Weight: 1},
+		{Id: 2, Device: "sdc", Ip: "127.0.0.1", Port: 6010, Weight: 1},
+		{Id: 3, Device: "sdd", Ip: "127.0.0.1", Port: 6030, Weight: -1},
+		{Id: 4, Device: "sde", Ip: "192.0.2.1", Port: 6040, Weight: 1},
+	}}}
+	binds, err := ringBinds(srv.NewTestConfigLoader(testRing), "", "", []string{"0.0.0.0"})
+	require.Nil(t, err)
+	require.Equal(t, []*portBind{
+		{ip: "127.0.0.1", port: 6010, devices: map[string]bool{"sda": true, "sdc": true}},
+		{ip: "127.0.0.1", port: 6020, devices: map[string]bool{"sdb": true}},
+	}, binds)
+	binds, err = ringBinds(srv.NewTestConfigLoader(testRing), "", "", []string{"192.0.2.9"})
+	require.Nil(t, err)
+	require.Empty(t, binds)
+}
+
+func TestNetworkBuffersOnEveryBind(t *testing.T) {
+	driveRoot, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(driveRoot)
+	config, err := conf.StringConfig(fmt.Sprintf("[app:object-server]\ndevices=%s\nmount_check=false\nbind_ip=127.0.0.1, 127.0.0.2\nbind_port=6000\nnetwork_write_buffer=131072\n", driveRoot))
+	require.Nil(t, err)
+	ipPort, _, _, err := NewServer(config, &flag.FlagSet{}, srv.NewTestConfigLoader(&test.FakeRing{}))
+	require.Nil(t, err)
+	require.Equal(t, 131072, ipPort.Limits.NetworkWriteBuffer)
+	require.Equal(t, 1, len(ipPort.ExtraBinds))
+	require.Equal(t, "127.0.0.2", ipPort.ExtraBinds[0].Ip)
+	require.Equal(t, ipPort.Limits, ipPort.ExtraBinds[0].Limits)
+}
+
+func TestServersPerPortWildcardBindIP(t *testing.T) {
+	testRing := &allDevicesRing{&test.FakeRing{MockDevices: []*ring.Device{
+		{Id: 0, Device: "sda", Ip: "127.0.0.1", Port: 6000, Weight: 1},
+		{Id: 1, Device: "sdb", Ip: "127.0.0.1", Port: 6010, Weight: 1},
+	}}}
+	driveRoot, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(driveRoot)
+	config, err := conf.StringConfig(fmt.Sprintf("[app:object-server]\ndevices=%s\nmount_check=false\nbind_ip=0.0.0.0\nbind_port=6000\nservers_per_port=1\nnetwork_read_buffer=65536\n", driveRoot))
+	require.Nil(t, err)
+	ipPort, _, _, err := NewServer(config, &flag.FlagSet{}, srv.NewTestConfigLoader(testRing))
+	require.Nil(t, err)
+	// Only the ring's addresses are bound; a wildcard listener on 6000
+	// would collide with sda's.
+	require.Equal(t, "127.0.0.1", ipPort.Ip)
+	require.Equal(t, 6000, ipPort.Port)
+	require.Equal(t, 1, len(ipPort.ExtraBinds))
+	require.Equal(t, "127.0.0.1", ipPort.ExtraBinds[0].Ip)
+	require.Equal(t, 6010, ipPort.ExtraBinds[0].Port)
+	require.Equal(t, 65536, ipPort.Limits.NetworkReadBuffer)
+	require.Equal(t, ipPort.Limits, ipPort.ExtraBinds[0].Limits)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200) })
+	for _, tc := range []struct {
+		wrap   func(http.Handler) http.Handler
+		path   string
+		status int
+	}{
+		{ipPort.Wrap, "/sda/1/a/c/o", 200},
+		{ipPort.Wrap, "/sdb/1/a/c/o", 503},
+		{ipPort.Wrap, "/healthcheck", 200},
+		{ipPort.ExtraBinds[0].Wrap, "/sdb/1/a/c/o", 200},
+		{ipPort.ExtraBinds[0].Wrap, "/sda/1/a/c/o", 503},
+	} {
+		w := httptest.NewRecorder()
+		tc.wrap(handler).ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+		require.Equal(t, tc.status, w.Code, tc.path)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"hash/fnv"
 	"sort"
 	"strings"
+
+	"github.com/troubling/hummingbird/common"
 )
 
 // MetadataHash returns a hash of the contents of the metadata.
@@ -24,21 +26,26 @@ func MetadataHash(metadata map[string]string) string {
 	return fmt.Sprintf("%016x", hasher.Sum64())
 }
 
+// isDataFileMetadata reports whether key describes the object's data rather
+// than being set by requests, so a POST never replaces it. Content-Type is
+// the exception: a POST may change it, and it's carried from the data
+// otherwise.
+func isDataFileMetadata(key string) bool {
+	switch key {
+	case "Content-Length", "deleted", "ETag", "Ec-Scheme", "X-Static-Large-Object":
+		return true
+	}
+	return strings.HasPrefix(key, "X-Object-Sysmeta-") || strings.HasPrefix(key, common.ChecksumHeaderPrefix)
+}
+
 // MetadataMerge will return the result of merging the a and b metadata sets;
 // neither a nor b should be used after calling this method.
 func MetadataMerge(a map[string]string, b map[string]string) map[string]string {
 	if a["X-Timestamp"] < b["X-Timestamp"] {
 		a, b = b, a
 	}
-	for _, key := range []string{"Content-Length", "Content-Type", "deleted", "ETag"} {
-		if _, ok := a[key]; !ok {
-			if value, ok := b[key]; ok {
-				a[key] = value
-			}
-		}
-	}
 	for key, value := range b {
-		if strings.HasPrefix(key, "X-Object-Sysmeta-") {
+		if isDataFileMetadata(key) || key == "Content-Type" {
 			if _, ok := a[key]; !ok {
 				a[key] = value
 			}
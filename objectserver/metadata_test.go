@@ -0,0 +1,38 @@
+package objectserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataMerge(t *testing.T) {
+	put := map[string]string{
+		"X-Timestamp":            "1500000000.00000",
+		"Content-Length":         "9",
+		"Content-Type":           "text/plain",
+		"ETag":                   "abc",
+		"X-Object-Meta-First":    "1",
+		"X-Object-Sysmeta-Thing": "kept",
+		"X-Static-Large-Object":  "True",
+	}
+	post := map[string]string{
+		"X-Timestamp":          "1500000001.00000",
+		"Content-Type":         "text/html",
+		"X-Object-Meta-Second": "2",
+	}
+	merged := MetadataMerge(post, put)
+	require.Equal(t, map[string]string{
+		"X-Timestamp":            "1500000001.00000",
+		"Content-Length":         "9",
+		"Content-Type":           "text/html",
+		"ETag":                   "abc",
+		"X-Object-Meta-Second":   "2",
+		"X-Object-Sysmeta-Thing": "kept",
+		"X-Static-Large-Object":  "True",
+	}, merged)
+
+	put = map[string]string{"X-Timestamp": "1500000000.00000", "Content-Type": "text/plain"}
+	post = map[string]string{"X-Timestamp": "1500000001.00000"}
+	require.Equal(t, "text/plain", MetadataMerge(put, post)["Content-Type"])
+}
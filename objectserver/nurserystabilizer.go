@@ -19,11 +19,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/troubling/hummingbird/common"
 	"github.com/troubling/hummingbird/common/fs"
 	"github.com/troubling/hummingbird/common/ring"
 	"github.com/uber-go/tally"
@@ -80,7 +84,14 @@ func (nrd *nurseryDevice) Scan() {
 			defer func() {
 				<-nrd.r.nurseryConcurrencySem
 			}()
+			var metadata map[string]string
+			if o.Exists() {
+				metadata = o.Metadata()
+			}
 			if err := o.Stabilize(nrd.dev); err == nil {
+				if metadata != nil {
+					nrd.updateStable(metadata)
+				}
 				nrd.stabilizationSuccessesMetric.Inc(1)
 				nrd.UpdateStat("ObjectsStabilizedSuccess", 1)
 				nrd.UpdateStat("ObjectsStabilizedBytes", o.ContentLength())
@@ -107,6 +118,43 @@ func (nrd *nurseryDevice) Scan() {
 		zap.Duration("timeTook", time.Since(start)))
 }
 
+// updateStable tells the container the object is no longer in the nursery.
+// The update's timestamp is offset from the object's so it replaces the
+// listing the object server sent, but not one for a newer PUT or DELETE.
+func (nrd *nurseryDevice) updateStable(metadata map[string]string) {
+	parts := strings.SplitN(strings.TrimPrefix(metadata["name"], "/"), "/", 3)
+	if len(parts) != 3 {
+		return
+	}
+	timestamp, err := common.OffsetTimestamp(metadata["X-Timestamp"], 1)
+	if err != nil {
+		nrd.r.logger.Error("[stabilizeDevice] bad timestamp for container update", zap.String("name", metadata["name"]), zap.Error(err))
+		return
+	}
+	size, etag := metadata["Content-Length"], metadata["ETag"]
+	if override, ok := metadata["X-Object-Sysmeta-Container-Update-Override-Size"]; ok {
+		size = override
+	}
+	if override, ok := metadata["X-Object-Sysmeta-Container-Update-Override-Etag"]; ok {
+		etag = override
+	}
+	header := http.Header{
+		"X-Backend-Storage-Policy-Index": {strconv.Itoa(nrd.policy)},
+		"X-Backend-Object-State":         {"stable"},
+		"X-Timestamp":                    {timestamp},
+		"X-Content-Type":                 {metadata["Content-Type"]},
+		"X-Size":                         {size},
+		"X-Etag":                         {etag},
+		"User-Agent":                     {fmt.Sprintf("object-stabilizer %d", os.Getpid())},
+	}
+	if deleteAt, ok := metadata["X-Delete-At"]; ok {
+		header.Set("X-Delete-At", deleteAt)
+	}
+	if !nrd.r.updateContainers("PUT", parts[0], parts[1], parts[2], header) {
+		nrd.r.logger.Error("[stabilizeDevice] container update failed", zap.String("name", metadata["name"]))
+	}
+}
+
 func (nrd *nurseryDevice) ScanLoop() {
 	for {
 		select {
@@ -179,10 +227,11 @@ func GetNurseryDevice(oring ring.Ring, dev *ring.Device, policy int, r *Replicat
 		canchan:   make(chan struct{}),
 		objEngine: f,
 	}
-	nrd.stabilizationAttemptsMetric = r.metricsScope.Counter(fmt.Sprintf("%d_%s_stabilization_attempts", policy, dev.Device))
-	nrd.stabilizationSuccessesMetric = r.metricsScope.Counter(fmt.Sprintf("%d_%s_stabilization_successes", policy, dev.Device))
-	nrd.stabilizationFailuresMetric = r.metricsScope.Counter(fmt.Sprintf("%d_%s_stabilization_failures", policy, dev.Device))
-	nrd.stabilizationLastPassCountMetric = r.metricsScope.Gauge(fmt.Sprintf("%d_%s_stabilization_last_pass_count", policy, dev.Device))
-	nrd.stabilizationLastPassDurationMetric = r.metricsScope.Timer(fmt.Sprintf("%d_%s_stabilization_last_pass_duration", policy, dev.Device))
+	scope := r.deviceMetricsScope(policy, dev.Device)
+	nrd.stabilizationAttemptsMetric = scope.Counter("stabilization_attempts")
+	nrd.stabilizationSuccessesMetric = scope.Counter("stabilization_successes")
+	nrd.stabilizationFailuresMetric = scope.Counter("stabilization_failures")
+	nrd.stabilizationLastPassCountMetric = scope.Gauge("stabilization_last_pass_count")
+	nrd.stabilizationLastPassDurationMetric = scope.Timer("stabilization_last_pass_duration")
 	return nrd, nil
 }
@@ -72,7 +72,7 @@ func (d *devLimiter) waitForSomethingToFinish() {
 }
 
 func SendPriRepJob(job *PriorityRepJob, client common.HTTPClient, userAgent string) (string, bool) {
-	url := fmt.Sprintf("%s://%s:%d/priorityrep", job.FromDevice.Scheme, job.FromDevice.ReplicationIp, job.FromDevice.ReplicationPort)
+	url := fmt.Sprintf("%s://%s:%d%s/priorityrep", job.FromDevice.Scheme, job.FromDevice.ReplicationIp, job.FromDevice.ReplicationPort, job.FromDevice.PathPrefix)
 	jsonned, err := json.Marshal(job)
 	if err != nil {
 		return fmt.Sprintf("Failed to serialize job for some reason: %s", err), false
@@ -281,7 +281,7 @@ func doMoveParts(args []string, cnf srv.ConfigLoader) int {
 	}
 	// TODO: Do we want to trace requests with this client?
 	client := &http.Client{Timeout: time.Hour,
-		Transport: transport,
+		Transport: srv.NewSigningTransport(transport),
 	}
 	badParts := []uint64{}
 	for {
@@ -441,7 +441,7 @@ func RestoreDevice(args []string, cnf srv.ConfigLoader) {
 	// TODO: Do we want to trace requests with this client?
 	client := &http.Client{
 		Timeout:   time.Hour * 4,
-		Transport: transport,
+		Transport: srv.NewSigningTransport(transport),
 	}
 	badParts := []uint64{}
 	for {
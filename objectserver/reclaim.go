@@ -0,0 +1,160 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package objectserver
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/fs"
+	"github.com/troubling/hummingbird/common/srv"
+)
+
+// objectReclaimAge returns the object server's reclaim_age. The auditor,
+// replicator and reclaim command all use it, so none of them removes a
+// tombstone the object server would still keep.
+func objectReclaimAge(serverconf conf.Config) int64 {
+	return serverconf.GetInt("app:object-server", "reclaim_age", int64(common.ONE_WEEK))
+}
+
+// reclaimDevice removes tombstones older than reclaimAge and empty hash dirs
+// from a policy's partitions on devicePath, returning how many hash dirs it
+// removed, or with dryRun would have.
+func reclaimDevice(devicePath string, policy int, reclaimAge int64, dryRun bool) (int, error) {
+	objPath := filepath.Join(devicePath, PolicyDir(policy))
+	partitions, err := fs.ReadDirNames(objPath)
+	if err != nil {
+		return 0, err
+	}
+	reclaimed := 0
+	for _, partition := range partitions {
+		if _, err := strconv.ParseUint(partition, 10, 64); err != nil {
+			continue
+		}
+		partitionDir := filepath.Join(objPath, partition)
+		suffixes, err := fs.ReadDirNames(partitionDir)
+		if err != nil {
+			return reclaimed, err
+		}
+		for _, suffix := range suffixes {
+			if len(suffix) != 3 {
+				continue
+			}
+			suffixDir := filepath.Join(partitionDir, suffix)
+			hashes, err := fs.ReadDirNames(suffixDir)
+			if err != nil {
+				continue
+			}
+			for _, hash := range hashes {
+				if len(hash) != 32 {
+					continue
+				}
+				hashDir := filepath.Join(suffixDir, hash)
+				fileList, err := fs.ReadDirNames(hashDir)
+				if err != nil {
+					continue
+				}
+				if dryRun {
+					if reclaimableHashDir(fileList, reclaimAge) {
+						reclaimed++
+					}
+				} else if ReclaimHashDir(hashDir, fileList, reclaimAge) {
+					reclaimed++
+				}
+			}
+		}
+	}
+	return reclaimed, nil
+}
+
+func doReclaim(args []string, configFile string, cnf srv.ConfigLoader) int {
+	flags := flag.NewFlagSet("reclaim", flag.ExitOnError)
+	configPath := flags.String("c", configFile, "object server config file/directory to read reclaim_age from")
+	devices := flags.String("devices", "/srv/node", "directory containing the devices to clean up")
+	device := flags.String("device", "", "only clean up this device")
+	reclaimAge := flags.Int64("reclaim_age", 0, "remove tombstones older than this many seconds (default the object server's reclaim_age)")
+	dryRun := flags.Bool("dry_run", false, "only count what would be removed")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "USAGE: hummingbird reclaim [-c config] [-reclaim_age seconds] [-dry_run]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if len(flags.Args()) != 0 {
+		flags.Usage()
+		return 1
+	}
+	if *reclaimAge <= 0 {
+		*reclaimAge = int64(common.ONE_WEEK)
+		if *configPath != "" {
+			serverconf, err := conf.LoadConfig(*configPath)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Unable to load config:", err)
+				return 1
+			}
+			*reclaimAge = objectReclaimAge(serverconf)
+		}
+	}
+	policies, err := cnf.GetPolicies()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to load policies:", err)
+		return 1
+	}
+	deviceDirs, err := ioutil.ReadDir(*devices)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to list devices:", err)
+		return 1
+	}
+	verb := "Reclaimed"
+	if *dryRun {
+		verb = "Would reclaim"
+	}
+	ret := 0
+	for _, dev := range deviceDirs {
+		if *device != "" && dev.Name() != *device {
+			continue
+		}
+		devicePath := filepath.Join(*devices, dev.Name())
+		for _, policy := range policies {
+			if policy.Type != "replication" && policy.Type != "replication-nursery" {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(devicePath, PolicyDir(policy.Index))); err != nil {
+				continue
+			}
+			reclaimed, err := reclaimDevice(devicePath, policy.Index, *reclaimAge, *dryRun)
+			if err != nil {
+				fmt.Printf("Error cleaning up %s policy %d: %v\n", dev.Name(), policy.Index, err)
+				ret = 1
+				continue
+			}
+			fmt.Printf("%s %d hash dirs on %s policy %d\n", verb, reclaimed, dev.Name(), policy.Index)
+		}
+	}
+	return ret
+}
+
+// Reclaim removes old tombstones and empty hash dirs from this server's
+// replicated policies in one pass, for devices the auditor and replicator
+// haven't gotten around to.
+func Reclaim(args []string, configFile string, cnf srv.ConfigLoader) {
+	os.Exit(doReclaim(args, configFile, cnf))
+}
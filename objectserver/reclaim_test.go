@@ -0,0 +1,72 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package objectserver
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/common/test"
+)
+
+func TestReclaimDevice(t *testing.T) {
+	devicePath, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(devicePath)
+	suffixDir := filepath.Join(devicePath, "objects", "1", "abc")
+	for _, hash := range []string{"fffffffffffffffffffffffffffffabc", "00000000000000000000000000000abc", "11111111111111111111111111111abc"} {
+		require.Nil(t, os.MkdirAll(filepath.Join(suffixDir, hash), 0777))
+	}
+	for _, name := range []string{"fffffffffffffffffffffffffffffabc/12345.ts", "00000000000000000000000000000abc/12345.data"} {
+		f, err := os.Create(filepath.Join(suffixDir, name))
+		require.Nil(t, err)
+		f.Close()
+	}
+
+	reclaimed, err := reclaimDevice(devicePath, 0, int64(common.ONE_WEEK), true)
+	require.Nil(t, err)
+	require.Equal(t, 2, reclaimed)
+	_, err = os.Stat(filepath.Join(suffixDir, "fffffffffffffffffffffffffffffabc", "12345.ts"))
+	require.Nil(t, err)
+
+	reclaimed, err = reclaimDevice(devicePath, 0, int64(common.ONE_WEEK), false)
+	require.Nil(t, err)
+	require.Equal(t, 2, reclaimed)
+	hashes, err := ioutil.ReadDir(suffixDir)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(hashes))
+	require.Equal(t, "00000000000000000000000000000abc", hashes[0].Name())
+}
+
+func TestReclaimAgeFromObjectServer(t *testing.T) {
+	serverconf, err := conf.StringConfig("[app:object-server]\nreclaim_age=86400\n[object-auditor]\nreclaim_age=60\n")
+	require.Nil(t, err)
+	require.Equal(t, int64(86400), objectReclaimAge(serverconf))
+	auditorDaemon, err := NewAuditorDaemon(serverconf, &flag.FlagSet{}, srv.NewTestConfigLoader(&test.FakeRing{}))
+	require.Nil(t, err)
+	require.Equal(t, int64(86400), auditorDaemon.reclaimAge)
+
+	serverconf, err = conf.StringConfig("[object-auditor]\n")
+	require.Nil(t, err)
+	require.Equal(t, int64(common.ONE_WEEK), objectReclaimAge(serverconf))
+}
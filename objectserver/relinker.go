@@ -0,0 +1,284 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package objectserver
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/middleware"
+)
+
+// relinkProgressInterval is how often a relink saves its progress.
+const relinkProgressInterval = time.Minute
+
+// relinkState records which old partitions on a device have been finished,
+// so an interrupted relink or cleanup can pick up where it left off.
+type relinkState struct {
+	PartPower uint            `json:"part_power"`
+	Cleanup   bool            `json:"cleanup"`
+	Done      map[string]bool `json:"done"`
+}
+
+func relinkStatePath(devicePath string, policy int) string {
+	return filepath.Join(devicePath, fmt.Sprintf("relink.%s.json", PolicyDir(policy)))
+}
+
+func loadRelinkState(path string, partPower uint, cleanup bool) *relinkState {
+	state := &relinkState{}
+	if data, err := ioutil.ReadFile(path); err == nil && json.Unmarshal(data, state) == nil &&
+		state.PartPower == partPower && state.Cleanup == cleanup && state.Done != nil {
+		return state
+	}
+	return &relinkState{PartPower: partPower, Cleanup: cleanup, Done: map[string]bool{}}
+}
+
+func (s *relinkState) save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// relinkPartition returns the partition hash belongs in for a ring with the
+// given partition power.
+func relinkPartition(hash string, partPower uint) (uint64, error) {
+	b, err := hex.DecodeString(hash)
+	if err != nil || len(b) < 4 {
+		return 0, fmt.Errorf("Invalid hash %q", hash)
+	}
+	return uint64(binary.BigEndian.Uint32(b[:4]) >> (32 - partPower)), nil
+}
+
+// relinkHashDir hard links the files in hashDir into the same hash directory
+// under newPartitionDir, invalidating its suffix if anything was linked.
+func relinkHashDir(hashDir string, newPartitionDir string) error {
+	newHashDir := filepath.Join(newPartitionDir, filepath.Base(filepath.Dir(hashDir)), filepath.Base(hashDir))
+	files, err := ioutil.ReadDir(hashDir)
+	if err != nil {
+		return err
+	}
+	linked := false
+	for _, file := range files {
+		dst := filepath.Join(newHashDir, file.Name())
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(newHashDir, 0755); err != nil {
+			return err
+		}
+		if err := os.Link(filepath.Join(hashDir, file.Name()), dst); err != nil && !os.IsExist(err) {
+			return err
+		}
+		linked = true
+	}
+	if linked {
+		return InvalidateHash(newHashDir)
+	}
+	return nil
+}
+
+// relinkDevice walks one policy's partitions on a device, linking every
+// object into its partition under the new partition power. With cleanup set
+// the old copies are removed afterwards, along with any partitions that no
+// longer hold anything. Its progress, in partitions, goes to progress.
+func relinkDevice(devicePath string, policy int, partPower uint, cleanup bool, progress *middleware.ProgressReporter) (int, error) {
+	objPath := filepath.Join(devicePath, PolicyDir(policy))
+	partitions, err := ioutil.ReadDir(objPath)
+	if err != nil {
+		return 0, err
+	}
+	statePath := relinkStatePath(devicePath, policy)
+	state := loadRelinkState(statePath, partPower, cleanup)
+	// The replicator leaves the device alone while the state file exists,
+	// so it is written before anything is relinked and only removed once
+	// cleanup has finished.
+	if err := state.save(statePath); err != nil {
+		return 0, err
+	}
+	var todo []os.FileInfo
+	for _, partition := range partitions {
+		if _, err := strconv.ParseUint(partition.Name(), 10, 64); err == nil && partition.IsDir() && !state.Done[partition.Name()] {
+			todo = append(todo, partition)
+		}
+	}
+	progress.StartCycle(int64(len(todo)))
+	lastProgress := time.Now()
+	relinked := 0
+	for _, partition := range todo {
+		partitionDir := filepath.Join(objPath, partition.Name())
+		suffixes, err := ioutil.ReadDir(partitionDir)
+		if err != nil {
+			return relinked, err
+		}
+		var invalidated []string
+		for _, suffix := range suffixes {
+			if len(suffix.Name()) != 3 || !suffix.IsDir() {
+				continue
+			}
+			suffixDir := filepath.Join(partitionDir, suffix.Name())
+			hashes, err := ioutil.ReadDir(suffixDir)
+			if err != nil {
+				return relinked, err
+			}
+			for _, hash := range hashes {
+				newPart, err := relinkPartition(hash.Name(), partPower)
+				if err != nil || strconv.FormatUint(newPart, 10) == partition.Name() {
+					continue
+				}
+				hashDir := filepath.Join(suffixDir, hash.Name())
+				if err := relinkHashDir(hashDir, filepath.Join(objPath, strconv.FormatUint(newPart, 10))); err != nil {
+					return relinked, fmt.Errorf("Unable to relink %s: %v", hashDir, err)
+				}
+				relinked++
+				if cleanup {
+					if err := os.RemoveAll(hashDir); err != nil {
+						return relinked, err
+					}
+					invalidated = append(invalidated, suffix.Name())
+				}
+			}
+		}
+		if cleanup {
+			for _, suffix := range invalidated {
+				os.Remove(filepath.Join(partitionDir, suffix))
+			}
+			if len(invalidated) > 0 {
+				if err := invalidateSuffixes(partitionDir, invalidated); err != nil {
+					return relinked, err
+				}
+			}
+			removeEmptyPartition(partitionDir)
+		}
+		state.Done[partition.Name()] = true
+		if err := state.save(statePath); err != nil {
+			return relinked, err
+		}
+		progress.Processed(1)
+		// The progress is only for recon, so failing to save it doesn't
+		// stop the relink.
+		if time.Since(lastProgress) > relinkProgressInterval {
+			lastProgress = time.Now()
+			progress.Report()
+		}
+	}
+	if cleanup {
+		if err := os.Remove(statePath); err != nil {
+			return relinked, err
+		}
+	}
+	progress.EndCycle()
+	return relinked, nil
+}
+
+// removeEmptyPartition removes partitionDir if all that's left in it is its
+// hashes files.
+func removeEmptyPartition(partitionDir string) {
+	files, err := ioutil.ReadDir(partitionDir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		if file.Name() != "hashes.pkl" && file.Name() != "hashes.invalid" && file.Name() != ".lock" {
+			return
+		}
+	}
+	os.RemoveAll(partitionDir)
+}
+
+func doRelink(args []string, cnf srv.ConfigLoader) int {
+	flags := flag.NewFlagSet("relinker", flag.ExitOnError)
+	policyName := flags.String("P", "", "policy to use")
+	devices := flags.String("devices", "/srv/node", "directory containing the devices to relink")
+	device := flags.String("device", "", "only relink this device")
+	cleanup := flags.Bool("cleanup", false, "remove objects from their old partitions once the new ring is in place")
+	reconCachePath := flags.String("recon_cache_path", "/var/cache/swift", "directory the progress is saved to for recon")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "USAGE: hummingbird relinker [-cleanup] [new partition power]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if len(flags.Args()) != 1 {
+		flags.Usage()
+		return 1
+	}
+	partPower, err := strconv.ParseUint(flags.Arg(0), 10, 8)
+	if err != nil || partPower < 1 || partPower > 32 {
+		fmt.Fprintf(os.Stderr, "Invalid partition power %q\n", flags.Arg(0))
+		return 1
+	}
+	policies, err := cnf.GetPolicies()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to load policies:", err)
+		return 1
+	}
+	policy := policies[0]
+	if *policyName != "" {
+		policy = policies.NameLookup(*policyName)
+	}
+	if policy == nil {
+		fmt.Fprintf(os.Stderr, "Unknown policy named %q\n", *policyName)
+		return 1
+	}
+	if policy.Type != "replication" && policy.Type != "replication-nursery" {
+		fmt.Fprintf(os.Stderr, "The relinker doesn't support %s policies\n", policy.Type)
+		return 1
+	}
+	deviceDirs, err := ioutil.ReadDir(*devices)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to list devices:", err)
+		return 1
+	}
+	ret := 0
+	for _, dev := range deviceDirs {
+		if *device != "" && dev.Name() != *device {
+			continue
+		}
+		devicePath := filepath.Join(*devices, dev.Name())
+		if _, err := os.Stat(filepath.Join(devicePath, PolicyDir(policy.Index))); err != nil {
+			continue
+		}
+		progress := middleware.NewProgressReporter(*reconCachePath, "object", "object-relinker-"+deviceKeyId(dev.Name(), policy.Index), nil)
+		relinked, err := relinkDevice(devicePath, policy.Index, uint(partPower), *cleanup, progress)
+		if err != nil {
+			fmt.Printf("Error relinking %s: %v\n", dev.Name(), err)
+			ret = 1
+			continue
+		}
+		fmt.Printf("Relinked %d objects on %s\n", relinked, dev.Name())
+	}
+	return ret
+}
+
+// Relink links objects on this server into their partitions under a new,
+// increased partition power, and with -cleanup removes them from their old
+// partitions once the new ring has been deployed.
+func Relink(args []string, cnf srv.ConfigLoader) {
+	os.Exit(doRelink(args, cnf))
+}
@@ -0,0 +1,68 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package objectserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common/fs"
+	"github.com/troubling/hummingbird/middleware"
+)
+
+func TestRelinkDevice(t *testing.T) {
+	driveRoot, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(driveRoot)
+	devicePath := filepath.Join(driveRoot, "sda")
+	oldHashDir := filepath.Join(devicePath, "objects", "1", "abc", "c0000000000000000000000000000abc")
+	newHashDir := filepath.Join(devicePath, "objects", "3", "abc", "c0000000000000000000000000000abc")
+	require.Nil(t, os.MkdirAll(oldHashDir, 0777))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(oldHashDir, "12345.data"), []byte("data"), 0666))
+
+	progress := middleware.NewProgressReporter(driveRoot, "object", "object-relinker-sda", nil)
+	relinked, err := relinkDevice(devicePath, 0, 2, false, progress)
+	require.Nil(t, err)
+	require.Equal(t, 1, relinked)
+	p := progress.Progress()
+	require.Equal(t, int64(1), p.Total)
+	require.Equal(t, int64(1), p.Processed)
+	require.True(t, p.LastCycleEnd > 0)
+	data, err := ioutil.ReadFile(filepath.Join(newHashDir, "12345.data"))
+	require.Nil(t, err)
+	require.Equal(t, "data", string(data))
+	require.True(t, fs.Exists(filepath.Join(oldHashDir, "12345.data")))
+	state := loadRelinkState(relinkStatePath(devicePath, 0), 2, false)
+	require.True(t, state.Done["1"])
+
+	// a resumed run skips partitions it already finished, leaving only the
+	// one the first run made, which has nothing to move
+	relinked, err = relinkDevice(devicePath, 0, 2, false, progress)
+	require.Nil(t, err)
+	require.Equal(t, 0, relinked)
+	require.Equal(t, int64(1), progress.Progress().Total)
+
+	relinked, err = relinkDevice(devicePath, 0, 2, true, progress)
+	require.Nil(t, err)
+	require.Equal(t, 1, relinked)
+	require.False(t, fs.Exists(filepath.Join(devicePath, "objects", "1")))
+	require.True(t, fs.Exists(filepath.Join(newHashDir, "12345.data")))
+	// finishing cleanup lets the replicator back onto the device
+	require.False(t, fs.Exists(relinkStatePath(devicePath, 0)))
+}
@@ -17,19 +17,25 @@ package objectserver
 
 import (
 	"bufio"
+	"bytes"
+	"compress/flate"
 	"crypto/tls"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/troubling/hummingbird/common"
 	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/srv"
 )
 
 var RepUnmountedError = fmt.Errorf("Device unmounted")
@@ -37,6 +43,72 @@ var repDialer = (&net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Seco
 
 const repConnBufferSize = 32768
 
+// repConnFeaturesHeader lists the protocol extensions a REPCONN client would
+// like to use, and in the response, the ones the server agreed to.  Peers that
+// don't know it speak the original protocol.
+const repConnFeaturesHeader = "X-Repconn-Features"
+
+// repCompressedFrame is set in a message's length prefix when the message is
+// flate compressed.  Only messages of at least repCompressMinSize are worth
+// compressing.
+const (
+	repCompressedFrame = 1 << 31
+	repCompressMinSize = 512
+)
+
+// repConnFeatures are the extensions to the replication protocol in use on a
+// connection:
+//
+// persistent - after a partition is done, the connection waits for the next
+// BeginReplicationRequest instead of closing.
+//
+// batch - SyncFileRequests may carry a Batch of files, answered with a
+// SyncBatchResponse.
+//
+// compress - large messages may be compressed.
+type repConnFeatures struct {
+	persistent bool
+	batch      bool
+	compress   bool
+}
+
+func parseRepConnFeatures(value string) repConnFeatures {
+	var f repConnFeatures
+	for _, feature := range strings.Split(value, ",") {
+		switch strings.TrimSpace(feature) {
+		case "persistent":
+			f.persistent = true
+		case "batch":
+			f.batch = true
+		case "compress":
+			f.compress = true
+		}
+	}
+	return f
+}
+
+func (f repConnFeatures) String() string {
+	var features []string
+	if f.persistent {
+		features = append(features, "persistent")
+	}
+	if f.batch {
+		features = append(features, "batch")
+	}
+	if f.compress {
+		features = append(features, "compress")
+	}
+	return strings.Join(features, ",")
+}
+
+func (f repConnFeatures) and(o repConnFeatures) repConnFeatures {
+	return repConnFeatures{
+		persistent: f.persistent && o.persistent,
+		batch:      f.batch && o.batch,
+		compress:   f.compress && o.compress,
+	}
+}
+
 type BeginReplicationRequest struct {
 	Device     string
 	Partition  string
@@ -54,13 +126,17 @@ type SyncFileRequest struct {
 	Check  bool
 	Ping   bool
 	Done   bool
+	Batch  []SyncFileRequest `json:",omitempty"`
 }
 
 type SyncFileResponse struct {
 	Exists      bool
 	NewerExists bool
 	GoAhead     bool
-	Msg         string
+	// Synced is set for files in a batch that were saved without needing
+	// any data, such as tombstones.
+	Synced bool `json:",omitempty"`
+	Msg    string
 }
 
 type FileUploadResponse struct {
@@ -68,6 +144,14 @@ type FileUploadResponse struct {
 	Msg     string
 }
 
+type SyncBatchResponse struct {
+	Files []SyncFileResponse
+}
+
+type FileUploadBatchResponse struct {
+	Files []FileUploadResponse
+}
+
 type RepConn interface {
 	SendMessage(v interface{}) error
 	RecvMessage(v interface{}) error
@@ -83,6 +167,15 @@ type repConn struct {
 	c            net.Conn
 	disconnected bool
 	rcTimeout    time.Duration
+	features     repConnFeatures
+}
+
+// repConnFeaturesOf returns the protocol extensions agreed on for rc.
+func repConnFeaturesOf(rc RepConn) repConnFeatures {
+	if c, ok := rc.(*repConn); ok {
+		return c.features
+	}
+	return repConnFeatures{}
 }
 
 func (r *repConn) iTimeout() time.Duration {
@@ -109,7 +202,14 @@ func (r *repConn) SendMessage(v interface{}) error {
 		r.Close()
 		return err
 	}
-	if err := binary.Write(r, binary.BigEndian, uint32(len(jsoned))); err != nil {
+	length := uint32(len(jsoned))
+	if r.features.compress && len(jsoned) >= repCompressMinSize {
+		if compressed, err := deflateMessage(jsoned); err == nil && len(compressed) < len(jsoned) {
+			jsoned = compressed
+			length = uint32(len(jsoned)) | repCompressedFrame
+		}
+	}
+	if err := binary.Write(r, binary.BigEndian, length); err != nil {
 		r.Close()
 		return err
 	}
@@ -131,11 +231,18 @@ func (r *repConn) RecvMessage(v interface{}) (err error) {
 		r.Close()
 		return
 	}
-	data := make([]byte, length)
+	compressed := length&repCompressedFrame != 0
+	data := make([]byte, length&^repCompressedFrame)
 	if _, err = io.ReadFull(r, data); err != nil {
 		r.Close()
 		return
 	}
+	if compressed {
+		if data, err = inflateMessage(data); err != nil {
+			r.Close()
+			return
+		}
+	}
 	if err = json.Unmarshal(data, v); err != nil {
 		r.Close()
 		return
@@ -172,18 +279,49 @@ func (r *repConn) Close() {
 	r.c.Close()
 }
 
-func NewRepConn(dev *ring.Device, partition string, policy int, headers map[string]string, certFile, keyFile string, rcTimeout time.Duration) (RepConn, error) {
-	url := fmt.Sprintf("%s://%s:%d/%s/%s", dev.Scheme, dev.ReplicationIp, dev.ReplicationPort, dev.Device, partition)
+func deflateMessage(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func inflateMessage(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// NewRepConn opens a REPCONN connection to dev, asking for the protocol
+// extensions in features; the connection uses those the server agrees to.
+// With a signingKey, the request opening it carries a backend signature.
+func NewRepConn(dev *ring.Device, partition string, policy int, headers map[string]string, certFile, keyFile, signingKey string, rcTimeout time.Duration, features repConnFeatures) (RepConn, error) {
+	url := fmt.Sprintf("%s://%s:%d%s/%s/%s", dev.Scheme, dev.ReplicationIp, dev.ReplicationPort, dev.PathPrefix, dev.Device, partition)
 	req, err := http.NewRequest("REPCONN", url, nil)
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set(common.PriorityHeader, common.PriorityReplication)
 	req.Header.Set("X-Backend-Suppress-2xx-Logging", "t")
 	// left policy as an arg instead of a header to make it harder to forget to set it.
 	req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(policy))
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
+	if wanted := features.String(); wanted != "" {
+		req.Header.Set(repConnFeaturesHeader, wanted)
+	}
+	if signingKey != "" {
+		srv.SignBackendRequest(req, signingKey, time.Now())
+	}
 	conn, err := repDialer("tcp", req.URL.Host)
 	if err != nil {
 		return nil, err
@@ -214,9 +352,89 @@ func NewRepConn(dev *ring.Device, partition string, policy int, headers map[stri
 			bufio.NewWriterSize(newc, repConnBufferSize)),
 		c:         newc,
 		rcTimeout: rcTimeout,
+		features:  features.and(parseRepConnFeatures(resp.Header.Get(repConnFeaturesHeader))),
 	}, nil
 }
 
-func NewIncomingRepConn(rw *bufio.ReadWriter, c net.Conn, rcTimeout time.Duration) RepConn {
-	return &repConn{rw: rw, c: c, rcTimeout: rcTimeout}
+func NewIncomingRepConn(rw *bufio.ReadWriter, c net.Conn, rcTimeout time.Duration, features repConnFeatures) RepConn {
+	return &repConn{rw: rw, c: c, rcTimeout: rcTimeout, features: features}
+}
+
+type idleRepConn struct {
+	conn  RepConn
+	since time.Time
+}
+
+// repConnPool keeps persistent connections between partitions, so the next
+// partition synced between the same pair of devices can skip connecting.
+type repConnPool struct {
+	lock        sync.Mutex
+	idle        map[string][]idleRepConn
+	maxIdle     int
+	idleTimeout time.Duration
+}
+
+func newRepConnPool(maxIdle int, idleTimeout time.Duration) *repConnPool {
+	return &repConnPool{idle: map[string][]idleRepConn{}, maxIdle: maxIdle, idleTimeout: idleTimeout}
+}
+
+// repConnIdleTimeout is how long a pooled connection may sit idle, well short
+// of how long the server waits on it for the next partition.
+func repConnIdleTimeout(rcTimeout time.Duration) time.Duration {
+	if rcTimeout > 0 && rcTimeout/2 < time.Minute {
+		return rcTimeout / 2
+	}
+	return time.Minute
+}
+
+func repConnKey(localDevice string, dev *ring.Device, policy int) string {
+	return fmt.Sprintf("%s/%s://%s:%d%s/%s/%d", localDevice, dev.Scheme, dev.ReplicationIp, dev.ReplicationPort, dev.PathPrefix, dev.Device, policy)
+}
+
+// reap closes connections that have been idle too long; the caller holds the
+// lock.
+func (p *repConnPool) reap() {
+	for key, conns := range p.idle {
+		var kept []idleRepConn
+		for _, ic := range conns {
+			if time.Since(ic.since) < p.idleTimeout && !ic.conn.Disconnected() {
+				kept = append(kept, ic)
+			} else {
+				ic.conn.Close()
+			}
+		}
+		if len(kept) == 0 {
+			delete(p.idle, key)
+		} else {
+			p.idle[key] = kept
+		}
+	}
+}
+
+// get returns an idle connection for key, or nil if there isn't one.
+func (p *repConnPool) get(key string) RepConn {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.reap()
+	conns := p.idle[key]
+	if len(conns) == 0 {
+		return nil
+	}
+	p.idle[key] = conns[:len(conns)-1]
+	return conns[len(conns)-1].conn
+}
+
+// put keeps conn for reuse, closing it if there are enough idle already.
+func (p *repConnPool) put(key string, conn RepConn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.reap()
+	if conn.Disconnected() {
+		return
+	}
+	if len(p.idle[key]) >= p.maxIdle {
+		conn.Close()
+		return
+	}
+	p.idle[key] = append(p.idle[key], idleRepConn{conn: conn, since: time.Now()})
 }
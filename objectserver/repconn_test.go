@@ -0,0 +1,112 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package objectserver
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/srv"
+)
+
+func TestRepConnFeatures(t *testing.T) {
+	f := parseRepConnFeatures("persistent, compress,unknown")
+	require.Equal(t, repConnFeatures{persistent: true, compress: true}, f)
+	require.Equal(t, "persistent,compress", f.String())
+	require.Equal(t, repConnFeatures{compress: true}, f.and(repConnFeatures{batch: true, compress: true}))
+	require.Equal(t, "", repConnFeatures{}.String())
+}
+
+func testRepConnPair(features repConnFeatures) (RepConn, RepConn) {
+	c1, c2 := net.Pipe()
+	return NewIncomingRepConn(bufio.NewReadWriter(bufio.NewReader(c1), bufio.NewWriter(c1)), c1, 0, features),
+		NewIncomingRepConn(bufio.NewReadWriter(bufio.NewReader(c2), bufio.NewWriter(c2)), c2, 0, features)
+}
+
+func TestRepConnCompressedMessages(t *testing.T) {
+	for _, features := range []repConnFeatures{{}, {compress: true}} {
+		sender, receiver := testRepConnPair(features)
+		batch := SyncFileRequest{}
+		for i := 0; i < 50; i++ {
+			batch.Batch = append(batch.Batch, SyncFileRequest{Path: "sda/objects/1/abc/00000000000000000000000000000abc/1.ts", Xattrs: strings.Repeat("80", 100)})
+		}
+		go func() {
+			sender.SendMessage(batch)
+			sender.SendMessage(SyncFileRequest{Done: true})
+		}()
+		var received SyncFileRequest
+		require.Nil(t, receiver.RecvMessage(&received))
+		require.Equal(t, batch, received)
+		require.Nil(t, receiver.RecvMessage(&received))
+		require.True(t, received.Done)
+		sender.Close()
+		receiver.Close()
+	}
+}
+
+func TestRepConnPool(t *testing.T) {
+	pool := newRepConnPool(1, time.Hour)
+	dev := &ring.Device{Scheme: "http", ReplicationIp: "127.0.0.1", ReplicationPort: 6500, Device: "sdb"}
+	key := repConnKey("sda", dev, 0)
+	require.Nil(t, pool.get(key))
+
+	a, b := testRepConnPair(repConnFeatures{persistent: true})
+	pool.put(key, a)
+	pool.put(key, b)
+	require.True(t, b.Disconnected())
+	require.Equal(t, a, pool.get(key))
+	require.Nil(t, pool.get(key))
+
+	pool.put(key, a)
+	pool.idleTimeout = 0
+	require.Nil(t, pool.get(key))
+	require.True(t, a.Disconnected())
+	require.Equal(t, 2*time.Second, repConnIdleTimeout(4*time.Second))
+	require.Equal(t, time.Minute, repConnIdleTimeout(0))
+}
+
+func TestNewRepConnSigned(t *testing.T) {
+	var sigErr error
+	var priority string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sigErr = srv.CheckBackendSignature(r, "secret", time.Minute, time.Now())
+		priority = r.Header.Get(common.PriorityHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	require.Nil(t, err)
+	host, port, err := net.SplitHostPort(u.Host)
+	require.Nil(t, err)
+	dev := &ring.Device{Scheme: "http", Device: "sda", ReplicationIp: host}
+	dev.ReplicationPort, err = strconv.Atoi(port)
+	require.Nil(t, err)
+	rc, err := NewRepConn(dev, "1", 0, map[string]string{}, "", "", "secret", time.Second, repConnFeatures{})
+	require.Nil(t, err)
+	rc.Close()
+	require.Nil(t, sigErr)
+	require.Equal(t, common.PriorityReplication, priority)
+}
@@ -21,6 +21,7 @@ import (
 	"io"
 	"net/http"
 	_ "net/http/pprof"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -29,6 +30,7 @@ import (
 	"github.com/troubling/hummingbird/client"
 	"github.com/troubling/hummingbird/common"
 	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/fs"
 	"github.com/troubling/hummingbird/common/ring"
 	"github.com/troubling/hummingbird/common/srv"
 	"github.com/troubling/hummingbird/common/tracing"
@@ -94,6 +96,33 @@ type DeviceStats struct {
 	totalPassesMetric      tally.Counter
 	priorityRepsDoneMetric tally.Counter
 	lastPassDurationMetric tally.Timer
+
+	// progress is the pass's progress for recon, for replication and
+	// stabilization devices.
+	progress *middleware.ProgressReporter
+}
+
+// trackProgress passes the pass stats in update on to the device's
+// ProgressReporter: partitions for the replicator, objects for the nursery
+// stabilizer.
+func (stats *DeviceStats) trackProgress(update statUpdate) error {
+	if stats.progress == nil {
+		return nil
+	}
+	switch update.stat {
+	case "startRun":
+		stats.progress.StartCycle(0)
+	case "PartitionsTotal":
+		stats.progress.SetTotal(update.value)
+	case "PartitionsDone", "ObjectsStabilizedSuccess":
+		stats.progress.Processed(update.value)
+	case "ObjectsStabilizedError":
+		stats.progress.Processed(update.value)
+		stats.progress.Failed(update.value)
+	case "FullReplicateCount", "PassComplete":
+		return stats.progress.EndCycle()
+	}
+	return nil
 }
 
 type statUpdate struct {
@@ -112,12 +141,16 @@ type Replicator struct {
 	bindIp              string
 	CertFile            string
 	KeyFile             string
+	signingKey          string
 	devices             map[string]bool
 	partitions          map[string]bool
 	quorumDelete        bool
 	reclaimAge          int64
-	reserve             int64
+	deviceFilter        ring.DeviceFilter
+	reserve             fs.Reserve
 	incomingLimitPerDev int64
+	incomingTagLimits   []incomingTagLimit
+	listingConcurrency  int
 	policies            conf.PolicyList
 	logLevel            zap.AtomicLevel
 	metricsScope        tally.Scope
@@ -127,25 +160,32 @@ type Replicator struct {
 	tracer              opentracing.Tracer
 	auditor             *AuditorDaemon
 
-	stats                   map[string]map[string]*DeviceStats
-	runningDevices          map[string]ReplicationDevice
-	updatingDevices         map[string]*updateDevice
-	runningDevicesLock      sync.Mutex
-	logger                  srv.LowLevelLogger
-	objectRings             map[int]ring.Ring
-	objEngines              map[int]ObjectEngine
-	containerRing           ring.Ring
-	replicateConcurrencySem chan struct{}
-	updateConcurrencySem    chan struct{}
-	nurseryConcurrencySem   chan struct{}
-	updateStat              chan statUpdate
-	onceDone                chan struct{}
-	onceWaiting             int64
-	client                  common.HTTPClient
-	incomingSemLock         sync.Mutex
-	incomingSem             map[string]chan struct{}
-	asyncWG                 sync.WaitGroup // Used to wait on async goroutines
-	rcTimeout               time.Duration
+	// repConnFeatures are the protocol extensions used with replication
+	// peers that support them.
+	repConnFeatures      repConnFeatures
+	syncBatchSize        int
+	syncBatchMaxFileSize int64
+
+	stats                 map[string]map[string]*DeviceStats
+	runningDevices        map[string]ReplicationDevice
+	updatingDevices       map[string]*updateDevice
+	runningDevicesLock    sync.Mutex
+	logger                srv.LowLevelLogger
+	objectRings           map[int]ring.Ring
+	objEngines            map[int]ObjectEngine
+	containerRing         ring.Ring
+	throttle              *replicationThrottle
+	repConns              *repConnPool
+	updateConcurrencySem  chan struct{}
+	nurseryConcurrencySem chan struct{}
+	updateStat            chan statUpdate
+	onceDone              chan struct{}
+	onceWaiting           int64
+	client                common.HTTPClient
+	incomingSemLock       sync.Mutex
+	incomingSem           map[string]chan struct{}
+	asyncWG               sync.WaitGroup // Used to wait on async goroutines
+	rcTimeout             time.Duration
 }
 
 func (server *Replicator) Type() string {
@@ -211,15 +251,22 @@ func (r *Replicator) cancelStalledDevices() {
 	}
 }
 
+// deviceMetricsScope returns a scope whose metrics carry policy and device
+// labels, so the same metric name is shared by every local device.
+func (r *Replicator) deviceMetricsScope(policy int, device string) tally.Scope {
+	return r.metricsScope.Tagged(map[string]string{"policy": strconv.Itoa(policy), "device": device})
+}
+
 func (r *Replicator) addMetrics(devStats *DeviceStats, policy int, name string) {
-	devStats.cancelsMetric = r.metricsScope.Counter(fmt.Sprintf("%d_%s_cancels", policy, name))
-	devStats.filesSentMetric = r.metricsScope.Counter(fmt.Sprintf("%d_%s_files_sent", policy, name))
-	devStats.bytesSentMetric = r.metricsScope.Counter(fmt.Sprintf("%d_%s_bytes_sent", policy, name))
-	devStats.partitionsDoneMetric = r.metricsScope.Counter(fmt.Sprintf("%d_%s_partitions_done", policy, name))
-	devStats.partitionsTotalMetric = r.metricsScope.Counter(fmt.Sprintf("%d_%s_partitions_total", policy, name))
-	devStats.totalPassesMetric = r.metricsScope.Counter(fmt.Sprintf("%d_%s_total_passes", policy, name))
-	devStats.priorityRepsDoneMetric = r.metricsScope.Counter(fmt.Sprintf("%d_%s_priority_reps_done", policy, name))
-	devStats.lastPassDurationMetric = r.metricsScope.Timer(fmt.Sprintf("%d_%s_last_pass_duration", policy, name))
+	scope := r.deviceMetricsScope(policy, name)
+	devStats.cancelsMetric = scope.Counter("cancels")
+	devStats.filesSentMetric = scope.Counter("files_sent")
+	devStats.bytesSentMetric = scope.Counter("bytes_sent")
+	devStats.partitionsDoneMetric = scope.Counter("partitions_done")
+	devStats.partitionsTotalMetric = scope.Counter("partitions_total")
+	devStats.totalPassesMetric = scope.Counter("total_passes")
+	devStats.priorityRepsDoneMetric = scope.Counter("priority_reps_done")
+	devStats.lastPassDurationMetric = scope.Timer("last_pass_duration")
 }
 
 func (r *Replicator) verifyRunningDevices() {
@@ -252,6 +299,7 @@ func (r *Replicator) verifyRunningDevices() {
 						Stats: map[string]int64{},
 					}
 					r.addMetrics(r.stats[rd.Type()][key], policy, dev.Device)
+					r.stats[rd.Type()][key].progress = middleware.NewProgressReporter(r.reconCachePath, "object", rd.Type()+"-"+key, r.metricsScope)
 					go r.runningDevices[key].ScanLoop()
 				} else {
 					r.logger.Error("building replication device", zap.String("device", key), zap.Int("policy", policy), zap.Error(err))
@@ -286,6 +334,13 @@ func (r *Replicator) verifyRunningDevices() {
 func (r *Replicator) reportStats() {
 	r.runningDevicesLock.Lock()
 	defer r.runningDevicesLock.Unlock()
+	for key, rd := range r.runningDevices {
+		if stats, ok := r.stats[rd.Type()][key]; ok && stats.progress != nil {
+			if err := stats.progress.Report(); err != nil {
+				r.logger.Error("saving progress", zap.String("service", rd.Type()), zap.String("device", key), zap.Error(err))
+			}
+		}
+	}
 	minLastPass := time.Now()
 	allHaveCompleted := true
 	for key := range r.runningDevices {
@@ -409,6 +464,9 @@ func (r *Replicator) runLoopCheck(reportTimer <-chan time.Time) {
 		default:
 			stats.Stats[update.stat] += update.value
 		}
+		if err := stats.trackProgress(update); err != nil {
+			r.logger.Error("saving progress", zap.String("service", update.service), zap.String("device", update.deviceKey), zap.Error(err))
+		}
 	case <-reportTimer:
 		r.cancelStalledDevices()
 		r.verifyRunningDevices()
@@ -485,12 +543,23 @@ func NewReplicator(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLo
 	concurrency := int(serverconf.GetInt("object-replicator", "concurrency", 1))
 	updaterConcurrency := int(serverconf.GetInt("object-updater", "concurrency", 2))
 	nurseryConcurrency := int(serverconf.GetInt("object-nursery", "concurrency", 2))
+	rcTimeout := time.Duration(serverconf.GetInt("object-replicator", "replication_timeout_sec", 0)) * time.Second
+	syncBatchSize := int(serverconf.GetInt("object-replicator", "sync_batch_size", 64))
+	// These limits can be changed while running with PUTs to /throttle.
+	throttle := newReplicationThrottle(
+		serverconf.GetFloat("object-replicator", "mb_per_second", 0),
+		serverconf.GetFloat("object-replicator", "device_mb_per_second", 0),
+		concurrency,
+		int(serverconf.GetInt("object-replicator", "device_concurrency", 0)))
 
 	logLevelString := serverconf.GetDefault("object-replicator", "log_level", "INFO")
 	logLevel := zap.NewAtomicLevel()
 	logLevel.UnmarshalText([]byte(strings.ToLower(logLevelString)))
 	certFile := serverconf.GetDefault("object-replicator", "cert_file", "")
 	keyFile := serverconf.GetDefault("object-replicator", "key_file", "")
+	// REPCONN requests are sent on their own connections rather than through
+	// httpClient, so they're signed with the key directly.
+	signingKey, _, _ := conf.GetBackendSigning()
 	transport := &http.Transport{
 		MaxIdleConnsPerHost: 100,
 		MaxIdleConns:        0,
@@ -507,10 +576,24 @@ func NewReplicator(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLo
 	}
 	httpClient := &http.Client{
 		Timeout:   time.Second * 60,
-		Transport: transport,
+		Transport: srv.NewSigningTransport(transport),
+	}
+	reserve, err := fs.ParseReserve(serverconf.GetDefault("object-replicator", "fallocate_reserve", "0"))
+	if err != nil {
+		return ipPort, nil, nil, fmt.Errorf("Invalid fallocate_reserve: %v", err)
+	}
+	// device_filters names registered ring.DeviceFilters; handoffs they
+	// reject aren't replicated to.
+	deviceFilter, err := ring.GetDeviceFilter(serverconf.GetDefault("object-replicator", "device_filters", ""))
+	if err != nil {
+		return ipPort, nil, nil, err
+	}
+	incomingTagLimits, err := parseIncomingTagLimits(serverconf.GetDefault("object-replicator", "incoming_limit_tags", ""))
+	if err != nil {
+		return ipPort, nil, nil, err
 	}
 	replicator := &Replicator{
-		reserve:             serverconf.GetInt("object-replicator", "fallocate_reserve", 0),
+		reserve:             reserve,
 		reconCachePath:      serverconf.GetDefault("object-replicator", "recon_cache_path", "/var/cache/swift"),
 		checkMounts:         serverconf.GetBool("object-replicator", "mount_check", true),
 		deviceRoot:          serverconf.GetDefault("object-replicator", "devices", "/srv/node"),
@@ -518,23 +601,36 @@ func NewReplicator(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLo
 		bindIp:              serverconf.GetDefault("object-replicator", "bind_ip", "0.0.0.0"),
 		CertFile:            certFile,
 		KeyFile:             keyFile,
+		signingKey:          signingKey,
 		quorumDelete:        serverconf.GetBool("object-replicator", "quorum_delete", false),
-		reclaimAge:          int64(serverconf.GetInt("object-replicator", "reclaim_age", int64(common.ONE_WEEK))),
+		reclaimAge:          objectReclaimAge(serverconf),
 		incomingLimitPerDev: int64(serverconf.GetInt("object-replicator", "incoming_limit", 3)),
-
-		runningDevices:          make(map[string]ReplicationDevice),
-		updatingDevices:         make(map[string]*updateDevice),
-		objectRings:             make(map[int]ring.Ring),
-		replicateConcurrencySem: make(chan struct{}, concurrency),
-		updateConcurrencySem:    make(chan struct{}, updaterConcurrency),
-		nurseryConcurrencySem:   make(chan struct{}, nurseryConcurrency),
-		rcTimeout:               time.Duration(serverconf.GetInt("object-replicator", "replication_timeout_sec", 0)) * time.Second,
-		updateStat:              make(chan statUpdate),
-		devices:                 make(map[string]bool),
-		partitions:              make(map[string]bool),
-		onceDone:                make(chan struct{}),
-		client:                  httpClient,
-		incomingSem:             make(map[string]chan struct{}),
+		incomingTagLimits:   incomingTagLimits,
+		listingConcurrency:  int(serverconf.GetInt("object-replicator", "listing_concurrency", 1)),
+		deviceFilter:        deviceFilter,
+
+		repConnFeatures: repConnFeatures{
+			persistent: serverconf.GetBool("object-replicator", "persistent_connections", true),
+			batch:      syncBatchSize > 1,
+			compress:   serverconf.GetBool("object-replicator", "compress_messages", true),
+		},
+		syncBatchSize:        syncBatchSize,
+		syncBatchMaxFileSize: serverconf.GetInt("object-replicator", "sync_batch_max_file_size", 65536),
+
+		runningDevices:        make(map[string]ReplicationDevice),
+		updatingDevices:       make(map[string]*updateDevice),
+		objectRings:           make(map[int]ring.Ring),
+		throttle:              throttle,
+		repConns:              newRepConnPool(2, repConnIdleTimeout(rcTimeout)),
+		updateConcurrencySem:  make(chan struct{}, updaterConcurrency),
+		nurseryConcurrencySem: make(chan struct{}, nurseryConcurrency),
+		rcTimeout:             rcTimeout,
+		updateStat:            make(chan statUpdate),
+		devices:               make(map[string]bool),
+		partitions:            make(map[string]bool),
+		onceDone:              make(chan struct{}),
+		client:                httpClient,
+		incomingSem:           make(map[string]chan struct{}),
 		stats: map[string]map[string]*DeviceStats{
 			"object-replicator": {},
 			"object-updater":    {},
@@ -28,6 +28,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"sync/atomic"
 	"testing"
@@ -42,6 +43,7 @@ import (
 	"github.com/troubling/hummingbird/common/ring"
 	"github.com/troubling/hummingbird/common/srv"
 	"github.com/troubling/hummingbird/common/test"
+	"github.com/troubling/hummingbird/middleware"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
@@ -65,7 +67,6 @@ func newTestReplicatorWithFlags(confLoader *srv.TestConfigLoader, settings []str
 	}
 	rep := replicator.(*Replicator)
 	rep.GetHandler(conf, fmt.Sprintf("test_object_replicator_%d", atomic.AddUint64(&testObjectReplicators, 1)))
-	rep.replicateConcurrencySem = make(chan struct{}, 1)
 	rep.updateConcurrencySem = make(chan struct{}, 1)
 	rep.updateStat = make(chan statUpdate, 100)
 	return rep, conf, nil
@@ -447,6 +448,46 @@ func TestListObjFiles(t *testing.T) {
 	require.True(t, fs.Exists(filepath.Join(dir, "objects")))
 }
 
+func TestListObjFilesConcurrency(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	repl, _, err := newTestReplicator(confLoader, "listing_concurrency", "4")
+	require.Nil(t, err)
+	require.Equal(t, 4, repl.listingConcurrency)
+	rd := &swiftDevice{
+		r:      repl,
+		dev:    &ring.Device{},
+		policy: 0,
+		cancel: make(chan struct{}),
+	}
+	rd.i = rd
+	dir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	var expected []string
+	for _, suffix := range []string{"abc", "def", "012", "345", "678", "9ab"} {
+		hashDir := filepath.Join(dir, "objects", "1", suffix, "d41d8cd98f00b204e9800998ecf8"+suffix+"e")
+		require.Nil(t, os.MkdirAll(hashDir, 0777))
+		for _, name := range []string{"12345.data", "12346.meta"} {
+			require.Nil(t, ioutil.WriteFile(filepath.Join(hashDir, name), nil, 0666))
+			if suffix != "9ab" {
+				expected = append(expected, filepath.Join(hashDir, name))
+			}
+		}
+		require.Nil(t, ioutil.WriteFile(filepath.Join(hashDir, "junk"), nil, 0666))
+	}
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "objects", "1", "hashes.pkl"), nil, 0666))
+	objChan := make(chan string)
+	var files []string
+	go rd.listObjFiles(objChan, make(chan struct{}), filepath.Join(dir, "objects", "1"), func(suffix string) bool { return suffix != "9ab" })
+	for obj := range objChan {
+		files = append(files, obj)
+	}
+	sort.Strings(files)
+	sort.Strings(expected)
+	require.Equal(t, expected, files)
+}
+
 func TestCancelListObjFiles(t *testing.T) {
 	testRing := &test.FakeRing{}
 	confLoader := srv.NewTestConfigLoader(testRing)
@@ -739,6 +780,34 @@ func TestReplicate(t *testing.T) {
 	require.Equal(t, []string{"1", "2", "2", "3"}, calledWith)
 }
 
+func TestReplicateSkipsRelinkingDevice(t *testing.T) {
+	deviceRoot, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(deviceRoot)
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	replicator, _, err := newTestReplicator(confLoader, "bind_port", "1234", "check_mounts", "no", "devices", deviceRoot)
+	require.Nil(t, err)
+	rd := newPatchableReplicationDevice(testRing, replicator)
+	rd.dev.Device = "sda"
+	rd._listPartitions = func() ([]string, []string, error) {
+		return []string{"1", "2", "3"}, nil, nil
+	}
+	calledWith := []string{}
+	rd._replicatePartition = func(partition string) {
+		calledWith = append(calledWith, partition)
+		if partition == "1" {
+			// the relinker starts partway through a pass
+			require.Nil(t, (&relinkState{Done: map[string]bool{}}).save(relinkStatePath(filepath.Join(deviceRoot, "sda"), 0)))
+		}
+	}
+	require.Nil(t, os.MkdirAll(filepath.Join(deviceRoot, "sda"), 0777))
+	rd.Scan()
+	require.Equal(t, []string{"1"}, calledWith)
+	rd.Scan()
+	require.Equal(t, []string{"1"}, calledWith)
+}
+
 func TestCancelReplicate(t *testing.T) {
 	testRing := &test.FakeRing{}
 	confLoader := srv.NewTestConfigLoader(testRing)
@@ -914,6 +983,28 @@ func TestCancelStalledDevices(t *testing.T) {
 	require.True(t, mockDevices[2].running)
 }
 
+func TestIncomingTagLimits(t *testing.T) {
+	testRing := &test.FakeRing{MockLocalDevices: []*ring.Device{
+		{Device: "sda", Tags: map[string]string{"disk": "ssd"}},
+		{Device: "sdb", Tags: map[string]string{"disk": "hdd"}},
+		{Device: "sdc"},
+	}}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	replicator, _, err := newTestReplicator(confLoader, "incoming_limit", "3", "incoming_limit_tags", "disk:ssd=8, disk:hdd=1")
+	require.Nil(t, err)
+	require.Equal(t, int64(8), replicator.deviceIncomingLimit("sda"))
+	require.Equal(t, int64(1), replicator.deviceIncomingLimit("sdb"))
+	require.Equal(t, int64(3), replicator.deviceIncomingLimit("sdc"))
+	require.True(t, replicator.incomingBegin("sdb", time.Millisecond))
+	require.False(t, replicator.incomingBegin("sdb", time.Millisecond))
+	replicator.incomingDone("sdb")
+
+	_, _, err = newTestReplicator(confLoader, "incoming_limit_tags", "ssd=8")
+	require.NotNil(t, err)
+	_, _, err = newTestReplicator(confLoader, "incoming_limit_tags", "disk:ssd=0")
+	require.NotNil(t, err)
+}
+
 func TestVerifyDevices(t *testing.T) {
 	testRing := &test.FakeRing{MockLocalDevices: []*ring.Device{{Device: "sda"}}}
 	confLoader := srv.NewTestConfigLoader(testRing)
@@ -1287,6 +1378,62 @@ func TestReplicationHandoffQuorumDelete(t *testing.T) {
 	require.Equal(t, 200, resp.StatusCode)
 }
 
+func TestReplicationBatchedTombstone(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	ts, err := makeObjectServer(confLoader)
+	require.Nil(t, err)
+	defer ts.Close()
+	ts2, err := makeObjectServer(confLoader)
+	require.Nil(t, err)
+	defer ts2.Close()
+
+	putTimestamp := common.GetTimestamp()
+	for _, server := range []*TestServer{ts, ts2} {
+		req, err := http.NewRequest("PUT", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", server.host, server.port),
+			bytes.NewBuffer([]byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ")))
+		require.Nil(t, err)
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("Content-Length", "26")
+		req.Header.Set("X-Timestamp", putTimestamp)
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		require.Equal(t, 201, resp.StatusCode)
+	}
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts.host, ts.port), nil)
+	require.Nil(t, err)
+	req.Header.Set("X-Timestamp", common.GetTimestamp())
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	require.Equal(t, 204, resp.StatusCode)
+
+	trs1, err := makeReplicatorWebServer(confLoader)
+	require.Nil(t, err)
+	defer trs1.Close()
+	trs1.replicator.deviceRoot = ts.objServer.driveRoot
+	trs2, err := makeReplicatorWebServer(confLoader)
+	require.Nil(t, err)
+	defer trs2.Close()
+	trs2.replicator.deviceRoot = ts2.objServer.driveRoot
+	ldev := &ring.Device{ReplicationIp: trs1.host, ReplicationPort: trs1.port, Device: "sda", Scheme: "http"}
+	rdev := &ring.Device{ReplicationIp: trs2.host, ReplicationPort: trs2.port, Device: "sda", Scheme: "http"}
+	testRing.MockLocalDevices = []*ring.Device{ldev}
+	testRing.MockGetJobNodes = []*ring.Device{rdev}
+
+	trs1.replicator.Run()
+
+	req, err = http.NewRequest("HEAD", fmt.Sprintf("http://%s:%d/sda/0/a/c/o", ts2.host, ts2.port), nil)
+	require.Nil(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	require.Equal(t, 404, resp.StatusCode)
+	// The connection is kept for the next partition.
+	conn := trs1.replicator.repConns.get(repConnKey("sda", rdev, 0))
+	require.NotNil(t, conn)
+	require.Equal(t, repConnFeatures{persistent: true, batch: true, compress: true}, repConnFeaturesOf(conn))
+	conn.Close()
+}
+
 func TestAllDifferentRegionsSync(t *testing.T) {
 	// syncing a file in non handoff partition to 3 devs in separate remote regions
 	deviceRoot, err := ioutil.TempDir("", "")
@@ -1561,3 +1708,38 @@ func TestHalfSameRegionsSyncHandoffYesExists(t *testing.T) {
 	require.Equal(t, 3, insync)
 	require.Equal(t, 18, dataReceived)
 }
+
+func TestDeviceStatsTrackProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	stats := &DeviceStats{progress: middleware.NewProgressReporter(dir, "object", "object-replicator-sda", nil)}
+	for _, update := range []statUpdate{
+		{"object-replicator", "sda", "startRun", 1},
+		{"object-replicator", "sda", "PartitionsTotal", 4},
+		{"object-replicator", "sda", "PartitionsDone", 1},
+		{"object-replicator", "sda", "FilesSent", 3},
+		{"object-replicator", "sda", "PartitionsDone", 1},
+	} {
+		require.Nil(t, stats.trackProgress(update))
+	}
+	p := stats.progress.Progress()
+	require.Equal(t, int64(4), p.Total)
+	require.Equal(t, int64(2), p.Processed)
+	require.Equal(t, float64(0), p.LastCycleEnd)
+	require.Nil(t, stats.trackProgress(statUpdate{"object-replicator", "sda", "FullReplicateCount", 1}))
+	require.True(t, stats.progress.Progress().LastCycleEnd > 0)
+
+	// The nursery stabilizer counts objects.
+	stats.trackProgress(statUpdate{"object-nursery", "sda", "startRun", 1})
+	stats.trackProgress(statUpdate{"object-nursery", "sda", "ObjectsStabilizedSuccess", 1})
+	stats.trackProgress(statUpdate{"object-nursery", "sda", "ObjectsStabilizedBytes", 100})
+	stats.trackProgress(statUpdate{"object-nursery", "sda", "ObjectsStabilizedError", 1})
+	p = stats.progress.Progress()
+	require.Equal(t, int64(0), p.Total)
+	require.Equal(t, int64(2), p.Processed)
+	require.Equal(t, int64(1), p.Failures)
+
+	// Devices without a reporter, like the updater's, are left alone.
+	require.Nil(t, (&DeviceStats{}).trackProgress(statUpdate{"object-updater", "sda", "startRun", 1}))
+}
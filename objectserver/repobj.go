@@ -21,7 +21,7 @@ var _ Object = &repObject{}
 
 type repObject struct {
 	IndexDBItem
-	reserve          int64
+	reserve          fs.Reserve
 	asyncWG          *sync.WaitGroup
 	idb              *IndexDB
 	ring             ring.Ring
@@ -31,6 +31,7 @@ type repObject struct {
 	metadata         map[string]string
 	client           *http.Client
 	txnId            string
+	readahead        int64
 }
 
 func (ro *repObject) Metadata() map[string]string {
@@ -86,6 +87,7 @@ func (ro *repObject) CopyRange(w io.Writer, start int64, end int64) (int64, erro
 		f.Close()
 		return 0, err
 	}
+	adviseRangeRead(f, start, end, ro.readahead)
 	written, err := common.CopyN(f, end-start, w)
 	if err == nil {
 		err = f.Close()
@@ -105,6 +107,9 @@ func (ro *repObject) SetData(size int64) (io.Writer, error) {
 	}
 	var err error
 	ro.atomicFileWriter, err = ro.idb.TempFile(ro.Hash, roShard, math.MaxInt64, size, true)
+	if err == fs.ErrNotEnoughReserve {
+		return nil, DriveFullError
+	}
 	return ro.atomicFileWriter, err
 }
 
@@ -160,8 +165,9 @@ func (ro *repObject) isStable(dev *ring.Device) (bool, []*ring.Device, error) {
 			goodNodes++
 			continue
 		}
-		url := fmt.Sprintf("%s://%s:%d/%s/%d%s", node.Scheme, node.Ip, node.Port, node.Device, partition, common.Urlencode(ro.metadata["name"]))
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d%s", node.Scheme, node.Ip, node.Port, node.PathPrefix, node.Device, partition, common.Urlencode(ro.metadata["name"]))
 		req, err := http.NewRequest("HEAD", url, nil)
+		req.Header.Set(common.PriorityHeader, common.PriorityReplication)
 		req.Header.Set("X-Backend-Storage-Policy-Index", strconv.FormatInt(int64(ro.policy), 10))
 		req.Header.Set("User-Agent", "nursery-stabilizer")
 		resp, err := ro.client.Do(req)
@@ -192,10 +198,11 @@ func (ro *repObject) stabilizeDelete(dev *ring.Device) error {
 		if node.Ip == dev.Ip && node.Port == dev.Port && node.Device == dev.Device {
 			continue
 		}
-		req, err := http.NewRequest("DELETE", fmt.Sprintf("%s://%s:%d/rep-obj/%s/%s", node.Scheme, node.ReplicationIp, node.ReplicationPort, node.Device, ro.Hash), nil)
+		req, err := http.NewRequest("DELETE", fmt.Sprintf("%s://%s:%d%s/rep-obj/%s/%s", node.Scheme, node.ReplicationIp, node.ReplicationPort, node.PathPrefix, node.Device, ro.Hash), nil)
 		if err != nil {
 			return err
 		}
+		req.Header.Set(common.PriorityHeader, common.PriorityReplication)
 		req.Header.Set("X-Timestamp", ro.metadata["X-Timestamp"])
 		req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(ro.policy))
 		req.Header.Set("X-Trans-Id", ro.txnId)
@@ -230,10 +237,11 @@ func (ro *repObject) restabilize(dev *ring.Device) error {
 		if node.Ip == dev.Ip && node.Port == dev.Port && node.Device == dev.Device {
 			continue
 		}
-		req, err := http.NewRequest("POST", fmt.Sprintf("%s://%s:%d/rep-obj/%s/%s", node.Scheme, node.ReplicationIp, node.ReplicationPort, node.Device, ro.Hash), nil)
+		req, err := http.NewRequest("POST", fmt.Sprintf("%s://%s:%d%s/rep-obj/%s/%s", node.Scheme, node.ReplicationIp, node.ReplicationPort, node.PathPrefix, node.Device, ro.Hash), nil)
 		if err != nil {
 			return err
 		}
+		req.Header.Set(common.PriorityHeader, common.PriorityReplication)
 		req.Header.Set("X-Timestamp", ro.metadata["X-Timestamp"])
 		req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(ro.policy))
 		req.Header.Set("X-Trans-Id", ro.txnId)
@@ -308,13 +316,14 @@ func (ro *repObject) Replicate(prirep PriorityRepJob) error {
 	}
 	defer fp.Close()
 	req, err := http.NewRequest("PUT",
-		fmt.Sprintf("%s://%s:%d/rep-obj/%s/%s",
-			prirep.ToDevice.Scheme, prirep.ToDevice.Ip, prirep.ToDevice.Port,
+		fmt.Sprintf("%s://%s:%d%s/rep-obj/%s/%s",
+			prirep.ToDevice.Scheme, prirep.ToDevice.Ip, prirep.ToDevice.Port, prirep.ToDevice.PathPrefix,
 			prirep.ToDevice.Device, ro.Hash), fp)
 	if err != nil {
 		return err
 	}
 	req.ContentLength = ro.ContentLength()
+	req.Header.Set(common.PriorityHeader, common.PriorityReplication)
 	req.Header.Set("X-Timestamp", ro.metadata["X-Timestamp"])
 	req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(ro.policy))
 	req.Header.Set("X-Trans-Id", ro.txnId)
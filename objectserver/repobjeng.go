@@ -21,6 +21,7 @@ import (
 
 	"github.com/troubling/hummingbird/common"
 	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/fs"
 	"github.com/troubling/hummingbird/common/ring"
 	"github.com/troubling/hummingbird/common/srv"
 )
@@ -53,6 +54,10 @@ func repEngineConstructor(config conf.Config, policy *conf.Policy, flags *flag.F
 	if err != nil {
 		return nil, err
 	}
+	reserve, err := fs.ParseReserve(config.GetDefault("app:object-server", "fallocate_reserve", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid fallocate_reserve: %v", err)
+	}
 	logLevelString := config.GetDefault("app:object-server", "log_level", "INFO")
 	logLevel := zap.NewAtomicLevel()
 	logLevel.UnmarshalText([]byte(strings.ToLower(logLevelString)))
@@ -83,7 +88,7 @@ func repEngineConstructor(config conf.Config, policy *conf.Policy, flags *flag.F
 		driveRoot:      driveRoot,
 		hashPathPrefix: hashPathPrefix,
 		hashPathSuffix: hashPathSuffix,
-		reserve:        config.GetInt("app:object-server", "fallocate_reserve", 0),
+		reserve:        reserve,
 		policy:         policy.Index,
 		ring:           rng,
 		idbs:           map[string]*IndexDB{},
@@ -91,8 +96,9 @@ func repEngineConstructor(config conf.Config, policy *conf.Policy, flags *flag.F
 		numSubDirs:     subdirs,
 		client: &http.Client{
 			Timeout:   120 * time.Minute,
-			Transport: transport,
+			Transport: srv.NewSigningTransport(transport),
 		},
+		readaheadThreshold: config.GetInt("app:object-server", "range_readahead_threshold", 0),
 	}
 	if re.logger, err = srv.SetupLogger("repobjengine", &logLevel, flags); err != nil {
 		return nil, fmt.Errorf("Error setting up logger: %v", err)
@@ -106,7 +112,7 @@ type repEngine struct {
 	driveRoot      string
 	hashPathPrefix string
 	hashPathSuffix string
-	reserve        int64
+	reserve        fs.Reserve
 	policy         int
 	ring           ring.Ring
 	logger         srv.LowLevelLogger
@@ -115,6 +121,8 @@ type repEngine struct {
 	dbPartPower    int
 	numSubDirs     int
 	client         *http.Client
+	// readaheadThreshold is as for SwiftEngine.
+	readaheadThreshold int64
 }
 
 func (re *repEngine) getDB(device string) (*IndexDB, error) {
@@ -142,13 +150,14 @@ func (re *repEngine) New(vars map[string]string, needData bool, asyncWG *sync.Wa
 		IndexDBItem: IndexDBItem{
 			Hash: hash,
 		},
-		ring:     re.ring,
-		policy:   re.policy,
-		reserve:  re.reserve,
-		metadata: map[string]string{},
-		asyncWG:  asyncWG,
-		client:   re.client,
-		txnId:    vars["txnId"],
+		ring:      re.ring,
+		policy:    re.policy,
+		reserve:   re.reserve,
+		metadata:  map[string]string{},
+		asyncWG:   asyncWG,
+		client:    re.client,
+		txnId:     vars["txnId"],
+		readahead: re.readaheadThreshold,
 	}
 	if idb, err := re.getDB(vars["device"]); err == nil {
 		obj.idb = idb
@@ -194,8 +203,9 @@ func (re *repEngine) GetObjectsToReplicate(prirep PriorityRepJob, c chan ObjectS
 	if len(items) == 0 {
 		return
 	}
-	url := fmt.Sprintf("%s://%s:%d/rep-partition/%s/%d", prirep.ToDevice.Scheme, prirep.ToDevice.Ip, prirep.ToDevice.Port, prirep.ToDevice.Device, prirep.Partition)
+	url := fmt.Sprintf("%s://%s:%d%s/rep-partition/%s/%d", prirep.ToDevice.Scheme, prirep.ToDevice.Ip, prirep.ToDevice.Port, prirep.ToDevice.PathPrefix, prirep.ToDevice.Device, prirep.Partition)
 	req, err := http.NewRequest("GET", url, nil)
+	req.Header.Set(common.PriorityHeader, common.PriorityReplication)
 	req.Header.Set("X-Backend-Storage-Policy-Index", strconv.Itoa(prirep.Policy))
 	req.Header.Set("User-Agent", "nursery-stabilizer")
 	resp, err := re.client.Do(req)
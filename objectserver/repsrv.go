@@ -19,6 +19,7 @@ import (
 	"bufio"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -41,11 +42,68 @@ import (
 	"go.uber.org/zap"
 )
 
+// incomingTagLimit is an incoming_limit_tags entry, like disk:ssd=8, limiting
+// concurrent incoming replication to devices with that tag.
+type incomingTagLimit struct {
+	key   string
+	value string
+	limit int64
+}
+
+func parseIncomingTagLimits(s string) ([]incomingTagLimit, error) {
+	var limits []incomingTagLimit
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("Invalid incoming_limit_tags entry %q", item)
+		}
+		key, value, ok := ring.ParseTagSelector(strings.TrimSpace(item[:i]))
+		if !ok {
+			return nil, fmt.Errorf("Invalid incoming_limit_tags entry %q", item)
+		}
+		limit, err := strconv.ParseInt(strings.TrimSpace(item[i+1:]), 10, 64)
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("Invalid incoming_limit_tags entry %q", item)
+		}
+		limits = append(limits, incomingTagLimit{key: key, value: value, limit: limit})
+	}
+	return limits, nil
+}
+
+// deviceIncomingLimit returns the incoming limit for the first
+// incoming_limit_tags entry the local device matches, or incoming_limit.
+func (r *Replicator) deviceIncomingLimit(device string) int64 {
+	if len(r.incomingTagLimits) == 0 {
+		return r.incomingLimitPerDev
+	}
+	for _, oring := range r.objectRings {
+		devs, err := oring.LocalDevices(r.port)
+		if err != nil {
+			continue
+		}
+		for _, dev := range devs {
+			if dev.Device != device {
+				continue
+			}
+			for _, tl := range r.incomingTagLimits {
+				if dev.HasTag(tl.key, tl.value) {
+					return tl.limit
+				}
+			}
+		}
+	}
+	return r.incomingLimitPerDev
+}
+
 func (r *Replicator) incomingBegin(device string, timeout time.Duration) bool {
 	r.incomingSemLock.Lock()
 	devSem, ok := r.incomingSem[device]
 	if !ok {
-		devSem = make(chan struct{}, r.incomingLimitPerDev)
+		devSem = make(chan struct{}, r.deviceIncomingLimit(device))
 		r.incomingSem[device] = devSem
 	}
 	r.incomingSemLock.Unlock()
@@ -187,20 +245,139 @@ func (r *Replicator) objReplicateHandler(writer http.ResponseWriter, request *ht
 	writer.Write(pickle.PickleDumps(hashes))
 }
 
+var errBadXattrs = fmt.Errorf("bad xattrs")
+
+// checkSyncFile answers a SyncFileRequest without its data, returning whether
+// the file should be sent.
+func (r *Replicator) checkSyncFile(sfr *SyncFileRequest) (SyncFileResponse, bool) {
+	fileName := filepath.Join(r.deviceRoot, sfr.Path)
+	hashDir := filepath.Dir(fileName)
+	if ext := filepath.Ext(fileName); (ext != ".data" && ext != ".ts" && ext != ".meta") || len(filepath.Base(hashDir)) != 32 {
+		return SyncFileResponse{Msg: "bad file path"}, false
+	}
+	if fs.Exists(fileName) {
+		return SyncFileResponse{Exists: true, Msg: "exists"}, false
+	}
+	dataFile, metaFile := ObjectFiles(hashDir)
+	if filepath.Base(fileName) < filepath.Base(dataFile) || filepath.Base(fileName) < filepath.Base(metaFile) {
+		return SyncFileResponse{NewerExists: true, Msg: "newer exists"}, false
+	}
+	if sfr.Check {
+		return SyncFileResponse{Exists: false, Msg: "doesn't exist"}, false
+	}
+	return SyncFileResponse{GoAhead: true, Msg: "go ahead"}, true
+}
+
+// syncTempFile starts the temp file an incoming file is written to, with its
+// metadata already set.
+func (r *Replicator) syncTempFile(tempDir string, sfr *SyncFileRequest) (fs.AtomicFileWriter, error) {
+	tempFile, err := fs.NewAtomicFileWriter(tempDir, filepath.Dir(filepath.Join(r.deviceRoot, sfr.Path)))
+	if err != nil {
+		return nil, err
+	}
+	if err := tempFile.Preallocate(sfr.Size, r.reserve); err != nil {
+		tempFile.Abandon()
+		return nil, err
+	}
+	if xattrs, err := hex.DecodeString(sfr.Xattrs); err != nil || len(xattrs) == 0 {
+		tempFile.Abandon()
+		return nil, errBadXattrs
+	} else if err := common.SwiftObjectRawWriteMetadata(tempFile.Fd(), xattrs); err != nil {
+		tempFile.Abandon()
+		return nil, err
+	}
+	return tempFile, nil
+}
+
+// saveSyncFile moves a received file into place and cleans up the files it
+// replaces.
+func (r *Replicator) saveSyncFile(tempFile fs.AtomicFileWriter, sfr *SyncFileRequest) error {
+	fileName := filepath.Join(r.deviceRoot, sfr.Path)
+	hashDir := filepath.Dir(fileName)
+	dataFile, metaFile := ObjectFiles(hashDir)
+	if err := tempFile.Save(fileName); err != nil {
+		return err
+	}
+	if dataFile != "" || metaFile != "" {
+		HashCleanupListDir(hashDir, r.reclaimAge)
+	}
+	InvalidateHash(hashDir)
+	return nil
+}
+
+// syncBatch handles a batch of SyncFileRequests with one response for them
+// all.  Files without data, like tombstones, are saved right away; the data
+// for the others that are wanted follows, in order, and gets a single
+// FileUploadBatchResponse.
+func (r *Replicator) syncBatch(rc RepConn, tempDir string, batch []SyncFileRequest) (string, error) {
+	type pendingFile struct {
+		sfr      *SyncFileRequest
+		tempFile fs.AtomicFileWriter
+	}
+	var pending []pendingFile
+	defer func() {
+		for _, p := range pending {
+			p.tempFile.Abandon()
+		}
+	}()
+	responses := make([]SyncFileResponse, len(batch))
+	for i := range batch {
+		sfr := &batch[i]
+		var wanted bool
+		if responses[i], wanted = r.checkSyncFile(sfr); !wanted {
+			continue
+		}
+		tempFile, err := r.syncTempFile(tempDir, sfr)
+		if err != nil {
+			responses[i] = SyncFileResponse{Msg: err.Error()}
+			continue
+		}
+		if sfr.Size > 0 {
+			pending = append(pending, pendingFile{sfr: sfr, tempFile: tempFile})
+			continue
+		}
+		if err := r.saveSyncFile(tempFile, sfr); err != nil {
+			responses[i] = SyncFileResponse{Msg: err.Error()}
+		} else {
+			responses[i] = SyncFileResponse{Synced: true, Msg: "saved"}
+		}
+		tempFile.Abandon()
+	}
+	if err := rc.SendMessage(SyncBatchResponse{Files: responses}); err != nil {
+		return "sending batch response", err
+	}
+	if len(pending) == 0 {
+		return "batch done", nil
+	}
+	uploads := make([]FileUploadResponse, len(pending))
+	for i, p := range pending {
+		if _, err := common.CopyN(rc, p.sfr.Size, p.tempFile); err != nil {
+			return "copying batch data", err
+		}
+		if err := r.saveSyncFile(p.tempFile, p.sfr); err != nil {
+			uploads[i] = FileUploadResponse{Msg: err.Error()}
+		} else {
+			uploads[i] = FileUploadResponse{Success: true, Msg: "YAY"}
+		}
+	}
+	return "batch files done", rc.SendMessage(FileUploadBatchResponse{Files: uploads})
+}
+
 func (r *Replicator) objRepConnHandler(writer http.ResponseWriter, request *http.Request) {
 	var conn net.Conn
 	var rw *bufio.ReadWriter
 	var err error
-	var brr BeginReplicationRequest
-
-	vars := srv.GetVars(request)
-	startTime := time.Now()
 
 	policy, err := strconv.Atoi(request.Header.Get("X-Backend-Storage-Policy-Index"))
 	if err != nil {
 		policy = 0
 	}
 
+	var features repConnFeatures
+	if wanted := request.Header.Get(repConnFeaturesHeader); wanted != "" {
+		features = r.repConnFeatures.and(parseRepConnFeatures(wanted))
+		writer.Header().Set(repConnFeaturesHeader, features.String())
+	}
 	writer.WriteHeader(http.StatusOK)
 	if hijacker, ok := writer.(http.Hijacker); !ok {
 		srv.GetLogger(request).Error("[ObjRepConnHandler] Writer not a Hijacker")
@@ -213,17 +390,33 @@ func (r *Replicator) objRepConnHandler(writer http.ResponseWriter, request *http
 	}
 	defer conn.Close()
 
-	rc := NewIncomingRepConn(rw, conn, r.rcTimeout)
-	if err := rc.RecvMessage(&brr); err != nil {
-		srv.GetLogger(request).Error("[ObjRepConnHandler] Error receiving BeginReplicationRequest", zap.Error(err))
-		writer.WriteHeader(http.StatusBadRequest)
-		return
+	rc := NewIncomingRepConn(rw, conn, r.rcTimeout, features)
+	for first := true; first || features.persistent; first = false {
+		var brr BeginReplicationRequest
+		if err := rc.RecvMessage(&brr); err != nil {
+			// Persistent connections are closed by the client once idle.
+			if first {
+				srv.GetLogger(request).Error("[ObjRepConnHandler] Error receiving BeginReplicationRequest", zap.Error(err))
+				writer.WriteHeader(http.StatusBadRequest)
+			}
+			return
+		}
+		if !r.objRepConnPartition(writer, request, rc, brr, policy) {
+			return
+		}
 	}
+}
+
+// objRepConnPartition syncs the partition in brr, returning whether it
+// finished cleanly.
+func (r *Replicator) objRepConnPartition(writer http.ResponseWriter, request *http.Request, rc RepConn, brr BeginReplicationRequest, policy int) bool {
+	var err error
+	startTime := time.Now()
 	if request.Header.Get("X-Force-Acquire") != "true" {
 		if !r.incomingBegin(brr.Device, replicateIncomingTimeout) {
 			srv.GetLogger(request).Error("[ObjRepConnHandler] Timed out waiting for concurrency slot")
 			writer.WriteHeader(503)
-			return
+			return false
 		}
 		defer r.incomingDone(brr.Device)
 	}
@@ -233,14 +426,15 @@ func (r *Replicator) objRepConnHandler(writer http.ResponseWriter, request *http
 		if err != nil {
 			srv.GetLogger(request).Error("[ObjRepConnHandler] Error getting hashes", zap.Error(err))
 			writer.WriteHeader(http.StatusInternalServerError)
-			return
+			return false
 		}
 	}
 	if err := rc.SendMessage(BeginReplicationResponse{Hashes: hashes}); err != nil {
 		srv.GetLogger(request).Error("[ObjRepConnHandler] Error sending BeginReplicationResponse", zap.Duration("connectionTime", time.Since(startTime)), zap.Error(err))
 		writer.WriteHeader(http.StatusInternalServerError)
-		return
+		return false
 	}
+	tempDir := TempDirPath(r.deviceRoot, brr.Device)
 	sfrsProcessed := int64(0)
 	startTime = time.Now()
 	for {
@@ -256,54 +450,33 @@ func (r *Replicator) objRepConnHandler(writer http.ResponseWriter, request *http
 			if sfr.Ping {
 				return "ping", rc.SendMessage(SyncFileResponse{Msg: "pong"})
 			}
-			tempDir := TempDirPath(r.deviceRoot, vars["device"])
-			fileName := filepath.Join(r.deviceRoot, sfr.Path)
-			hashDir := filepath.Dir(fileName)
-
-			if ext := filepath.Ext(fileName); (ext != ".data" && ext != ".ts" && ext != ".meta") || len(filepath.Base(filepath.Dir(fileName))) != 32 {
-				return "invalid file path", rc.SendMessage(SyncFileResponse{Msg: "bad file path"})
-			}
-			if fs.Exists(fileName) {
-				return "file exists", rc.SendMessage(SyncFileResponse{Exists: true, Msg: "exists"})
+			if len(sfr.Batch) > 0 {
+				return r.syncBatch(rc, tempDir, sfr.Batch)
 			}
-			dataFile, metaFile := ObjectFiles(hashDir)
-			if filepath.Base(fileName) < filepath.Base(dataFile) || filepath.Base(fileName) < filepath.Base(metaFile) {
-				return "newer file exists", rc.SendMessage(SyncFileResponse{NewerExists: true, Msg: "newer exists"})
+			if response, wanted := r.checkSyncFile(&sfr); !wanted {
+				return "file not wanted", rc.SendMessage(response)
 			}
-			if sfr.Check {
-				return "just check", rc.SendMessage(SyncFileResponse{Exists: false, Msg: "doesn't exist"})
-			}
-			tempFile, err := fs.NewAtomicFileWriter(tempDir, hashDir)
-			if err != nil {
-				return "creating file writer", err
-			}
-			defer tempFile.Abandon()
-			if err := tempFile.Preallocate(sfr.Size, r.reserve); err != nil {
-				return "preallocating space", err
-			}
-			if xattrs, err := hex.DecodeString(sfr.Xattrs); err != nil || len(xattrs) == 0 {
+			tempFile, err := r.syncTempFile(tempDir, &sfr)
+			if err == errBadXattrs {
 				return "parsing xattrs", rc.SendMessage(SyncFileResponse{Msg: "bad xattrs"})
-			} else if err := common.SwiftObjectRawWriteMetadata(tempFile.Fd(), xattrs); err != nil {
-				return "writing metadata", err
+			} else if err != nil {
+				return "creating temp file", err
 			}
+			defer tempFile.Abandon()
 			if err := rc.SendMessage(SyncFileResponse{GoAhead: true, Msg: "go ahead"}); err != nil {
 				return "sending go ahead", err
 			}
 			if _, err := common.CopyN(rc, sfr.Size, tempFile); err != nil {
 				return "copying data", err
 			}
-			if err := tempFile.Save(fileName); err != nil {
+			if err := r.saveSyncFile(tempFile, &sfr); err != nil {
 				return "saving file", err
 			}
-			if dataFile != "" || metaFile != "" {
-				HashCleanupListDir(hashDir, r.reclaimAge)
-			}
-			InvalidateHash(hashDir)
 			err = rc.SendMessage(FileUploadResponse{Success: true, Msg: "YAY"})
 			return "file done", err
 		}()
 		if err == replicationDone {
-			return
+			return true
 		} else if err != nil {
 			srv.GetLogger(request).Error("[ObjRepConnHandler] Error replicating",
 				zap.String("errType", errType),
@@ -311,7 +484,7 @@ func (r *Replicator) objRepConnHandler(writer http.ResponseWriter, request *http
 				zap.Duration("sfrProcessTime", time.Since(startTime)),
 				zap.Error(err))
 			writer.WriteHeader(http.StatusInternalServerError)
-			return
+			return false
 		}
 	}
 }
@@ -327,32 +500,41 @@ func (r *Replicator) GetHandler(config conf.Config, metricsPrefix string) http.H
 		CachedReporter: promreporter.NewReporter(promreporter.Options{}),
 		Separator:      promreporter.DefaultSeparator,
 	}, time.Second)
+	if r.auditor != nil {
+		r.auditor.metricsScope = r.metricsScope.SubScope("auditor")
+	}
 	commonHandlers := alice.New(
 		middleware.NewDebugResponses(config.GetBool("debug", "debug_x_source_code", false)),
 		r.LogRequest,
 		middleware.RecoverHandler,
 		middleware.ValidateRequest,
 	)
+	dataHandlers := commonHandlers.Append(middleware.BackendSignatures())
 	router := srv.NewRouter()
 	router.Get("/metrics", prometheus.Handler())
 	router.Get("/loglevel", r.logLevel)
 	router.Put("/loglevel", r.logLevel)
+	router.Get("/loglevel/:component", http.HandlerFunc(srv.LogLevelHandler))
+	router.Put("/loglevel/:component", http.HandlerFunc(srv.LogLevelHandler))
+	router.Get("/loglevels", http.HandlerFunc(srv.LogLevelsHandler))
 	router.Get("/healthcheck", commonHandlers.ThenFunc(r.HealthcheckHandler))
 	router.Get("/debug/pprof/:parm", http.DefaultServeMux)
 	router.Post("/debug/pprof/:parm", http.DefaultServeMux)
-	router.Post("/priorityrep", commonHandlers.ThenFunc(r.priorityRepHandler))
-	router.Post("/stabilize/:device/:partition/:account/:container/*obj", commonHandlers.ThenFunc(r.stabilizeHandler))
+	router.Post("/priorityrep", dataHandlers.ThenFunc(r.priorityRepHandler))
+	router.Post("/stabilize/:device/:partition/:account/:container/*obj", dataHandlers.ThenFunc(r.stabilizeHandler))
 	router.Get("/progress/:name", commonHandlers.ThenFunc(r.ProgressReportHandler))
+	router.Get("/throttle", commonHandlers.ThenFunc(r.throttleHandler))
+	router.Put("/throttle", commonHandlers.ThenFunc(r.throttleHandler))
 	for _, policy := range r.policies {
-		router.HandlePolicy("REPCONN", "/:device/:partition", policy.Index, commonHandlers.ThenFunc(r.objRepConnHandler))
-		router.HandlePolicy("REPLICATE", "/:device/:partition/:suffixes", policy.Index, commonHandlers.ThenFunc(r.objReplicateHandler))
-		router.HandlePolicy("REPLICATE", "/:device/:partition", policy.Index, commonHandlers.ThenFunc(r.objReplicateHandler))
+		router.HandlePolicy("REPCONN", "/:device/:partition", policy.Index, dataHandlers.ThenFunc(r.objRepConnHandler))
+		router.HandlePolicy("REPLICATE", "/:device/:partition/:suffixes", policy.Index, dataHandlers.ThenFunc(r.objReplicateHandler))
+		router.HandlePolicy("REPLICATE", "/:device/:partition", policy.Index, dataHandlers.ThenFunc(r.objReplicateHandler))
 	}
 	router.Get("/debug/*_", http.DefaultServeMux)
 	for policy, objEngine := range r.objEngines {
 		if rhoe, ok := objEngine.(PolicyHandlerRegistrator); ok {
 			rhoe.RegisterHandlers(func(method, path string, handler http.HandlerFunc) {
-				router.HandlePolicy(method, path, policy, commonHandlers.ThenFunc(handler))
+				router.HandlePolicy(method, path, policy, dataHandlers.ThenFunc(handler))
 			})
 		}
 	}
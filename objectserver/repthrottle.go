@@ -0,0 +1,258 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package objectserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// byteRate spaces out writes so they average no more than rate bytes per
+// second.  A rate of 0 doesn't limit.
+type byteRate struct {
+	lock sync.Mutex
+	rate int64
+	next time.Time
+}
+
+func (b *byteRate) setRate(rate int64) {
+	b.lock.Lock()
+	b.rate = rate
+	b.lock.Unlock()
+}
+
+// wait reserves time for n bytes, sleeping until the bytes before them have
+// had theirs.
+func (b *byteRate) wait(n int64) {
+	b.lock.Lock()
+	if b.rate <= 0 {
+		b.lock.Unlock()
+		return
+	}
+	now := time.Now()
+	if b.next.Before(now) {
+		b.next = now
+	}
+	delay := b.next.Sub(now)
+	b.next = b.next.Add(time.Duration(n) * time.Second / time.Duration(b.rate))
+	b.lock.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// slots is a counting semaphore whose size can be changed while it's in use.
+// A limit of 0 doesn't limit.
+type slots struct {
+	lock  sync.Mutex
+	cond  *sync.Cond
+	limit int
+	inUse int
+}
+
+func newSlots(limit int) *slots {
+	s := &slots{limit: limit}
+	s.cond = sync.NewCond(&s.lock)
+	return s
+}
+
+func (s *slots) acquire() {
+	s.lock.Lock()
+	for s.limit > 0 && s.inUse >= s.limit {
+		s.cond.Wait()
+	}
+	s.inUse++
+	s.lock.Unlock()
+}
+
+func (s *slots) release() {
+	s.lock.Lock()
+	s.inUse--
+	s.cond.Broadcast()
+	s.lock.Unlock()
+}
+
+func (s *slots) setLimit(limit int) {
+	s.lock.Lock()
+	s.limit = limit
+	s.cond.Broadcast()
+	s.lock.Unlock()
+}
+
+// ThrottleSettings are the replicator's bandwidth and concurrency limits, as
+// served and accepted by its /throttle endpoint.  Rates are in MB/s and 0 is
+// unlimited, except for Concurrency, which must be at least 1.  Fields left
+// out of an update keep their current values.
+type ThrottleSettings struct {
+	MBPerSecond       *float64 `json:"mb_per_second,omitempty"`
+	DeviceMBPerSecond *float64 `json:"device_mb_per_second,omitempty"`
+	Concurrency       *int     `json:"concurrency,omitempty"`
+	DeviceConcurrency *int     `json:"device_concurrency,omitempty"`
+}
+
+// replicationThrottle limits how fast the replicator sends file data and how
+// many partitions it syncs at once, both across all devices and for each
+// device.
+type replicationThrottle struct {
+	lock              sync.Mutex
+	mbPerSecond       float64
+	deviceMBPerSecond float64
+	concurrency       int
+	deviceConcurrency int
+	rate              *byteRate
+	deviceRates       map[string]*byteRate
+	partitions        *slots
+	devicePartitions  map[string]*slots
+}
+
+func newReplicationThrottle(mbPerSecond, deviceMBPerSecond float64, concurrency, deviceConcurrency int) *replicationThrottle {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	t := &replicationThrottle{
+		mbPerSecond:       mbPerSecond,
+		deviceMBPerSecond: deviceMBPerSecond,
+		concurrency:       concurrency,
+		deviceConcurrency: deviceConcurrency,
+		rate:              &byteRate{rate: mbToBytes(mbPerSecond)},
+		deviceRates:       map[string]*byteRate{},
+		partitions:        newSlots(concurrency),
+		devicePartitions:  map[string]*slots{},
+	}
+	return t
+}
+
+func mbToBytes(mb float64) int64 {
+	return int64(mb * 1024 * 1024)
+}
+
+func (t *replicationThrottle) device(device string) (*byteRate, *slots) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	rate, ok := t.deviceRates[device]
+	if !ok {
+		rate = &byteRate{rate: mbToBytes(t.deviceMBPerSecond)}
+		t.deviceRates[device] = rate
+	}
+	partitions, ok := t.devicePartitions[device]
+	if !ok {
+		partitions = newSlots(t.deviceConcurrency)
+		t.devicePartitions[device] = partitions
+	}
+	return rate, partitions
+}
+
+// beginPartition waits until the device may sync another partition.  The
+// device's own limit is taken first, so a busy device doesn't hold global
+// slots that other devices could use.
+func (t *replicationThrottle) beginPartition(device string) {
+	_, partitions := t.device(device)
+	partitions.acquire()
+	t.partitions.acquire()
+}
+
+func (t *replicationThrottle) endPartition(device string) {
+	_, partitions := t.device(device)
+	t.partitions.release()
+	partitions.release()
+}
+
+// sent waits until n more bytes may be sent from the device.
+func (t *replicationThrottle) sent(device string, n int64) {
+	rate, _ := t.device(device)
+	rate.wait(n)
+	t.rate.wait(n)
+}
+
+func (t *replicationThrottle) settings() ThrottleSettings {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	mbPerSecond, deviceMBPerSecond := t.mbPerSecond, t.deviceMBPerSecond
+	concurrency, deviceConcurrency := t.concurrency, t.deviceConcurrency
+	return ThrottleSettings{
+		MBPerSecond:       &mbPerSecond,
+		DeviceMBPerSecond: &deviceMBPerSecond,
+		Concurrency:       &concurrency,
+		DeviceConcurrency: &deviceConcurrency,
+	}
+}
+
+func (t *replicationThrottle) valid(s ThrottleSettings) bool {
+	return (s.MBPerSecond == nil || *s.MBPerSecond >= 0) &&
+		(s.DeviceMBPerSecond == nil || *s.DeviceMBPerSecond >= 0) &&
+		(s.Concurrency == nil || *s.Concurrency >= 1) &&
+		(s.DeviceConcurrency == nil || *s.DeviceConcurrency >= 0)
+}
+
+// update applies the settings given in s; syncs already running finish, but
+// anything waiting is held to the new limits.
+func (t *replicationThrottle) update(s ThrottleSettings) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if s.MBPerSecond != nil {
+		t.mbPerSecond = *s.MBPerSecond
+		t.rate.setRate(mbToBytes(t.mbPerSecond))
+	}
+	if s.DeviceMBPerSecond != nil {
+		t.deviceMBPerSecond = *s.DeviceMBPerSecond
+		for _, rate := range t.deviceRates {
+			rate.setRate(mbToBytes(t.deviceMBPerSecond))
+		}
+	}
+	if s.Concurrency != nil {
+		t.concurrency = *s.Concurrency
+		t.partitions.setLimit(t.concurrency)
+	}
+	if s.DeviceConcurrency != nil {
+		t.deviceConcurrency = *s.DeviceConcurrency
+		for _, partitions := range t.devicePartitions {
+			partitions.setLimit(t.deviceConcurrency)
+		}
+	}
+}
+
+// throttleHandler serves the current replication limits on GET and changes
+// them on PUT, so rebalances can be sped up or slowed down without a restart.
+// Changes last until the replicator is restarted.
+func (r *Replicator) throttleHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method == "PUT" {
+		data, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		var s ThrottleSettings
+		if err := json.Unmarshal(data, &s); err != nil || !r.throttle.valid(s) {
+			w.WriteHeader(400)
+			return
+		}
+		r.throttle.update(s)
+		r.logger.Info("Replication throttle updated", zap.Any("settings", r.throttle.settings()))
+	}
+	data, err := json.Marshal(r.throttle.settings())
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(data)
+}
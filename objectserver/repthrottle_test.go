@@ -0,0 +1,123 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package objectserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/common/test"
+)
+
+func TestByteRate(t *testing.T) {
+	b := &byteRate{rate: 1000}
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		b.wait(50)
+	}
+	// The first write goes right away, the other three wait 50ms each.
+	require.True(t, time.Since(start) >= 150*time.Millisecond)
+
+	b.setRate(0)
+	start = time.Now()
+	b.wait(1000000)
+	b.wait(1000000)
+	require.True(t, time.Since(start) < 100*time.Millisecond)
+}
+
+func TestSlotsSetLimit(t *testing.T) {
+	s := newSlots(1)
+	s.acquire()
+	acquired := make(chan struct{})
+	go func() {
+		s.acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired a slot over the limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+	s.setLimit(2)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("raising the limit didn't free a slot")
+	}
+	s.release()
+	s.release()
+}
+
+func TestThrottleDeviceConcurrency(t *testing.T) {
+	throttle := newReplicationThrottle(0, 0, 2, 1)
+	throttle.beginPartition("sda")
+	began := make(chan string, 2)
+	go func() {
+		throttle.beginPartition("sda")
+		began <- "sda"
+	}()
+	go func() {
+		throttle.beginPartition("sdb")
+		began <- "sdb"
+	}()
+	require.Equal(t, "sdb", <-began)
+	select {
+	case <-began:
+		t.Fatal("sda synced two partitions at once")
+	case <-time.After(10 * time.Millisecond):
+	}
+	throttle.endPartition("sda")
+	require.Equal(t, "sda", <-began)
+}
+
+func TestThrottleHandler(t *testing.T) {
+	replicator, _, err := newTestReplicator(srv.NewTestConfigLoader(&test.FakeRing{}), "mb_per_second", "10", "device_concurrency", "2")
+	require.Nil(t, err)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/throttle", nil)
+	replicator.throttleHandler(w, req)
+	require.Equal(t, 200, w.Code)
+	var s ThrottleSettings
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &s))
+	require.Equal(t, 10.0, *s.MBPerSecond)
+	require.Equal(t, 0.0, *s.DeviceMBPerSecond)
+	require.Equal(t, 2, *s.DeviceConcurrency)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/throttle", bytes.NewBufferString(`{"device_mb_per_second": 2.5, "concurrency": 4}`))
+	replicator.throttleHandler(w, req)
+	require.Equal(t, 200, w.Code)
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &s))
+	require.Equal(t, 10.0, *s.MBPerSecond)
+	require.Equal(t, 2.5, *s.DeviceMBPerSecond)
+	require.Equal(t, 4, *s.Concurrency)
+	rate, _ := replicator.throttle.device("sda")
+	require.Equal(t, int64(2.5*1024*1024), rate.rate)
+
+	for _, body := range []string{`{"concurrency": 0}`, `{"mb_per_second": -1}`, `nope`} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("PUT", "/throttle", bytes.NewBufferString(body))
+		replicator.throttleHandler(w, req)
+		require.Equal(t, 400, w.Code)
+	}
+}
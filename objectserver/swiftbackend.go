@@ -28,6 +28,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/troubling/hummingbird/common"
@@ -86,8 +87,10 @@ func QuarantineHash(hashDir string) error {
 // InvalidateHash invalidates the hashdir's suffix hash, indicating it needs to be recalculated.
 func InvalidateHash(hashDir string) error {
 	suffDir := filepath.Dir(hashDir)
-	partitionDir := filepath.Dir(suffDir)
+	return invalidateSuffixes(filepath.Dir(suffDir), []string{filepath.Base(suffDir)})
+}
 
+func invalidateSuffixes(partitionDir string, suffixes []string) error {
 	if partitionLock, err := fs.LockPath(partitionDir, 10*time.Second); err != nil {
 		return err
 	} else {
@@ -98,10 +101,128 @@ func InvalidateHash(hashDir string) error {
 		return err
 	}
 	defer fp.Close()
-	_, err = fmt.Fprintf(fp, "%s\n", filepath.Base(suffDir))
+	_, err = io.WriteString(fp, strings.Join(suffixes, "\n")+"\n")
 	return err
 }
 
+// hashInvalidator batches suffix invalidations, so a partition taking many
+// writes is locked and has hashes.invalid appended to once per flush instead
+// of once per write. Invalidations still pending when the process dies are
+// lost, leaving those suffixes unreplicated until something else touches
+// them, so the flush interval should stay short.
+type hashInvalidator struct {
+	lock    sync.Mutex
+	pending map[string]map[string]bool // partition dir -> suffixes
+}
+
+var (
+	batchedInvalidator     *hashInvalidator
+	batchedInvalidatorOnce sync.Once
+)
+
+// getHashInvalidator returns the process's hashInvalidator, starting it with
+// the given flush interval the first time it's called.
+func getHashInvalidator(interval time.Duration) *hashInvalidator {
+	batchedInvalidatorOnce.Do(func() {
+		batchedInvalidator = &hashInvalidator{pending: map[string]map[string]bool{}}
+		go func() {
+			for range time.Tick(interval) {
+				batchedInvalidator.flush()
+			}
+		}()
+	})
+	return batchedInvalidator
+}
+
+var (
+	groupSyncer     *fs.GroupSyncer
+	groupSyncerOnce sync.Once
+)
+
+// getGroupSyncer returns the process's GroupSyncer, shared by every policy so
+// writes to a device are grouped whatever their policy.
+func getGroupSyncer(window time.Duration) *fs.GroupSyncer {
+	groupSyncerOnce.Do(func() {
+		groupSyncer = fs.NewGroupSyncer(window)
+	})
+	return groupSyncer
+}
+
+func (hi *hashInvalidator) invalidate(hashDir string) {
+	suffDir := filepath.Dir(hashDir)
+	partitionDir := filepath.Dir(suffDir)
+	hi.lock.Lock()
+	if hi.pending[partitionDir] == nil {
+		hi.pending[partitionDir] = map[string]bool{}
+	}
+	hi.pending[partitionDir][filepath.Base(suffDir)] = true
+	hi.lock.Unlock()
+}
+
+func (hi *hashInvalidator) flush() {
+	if hi == nil {
+		return
+	}
+	hi.lock.Lock()
+	pending := hi.pending
+	hi.pending = map[string]map[string]bool{}
+	hi.lock.Unlock()
+	for partitionDir, suffixSet := range pending {
+		suffixes := make([]string, 0, len(suffixSet))
+		for suffix := range suffixSet {
+			suffixes = append(suffixes, suffix)
+		}
+		if err := invalidateSuffixes(partitionDir, suffixes); err != nil {
+			// try again next flush
+			hi.lock.Lock()
+			if hi.pending[partitionDir] == nil {
+				hi.pending[partitionDir] = map[string]bool{}
+			}
+			for _, suffix := range suffixes {
+				hi.pending[partitionDir][suffix] = true
+			}
+			hi.lock.Unlock()
+		}
+	}
+}
+
+// reclaimable returns whether filename is a tombstone older than reclaimAge
+// seconds, which every replica will have seen by now.
+func reclaimable(filename string, reclaimAge int64) bool {
+	if !strings.HasSuffix(filename, ".ts") {
+		return false
+	}
+	withoutSuffix := strings.Split(filename, ".")[0]
+	if strings.Contains(withoutSuffix, "_") {
+		withoutSuffix = strings.Split(withoutSuffix, "_")[0]
+	}
+	timestamp, _ := strconv.ParseFloat(withoutSuffix, 64)
+	return time.Now().Unix()-int64(timestamp) > reclaimAge
+}
+
+// reclaimableHashDir returns whether a hash dir holding fileList is nothing
+// but a reclaimable tombstone, or nothing at all.
+func reclaimableHashDir(fileList []string, reclaimAge int64) bool {
+	return len(fileList) == 0 || (len(fileList) == 1 && reclaimable(fileList[0], reclaimAge))
+}
+
+// ReclaimHashDir removes hashDir, holding fileList, if it's reclaimable and
+// returns whether it did.  The suffix is invalidated when a tombstone goes, so
+// its hash is recalculated.  A file written into hashDir in the meantime
+// keeps the directory around.
+func ReclaimHashDir(hashDir string, fileList []string, reclaimAge int64) bool {
+	if !reclaimableHashDir(fileList, reclaimAge) {
+		return false
+	}
+	if len(fileList) == 1 {
+		if err := os.Remove(filepath.Join(hashDir, fileList[0])); err != nil && !os.IsNotExist(err) {
+			return false
+		}
+		InvalidateHash(hashDir)
+	}
+	return os.Remove(hashDir) == nil
+}
+
 func HashCleanupListDir(hashDir string, reclaimAge int64) ([]string, error) {
 	fileList, err := fs.ReadDirNames(hashDir)
 	returnList := []string{}
@@ -118,16 +239,9 @@ func HashCleanupListDir(hashDir string, reclaimAge int64) ([]string, error) {
 	deleteRestMeta := false
 	if len(fileList) == 1 {
 		filename := fileList[0]
-		if strings.HasSuffix(filename, ".ts") {
-			withoutSuffix := strings.Split(filename, ".")[0]
-			if strings.Contains(withoutSuffix, "_") {
-				withoutSuffix = strings.Split(withoutSuffix, "_")[0]
-			}
-			timestamp, _ := strconv.ParseFloat(withoutSuffix, 64)
-			if time.Now().Unix()-int64(timestamp) > reclaimAge {
-				os.RemoveAll(hashDir + "/" + filename)
-				return returnList, nil
-			}
+		if reclaimable(filename, reclaimAge) {
+			os.RemoveAll(hashDir + "/" + filename)
+			return returnList, nil
 		}
 		returnList = append(returnList, filename)
 	} else {
@@ -323,7 +437,10 @@ func applyMetaFile(metaFile string, datafileMetadata map[string]string) (map[str
 		return nil, err
 	} else {
 		for k, v := range datafileMetadata {
-			if k == "Content-Length" || k == "Content-Type" || k == "deleted" || k == "ETag" || k == "X-Backend-Data-Timestamp" || strings.HasPrefix(k, "X-Object-Sysmeta-") {
+			if isDataFileMetadata(k) || k == "X-Backend-Data-Timestamp" {
+				metadata[k] = v
+			} else if _, ok := metadata[k]; !ok && k == "Content-Type" {
+				// .meta files only have a Content-Type if a POST changed it.
 				metadata[k] = v
 			}
 		}
@@ -361,10 +478,5 @@ func TempDirPath(driveRoot string, device string) string {
 }
 
 func Expired(metadata map[string]string) bool {
-	if deleteAt, ok := metadata["X-Delete-At"]; ok {
-		if deleteTime, err := common.ParseDate(deleteAt); err == nil && deleteTime.Before(time.Now()) {
-			return true
-		}
-	}
-	return false
+	return common.DeleteAtPassed(metadata["X-Delete-At"])
 }
@@ -19,6 +19,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/troubling/hummingbird/common"
@@ -87,6 +89,66 @@ func TestInvalidateHash(t *testing.T) {
 	assert.Equal(t, "8834e84467693c2e8f670f4afbea5334", hashes["abc"])
 }
 
+func TestReclaimHashDir(t *testing.T) {
+	driveRoot, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(driveRoot)
+	suffixDir := filepath.Join(driveRoot, "sda", "objects", "1", "abc")
+	oldTombstone := filepath.Join(suffixDir, "fffffffffffffffffffffffffffffabc")
+	newTombstone := filepath.Join(suffixDir, "00000000000000000000000000000abc")
+	empty := filepath.Join(suffixDir, "11111111111111111111111111111abc")
+	for _, hashDir := range []string{oldTombstone, newTombstone, empty} {
+		os.MkdirAll(hashDir, 0777)
+	}
+	f, _ := os.Create(filepath.Join(oldTombstone, "12345.ts"))
+	f.Close()
+	f, _ = os.Create(filepath.Join(newTombstone, common.GetTimestamp()+".ts"))
+	f.Close()
+
+	for _, hashDir := range []string{oldTombstone, newTombstone, empty} {
+		fileList, err := fs.ReadDirNames(hashDir)
+		require.Nil(t, err)
+		require.Equal(t, hashDir != newTombstone, ReclaimHashDir(hashDir, fileList, int64(common.ONE_WEEK)))
+	}
+	hashes, err := fs.ReadDirNames(suffixDir)
+	require.Nil(t, err)
+	require.Equal(t, []string{"00000000000000000000000000000abc"}, hashes)
+	invalid, err := ioutil.ReadFile(filepath.Join(driveRoot, "sda", "objects", "1", "hashes.invalid"))
+	require.Nil(t, err)
+	require.Equal(t, "abc\n", string(invalid))
+}
+
+func TestHashInvalidatorBatches(t *testing.T) {
+	driveRoot, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(driveRoot)
+	partitionDir := filepath.Join(driveRoot, "sda", "objects", "1")
+	os.MkdirAll(filepath.Join(partitionDir, "abc", "00000000000000000000000000000abc"), 0777)
+	f, _ := os.Create(filepath.Join(partitionDir, "abc", "00000000000000000000000000000abc", "67890.data"))
+	f.Close()
+	hashes, err := GetHashes(driveRoot, "sda", "1", nil, int64(common.ONE_WEEK), 0, nil)
+	require.Nil(t, err)
+	oldHash := hashes["abc"]
+
+	f, _ = os.Create(filepath.Join(partitionDir, "abc", "00000000000000000000000000000abc", "99999.meta"))
+	f.Close()
+	hi := &hashInvalidator{pending: map[string]map[string]bool{}}
+	hi.invalidate(filepath.Join(partitionDir, "abc", "00000000000000000000000000000abc"))
+	hi.invalidate(filepath.Join(partitionDir, "abc", "fffffffffffffffffffffffffffffabc"))
+	hi.invalidate(filepath.Join(partitionDir, "def", "fffffffffffffffffffffffffffffdef"))
+	require.False(t, fs.Exists(filepath.Join(partitionDir, "hashes.invalid")))
+
+	hi.flush()
+	data, err := ioutil.ReadFile(filepath.Join(partitionDir, "hashes.invalid"))
+	require.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	sort.Strings(lines)
+	require.Equal(t, []string{"abc", "def"}, lines)
+	require.Equal(t, 0, len(hi.pending))
+
+	hashes, err = GetHashes(driveRoot, "sda", "1", nil, int64(common.ONE_WEEK), 0, nil)
+	require.Nil(t, err)
+	require.NotEqual(t, oldHash, hashes["abc"])
+}
+
 func TestPolicyDir(t *testing.T) {
 	policy, err := UnPolicyDir("objects")
 	require.Nil(t, err)
@@ -104,6 +104,7 @@ type swiftDevice struct {
 		beginReplication(dev *ring.Device, partition string, hashes bool, rChan chan beginReplicationResponse, headers map[string]string)
 		listObjFiles(objChan chan string, cancel chan struct{}, partdir string, needSuffix func(string) bool)
 		syncFile(objFile string, dst []*syncFileArg, handoff bool) (syncs int, insync int, err error)
+		syncBatch(objFiles []string, dst []*syncFileArg, handoff bool) (syncs int, insync []int, err error)
 		replicateUsingHashes(rjob replJob, moreNodes ring.MoreNodes) (int64, error)
 		replicateAll(rjob replJob, isHandoff bool) (int64, error)
 		cleanTemp()
@@ -128,6 +129,17 @@ type syncFileArg struct {
 	dev  *ring.Device
 }
 
+// activeSyncs counts the destinations still being sent to.
+func activeSyncs(wrs []*syncFileArg) int {
+	count := 0
+	for _, sfa := range wrs {
+		if sfa != nil {
+			count++
+		}
+	}
+	return count
+}
+
 type replJob struct {
 	partition string
 	nodes     []*ring.Device
@@ -146,13 +158,46 @@ func (rd *swiftDevice) UpdateStat(stat string, amount int64) {
 	rd.r.updateStat <- statUpdate{rd.Type(), rd.Key(), stat, amount}
 }
 
+// isObjFileName reports whether name looks like a .ts, .data or .meta file.
+func isObjFileName(name string) bool {
+	for i := 0; i < len(name)-1; i++ {
+		if name[i] == '.' && (name[i+1] == 't' || name[i+1] == 'd' || name[i+1] == 'm') {
+			return true
+		}
+	}
+	return false
+}
+
+func isSuffixName(name string) bool {
+	if len(name) != 3 {
+		return false
+	}
+	for i := 0; i < 3; i++ {
+		if !(name[i] >= '0' && name[i] <= '9') && !(name[i] >= 'a' && name[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// listObjFiles sends the object files in partdir's suffixes down objChan,
+// listing up to the replicator's listing_concurrency suffixes at once, and
+// removes empty hash, suffix and partition directories along the way.
 func (rd *swiftDevice) listObjFiles(objChan chan string, cancel chan struct{}, partdir string, needSuffix func(string) bool) {
 	defer close(objChan)
-	suffixDirs, err := filepath.Glob(filepath.Join(partdir, "[a-f0-9][a-f0-9][a-f0-9]"))
+	entries, err := fs.ReadDirEntries(partdir)
 	if err != nil {
-		rd.r.logger.Error("[listObjFiles]", zap.Error(err))
+		if !os.IsNotExist(err) {
+			rd.r.logger.Error("[listObjFiles]", zap.Error(err))
+		}
 		return
 	}
+	var suffixDirs []string
+	for _, entry := range entries {
+		if entry.IsDir && isSuffixName(entry.Name) {
+			suffixDirs = append(suffixDirs, filepath.Join(partdir, entry.Name))
+		}
+	}
 	if len(suffixDirs) == 0 {
 		os.Remove(filepath.Join(partdir, ".lock"))
 		os.Remove(filepath.Join(partdir, "hashes.pkl"))
@@ -164,38 +209,85 @@ func (rd *swiftDevice) listObjFiles(objChan chan string, cancel chan struct{}, p
 		j := rand.Intn(i + 1)
 		suffixDirs[j], suffixDirs[i] = suffixDirs[i], suffixDirs[j]
 	}
+	workers := rd.r.listingConcurrency
+	if workers > len(suffixDirs) {
+		workers = len(suffixDirs)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	suffixChan := make(chan string)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for suffDir := range suffixChan {
+				if !rd.listSuffixObjFiles(objChan, cancel, suffDir) {
+					return
+				}
+			}
+		}()
+	}
+feed:
 	for _, suffDir := range suffixDirs {
 		if !needSuffix(filepath.Base(suffDir)) {
 			continue
 		}
-		hashDirs, err := filepath.Glob(filepath.Join(suffDir, "????????????????????????????????"))
+		select {
+		case suffixChan <- suffDir:
+		case <-cancel:
+			break feed
+		}
+	}
+	close(suffixChan)
+	wg.Wait()
+}
+
+// listSuffixObjFiles sends the object files in suffDir down objChan, returning
+// false if it was canceled.
+func (rd *swiftDevice) listSuffixObjFiles(objChan chan string, cancel chan struct{}, suffDir string) bool {
+	hashes, err := fs.ReadDirEntries(suffDir)
+	if err != nil {
+		rd.r.logger.Error("[listObjFiles]", zap.Error(err))
+		return true
+	}
+	if len(hashes) == 0 {
+		os.Remove(suffDir)
+		return true
+	}
+	for _, entry := range hashes {
+		if len(entry.Name) != 32 || !entry.IsDir {
+			continue
+		}
+		hashDir := filepath.Join(suffDir, entry.Name)
+		fileList, err := fs.ReadDirNames(hashDir)
 		if err != nil {
 			rd.r.logger.Error("[listObjFiles]", zap.Error(err))
-			return
+			continue
 		}
-		if len(hashDirs) == 0 {
-			os.Remove(suffDir)
+		if ReclaimHashDir(hashDir, fileList, rd.r.reclaimAge) {
+			// Old tombstones aren't worth sending; the other replicas
+			// should be reclaiming theirs by now too.
 			continue
 		}
-		for _, hashDir := range hashDirs {
-			fileList, err := filepath.Glob(filepath.Join(hashDir, "*.[tdm]*"))
-			if len(fileList) == 0 {
-				os.Remove(hashDir)
+		found := false
+		for _, name := range fileList {
+			if !isObjFileName(name) {
 				continue
 			}
-			if err != nil {
-				rd.r.logger.Error("[listObjFiles]", zap.Error(err))
-				return
-			}
-			for _, objFile := range fileList {
-				select {
-				case objChan <- objFile:
-				case <-cancel:
-					return
-				}
+			found = true
+			select {
+			case objChan <- filepath.Join(hashDir, name):
+			case <-cancel:
+				return false
 			}
 		}
+		if !found {
+			os.Remove(hashDir)
+		}
 	}
+	return true
 }
 
 func (rd *swiftDevice) syncFile(objFile string, dst []*syncFileArg, handoff bool) (syncs int, insync int, err error) {
@@ -255,6 +347,7 @@ func (rd *swiftDevice) syncFile(objFile string, dst []*syncFileArg, handoff bool
 	var totalRead int64
 	for length, err = fp.Read(scratch); err == nil; length, err = fp.Read(scratch) {
 		totalRead += int64(length)
+		rd.r.throttle.sent(rd.dev.Device, int64(length*activeSyncs(wrs)))
 		for index, sfa := range wrs {
 			if sfa == nil {
 				continue
@@ -290,6 +383,137 @@ func (rd *swiftDevice) syncFile(objFile string, dst []*syncFileArg, handoff bool
 	return syncs, insync, nil
 }
 
+// syncBatch syncs small files with one round trip to each node in dst for
+// the lot, plus one more for the data of those the node wants.  Files without
+// data, like tombstones, are saved by the nodes straight from the batch.  It
+// returns how many copies were sent and, for each file, how many of the nodes
+// have it.
+func (rd *swiftDevice) syncBatch(objFiles []string, dst []*syncFileArg, handoff bool) (syncs int, insync []int, err error) {
+	type batchFile struct {
+		index   int
+		relPath string
+		xattrs  string
+		data    []byte
+		// are we already going to sync to this region?
+		syncingRemoteRegion map[int]bool
+	}
+	insync = make([]int, len(objFiles))
+	files := make([]*batchFile, 0, len(objFiles))
+	for i, objFile := range objFiles {
+		fp, xattrs, fileSize, err := getFile(objFile)
+		if _, ok := err.(quarantineFileError); ok {
+			hashDir := filepath.Dir(objFile)
+			rd.r.logger.Error("[syncBatch] Failed audit and is being quarantined",
+				zap.String("hashDir", hashDir),
+				zap.Error(err))
+			QuarantineHash(hashDir)
+			continue
+		} else if err != nil {
+			continue
+		}
+		data := make([]byte, fileSize)
+		_, err = io.ReadFull(fp, data)
+		fp.Close()
+		if err != nil {
+			return 0, insync, fmt.Errorf("Failed to read the full file: %s, %v", objFile, err)
+		}
+		lst := strings.Split(objFile, string(os.PathSeparator))
+		files = append(files, &batchFile{
+			index:               i,
+			relPath:             filepath.Join(lst[len(lst)-5:]...),
+			xattrs:              hex.EncodeToString(xattrs),
+			data:                data,
+			syncingRemoteRegion: map[int]bool{},
+		})
+	}
+	for _, sfa := range dst {
+		var batch []SyncFileRequest
+		var sent []*batchFile
+		for _, f := range files {
+			syncingRegion := f.syncingRemoteRegion[sfa.dev.Region]
+			if syncingRegion && !handoff {
+				continue
+			}
+			batch = append(batch, SyncFileRequest{
+				Path:   filepath.Join(sfa.dev.Device, f.relPath),
+				Xattrs: f.xattrs,
+				Size:   int64(len(f.data)),
+				// if we're already syncing handoffs to this remote region, just do a check
+				Check: syncingRegion,
+			})
+			sent = append(sent, f)
+		}
+		if len(batch) == 0 {
+			continue
+		}
+		var sbr SyncBatchResponse
+		if sfa.conn.SendMessage(SyncFileRequest{Batch: batch}) != nil || sfa.conn.RecvMessage(&sbr) != nil {
+			continue
+		}
+		if len(sbr.Files) != len(sent) {
+			rd.r.logger.Error("[syncBatch] Wrong number of responses", zap.Int("device id", sfa.dev.Id), zap.Int("sent", len(sent)), zap.Int("responses", len(sbr.Files)))
+			sfa.conn.Close()
+			continue
+		}
+		var wanted []*batchFile
+		var wantedBytes int64
+		for i, sfr := range sbr.Files {
+			f := sent[i]
+			if sfr.GoAhead || sfr.Synced {
+				if sfa.dev.Region != rd.dev.Region {
+					f.syncingRemoteRegion[sfa.dev.Region] = true
+				}
+			}
+			if sfr.GoAhead {
+				wanted = append(wanted, f)
+				wantedBytes += int64(len(f.data))
+			} else if sfr.Synced {
+				syncs++
+				insync[f.index]++
+				rd.UpdateStat("FilesSent", 1)
+			} else if sfr.NewerExists {
+				insync[f.index]++
+				if os.Remove(objFiles[f.index]) == nil {
+					InvalidateHash(filepath.Dir(objFiles[f.index]))
+				}
+			} else if sfr.Exists {
+				insync[f.index]++
+			}
+		}
+		if len(wanted) == 0 {
+			continue
+		}
+		rd.r.throttle.sent(rd.dev.Device, wantedBytes)
+		writeFailed := false
+		for _, f := range wanted {
+			if _, err := sfa.conn.Write(f.data); err != nil {
+				rd.r.logger.Error("Failed to write to remoteDevice",
+					zap.Int("device id", sfa.dev.Id),
+					zap.Error(err))
+				writeFailed = true
+				break
+			}
+		}
+		var fur FileUploadBatchResponse
+		if writeFailed || sfa.conn.Flush() != nil || sfa.conn.RecvMessage(&fur) != nil {
+			continue
+		}
+		if len(fur.Files) != len(wanted) {
+			sfa.conn.Close()
+			continue
+		}
+		for i, upload := range fur.Files {
+			if upload.Success {
+				syncs++
+				insync[wanted[i].index]++
+				rd.UpdateStat("FilesSent", 1)
+				rd.UpdateStat("BytesSent", int64(len(wanted[i].data)))
+			}
+		}
+	}
+	return syncs, insync, nil
+}
+
 func spaceWriter(w http.ResponseWriter, c chan struct{}, d chan struct{}) {
 	defer close(d)
 	for {
@@ -359,9 +583,23 @@ func (rd *swiftDevice) beginReplication(dev *ring.Device, partition string, hash
 	if headers == nil {
 		headers = map[string]string{}
 	}
+	// Connections with headers of their own, like priority replication's
+	// X-Force-Acquire, aren't shared with other partitions.
+	features := rd.r.repConnFeatures
+	if len(headers) == 0 {
+		if rc := rd.r.repConns.get(repConnKey(rd.dev.Device, dev, rd.policy)); rc != nil {
+			if rc.SendMessage(BeginReplicationRequest{Device: dev.Device, Partition: partition, NeedHashes: hashes}) == nil && rc.RecvMessage(&brr) == nil {
+				rChan <- beginReplicationResponse{dev: dev, conn: rc, hashes: brr.Hashes}
+				return
+			}
+			rc.Close()
+		}
+	} else {
+		features.persistent = false
+	}
 	headers["X-Trans-Id"] = fmt.Sprintf("%s-%d", common.UUID(), dev.Id)
 
-	if rc, err := NewRepConn(dev, partition, rd.policy, headers, rd.r.CertFile, rd.r.KeyFile, rd.r.rcTimeout); err != nil {
+	if rc, err := NewRepConn(dev, partition, rd.policy, headers, rd.r.CertFile, rd.r.KeyFile, rd.r.signingKey, rd.r.rcTimeout, features); err != nil {
 		rChan <- beginReplicationResponse{dev: dev, err: err}
 	} else if err := rc.SendMessage(BeginReplicationRequest{Device: dev.Device, Partition: partition, NeedHashes: hashes}); err != nil {
 		rChan <- beginReplicationResponse{dev: dev, err: err}
@@ -372,12 +610,60 @@ func (rd *swiftDevice) beginReplication(dev *ring.Device, partition string, hash
 	}
 }
 
+// releaseConns ends the partition on the connections in conns, keeping those
+// that can be reused for the next partition if done is set, and closing the
+// rest.
+func (rd *swiftDevice) releaseConns(conns map[int]RepConn, devs map[int]*ring.Device, done bool) {
+	for id, conn := range conns {
+		if done && !conn.Disconnected() {
+			conn.SendMessage(SyncFileRequest{Done: true})
+		}
+		if done && repConnFeaturesOf(conn).persistent && !conn.Disconnected() {
+			rd.r.repConns.put(repConnKey(rd.dev.Device, devs[id], rd.policy), conn)
+		} else {
+			conn.Close()
+		}
+	}
+}
+
+// batchable returns whether objFile is small enough to be synced in a batch,
+// and all of dst can take batches.
+func (rd *swiftDevice) batchable(objFile string, dst []*syncFileArg) bool {
+	if rd.r.syncBatchSize < 2 {
+		return false
+	}
+	for _, sfa := range dst {
+		if !repConnFeaturesOf(sfa.conn).batch {
+			return false
+		}
+	}
+	fi, err := os.Stat(objFile)
+	return err == nil && fi.Size() <= rd.r.syncBatchMaxFileSize
+}
+
+func sameSyncDevices(a, b []*syncFileArg) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].dev.Id != b[i].dev.Id {
+			return false
+		}
+	}
+	return true
+}
+
 func (rd *swiftDevice) replicateUsingHashes(rjob replJob, moreNodes ring.MoreNodes) (int64, error) {
 	path := filepath.Join(rd.r.deviceRoot, rd.dev.Device, PolicyDir(rd.policy), rjob.partition)
 	syncCount := int64(0)
 	startGetHashesRemote := time.Now()
 	remoteHashes := make(map[int]map[string]string)
 	remoteConnections := make(map[int]RepConn)
+	remoteDevs := make(map[int]*ring.Device)
+	finished := false
+	defer func() {
+		rd.releaseConns(remoteConnections, remoteDevs, finished)
+	}()
 	rChan := make(chan beginReplicationResponse)
 	for _, dev := range rjob.nodes {
 		go rd.i.beginReplication(dev, rjob.partition, true, rChan, rjob.headers)
@@ -385,9 +671,9 @@ func (rd *swiftDevice) replicateUsingHashes(rjob replJob, moreNodes ring.MoreNod
 	for i := 0; i < len(rjob.nodes); i++ {
 		rData := <-rChan
 		if rData.err == nil {
-			defer rData.conn.Close()
 			remoteHashes[rData.dev.Id] = rData.hashes
 			remoteConnections[rData.dev.Id] = rData.conn
+			remoteDevs[rData.dev.Id] = rData.dev
 		} else if rData.err == RepUnmountedError {
 			if nextNode := moreNodes.Next(); nextNode != nil {
 				go rd.i.beginReplication(nextNode, rjob.partition, true, rChan, rjob.headers)
@@ -437,6 +723,20 @@ func (rd *swiftDevice) replicateUsingHashes(rjob replJob, moreNodes ring.MoreNod
 		return false
 	})
 	startSyncing := time.Now()
+	var batch []string
+	var batchDst []*syncFileArg
+	flushBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		syncs, _, err := rd.i.syncBatch(batch, batchDst, false)
+		syncCount += int64(syncs)
+		batch = nil
+		if err != nil {
+			rd.r.logger.Error("[syncBatch]", zap.Error(err))
+		}
+		return err
+	}
 	for objFile := range objChan {
 		toSync := make([]*syncFileArg, 0)
 		suffix := filepath.Base(filepath.Dir(filepath.Dir(objFile)))
@@ -450,6 +750,21 @@ func (rd *swiftDevice) replicateUsingHashes(rjob replJob, moreNodes ring.MoreNod
 		if len(toSync) == 0 {
 			break
 		}
+		if rd.batchable(objFile, toSync) {
+			if !sameSyncDevices(batchDst, toSync) {
+				if err := flushBatch(); err != nil {
+					return syncCount, err
+				}
+			}
+			batch = append(batch, objFile)
+			batchDst = toSync
+			if len(batch) >= rd.r.syncBatchSize {
+				if err := flushBatch(); err != nil {
+					return syncCount, err
+				}
+			}
+			continue
+		}
 		if syncs, _, err := rd.i.syncFile(objFile, toSync, false); err == nil {
 			syncCount += int64(syncs)
 		} else {
@@ -457,11 +772,10 @@ func (rd *swiftDevice) replicateUsingHashes(rjob replJob, moreNodes ring.MoreNod
 			return syncCount, err
 		}
 	}
-	for _, conn := range remoteConnections {
-		if !conn.Disconnected() {
-			conn.SendMessage(SyncFileRequest{Done: true})
-		}
+	if err := flushBatch(); err != nil {
+		return syncCount, err
 	}
+	finished = true
 	timeSyncing := float64(time.Now().Sub(startSyncing)) / float64(time.Second)
 	if syncCount > 0 {
 		rd.r.logger.Info("[replicateUsingHashes]",
@@ -478,6 +792,11 @@ func (rd *swiftDevice) replicateAll(rjob replJob, isHandoff bool) (int64, error)
 	path := filepath.Join(rd.r.deviceRoot, rd.dev.Device, PolicyDir(rd.policy), rjob.partition)
 	syncCount := int64(0)
 	remoteConnections := make(map[int]RepConn)
+	remoteDevs := make(map[int]*ring.Device)
+	finished := false
+	defer func() {
+		rd.releaseConns(remoteConnections, remoteDevs, finished)
+	}()
 	rChan := make(chan beginReplicationResponse)
 	for _, dev := range rjob.nodes {
 		go rd.i.beginReplication(dev, rjob.partition, false, rChan, rjob.headers)
@@ -485,8 +804,8 @@ func (rd *swiftDevice) replicateAll(rjob replJob, isHandoff bool) (int64, error)
 	for i := 0; i < len(rjob.nodes); i++ {
 		rData := <-rChan
 		if rData.err == nil {
-			defer rData.conn.Close()
 			remoteConnections[rData.dev.Id] = rData.conn
+			remoteDevs[rData.dev.Id] = rData.dev
 		}
 	}
 	if len(remoteConnections) == 0 {
@@ -497,6 +816,34 @@ func (rd *swiftDevice) replicateAll(rjob replJob, isHandoff bool) (int64, error)
 	cancel := make(chan struct{})
 	defer close(cancel)
 	go rd.i.listObjFiles(objChan, cancel, path, func(string) bool { return true })
+	synced := func(objFile string, insync int) {
+		success := insync == len(rjob.nodes)
+		if rd.r.quorumDelete {
+			success = insync >= len(rjob.nodes)/2+1
+		}
+		if success && isHandoff {
+			os.Remove(objFile)
+			os.Remove(filepath.Dir(objFile))
+		}
+	}
+	var batch []string
+	var batchDst []*syncFileArg
+	flushBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		syncs, insync, err := rd.i.syncBatch(batch, batchDst, true)
+		if err != nil {
+			rd.r.logger.Error("[syncBatch]", zap.Error(err))
+			return err
+		}
+		syncCount += int64(syncs)
+		for i, objFile := range batch {
+			synced(objFile, insync[i])
+		}
+		batch = nil
+		return nil
+	}
 	for objFile := range objChan {
 		toSync := make([]*syncFileArg, 0)
 		for _, dev := range rjob.nodes {
@@ -507,27 +854,33 @@ func (rd *swiftDevice) replicateAll(rjob replJob, isHandoff bool) (int64, error)
 		if len(toSync) == 0 {
 			return 0, fmt.Errorf("replicateAll could get no remote connections to sync")
 		}
-		if syncs, insync, err := rd.i.syncFile(objFile, toSync, true); err == nil {
-			syncCount += int64(syncs)
-
-			success := insync == len(rjob.nodes)
-			if rd.r.quorumDelete {
-				success = insync >= len(rjob.nodes)/2+1
+		if rd.batchable(objFile, toSync) {
+			if !sameSyncDevices(batchDst, toSync) {
+				if err := flushBatch(); err != nil {
+					return syncCount, err
+				}
 			}
-			if success && isHandoff {
-				os.Remove(objFile)
-				os.Remove(filepath.Dir(objFile))
+			batch = append(batch, objFile)
+			batchDst = toSync
+			if len(batch) >= rd.r.syncBatchSize {
+				if err := flushBatch(); err != nil {
+					return syncCount, err
+				}
 			}
+			continue
+		}
+		if syncs, insync, err := rd.i.syncFile(objFile, toSync, true); err == nil {
+			syncCount += int64(syncs)
+			synced(objFile, insync)
 		} else {
 			rd.r.logger.Error("[syncFile]", zap.Error(err))
 			return syncCount, err
 		}
 	}
-	for _, conn := range remoteConnections {
-		if !conn.Disconnected() {
-			conn.SendMessage(SyncFileRequest{Done: true})
-		}
+	if err := flushBatch(); err != nil {
+		return syncCount, err
 	}
+	finished = true
 	if syncCount > 0 {
 		rd.r.logger.Info("[replicateAll]", zap.String("Partition", path), zap.Any("Files Synced", syncCount))
 	}
@@ -553,10 +906,8 @@ func (rd *swiftDevice) cleanTemp() {
 }
 
 func (rd *swiftDevice) replicatePartition(partition string) {
-	rd.r.replicateConcurrencySem <- struct{}{}
-	defer func() {
-		<-rd.r.replicateConcurrencySem
-	}()
+	rd.r.throttle.beginPartition(rd.dev.Device)
+	defer rd.r.throttle.endPartition(rd.dev.Device)
 	partitioni, err := strconv.ParseUint(partition, 10, 64)
 	if err != nil {
 		return
@@ -571,7 +922,7 @@ func (rd *swiftDevice) replicatePartition(partition string) {
 		!common.LooksTrue(policy.Config["cache_hash_dirs"])) {
 		rd.i.replicateAll(rjob, handoff)
 	} else {
-		rd.i.replicateUsingHashes(rjob, rd.r.objectRings[rd.policy].GetMoreNodes(partitioni))
+		rd.i.replicateUsingHashes(rjob, ring.FilterMoreNodes(rd.r.objectRings[rd.policy].GetMoreNodes(partitioni), rd.r.deviceFilter))
 	}
 	rd.UpdateStat("PartitionsDone", 1)
 }
@@ -608,6 +959,17 @@ func (rd *swiftDevice) listPartitions() ([]string, []string, error) {
 	return partitionList, handoffList, nil
 }
 
+// relinking reports whether the relinker is partway through a partition
+// power increase on this device. Replicating then would push the relinked
+// partitions, which look like handoffs under the old ring, off the device.
+func (rd *swiftDevice) relinking() bool {
+	if fs.Exists(relinkStatePath(filepath.Join(rd.r.deviceRoot, rd.dev.Device), rd.policy)) {
+		rd.r.logger.Info("[replicateDevice] Not replicating while the device is being relinked", zap.String("Device", rd.dev.Device))
+		return true
+	}
+	return false
+}
+
 func (rd *swiftDevice) Scan() {
 	defer srv.LogPanics(rd.r.logger, fmt.Sprintf("PANIC REPLICATING DEVICE: %s", rd.dev.Device))
 	rd.UpdateStat("startRun", 1)
@@ -618,6 +980,9 @@ func (rd *swiftDevice) Scan() {
 	if fs.Exists(filepath.Join(rd.r.deviceRoot, rd.dev.Device, "lock_device")) {
 		return
 	}
+	if rd.relinking() {
+		return
+	}
 
 	rd.i.cleanTemp()
 
@@ -646,6 +1011,9 @@ func (rd *swiftDevice) Scan() {
 			}
 		default:
 		}
+		if rd.relinking() {
+			return
+		}
 		rd.i.replicatePartition(partition)
 		if j := common.StringInSliceIndex(partition, handoffPartitions); j >= 0 {
 			handoffPartitions = append(handoffPartitions[:j], handoffPartitions[j+1:]...)
@@ -710,9 +1078,12 @@ type SwiftObject struct {
 	metaFile     string
 	workingClass string
 	metadata     map[string]string
-	reserve      int64
+	reserve      fs.Reserve
 	reclaimAge   int64
 	asyncWG      *sync.WaitGroup // Used to keep track of async goroutines
+	invalidator  *hashInvalidator
+	syncer       *fs.GroupSyncer
+	readahead    int64
 }
 
 // Metadata returns the object's metadata.
@@ -752,11 +1123,28 @@ func (o *SwiftObject) Copy(dsts ...io.Writer) (written int64, err error) {
 	}
 }
 
+// adviseRangeRead sets the kernel's readahead for a ranged read of f. A range
+// of at least threshold bytes, like a video player streaming through a file,
+// is read ahead further than usual; a shorter one is read without readahead,
+// so a small random read doesn't pull in data nobody asked for. A threshold
+// of 0 leaves readahead alone.
+func adviseRangeRead(f *os.File, start, end, threshold int64) {
+	if threshold <= 0 {
+		return
+	}
+	if end-start >= threshold {
+		fs.Fadvise(f, start, end-start, fs.AdviceSequential)
+	} else {
+		fs.Fadvise(f, start, end-start, fs.AdviceRandom)
+	}
+}
+
 // CopyRange copies data in the range of start to end from the underlying .data file to the writer.
 func (o *SwiftObject) CopyRange(w io.Writer, start int64, end int64) (int64, error) {
 	if _, err := o.file.Seek(start, os.SEEK_SET); err != nil {
 		return 0, err
 	}
+	adviseRangeRead(o.file, start, end, o.readahead)
 	return common.CopyN(o.file, end-start, w)
 }
 
@@ -772,7 +1160,11 @@ func (o *SwiftObject) Repr() string {
 
 func (o *SwiftObject) newFile(class string, size int64) (io.Writer, error) {
 	var err error
-	o.Close()
+	// Only the writer is replaced; the data file stays open until Close, so
+	// an APPEND can still copy the existing data into the new file.
+	if o.afw != nil {
+		o.afw.Abandon()
+	}
 	if o.afw, err = fs.NewAtomicFileWriter(o.tempDir, o.hashDir); err != nil {
 		return nil, fmt.Errorf("Error creating temp file: %v", err)
 	}
@@ -800,7 +1192,11 @@ func (o *SwiftObject) Commit(metadata map[string]string) error {
 		return fmt.Errorf("Error writing metadata: %v", err)
 	}
 	fileName := filepath.Join(o.hashDir, fmt.Sprintf("%s.%s", timestamp, o.workingClass))
-	o.afw.Save(fileName)
+	if o.syncer != nil {
+		o.syncer.Save(o.afw, fileName)
+	} else {
+		o.afw.Save(fileName)
+	}
 	o.asyncWG.Add(1)
 	go func() {
 		defer o.asyncWG.Done()
@@ -809,7 +1205,11 @@ func (o *SwiftObject) Commit(metadata map[string]string) error {
 			dir.Sync()
 			dir.Close()
 		}
-		InvalidateHash(o.hashDir)
+		if o.invalidator != nil {
+			o.invalidator.invalidate(o.hashDir)
+		} else {
+			InvalidateHash(o.hashDir)
+		}
 	}()
 	return nil
 }
@@ -849,15 +1249,20 @@ type SwiftEngine struct {
 	driveRoot      string
 	hashPathPrefix string
 	hashPathSuffix string
-	reserve        int64
+	reserve        fs.Reserve
 	reclaimAge     int64
 	policy         int
+	invalidator    *hashInvalidator
+	syncer         *fs.GroupSyncer
+	// readaheadThreshold is the smallest ranged GET that's read ahead
+	// sequentially; smaller ranges have readahead turned off.
+	readaheadThreshold int64
 }
 
 // New returns an instance of SwiftObject with the given parameters. Metadata is read in and if needData is true, the file is opened.  AsyncWG is a waitgroup if the object spawns any async operations
 func (f *SwiftEngine) New(vars map[string]string, needData bool, asyncWG *sync.WaitGroup) (Object, error) {
 	var err error
-	sor := &SwiftObject{reclaimAge: f.reclaimAge, reserve: f.reserve, asyncWG: asyncWG}
+	sor := &SwiftObject{reclaimAge: f.reclaimAge, reserve: f.reserve, asyncWG: asyncWG, invalidator: f.invalidator, syncer: f.syncer, readahead: f.readaheadThreshold}
 	sor.hashDir = ObjHashDir(vars, f.driveRoot, f.hashPathPrefix, f.hashPathSuffix, f.policy)
 	sor.tempDir = TempDirPath(f.driveRoot, vars["device"])
 	sor.dataFile, sor.metaFile = ObjectFiles(sor.hashDir)
@@ -914,19 +1319,30 @@ var replicationDone = fmt.Errorf("Replication done")
 // SwiftEngineConstructor creates a SwiftEngine given the object server configs.
 func SwiftEngineConstructor(config conf.Config, policy *conf.Policy, flags *flag.FlagSet) (ObjectEngine, error) {
 	driveRoot := config.GetDefault("app:object-server", "devices", "/srv/node")
-	reserve := config.GetInt("app:object-server", "fallocate_reserve", 0)
+	reserve, err := fs.ParseReserve(config.GetDefault("app:object-server", "fallocate_reserve", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid fallocate_reserve: %v", err)
+	}
 	hashPathPrefix, hashPathSuffix, err := conf.GetHashPrefixAndSuffix()
 	if err != nil {
 		return nil, errors.New("Unable to load hashpath prefix and suffix")
 	}
-	reclaimAge := int64(config.GetInt("app:object-server", "reclaim_age", int64(common.ONE_WEEK)))
-	return &SwiftEngine{
+	reclaimAge := objectReclaimAge(config)
+	engine := &SwiftEngine{
 		driveRoot:      driveRoot,
 		hashPathPrefix: hashPathPrefix,
 		hashPathSuffix: hashPathSuffix,
 		reserve:        reserve,
 		reclaimAge:     reclaimAge,
-		policy:         policy.Index}, nil
+		policy:         policy.Index}
+	engine.readaheadThreshold = config.GetInt("app:object-server", "range_readahead_threshold", 0)
+	if interval := config.GetFloat("app:object-server", "hash_invalidation_interval", 0); interval > 0 {
+		engine.invalidator = getHashInvalidator(time.Duration(interval * float64(time.Second)))
+	}
+	if window := config.GetFloat("app:object-server", "fsync_batch_window", 0); window > 0 {
+		engine.syncer = getGroupSyncer(time.Duration(window * float64(time.Second)))
+	}
+	return engine, nil
 }
 
 func init() {
@@ -44,6 +44,35 @@ func testObjectRoundtrip(swcon ObjectEngine, t *testing.T) {
 	require.Equal(t, "!", buf.String())
 }
 
+func TestSwiftObjectCopyRangeReadahead(t *testing.T) {
+	driveRoot, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(driveRoot)
+	swcon := &SwiftEngine{driveRoot: driveRoot, hashPathPrefix: "prefix", hashPathSuffix: "suffix", readaheadThreshold: 1024}
+	vars := map[string]string{"device": "sda", "account": "a", "container": "c", "object": "o", "partition": "1"}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	swo, err := swcon.New(vars, false, &wg)
+	require.Nil(t, err)
+	w, err := swo.SetData(int64(len(data)))
+	require.Nil(t, err)
+	w.Write(data)
+	require.Nil(t, swo.Commit(map[string]string{"Content-Length": "10000", "Content-Type": "text/plain", "X-Timestamp": "1234567890.123456"}))
+	swo.Close()
+
+	swo, err = swcon.New(vars, true, &wg)
+	require.Nil(t, err)
+	defer swo.Close()
+	for _, r := range [][2]int64{{5000, 10000}, {15, 25}, {0, 1024}} {
+		buf := &bytes.Buffer{}
+		n, err := swo.CopyRange(buf, r[0], r[1])
+		require.Nil(t, err)
+		require.Equal(t, r[1]-r[0], n)
+		require.Equal(t, data[r[0]:r[1]], buf.Bytes())
+	}
+}
+
 func TestSwiftObjectFailAuditContentLengthWrong(t *testing.T) {
 	driveRoot, err := ioutil.TempDir("", "")
 	require.Nil(t, err)
@@ -25,6 +25,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"context"
@@ -69,12 +71,17 @@ func (server *ObjectServer) expirerContainer(deleteAt time.Time, account, contai
 	return fmt.Sprintf("%010d", timestamp)
 }
 
-func (server *ObjectServer) sendContainerUpdate(ctx context.Context, scheme, host, device, method, partition, account, container, obj string, headers http.Header) bool {
-	obj_url := fmt.Sprintf("%s://%s/%s/%s/%s/%s/%s", scheme, host, device, partition,
+func (server *ObjectServer) sendContainerUpdate(ctx context.Context, scheme, host, pathPrefix, device, method, partition, account, container, obj string, headers http.Header) bool {
+	obj_url := fmt.Sprintf("%s://%s%s/%s/%s/%s/%s/%s", scheme, host, pathPrefix, device, partition,
 		common.Urlencode(account), common.Urlencode(container), common.Urlencode(obj))
 	if req, err := http.NewRequest(method, obj_url, nil); err == nil {
 		req = req.WithContext(ctx)
-		req.Header = headers
+		// Replicas are updated concurrently and the client may add headers
+		// of its own, like tracing's, so each request gets a copy.
+		req.Header = make(http.Header, len(headers))
+		for key, values := range headers {
+			req.Header[key] = append([]string(nil), values...)
+		}
 		if resp, err := server.updateClient.Do(req); err == nil {
 			resp.Body.Close()
 			if resp.StatusCode/100 == 2 {
@@ -121,6 +128,10 @@ func (server *ObjectServer) updateContainer(ctx context.Context, metadata map[st
 	for len(schemes) < len(hosts) {
 		schemes = append(schemes, "http")
 	}
+	pathPrefixes := splitHeader(request.Header.Get("X-Container-Path-Prefix"))
+	for len(pathPrefixes) < len(hosts) {
+		pathPrefixes = append(pathPrefixes, "")
+	}
 	requestHeaders := http.Header{
 		"X-Backend-Storage-Policy-Index": {common.GetDefault(request.Header, "X-Backend-Storage-Policy-Index", "0")},
 		"Referer":                        {common.GetDefault(request.Header, "Referer", "-")},
@@ -129,22 +140,55 @@ func (server *ObjectServer) updateContainer(ctx context.Context, metadata map[st
 		"X-Timestamp":                    request.Header["X-Timestamp"],
 		"X-Delete-At":                    request.Header["X-Delete-At"],
 	}
-	if request.Method != "DELETE" {
+	// An append leaves a whole new object behind, and a POST changing the
+	// Content-Type relists it, so both are listed like a PUT.
+	method := request.Method
+	if method == "APPEND" || method == "POST" {
+		method = "PUT"
+	}
+	if method != "DELETE" {
 		requestHeaders.Add("X-Content-Type", metadata["Content-Type"])
-		requestHeaders.Add("X-Size", metadata["Content-Length"])
-		requestHeaders.Add("X-Etag", metadata["ETag"])
+		size, etag := metadata["Content-Length"], metadata["ETag"]
+		if override, ok := metadata["X-Object-Sysmeta-Container-Update-Override-Size"]; ok {
+			size = override
+		}
+		if override, ok := metadata["X-Object-Sysmeta-Container-Update-Override-Etag"]; ok {
+			etag = override
+		}
+		requestHeaders.Add("X-Size", size)
+		requestHeaders.Add("X-Etag", etag)
 	}
-	failures := 0
+	// New objects in a nursery engine are listed as such until the
+	// stabilizer says otherwise; see nurseryDevice.updateStable.
+	if method == "PUT" {
+		if _, ok := server.objEngines[requestPolicy(request)].(NurseryObjectEngine); ok {
+			requestHeaders.Set("X-Backend-Object-State", "nursery")
+		}
+	}
+	// Each container replica is updated concurrently, bounded by the update
+	// client's timeout, and a single async pending covers any that failed.
+	var failures int32
+	var wg sync.WaitGroup
 	for index := range hosts {
-		if !server.sendContainerUpdate(ctx, schemes[index], hosts[index], devices[index], request.Method, partition, vars["account"], vars["container"], vars["obj"], requestHeaders) {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			if server.sendContainerUpdate(ctx, schemes[index], hosts[index], pathPrefixes[index], devices[index], method, partition, vars["account"], vars["container"], vars["obj"], requestHeaders) {
+				server.metricsScope.Tagged(map[string]string{"result": "success"}).Counter("container_updates").Inc(1)
+				return
+			}
 			logger.Error("ERROR container update failed (saving for async update later)",
 				zap.String("Host", hosts[index]),
 				zap.String("Device", devices[index]))
-			failures++
-		}
+			server.metricsScope.Tagged(map[string]string{"result": "failure"}).Counter("container_updates").Inc(1)
+			atomic.AddInt32(&failures, 1)
+		}(index)
 	}
+	wg.Wait()
 	if failures > 0 {
-		server.saveAsync(request.Method, vars["account"], vars["container"], vars["obj"], vars["device"], requestHeaders, logger)
+		server.saveAsync(method, vars["account"], vars["container"], vars["obj"], vars["device"], requestHeaders, logger)
+		server.metricsScope.Counter("async_pendings").Inc(1)
+		server.statsd.Increment("async_pendings")
 	}
 }
 
@@ -21,14 +21,20 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common"
 	"github.com/troubling/hummingbird/common/fs"
 	"github.com/troubling/hummingbird/common/pickle"
 	"github.com/troubling/hummingbird/common/srv"
 	"github.com/troubling/hummingbird/common/test"
+	"github.com/uber-go/tally"
 	"go.uber.org/zap"
 )
 
@@ -114,6 +120,49 @@ func TestUpdateContainer(t *testing.T) {
 	require.Equal(t, asyncData["obj"], "o")
 }
 
+func TestUpdateContainerOverride(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	ts, err := makeObjectServer(confLoader)
+	require.Nil(t, err)
+	server := ts.objServer
+	defer ts.Close()
+
+	state := ""
+	if _, ok := server.objEngines[0].(NurseryObjectEngine); ok {
+		state = "nursery"
+	}
+	requestSent := false
+	cs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "100", r.Header.Get("X-Size"))
+		require.Equal(t, "00000000000000000000000000000000", r.Header.Get("X-Etag"))
+		require.Equal(t, state, r.Header.Get("X-Backend-Object-State"))
+		requestSent = true
+	}))
+	defer cs.Close()
+	u, err := url.Parse(cs.URL)
+	require.Nil(t, err)
+	req, err := http.NewRequest("PUT", "/I/dont/think/this/matters", nil)
+	require.Nil(t, err)
+	req.Header.Add("X-Container-Partition", "1")
+	req.Header.Add("X-Container-Host", u.Host)
+	req.Header.Add("X-Container-Device", "sdb")
+	req.Header.Add("X-Timestamp", "12345.6789")
+
+	vars := map[string]string{"account": "a", "container": "c", "obj": "o", "device": "sda"}
+	req = srv.SetVars(req, vars)
+	metadata := map[string]string{
+		"X-Timestamp":    "12345.789",
+		"Content-Type":   "text/plain",
+		"Content-Length": "30",
+		"ETag":           "ffffffffffffffffffffffffffffffff",
+		"X-Object-Sysmeta-Container-Update-Override-Size": "100",
+		"X-Object-Sysmeta-Container-Update-Override-Etag": "00000000000000000000000000000000",
+	}
+	server.updateContainer(req.Context(), metadata, req, vars, zap.NewNop())
+	require.True(t, requestSent)
+}
+
 func TestUpdateContainerNoHeaders(t *testing.T) {
 	testRing := &test.FakeRing{}
 	confLoader := srv.NewTestConfigLoader(testRing)
@@ -150,3 +199,132 @@ func TestUpdateContainerNoHeaders(t *testing.T) {
 	expectedFile := filepath.Join(ts.root, "sda", "async_pending", "099", "2f714cd91b0e5d803cde2012b01d7099-12345.6789")
 	require.False(t, fs.Exists(expectedFile))
 }
+
+func TestUpdateContainerConcurrentFailure(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	ts, err := makeObjectServer(confLoader)
+	require.Nil(t, err)
+	server := ts.objServer
+	defer ts.Close()
+	server.hashPathPrefix = ""
+	server.hashPathSuffix = "changeme"
+	scope := tally.NewTestScope("", nil)
+	server.metricsScope = scope
+
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer good.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+	gu, err := url.Parse(good.URL)
+	require.Nil(t, err)
+	bu, err := url.Parse(bad.URL)
+	require.Nil(t, err)
+	req, err := http.NewRequest("PUT", "/I/dont/think/this/matters", nil)
+	require.Nil(t, err)
+	req.Header.Add("X-Container-Partition", "1")
+	req.Header.Add("X-Container-Host", gu.Host+","+bu.Host)
+	req.Header.Add("X-Container-Device", "sdb,sdc")
+	req.Header.Add("X-Timestamp", "12345.6789")
+
+	vars := map[string]string{"account": "a", "container": "c", "obj": "o", "device": "sda"}
+	req = srv.SetVars(req, vars)
+	metadata := map[string]string{
+		"X-Timestamp":    "12345.789",
+		"Content-Type":   "text/plain",
+		"Content-Length": "30",
+		"ETag":           "ffffffffffffffffffffffffffffffff",
+	}
+	server.updateContainer(req.Context(), metadata, req, vars, zap.NewNop())
+	expectedFile := filepath.Join(ts.root, "sda", "async_pending", "099", "2f714cd91b0e5d803cde2012b01d7099-12345.6789")
+	require.True(t, fs.Exists(expectedFile))
+	counters := scope.Snapshot().Counters()
+	require.Equal(t, int64(1), counters["container_updates+result=success"].Value())
+	require.Equal(t, int64(1), counters["container_updates+result=failure"].Value())
+	require.Equal(t, int64(1), counters["async_pendings+"].Value())
+}
+
+type headerWritingClient struct {
+	common.HTTPClient
+}
+
+func (c *headerWritingClient) Do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Uber-Trace-Id", "whatever")
+	return c.HTTPClient.Do(req)
+}
+
+func TestUpdateContainerHeadersPerRequest(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	ts, err := makeObjectServer(confLoader)
+	require.Nil(t, err)
+	server := ts.objServer
+	defer ts.Close()
+	server.updateClient = &headerWritingClient{HTTPClient: http.DefaultClient}
+
+	var updates int32
+	cs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "12345.6789", r.Header.Get("X-Timestamp"))
+		atomic.AddInt32(&updates, 1)
+	}))
+	defer cs.Close()
+	u, err := url.Parse(cs.URL)
+	require.Nil(t, err)
+	req, err := http.NewRequest("PUT", "/I/dont/think/this/matters", nil)
+	require.Nil(t, err)
+	req.Header.Add("X-Container-Partition", "1")
+	req.Header.Add("X-Container-Host", strings.Repeat(u.Host+",", 7)+u.Host)
+	req.Header.Add("X-Container-Device", "sdb,sdc,sdd,sde,sdf,sdg,sdh,sdi")
+	req.Header.Add("X-Timestamp", "12345.6789")
+	vars := map[string]string{"account": "a", "container": "c", "obj": "o", "device": "sda"}
+	req = srv.SetVars(req, vars)
+	metadata := map[string]string{
+		"X-Timestamp":    "12345.789",
+		"Content-Type":   "text/plain",
+		"Content-Length": "30",
+		"ETag":           "ffffffffffffffffffffffffffffffff",
+	}
+	// With -race, the client writing to a shared header map is caught here.
+	server.updateContainer(req.Context(), metadata, req, vars, zap.NewNop())
+	require.Equal(t, int32(8), atomic.LoadInt32(&updates))
+}
+
+func TestUpdateContainerPathPrefix(t *testing.T) {
+	testRing := &test.FakeRing{}
+	confLoader := srv.NewTestConfigLoader(testRing)
+	ts, err := makeObjectServer(confLoader)
+	require.Nil(t, err)
+	server := ts.objServer
+	defer ts.Close()
+
+	var paths []string
+	var lock sync.Mutex
+	cs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		paths = append(paths, r.URL.Path)
+		lock.Unlock()
+	}))
+	defer cs.Close()
+	u, err := url.Parse(cs.URL)
+	require.Nil(t, err)
+	req, err := http.NewRequest("PUT", "/I/dont/think/this/matters", nil)
+	require.Nil(t, err)
+	req.Header.Add("X-Container-Partition", "1")
+	req.Header.Add("X-Container-Host", u.Host+","+u.Host)
+	req.Header.Add("X-Container-Device", "sdb,sdc")
+	req.Header.Add("X-Container-Path-Prefix", "/storage")
+	req.Header.Add("X-Timestamp", "12345.6789")
+	vars := map[string]string{"account": "a", "container": "c", "obj": "o", "device": "sda"}
+	req = srv.SetVars(req, vars)
+	metadata := map[string]string{
+		"X-Timestamp":    "12345.789",
+		"Content-Type":   "text/plain",
+		"Content-Length": "30",
+		"ETag":           "ffffffffffffffffffffffffffffffff",
+	}
+	server.updateContainer(req.Context(), metadata, req, vars, zap.NewNop())
+	sort.Strings(paths)
+	require.Equal(t, []string{"/sdc/1/a/c/o", "/storage/sdb/1/a/c/o"}, paths)
+}
@@ -37,6 +37,9 @@ import (
 
 const asyncPendingSleep = 10 * time.Millisecond
 
+// asyncProgressInterval is how often an updater pass saves its progress.
+const asyncProgressInterval = time.Minute
+
 type asyncPending struct {
 	Headers   map[string]string `pickle:"headers"`
 	Object    string            `pickle:"obj"`
@@ -53,6 +56,8 @@ type updateDevice struct {
 	lastReconDump time.Time
 	reconLock     sync.Mutex
 	reconRunning  bool
+	progress      *middleware.ProgressReporter
+	lastProgress  time.Time
 }
 
 func (ud *updateDevice) updateStat(stat string, amount int64) {
@@ -86,20 +91,26 @@ func (ud *updateDevice) listAsyncs(c chan string, cancel chan struct{}) {
 }
 
 func (ud *updateDevice) updateContainers(ap *asyncPending) bool {
-	successes := uint64(0)
-	part := ud.r.containerRing.GetPartition(ap.Account, ap.Container, "")
 	header := common.Map2Headers(ap.Headers)
 	header.Set("User-Agent", fmt.Sprintf("object-updater %d", os.Getpid()))
-	for _, node := range ud.r.containerRing.GetNodes(part) {
-		objUrl := fmt.Sprintf("%s://%s:%d/%s/%d/%s/%s/%s", node.Scheme, node.Ip, node.Port, node.Device, part,
-			common.Urlencode(ap.Account), common.Urlencode(ap.Container), common.Urlencode(ap.Object))
-		req, err := http.NewRequest(ap.Method, objUrl, nil)
+	return ud.r.updateContainers(ap.Method, ap.Account, ap.Container, ap.Object, header)
+}
+
+// updateContainers sends a container update to each of the object's
+// container nodes, returning whether a quorum of them took it.
+func (r *Replicator) updateContainers(method, account, container, obj string, header http.Header) bool {
+	successes := uint64(0)
+	part := r.containerRing.GetPartition(account, container, "")
+	for _, node := range r.containerRing.GetNodes(part) {
+		objUrl := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s/%s", node.Scheme, node.Ip, node.Port, node.PathPrefix, node.Device, part,
+			common.Urlencode(account), common.Urlencode(container), common.Urlencode(obj))
+		req, err := http.NewRequest(method, objUrl, nil)
 		if err != nil {
-			ud.r.logger.Error("updateContainers creating new request", zap.Error(err))
+			r.logger.Error("updateContainers creating new request", zap.Error(err))
 			continue
 		}
 		req.Header = header
-		resp, err := ud.r.client.Do(req)
+		resp, err := r.client.Do(req)
 		if err != nil {
 			continue
 		}
@@ -108,19 +119,22 @@ func (ud *updateDevice) updateContainers(ap *asyncPending) bool {
 			successes++
 		}
 	}
-	return successes >= (ud.r.containerRing.ReplicaCount()/2)+1
+	return successes >= (r.containerRing.ReplicaCount()/2)+1
 }
 
 func (ud *updateDevice) processAsync(async string) {
+	ud.progress.Processed(1)
 	data, err := ioutil.ReadFile(async)
 	if err != nil {
 		ud.updateStat("Error", 1)
+		ud.progress.Failed(1)
 		ud.r.logger.Error("read async_pending fail", zap.String("file", async), zap.Error(err))
 		return
 	}
 	var ap asyncPending
 	if err := pickle.Unmarshal(data, &ap); err != nil {
 		ud.updateStat("Error", 1)
+		ud.progress.Failed(1)
 		ud.r.logger.Error("unmarshal async_pending fail", zap.String("file", async), zap.Error(err))
 		return
 	}
@@ -130,6 +144,7 @@ func (ud *updateDevice) processAsync(async string) {
 		os.Remove(filepath.Dir(async))
 	} else {
 		ud.updateStat("Failure", 1)
+		ud.progress.Failed(1)
 	}
 }
 
@@ -169,6 +184,8 @@ func (ud *updateDevice) reconReportAsync() {
 
 func (ud *updateDevice) update() {
 	ud.updateStat("startRun", 1)
+	ud.progress.StartCycle(0)
+	ud.lastProgress = time.Now()
 	if ud.lastReconDump.IsZero() || time.Since(ud.lastReconDump) > time.Hour {
 		ud.lastReconDump = time.Now()
 		go ud.reconReportAsync()
@@ -195,8 +212,17 @@ func (ud *updateDevice) update() {
 			ud.lastReconDump = time.Now()
 			go ud.reconReportAsync()
 		}
+		if time.Since(ud.lastProgress) > asyncProgressInterval {
+			ud.lastProgress = time.Now()
+			if err := ud.progress.Report(); err != nil {
+				ud.r.logger.Error("object-updater saving progress", zap.Error(err))
+			}
+		}
 	}
 	ud.updateStat("PassComplete", 1)
+	if err := ud.progress.EndCycle(); err != nil {
+		ud.r.logger.Error("object-updater saving progress", zap.Error(err))
+	}
 }
 
 func (ud *updateDevice) updateLoop() {
@@ -218,9 +244,10 @@ func (ud *updateDevice) cancel() {
 
 func newUpdateDevice(dev *ring.Device, policy int, r *Replicator) *updateDevice {
 	return &updateDevice{
-		policy:  policy,
-		dev:     dev,
-		r:       r,
-		canchan: make(chan struct{}),
+		policy:   policy,
+		dev:      dev,
+		r:        r,
+		canchan:  make(chan struct{}),
+		progress: middleware.NewProgressReporter(r.reconCachePath, "object", "object-updater-"+deviceKeyId(dev.Device, policy), r.metricsScope),
 	}
 }
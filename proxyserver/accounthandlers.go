@@ -18,6 +18,7 @@ package proxyserver
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/troubling/hummingbird/common"
@@ -25,6 +26,32 @@ import (
 	"github.com/troubling/hummingbird/proxyserver/middleware"
 )
 
+// maxContainersHeader lets a reseller admin give an account its own
+// container limit, overriding max_containers_per_account; an empty value
+// removes the override and 0 makes the account unlimited.
+const maxContainersHeader = "X-Account-Max-Containers"
+
+// setMaxContainers stores the request's X-Account-Max-Containers as the
+// account's Max-Containers sysmeta, returning a status other than 200 and a
+// message if the request may not set it.
+func setMaxContainers(ctx *middleware.ProxyContext, request *http.Request) (int, string) {
+	value, ok := request.Header[maxContainersHeader]
+	if !ok {
+		return http.StatusOK, ""
+	}
+	request.Header.Del(maxContainersHeader)
+	if !ctx.ResellerRequest {
+		return http.StatusForbidden, "Only a reseller admin may set " + maxContainersHeader
+	}
+	if value[0] != "" {
+		if n, err := strconv.ParseInt(value[0], 10, 64); err != nil || n < 0 {
+			return http.StatusBadRequest, "Invalid " + maxContainersHeader
+		}
+	}
+	request.Header.Set("X-Account-Sysmeta-Max-Containers", value[0])
+	return http.StatusOK, ""
+}
+
 func (server *ProxyServer) AccountGetHandler(writer http.ResponseWriter, request *http.Request) {
 	vars := srv.GetVars(request)
 	ctx := middleware.GetProxyContext(request)
@@ -47,7 +74,7 @@ func (server *ProxyServer) AccountGetHandler(writer http.ResponseWriter, request
 		}
 	}
 	resp := ctx.C.GetAccountRaw(request.Context(), vars["account"], options, request.Header)
-	if resp.StatusCode == http.StatusNotFound && server.accountAutoCreate &&
+	if resp.StatusCode == http.StatusNotFound && server.autoCreates(vars["account"]) &&
 		resp.Header.Get("X-Backend-Delete-Timestamp") == "" {
 		resp.Body.Close()
 		ctx.AutoCreateAccount(request.Context(), vars["account"], request.Header)
@@ -76,20 +103,33 @@ func (server *ProxyServer) AccountHeadHandler(writer http.ResponseWriter, reques
 			return
 		}
 	}
+	if server.useHeadCache(ctx, request) {
+		if header := cachedAccountHeader(ctx, request, vars["account"]); header != nil {
+			server.writeAccountHead(writer, ctx, header, http.StatusNoContent)
+			return
+		}
+	}
 	resp := ctx.C.HeadAccount(request.Context(), vars["account"], request.Header)
-	if resp.StatusCode == http.StatusNotFound && server.accountAutoCreate &&
+	if resp.StatusCode == http.StatusNotFound && server.autoCreates(vars["account"]) &&
 		resp.Header.Get("X-Backend-Delete-Timestamp") == "" {
 		resp.Body.Close()
 		ctx.AutoCreateAccount(request.Context(), vars["account"], request.Header)
 		resp = ctx.C.HeadAccount(request.Context(), vars["account"], request.Header)
 	}
-	for k := range resp.Header {
+	resp.Body.Close()
+	server.writeAccountHead(writer, ctx, resp.Header, resp.StatusCode)
+}
+
+func (server *ProxyServer) writeAccountHead(writer http.ResponseWriter, ctx *middleware.ProxyContext, header http.Header, status int) {
+	for k := range header {
 		if !common.OwnerHeaders[strings.ToLower(k)] || ctx.StorageOwner {
-			writer.Header().Set(k, resp.Header.Get(k))
+			writer.Header().Set(k, header.Get(k))
 		}
 	}
-	resp.Body.Close()
-	writer.WriteHeader(resp.StatusCode)
+	if server.headCacheControl != "" && status/100 == 2 {
+		writer.Header().Set("Cache-Control", server.headCacheControl)
+	}
+	writer.WriteHeader(status)
 }
 
 func (server *ProxyServer) AccountPostHandler(writer http.ResponseWriter, request *http.Request) {
@@ -111,6 +151,10 @@ func (server *ProxyServer) AccountPostHandler(writer http.ResponseWriter, reques
 		writer.Write([]byte(fmt.Sprintf("<html><h1>%s</h1><p>%s</p></html>", http.StatusText(status), str)))
 		return
 	}
+	if status, str := setMaxContainers(ctx, request); status != http.StatusOK {
+		srv.SimpleErrorResponse(writer, status, str)
+		return
+	}
 	for k := range request.Header {
 		if common.OwnerHeaders[strings.ToLower(k)] && !ctx.StorageOwner {
 			request.Header.Del(k)
@@ -122,7 +166,7 @@ func (server *ProxyServer) AccountPostHandler(writer http.ResponseWriter, reques
 	}
 	defer ctx.InvalidateAccountInfo(request.Context(), vars["account"])
 	resp := ctx.C.PostAccount(request.Context(), vars["account"], request.Header)
-	if resp.StatusCode == http.StatusNotFound && server.accountAutoCreate &&
+	if resp.StatusCode == http.StatusNotFound && server.autoCreates(vars["account"]) &&
 		resp.Header.Get("X-Backend-Delete-Timestamp") == "" {
 		resp.Body.Close()
 		ctx.AutoCreateAccount(request.Context(), vars["account"], request.Header)
@@ -151,6 +195,10 @@ func (server *ProxyServer) AccountPutHandler(writer http.ResponseWriter, request
 		writer.Write([]byte(fmt.Sprintf("<html><h1>%s</h1><p>%s</p></html>", http.StatusText(status), str)))
 		return
 	}
+	if status, str := setMaxContainers(ctx, request); status != http.StatusOK {
+		srv.SimpleErrorResponse(writer, status, str)
+		return
+	}
 	for k := range request.Header {
 		if common.OwnerHeaders[strings.ToLower(k)] && !ctx.StorageOwner {
 			request.Header.Del(k)
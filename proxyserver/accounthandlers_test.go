@@ -0,0 +1,54 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package proxyserver
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/proxyserver/middleware"
+)
+
+func TestSetMaxContainers(t *testing.T) {
+	for _, tc := range []struct {
+		reseller bool
+		value    string
+		status   int
+	}{
+		{true, "100", http.StatusOK},
+		{true, "", http.StatusOK},
+		{false, "100", http.StatusForbidden},
+		{true, "-1", http.StatusBadRequest},
+		{true, "lots", http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest("POST", "/v1/AUTH_test", nil)
+		require.Nil(t, err)
+		req.Header.Set("X-Account-Max-Containers", tc.value)
+		status, _ := setMaxContainers(&middleware.ProxyContext{ResellerRequest: tc.reseller}, req)
+		require.Equal(t, tc.status, status)
+		require.Equal(t, "", req.Header.Get("X-Account-Max-Containers"))
+		if status == http.StatusOK {
+			require.Equal(t, []string{tc.value}, req.Header["X-Account-Sysmeta-Max-Containers"])
+		}
+	}
+
+	req, err := http.NewRequest("POST", "/v1/AUTH_test", nil)
+	require.Nil(t, err)
+	status, _ := setMaxContainers(&middleware.ProxyContext{}, req)
+	require.Equal(t, http.StatusOK, status)
+	require.Nil(t, req.Header["X-Account-Sysmeta-Max-Containers"])
+}
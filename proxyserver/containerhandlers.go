@@ -18,8 +18,10 @@ package proxyserver
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/troubling/hummingbird/client"
 	"github.com/troubling/hummingbird/common"
 	"github.com/troubling/hummingbird/common/srv"
 	"github.com/troubling/hummingbird/proxyserver/middleware"
@@ -55,6 +57,43 @@ func (server *ProxyServer) ContainerGetHandler(writer http.ResponseWriter, reque
 			}
 		}
 	}
+	if common.LooksTrue(request.Form.Get("watch")) {
+		ci, err := ctx.C.GetContainerInfo(request.Context(), vars["account"], vars["container"])
+		if err == nil {
+			ctx.ACL = ci.ReadACL
+		}
+		if ctx.Authorize != nil {
+			if ok, s := ctx.Authorize(request); !ok {
+				srv.StandardResponse(writer, s)
+				return
+			}
+		}
+		if err == client.ContainerNotFound {
+			srv.StandardResponse(writer, http.StatusNotFound)
+			return
+		} else if err != nil {
+			srv.StandardResponse(writer, http.StatusInternalServerError)
+			return
+		}
+		server.containerWatch(writer, request, vars["account"], vars["container"], ci.StoragePolicyIndex)
+		return
+	}
+	// verbose=backend adds each object's storage policy and state to the
+	// listing, for reseller admins only; they don't need the container's ACL,
+	// so it's fine to authorize before fetching it.
+	if request.Form.Get("verbose") == "backend" {
+		if ctx.Authorize != nil {
+			if ok, s := ctx.Authorize(request); !ok {
+				srv.StandardResponse(writer, s)
+				return
+			}
+		}
+		if !ctx.ResellerRequest {
+			srv.StandardResponse(writer, http.StatusForbidden)
+			return
+		}
+		options["verbose"] = "backend"
+	}
 	resp := ctx.C.GetContainerRaw(request.Context(), vars["account"], vars["container"], options, request.Header)
 	defer resp.Body.Close()
 	ctx.C.SetContainerInfo(request.Context(), vars["account"], vars["container"], resp)
@@ -85,22 +124,33 @@ func (server *ProxyServer) ContainerHeadHandler(writer http.ResponseWriter, requ
 		srv.StandardResponse(writer, 404)
 		return
 	}
-	resp := ctx.C.HeadContainer(request.Context(), vars["account"], vars["container"], request.Header)
-	resp.Body.Close()
-	ctx.C.SetContainerInfo(request.Context(), vars["account"], vars["container"], resp)
-	ctx.ACL = resp.Header.Get("X-Container-Read")
+	var header http.Header
+	status := http.StatusNoContent
+	if server.useHeadCache(ctx, request) {
+		header = server.cachedContainerHeader(ctx, request, vars["account"], vars["container"])
+	}
+	if header == nil {
+		resp := ctx.C.HeadContainer(request.Context(), vars["account"], vars["container"], request.Header)
+		resp.Body.Close()
+		ctx.C.SetContainerInfo(request.Context(), vars["account"], vars["container"], resp)
+		header, status = resp.Header, resp.StatusCode
+	}
+	ctx.ACL = header.Get("X-Container-Read")
 	if ctx.Authorize != nil {
 		if ok, s := ctx.Authorize(request); !ok {
 			srv.StandardResponse(writer, s)
 			return
 		}
 	}
-	for k := range resp.Header {
+	for k := range header {
 		if !common.OwnerHeaders[strings.ToLower(k)] || ctx.StorageOwner {
-			writer.Header().Set(k, resp.Header.Get(k))
+			writer.Header().Set(k, header.Get(k))
 		}
 	}
-	writer.WriteHeader(resp.StatusCode)
+	if server.headCacheControl != "" && status/100 == 2 {
+		writer.Header().Set("Cache-Control", server.headCacheControl)
+	}
+	writer.WriteHeader(status)
 }
 
 func (server *ProxyServer) ContainerPostHandler(writer http.ResponseWriter, request *http.Request) {
@@ -144,6 +194,18 @@ func (server *ProxyServer) ContainerPostHandler(writer http.ResponseWriter, requ
 	srv.StandardResponse(writer, resp.StatusCode)
 }
 
+// containerLimit returns how many containers the account may have, or 0 if
+// it may have any number.
+func (server *ProxyServer) containerLimit(account string, ai *middleware.AccountInfo) int64 {
+	if limit, err := strconv.ParseInt(ai.SysMetadata["Max-Containers"], 10, 64); err == nil && limit >= 0 {
+		return limit
+	}
+	if server.maxContainersWhitelist[account] {
+		return 0
+	}
+	return server.maxContainers
+}
+
 func (server *ProxyServer) ContainerPutHandler(writer http.ResponseWriter, request *http.Request) {
 	vars := srv.GetVars(request)
 	ctx := middleware.GetProxyContext(request)
@@ -161,11 +223,11 @@ func (server *ProxyServer) ContainerPutHandler(writer http.ResponseWriter, reque
 			return
 		}
 	}
-	_, err := ctx.GetAccountInfo(request.Context(), vars["account"])
+	ai, err := ctx.GetAccountInfo(request.Context(), vars["account"])
 	if err != nil {
-		if server.accountAutoCreate {
+		if server.autoCreates(vars["account"]) {
 			ctx.AutoCreateAccount(request.Context(), vars["account"], request.Header)
-			_, err = ctx.GetAccountInfo(request.Context(), vars["account"])
+			ai, err = ctx.GetAccountInfo(request.Context(), vars["account"])
 		}
 	}
 	if err != nil {
@@ -178,6 +240,13 @@ func (server *ProxyServer) ContainerPutHandler(writer http.ResponseWriter, reque
 		writer.Write([]byte(fmt.Sprintf("<html><h1>%s</h1><p>%s</p></html>", http.StatusText(status), str)))
 		return
 	}
+	if limit := server.containerLimit(vars["account"], ai); limit > 0 && ai.ContainerCount >= limit {
+		// PUTs to containers that already exist don't add to the count.
+		if _, err := ctx.C.GetContainerInfo(request.Context(), vars["account"], vars["container"]); err == client.ContainerNotFound {
+			srv.SimpleErrorResponse(writer, http.StatusForbidden, fmt.Sprintf("Reached container limit of %d", limit))
+			return
+		}
+	}
 	for k := range request.Header {
 		if common.OwnerHeaders[strings.ToLower(k)] && !ctx.StorageOwner {
 			request.Header.Del(k)
@@ -214,6 +283,26 @@ func (server *ProxyServer) ContainerDeleteHandler(writer http.ResponseWriter, re
 	srv.StandardResponse(writer, resp.StatusCode)
 }
 
+// ContainerRestoreHandler brings back a container deleted within the
+// container servers' restore_window, along with its metadata. It's an
+// operator endpoint, so it isn't authorized like the /v1 handlers.
+func (server *ProxyServer) ContainerRestoreHandler(writer http.ResponseWriter, request *http.Request) {
+	vars := srv.GetVars(request)
+	ctx := middleware.GetProxyContext(request)
+	if ctx == nil {
+		server.logger.Error("could not get proxy context")
+		srv.StandardResponse(writer, 500)
+		return
+	}
+	headers := http.Header{
+		"X-Timestamp": {request.Header.Get("X-Timestamp")},
+		"X-Trans-Id":  {request.Header.Get("X-Trans-Id")},
+	}
+	resp := ctx.C.RestoreContainer(request.Context(), vars["account"], vars["container"], headers)
+	resp.Body.Close()
+	srv.StandardResponse(writer, resp.StatusCode)
+}
+
 func cleanACLs(r *http.Request) error {
 	for _, header := range []string{"X-Container-Read", "X-Container-Write"} {
 		if r.Header.Get(header) != "" {
@@ -235,7 +324,7 @@ func setVary(writer http.ResponseWriter, h string) {
 	}
 }
 
-var publicMethods = []string{"HEAD", "GET", "PUT", "POST", "OPTIONS", "DELETE", "COPY"}
+var publicMethods = []string{"HEAD", "GET", "PUT", "POST", "OPTIONS", "DELETE", "COPY", "APPEND"}
 
 func (server *ProxyServer) OptionsHandler(writer http.ResponseWriter, request *http.Request) {
 	vars := srv.GetVars(request)
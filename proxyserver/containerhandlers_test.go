@@ -26,6 +26,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"github.com/troubling/hummingbird/client"
+	"github.com/troubling/hummingbird/client/clienttest"
 	"github.com/troubling/hummingbird/common/conf"
 	"github.com/troubling/hummingbird/common/srv"
 	"github.com/troubling/hummingbird/common/test"
@@ -127,3 +128,39 @@ func TestOptionsHandlerNotSetup(t *testing.T) {
 	require.Equal(t, fakeWriter.StatusMap["S"], 401)
 	require.Equal(t, theHeader.Get("Access-Control-Allow-Origin"), "")
 }
+
+func TestContainerLimit(t *testing.T) {
+	server := &ProxyServer{maxContainers: 10, maxContainersWhitelist: map[string]bool{"AUTH_big": true}}
+	ai := &middleware.AccountInfo{SysMetadata: map[string]string{}}
+	require.Equal(t, int64(10), server.containerLimit("AUTH_test", ai))
+	require.Equal(t, int64(0), server.containerLimit("AUTH_big", ai))
+	ai.SysMetadata["Max-Containers"] = "100"
+	require.Equal(t, int64(100), server.containerLimit("AUTH_test", ai))
+	ai.SysMetadata["Max-Containers"] = "0"
+	require.Equal(t, int64(0), server.containerLimit("AUTH_test", ai))
+	ai.SysMetadata["Max-Containers"] = ""
+	require.Equal(t, int64(10), server.containerLimit("AUTH_test", ai))
+}
+
+func TestContainerRestoreHandler(t *testing.T) {
+	store := clienttest.NewStore()
+	rc := clienttest.NewRequestClient(store)
+	rc.PutAccount(context.Background(), "a", http.Header{}).Body.Close()
+	rc.PutContainer(context.Background(), "a", "c", http.Header{}).Body.Close()
+	server := &ProxyServer{logger: zap.NewNop()}
+	restore := func() int {
+		req := httptest.NewRequest("PUT", "/op/restore/a/c", nil)
+		req.Header.Set("X-Timestamp", "1500000000.00000")
+		req = srv.SetVars(req, map[string]string{"account": "a", "container": "c"})
+		req = req.WithContext(context.WithValue(req.Context(), "proxycontext", &middleware.ProxyContext{C: rc, Logger: zap.NewNop()}))
+		w := httptest.NewRecorder()
+		server.ContainerRestoreHandler(w, req)
+		return w.Code
+	}
+	require.Equal(t, http.StatusConflict, restore())
+	rc.DeleteContainer(context.Background(), "a", "c", http.Header{}).Body.Close()
+	require.Equal(t, http.StatusCreated, restore())
+	resp := rc.HeadContainer(context.Background(), "a", "c", http.Header{})
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
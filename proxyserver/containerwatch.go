@@ -0,0 +1,147 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package proxyserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/containerserver"
+	"github.com/troubling/hummingbird/proxyserver/middleware"
+	"go.uber.org/zap"
+)
+
+// watchBatchSize is how many changes a watch asks a container server for at
+// a time.
+const watchBatchSize = 1000
+
+// watchKeepalive is how long a watch goes without sending anything before
+// it sends a comment, so idle connections aren't dropped along the way.
+const watchKeepalive = 15 * time.Second
+
+// watchEvent is the data of a container watch's "put" and "delete" events.
+type watchEvent struct {
+	Name         string `json:"name"`
+	Bytes        *int64 `json:"bytes,omitempty"`
+	Hash         string `json:"hash,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
+	LastModified string `json:"last_modified"`
+}
+
+func newWatchEvent(r *containerserver.ObjectRecord) (string, *watchEvent) {
+	e := &watchEvent{Name: r.Name}
+	if ts, err := common.ParseDate(r.CreatedAt); err == nil {
+		e.LastModified = ts.Format("2006-01-02T15:04:05.000000")
+	}
+	if r.Deleted != 0 {
+		return "delete", e
+	}
+	size := r.Size
+	e.Bytes, e.Hash, e.ContentType = &size, r.ETag, r.ContentType
+	return "put", e
+}
+
+// parseWatchEventID splits a Last-Event-ID into the container replica's ID
+// and the row it was at.
+func parseWatchEventID(id string) (string, int64) {
+	if i := strings.LastIndex(id, ":"); i > 0 {
+		if row, err := strconv.ParseInt(id[i+1:], 10, 64); err == nil {
+			return id[:i], row
+		}
+	}
+	return "", 0
+}
+
+// containerWatch streams the objects put and deleted in a container as
+// server-sent events, following the changes feed of whichever container
+// replica answers. Each event's id is the replica's ID and row, so a
+// client that reconnects with Last-Event-ID picks up where it left off. If
+// a different replica answers, whose rows don't line up with the last
+// one's, the watch starts over from that replica's newest row, and changes
+// in between can be missed; clients that can't miss any should still list
+// the container now and then.
+func (server *ProxyServer) containerWatch(writer http.ResponseWriter, request *http.Request, account, container string, policy int) {
+	ctx := middleware.GetProxyContext(request)
+	replicaID, row := parseWatchEventID(request.Header.Get("Last-Event-Id"))
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.WriteHeader(http.StatusOK)
+	srv.Flush(writer)
+	var deadline <-chan time.Time
+	if server.containerWatchMaxDuration > 0 {
+		deadline = time.After(server.containerWatchMaxDuration)
+	}
+	lastSent := time.Now()
+	for {
+		options := map[string]string{"changes_since": strconv.FormatInt(row, 10), "limit": strconv.Itoa(watchBatchSize)}
+		if replicaID == "" {
+			options["limit"] = "0"
+		}
+		resp := ctx.C.GetContainerRaw(request.Context(), account, container, options, nil)
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return
+		}
+		var records []*containerserver.ObjectRecord
+		if resp.StatusCode/100 != 2 || err != nil {
+			ctx.Logger.Debug("Container watch unable to get changes", zap.Int("status", resp.StatusCode), zap.Error(err))
+		} else if id := resp.Header.Get("X-Backend-Container-Id"); id == "" {
+			ctx.Logger.Debug("Container watch got a container server without changes_since")
+		} else if id != replicaID {
+			replicaID = id
+			row, _ = strconv.ParseInt(resp.Header.Get("X-Backend-Max-Row"), 10, 64)
+		} else if err := json.Unmarshal(body, &records); err != nil {
+			ctx.Logger.Debug("Container watch got bad changes", zap.Error(err))
+		}
+		for _, r := range records {
+			row = r.Rowid
+			if r.StoragePolicyIndex != policy {
+				continue
+			}
+			event, data := newWatchEvent(r)
+			if js, err := json.Marshal(data); err == nil {
+				fmt.Fprintf(writer, "id: %s:%d\nevent: %s\ndata: %s\n\n", replicaID, row, event, js)
+				lastSent = time.Now()
+			}
+		}
+		if len(records) > 0 {
+			srv.Flush(writer)
+		}
+		if len(records) >= watchBatchSize {
+			continue
+		}
+		if time.Since(lastSent) >= watchKeepalive {
+			writer.Write([]byte(":\n\n"))
+			srv.Flush(writer)
+			lastSent = time.Now()
+		}
+		select {
+		case <-time.After(server.containerWatchInterval):
+		case <-deadline:
+			return
+		case <-request.Context().Done():
+			return
+		}
+	}
+}
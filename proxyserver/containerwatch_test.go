@@ -0,0 +1,170 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package proxyserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/client"
+	"github.com/troubling/hummingbird/client/clienttest"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/common/test"
+	"github.com/troubling/hummingbird/containerserver"
+	"github.com/troubling/nectar/nectarutil"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+// watchTestClient answers changes_since listings from records, as a
+// container replica with ID "r1".
+type watchTestClient struct {
+	*clienttest.RequestClient
+	records []*containerserver.ObjectRecord
+}
+
+func (c *watchTestClient) GetContainerRaw(ctx context.Context, a string, cn string, options map[string]string, headers http.Header) *http.Response {
+	since, _ := strconv.ParseInt(options["changes_since"], 10, 64)
+	limit, _ := strconv.Atoi(options["limit"])
+	page := []*containerserver.ObjectRecord{}
+	for _, r := range c.records {
+		if r.Rowid > since && len(page) < limit {
+			page = append(page, r)
+		}
+	}
+	body, _ := json.Marshal(page)
+	resp := nectarutil.ResponseStub(http.StatusOK, string(body))
+	resp.Header.Set("X-Backend-Container-Id", "r1")
+	resp.Header.Set("X-Backend-Max-Row", strconv.FormatInt(c.records[len(c.records)-1].Rowid, 10))
+	return resp
+}
+
+// watchTestProxyClient hands every request the same watchTestClient.
+type watchTestProxyClient struct {
+	client.ProxyClient
+	rc client.RequestClient
+}
+
+func (p *watchTestProxyClient) NewRequestClient(mc ring.MemcacheRing, lc map[string]*client.ContainerInfo, logger srv.LowLevelLogger) client.RequestClient {
+	return p.rc
+}
+
+func TestContainerWatch(t *testing.T) {
+	store := clienttest.NewStore()
+	rc := &watchTestClient{RequestClient: clienttest.NewRequestClient(store), records: []*containerserver.ObjectRecord{
+		{Rowid: 1, Name: "old", CreatedAt: "1530814569.00000", Size: 1},
+		{Rowid: 2, Name: "older", CreatedAt: "1530814569.00000", Size: 1},
+		{Rowid: 3, Name: "a", CreatedAt: "1530814570.50000", Size: 5, ETag: "5d41402abc4b2a76b9719d911017c592", ContentType: "text/plain"},
+		{Rowid: 4, Name: "old", CreatedAt: "1530814571.00000", Deleted: 1},
+		{Rowid: 5, Name: "misplaced", CreatedAt: "1530814572.00000", StoragePolicyIndex: 1},
+	}}
+	resp := rc.PutContainer(context.Background(), "AUTH_a", "c", http.Header{"X-Container-Read": {".r:*,.rlistings"}})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	server := &ProxyServer{
+		logger:                    zap.NewNop(),
+		metricsScope:              tally.NoopScope,
+		mc:                        &test.FakeMemcacheRing{},
+		proxyClient:               &watchTestProxyClient{ProxyClient: clienttest.NewProxyClient(store), rc: rc},
+		containerWatchInterval:    time.Millisecond,
+		containerWatchMaxDuration: 50 * time.Millisecond,
+	}
+	router := srv.NewRouter()
+	router.Get("/v1/:account/:container", http.HandlerFunc(server.ContainerGetHandler))
+	server.router = router
+	config, err := conf.StringConfig("")
+	require.Nil(t, err)
+	// The whole default pipeline, so every middleware that wraps the
+	// response writer has to pass flushes along.
+	handler, err := server.buildPipeline(config)
+	require.Nil(t, err)
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	get := func(ctx context.Context, lastEventID string) *http.Response {
+		req, err := http.NewRequest("GET", ts.URL+"/v1/AUTH_a/c?watch=true", nil)
+		require.Nil(t, err)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-Id", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		require.Nil(t, err)
+		require.Equal(t, 200, resp.StatusCode)
+		require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		return resp
+	}
+	watch := func(lastEventID string) string {
+		resp := get(context.Background(), lastEventID)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return string(body)
+	}
+
+	// A new watch starts from the replica's newest row.
+	require.Equal(t, "", watch(""))
+	require.Equal(t, "id: r1:3\nevent: put\n"+
+		`data: {"name":"a","bytes":5,"hash":"5d41402abc4b2a76b9719d911017c592","content_type":"text/plain","last_modified":"2018-07-05T18:16:10.500000"}`+"\n\n"+
+		"id: r1:4\nevent: delete\n"+
+		`data: {"name":"old","last_modified":"2018-07-05T18:16:11.000000"}`+"\n\n", watch("r1:2"))
+	require.Equal(t, "", watch("r1:4"))
+	// Another replica's rows don't line up, so it starts over at the newest.
+	require.Equal(t, "", watch("r2:0"))
+
+	// Events reach the client as they're sent, not when the watch ends.
+	server.containerWatchMaxDuration = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resp = get(ctx, "r1:2")
+	defer resp.Body.Close()
+	line := make(chan string, 1)
+	go func() {
+		l, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		line <- l
+	}()
+	select {
+	case l := <-line:
+		require.Equal(t, "id: r1:3\n", l)
+	case <-time.After(10 * time.Second):
+		t.Fatal("watch events were held back")
+	}
+}
+
+func TestParseWatchEventID(t *testing.T) {
+	for id, expected := range map[string]struct {
+		replicaID string
+		row       int64
+	}{
+		"":           {"", 0},
+		"abc:12":     {"abc", 12},
+		"a:b:c:12":   {"a:b:c", 12},
+		"abc":        {"", 0},
+		":12":        {"", 0},
+		"abc:twelve": {"", 0},
+	} {
+		replicaID, row := parseWatchEventID(id)
+		require.Equal(t, expected.replicaID, replicaID, id)
+		require.Equal(t, expected.row, row, id)
+	}
+}
@@ -30,7 +30,7 @@ func (server *ProxyServer) EndpointsObjectGetHandler(writer http.ResponseWriter,
 	partition := ring.GetPartition(vars["account"], vars["container"], vars["obj"])
 	endpoints := []string{}
 	for _, device := range ring.GetNodes(partition) {
-		endpoints = append(endpoints, fmt.Sprintf("%s://%s:%d/%s/%d/%s/%s/%s", device.Scheme, device.Ip, device.Port, device.Device, partition, common.Urlencode(vars["account"]), common.Urlencode(vars["container"]), common.Urlencode(vars["obj"])))
+		endpoints = append(endpoints, fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s/%s", device.Scheme, device.Ip, device.Port, device.PathPrefix, device.Device, partition, common.Urlencode(vars["account"]), common.Urlencode(vars["container"]), common.Urlencode(vars["obj"])))
 	}
 	body, err := json.Marshal(endpoints)
 	if err != nil {
@@ -56,7 +56,7 @@ func (server *ProxyServer) EndpointsContainerGetHandler(writer http.ResponseWrit
 	partition := ring.GetPartition(vars["account"], vars["container"], "")
 	endpoints := []string{}
 	for _, device := range ring.GetNodes(partition) {
-		endpoints = append(endpoints, fmt.Sprintf("%s://%s:%d/%s/%d/%s/%s", device.Scheme, device.Ip, device.Port, device.Device, partition, common.Urlencode(vars["account"]), common.Urlencode(vars["container"])))
+		endpoints = append(endpoints, fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s", device.Scheme, device.Ip, device.Port, device.PathPrefix, device.Device, partition, common.Urlencode(vars["account"]), common.Urlencode(vars["container"])))
 	}
 	body, err := json.Marshal(endpoints)
 	if err != nil {
@@ -82,7 +82,7 @@ func (server *ProxyServer) EndpointsAccountGetHandler(writer http.ResponseWriter
 	partition := ring.GetPartition(vars["account"], "", "")
 	endpoints := []string{}
 	for _, device := range ring.GetNodes(partition) {
-		endpoints = append(endpoints, fmt.Sprintf("%s://%s:%d/%s/%d/%s", device.Scheme, device.Ip, device.Port, device.Device, partition, common.Urlencode(vars["account"])))
+		endpoints = append(endpoints, fmt.Sprintf("%s://%s:%d%s/%s/%d/%s", device.Scheme, device.Ip, device.Port, device.PathPrefix, device.Device, partition, common.Urlencode(vars["account"])))
 	}
 	body, err := json.Marshal(endpoints)
 	if err != nil {
@@ -123,7 +123,7 @@ func (server *ProxyServer) EndpointsObjectGetHandler2(writer http.ResponseWriter
 	}{Headers: map[string]string{}}
 	data.Headers["X-Backend-Storage-Policy-Index"] = strconv.Itoa(containerInfo.StoragePolicyIndex)
 	for _, device := range ring.GetNodes(partition) {
-		data.Endpoints = append(data.Endpoints, fmt.Sprintf("%s://%s:%d/%s/%d/%s/%s/%s", device.Scheme, device.Ip, device.Port, device.Device, partition, common.Urlencode(vars["account"]), common.Urlencode(vars["container"]), common.Urlencode(vars["obj"])))
+		data.Endpoints = append(data.Endpoints, fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s/%s", device.Scheme, device.Ip, device.Port, device.PathPrefix, device.Device, partition, common.Urlencode(vars["account"]), common.Urlencode(vars["container"]), common.Urlencode(vars["obj"])))
 	}
 	body, err := json.Marshal(data)
 	if err != nil {
@@ -159,7 +159,7 @@ func (server *ProxyServer) EndpointsContainerGetHandler2(writer http.ResponseWri
 	}{Headers: map[string]string{}}
 	data.Headers["X-Backend-Storage-Policy-Index"] = strconv.Itoa(containerInfo.StoragePolicyIndex)
 	for _, device := range ring.GetNodes(partition) {
-		data.Endpoints = append(data.Endpoints, fmt.Sprintf("%s://%s:%d/%s/%d/%s/%s", device.Scheme, device.Ip, device.Port, device.Device, partition, common.Urlencode(vars["account"]), common.Urlencode(vars["container"])))
+		data.Endpoints = append(data.Endpoints, fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s", device.Scheme, device.Ip, device.Port, device.PathPrefix, device.Device, partition, common.Urlencode(vars["account"]), common.Urlencode(vars["container"])))
 	}
 	body, err := json.Marshal(data)
 	if err != nil {
@@ -188,7 +188,7 @@ func (server *ProxyServer) EndpointsAccountGetHandler2(writer http.ResponseWrite
 		Headers   map[string]string `json:"headers"`
 	}{Headers: map[string]string{}}
 	for _, device := range ring.GetNodes(partition) {
-		data.Endpoints = append(data.Endpoints, fmt.Sprintf("%s://%s:%d/%s/%d/%s", device.Scheme, device.Ip, device.Port, device.Device, partition, common.Urlencode(vars["account"])))
+		data.Endpoints = append(data.Endpoints, fmt.Sprintf("%s://%s:%d%s/%s/%d/%s", device.Scheme, device.Ip, device.Port, device.PathPrefix, device.Device, partition, common.Urlencode(vars["account"])))
 	}
 	body, err := json.Marshal(data)
 	if err != nil {
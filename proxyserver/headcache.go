@@ -0,0 +1,93 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package proxyserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/troubling/hummingbird/client"
+	"github.com/troubling/hummingbird/proxyserver/middleware"
+)
+
+// useHeadCache returns whether an account or container HEAD may be answered
+// from memcache. Clients that need the backend's answer can send
+// Cache-Control: no-cache, or X-Newest as they would for objects.
+func (server *ProxyServer) useHeadCache(ctx *middleware.ProxyContext, request *http.Request) bool {
+	return server.headFromCache && ctx.ProxyContextMiddleware != nil && ctx.Cache != nil &&
+		request.Header.Get("X-Newest") == "" &&
+		!strings.Contains(strings.ToLower(request.Header.Get("Cache-Control")), "no-cache")
+}
+
+// cachedAccountHeader builds the headers of an account HEAD from the
+// account's info in memcache, or returns nil if it isn't there or was cached
+// without the account's timestamp.
+func cachedAccountHeader(ctx *middleware.ProxyContext, request *http.Request, account string) http.Header {
+	var ai *middleware.AccountInfo
+	if err := ctx.Cache.GetStructured(request.Context(), fmt.Sprintf("account/%s", account), &ai); err != nil || ai == nil || ai.StatusCode/100 != 2 || ai.Timestamp == "" {
+		return nil
+	}
+	header := http.Header{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"X-Account-Container-Count": {strconv.FormatInt(ai.ContainerCount, 10)},
+		"X-Account-Object-Count":    {strconv.FormatInt(ai.ObjectCount, 10)},
+		"X-Account-Bytes-Used":      {strconv.FormatInt(ai.ObjectBytes, 10)},
+		"X-Timestamp":               {ai.Timestamp},
+		"X-Backend-Cached":          {"true"},
+	}
+	for k, v := range ai.Metadata {
+		header.Set("X-Account-Meta-"+k, v)
+	}
+	for k, v := range ai.SysMetadata {
+		header.Set("X-Account-Sysmeta-"+k, v)
+	}
+	return header
+}
+
+// cachedContainerHeader builds the headers of a container HEAD from the
+// container's info in memcache, or returns nil if it isn't there or was
+// cached without the container's timestamp.
+func (server *ProxyServer) cachedContainerHeader(ctx *middleware.ProxyContext, request *http.Request, account, container string) http.Header {
+	var ci *client.ContainerInfo
+	if err := ctx.Cache.GetStructured(request.Context(), fmt.Sprintf("container/%s/%s", account, container), &ci); err != nil || ci == nil || ci.Timestamp == "" {
+		return nil
+	}
+	header := http.Header{
+		"Content-Type":                   {"text/plain; charset=utf-8"},
+		"X-Container-Object-Count":       {strconv.FormatInt(ci.ObjectCount, 10)},
+		"X-Container-Bytes-Used":         {strconv.FormatInt(ci.ObjectBytes, 10)},
+		"X-Backend-Storage-Policy-Index": {strconv.Itoa(ci.StoragePolicyIndex)},
+		"X-Timestamp":                    {ci.Timestamp},
+		"X-Backend-Cached":               {"true"},
+	}
+	if policy := server.policies[ci.StoragePolicyIndex]; policy != nil {
+		header.Set("X-Storage-Policy", policy.Name)
+	}
+	for k, v := range ci.Metadata {
+		header.Set("X-Container-Meta-"+k, v)
+	}
+	for k, v := range ci.SysMetadata {
+		header.Set("X-Container-Sysmeta-"+k, v)
+	}
+	for k, v := range map[string]string{"X-Container-Read": ci.ReadACL, "X-Container-Write": ci.WriteACL, "X-Container-Sync-Key": ci.SyncKey} {
+		if v != "" {
+			header.Set(k, v)
+		}
+	}
+	return header
+}
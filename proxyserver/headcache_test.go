@@ -0,0 +1,98 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package proxyserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/client/clienttest"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/common/test"
+	"github.com/troubling/hummingbird/proxyserver/middleware"
+	"go.uber.org/zap"
+)
+
+func TestHeadFromCache(t *testing.T) {
+	store := clienttest.NewStore()
+	rc := clienttest.NewRequestClient(store)
+	require.Equal(t, 201, rc.PutContainer(context.Background(), "a", "c", http.Header{}).StatusCode)
+	mc := &test.FakeMemcacheRing{MockGetStructured: map[string][]byte{
+		"account/a":     []byte(`{"ContainerCount":1,"ObjectCount":3,"ObjectBytes":10,"Metadata":{"Color":"red"},"status":204,"Timestamp":"1500000000.00000"}`),
+		"container/a/c": []byte(`{"ObjectCount":3,"ObjectBytes":10,"Metadata":{"Color":"blue"},"StoragePolicyIndex":0,"ReadACL":".r:*","Timestamp":"1500000001.00000"}`),
+	}}
+	server := &ProxyServer{logger: zap.NewNop(), headFromCache: true, headCacheControl: "max-age=5", policies: staticPolicyList}
+	head := func(path string, header http.Header, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("HEAD", path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req = srv.SetVars(req, map[string]string{"account": "a", "container": "c"})
+		req = req.WithContext(context.WithValue(req.Context(), "proxycontext", &middleware.ProxyContext{
+			ProxyContextMiddleware: &middleware.ProxyContextMiddleware{Cache: mc},
+			C:                      rc,
+			Logger:                 zap.NewNop(),
+		}))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	rec := head("/v1/a/c", nil, server.ContainerHeadHandler)
+	require.Equal(t, 204, rec.Code)
+	require.Equal(t, "true", rec.Header().Get("X-Backend-Cached"))
+	require.Equal(t, "3", rec.Header().Get("X-Container-Object-Count"))
+	require.Equal(t, "blue", rec.Header().Get("X-Container-Meta-Color"))
+	require.Equal(t, "1500000001.00000", rec.Header().Get("X-Timestamp"))
+	require.Equal(t, "gold", rec.Header().Get("X-Storage-Policy"))
+	require.Equal(t, "max-age=5", rec.Header().Get("Cache-Control"))
+	// Only the owner sees the ACLs.
+	require.Equal(t, "", rec.Header().Get("X-Container-Read"))
+
+	rec = head("/v1/a/c", http.Header{"Cache-Control": {"no-cache"}}, server.ContainerHeadHandler)
+	require.Equal(t, 204, rec.Code)
+	require.Equal(t, "", rec.Header().Get("X-Backend-Cached"))
+	require.Equal(t, "0", rec.Header().Get("X-Container-Object-Count"))
+	require.Equal(t, "max-age=5", rec.Header().Get("Cache-Control"))
+
+	rec = head("/v1/a", nil, server.AccountHeadHandler)
+	require.Equal(t, 204, rec.Code)
+	require.Equal(t, "true", rec.Header().Get("X-Backend-Cached"))
+	require.Equal(t, "1", rec.Header().Get("X-Account-Container-Count"))
+	require.Equal(t, "red", rec.Header().Get("X-Account-Meta-Color"))
+	require.Equal(t, "1500000000.00000", rec.Header().Get("X-Timestamp"))
+	rec = head("/v1/a", http.Header{"X-Newest": {"true"}}, server.AccountHeadHandler)
+	require.Equal(t, "", rec.Header().Get("X-Backend-Cached"))
+	require.Equal(t, "", rec.Header().Get("X-Account-Meta-Color"))
+
+	// Info cached without a timestamp isn't enough to answer from.
+	mc.MockGetStructured["container/a/c"] = []byte(`{"ObjectCount":3,"ObjectBytes":10,"StoragePolicyIndex":0}`)
+	rec = head("/v1/a/c", nil, server.ContainerHeadHandler)
+	require.Equal(t, 204, rec.Code)
+	require.Equal(t, "", rec.Header().Get("X-Backend-Cached"))
+	require.Equal(t, "0", rec.Header().Get("X-Container-Object-Count"))
+
+	server.headFromCache = false
+	rec = head("/v1/a/c", nil, server.ContainerHeadHandler)
+	require.Equal(t, "", rec.Header().Get("X-Backend-Cached"))
+	require.Equal(t, "0", rec.Header().Get("X-Container-Object-Count"))
+	server.headCacheControl = ""
+	rec = head("/v1/a/c", nil, server.ContainerHeadHandler)
+	require.Equal(t, "", rec.Header().Get("Cache-Control"))
+}
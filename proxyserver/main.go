@@ -16,6 +16,7 @@
 package proxyserver
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -23,6 +24,8 @@ import (
 	_ "net/http/pprof"
 	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	opentracing "github.com/opentracing/opentracing-go"
@@ -47,10 +50,57 @@ type ProxyServer struct {
 	logLevel          zap.AtomicLevel
 	mc                ring.MemcacheRing
 	accountAutoCreate bool
+	allowOpenExpired  bool
 	proxyClient       client.ProxyClient
 	metricsCloser     io.Closer
 	traceCloser       io.Closer
 	tracer            opentracing.Tracer
+	metricsScope      tally.Scope
+	router            http.Handler
+	pipeline          atomic.Value
+	reloadLock        sync.Mutex
+	configFile        string
+	bindPort          int
+
+	// maxContainers limits how many containers an account may have; 0 is
+	// unlimited. Accounts in maxContainersWhitelist, or with their own
+	// Max-Containers sysmeta, aren't held to it.
+	maxContainers          int64
+	maxContainersWhitelist map[string]bool
+
+	// autoCreatePrefixes are the auth middleware's reseller prefixes; with
+	// accountAutoCreate, only accounts under one of them are created.
+	autoCreatePrefixes []string
+
+	// containerWatchInterval is how often container watches check for
+	// changes, for up to containerWatchMaxDuration; 0 lets them run until
+	// the client goes away.
+	containerWatchInterval    time.Duration
+	containerWatchMaxDuration time.Duration
+
+	// headCacheControl is the Cache-Control of successful account and
+	// container HEADs. With headFromCache, those HEADs are answered from
+	// the account and container info in memcache when it's there.
+	headCacheControl string
+	headFromCache    bool
+	policies         conf.PolicyList
+
+	// timestampGuard is "adjust" or "reject" to keep object writes from
+	// going back in time when proxy clocks drift, or "" for neither. The
+	// last X-Timestamp written to each object is kept in memcache for
+	// timestampGuardTTL seconds.
+	timestampGuard    string
+	timestampGuardTTL int
+}
+
+// autoCreates returns whether a missing account should be created on
+// first use.
+func (server *ProxyServer) autoCreates(account string) bool {
+	if !server.accountAutoCreate {
+		return false
+	}
+	_, ok := conf.ResellerPrefix(server.autoCreatePrefixes, account)
+	return ok
 }
 
 func (server *ProxyServer) Type() string {
@@ -71,10 +121,35 @@ func (server *ProxyServer) Finalize() {
 	server.proxyClient.Close()
 }
 
+// DeviceHealthHandler lists the backend devices this proxy is avoiding, such
+// as ones that recently answered 507 Insufficient Storage.
+func (server *ProxyServer) DeviceHealthHandler(writer http.ResponseWriter, request *http.Request) {
+	data, err := json.Marshal(server.proxyClient.DeviceHealth())
+	if err != nil {
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(data)
+}
+
+// PoliciesHandler lists the storage policies this proxy has configured, with
+// the state of their object rings.
+func (server *ProxyServer) PoliciesHandler(writer http.ResponseWriter, request *http.Request) {
+	data, err := json.Marshal(server.proxyClient.Policies())
+	if err != nil {
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(data)
+}
+
 func (server *ProxyServer) GetHandler(config conf.Config, metricsPrefix string) http.Handler {
 	obfuscatedPrefix, _ := config.Get("app:proxy-server", "obfuscated_prefix")
-	var metricsScope tally.Scope
-	metricsScope, server.metricsCloser = tally.NewRootScope(tally.ScopeOptions{
+	server.metricsScope, server.metricsCloser = tally.NewRootScope(tally.ScopeOptions{
 		Prefix:         metricsPrefix,
 		Tags:           map[string]string{},
 		CachedReporter: promreporter.NewReporter(promreporter.Options{}),
@@ -89,6 +164,15 @@ func (server *ProxyServer) GetHandler(config conf.Config, metricsPrefix string)
 		router.Get(path.Join("/", op, "metrics"), prometheus.Handler())
 		router.Get(path.Join("/", op, "loglevel"), server.logLevel)
 		router.Put(path.Join("/", op, "loglevel"), server.logLevel)
+		router.Get(path.Join("/", op, "loglevel/:component"), http.HandlerFunc(srv.LogLevelHandler))
+		router.Put(path.Join("/", op, "loglevel/:component"), http.HandlerFunc(srv.LogLevelHandler))
+		router.Get(path.Join("/", op, "loglevels"), http.HandlerFunc(srv.LogLevelsHandler))
+		router.Get(path.Join("/", op, "requeststats"), http.HandlerFunc(middleware.RequestStatsHandler))
+		router.Get(path.Join("/", op, "devicehealth"), http.HandlerFunc(server.DeviceHealthHandler))
+		router.Get(path.Join("/", op, "policies"), http.HandlerFunc(server.PoliciesHandler))
+		router.Get(path.Join("/", op, "recon/hummingbirdtime"), http.HandlerFunc(globalmiddleware.TimeHandler))
+		router.Put(path.Join("/", op, "reload"), http.HandlerFunc(server.ReloadHandler))
+		router.Put(path.Join("/", op, "restore/:account/:container"), http.HandlerFunc(server.ContainerRestoreHandler))
 		router.Get(path.Join("/", op, "debug/pprof/:parm"), http.DefaultServeMux)
 		router.Post(path.Join("/", op, "debug/pprof/:parm"), http.DefaultServeMux)
 		router.Get(path.Join("/", op, "endpoints/v1/:account/:container/*obj"), http.HandlerFunc(server.EndpointsObjectGetHandler))
@@ -106,6 +190,7 @@ func (server *ProxyServer) GetHandler(config conf.Config, metricsPrefix string)
 	router.Put("/v1/:account/:container/*obj", http.HandlerFunc(server.ObjectPutHandler))
 	router.Delete("/v1/:account/:container/*obj", http.HandlerFunc(server.ObjectDeleteHandler))
 	router.Post("/v1/:account/:container/*obj", http.HandlerFunc(server.ObjectPostHandler))
+	router.Handle("APPEND", "/v1/:account/:container/*obj", http.HandlerFunc(server.ObjectAppendHandler))
 	router.Options("/v1/:account/:container/*obj", http.HandlerFunc(server.OptionsHandler))
 
 	router.Get("/v1/:account/:container", http.HandlerFunc(server.ContainerGetHandler))
@@ -134,75 +219,121 @@ func (server *ProxyServer) GetHandler(config conf.Config, metricsPrefix string)
 	router.Options("/v1/:account", http.HandlerFunc(server.OptionsHandler))
 	router.Options("/v1/:account/", http.HandlerFunc(server.OptionsHandler))
 
-	tempAuth := config.GetBool("app:proxy-server", "tempauth_enabled", true)
-	var middlewares []struct {
-		construct func(conf.Section, tally.Scope) (func(http.Handler) http.Handler, error)
-		section   string
+	server.router = router
+	handler, err := server.buildPipeline(config)
+	if err != nil {
+		// TODO: propagate error upwards instead of panicking
+		panic(err.Error())
 	}
-	// TODO: make this all dynamical and stuff
-	if tempAuth {
-		middlewares = []struct {
-			construct func(conf.Section, tally.Scope) (func(http.Handler) http.Handler, error)
-			section   string
-		}{
-			{middleware.NewCatchError, "filter:catch_errors"},
-			{middleware.NewHealthcheck, "filter:healthcheck"},
-			{middleware.NewRequestLogger, "filter:proxy-logging"},
-			{middleware.NewS3Auth, "filter:s3api"},
-			{middleware.NewCrossDomain, "filter:crossdomain"},
-			{middleware.NewCors, "filter:cors"}, // TODO: i dont want to have to have a seciton for this
-			{middleware.NewFormPost, "filter:formpost"},
-			{middleware.NewTempURL, "filter:tempurl"},
-			{middleware.NewTempAuth, "filter:tempauth"},
-			{middleware.NewS3Api, "filter:s3api"},
-			{middleware.NewBulk, "filter:bulk"},
-			{middleware.NewMultirange, "filter:multirange"},
-			{middleware.NewRatelimiter, "filter:ratelimit"},
-			{middleware.NewStaticWeb, "filter:staticweb"},
-			{middleware.NewCopyMiddleware, "filter:copy"},
-			{middleware.NewAccountQuota, "filter:account-quotas"},
-			{middleware.NewContainerQuota, "filter:container-quotas"},
-			{middleware.NewVersionedWrites, "filter:versioned_writes"},
-			{middleware.NewXlo, "filter:slo"},
+	server.pipeline.Store(handler)
+	return server
+}
+
+// defaultPipeline is the middlewares used when the config has no
+// [pipeline:main] section.
+var defaultPipeline = []string{
+	"catch_errors", "healthcheck", "proxy-logging", "slowlog", "qos", "auth_challenge",
+	"s3website", "s3auth", "crossdomain", "cors", "formpost", "tempurl", "cdn", "container_sync",
+	"tempauth", "s3api", "requeststats", "bulk", "multirange", "ratelimit", "staticweb", "copy",
+	"object_cache", "cache_control", "name_check", "read_only", "retention",
+	"account-quotas", "container-quotas", "versioned_writes", "slo", "resumable", "compression",
+}
+
+// keystonePipeline is the default with tempauth_enabled = false.
+var keystonePipeline = []string{
+	"catch_errors", "healthcheck", "proxy-logging", "slowlog", "qos", "auth_challenge",
+	"s3website", "s3auth", "crossdomain", "cors", "formpost", "tempurl", "cdn", "container_sync",
+	"authtoken", "s3api", "requeststats", "keystoneauth", "bulk", "multirange", "ratelimit", "staticweb", "copy",
+	"object_cache", "cache_control", "name_check", "read_only", "retention",
+	"account-quotas", "container-quotas", "versioned_writes", "slo", "resumable", "compression",
+}
+
+// pipelineNames returns the middlewares named by the pipeline setting of
+// [pipeline:main], or the default pipeline. A trailing "proxy-server", as
+// in Swift configs, is the router and is left off. A Swift config's
+// pipeline usually names middlewares this proxy doesn't have, or puts them
+// in an order it can't use, so one that doesn't check out is replaced by the
+// default pipeline, with the reason returned as ignored, rather than keeping
+// the proxy from starting.
+func pipelineNames(config conf.Config) (names []string, ignored error) {
+	if value, ok := config.Get("pipeline:main", "pipeline"); ok {
+		names := strings.Fields(value)
+		if len(names) == 0 || names[len(names)-1] != "proxy-server" {
+			return names, nil
 		}
-	} else {
-		middlewares = []struct {
-			construct func(conf.Section, tally.Scope) (func(http.Handler) http.Handler, error)
-			section   string
-		}{
-			{middleware.NewCatchError, "filter:catch_errors"},
-			{middleware.NewHealthcheck, "filter:healthcheck"},
-			{middleware.NewRequestLogger, "filter:proxy-logging"},
-			{middleware.NewS3Auth, "filter:s3api"},
-			{middleware.NewCrossDomain, "filter:crossdomain"},
-			{middleware.NewCors, "filter:cors"},
-			{middleware.NewFormPost, "filter:formpost"},
-			{middleware.NewTempURL, "filter:tempurl"},
-			{middleware.NewAuthToken, "filter:authtoken"},
-			{middleware.NewS3Api, "filter:s3api"},
-			{middleware.NewKeystoneAuth, "filter:keystoneauth"},
-			{middleware.NewBulk, "filter:bulk"},
-			{middleware.NewMultirange, "filter:multirange"},
-			{middleware.NewRatelimiter, "filter:ratelimit"},
-			{middleware.NewStaticWeb, "filter:staticweb"},
-			{middleware.NewCopyMiddleware, "filter:copy"},
-			{middleware.NewAccountQuota, "filter:account-quotas"},
-			{middleware.NewContainerQuota, "filter:container-quotas"},
-			{middleware.NewVersionedWrites, "filter:versioned_writes"},
-			{middleware.NewXlo, "filter:slo"},
+		names = names[:len(names)-1]
+		if ignored = middleware.CheckPipeline(names); ignored == nil {
+			return names, nil
 		}
 	}
-	pipeline := alice.New(globalmiddleware.ServerTracer(server.tracer), middleware.NewContext(config.GetBool("debug", "debug_x_source_code", false),
+	if config.GetBool("app:proxy-server", "tempauth_enabled", true) {
+		return defaultPipeline, ignored
+	}
+	return keystonePipeline, ignored
+}
+
+// buildPipeline constructs the middleware pipeline in front of the router
+// from config.
+func (server *ProxyServer) buildPipeline(config conf.Config) (http.Handler, error) {
+	names, ignored := pipelineNames(config)
+	if ignored != nil {
+		server.logger.With(zap.Error(ignored)).Warn("Using the default pipeline instead of the Swift one in [pipeline:main]")
+	}
+	mids, err := middleware.NewPipeline(names, config, server.metricsScope)
+	if err != nil {
+		return nil, err
+	}
+	listingCompression, err := middleware.NewListingCompression(config.GetSection("app:proxy-server"), server.metricsScope)
+	if err != nil {
+		return nil, err
+	}
+	pipeline := alice.New(globalmiddleware.ServerTracer(server.tracer), listingCompression, middleware.NewContext(config.GetBool("debug", "debug_x_source_code", false),
 		server.mc, server.logger, server.proxyClient))
-	for _, m := range middlewares {
-		mid, err := m.construct(config.GetSection(m.section), metricsScope)
+	for _, mid := range mids {
+		pipeline = pipeline.Append(mid)
+	}
+	return pipeline.Then(server.router), nil
+}
+
+// ReloadConfig re-reads the proxy's config file and swaps in a middleware
+// pipeline built from it. Requests already in flight finish on the old
+// pipeline. Settings outside the filter sections, such as bind addresses,
+// rings and policies, still need a restart.
+func (server *ProxyServer) ReloadConfig() error {
+	server.reloadLock.Lock()
+	defer server.reloadLock.Unlock()
+	configs, err := conf.LoadConfigs(server.configFile)
+	if err != nil {
+		return err
+	}
+	for _, config := range configs {
+		if int(config.GetInt("DEFAULT", "bind_port", common.DefaultProxyServerPort)) != server.bindPort {
+			continue
+		}
+		handler, err := server.buildPipeline(config)
 		if err != nil {
-			// TODO: propagate error upwards instead of panicking
-			panic("Unable to construct middleware")
+			return err
 		}
-		pipeline = pipeline.Append(mid)
+		server.pipeline.Store(handler)
+		server.logger.Info("Reloaded middleware config", zap.String("config", server.configFile))
+		return nil
 	}
-	return pipeline.Then(router)
+	return fmt.Errorf("No config in %s for port %d", server.configFile, server.bindPort)
+}
+
+// ReloadHandler reloads the middleware config on a PUT to /reload under the
+// obfuscated prefix.
+func (server *ProxyServer) ReloadHandler(writer http.ResponseWriter, request *http.Request) {
+	if err := server.ReloadConfig(); err != nil {
+		server.logger.Error("Error reloading config", zap.Error(err))
+		srv.SimpleErrorResponse(writer, http.StatusInternalServerError, err.Error())
+		return
+	}
+	srv.StandardResponse(writer, http.StatusNoContent)
+}
+
+func (server *ProxyServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	server.pipeline.Load().(http.Handler).ServeHTTP(writer, request)
 }
 
 func NewServer(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader) (*srv.IpPort, srv.Server, srv.LowLevelLogger, error) {
@@ -216,6 +347,10 @@ func NewServer(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader
 
 	bindIP := serverconf.GetDefault("DEFAULT", "bind_ip", "0.0.0.0")
 	bindPort := int(serverconf.GetInt("DEFAULT", "bind_port", common.DefaultProxyServerPort))
+	server.bindPort = bindPort
+	if f := flags.Lookup("c"); f != nil {
+		server.configFile = f.Value.String()
+	}
 	certFile := serverconf.GetDefault("DEFAULT", "cert_file", "")
 	keyFile := serverconf.GetDefault("DEFAULT", "key_file", "")
 
@@ -226,6 +361,35 @@ func NewServer(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader
 	server.logLevel = zap.NewAtomicLevel()
 	server.logLevel.UnmarshalText([]byte(strings.ToLower(logLevelString)))
 	server.accountAutoCreate = serverconf.GetBool("app:proxy-server", "account_autocreate", false)
+	authSection := "filter:tempauth"
+	if !serverconf.GetBool("app:proxy-server", "tempauth_enabled", true) {
+		authSection = "filter:keystoneauth"
+	}
+	server.autoCreatePrefixes, _ = conf.ReadResellerOptions(serverconf.GetSection(authSection), nil)
+	server.allowOpenExpired = serverconf.GetBool("app:proxy-server", "allow_open_expired", false)
+	server.containerWatchInterval = time.Duration(serverconf.GetInt("app:proxy-server", "container_watch_interval_ms", 1000)) * time.Millisecond
+	server.containerWatchMaxDuration = time.Duration(serverconf.GetInt("app:proxy-server", "container_watch_max_duration", 3600)) * time.Second
+	server.headCacheControl = serverconf.GetDefault("app:proxy-server", "head_cache_control", "")
+	server.headFromCache = serverconf.GetBool("app:proxy-server", "head_from_cache", false)
+	switch guard := serverconf.GetDefault("app:proxy-server", "timestamp_guard", "off"); guard {
+	case "adjust", "reject":
+		server.timestampGuard = guard
+	case "off":
+	default:
+		return ipPort, nil, nil, fmt.Errorf("Invalid timestamp_guard %q; use adjust, reject or off", guard)
+	}
+	server.timestampGuardTTL = int(serverconf.GetInt("app:proxy-server", "timestamp_guard_ttl", 3600))
+	// client_chunk_size is the size of the reads and writes object data is
+	// streamed between clients and the backends in.
+	common.SetCopyBufferSize(int(serverconf.GetInt("app:proxy-server", "client_chunk_size",
+		serverconf.GetInt("app:proxy-server", "copy_buffer_size", common.DefaultCopyBufferSize))))
+	server.maxContainers = serverconf.GetInt("app:proxy-server", "max_containers_per_account", 0)
+	server.maxContainersWhitelist = map[string]bool{}
+	for _, account := range strings.Split(serverconf.GetDefault("app:proxy-server", "max_containers_whitelist", ""), ",") {
+		if account = strings.TrimSpace(account); account != "" {
+			server.maxContainersWhitelist[account] = true
+		}
+	}
 	if server.logger, err = srv.SetupLogger("proxy-server", &server.logLevel, flags); err != nil {
 		return ipPort, nil, nil, fmt.Errorf("Error setting up logger: %v", err)
 	}
@@ -240,8 +404,15 @@ func NewServer(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader
 	if err != nil {
 		return ipPort, nil, nil, err
 	}
+	server.policies = policies
+	clientLogLevel := zap.NewAtomicLevel()
+	clientLogLevel.UnmarshalText([]byte(strings.ToLower(serverconf.GetDefault("app:proxy-server", "client_log_level", logLevelString))))
+	clientLogger, err := srv.SetupLogger("proxy-client", &clientLogLevel, flags)
+	if err != nil {
+		return ipPort, nil, nil, fmt.Errorf("Error setting up client logger: %v", err)
+	}
 	server.proxyClient, err = client.NewProxyClient(
-		policies, cnf, server.logger, certFile, keyFile, readAff, writeAff, writeAffCount, serverconf)
+		policies, cnf, clientLogger, certFile, keyFile, readAff, writeAff, writeAffCount, serverconf)
 	if err != nil {
 		return ipPort, nil, nil, fmt.Errorf("Error setting up proxyClient: %v", err)
 	}
@@ -255,7 +426,13 @@ func NewServer(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader
 	for k, v := range common.DEFAULT_CONSTRAINTS {
 		info[k] = v
 	}
+	info["max_containers_per_account"] = server.maxContainers
 	middleware.RegisterInfo("swift", info)
-	ipPort = &srv.IpPort{Ip: bindIP, Port: int(bindPort), CertFile: certFile, KeyFile: keyFile}
+	tlsConf, err := getTLSConfig(serverconf, certFile, keyFile)
+	if err != nil {
+		return ipPort, nil, nil, err
+	}
+	ipPort = &srv.IpPort{Ip: bindIP, Port: int(bindPort), CertFile: certFile, KeyFile: keyFile,
+		Limits: srv.GetServerLimits(serverconf, "app:proxy-server"), TLSConfig: tlsConf}
 	return ipPort, server, server.logger, nil
 }
@@ -0,0 +1,123 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package proxyserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/client/clienttest"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/test"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+func TestReloadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "proxy-server.conf")
+	writeConfig := func(pipeline, readOnly string) {
+		require.Nil(t, ioutil.WriteFile(configFile, []byte("[DEFAULT]\nbind_port = 8080\n"+
+			"[pipeline:main]\npipeline = "+pipeline+"\n"+
+			"[filter:read_only]\nread_only = "+readOnly+"\n"), 0600))
+	}
+	server := &ProxyServer{
+		logger:       zap.NewNop(),
+		metricsScope: tally.NoopScope,
+		mc:           &test.FakeMemcacheRing{},
+		proxyClient:  clienttest.NewProxyClient(clienttest.NewStore()),
+		router: http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusCreated)
+		}),
+		configFile: configFile,
+		bindPort:   8080,
+	}
+	put := func() int {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("PUT", "/v1/AUTH_test/c", nil))
+		return w.Code
+	}
+
+	writeConfig("read_only proxy-server", "true")
+	require.Nil(t, server.ReloadConfig())
+	require.Equal(t, http.StatusServiceUnavailable, put())
+
+	// The new settings apply to the next request.
+	writeConfig("read_only proxy-server", "false")
+	require.Nil(t, server.ReloadConfig())
+	require.Equal(t, http.StatusCreated, put())
+
+	// A failed reload leaves the running pipeline alone.
+	writeConfig("tempauth tempurl read_only", "true")
+	require.NotNil(t, server.ReloadConfig())
+	require.Equal(t, http.StatusCreated, put())
+	writeConfig("read_only", "true")
+	server.bindPort = 8081
+	require.NotNil(t, server.ReloadConfig())
+	require.Equal(t, http.StatusCreated, put())
+	server.configFile = filepath.Join(dir, "missing.conf")
+	require.NotNil(t, server.ReloadConfig())
+	require.Equal(t, http.StatusCreated, put())
+}
+
+func TestAutoCreates(t *testing.T) {
+	server := &ProxyServer{autoCreatePrefixes: []string{"AUTH_", "SERVICE_"}}
+	require.False(t, server.autoCreates("AUTH_test"))
+	server.accountAutoCreate = true
+	require.True(t, server.autoCreates("AUTH_test"))
+	require.True(t, server.autoCreates("SERVICE_test"))
+	require.False(t, server.autoCreates("test"))
+}
+
+func TestPipelineNames(t *testing.T) {
+	for _, test := range []struct {
+		config  string
+		names   []string
+		ignored bool
+	}{
+		{"", defaultPipeline, false},
+		{"[app:proxy-server]\ntempauth_enabled = false\n", keystonePipeline, false},
+		{"[pipeline:main]\npipeline = catch_errors  tempauth proxy-server\n", []string{"catch_errors", "tempauth"}, false},
+		{"[pipeline:main]\npipeline = tempauth tempurl\n", []string{"tempauth", "tempurl"}, false},
+		{"[pipeline:main]\npipeline = catch_errors gatekeeper healthcheck proxy-logging cache tempauth proxy-server\n", defaultPipeline, true},
+		{"[pipeline:main]\npipeline = tempauth tempurl proxy-server\n", defaultPipeline, true},
+		{"[pipeline:main]\npipeline = authtoken keystoneauth gatekeeper proxy-server\n[app:proxy-server]\ntempauth_enabled = false\n", keystonePipeline, true},
+	} {
+		config, err := conf.StringConfig(test.config)
+		require.Nil(t, err)
+		names, ignored := pipelineNames(config)
+		require.Equal(t, test.names, names, test.config)
+		require.Equal(t, test.ignored, ignored != nil, test.config)
+	}
+
+	config, err := conf.StringConfig("[pipeline:main]\npipeline = catch_errors  tempauth proxy-server\n")
+	require.Nil(t, err)
+
+	server := &ProxyServer{logger: zap.NewNop(), metricsScope: tally.NoopScope, router: http.NotFoundHandler()}
+	_, err = server.buildPipeline(config)
+	require.Nil(t, err)
+	config, err = conf.StringConfig("[pipeline:main]\npipeline = tempauth tempurl\n")
+	require.Nil(t, err)
+	_, err = server.buildPipeline(config)
+	require.NotNil(t, err)
+}
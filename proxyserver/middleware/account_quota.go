@@ -28,7 +28,7 @@ import (
 func accountQuota(metric tally.Counter) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-			if !(request.Method == "PUT" || request.Method == "POST") {
+			if !(request.Method == "PUT" || request.Method == "POST" || request.Method == "APPEND") {
 				next.ServeHTTP(writer, request)
 				return
 			}
@@ -0,0 +1,180 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/uber-go/tally"
+)
+
+// authChallengeMaxBody is as much of a 401 or 403 body from further down the
+// pipeline as is kept to use as the message.
+const authChallengeMaxBody = 4096
+
+// authChallenge rewrites the 401 and 403 responses of the Swift API, from
+// whichever auth middleware or handler sent them, into a consistent HTML or
+// JSON body with an optional link to the deployment's docs, and names the
+// realm in WWW-Authenticate challenges. Responses that already have a body
+// format of their own, and those to S3 requests, are left alone.
+type authChallenge struct {
+	next                http.Handler
+	realm               string
+	format              string
+	docsURL             string
+	unauthorizedMessage string
+	forbiddenMessage    string
+}
+
+type authChallengeBody struct {
+	Code    int    `json:"code"`
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	DocsURL string `json:"docs_url,omitempty"`
+}
+
+// authChallengeWriter holds back a 401 or 403 and its body so authChallenge
+// can replace them once the rest of the pipeline is done.
+type authChallengeWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *authChallengeWriter) WriteHeader(status int) {
+	contentType := w.Header().Get("Content-Type")
+	if (status == http.StatusUnauthorized || status == http.StatusForbidden) &&
+		(contentType == "" || strings.HasPrefix(contentType, "text/html")) {
+		w.status = status
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *authChallengeWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		return w.ResponseWriter.Write(b)
+	}
+	if room := authChallengeMaxBody - w.body.Len(); room > 0 {
+		if len(b) > room {
+			w.body.Write(b[:room])
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return len(b), nil
+}
+
+func (w *authChallengeWriter) Flush() {
+	if w.status == 0 {
+		if f, ok := w.ResponseWriter.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+}
+
+// message returns the text of the held back body when it's something more
+// specific than a standard error page, or else the configured message.
+func (ac *authChallenge) message(status int, body string) string {
+	if body = strings.TrimSpace(body); body != "" && !strings.HasPrefix(body, "<") {
+		return body
+	}
+	if status == http.StatusUnauthorized {
+		return ac.unauthorizedMessage
+	}
+	return ac.forbiddenMessage
+}
+
+func (ac *authChallenge) wantsJSON(request *http.Request) bool {
+	return ac.format == "json" || (ac.format == "auto" && strings.Contains(request.Header.Get("Accept"), "application/json"))
+}
+
+func (ac *authChallenge) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	w := &authChallengeWriter{ResponseWriter: writer}
+	ac.next.ServeHTTP(w, request)
+	if w.status == 0 {
+		return
+	}
+	if ctx := GetProxyContext(request); ctx != nil && ctx.S3Auth != nil {
+		// S3 clients expect S3's own errors.
+		writer.WriteHeader(w.status)
+		writer.Write(w.body.Bytes())
+		return
+	}
+	if w.status == http.StatusUnauthorized && ac.realm != "" && writer.Header().Get("Www-Authenticate") == "" {
+		writer.Header().Set("Www-Authenticate", fmt.Sprintf("Swift realm=\"%s\"", ac.realm))
+	}
+	b := authChallengeBody{Code: w.status, Title: http.StatusText(w.status), Message: ac.message(w.status, w.body.String()), DocsURL: ac.docsURL}
+	var body []byte
+	if ac.wantsJSON(request) {
+		body, _ = json.Marshal(b)
+		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	} else {
+		body = []byte(fmt.Sprintf("<html><h1>%s</h1><p>%s</p>", html.EscapeString(b.Title), html.EscapeString(b.Message)))
+		if b.DocsURL != "" {
+			body = append(body, fmt.Sprintf("<p><a href=\"%s\">%s</a></p>", html.EscapeString(b.DocsURL), html.EscapeString(b.DocsURL))...)
+		}
+		body = append(body, "</html>"...)
+		writer.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	}
+	if request.Method == "HEAD" {
+		body = nil
+	}
+	writer.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	writer.WriteHeader(w.status)
+	writer.Write(body)
+}
+
+func NewAuthChallenge(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
+	format := strings.ToLower(config.GetDefault("format", "html"))
+	if format != "html" && format != "json" && format != "auto" {
+		return nil, fmt.Errorf("invalid format: %s", format)
+	}
+	realm := config.GetDefault("realm", "")
+	if strings.ContainsAny(realm, "\"\\\r\n") {
+		return nil, fmt.Errorf("invalid realm: %q", realm)
+	}
+	docsURL := config.GetDefault("docs_url", "")
+	unauthorizedMessage := config.GetDefault("unauthorized_message", "")
+	forbiddenMessage := config.GetDefault("forbidden_message", "")
+	if realm == "" && format == "html" && docsURL == "" && unauthorizedMessage == "" && forbiddenMessage == "" {
+		// Nothing to change from the standard responses.
+		return func(next http.Handler) http.Handler { return next }, nil
+	}
+	if unauthorizedMessage == "" {
+		unauthorizedMessage = "This server could not verify that you are authorized to access the document you requested."
+	}
+	if forbiddenMessage == "" {
+		forbiddenMessage = "Access was denied to this resource."
+	}
+	return func(next http.Handler) http.Handler {
+		return &authChallenge{
+			next:                next,
+			realm:               realm,
+			format:              format,
+			docsURL:             docsURL,
+			unauthorizedMessage: unauthorizedMessage,
+			forbiddenMessage:    forbiddenMessage,
+		}
+	}, nil
+}
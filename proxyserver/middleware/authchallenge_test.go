@@ -0,0 +1,87 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+)
+
+func authChallengeTestRequest(t *testing.T, settings string, accept string, next http.HandlerFunc) *httptest.ResponseRecorder {
+	config, err := conf.StringConfig("[filter:auth_challenge]\n" + settings)
+	require.Nil(t, err)
+	mid, err := NewAuthChallenge(config.GetSection("filter:auth_challenge"), common.NewTestScope())
+	require.Nil(t, err)
+	req, err := http.NewRequest("GET", "/v1/a/c/o", nil)
+	require.Nil(t, err)
+	req.Header.Set("Accept", accept)
+	w := httptest.NewRecorder()
+	mid(next).ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthChallenge(t *testing.T) {
+	unauthorized := func(writer http.ResponseWriter, request *http.Request) {
+		srv.StandardResponse(writer, http.StatusUnauthorized)
+	}
+	w := authChallengeTestRequest(t, "", "", unauthorized)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, "", w.Header().Get("Www-Authenticate"))
+	require.Contains(t, w.Body.String(), "could not verify")
+
+	w = authChallengeTestRequest(t, "realm = Example Cloud\ndocs_url = https://docs.example.com/auth\n", "", unauthorized)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, "Swift realm=\"Example Cloud\"", w.Header().Get("Www-Authenticate"))
+	require.Equal(t, "text/html; charset=UTF-8", w.Header().Get("Content-Type"))
+	require.Contains(t, w.Body.String(), "<a href=\"https://docs.example.com/auth\">")
+
+	w = authChallengeTestRequest(t, "format = auto\nforbidden_message = Ask your admin.\n", "application/json", func(writer http.ResponseWriter, request *http.Request) {
+		srv.StandardResponse(writer, http.StatusForbidden)
+	})
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	var body authChallengeBody
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Equal(t, authChallengeBody{Code: 403, Title: "Forbidden", Message: "Ask your admin."}, body)
+
+	// A specific reason from the handler is kept as the message.
+	w = authChallengeTestRequest(t, "format = json\n", "", func(writer http.ResponseWriter, request *http.Request) {
+		srv.SimpleErrorResponse(writer, http.StatusForbidden, "Reached container limit of 5")
+	})
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Equal(t, "Reached container limit of 5", body.Message)
+
+	// Bodies in a format of their own pass through.
+	w = authChallengeTestRequest(t, "format = json\n", "", func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/xml")
+		writer.WriteHeader(http.StatusForbidden)
+		writer.Write([]byte("<Error><Code>AccessDenied</Code></Error>"))
+	})
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Equal(t, "<Error><Code>AccessDenied</Code></Error>", w.Body.String())
+
+	config, err := conf.StringConfig("[filter:auth_challenge]\nformat = xml\n")
+	require.Nil(t, err)
+	_, err = NewAuthChallenge(config.GetSection("filter:auth_challenge"), common.NewTestScope())
+	require.NotNil(t, err)
+}
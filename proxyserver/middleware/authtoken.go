@@ -284,7 +284,7 @@ func (at *authToken) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			r.Header.Set("X-Service-Identity-Status", "Invalid")
 		}
 	}
-	if proxyCtx.S3Auth != nil {
+	if proxyCtx.S3Auth != nil && !proxyCtx.S3Auth.Anonymous {
 		// Handle S3 auth validation first
 		userToken, userTokenValid := at.validateS3Signature(r.Context(), proxyCtx)
 		if userToken != nil && userTokenValid {
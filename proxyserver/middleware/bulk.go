@@ -103,38 +103,13 @@ func (b *bulkPut) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	if ok, _ := strconv.ParseBool(request.Header.Get("X-Detect-Content-Type")); ok {
 		contentType = ""
 	}
-	accept := request.Header.Get("Accept")
-	outputType := "text"
-	if strings.Contains(accept, "/json") {
-		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
-		outputType = "json"
-	} else if strings.Contains(accept, "/xml") {
-		writer.Header().Set("Content-Type", "application/xml; charset=utf-8")
-		outputType = "xml"
-	} else {
-		writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	}
+	outputType := bulkOutputType(writer, request)
 	writer.Header().Set("Transfer-Encoding", "chunked")
 	writer.WriteHeader(http.StatusOK)
 	if outputType == "xml" {
 		writer.Write([]byte(xml.Header))
 	}
-	stopTheSpaces := make(chan struct{})
-	hasEmittedSpaces := make(chan bool)
-	go func() {
-		spacesWereEmitted := false
-		for {
-			select {
-			case <-time.After(b.yieldFrequency):
-				writer.Write([]byte("  "))
-				spacesWereEmitted = true
-			case <-stopTheSpaces:
-				hasEmittedSpaces <- spacesWereEmitted
-				close(hasEmittedSpaces)
-				return
-			}
-		}
-	}()
+	hb := startHeartbeat(writer, b.yieldFrequency)
 	ctx := GetProxyContext(request)
 	numberFilesCreated := 0
 	failures := [][]string{}
@@ -213,11 +188,7 @@ func (b *bulkPut) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 		responseStatus = http.StatusBadRequest
 		responseBody = "Invalid Tar File: No Valid Files"
 	}
-	close(stopTheSpaces)
-	if <-hasEmittedSpaces {
-		// Not sure why, but the Swift code uses \r\n here and \n everywhere else.
-		writer.Write([]byte("\r\n\r\n"))
-	}
+	hb.end()
 	switch outputType {
 	case "json":
 		type js struct {
@@ -289,39 +260,29 @@ type bulkDelete struct {
 	maxFailedDeletes     int
 }
 
-func (b *bulkDelete) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+// bulkOutputType picks the results format, "json", "xml" or "text", from the
+// request's Accept header and sets the response's Content-Type to match.
+func bulkOutputType(writer http.ResponseWriter, request *http.Request) string {
 	accept := request.Header.Get("Accept")
-	outputType := "text"
 	if strings.Contains(accept, "/json") {
 		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
-		outputType = "json"
+		return "json"
 	} else if strings.Contains(accept, "/xml") {
 		writer.Header().Set("Content-Type", "application/xml; charset=utf-8")
-		outputType = "xml"
-	} else {
-		writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		return "xml"
 	}
+	writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	return "text"
+}
+
+func (b *bulkDelete) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	outputType := bulkOutputType(writer, request)
 	writer.Header().Set("Transfer-Encoding", "chunked")
 	writer.WriteHeader(http.StatusOK)
 	if outputType == "xml" {
 		writer.Write([]byte(xml.Header))
 	}
-	stopTheSpaces := make(chan struct{})
-	hasEmittedSpaces := make(chan bool)
-	go func() {
-		spacesWereEmitted := false
-		for {
-			select {
-			case <-time.After(b.yieldFrequency):
-				writer.Write([]byte("  "))
-				spacesWereEmitted = true
-			case <-stopTheSpaces:
-				hasEmittedSpaces <- spacesWereEmitted
-				close(hasEmittedSpaces)
-				return
-			}
-		}
-	}()
+	hb := startHeartbeat(writer, b.yieldFrequency)
 	ctx := GetProxyContext(request)
 	apiReq, account, _, _ := getPathSegments(request.URL.Path)
 	numberDeleted := 0
@@ -421,11 +382,13 @@ func (b *bulkDelete) ServeHTTP(writer http.ResponseWriter, request *http.Request
 		responseStatus = http.StatusRequestEntityTooLarge
 		responseBody = fmt.Sprintf("Maximum Bulk Deletes: %d per request", b.maxDeletesPerRequest)
 	}
-	close(stopTheSpaces)
-	if <-hasEmittedSpaces {
-		// Not sure why, but the Swift code uses \r\n here and \n everywhere else.
-		writer.Write([]byte("\r\n\r\n"))
-	}
+	hb.end()
+	writeBulkDeleteResults(writer, outputType, responseStatus, responseBody, numberDeleted, numberNotFound, failures)
+}
+
+// writeBulkDeleteResults writes the summary of a delete of many objects, as
+// returned by bulk delete and SLO delete, in the given output type.
+func writeBulkDeleteResults(writer io.Writer, outputType string, responseStatus int, responseBody string, numberDeleted, numberNotFound int, failures [][]string) {
 	switch outputType {
 	case "json":
 		type js struct {
@@ -488,7 +451,6 @@ func (b *bulkDelete) ServeHTTP(writer http.ResponseWriter, request *http.Request
 			writer.Write([]byte(fmt.Sprintf("%s, %s\n", failure[0], failure[1])))
 		}
 	}
-	return
 }
 
 func processBulkTar(r io.Reader, f func(name string, header http.Header, reader io.Reader)) error {
@@ -0,0 +1,124 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/uber-go/tally"
+)
+
+// cacheControl makes the caching headers of object GETs and HEADs
+// predictable for browsers and CDNs: Last-Modified always comes from the
+// object's X-Timestamp, Etags are all strong or all weak, and Cache-Control
+// can be given a default per container.
+//
+// Containers override the filter's settings with X-Container-Meta-Etag-Policy
+// (strong or weak), X-Container-Meta-Cache-Control and
+// X-Container-Meta-Cache-Control-Override; with the override true, the
+// container's Cache-Control replaces any the object was uploaded with instead
+// of only filling in for objects without one.
+type cacheControl struct {
+	next                 http.Handler
+	etagPolicy           string
+	defaultCacheControl  string
+	overrideCacheControl bool
+}
+
+func validEtagPolicy(policy string) bool {
+	return policy == "strong" || policy == "weak"
+}
+
+// formatEtag returns etag, quoted or not and weak or not, in the form policy
+// asks for.
+func formatEtag(etag, policy string) string {
+	etag = strings.Trim(strings.TrimPrefix(etag, "W/"), "\"")
+	if policy == "weak" {
+		return "W/\"" + etag + "\""
+	}
+	return "\"" + etag + "\""
+}
+
+func (cc *cacheControl) fixHeaders(request *http.Request, header http.Header, account, container string) {
+	if ts := header.Get("X-Timestamp"); ts != "" {
+		if t, err := common.ParseDate(ts); err == nil {
+			header.Set("Last-Modified", common.FormatLastModified(t))
+		}
+	}
+	etagPolicy := cc.etagPolicy
+	cacheControl := cc.defaultCacheControl
+	override := cc.overrideCacheControl
+	if ctx := GetProxyContext(request); ctx != nil && ctx.C != nil {
+		if ci, err := ctx.C.GetContainerInfo(request.Context(), account, container); err == nil {
+			if p := strings.ToLower(ci.Metadata["Etag-Policy"]); validEtagPolicy(p) {
+				etagPolicy = p
+			}
+			if v, ok := ci.Metadata["Cache-Control"]; ok && v != "" {
+				cacheControl = v
+			}
+			if v, ok := ci.Metadata["Cache-Control-Override"]; ok && v != "" {
+				override = common.LooksTrue(v)
+			}
+		}
+	}
+	if etag := header.Get("Etag"); etag != "" {
+		header.Set("Etag", formatEtag(etag, etagPolicy))
+	}
+	if cacheControl != "" && (override || header.Get("Cache-Control") == "") {
+		header.Set("Cache-Control", cacheControl)
+	}
+}
+
+func (cc *cacheControl) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	apiReq, account, container, object := getPathParts(request)
+	if !apiReq || object == "" || (request.Method != "GET" && request.Method != "HEAD") {
+		cc.next.ServeHTTP(writer, request)
+		return
+	}
+	if ctx := GetProxyContext(request); ctx != nil && ctx.S3Auth != nil {
+		// s3api has its own idea of what an ETag looks like.
+		cc.next.ServeHTTP(writer, request)
+		return
+	}
+	cc.next.ServeHTTP(srv.NewCustomWriter(writer, func(w http.ResponseWriter, status int) int {
+		if status == http.StatusOK || status == http.StatusPartialContent || status == http.StatusNotModified {
+			cc.fixHeaders(request, w.Header(), account, container)
+		}
+		return status
+	}), request)
+}
+
+func NewCacheControl(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
+	etagPolicy := strings.ToLower(config.GetDefault("etag_policy", "strong"))
+	if !validEtagPolicy(etagPolicy) {
+		return nil, fmt.Errorf("invalid etag_policy: %s", etagPolicy)
+	}
+	defaultCacheControl := config.GetDefault("default_cache_control", "")
+	overrideCacheControl := config.GetBool("override_cache_control", false)
+	return func(next http.Handler) http.Handler {
+		return &cacheControl{
+			next:                 next,
+			etagPolicy:           etagPolicy,
+			defaultCacheControl:  defaultCacheControl,
+			overrideCacheControl: overrideCacheControl,
+		}
+	}, nil
+}
@@ -0,0 +1,103 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/client"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/common/test"
+	"go.uber.org/zap"
+)
+
+func cacheControlTestRequest(t *testing.T, settings string, containerMeta map[string]string, objectHeaders map[string]string) http.Header {
+	config, err := conf.StringConfig("[filter:cache_control]\n" + settings)
+	require.Nil(t, err)
+	mid, err := NewCacheControl(config.GetSection("filter:cache_control"), common.NewTestScope())
+	require.Nil(t, err)
+	h := mid(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("X-Timestamp", "1500000000.12345")
+		writer.Header().Set("Etag", "\"d41d8cd98f00b204e9800998ecf8427e\"")
+		for k, v := range objectHeaders {
+			writer.Header().Set(k, v)
+		}
+		writer.WriteHeader(http.StatusOK)
+	}))
+	f, err := client.NewProxyClient(staticPolicyList, srv.NewTestConfigLoader(&test.FakeRing{}),
+		nil, "", "", "", "", "", conf.Config{})
+	require.Nil(t, err)
+	req, err := http.NewRequest("GET", "/v1/a/c/o", nil)
+	require.Nil(t, err)
+	req = req.WithContext(context.WithValue(req.Context(), "proxycontext", &ProxyContext{
+		Logger: zap.NewNop(),
+		C:      f.NewRequestClient(nil, map[string]*client.ContainerInfo{"container/a/c": {Metadata: containerMeta}}, zap.NewNop()),
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	return w.Header()
+}
+
+func TestCacheControlDefaults(t *testing.T) {
+	h := cacheControlTestRequest(t, "", map[string]string{}, map[string]string{"Last-Modified": "bogus"})
+	require.Equal(t, "Fri, 14 Jul 2017 02:40:01 GMT", h.Get("Last-Modified"))
+	require.Equal(t, "\"d41d8cd98f00b204e9800998ecf8427e\"", h.Get("Etag"))
+	require.Equal(t, "", h.Get("Cache-Control"))
+
+	h = cacheControlTestRequest(t, "", map[string]string{}, map[string]string{"Etag": "W/d41d8cd98f00b204e9800998ecf8427e"})
+	require.Equal(t, "\"d41d8cd98f00b204e9800998ecf8427e\"", h.Get("Etag"))
+}
+
+func TestCacheControlWeakEtags(t *testing.T) {
+	h := cacheControlTestRequest(t, "etag_policy = weak", map[string]string{}, nil)
+	require.Equal(t, "W/\"d41d8cd98f00b204e9800998ecf8427e\"", h.Get("Etag"))
+
+	h = cacheControlTestRequest(t, "etag_policy = weak", map[string]string{"Etag-Policy": "strong"}, nil)
+	require.Equal(t, "\"d41d8cd98f00b204e9800998ecf8427e\"", h.Get("Etag"))
+
+	config, err := conf.StringConfig("[filter:cache_control]\netag_policy = medium\n")
+	require.Nil(t, err)
+	_, err = NewCacheControl(config.GetSection("filter:cache_control"), common.NewTestScope())
+	require.NotNil(t, err)
+}
+
+func TestCacheControlInjection(t *testing.T) {
+	h := cacheControlTestRequest(t, "default_cache_control = max-age=60", map[string]string{}, nil)
+	require.Equal(t, "max-age=60", h.Get("Cache-Control"))
+
+	// The object's own Cache-Control is passed through...
+	h = cacheControlTestRequest(t, "default_cache_control = max-age=60", map[string]string{}, map[string]string{"Cache-Control": "no-cache"})
+	require.Equal(t, "no-cache", h.Get("Cache-Control"))
+
+	// ...and the container's setting fills in for objects without one...
+	h = cacheControlTestRequest(t, "default_cache_control = max-age=60", map[string]string{"Cache-Control": "public, max-age=86400"}, nil)
+	require.Equal(t, "public, max-age=86400", h.Get("Cache-Control"))
+	h = cacheControlTestRequest(t, "", map[string]string{"Cache-Control": "public, max-age=86400"}, map[string]string{"Cache-Control": "no-cache"})
+	require.Equal(t, "no-cache", h.Get("Cache-Control"))
+
+	// ...unless it overrides.
+	h = cacheControlTestRequest(t, "", map[string]string{"Cache-Control": "public, max-age=86400", "Cache-Control-Override": "true"}, map[string]string{"Cache-Control": "no-cache"})
+	require.Equal(t, "public, max-age=86400", h.Get("Cache-Control"))
+	h = cacheControlTestRequest(t, "default_cache_control = max-age=60\noverride_cache_control = true", map[string]string{}, map[string]string{"Cache-Control": "no-cache"})
+	require.Equal(t, "max-age=60", h.Get("Cache-Control"))
+}
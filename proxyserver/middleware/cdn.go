@@ -0,0 +1,208 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/uber-go/tally"
+)
+
+// cdn lets a CDN pull objects from CDN-backed containers, flagged with
+// X-Container-Meta-Cdn-Enabled, by signing its origin requests, and purges
+// objects from the CDN when they're overwritten or deleted.
+//
+// Origin pulls carry X-Cdn-Origin-Expires, a unix time, and
+// X-Cdn-Origin-Signature, the hex HMAC-SHA256 with origin_pull_key of
+// "<method>\n<expires>\n<path>", like a temp URL's.
+type cdn struct {
+	next             http.Handler
+	originPullKey    []byte
+	cdnURL           string
+	purger           cdnPurger
+	purgeSem         chan struct{}
+	purgeWG          sync.WaitGroup
+	originPullMetric tally.Counter
+	purgeMetric      tally.Counter
+	purgeErrorMetric tally.Counter
+	purgeDropMetric  tally.Counter
+}
+
+func cdnOriginSignature(key []byte, method, path string, expires int64) []byte {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%d\n%s", method, expires, path)
+	return mac.Sum(nil)
+}
+
+func (c *cdn) checkOriginPull(request *http.Request, exps, sig string) bool {
+	if len(c.originPullKey) == 0 || (request.Method != "GET" && request.Method != "HEAD") {
+		return false
+	}
+	expires, err := strconv.ParseInt(exps, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	sigb, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(sigb, cdnOriginSignature(c.originPullKey, request.Method, request.URL.Path, expires))
+}
+
+// purgeURL returns the CDN URL of the object, or "" if its container isn't
+// CDN-backed.
+func (c *cdn) purgeURL(request *http.Request, account, container, object string) string {
+	ctx := GetProxyContext(request)
+	ci, err := ctx.C.GetContainerInfo(request.Context(), account, container)
+	if err != nil || !common.LooksTrue(ci.Metadata["Cdn-Enabled"]) {
+		return ""
+	}
+	base := c.cdnURL
+	if u := ci.Metadata["Cdn-Url"]; u != "" {
+		base = u
+	}
+	if base == "" {
+		return ""
+	}
+	return strings.TrimRight(base, "/") + "/v1/" + common.Urlencode(account) + "/" + common.Urlencode(container) + "/" + common.Urlencode(object)
+}
+
+func (c *cdn) purge(urls []string) {
+	select {
+	case c.purgeSem <- struct{}{}:
+	default:
+		c.purgeDropMetric.Inc(int64(len(urls)))
+		return
+	}
+	c.purgeWG.Add(1)
+	go func() {
+		defer c.purgeWG.Done()
+		defer func() { <-c.purgeSem }()
+		c.purgeMetric.Inc(int64(len(urls)))
+		if err := c.purger.purge(urls); err != nil {
+			c.purgeErrorMetric.Inc(1)
+		}
+	}()
+}
+
+func (c *cdn) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	apiReq, account, container, object := getPathParts(request)
+	ctx := GetProxyContext(request)
+	if !apiReq || object == "" || ctx == nil {
+		c.next.ServeHTTP(writer, request)
+		return
+	}
+	exps := request.Header.Get("X-Cdn-Origin-Expires")
+	sig := request.Header.Get("X-Cdn-Origin-Signature")
+	if exps != "" || sig != "" {
+		request.Header.Del("X-Cdn-Origin-Expires")
+		request.Header.Del("X-Cdn-Origin-Signature")
+		if !c.checkOriginPull(request, exps, sig) {
+			srv.StandardResponse(writer, http.StatusUnauthorized)
+			return
+		}
+		ci, err := ctx.C.GetContainerInfo(request.Context(), account, container)
+		if err != nil || !common.LooksTrue(ci.Metadata["Cdn-Enabled"]) {
+			srv.StandardResponse(writer, http.StatusUnauthorized)
+			return
+		}
+		c.originPullMetric.Inc(1)
+		ctx.RemoteUsers = []string{".cdn"}
+		ctx.Authorize = func(r *http.Request) (bool, int) {
+			ar, a, cn, _ := getPathParts(r)
+			if ar && a == account && cn == container && (r.Method == "GET" || r.Method == "HEAD") {
+				return true, http.StatusOK
+			}
+			return false, http.StatusUnauthorized
+		}
+		c.next.ServeHTTP(writer, request)
+		return
+	}
+	if c.purger == nil || (request.Method != "PUT" && request.Method != "POST" && request.Method != "DELETE" && request.Method != "COPY") {
+		c.next.ServeHTTP(writer, request)
+		return
+	}
+	w := &srv.WebWriter{ResponseWriter: writer, Status: http.StatusInternalServerError}
+	c.next.ServeHTTP(w, request)
+	if w.Status/100 != 2 {
+		return
+	}
+	if request.Method == "COPY" {
+		dest, err := url.PathUnescape(request.Header.Get("Destination"))
+		if err != nil {
+			return
+		}
+		parts := strings.SplitN(strings.TrimPrefix(dest, "/"), "/", 2)
+		if len(parts) != 2 {
+			return
+		}
+		if a := request.Header.Get("Destination-Account"); a != "" {
+			account = a
+		}
+		container, object = parts[0], parts[1]
+	}
+	if u := c.purgeURL(request, account, container, object); u != "" {
+		c.purge([]string{u})
+	}
+}
+
+func NewCdn(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
+	if !config.GetBool("enabled", false) {
+		return func(next http.Handler) http.Handler {
+			return next
+		}, nil
+	}
+	purger, err := newCdnPurger(config)
+	if err != nil {
+		return nil, err
+	}
+	originPullKey := []byte(config.GetDefault("origin_pull_key", ""))
+	cdnURL := config.GetDefault("cdn_url", "")
+	purgeConcurrency := config.GetInt("purge_concurrency", 4)
+	if purgeConcurrency < 1 {
+		purgeConcurrency = 1
+	}
+	originPullMetric := metricsScope.Counter("cdn_origin_pulls")
+	purgeMetric := metricsScope.Counter("cdn_purges")
+	purgeErrorMetric := metricsScope.Counter("cdn_purge_errors")
+	purgeDropMetric := metricsScope.Counter("cdn_purges_dropped")
+	purgeSem := make(chan struct{}, purgeConcurrency)
+	return func(next http.Handler) http.Handler {
+		return &cdn{
+			next:             next,
+			originPullKey:    originPullKey,
+			cdnURL:           cdnURL,
+			purger:           purger,
+			purgeSem:         purgeSem,
+			originPullMetric: originPullMetric,
+			purgeMetric:      purgeMetric,
+			purgeErrorMetric: purgeErrorMetric,
+			purgeDropMetric:  purgeDropMetric,
+		}
+	}, nil
+}
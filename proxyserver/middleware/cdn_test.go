@@ -0,0 +1,189 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/client"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/common/test"
+	"go.uber.org/zap"
+)
+
+type fakeCdnPurger struct {
+	lock sync.Mutex
+	urls []string
+}
+
+func (p *fakeCdnPurger) purge(urls []string) error {
+	p.lock.Lock()
+	p.urls = append(p.urls, urls...)
+	p.lock.Unlock()
+	return nil
+}
+
+func newTestCdn(t *testing.T, purger cdnPurger) *cdn {
+	config, err := conf.StringConfig("[filter:cdn]\nenabled = true\norigin_pull_key = sekrit\ncdn_url = https://cdn.example.com/\n")
+	require.Nil(t, err)
+	mid, err := NewCdn(config.GetSection("filter:cdn"), common.NewTestScope())
+	require.Nil(t, err)
+	c := mid(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if ctx := GetProxyContext(request); ctx.Authorize != nil {
+			if ok, s := ctx.Authorize(request); !ok {
+				srv.StandardResponse(writer, s)
+				return
+			}
+		}
+		writer.WriteHeader(http.StatusCreated)
+	})).(*cdn)
+	c.purger = purger
+	return c
+}
+
+func cdnTestRequest(t *testing.T, h http.Handler, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	f, err := client.NewProxyClient(staticPolicyList, srv.NewTestConfigLoader(&test.FakeRing{}),
+		nil, "", "", "", "", "", conf.Config{})
+	require.Nil(t, err)
+	req, err := http.NewRequest(method, path, nil)
+	require.Nil(t, err)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req = req.WithContext(context.WithValue(req.Context(), "proxycontext", &ProxyContext{
+		Logger: zap.NewNop(),
+		C: f.NewRequestClient(nil, map[string]*client.ContainerInfo{
+			"container/a/c":     {Metadata: map[string]string{"Cdn-Enabled": "true"}},
+			"container/a/other": {Metadata: map[string]string{"Cdn-Enabled": "true", "Cdn-Url": "https://other.example.com"}},
+			"container/a/plain": {Metadata: map[string]string{}},
+		}, zap.NewNop()),
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func signedOriginHeaders(method, path string, expires int64) map[string]string {
+	return map[string]string{
+		"X-Cdn-Origin-Expires":   fmt.Sprintf("%d", expires),
+		"X-Cdn-Origin-Signature": hex.EncodeToString(cdnOriginSignature([]byte("sekrit"), method, path, expires)),
+	}
+}
+
+func TestCdnOriginPull(t *testing.T) {
+	c := newTestCdn(t, nil)
+	expires := time.Now().Unix() + 60
+	w := cdnTestRequest(t, c, "GET", "/v1/a/c/o", signedOriginHeaders("GET", "/v1/a/c/o", expires))
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = cdnTestRequest(t, c, "HEAD", "/v1/a/c/o", signedOriginHeaders("HEAD", "/v1/a/c/o", expires))
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	// Wrong path, method, expiry, or a container that isn't CDN-backed.
+	w = cdnTestRequest(t, c, "GET", "/v1/a/c/o2", signedOriginHeaders("GET", "/v1/a/c/o", expires))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	w = cdnTestRequest(t, c, "PUT", "/v1/a/c/o", signedOriginHeaders("PUT", "/v1/a/c/o", expires))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	w = cdnTestRequest(t, c, "GET", "/v1/a/c/o", signedOriginHeaders("GET", "/v1/a/c/o", time.Now().Unix()-1))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	w = cdnTestRequest(t, c, "GET", "/v1/a/plain/o", signedOriginHeaders("GET", "/v1/a/plain/o", expires))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	w = cdnTestRequest(t, c, "GET", "/v1/a/c/o", map[string]string{"X-Cdn-Origin-Signature": "abcd"})
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestCdnPurge(t *testing.T) {
+	purger := &fakeCdnPurger{}
+	c := newTestCdn(t, purger)
+	cdnTestRequest(t, c, "PUT", "/v1/a/c/o", nil)
+	cdnTestRequest(t, c, "DELETE", "/v1/a/other/dir/o%202", nil)
+	cdnTestRequest(t, c, "COPY", "/v1/a/plain/src", map[string]string{"Destination": "c/copied"})
+	cdnTestRequest(t, c, "PUT", "/v1/a/plain/o", nil)
+	cdnTestRequest(t, c, "GET", "/v1/a/c/o", nil)
+	c.purgeWG.Wait()
+	purger.lock.Lock()
+	defer purger.lock.Unlock()
+	require.ElementsMatch(t, []string{
+		"https://cdn.example.com/v1/a/c/o",
+		"https://other.example.com/v1/a/other/dir/o%202",
+		"https://cdn.example.com/v1/a/c/copied",
+	}, purger.urls)
+}
+
+func TestCdnPurgers(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		requests = append(requests, request)
+		bodies = append(bodies, string(body))
+		writer.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+	newPurger := func(settings string) cdnPurger {
+		config, err := conf.StringConfig("[filter:cdn]\n" + settings)
+		require.Nil(t, err)
+		p, err := newCdnPurger(config.GetSection("filter:cdn"))
+		require.Nil(t, err)
+		return p
+	}
+
+	p := newPurger(fmt.Sprintf("purge_provider = fastly\nfastly_endpoint = %s\nfastly_api_key = key", ts.URL))
+	require.Nil(t, p.purge([]string{"https://cdn.example.com/v1/a/c/o"}))
+	require.Equal(t, "/purge/cdn.example.com/v1/a/c/o", requests[0].URL.Path)
+	require.Equal(t, "key", requests[0].Header.Get("Fastly-Key"))
+
+	p = newPurger(fmt.Sprintf("purge_provider = cloudfront\ncloudfront_endpoint = %s\ncloudfront_distribution_id = DIST\naws_access_key = AK\naws_secret_key = SK", ts.URL))
+	require.Nil(t, p.purge([]string{"https://cdn.example.com/v1/a/c/o", "https://cdn.example.com/v1/a/c/o2"}))
+	require.Equal(t, "/2020-05-31/distribution/DIST/invalidation", requests[1].URL.Path)
+	require.True(t, strings.HasPrefix(requests[1].Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AK/"))
+	require.Contains(t, bodies[1], "<Quantity>2</Quantity><Items><Path>/v1/a/c/o</Path><Path>/v1/a/c/o2</Path></Items>")
+
+	p = newPurger(fmt.Sprintf("purge_provider = akamai\nakamai_host = %s\nakamai_client_token = ct\nakamai_client_secret = cs\nakamai_access_token = at", ts.URL))
+	require.Nil(t, p.purge([]string{"https://cdn.example.com/v1/a/c/o"}))
+	require.Equal(t, "/ccu/v3/invalidate/url/production", requests[2].URL.Path)
+	require.True(t, strings.HasPrefix(requests[2].Header.Get("Authorization"), "EG1-HMAC-SHA256 client_token=ct;access_token=at;"))
+	var objects map[string][]string
+	require.Nil(t, json.Unmarshal([]byte(bodies[2]), &objects))
+	require.Equal(t, []string{"https://cdn.example.com/v1/a/c/o"}, objects["objects"])
+
+	config, err := conf.StringConfig("[filter:cdn]\npurge_provider = fastly\n")
+	require.Nil(t, err)
+	_, err = newCdnPurger(config.GetSection("filter:cdn"))
+	require.NotNil(t, err)
+}
+
+func TestSignAWSv4(t *testing.T) {
+	// The "get-vanilla" case from AWS's signature version 4 test suite.
+	req, err := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	require.Nil(t, err)
+	now, err := time.Parse("20060102T150405Z", "20150830T123600Z")
+	require.Nil(t, err)
+	signAWSv4(req, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service", now)
+	require.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31", req.Header.Get("Authorization"))
+}
@@ -0,0 +1,248 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+)
+
+// cdnPurger removes URLs from a CDN's caches.
+type cdnPurger interface {
+	purge(urls []string) error
+}
+
+func newCdnPurger(config conf.Section) (cdnPurger, error) {
+	client := &http.Client{Timeout: time.Duration(config.GetInt("purge_timeout", 30)) * time.Second}
+	switch provider := config.GetDefault("purge_provider", ""); provider {
+	case "":
+		return nil, nil
+	case "fastly":
+		p := &fastlyPurger{
+			client:   client,
+			endpoint: config.GetDefault("fastly_endpoint", "https://api.fastly.com"),
+			apiKey:   config.GetDefault("fastly_api_key", ""),
+		}
+		if p.apiKey == "" {
+			return nil, fmt.Errorf("cdn purge_provider fastly needs fastly_api_key")
+		}
+		return p, nil
+	case "cloudfront":
+		p := &cloudfrontPurger{
+			client:         client,
+			endpoint:       config.GetDefault("cloudfront_endpoint", "https://cloudfront.amazonaws.com"),
+			distributionID: config.GetDefault("cloudfront_distribution_id", ""),
+			accessKey:      config.GetDefault("aws_access_key", ""),
+			secretKey:      config.GetDefault("aws_secret_key", ""),
+		}
+		if p.distributionID == "" || p.accessKey == "" || p.secretKey == "" {
+			return nil, fmt.Errorf("cdn purge_provider cloudfront needs cloudfront_distribution_id, aws_access_key and aws_secret_key")
+		}
+		return p, nil
+	case "akamai":
+		p := &akamaiPurger{
+			client:       client,
+			host:         config.GetDefault("akamai_host", ""),
+			clientToken:  config.GetDefault("akamai_client_token", ""),
+			clientSecret: config.GetDefault("akamai_client_secret", ""),
+			accessToken:  config.GetDefault("akamai_access_token", ""),
+			network:      config.GetDefault("akamai_network", "production"),
+		}
+		if p.host == "" || p.clientToken == "" || p.clientSecret == "" || p.accessToken == "" {
+			return nil, fmt.Errorf("cdn purge_provider akamai needs akamai_host, akamai_client_token, akamai_client_secret and akamai_access_token")
+		}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unknown cdn purge_provider: %s", provider)
+	}
+}
+
+func doPurgeRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("purge request to %s returned %d", req.URL.Host, resp.StatusCode)
+	}
+	return nil
+}
+
+// fastlyPurger purges URLs one at a time with Fastly's purge API.
+type fastlyPurger struct {
+	client   *http.Client
+	endpoint string
+	apiKey   string
+}
+
+func (p *fastlyPurger) purge(urls []string) error {
+	var firstErr error
+	for _, u := range urls {
+		cached := u
+		if i := strings.Index(cached, "://"); i >= 0 {
+			cached = cached[i+3:]
+		}
+		req, err := http.NewRequest("POST", p.endpoint+"/purge/"+cached, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Fastly-Key", p.apiKey)
+		req.Header.Set("Accept", "application/json")
+		if err := doPurgeRequest(p.client, req); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// cloudfrontPurger creates a CloudFront invalidation for the URLs' paths.
+type cloudfrontPurger struct {
+	client         *http.Client
+	endpoint       string
+	distributionID string
+	accessKey      string
+	secretKey      string
+}
+
+type cloudfrontInvalidationBatch struct {
+	XMLName         xml.Name `xml:"http://cloudfront.amazonaws.com/doc/2020-05-31/ InvalidationBatch"`
+	Quantity        int      `xml:"Paths>Quantity"`
+	Paths           []string `xml:"Paths>Items>Path"`
+	CallerReference string   `xml:"CallerReference"`
+}
+
+func (p *cloudfrontPurger) purge(urls []string) error {
+	batch := cloudfrontInvalidationBatch{CallerReference: common.UUID()}
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return err
+		}
+		batch.Paths = append(batch.Paths, parsed.EscapedPath())
+	}
+	batch.Quantity = len(batch.Paths)
+	body, err := xml.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", p.endpoint+"/2020-05-31/distribution/"+url.PathEscape(p.distributionID)+"/invalidation", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/xml")
+	signAWSv4(req, body, p.accessKey, p.secretKey, "us-east-1", "cloudfront", time.Now())
+	return doPurgeRequest(p.client, req)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	io.WriteString(mac, data)
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// signAWSv4 adds an AWS signature version 4 Authorization to req, signing
+// only its host and date.
+func signAWSv4(req *http.Request, body []byte, accessKey, secretKey, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\nx-amz-date:" + amzDate + "\n",
+		"host;x-amz-date",
+		sha256Hex(body),
+	}, "\n")
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=host;x-amz-date, Signature=%s",
+		accessKey, scope, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+}
+
+// akamaiPurger invalidates URLs with Akamai's Fast Purge API, signing its
+// requests with EdgeGrid.
+type akamaiPurger struct {
+	client       *http.Client
+	host         string
+	clientToken  string
+	clientSecret string
+	accessToken  string
+	network      string
+}
+
+func (p *akamaiPurger) purge(urls []string) error {
+	body, err := json.Marshal(map[string][]string{"objects": urls})
+	if err != nil {
+		return err
+	}
+	host := p.host
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	req, err := http.NewRequest("POST", host+"/ccu/v3/invalidate/url/"+url.PathEscape(p.network), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signEdgeGrid(req, body, p.clientToken, p.clientSecret, p.accessToken, common.UUID(), time.Now())
+	return doPurgeRequest(p.client, req)
+}
+
+// signEdgeGrid adds an Akamai EG1-HMAC-SHA256 Authorization to req.
+func signEdgeGrid(req *http.Request, body []byte, clientToken, clientSecret, accessToken, nonce string, now time.Time) {
+	timestamp := now.UTC().Format("20060102T15:04:05+0000")
+	auth := fmt.Sprintf("EG1-HMAC-SHA256 client_token=%s;access_token=%s;timestamp=%s;nonce=%s;", clientToken, accessToken, timestamp, nonce)
+	bodyHash := ""
+	if req.Method == "POST" && len(body) > 0 {
+		sum := sha256.Sum256(body)
+		bodyHash = base64.StdEncoding.EncodeToString(sum[:])
+	}
+	path := req.URL.EscapedPath()
+	if req.URL.RawQuery != "" {
+		path += "?" + req.URL.RawQuery
+	}
+	data := strings.Join([]string{req.Method, req.URL.Scheme, req.URL.Host, path, "", bodyHash, auth}, "\t")
+	signingKey := base64.StdEncoding.EncodeToString(hmacSHA256([]byte(clientSecret), timestamp))
+	signature := base64.StdEncoding.EncodeToString(hmacSHA256([]byte(signingKey), data))
+	req.Header.Set("Authorization", auth+"signature="+signature)
+}
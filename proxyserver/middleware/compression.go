@@ -0,0 +1,575 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+// Objects are compressed as a series of independent zstd frames, each
+// holding frameSize bytes of the original data, followed by a seek table in
+// the zstd seekable format. Any zstd decoder can read the whole object, and
+// a range can be served by fetching only the frames that cover it.
+const (
+	compressionSource       = "compression"
+	compressionSysmeta      = "X-Object-Sysmeta-Compression"
+	compressionOverride     = "X-Object-Sysmeta-Container-Update-Override-"
+	seekTableSkippableMagic = 0x184D2A5E
+	seekTableMagic          = 0x8F92EAB1
+	seekTableFooterLen      = 9
+	seekTableChecksumFlag   = 0x80
+)
+
+var errCompressionEtag = errors.New("etag does not match object body")
+
+func seekTableLen(frames int64) int64 {
+	return 8 + 8*frames + seekTableFooterLen
+}
+
+func appendSeekTable(b []byte, frames [][2]uint32) []byte {
+	var scratch [4]byte
+	put := func(v uint32) {
+		binary.LittleEndian.PutUint32(scratch[:], v)
+		b = append(b, scratch[:]...)
+	}
+	put(seekTableSkippableMagic)
+	put(uint32(8*len(frames) + seekTableFooterLen))
+	for _, f := range frames {
+		put(f[0])
+		put(f[1])
+	}
+	put(uint32(len(frames)))
+	b = append(b, 0)
+	put(seekTableMagic)
+	return b
+}
+
+// parseSeekTable returns the compressed size of each frame in the table.
+func parseSeekTable(b []byte, frames int64) ([]int64, error) {
+	if len(b) < 8+seekTableFooterLen {
+		return nil, fmt.Errorf("seek table too short: %d bytes", len(b))
+	}
+	footer := b[len(b)-seekTableFooterLen:]
+	if binary.LittleEndian.Uint32(b) != seekTableSkippableMagic || binary.LittleEndian.Uint32(footer[5:]) != seekTableMagic {
+		return nil, errors.New("invalid seek table magic")
+	}
+	if int64(binary.LittleEndian.Uint32(footer)) != frames {
+		return nil, fmt.Errorf("seek table has %d frames, expected %d", binary.LittleEndian.Uint32(footer), frames)
+	}
+	entryLen := int64(8)
+	if footer[4]&seekTableChecksumFlag != 0 {
+		entryLen = 12
+	}
+	entries := b[8 : len(b)-seekTableFooterLen]
+	if int64(len(entries)) != entryLen*frames {
+		return nil, errors.New("invalid seek table length")
+	}
+	sizes := make([]int64, frames)
+	for i := range sizes {
+		sizes[i] = int64(binary.LittleEndian.Uint32(entries[int64(i)*entryLen:]))
+	}
+	return sizes, nil
+}
+
+// compressReader reads the original object from src and returns the
+// compressed representation, verifying the length and etag the client sent
+// before the final frame is released.
+type compressReader struct {
+	src      io.Reader
+	enc      *zstd.Encoder
+	buf      []byte
+	out      []byte
+	frames   [][2]uint32
+	hash     hash.Hash
+	etag     string
+	expected int64
+	read     int64
+	done     bool
+	err      error
+}
+
+func (cr *compressReader) Read(p []byte) (int, error) {
+	for len(cr.out) == 0 {
+		if cr.err != nil {
+			return 0, cr.err
+		}
+		if cr.done {
+			return 0, io.EOF
+		}
+		cr.err = cr.fill()
+	}
+	n := copy(p, cr.out)
+	cr.out = cr.out[n:]
+	return n, nil
+}
+
+func (cr *compressReader) fill() error {
+	n, err := io.ReadFull(cr.src, cr.buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	cr.read += int64(n)
+	cr.hash.Write(cr.buf[:n])
+	last := err != nil || cr.read == cr.expected
+	if last {
+		if cr.read != cr.expected {
+			return io.ErrUnexpectedEOF
+		}
+		if fmt.Sprintf("%x", cr.hash.Sum(nil)) != cr.etag {
+			return errCompressionEtag
+		}
+	}
+	var frame []byte
+	if n > 0 {
+		frame = cr.enc.EncodeAll(cr.buf[:n], nil)
+		cr.frames = append(cr.frames, [2]uint32{uint32(len(frame)), uint32(n)})
+	}
+	if last {
+		frame = appendSeekTable(frame, cr.frames)
+		cr.done = true
+	}
+	cr.out = frame
+	return nil
+}
+
+type compressionPutWriter struct {
+	http.ResponseWriter
+	cr      *compressReader
+	discard bool
+}
+
+func (w *compressionPutWriter) WriteHeader(status int) {
+	if w.cr.err == errCompressionEtag {
+		w.discard = true
+		srv.StandardResponse(w.ResponseWriter, http.StatusUnprocessableEntity)
+		return
+	}
+	if status/100 == 2 {
+		w.Header().Set("Etag", w.cr.etag)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *compressionPutWriter) Write(b []byte) (int, error) {
+	if w.discard {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressionPutWriter) Flush() {
+	if !w.discard {
+		srv.Flush(w.ResponseWriter)
+	}
+}
+
+type compressionInfo struct {
+	length    int64
+	frameSize int64
+	etag      string
+}
+
+func (ci *compressionInfo) frames() int64 {
+	return (ci.length + ci.frameSize - 1) / ci.frameSize
+}
+
+func getCompressionInfo(header http.Header) (*compressionInfo, error) {
+	if alg := header.Get(compressionSysmeta); alg != "zstd" {
+		return nil, fmt.Errorf("unknown compression algorithm %q", alg)
+	}
+	length, err := strconv.ParseInt(header.Get(compressionSysmeta+"-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid compressed object length %q", header.Get(compressionSysmeta+"-Length"))
+	}
+	frameSize, err := strconv.ParseInt(header.Get(compressionSysmeta+"-Frame-Size"), 10, 64)
+	if err != nil || frameSize <= 0 {
+		return nil, fmt.Errorf("invalid compressed object frame size %q", header.Get(compressionSysmeta+"-Frame-Size"))
+	}
+	return &compressionInfo{length: length, frameSize: frameSize, etag: header.Get(compressionSysmeta + "-Etag")}, nil
+}
+
+// compressionGetWriter identifies compressed objects in responses. Full GETs
+// are decompressed as they are written; ranged responses are swallowed so the
+// range can be served from the seek table afterwards.
+type compressionGetWriter struct {
+	http.ResponseWriter
+	request    *http.Request
+	logger     srv.LowLevelLogger
+	compressed bool
+	status     int
+	info       *compressionInfo
+	pw         *io.PipeWriter
+	done       chan struct{}
+}
+
+func (cw *compressionGetWriter) WriteHeader(status int) {
+	cw.status = status
+	header := cw.Header()
+	if header.Get(compressionSysmeta) == "" {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.compressed = true
+	info, err := getCompressionInfo(header)
+	if err != nil {
+		cw.logger.Error("invalid compression metadata", zap.String("path", cw.request.URL.Path), zap.Error(err))
+		stripCompressionHeaders(header)
+		srv.StandardResponse(cw.ResponseWriter, http.StatusInternalServerError)
+		return
+	}
+	cw.info = info
+	if status == http.StatusPartialContent || status == http.StatusRequestedRangeNotSatisfiable {
+		return
+	}
+	stripCompressionHeaders(header)
+	if info.etag != "" {
+		header.Set("Etag", fmt.Sprintf("\"%s\"", info.etag))
+	}
+	if status == http.StatusOK {
+		header.Set("Content-Length", strconv.FormatInt(info.length, 10))
+		if cw.request.Method == "GET" {
+			cw.startDecoder()
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressionGetWriter) startDecoder() {
+	pr, pw := io.Pipe()
+	cw.pw = pw
+	cw.done = make(chan struct{})
+	go func() {
+		defer close(cw.done)
+		dec, err := zstd.NewReader(pr, zstd.WithDecoderConcurrency(1))
+		if err == nil {
+			_, err = io.Copy(cw.ResponseWriter, dec)
+			dec.Close()
+		}
+		if err != nil {
+			cw.logger.Error("error decompressing object", zap.String("path", cw.request.URL.Path), zap.Error(err))
+		}
+		pr.CloseWithError(err)
+	}()
+}
+
+func (cw *compressionGetWriter) Write(b []byte) (int, error) {
+	if cw.pw != nil {
+		return cw.pw.Write(b)
+	}
+	if cw.compressed {
+		return len(b), nil
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush passes through for responses that aren't compressed; decompressed
+// output is written by the decoder as it goes.
+func (cw *compressionGetWriter) Flush() {
+	if !cw.compressed {
+		srv.Flush(cw.ResponseWriter)
+	}
+}
+
+func (cw *compressionGetWriter) finish() {
+	if cw.pw != nil {
+		cw.pw.Close()
+		<-cw.done
+	}
+}
+
+// compressionSubWriter streams the body of a successful subrequest into a pipe.
+type compressionSubWriter struct {
+	header http.Header
+	status int
+	w      io.Writer
+}
+
+func (sw *compressionSubWriter) Header() http.Header    { return sw.header }
+func (sw *compressionSubWriter) WriteHeader(status int) { sw.status = status }
+func (sw *compressionSubWriter) Write(b []byte) (int, error) {
+	if sw.status/100 != 2 {
+		return len(b), nil
+	}
+	return sw.w.Write(b)
+}
+
+func stripCompressionHeaders(header http.Header) {
+	RemoveItemsWithPrefix(header, compressionSysmeta)
+	RemoveItemsWithPrefix(header, compressionOverride)
+}
+
+type compressionMiddleware struct {
+	next           http.Handler
+	enc            *zstd.Encoder
+	contentTypes   []string
+	frameSize      int64
+	minSize        int64
+	putMetric      tally.Counter
+	getMetric      tally.Counter
+	getRangeMetric tally.Counter
+}
+
+func (c *compressionMiddleware) compressible(request *http.Request) bool {
+	if request.ContentLength < c.minSize || request.ContentLength > common.MAX_FILE_SIZE ||
+		request.Header.Get("Content-Encoding") != "" ||
+		request.Header.Get("X-Copy-From") != "" ||
+		request.Header.Get("X-Object-Manifest") != "" ||
+		request.Header.Get("X-Static-Large-Object") != "" ||
+		request.URL.Query().Get("multipart-manifest") != "" ||
+		len(request.Trailer) > 0 {
+		return false
+	}
+	etag := strings.Trim(request.Header.Get("Etag"), "\"")
+	if etag == "" {
+		etag, _ = common.ContentMD5Etag(request.Header.Get("Content-MD5"))
+	}
+	if len(etag) != 32 {
+		// Without a client supplied etag there is nowhere to keep the etag of
+		// the original data, so the object is stored as is.
+		return false
+	}
+	contentType := strings.ToLower(strings.TrimSpace(strings.Split(request.Header.Get("Content-Type"), ";")[0]))
+	for _, ct := range c.contentTypes {
+		if ct == contentType || (strings.HasSuffix(ct, "/*") && strings.HasPrefix(contentType, ct[:len(ct)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *compressionMiddleware) handlePut(writer http.ResponseWriter, request *http.Request) {
+	etag := strings.ToLower(strings.Trim(request.Header.Get("Etag"), "\""))
+	if contentMD5 := request.Header.Get("Content-MD5"); contentMD5 != "" {
+		md5Etag, err := common.ContentMD5Etag(contentMD5)
+		if err != nil {
+			srv.SimpleErrorResponse(writer, http.StatusBadRequest, err.Error())
+			return
+		}
+		if etag != "" && etag != md5Etag {
+			srv.SimpleErrorResponse(writer, http.StatusBadRequest, "Content-MD5 and ETag don't match")
+			return
+		}
+		// The original data is checked against it as it's compressed; the
+		// compressed body sent on doesn't match it.
+		etag = md5Etag
+		request.Header.Del("Content-MD5")
+	}
+	c.putMetric.Inc(1)
+	request.Header.Set(compressionSysmeta, "zstd")
+	request.Header.Set(compressionSysmeta+"-Length", strconv.FormatInt(request.ContentLength, 10))
+	request.Header.Set(compressionSysmeta+"-Frame-Size", strconv.FormatInt(c.frameSize, 10))
+	request.Header.Set(compressionSysmeta+"-Etag", etag)
+	request.Header.Set(compressionOverride+"Size", strconv.FormatInt(request.ContentLength, 10))
+	request.Header.Set(compressionOverride+"Etag", etag)
+	request.Header.Del("Etag")
+	request.Header.Del("Content-Length")
+	cr := &compressReader{
+		src:      request.Body,
+		enc:      c.enc,
+		buf:      make([]byte, c.frameSize),
+		hash:     md5.New(),
+		etag:     etag,
+		expected: request.ContentLength,
+	}
+	request.Body = ioutil.NopCloser(cr)
+	request.ContentLength = -1
+	request.TransferEncoding = []string{"chunked"}
+	c.next.ServeHTTP(&compressionPutWriter{ResponseWriter: writer, cr: cr}, request)
+}
+
+func (c *compressionMiddleware) serveRange(cw *compressionGetWriter, request *http.Request) {
+	c.getRangeMetric.Inc(1)
+	ctx := GetProxyContext(request)
+	writer := cw.ResponseWriter
+	header := writer.Header()
+	info := cw.info
+	stripCompressionHeaders(header)
+	header.Del("Content-Range")
+	if info.etag != "" {
+		header.Set("Etag", fmt.Sprintf("\"%s\"", info.etag))
+	}
+	ranges, err := common.ParseRange(request.Header.Get("Range"), info.length)
+	if err != nil || len(ranges) != 1 {
+		header.Set("Content-Length", "0")
+		header.Set("Content-Range", fmt.Sprintf("bytes */%d", info.length))
+		writer.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	rng := ranges[0]
+
+	subreq, err := ctx.newSubrequest("GET", request.URL.Path, nil, request, compressionSource)
+	if err != nil {
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	subreq.Header.Set("Range", fmt.Sprintf("bytes=-%d", seekTableLen(info.frames())))
+	tw := NewCaptureWriter()
+	ctx.serveHTTPSubrequest(tw, subreq)
+	if tw.status/100 != 2 {
+		ctx.Logger.Error("unable to fetch compression seek table", zap.String("path", request.URL.Path), zap.Int("status", tw.status))
+		srv.StandardResponse(writer, http.StatusServiceUnavailable)
+		return
+	}
+	sizes, err := parseSeekTable(tw.body, info.frames())
+	if err != nil {
+		ctx.Logger.Error("invalid compression seek table", zap.String("path", request.URL.Path), zap.Error(err))
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+
+	firstFrame, lastFrame := rng.Start/info.frameSize, (rng.End-1)/info.frameSize
+	var start, end int64
+	for i, size := range sizes {
+		if int64(i) < firstFrame {
+			start += size
+		}
+		if int64(i) <= lastFrame {
+			end += size
+		}
+	}
+	subreq, err = ctx.newSubrequest("GET", request.URL.Path, nil, request, compressionSource)
+	if err != nil {
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	subreq.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	sw := &compressionSubWriter{header: make(http.Header), w: pw}
+	go func() {
+		ctx.serveHTTPSubrequest(sw, subreq)
+		if sw.status/100 != 2 {
+			pw.CloseWithError(fmt.Errorf("bad status code %d", sw.status))
+		} else {
+			pw.Close()
+		}
+	}()
+	dec, err := zstd.NewReader(pr, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	defer dec.Close()
+	if _, err := io.CopyN(ioutil.Discard, dec, rng.Start-firstFrame*info.frameSize); err != nil {
+		ctx.Logger.Error("unable to read compressed range", zap.String("path", request.URL.Path), zap.Error(err))
+		srv.StandardResponse(writer, http.StatusServiceUnavailable)
+		return
+	}
+	header.Set("Content-Length", strconv.FormatInt(rng.End-rng.Start, 10))
+	header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rng.Start, rng.End-1, info.length))
+	writer.WriteHeader(http.StatusPartialContent)
+	if _, err := io.CopyN(writer, dec, rng.End-rng.Start); err != nil {
+		ctx.Logger.Error("error decompressing range", zap.String("path", request.URL.Path), zap.Error(err))
+	}
+}
+
+func (c *compressionMiddleware) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	ctx := GetProxyContext(request)
+	if ctx == nil || ctx.Source == compressionSource {
+		c.next.ServeHTTP(writer, request)
+		return
+	}
+	if apiReq, _, container, object := getPathParts(request); !apiReq || container == "" || object == "" {
+		c.next.ServeHTTP(writer, request)
+		return
+	}
+	switch request.Method {
+	case "PUT":
+		stripCompressionHeaders(request.Header)
+		if c.compressible(request) {
+			c.handlePut(writer, request)
+			return
+		}
+	case "GET", "HEAD":
+		if request.Method == "HEAD" {
+			// A ranged HEAD would describe the stored bytes rather than the
+			// original object.
+			request.Header.Del("Range")
+		}
+		updateEtagIsAt(request, compressionSysmeta+"-Etag")
+		cw := &compressionGetWriter{ResponseWriter: writer, request: request, logger: ctx.Logger}
+		c.next.ServeHTTP(cw, request)
+		cw.finish()
+		if cw.compressed && cw.info != nil {
+			if cw.status == http.StatusPartialContent || cw.status == http.StatusRequestedRangeNotSatisfiable {
+				c.serveRange(cw, request)
+			} else if cw.status == http.StatusOK && request.Method == "GET" {
+				c.getMetric.Inc(1)
+			}
+		}
+		return
+	}
+	c.next.ServeHTTP(writer, request)
+}
+
+func NewCompression(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
+	if !config.GetBool("enabled", false) {
+		return func(next http.Handler) http.Handler {
+			return next
+		}, nil
+	}
+	frameSize := config.GetInt("frame_size", 262144)
+	if frameSize <= 0 {
+		return nil, fmt.Errorf("invalid compression frame_size: %d", frameSize)
+	}
+	ok, level := zstd.EncoderLevelFromString(config.GetDefault("level", "default"))
+	if !ok {
+		return nil, fmt.Errorf("invalid compression level: %s", config.GetDefault("level", "default"))
+	}
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+	if err != nil {
+		return nil, err
+	}
+	var contentTypes []string
+	for _, ct := range strings.Split(config.GetDefault("content_types", "text/*,application/json,application/xml,application/javascript"), ",") {
+		if ct = strings.ToLower(strings.TrimSpace(ct)); ct != "" {
+			contentTypes = append(contentTypes, ct)
+		}
+	}
+	RegisterInfo("compression", map[string]interface{}{"algorithm": "zstd", "content_types": contentTypes})
+	putMetric := metricsScope.Counter("compression_PUT_requests")
+	getMetric := metricsScope.Counter("compression_GET_requests")
+	getRangeMetric := metricsScope.Counter("compression_GET_range_requests")
+	minSize := config.GetInt("min_size", 4096)
+	return func(next http.Handler) http.Handler {
+		return &compressionMiddleware{
+			next:           next,
+			enc:            enc,
+			contentTypes:   contentTypes,
+			frameSize:      frameSize,
+			minSize:        minSize,
+			putMetric:      putMetric,
+			getMetric:      getMetric,
+			getRangeMetric: getRangeMetric,
+		}
+	}, nil
+}
@@ -0,0 +1,241 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+)
+
+// compressionStore is a minimal object backend that keeps whatever it is sent.
+type compressionStore struct {
+	data   []byte
+	header http.Header
+}
+
+func (s *compressionStore) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case "PUT":
+		body, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		s.data = body
+		s.header = make(http.Header)
+		for k := range request.Header {
+			if strings.HasPrefix(k, "X-Object-Sysmeta-") {
+				s.header.Set(k, request.Header.Get(k))
+			}
+		}
+		s.header.Set("Content-Type", request.Header.Get("Content-Type"))
+		s.header.Set("Etag", fmt.Sprintf("%x", md5.Sum(body)))
+		writer.Header().Set("Etag", s.header.Get("Etag"))
+		writer.WriteHeader(http.StatusCreated)
+	case "GET", "HEAD":
+		for k := range s.header {
+			writer.Header().Set(k, s.header.Get(k))
+		}
+		contentLength := int64(len(s.data))
+		if rng := request.Header.Get("Range"); rng != "" {
+			ranges, err := common.ParseRange(rng, contentLength)
+			if err != nil {
+				writer.Header().Set("Content-Length", "0")
+				writer.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", contentLength))
+				writer.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			} else if len(ranges) == 1 {
+				writer.Header().Set("Content-Length", strconv.FormatInt(ranges[0].End-ranges[0].Start, 10))
+				writer.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", ranges[0].Start, ranges[0].End-1, contentLength))
+				writer.WriteHeader(http.StatusPartialContent)
+				writer.Write(s.data[ranges[0].Start:ranges[0].End])
+				return
+			}
+		}
+		writer.Header().Set("Content-Length", strconv.FormatInt(contentLength, 10))
+		writer.WriteHeader(http.StatusOK)
+		if request.Method == "GET" {
+			writer.Write(s.data)
+		}
+	}
+}
+
+func compressionTestHandler(t *testing.T, settings string) (http.Handler, *compressionStore) {
+	config, err := conf.StringConfig("[filter:compression]\n" + settings)
+	require.Nil(t, err)
+	store := &compressionStore{}
+	mid, err := NewCompression(config.GetSection("filter:compression"), common.NewTestScope())
+	require.Nil(t, err)
+	return mid(store), store
+}
+
+func compressionRequest(t *testing.T, h http.Handler, method string, body []byte) *http.Request {
+	req, err := http.NewRequest(method, "/v1/a/c/o", bytes.NewReader(body))
+	require.Nil(t, err)
+	return req.WithContext(context.WithValue(req.Context(), "proxycontext", &ProxyContext{
+		Logger:                 zap.NewNop(),
+		ProxyContextMiddleware: &ProxyContextMiddleware{next: h},
+	}))
+}
+
+func compressionTestData() []byte {
+	var buf bytes.Buffer
+	for i := 0; buf.Len() < 10000; i++ {
+		fmt.Fprintf(&buf, "line %d of some very compressible text\n", i)
+	}
+	return buf.Bytes()
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	h, store := compressionTestHandler(t, "enabled = true\nframe_size = 1024\nmin_size = 1")
+	data := compressionTestData()
+	etag := fmt.Sprintf("%x", md5.Sum(data))
+
+	req := compressionRequest(t, h, "PUT", data)
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Etag", etag)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, etag, w.Header().Get("Etag"))
+	require.True(t, len(store.data) < len(data))
+	require.Equal(t, "zstd", store.header.Get("X-Object-Sysmeta-Compression"))
+	require.Equal(t, strconv.Itoa(len(data)), store.header.Get("X-Object-Sysmeta-Compression-Length"))
+	require.Equal(t, strconv.Itoa(len(data)), store.header.Get("X-Object-Sysmeta-Container-Update-Override-Size"))
+	require.Equal(t, etag, store.header.Get("X-Object-Sysmeta-Container-Update-Override-Etag"))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, compressionRequest(t, h, "GET", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, data, w.Body.Bytes())
+	require.Equal(t, strconv.Itoa(len(data)), w.Header().Get("Content-Length"))
+	require.Equal(t, "\""+etag+"\"", w.Header().Get("Etag"))
+	require.Equal(t, "", w.Header().Get("X-Object-Sysmeta-Compression"))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, compressionRequest(t, h, "HEAD", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, strconv.Itoa(len(data)), w.Header().Get("Content-Length"))
+
+	for _, rng := range []common.HttpRange{{Start: 0, End: 10}, {Start: 1000, End: 3000}, {Start: 5000, End: 5001}, {Start: int64(len(data) - 100), End: int64(len(data))}} {
+		req = compressionRequest(t, h, "GET", nil)
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", rng.Start, rng.End-1))
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		require.Equal(t, http.StatusPartialContent, w.Code)
+		require.Equal(t, data[rng.Start:rng.End], w.Body.Bytes())
+		require.Equal(t, fmt.Sprintf("bytes %d-%d/%d", rng.Start, rng.End-1, len(data)), w.Header().Get("Content-Range"))
+		require.Equal(t, strconv.FormatInt(rng.End-rng.Start, 10), w.Header().Get("Content-Length"))
+	}
+
+	req = compressionRequest(t, h, "GET", nil)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", len(data)))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+	require.Equal(t, fmt.Sprintf("bytes */%d", len(data)), w.Header().Get("Content-Range"))
+}
+
+func TestCompressionBadEtag(t *testing.T) {
+	h, store := compressionTestHandler(t, "enabled = true\nmin_size = 1")
+	req := compressionRequest(t, h, "PUT", compressionTestData())
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Etag", "ffffffffffffffffffffffffffffffff")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	require.Nil(t, store.header)
+}
+
+func TestCompressionContentMD5(t *testing.T) {
+	data := compressionTestData()
+	sum := md5.Sum(data)
+	contentMD5 := base64.StdEncoding.EncodeToString(sum[:])
+	for _, tc := range []struct {
+		contentMD5, etag string
+		status           int
+	}{
+		{contentMD5, "", http.StatusCreated},
+		{contentMD5, fmt.Sprintf("%x", sum), http.StatusCreated},
+		{contentMD5, "ffffffffffffffffffffffffffffffff", http.StatusBadRequest},
+		{"1B2M2Y8AsgTpgAmY7PhCfg==", "", http.StatusUnprocessableEntity},
+	} {
+		h, store := compressionTestHandler(t, "enabled = true\nmin_size = 1")
+		req := compressionRequest(t, h, "PUT", data)
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Content-MD5", tc.contentMD5)
+		req.Header.Set("Etag", tc.etag)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		require.Equal(t, tc.status, w.Code, tc.contentMD5+" "+tc.etag)
+		if tc.status == http.StatusCreated {
+			require.Equal(t, "zstd", store.header.Get("X-Object-Sysmeta-Compression"))
+			require.Equal(t, "", req.Header.Get("Content-MD5"))
+		}
+	}
+}
+
+func TestCompressionSkipped(t *testing.T) {
+	data := compressionTestData()
+	etag := fmt.Sprintf("%x", md5.Sum(data))
+	for _, tc := range []struct {
+		settings    string
+		contentType string
+		etag        string
+	}{
+		{"", "text/plain", etag},
+		{"enabled = true", "image/jpeg", etag},
+		{"enabled = true", "text/plain", ""},
+		{"enabled = true\nmin_size = 100000", "text/plain", etag},
+	} {
+		h, store := compressionTestHandler(t, tc.settings)
+		req := compressionRequest(t, h, "PUT", data)
+		req.Header.Set("Content-Type", tc.contentType)
+		req.Header.Set("Etag", tc.etag)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.Equal(t, data, store.data)
+		require.Equal(t, "", store.header.Get("X-Object-Sysmeta-Compression"))
+	}
+}
+
+func TestSeekTable(t *testing.T) {
+	table := appendSeekTable(nil, [][2]uint32{{10, 100}, {20, 100}, {5, 7}})
+	require.Equal(t, seekTableLen(3), int64(len(table)))
+	sizes, err := parseSeekTable(table, 3)
+	require.Nil(t, err)
+	require.Equal(t, []int64{10, 20, 5}, sizes)
+	_, err = parseSeekTable(table, 2)
+	require.NotNil(t, err)
+	_, err = parseSeekTable(table[1:], 3)
+	require.NotNil(t, err)
+}
@@ -50,7 +50,7 @@ func containerQuota(metric tally.Counter) func(http.Handler) http.Handler {
 						return
 					}
 				}
-			} else if obj != "" && request.Method == "PUT" {
+			} else if obj != "" && (request.Method == "PUT" || request.Method == "APPEND") {
 				ci, err := ctx.C.GetContainerInfo(request.Context(), account, container)
 				if err != nil {
 					next.ServeHTTP(writer, request)
@@ -67,7 +67,7 @@ func containerQuota(metric tally.Counter) func(http.Handler) http.Handler {
 					}
 				}
 				qCount := ci.Metadata["Quota-Count"]
-				if qCount != "" {
+				if qCount != "" && request.Method == "PUT" {
 					if quota, err := strconv.ParseInt(qCount, 10, 64); err == nil {
 						newCount := ci.ObjectCount + 1
 						if quota < newCount {
@@ -0,0 +1,97 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+// containerSync authorizes object requests signed by another cluster in one
+// of our container sync realms with an X-Container-Sync-Auth header, so
+// syncing doesn't need any user's credentials.
+type containerSync struct {
+	next          http.Handler
+	realms        conf.SyncRealmList
+	invalidMetric tally.Counter
+}
+
+func (cs *containerSync) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	auth := request.Header.Get("X-Container-Sync-Auth")
+	apiReq, account, container, obj := getPathParts(request)
+	if auth == "" || !apiReq || obj == "" {
+		cs.next.ServeHTTP(writer, request)
+		return
+	}
+	ctx := GetProxyContext(request)
+	userKey := ""
+	if ci, err := ctx.C.GetContainerInfo(request.Context(), account, container); err == nil {
+		userKey = ci.SyncKey
+	}
+	if !cs.realms.ValidSyncAuth(auth, request.Method, common.Urlencode(request.URL.Path), ctx.syncTimestamp, userKey) {
+		cs.invalidMetric.Inc(1)
+		ctx.Logger.Debug("Invalid X-Container-Sync-Auth", zap.String("path", request.URL.Path))
+		writer.Header().Set("Www-Authenticate", "SwiftContainerSync realm=\"unknown\"")
+		srv.SimpleErrorResponse(writer, http.StatusUnauthorized, "X-Container-Sync-Auth header not valid; contact cluster operator for support.")
+		return
+	}
+	// Synced objects keep the timestamps they have in the source cluster.
+	if ctx.syncTimestamp != "" {
+		if ts, err := common.StandardizeTimestamp(ctx.syncTimestamp); err == nil {
+			request.Header.Set("X-Timestamp", ts)
+		}
+	}
+	ctx.Authorize = func(r *http.Request) (bool, int) {
+		return true, http.StatusOK
+	}
+	cs.next.ServeHTTP(writer, request)
+}
+
+// NewContainerSync accepts object requests from other clusters in the realms
+// in container-sync-realms.conf.
+func NewContainerSync(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
+	if !config.GetBool("enabled", false) {
+		return func(next http.Handler) http.Handler {
+			return next
+		}, nil
+	}
+	realms, err := conf.GetSyncRealms()
+	if err != nil {
+		return nil, err
+	}
+	info := map[string]interface{}{}
+	for name, realm := range realms {
+		clusters := map[string]interface{}{}
+		for cluster := range realm.Clusters {
+			clusters[cluster] = map[string]interface{}{}
+		}
+		info[name] = map[string]interface{}{"clusters": clusters}
+	}
+	RegisterInfo("container_sync", map[string]interface{}{"realms": info})
+	invalidMetric := metricsScope.Counter("container_sync_invalid_auth")
+	return func(next http.Handler) http.Handler {
+		return &containerSync{
+			next:          next,
+			realms:        realms,
+			invalidMetric: invalidMetric,
+		}
+	}, nil
+}
@@ -0,0 +1,84 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/client"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/common/test"
+	"go.uber.org/zap"
+)
+
+func TestContainerSyncAuth(t *testing.T) {
+	realms := conf.SyncRealmList{
+		"US": {Name: "US", Key1: "realmkey", Clusters: map[string]string{"dfw1": "http://dfw1/v1/"}},
+	}
+	f, err := client.NewProxyClient(staticPolicyList, srv.NewTestConfigLoader(&test.FakeRing{}),
+		nil, "", "", "", "", "", conf.Config{})
+	require.Nil(t, err)
+	var authorized bool
+	var timestamp string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := GetProxyContext(r)
+		authorized = ctx.Authorize != nil
+		timestamp = r.Header.Get("X-Timestamp")
+		w.WriteHeader(201)
+	})
+	invalid := common.NewTestScope().Counter("container_sync_invalid_auth")
+	cs := &containerSync{next: next, realms: realms, invalidMetric: invalid}
+	sendSynced := func(path, auth, syncTimestamp string) int {
+		authorized, timestamp = false, ""
+		ctx := &ProxyContext{
+			Logger: zap.NewNop(),
+			C: f.NewRequestClient(nil, map[string]*client.ContainerInfo{
+				"container/a/c": {SyncKey: "secret"},
+			}, zap.NewNop()),
+			syncTimestamp: syncTimestamp,
+		}
+		req := httptest.NewRequest("PUT", path, nil)
+		req.Header.Set("X-Timestamp", "2000000000.00000")
+		if auth != "" {
+			req.Header.Set("X-Container-Sync-Auth", auth)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), "proxycontext", ctx))
+		w := httptest.NewRecorder()
+		cs.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	auth, err := realms.SyncAuth("US", "PUT", "/v1/a/c/o%20x", "1387212345.67890", "secret")
+	require.Nil(t, err)
+	require.Equal(t, 201, sendSynced("/v1/a/c/o%20x", auth, "1387212345.67890"))
+	require.True(t, authorized)
+	require.Equal(t, "1387212345.67890", timestamp)
+
+	// Not signed: left to the auth middleware.
+	require.Equal(t, 201, sendSynced("/v1/a/c/o%20x", "", ""))
+	require.False(t, authorized)
+	require.Equal(t, "2000000000.00000", timestamp)
+
+	require.Equal(t, 401, sendSynced("/v1/a/c/o%20x", auth, "1387212345.67891"))
+	require.Equal(t, 401, sendSynced("/v1/a/c/other", auth, "1387212345.67890"))
+	require.Equal(t, 401, sendSynced("/v1/a/c/o%20x", "US nonce bad", "1387212345.67890"))
+}
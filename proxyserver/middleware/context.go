@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"runtime/debug"
 	"strconv"
 	"strings"
@@ -69,6 +70,10 @@ type captureWriter struct {
 func (x *captureWriter) Header() http.Header    { return x.header }
 func (x *captureWriter) WriteHeader(status int) { x.status = status }
 func (x *captureWriter) Write(b []byte) (int, error) {
+	// As with an http.ResponseWriter, writing without a WriteHeader is a 200.
+	if x.status == 0 {
+		x.status = http.StatusOK
+	}
 	x.body = append(x.body, b...)
 	return len(b), nil
 }
@@ -84,6 +89,8 @@ type AccountInfo struct {
 	Metadata       map[string]string
 	SysMetadata    map[string]string
 	StatusCode     int `json:"status"`
+	// Timestamp is the account's X-Timestamp, when it was created.
+	Timestamp string
 }
 
 type AuthorizeFunc func(r *http.Request) (bool, int)
@@ -114,6 +121,13 @@ type ProxyContext struct {
 	depth            int
 	Source           string
 	S3Auth           *S3AuthInfo
+	// syncTimestamp is the client's X-Timestamp, kept for checking
+	// X-Container-Sync-Auth signatures.
+	syncTimestamp string
+	// middlewareTimes are recorded by the pipeline as the request passes
+	// through each middleware.
+	middlewareTimesLock sync.Mutex
+	middlewareTimes     []MiddlewareTime
 }
 
 func GetProxyContext(r *http.Request) *ProxyContext {
@@ -182,6 +196,7 @@ func (pc *ProxyContext) GetAccountInfo(ctx context.Context, account string) (*Ac
 			Metadata:    make(map[string]string),
 			SysMetadata: make(map[string]string),
 			StatusCode:  resp.StatusCode,
+			Timestamp:   resp.Header.Get("X-Timestamp"),
 		}
 		var err error
 		if ai.ContainerCount, err = strconv.ParseInt(resp.Header.Get("X-Account-Container-Count"), 10, 64); err != nil {
@@ -271,7 +286,67 @@ func (pc *ProxyContext) serveHTTPSubrequest(writer http.ResponseWriter, subreq *
 		return status
 	})
 	subwriter.Header().Set("X-Trans-Id", subctx.TxId)
+	start := time.Now()
 	pc.next.ServeHTTP(subwriter, subreq)
+	pc.subrequestDone(subreq, time.Since(start))
+}
+
+// prefetchPaths returns the accounts and containers, as "account" or
+// "account/container", whose info a request is almost certain to need: its
+// own, and for copies the other end's.
+func prefetchPaths(request *http.Request) []string {
+	apiRequest, account, container, _ := getPathParts(request)
+	if !apiRequest || account == "" {
+		return nil
+	}
+	paths := []string{account}
+	if container != "" {
+		paths = append(paths, account+"/"+container)
+	}
+	add := func(otherAccount, header string) {
+		if otherAccount == "" {
+			otherAccount = account
+		}
+		if !common.StringInSlice(otherAccount, paths) {
+			paths = append(paths, otherAccount)
+		}
+		if p, err := url.QueryUnescape(header); err == nil {
+			if parts := strings.SplitN(strings.TrimPrefix(p, "/"), "/", 2); len(parts) == 2 && parts[0] != "" {
+				if path := otherAccount + "/" + parts[0]; !common.StringInSlice(path, paths) {
+					paths = append(paths, path)
+				}
+			}
+		}
+	}
+	if v := request.Header.Get("X-Copy-From"); v != "" && request.Method == "PUT" {
+		add(request.Header.Get("X-Copy-From-Account"), v)
+	}
+	if v := request.Header.Get("Destination"); v != "" && request.Method == "COPY" {
+		add(request.Header.Get("Destination-Account"), v)
+	}
+	return paths
+}
+
+// prefetchInfo looks up the info from prefetchPaths in parallel, so the
+// handlers and middlewares find it already cached.
+func (pc *ProxyContext) prefetchInfo(request *http.Request) {
+	paths := prefetchPaths(request)
+	if len(paths) == 0 {
+		return
+	}
+	wg := &sync.WaitGroup{}
+	for _, path := range paths {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			if i := strings.Index(path, "/"); i >= 0 {
+				pc.C.GetContainerInfo(request.Context(), path[:i], path[i+1:])
+			} else {
+				pc.GetAccountInfo(request.Context(), path)
+			}
+		}(path)
+	}
+	wg.Wait()
 }
 
 func (m *ProxyContextMiddleware) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
@@ -307,6 +382,10 @@ func (m *ProxyContextMiddleware) ServeHTTP(writer http.ResponseWriter, request *
 		}
 	}
 
+	syncTimestamp := ""
+	if request.Header.Get("X-Container-Sync-Auth") != "" {
+		syncTimestamp = request.Header.Get("X-Timestamp")
+	}
 	for k := range request.Header {
 		for _, ex := range excludeHeaders {
 			if strings.HasPrefix(k, ex) || k == "X-Timestamp" {
@@ -314,6 +393,15 @@ func (m *ProxyContextMiddleware) ServeHTTP(writer http.ResponseWriter, request *
 			}
 		}
 	}
+	if len(request.Trailer) > 0 {
+		stripExcludedTrailers(request.Trailer)
+		request.Body = &trailerStrippingBody{ReadCloser: request.Body, trailer: request.Trailer}
+	}
+
+	if request.Method == "PUT" || request.Method == "POST" || request.Method == "COPY" {
+		// Metadata is stored as UTF-8, whichever way the client encoded it.
+		common.DecodeMetaHeaders(request.Header, "X-Account-Meta-", "X-Container-Meta-", "X-Object-Meta-")
+	}
 
 	transId := common.GetTransactionId()
 	request.Header.Set("X-Trans-Id", transId)
@@ -328,26 +416,11 @@ func (m *ProxyContextMiddleware) ServeHTTP(writer http.ResponseWriter, request *
 		TxId:                   transId,
 		status:                 500,
 		accountInfoCache:       make(map[string]*AccountInfo),
+		syncTimestamp:          syncTimestamp,
 		C:                      m.proxyClientFactory.NewRequestClient(m.Cache, make(map[string]*client.ContainerInfo), logr),
 	}
-	// we'll almost certainly need the AccountInfo and ContainerInfo for the current path, so pre-fetch them in parallel.
-	apiRequest, account, container, _ := getPathParts(request)
-	if apiRequest && account != "" {
-		wg := &sync.WaitGroup{}
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			pc.GetAccountInfo(request.Context(), account)
-		}()
-		if container != "" {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				pc.C.GetContainerInfo(request.Context(), account, container)
-			}()
-		}
-		wg.Wait()
-	}
+	pc.prefetchInfo(request)
+	_, account, _, _ := getPathParts(request)
 	newWriter := srv.NewCustomWriter(writer, func(w http.ResponseWriter, status int) int {
 		// strip out any bad headers before calling real WriteHeader
 		for k := range w.Header() {
@@ -381,6 +454,33 @@ func (m *ProxyContextMiddleware) ServeHTTP(writer http.ResponseWriter, request *
 	m.next.ServeHTTP(newWriter, request)
 }
 
+func stripExcludedTrailers(trailer http.Header) {
+	for k := range trailer {
+		for _, ex := range excludeHeaders {
+			if strings.HasPrefix(k, ex) {
+				delete(trailer, k)
+			}
+		}
+	}
+}
+
+// trailerStrippingBody drops the client's trailers that it may not set, like
+// sysmeta, as they arrive. Trailers are only read in at the end of the body,
+// and needn't have been declared up front, so stripping them on the way in
+// isn't enough.
+type trailerStrippingBody struct {
+	io.ReadCloser
+	trailer http.Header
+}
+
+func (b *trailerStrippingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		stripExcludedTrailers(b.trailer)
+	}
+	return n, err
+}
+
 func NewContext(debugResponses bool, mc ring.MemcacheRing, log srv.LowLevelLogger, proxyClientFactory client.ProxyClient) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return &ProxyContextMiddleware{
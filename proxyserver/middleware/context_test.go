@@ -0,0 +1,100 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/client/clienttest"
+	"github.com/troubling/hummingbird/common/test"
+	"go.uber.org/zap"
+)
+
+func TestPrefetchPaths(t *testing.T) {
+	paths := func(method, path string, headers ...string) []string {
+		r := httptest.NewRequest(method, path, nil)
+		for i := 0; i < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		return prefetchPaths(r)
+	}
+	require.Nil(t, paths("GET", "/info"))
+	require.Equal(t, []string{"a"}, paths("GET", "/v1/a"))
+	require.Equal(t, []string{"a", "a/c"}, paths("GET", "/v1/a/c"))
+	require.Equal(t, []string{"a", "a/c"}, paths("PUT", "/v1/a/c/o"))
+	require.Equal(t, []string{"a", "a/c", "a/src"}, paths("PUT", "/v1/a/c/o", "X-Copy-From", "/src/o"))
+	require.Equal(t, []string{"a", "a/c"}, paths("PUT", "/v1/a/c/o", "X-Copy-From", "c/o2"))
+	require.Equal(t, []string{"a", "a/c", "b", "b/src c"},
+		paths("PUT", "/v1/a/c/o", "X-Copy-From", "src%20c/o", "X-Copy-From-Account", "b"))
+	require.Equal(t, []string{"a", "a/c", "a/dst"}, paths("COPY", "/v1/a/c/o", "Destination", "dst/o"))
+	// Only PUTs copy from X-Copy-From.
+	require.Equal(t, []string{"a", "a/c"}, paths("POST", "/v1/a/c/o", "X-Copy-From", "/src/o"))
+}
+
+func TestContextDecodesMetadata(t *testing.T) {
+	var got http.Header
+	handler := NewContext(false, &test.FakeMemcacheRing{}, zap.NewNop(), clienttest.NewProxyClient(clienttest.NewStore()))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Header
+			w.WriteHeader(204)
+		}))
+	for _, method := range []string{"PUT", "POST", "GET"} {
+		r := httptest.NewRequest(method, "/v1/a/c/o", nil)
+		r.Header.Set("X-Object-Meta-Encoded", "=?UTF-8?B?aMOpbGxv?=")
+		r.Header.Set("X-Container-Meta-Extended", "UTF-8''caf%C3%A9")
+		r.Header.Set("X-Object-Meta-Raw", "héllo")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		if method == "GET" {
+			require.Equal(t, "=?UTF-8?B?aMOpbGxv?=", got.Get("X-Object-Meta-Encoded"))
+			continue
+		}
+		require.Equal(t, "héllo", got.Get("X-Object-Meta-Encoded"), method)
+		require.Equal(t, "café", got.Get("X-Container-Meta-Extended"), method)
+		require.Equal(t, "héllo", got.Get("X-Object-Meta-Raw"), method)
+	}
+}
+
+func TestContextStripsTrailers(t *testing.T) {
+	var got http.Header
+	handler := NewContext(false, &test.FakeMemcacheRing{}, zap.NewNop(), clienttest.NewProxyClient(clienttest.NewStore()))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ioutil.ReadAll(r.Body)
+			got = r.Trailer
+			w.WriteHeader(201)
+		}))
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	req, err := http.NewRequest("PUT", ts.URL+"/v1/a/c/o", ioutil.NopCloser(strings.NewReader("some data")))
+	require.Nil(t, err)
+	req.ContentLength = -1
+	req.Trailer = http.Header{
+		"X-Object-Etag":            {"1e50210a0202497fb79bc38b6ade6c34"},
+		"X-Object-Sysmeta-S3-Etag": {"forged"},
+		"X-Backend-Thing":          {"forged"},
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, 201, resp.StatusCode)
+	require.Equal(t, "1e50210a0202497fb79bc38b6ade6c34", got.Get("X-Object-Etag"))
+	require.Equal(t, "", got.Get("X-Object-Sysmeta-S3-Etag"))
+	require.Equal(t, "", got.Get("X-Backend-Thing"))
+}
@@ -47,6 +47,10 @@ func (cw *CopyWriter) WriteHeader(status int) {
 	}
 }
 
+func (cw *CopyWriter) Flush() {
+	srv.Flush(cw.ResponseWriter)
+}
+
 type copyMiddleware struct {
 	next http.Handler
 }
@@ -276,9 +280,17 @@ func (c *copyMiddleware) handlePut(writer *CopyWriter, request *http.Request) {
 		}
 	}
 
+	if values.Get("multipart-manifest") != "put" {
+		// Only a copy of the manifest itself is still the completed
+		// multipart upload the S3 ETag was made for.
+		request.Header.Del(s3EtagSysmeta)
+	}
+
 	request.URL.RawQuery = values.Encode()
 	request.ContentLength = 0
 	request.Body = srcBody
+	// Any Content-MD5 was for the request's own body, which isn't sent.
+	request.Header.Del("Content-MD5")
 
 	if srcStatus == http.StatusOK &&
 		srcHeader.Get("X-Static-Large-Object") == "" &&
@@ -294,8 +306,9 @@ func (c *copyMiddleware) handlePut(writer *CopyWriter, request *http.Request) {
 		request.Header.Set("Etag", srcHeader.Get("Etag"))
 	} else {
 		// since we're not copying the source etag, make sure that any
-		// container update override values are not copied.
+		// container update override values or checksums are not copied.
 		RemoveItemsWithPrefix(request.Header, "X-Object-Sysmeta-Container-Update-Override-")
+		RemoveItemsWithPrefix(request.Header, common.ChecksumHeaderPrefix)
 	}
 
 	request.Header.Del("X-Copy-From")
@@ -254,6 +254,42 @@ func PostAsCopyPutResponseFunc(t *testing.T, w http.ResponseWriter, r *http.Requ
 	w.Write([]byte(body))
 }
 
+func TestCopyS3Etag(t *testing.T) {
+	c, err := NewCopyMiddleware(conf.Section{}, common.NewTestScope())
+	require.Nil(t, err)
+	var put http.Header
+	get := func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Static-Large-Object", "True")
+		w.Header().Set(s3EtagSysmeta, "b1946ac92492d2347c6235b4d2611184-2")
+		w.Header().Set("X-Object-Sysmeta-Foo", "SourceObjectSysmetaFoo")
+		w.WriteHeader(200)
+		w.Write([]byte("stuff"))
+	}
+	putFunc := func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "PUT", r.Method)
+		put = r.Header
+		w.WriteHeader(201)
+	}
+	doCopy := func(query string) {
+		handler := c(NewPassthroughFunc(t, get, putFunc))
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/v1/a/c/o2"+query, nil)
+		req.Header.Set("X-Copy-From", "c/o")
+		req = req.WithContext(context.WithValue(req.Context(), "proxycontext", NewFakeProxyContext(handler)))
+		handler.ServeHTTP(rr, req)
+		require.Equal(t, 201, rr.Code)
+	}
+
+	// Copying the object's data makes a plain object.
+	doCopy("")
+	require.Equal(t, "SourceObjectSysmetaFoo", put.Get("X-Object-Sysmeta-Foo"))
+	require.Equal(t, "", put.Get(s3EtagSysmeta))
+
+	// A copy of the manifest keeps the multipart ETag.
+	doCopy("?multipart-manifest=get")
+	require.Equal(t, "b1946ac92492d2347c6235b4d2611184-2", put.Get(s3EtagSysmeta))
+}
+
 func TestPostAsCopy(t *testing.T) {
 
 	configString := "[filter:copy]\nobject_post_as_copy = true"
@@ -85,18 +85,11 @@ func authenticateFormpost(ctx context.Context, proxyCtx *ProxyContext, account,
 		return hmac.Equal(sigb, mac.Sum(nil))
 	}
 
-	if ai, err := proxyCtx.GetAccountInfo(ctx, account); err == nil {
-		if key, ok := ai.Metadata["Temp-Url-Key"]; ok && checkhmac([]byte(key)) {
-			return FP_SCOPE_ACCOUNT
-		} else if key, ok := ai.Metadata["Temp-Url-Key-2"]; ok && checkhmac([]byte(key)) {
-			return FP_SCOPE_ACCOUNT
-		} else if ci, err := proxyCtx.C.GetContainerInfo(ctx, account, container); err == nil {
-			if key, ok := ci.Metadata["Temp-Url-Key"]; ok && checkhmac([]byte(key)) {
-				return FP_SCOPE_CONTAINER
-			} else if key, ok := ci.Metadata["Temp-Url-Key-2"]; ok && checkhmac([]byte(key)) {
-				return FP_SCOPE_CONTAINER
-			}
-		}
+	switch tempURLKeyScope(ctx, proxyCtx, account, container, checkhmac) {
+	case SCOPE_ACCOUNT:
+		return FP_SCOPE_ACCOUNT
+	case SCOPE_CONTAINER:
+		return FP_SCOPE_CONTAINER
 	}
 	return FP_INVALID
 }
@@ -0,0 +1,69 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+// heartbeat keeps a response that has already sent its headers alive while a
+// long operation runs, by writing whitespace every interval. Load balancers
+// and clients would otherwise give up on a connection with nothing on it.
+type heartbeat struct {
+	writer  http.ResponseWriter
+	stop    chan struct{}
+	emitted chan bool
+}
+
+// startHeartbeat starts writing to writer every interval; an interval of 0
+// never writes. The caller mustn't write to writer until stop returns.
+func startHeartbeat(writer http.ResponseWriter, interval time.Duration) *heartbeat {
+	h := &heartbeat{writer: writer, stop: make(chan struct{}), emitted: make(chan bool)}
+	go func() {
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		emitted := false
+		for {
+			select {
+			case <-tick:
+				writer.Write([]byte("  "))
+				if f, ok := writer.(http.Flusher); ok {
+					f.Flush()
+				}
+				emitted = true
+			case <-h.stop:
+				h.emitted <- emitted
+				return
+			}
+		}
+	}()
+	return h
+}
+
+// end stops the heartbeat, separating any whitespace written from what the
+// caller writes next.
+func (h *heartbeat) end() {
+	close(h.stop)
+	if <-h.emitted {
+		// Not sure why, but the Swift code uses \r\n here and \n everywhere else.
+		h.writer.Write([]byte("\r\n\r\n"))
+	}
+}
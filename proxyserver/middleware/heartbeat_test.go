@@ -0,0 +1,42 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeartbeat(t *testing.T) {
+	w := httptest.NewRecorder()
+	hb := startHeartbeat(w, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	hb.end()
+	body := w.Body.String()
+	require.True(t, strings.HasPrefix(body, "  "))
+	require.True(t, strings.HasSuffix(body, "  \r\n\r\n"))
+	require.True(t, w.Flushed)
+
+	w = httptest.NewRecorder()
+	hb = startHeartbeat(w, 0)
+	time.Sleep(5 * time.Millisecond)
+	hb.end()
+	require.Equal(t, "", w.Body.String())
+}
@@ -261,18 +261,7 @@ func (ka *keystoneAuth) authorize(r *http.Request) (bool, int) {
 }
 
 func (ka *keystoneAuth) getAccountPrefix(account string) (string, bool) {
-	// Empty prefix matches everything, so try to match others first
-	for _, prefix := range ka.resellerPrefixes {
-		if prefix != "" && strings.HasPrefix(account, prefix) {
-			return prefix, true
-		}
-	}
-	for _, prefix := range ka.resellerPrefixes {
-		if prefix == "" {
-			return "", true
-		}
-	}
-	return "", false
+	return conf.ResellerPrefix(ka.resellerPrefixes, account)
 }
 
 func (ka *keystoneAuth) authorizeAnonymous(r *http.Request) (bool, int) {
@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"crypto/md5"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
@@ -29,6 +30,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -96,6 +98,10 @@ func (w *etagQuoteWriter) WriteHeader(status int) {
 	w.ResponseWriter.WriteHeader(status)
 }
 
+func (w *etagQuoteWriter) Flush() {
+	srv.Flush(w.ResponseWriter)
+}
+
 type xloIdentifyWriter struct {
 	http.ResponseWriter
 	funcName string
@@ -130,6 +136,14 @@ func (sw *xloIdentifyWriter) Write(b []byte) (int, error) {
 	}
 }
 
+// Flush only passes through for responses that aren't large objects, whose
+// manifests are held back.
+func (sw *xloIdentifyWriter) Flush() {
+	if !sw.isSlo && !sw.isDlo {
+		srv.Flush(sw.ResponseWriter)
+	}
+}
+
 type xloForwardBodyWriter struct {
 	http.ResponseWriter
 	// If constructed with status != 0 xloForwardBodyWriter will call x.ResponseWriter.WriteHeader.
@@ -156,6 +170,10 @@ func (x *xloForwardBodyWriter) Write(b []byte) (int, error) {
 	return x.ResponseWriter.Write(b)
 }
 
+func (x *xloForwardBodyWriter) Flush() {
+	srv.Flush(x.ResponseWriter)
+}
+
 func needToRefetchManifest(sw *xloIdentifyWriter, request *http.Request) bool {
 	if request.Method == "HEAD" {
 		return true
@@ -183,6 +201,7 @@ type xloMiddleware struct {
 	sloGetRequestsMetric    tally.Counter
 	sloPutRequestsMetric    tally.Counter
 	sloDeleteRequestsMetric tally.Counter
+	yieldFrequency          time.Duration
 }
 
 func (xlo *xloMiddleware) feedOutSegments(sw *xloIdentifyWriter, request *http.Request, manifest []segItem, reqRange common.HttpRange, status int) {
@@ -389,6 +408,8 @@ func (xlo *xloMiddleware) handleDloGet(sw *xloIdentifyWriter, request *http.Requ
 			"invalid must multipath PUT to an object path: %s", request.URL.Path))
 		return
 	}
+	// The manifest's own checksums don't describe the segments' content.
+	RemoveItemsWithPrefix(sw.Header(), common.ChecksumHeaderPrefix)
 	container, prefix, err := splitSegPath(sw.Header().Get("X-Object-Manifest"))
 	if err != nil {
 		srv.SimpleErrorResponse(sw.ResponseWriter, 400, "invalid dlo manifest path")
@@ -428,6 +449,7 @@ func (xlo *xloMiddleware) handleSloGet(sw *xloIdentifyWriter, request *http.Requ
 		sw.ResponseWriter.Write(manifestBytes)
 		return
 	}
+	RemoveItemsWithPrefix(sw.Header(), common.ChecksumHeaderPrefix)
 	sloEtag := sw.Header().Get("X-Object-Sysmeta-Slo-Etag")
 	savedContentLength := sw.Header().Get("X-Object-Sysmeta-Slo-Size")
 
@@ -514,11 +536,51 @@ func (xlo *xloMiddleware) handleSloPut(writer http.ResponseWriter, request *http
 		srv.SimpleErrorResponse(writer, 405, "Multipart Manifest PUTs cannot be COPY requests")
 		return
 	}
-	manifest, errs := parsePutSloManifest(request.Body)
+	// The manifest stored isn't the one sent, so a Content-MD5 is checked
+	// against the one sent here rather than by the object servers.
+	body := request.Body
+	var md5Reader *common.ContentMD5Reader
+	if contentMD5 := request.Header.Get("Content-MD5"); contentMD5 != "" {
+		if md5Reader, err = common.NewContentMD5Reader(request.Body, contentMD5); err != nil {
+			srv.SimpleErrorResponse(writer, 400, err.Error())
+			return
+		}
+		body = ioutil.NopCloser(md5Reader)
+	}
+	manifest, errs := parsePutSloManifest(body)
+	if md5Reader != nil {
+		io.Copy(ioutil.Discard, body)
+		if md5Reader.Mismatch() {
+			srv.SimpleErrorResponse(writer, http.StatusUnprocessableEntity, common.ErrContentMD5Mismatch.Error())
+			return
+		}
+		request.Header.Del("Content-MD5")
+	}
 	if len(errs) > 0 {
 		srv.SimpleErrorResponse(writer, 400, strings.Join(errs, "\n"))
 		return
 	}
+	// With heartbeat=on, the response starts right away as a 202 and the
+	// outcome follows in the body, so validating a long manifest can't run
+	// into idle timeouts.
+	var hb *heartbeat
+	outputType := ""
+	if common.LooksTrue(request.URL.Query().Get("heartbeat")) {
+		outputType = bulkOutputType(writer, request)
+		writer.WriteHeader(http.StatusAccepted)
+		if outputType == "xml" {
+			writer.Write([]byte(xml.Header))
+		}
+		hb = startHeartbeat(writer, xlo.yieldFrequency)
+	}
+	fail := func(status int, body string, errs []string) {
+		if hb == nil {
+			srv.SimpleErrorResponse(writer, status, body)
+			return
+		}
+		hb.end()
+		writeSloPutResults(writer, outputType, status, body, "", "", errs)
+	}
 	var toPutManifest []segItem
 	i := 0
 	totalSize := int64(0)
@@ -539,6 +601,7 @@ func (xlo *xloMiddleware) handleSloPut(writer http.ResponseWriter, request *http
 		newReq, err := ctx.newSubrequest("HEAD", newPath, http.NoBody, request, "slo")
 		if err != nil {
 			ctx.Logger.Error("Couldn't create http.Request", zap.Error(err))
+			fail(http.StatusInternalServerError, "", nil)
 			return
 		}
 		pw := NewCaptureWriter()
@@ -597,13 +660,13 @@ func (xlo *xloMiddleware) handleSloPut(writer http.ResponseWriter, request *http
 		toPutManifest = append(toPutManifest, newSi)
 	}
 	if len(errs) > 0 {
-		srv.SimpleErrorResponse(writer, 400, strings.Join(errs, "\n"))
+		fail(400, strings.Join(errs, "\n"), errs)
 		return
 	}
 	xloEtagGen := fmt.Sprintf("%x", sloEtag.Sum(nil))
 	if reqEtag := request.Header.Get("Etag"); reqEtag != "" {
 		if strings.Trim(reqEtag, "\"") != xloEtagGen {
-			srv.SimpleErrorResponse(writer, 422, "Invalid Etag")
+			fail(422, "Invalid Etag", nil)
 			return
 		}
 	}
@@ -624,51 +687,117 @@ func (xlo *xloMiddleware) handleSloPut(writer http.ResponseWriter, request *http
 	request.Header.Set("Etag", fmt.Sprintf("%x", md5.Sum(newBody)))
 	request.Header.Set("Content-Length", strconv.Itoa(len(newBody)))
 
+	if hb != nil {
+		cw := NewCaptureWriter()
+		xlo.next.ServeHTTP(cw, request)
+		hb.end()
+		body := ""
+		if cw.status/100 != 2 {
+			body = string(cw.body)
+		}
+		writeSloPutResults(writer, outputType, cw.status, body, cw.Header().Get("Etag"), cw.Header().Get("Last-Modified"), nil)
+		return
+	}
 	etagWriter := &etagQuoteWriter{ResponseWriter: writer}
 	xlo.next.ServeHTTP(etagWriter, request)
 	return
 }
 
-func segmentIsSlo(request *http.Request, path string) bool {
+// writeSloPutResults writes the outcome of a heartbeat=on SLO PUT.
+func writeSloPutResults(writer io.Writer, outputType string, responseStatus int, responseBody, etag, lastModified string, errs []string) {
+	if errs == nil {
+		errs = []string{}
+	}
+	if etag != "" {
+		etag = "\"" + strings.Trim(etag, "\"") + "\""
+	}
+	switch outputType {
+	case "json":
+		b, _ := json.Marshal(map[string]interface{}{
+			"Response Status": httpStatusString(responseStatus),
+			"Response Body":   responseBody,
+			"Etag":            etag,
+			"Last Modified":   lastModified,
+			"Errors":          errs,
+		})
+		writer.Write(b)
+		writer.Write([]byte("\n"))
+	case "xml":
+		x := struct {
+			XMLName        xml.Name `xml:"put"`
+			ResponseStatus string   `xml:"response_status"`
+			ResponseBody   string   `xml:"response_body"`
+			Etag           string   `xml:"etag"`
+			LastModified   string   `xml:"last_modified"`
+			Errors         []string `xml:"errors>error"`
+		}{ResponseStatus: httpStatusString(responseStatus), ResponseBody: responseBody, Etag: etag, LastModified: lastModified, Errors: errs}
+		b, _ := xml.Marshal(x)
+		writer.Write(b)
+		writer.Write([]byte("\n"))
+	default:
+		writer.Write([]byte(fmt.Sprintf("Response Status: %s\n", httpStatusString(responseStatus))))
+		writer.Write([]byte(fmt.Sprintf("Response Body: %s\n", responseBody)))
+		writer.Write([]byte(fmt.Sprintf("Etag: %s\n", etag)))
+		writer.Write([]byte(fmt.Sprintf("Last Modified: %s\n", lastModified)))
+		writer.Write([]byte("Errors:\n"))
+		for _, e := range errs {
+			writer.Write([]byte(e + "\n"))
+		}
+	}
+}
+
+// maxSloDeleteDepth limits how deeply nested SLOs are followed on delete.
+const maxSloDeleteDepth = 10
+
+// sloDeletePaths returns the /container/object paths to delete to remove the
+// SLO manifest at /container/object manPath: its segments, those of any
+// nested SLOs, and then the manifest itself. Nested manifests that can't be
+// read are added to failures and left alone along with their segments.
+func (xlo *xloMiddleware) sloDeletePaths(request *http.Request, account, manPath string, depth int, failures *[][]string) ([]string, int, error) {
+	if depth > maxSloDeleteDepth {
+		return nil, http.StatusBadRequest, fmt.Errorf("Max recursion depth exceeded on %s", manPath)
+	}
 	ctx := GetProxyContext(request)
-	newReq, err := ctx.newSubrequest("HEAD", path, http.NoBody, request, "slo")
+	newReq, err := ctx.newSubrequest("GET", fmt.Sprintf("/v1/%s%s?multipart-manifest=get", account, manPath), http.NoBody, request, "slo")
 	if err != nil {
-		ctx.Logger.Error("error building subrequest", zap.Error(err))
-		return false
+		return nil, http.StatusInternalServerError, err
 	}
-	writer := NewCaptureWriter()
-	ctx.serveHTTPSubrequest(writer, newReq)
-	if writer.Header().Get("X-Static-Large-Object") == "True" {
-		return true
+	sw := NewCaptureWriter()
+	ctx.serveHTTPSubrequest(sw, newReq)
+	if sw.status/100 != 2 {
+		return nil, sw.status, fmt.Errorf("Error %d fetching manifest %s", sw.status, manPath)
 	}
-	return false
-}
-
-func (xlo *xloMiddleware) deleteAllSegments(w http.ResponseWriter, request *http.Request, manifest []segItem) error {
-	pathMap, err := common.ParseProxyPath(request.URL.Path)
-	if err != nil || pathMap["account"] == "" {
-		return fmt.Errorf("invalid path to slo delete: %s: %s", request.URL.Path, err)
+	if sw.Header().Get("X-Static-Large-Object") != "True" {
+		return nil, http.StatusBadRequest, fmt.Errorf("Not an SLO manifest: %s", manPath)
 	}
-	ctx := GetProxyContext(request)
+	var manifest []segItem
+	if err := json.Unmarshal(sw.body, &manifest); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Invalid manifest %s: %s", manPath, err)
+	}
+	var paths []string
 	for _, si := range manifest {
 		container, object, err := splitSegPath(si.Name)
 		if err != nil {
-			return fmt.Errorf("invalid slo item: %s: %s", si.Name, err)
-		}
-		newPath := fmt.Sprintf("/v1/%s/%s/%s", pathMap["account"], container, object)
-		if segmentIsSlo(request, newPath) {
-			newPath += "?multipart-manifest=delete"
+			*failures = append(*failures, []string{si.Name, httpStatusString(http.StatusBadRequest)})
+			continue
 		}
-		newReq, err := ctx.newSubrequest("DELETE", newPath, http.NoBody, request, "slo")
-		if err != nil {
-			return fmt.Errorf("error building subrequest: %s", err)
+		segPath := "/" + container + "/" + object
+		if si.SubSlo {
+			subPaths, status, err := xlo.sloDeletePaths(request, account, segPath, depth+1, failures)
+			if err != nil {
+				*failures = append(*failures, []string{segPath, httpStatusString(status)})
+				continue
+			}
+			paths = append(paths, subPaths...)
+			continue
 		}
-		sw := NewCaptureWriter()
-		ctx.serveHTTPSubrequest(sw, newReq)
+		paths = append(paths, segPath)
 	}
-	return nil
+	return append(paths, manPath), http.StatusOK, nil
 }
 
+// handleSloDelete deletes an SLO's segments and then its manifest, writing
+// the results the way bulk delete does.
 func (xlo *xloMiddleware) handleSloDelete(writer http.ResponseWriter, request *http.Request) {
 	xlo.sloDeleteRequestsMetric.Inc(1)
 	pathMap, err := common.ParseProxyPath(request.URL.Path)
@@ -677,17 +806,54 @@ func (xlo *xloMiddleware) handleSloDelete(writer http.ResponseWriter, request *h
 			"invalid must multipath DELETE to an object path: %s", request.URL.Path))
 		return
 	}
-	manifest, status, err := xlo.buildSloManifest(request, request.URL.Path)
-	if err != nil {
-		srv.SimpleErrorResponse(writer, status, fmt.Sprintf("invalid manifest json: %d %s", status, err))
-		return
+	ctx := GetProxyContext(request)
+	outputType := bulkOutputType(writer, request)
+	writer.WriteHeader(http.StatusOK)
+	if outputType == "xml" {
+		writer.Write([]byte(xml.Header))
+	}
+	hb := startHeartbeat(writer, xlo.yieldFrequency)
+	manPath := "/" + pathMap["container"] + "/" + pathMap["object"]
+	numberDeleted := 0
+	numberNotFound := 0
+	failures := [][]string{}
+	failureResponseType := http.StatusBadRequest
+	responseStatus := http.StatusOK
+	responseBody := ""
+	paths, status, err := xlo.sloDeletePaths(request, pathMap["account"], manPath, 0, &failures)
+	if status == http.StatusNotFound {
+		numberNotFound++
+	} else if err != nil {
+		failures = append(failures, []string{manPath, httpStatusString(status)})
+		if status/100 == 5 {
+			failureResponseType = http.StatusBadGateway
+		}
+		responseBody = err.Error()
+	}
+	for _, p := range paths {
+		subreq, err := ctx.newSubrequest("DELETE", "/v1/"+pathMap["account"]+p, http.NoBody, request, "slo")
+		if err != nil {
+			failures = append(failures, []string{p, httpStatusString(http.StatusInternalServerError)})
+			continue
+		}
+		sw := NewCaptureWriter()
+		ctx.serveHTTPSubrequest(sw, subreq)
+		if sw.status/100 == 5 {
+			failures = append(failures, []string{p, httpStatusString(sw.status)})
+			failureResponseType = http.StatusBadGateway
+		} else if sw.status == http.StatusNotFound {
+			numberNotFound++
+		} else if sw.status/100 != 2 {
+			failures = append(failures, []string{p, httpStatusString(sw.status)})
+		} else {
+			numberDeleted++
+		}
 	}
-	if err = xlo.deleteAllSegments(writer, request, manifest); err != nil {
-		srv.SimpleErrorResponse(writer, 400, fmt.Sprintf("error deleting slo: %s", err))
-		return
+	if len(failures) > 0 {
+		responseStatus = failureResponseType
 	}
-	xlo.next.ServeHTTP(writer, request)
-	return
+	hb.end()
+	writeBulkDeleteResults(writer, outputType, responseStatus, responseBody, numberDeleted, numberNotFound, failures)
 }
 
 func updateEtagIsAt(request *http.Request, etagLoc string) {
@@ -760,6 +926,9 @@ func NewXlo(config conf.Section, metricsScope tally.Scope) (func(http.Handler) h
 	sloGetRequestsMetric := metricsScope.Counter("slo_GET_requests")
 	sloPutRequestsMetric := metricsScope.Counter("slo_PUT_requests")
 	sloDeleteRequestsMetric := metricsScope.Counter("slo_DELETE_requests")
+	// yield_frequency is how often whitespace is sent to keep long SLO
+	// deletes and heartbeat=on PUTs alive.
+	yieldFrequency := time.Duration(config.GetInt("yield_frequency", 10)) * time.Second
 	return func(next http.Handler) http.Handler {
 		return &xloMiddleware{
 			next:                    next,
@@ -767,6 +936,7 @@ func NewXlo(config conf.Section, metricsScope tally.Scope) (func(http.Handler) h
 			sloGetRequestsMetric:    sloGetRequestsMetric,
 			sloPutRequestsMetric:    sloPutRequestsMetric,
 			sloDeleteRequestsMetric: sloDeleteRequestsMetric,
+			yieldFrequency:          yieldFrequency,
 		}
 	}, nil
 }
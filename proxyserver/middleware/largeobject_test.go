@@ -18,6 +18,8 @@ package middleware
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -374,6 +376,99 @@ func TestPutSlo(t *testing.T) {
 	require.Equal(t, "/v1/a/hat/c", heads[2])
 }
 
+func TestPutSloContentMD5(t *testing.T) {
+	var putMD5s []string
+	next := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method == "PUT" {
+			putMD5s = append(putMD5s, request.Header.Get("Content-MD5"))
+			writer.WriteHeader(201)
+			return
+		}
+		writer.Header().Set("Content-Type", "octet")
+		writer.Header().Set("Content-Length", "3")
+		switch request.URL.Path {
+		case "/v1/a/hat/a":
+			writer.Header().Set("Etag", "\"202cb962ac59075b964b07152d234b70\"")
+		case "/v1/a/hat/b":
+			writer.Header().Set("Etag", "\"250cf8b51c773f3f8dc8b4be867a9a02\"")
+		case "/v1/a/hat/c":
+			writer.Header().Set("Etag", "\"68053af2923e00204c3ca7c6a3150cf7\"")
+		}
+		writer.WriteHeader(200)
+	})
+	sum := md5.Sum([]byte(simplePutManifest))
+	for _, tc := range []struct {
+		contentMD5 string
+		status     int
+	}{
+		{base64.StdEncoding.EncodeToString(sum[:]), 201},
+		{"1B2M2Y8AsgTpgAmY7PhCfg==", 422},
+		{"not base64!", 400},
+	} {
+		sm := newTestXLOMiddleware(next)
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("PUT", "/v1/a/c/o?multipart-manifest=put", bytes.NewBuffer([]byte(simplePutManifest)))
+		require.Nil(t, err)
+		req.Header.Set("Content-Length", strconv.Itoa(len(simplePutManifest)))
+		req.Header.Set("Content-MD5", tc.contentMD5)
+		req = req.WithContext(context.WithValue(req.Context(), "proxycontext", NewFakeProxyContext(next)))
+		sm.ServeHTTP(w, req)
+		require.Equal(t, tc.status, w.Code, tc.contentMD5)
+	}
+	// the rewritten manifest is sent without the client's Content-MD5
+	require.Equal(t, []string{""}, putMD5s)
+}
+
+func TestPutSloHeartbeat(t *testing.T) {
+	next := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method == "PUT" {
+			writer.Header().Set("Etag", "abc")
+			writer.Header().Set("Last-Modified", "Mon, 22 May 2017 17:24:05 GMT")
+			writer.WriteHeader(201)
+		}
+		if request.Method == "HEAD" {
+			switch request.URL.Path {
+			case "/v1/a/hat/a":
+				writer.Header().Set("Content-Length", "3")
+				writer.Header().Set("Etag", "\"202cb962ac59075b964b07152d234b70\"")
+				writer.WriteHeader(200)
+			case "/v1/a/hat/b":
+				writer.Header().Set("Content-Length", "3")
+				writer.Header().Set("Etag", "\"250cf8b51c773f3f8dc8b4be867a9a02\"")
+				writer.WriteHeader(200)
+			default:
+				writer.WriteHeader(404)
+			}
+		}
+	})
+	sm := newTestXLOMiddleware(next)
+	do := func(manifest string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("PUT", "/v1/a/c/o?multipart-manifest=put&heartbeat=on", bytes.NewBuffer([]byte(manifest)))
+		require.Nil(t, err)
+		req.Header.Set("Content-Length", strconv.Itoa(len(manifest)))
+		req.Header.Set("Accept", "application/json")
+		fakeContext := NewFakeProxyContext(next)
+		req = req.WithContext(context.WithValue(req.Context(), "proxycontext", fakeContext))
+		sm.ServeHTTP(w, req)
+		resp := w.Result()
+		results := map[string]interface{}{}
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&results))
+		return resp.StatusCode, results
+	}
+
+	status, results := do(`[{"path":"/hat/a"},{"path":"/hat/b"}]`)
+	require.Equal(t, 202, status)
+	require.Equal(t, "201 Created", results["Response Status"])
+	require.Equal(t, "\"abc\"", results["Etag"])
+	require.Equal(t, "Mon, 22 May 2017 17:24:05 GMT", results["Last Modified"])
+
+	status, results = do(simplePutManifest)
+	require.Equal(t, 202, status)
+	require.Equal(t, "400 Bad Request", results["Response Status"])
+	require.Equal(t, 1, len(results["Errors"].([]interface{})))
+}
+
 func TestDeleteSlo(t *testing.T) {
 	var paths []string
 	next := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
@@ -421,8 +516,11 @@ func TestDeleteSlo(t *testing.T) {
 
 	sm.ServeHTTP(w, req)
 	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
 
-	require.Equal(t, 204, resp.StatusCode)
+	require.Equal(t, 200, resp.StatusCode)
+	require.Contains(t, string(body), "Response Status: 200 OK\n")
+	require.Contains(t, string(body), "Number Deleted: 4\n")
 	require.Equal(t, "/v1/a/c/o", paths[0])
 	require.Equal(t, "/v1/a/hat/a", paths[1])
 	require.Equal(t, "/v1/a/hat/b", paths[2])
@@ -430,6 +528,84 @@ func TestDeleteSlo(t *testing.T) {
 	require.Equal(t, "/v1/a/c/o", paths[4])
 }
 
+func TestDeleteSloNested(t *testing.T) {
+	var deleted []string
+	next := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method == "GET" {
+			require.Equal(t, "get", request.URL.Query().Get("multipart-manifest"))
+			writer.Header().Set("X-Static-Large-Object", "True")
+			writer.WriteHeader(200)
+			switch request.URL.Path {
+			case "/v1/a/c/o":
+				writer.Write([]byte(superManifest))
+			case "/v1/a/hat/man":
+				writer.Write([]byte(simpleManifest))
+			}
+		}
+		if request.Method == "DELETE" {
+			deleted = append(deleted, request.URL.Path)
+			if request.URL.Path == "/v1/a/hat/b" {
+				writer.WriteHeader(404)
+			} else if request.URL.Path == "/v1/a/hat/c" {
+				writer.WriteHeader(503)
+			} else {
+				writer.WriteHeader(204)
+			}
+		}
+	})
+	sm := newTestXLOMiddleware(next)
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("DELETE", "/v1/a/c/o?multipart-manifest=delete", nil)
+	require.Nil(t, err)
+	req.Header.Set("Accept", "application/json")
+	fakeContext := NewFakeProxyContext(next)
+	req = req.WithContext(context.WithValue(req.Context(), "proxycontext", fakeContext))
+
+	sm.ServeHTTP(w, req)
+	resp := w.Result()
+	require.Equal(t, 200, resp.StatusCode)
+	var results struct {
+		ResponseStatus string `json:"Response Status"`
+		NumberDeleted  int    `json:"Number Deleted"`
+		NumberNotFound int    `json:"Number Not Found"`
+		Errors         [][]string
+	}
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&results))
+	require.Equal(t, []string{"/v1/a/hat/a", "/v1/a/hat/b", "/v1/a/hat/c", "/v1/a/hat/man", "/v1/a/hat/b", "/v1/a/hat/c", "/v1/a/c/o"}, deleted)
+	require.Equal(t, "502 Bad Gateway", results.ResponseStatus)
+	require.Equal(t, 3, results.NumberDeleted)
+	require.Equal(t, 2, results.NumberNotFound)
+	require.Equal(t, [][]string{{"/hat/c", "503 Service Unavailable"}, {"/hat/c", "503 Service Unavailable"}}, results.Errors)
+}
+
+func TestDeleteSloNotSlo(t *testing.T) {
+	deletes := 0
+	next := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method == "GET" {
+			writer.WriteHeader(200)
+			writer.Write([]byte("not a slo"))
+		}
+		if request.Method == "DELETE" {
+			deletes++
+			writer.WriteHeader(204)
+		}
+	})
+	sm := newTestXLOMiddleware(next)
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("DELETE", "/v1/a/c/o?multipart-manifest=delete", nil)
+	require.Nil(t, err)
+	fakeContext := NewFakeProxyContext(next)
+	req = req.WithContext(context.WithValue(req.Context(), "proxycontext", fakeContext))
+
+	sm.ServeHTTP(w, req)
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+	require.Equal(t, 200, resp.StatusCode)
+	require.Contains(t, string(body), "Response Status: 400 Bad Request\n")
+	require.Contains(t, string(body), "/c/o, 400 Bad Request\n")
+	require.Equal(t, 0, deletes)
+}
+
 func TestGetDlo(t *testing.T) {
 	next := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		if request.Method == "GET" {
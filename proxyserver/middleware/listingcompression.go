@@ -0,0 +1,211 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/uber-go/tally"
+)
+
+// listingEncoder is a pooled compressor for one Content-Encoding.
+type listingEncoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var listingEncoderPools = map[string]*sync.Pool{
+	"gzip": {New: func() interface{} {
+		gz, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return gz
+	}},
+	"br": {New: func() interface{} {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}},
+}
+
+// listingContentTypes are the responses worth compressing; anything else,
+// like a container watch's event stream or a staticweb file, is sent as is.
+var listingContentTypes = []string{"application/json", "application/xml", "text/xml", "text/plain"}
+
+// chooseListingEncoding returns the encoding in encodings the Accept-Encoding
+// header gives the highest q-value, with ties going to the one listed first
+// in encodings, or "" if the client accepts none of them.
+func chooseListingEncoding(acceptEncoding string, encodings []string) string {
+	q := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name == "" {
+			continue
+		}
+		value := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if f, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					value = f
+				}
+			}
+		}
+		q[name] = value
+	}
+	best, bestQ := "", 0.0
+	for _, encoding := range encodings {
+		value, ok := q[encoding]
+		if !ok {
+			if value, ok = q["*"]; !ok {
+				continue
+			}
+		}
+		if value > bestQ {
+			best, bestQ = encoding, value
+		}
+	}
+	return best
+}
+
+type listingCompressionWriter struct {
+	http.ResponseWriter
+	encoding    string
+	minSize     int64
+	enc         listingEncoder
+	wroteHeader bool
+	counter     tally.Counter
+}
+
+func (w *listingCompressionWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	header := w.Header()
+	if status != http.StatusOK || header.Get("Content-Encoding") != "" || !listingContentType(header.Get("Content-Type")) {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if cl, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil && cl < w.minSize {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", w.encoding)
+	if etag := header.Get("Etag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("Etag", "W/"+etag)
+	}
+	w.enc = listingEncoderPools[w.encoding].Get().(listingEncoder)
+	w.enc.Reset(w.ResponseWriter)
+	w.counter.Inc(1)
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *listingCompressionWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.enc == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.enc.Write(b)
+}
+
+func (w *listingCompressionWriter) Flush() {
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	srv.Flush(w.ResponseWriter)
+}
+
+// close finishes the compressed stream and returns its encoder to the pool.
+func (w *listingCompressionWriter) close() {
+	if w.enc != nil {
+		w.enc.Close()
+		w.enc.Reset(nil)
+		listingEncoderPools[w.encoding].Put(w.enc)
+		w.enc = nil
+	}
+}
+
+func listingContentType(contentType string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	for _, ct := range listingContentTypes {
+		if contentType == ct {
+			return true
+		}
+	}
+	return false
+}
+
+// NewListingCompression returns a middleware that compresses account and
+// container listings and /info with gzip or brotli for clients that send
+// Accept-Encoding. It's built from the proxy-server section rather than the
+// pipeline, as /info is answered ahead of the pipeline's middlewares.
+func NewListingCompression(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
+	var encodings []string
+	for _, encoding := range strings.Split(config.GetDefault("listing_compression", ""), ",") {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if encoding == "" {
+			continue
+		}
+		if _, ok := listingEncoderPools[encoding]; !ok {
+			return nil, fmt.Errorf("invalid listing_compression encoding %q; use gzip or br", encoding)
+		}
+		encodings = append(encodings, encoding)
+	}
+	if len(encodings) == 0 {
+		return func(next http.Handler) http.Handler {
+			return next
+		}, nil
+	}
+	minSize := config.GetInt("listing_compression_min_size", 1024)
+	counters := map[string]tally.Counter{}
+	for _, encoding := range encodings {
+		counters[encoding] = metricsScope.Counter("listing_compression_" + encoding + "_responses")
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if request.Method != "GET" || request.Header.Get("Range") != "" {
+				next.ServeHTTP(writer, request)
+				return
+			}
+			if request.URL.Path != "/info" {
+				if apiReq, account, _, object := getPathParts(request); !apiReq || account == "" || object != "" {
+					next.ServeHTTP(writer, request)
+					return
+				}
+			}
+			writer.Header().Add("Vary", "Accept-Encoding")
+			encoding := chooseListingEncoding(request.Header.Get("Accept-Encoding"), encodings)
+			if encoding == "" {
+				next.ServeHTTP(writer, request)
+				return
+			}
+			w := &listingCompressionWriter{ResponseWriter: writer, encoding: encoding, minSize: minSize, counter: counters[encoding]}
+			defer w.close()
+			next.ServeHTTP(w, request)
+		})
+	}, nil
+}
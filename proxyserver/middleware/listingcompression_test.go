@@ -0,0 +1,135 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+)
+
+func TestChooseListingEncoding(t *testing.T) {
+	encodings := []string{"br", "gzip"}
+	require.Equal(t, "", chooseListingEncoding("", encodings))
+	require.Equal(t, "", chooseListingEncoding("identity", encodings))
+	require.Equal(t, "gzip", chooseListingEncoding("gzip, deflate", encodings))
+	require.Equal(t, "br", chooseListingEncoding("gzip, deflate, br", encodings))
+	require.Equal(t, "gzip", chooseListingEncoding("gzip;q=1.0, br;q=0.5", encodings))
+	require.Equal(t, "gzip", chooseListingEncoding("br;q=0, *", encodings))
+	require.Equal(t, "", chooseListingEncoding("*;q=0", encodings))
+	require.Equal(t, "gzip", chooseListingEncoding("GZIP", encodings))
+	require.Equal(t, "gzip", chooseListingEncoding("gzip, br", []string{"gzip"}))
+}
+
+func listingCompressionTestHandler(t *testing.T, settings string, contentType string, body string) http.Handler {
+	config, err := conf.StringConfig("[app:proxy-server]\n" + settings)
+	require.Nil(t, err)
+	mid, err := NewListingCompression(config.GetSection("app:proxy-server"), common.NewTestScope())
+	require.Nil(t, err)
+	return mid(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", contentType)
+		writer.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		writer.WriteHeader(http.StatusOK)
+		writer.Write([]byte(body))
+	}))
+}
+
+func TestListingCompression(t *testing.T) {
+	listing := "[" + strings.Repeat(`{"name":"some object","bytes":1024},`, 100) + `{"name":"last"}]`
+	h := listingCompressionTestHandler(t, "listing_compression = gzip, br", "application/json; charset=utf-8", listing)
+
+	req := httptest.NewRequest("GET", "/v1/a/c?format=json", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	require.Equal(t, "", w.Header().Get("Content-Length"))
+	require.True(t, w.Body.Len() < len(listing))
+	gz, err := gzip.NewReader(w.Body)
+	require.Nil(t, err)
+	body, err := ioutil.ReadAll(gz)
+	require.Nil(t, err)
+	require.Equal(t, listing, string(body))
+
+	req = httptest.NewRequest("GET", "/v1/a", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+
+	req = httptest.NewRequest("GET", "/info", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	body, err = ioutil.ReadAll(brotli.NewReader(w.Body))
+	require.Nil(t, err)
+	require.Equal(t, listing, string(body))
+
+	// objects, HEADs and clients that don't ask are left alone
+	for _, r := range []struct{ method, path, acceptEncoding string }{
+		{"GET", "/v1/a/c/o", "gzip"},
+		{"HEAD", "/v1/a/c", "gzip"},
+		{"GET", "/v1/a/c", ""},
+		{"GET", "/v1/a/c", "deflate"},
+	} {
+		req = httptest.NewRequest(r.method, r.path, nil)
+		req.Header.Set("Accept-Encoding", r.acceptEncoding)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		require.Equal(t, "", w.Header().Get("Content-Encoding"))
+		require.Equal(t, strconv.Itoa(len(listing)), w.Header().Get("Content-Length"))
+	}
+}
+
+func TestListingCompressionSkipped(t *testing.T) {
+	req := httptest.NewRequest("GET", "/v1/a/c", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	// off by default
+	h := listingCompressionTestHandler(t, "", "text/plain", strings.Repeat("object\n", 1000))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, "", w.Header().Get("Content-Encoding"))
+	require.Equal(t, "", w.Header().Get("Vary"))
+
+	h = listingCompressionTestHandler(t, "listing_compression = gzip", "text/plain", "small\n")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, "", w.Header().Get("Content-Encoding"))
+	require.Equal(t, "small\n", w.Body.String())
+
+	h = listingCompressionTestHandler(t, "listing_compression = gzip", "text/html", strings.Repeat("<p>index</p>", 1000))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, "", w.Header().Get("Content-Encoding"))
+
+	config, err := conf.StringConfig("[app:proxy-server]\nlisting_compression = gzip, zstd")
+	require.Nil(t, err)
+	_, err = NewListingCompression(config.GetSection("app:proxy-server"), common.NewTestScope())
+	require.NotNil(t, err)
+}
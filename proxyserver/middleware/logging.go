@@ -25,8 +25,34 @@ import (
 	"github.com/uber-go/tally"
 )
 
+// statsdMetricName returns the Swift proxy-logging name for a request, like
+// "object.GET.200", or "" for requests outside the /v1 API.
+func statsdMetricName(request *http.Request, status int) string {
+	apiReq, account, container, obj := getPathParts(request)
+	if !apiReq || account == "" {
+		return ""
+	}
+	statType := "account"
+	if obj != "" {
+		statType = "object"
+	} else if container != "" {
+		statType = "container"
+	}
+	method := request.Method
+	switch method {
+	case "GET", "HEAD", "POST", "PUT", "DELETE", "COPY", "OPTIONS", "APPEND":
+	default:
+		method = "BAD_METHOD"
+	}
+	return fmt.Sprintf("%s.%s.%d", statType, method, status)
+}
+
 func NewRequestLogger(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
 	requestsMetric := metricsScope.Counter("requests")
+	statsd, err := srv.NewStatsdClientFromConfig(config, "proxy-server")
+	if err != nil {
+		return nil, err
+	}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 			start := time.Now()
@@ -40,6 +66,13 @@ func NewRequestLogger(config conf.Section, metricsScope tally.Scope) (func(http.
 				requestsMetric.Inc(1)
 				metricsScope.Counter(request.Method + "_requests").Inc(1)
 				metricsScope.Counter(fmt.Sprintf("%d_responses", newWriter.Status)).Inc(1)
+				if name := statsdMetricName(request, newWriter.Status); statsd != nil && name != "" {
+					statsd.Timing(name+".timing", time.Since(start))
+					if request.Method == "GET" && !newWriter.ResponseStarted.IsZero() {
+						statsd.Timing(name+".first-byte.timing", newWriter.ResponseStarted.Sub(start))
+					}
+					statsd.UpdateStats(name+".xfer", int64(newReader.ByteCount+newWriter.ByteCount))
+				}
 			}
 		})
 	}, nil
@@ -0,0 +1,41 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsdMetricName(t *testing.T) {
+	for _, test := range []struct {
+		method, path string
+		status       int
+		name         string
+	}{
+		{"GET", "/v1/a", 200, "account.GET.200"},
+		{"PUT", "/v1/a/c", 201, "container.PUT.201"},
+		{"HEAD", "/v1/a/c/o/with/slashes", 404, "object.HEAD.404"},
+		{"BREW", "/v1/a/c/o", 405, "object.BAD_METHOD.405"},
+		{"GET", "/info", 200, ""},
+	} {
+		req, err := http.NewRequest(test.method, test.path, nil)
+		require.Nil(t, err)
+		require.Equal(t, test.name, statsdMetricName(req, test.status))
+	}
+}
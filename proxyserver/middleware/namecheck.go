@@ -0,0 +1,106 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/uber-go/tally"
+)
+
+// nameCheck refuses to create containers and objects with names that could
+// trip up the filesystems and tools they might end up in. Only PUTs are
+// checked, so anything already stored under a bad name can still be read
+// and deleted.
+type nameCheck struct {
+	next           http.Handler
+	forbiddenChars string
+	allowed        *regexp.Regexp
+	rejectMetric   tally.Counter
+}
+
+// badName returns the status and message to reject name with, or 0 if it's
+// acceptable.
+func (nc *nameCheck) badName(name string) (int, string) {
+	if !utf8.ValidString(name) || strings.Contains(name, "\x00") {
+		return http.StatusPreconditionFailed, "Invalid UTF8 or contains NULL"
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return http.StatusBadRequest, "Name contains control characters"
+		}
+	}
+	if nc.forbiddenChars != "" && strings.ContainsAny(name, nc.forbiddenChars) {
+		return http.StatusBadRequest, fmt.Sprintf("Name contains forbidden characters (%s)", nc.forbiddenChars)
+	}
+	if nc.allowed != nil && !nc.allowed.MatchString(name) {
+		return http.StatusBadRequest, fmt.Sprintf("Name doesn't match %s", nc.allowed.String())
+	}
+	return 0, ""
+}
+
+func (nc *nameCheck) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	apiReq, _, container, obj := getPathParts(request)
+	if !apiReq || request.Method != "PUT" || container == "" {
+		nc.next.ServeHTTP(writer, request)
+		return
+	}
+	name := container
+	if obj != "" {
+		name = obj
+	}
+	if status, msg := nc.badName(name); status != 0 {
+		nc.rejectMetric.Inc(1)
+		srv.SimpleErrorResponse(writer, status, msg)
+		return
+	}
+	nc.next.ServeHTTP(writer, request)
+}
+
+// NewNameCheck rejects new container and object names with control
+// characters, any of forbidden_chars, or that don't match allowed_regexp.
+func NewNameCheck(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
+	if !config.GetBool("enabled", false) {
+		return func(next http.Handler) http.Handler {
+			return next
+		}, nil
+	}
+	var allowed *regexp.Regexp
+	if pattern := config.GetDefault("allowed_regexp", ""); pattern != "" {
+		var err error
+		if allowed, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("Invalid allowed_regexp %q: %v", pattern, err)
+		}
+	}
+	forbiddenChars := config.GetDefault("forbidden_chars", "")
+	rejectMetric := metricsScope.Counter("name_check_rejected_requests")
+	RegisterInfo("name_check", map[string]interface{}{"forbidden_chars": forbiddenChars, "allowed_regexp": config.GetDefault("allowed_regexp", "")})
+	return func(next http.Handler) http.Handler {
+		return &nameCheck{
+			next:           next,
+			forbiddenChars: forbiddenChars,
+			allowed:        allowed,
+			rejectMetric:   rejectMetric,
+		}
+	}, nil
+}
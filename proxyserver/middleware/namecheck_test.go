@@ -0,0 +1,66 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+)
+
+func nameCheckTestRequest(t *testing.T, settings string, method, path string) int {
+	config, err := conf.StringConfig("[filter:name_check]\nenabled = true\n" + settings)
+	require.Nil(t, err)
+	mid, err := NewNameCheck(config.GetSection("filter:name_check"), common.NewTestScope())
+	require.Nil(t, err)
+	h := mid(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusCreated)
+	}))
+	req, err := http.NewRequest(method, "http://localhost"+path, nil)
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestNameCheckControlChars(t *testing.T) {
+	require.Equal(t, http.StatusCreated, nameCheckTestRequest(t, "", "PUT", "/v1/a/c/o"))
+	require.Equal(t, http.StatusBadRequest, nameCheckTestRequest(t, "", "PUT", "/v1/a/c/o%0Aname"))
+	require.Equal(t, http.StatusBadRequest, nameCheckTestRequest(t, "", "PUT", "/v1/a/c%1B"))
+	require.Equal(t, http.StatusPreconditionFailed, nameCheckTestRequest(t, "", "PUT", "/v1/a/c/o%FF"))
+	// existing objects can still be dealt with
+	require.Equal(t, http.StatusCreated, nameCheckTestRequest(t, "", "DELETE", "/v1/a/c/o%0Aname"))
+}
+
+func TestNameCheckForbiddenChars(t *testing.T) {
+	require.Equal(t, http.StatusBadRequest, nameCheckTestRequest(t, "forbidden_chars = <>", "PUT", "/v1/a/c/o%3C"))
+	require.Equal(t, http.StatusCreated, nameCheckTestRequest(t, "forbidden_chars = <>", "PUT", "/v1/a/c/o"))
+}
+
+func TestNameCheckAllowedRegexp(t *testing.T) {
+	settings := "allowed_regexp = ^[a-z0-9/._-]+$"
+	require.Equal(t, http.StatusCreated, nameCheckTestRequest(t, settings, "PUT", "/v1/a/c/dir/file.txt"))
+	require.Equal(t, http.StatusBadRequest, nameCheckTestRequest(t, settings, "PUT", "/v1/a/c/File"))
+
+	config, err := conf.StringConfig("[filter:name_check]\nenabled = true\nallowed_regexp = [")
+	require.Nil(t, err)
+	_, err = NewNameCheck(config.GetSection("filter:name_check"), common.NewTestScope())
+	require.NotNil(t, err)
+}
@@ -0,0 +1,369 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/uber-go/tally"
+)
+
+// cachedObject is a small object's response, or, with just Gen set, a
+// container's or object's cache generation. Objects are only valid while
+// their Gen matches their container's, so a container write can drop all its
+// objects at once, and, in a local cache, while their ObjectGen matches the
+// object's, so writes through other proxies drop them too.
+type cachedObject struct {
+	Gen       int64       `json:"gen"`
+	ObjectGen int64       `json:"object_gen,omitempty"`
+	Header    http.Header `json:"header,omitempty"`
+	Body      []byte      `json:"body,omitempty"`
+}
+
+func (o *cachedObject) size() int64 {
+	size := int64(len(o.Body)) + 8
+	for k, v := range o.Header {
+		size += int64(len(k))
+		for _, s := range v {
+			size += int64(len(s))
+		}
+	}
+	return size
+}
+
+type objectCacheStore interface {
+	get(ctx context.Context, mc ring.MemcacheRing, key string) *cachedObject
+	set(ctx context.Context, mc ring.MemcacheRing, key string, obj *cachedObject)
+	delete(ctx context.Context, mc ring.MemcacheRing, key string)
+}
+
+// memcacheObjectStore keeps objects in the proxy's memcache ring, shared by
+// all the proxies using it.
+type memcacheObjectStore struct {
+	ttl int
+}
+
+func (s *memcacheObjectStore) get(ctx context.Context, mc ring.MemcacheRing, key string) *cachedObject {
+	var obj cachedObject
+	if err := mc.GetStructured(ctx, key, &obj); err != nil || obj.Gen == 0 {
+		return nil
+	}
+	return &obj
+}
+
+func (s *memcacheObjectStore) set(ctx context.Context, mc ring.MemcacheRing, key string, obj *cachedObject) {
+	mc.Set(ctx, key, obj, s.ttl)
+}
+
+func (s *memcacheObjectStore) delete(ctx context.Context, mc ring.MemcacheRing, key string) {
+	mc.Delete(ctx, key)
+}
+
+type localObjectEntry struct {
+	key     string
+	obj     *cachedObject
+	expires time.Time
+}
+
+// localObjectStore is an in-memory LRU of up to maxBytes, private to this
+// proxy. The generations that invalidate it are kept in memcache, so every
+// proxy sees every write.
+type localObjectStore struct {
+	lock     sync.Mutex
+	ttl      time.Duration
+	maxBytes int64
+	size     int64
+	lru      *list.List
+	items    map[string]*list.Element
+}
+
+func newLocalObjectStore(maxBytes int64, ttl time.Duration) *localObjectStore {
+	return &localObjectStore{
+		ttl:      ttl,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+func (s *localObjectStore) get(ctx context.Context, mc ring.MemcacheRing, key string) *cachedObject {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	e, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+	entry := e.Value.(*localObjectEntry)
+	if time.Now().After(entry.expires) {
+		s.remove(e)
+		return nil
+	}
+	s.lru.MoveToFront(e)
+	return entry.obj
+}
+
+func (s *localObjectStore) set(ctx context.Context, mc ring.MemcacheRing, key string, obj *cachedObject) {
+	size := obj.size() + int64(len(key))
+	if size > s.maxBytes {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+	s.items[key] = s.lru.PushFront(&localObjectEntry{key: key, obj: obj, expires: time.Now().Add(s.ttl)})
+	s.size += size
+	for s.size > s.maxBytes {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *localObjectStore) delete(ctx context.Context, mc ring.MemcacheRing, key string) {
+	s.lock.Lock()
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+	s.lock.Unlock()
+}
+
+// remove drops e from the cache; the lock must be held.
+func (s *localObjectStore) remove(e *list.Element) {
+	entry := s.lru.Remove(e).(*localObjectEntry)
+	delete(s.items, entry.key)
+	s.size -= entry.obj.size() + int64(len(entry.key))
+}
+
+// objectCacheWriter passes a response through while keeping a copy of the
+// body, until it grows past maxSize.
+type objectCacheWriter struct {
+	http.ResponseWriter
+	status  int
+	maxSize int
+	body    []byte
+	tooBig  bool
+}
+
+func (w *objectCacheWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *objectCacheWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.tooBig {
+		if len(w.body)+len(b) > w.maxSize {
+			w.tooBig = true
+			w.body = nil
+		} else {
+			w.body = append(w.body, b...)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *objectCacheWriter) Flush() {
+	srv.Flush(w.ResponseWriter)
+}
+
+// objectCache serves small, publicly readable objects from a cache, so hot
+// assets don't have to come from the object servers on every request.
+type objectCache struct {
+	next          http.Handler
+	store         objectCacheStore
+	gens          objectCacheStore
+	local         bool
+	maxObjectSize int64
+	hitMetric     tally.Counter
+	missMetric    tally.Counter
+}
+
+// Responses to these headers depend on more than the object, so requests
+// with them aren't cached.
+var objectCacheBypassHeaders = []string{"Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since", "X-Newest", common.OpenExpiredHeader}
+
+func objectCacheKey(account, container, object string) string {
+	return fmt.Sprintf("objcache/%s/%s/%s", account, container, object)
+}
+
+func objectCacheGenKey(account, container string) string {
+	return fmt.Sprintf("objcache/%s/%s", account, container)
+}
+
+func objectCacheObjectGenKey(account, container, object string) string {
+	return fmt.Sprintf("objcachegen/%s/%s/%s", account, container, object)
+}
+
+// generation returns the cache generation kept at key, starting a new one
+// if it has none, so objects cached under an evicted generation don't come
+// back to life.
+func (oc *objectCache) generation(ctx context.Context, mc ring.MemcacheRing, key string) int64 {
+	if gen := oc.gens.get(ctx, mc, key); gen != nil {
+		return gen.Gen
+	}
+	return oc.newGeneration(ctx, mc, key)
+}
+
+func (oc *objectCache) newGeneration(ctx context.Context, mc ring.MemcacheRing, key string) int64 {
+	gen := &cachedObject{Gen: time.Now().UnixNano()}
+	oc.gens.set(ctx, mc, key, gen)
+	return gen.Gen
+}
+
+func (oc *objectCache) cacheable(request *http.Request, pctx *ProxyContext) bool {
+	if request.URL.RawQuery != "" || pctx.S3Auth != nil || pctx.depth > 0 {
+		return false
+	}
+	if request.Header.Get("X-Auth-Token") != "" || request.Header.Get("X-Storage-Token") != "" {
+		return false
+	}
+	for _, h := range objectCacheBypassHeaders {
+		if request.Header.Get(h) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (oc *objectCache) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	apiReq, account, container, object := getPathParts(request)
+	pctx := GetProxyContext(request)
+	if !apiReq || container == "" || pctx == nil {
+		oc.next.ServeHTTP(writer, request)
+		return
+	}
+	ctx := request.Context()
+	if request.Method != "GET" && request.Method != "HEAD" {
+		oc.next.ServeHTTP(writer, request)
+		if object == "" {
+			if request.Method == "PUT" || request.Method == "POST" || request.Method == "DELETE" {
+				oc.newGeneration(ctx, pctx.Cache, objectCacheGenKey(account, container))
+			}
+		} else {
+			oc.store.delete(ctx, pctx.Cache, objectCacheKey(account, container, object))
+			if oc.local {
+				oc.newGeneration(ctx, pctx.Cache, objectCacheObjectGenKey(account, container, object))
+			}
+		}
+		return
+	}
+	if object == "" || !oc.cacheable(request, pctx) {
+		oc.next.ServeHTTP(writer, request)
+		return
+	}
+	gen := oc.generation(ctx, pctx.Cache, objectCacheGenKey(account, container))
+	var objectGen int64
+	if oc.local {
+		objectGen = oc.generation(ctx, pctx.Cache, objectCacheObjectGenKey(account, container, object))
+	}
+	key := objectCacheKey(account, container, object)
+	cached := oc.store.get(ctx, pctx.Cache, key)
+	if cached != nil && common.DeleteAtPassed(cached.Header.Get("X-Delete-At")) {
+		oc.store.delete(ctx, pctx.Cache, key)
+		cached = nil
+	}
+	if cached != nil && cached.Gen == gen && cached.ObjectGen == objectGen {
+		ci, err := pctx.C.GetContainerInfo(ctx, account, container)
+		if err == nil {
+			pctx.ACL = ci.ReadACL
+			if pctx.Authorize != nil {
+				if ok, s := pctx.Authorize(request); !ok {
+					srv.StandardResponse(writer, s)
+					return
+				}
+			}
+			oc.hitMetric.Inc(1)
+			for k, v := range cached.Header {
+				writer.Header()[k] = v
+			}
+			writer.WriteHeader(http.StatusOK)
+			if request.Method == "GET" {
+				writer.Write(cached.Body)
+			}
+			return
+		}
+	}
+	oc.missMetric.Inc(1)
+	if request.Method == "HEAD" {
+		oc.next.ServeHTTP(writer, request)
+		return
+	}
+	w := &objectCacheWriter{ResponseWriter: writer, maxSize: int(oc.maxObjectSize)}
+	oc.next.ServeHTTP(w, request)
+	if w.status != http.StatusOK || w.tooBig {
+		return
+	}
+	header := writer.Header()
+	if header.Get("X-Static-Large-Object") != "" || header.Get("X-Object-Manifest") != "" {
+		return
+	}
+	if cl, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err != nil || cl != int64(len(w.body)) {
+		return
+	}
+	obj := &cachedObject{Gen: gen, ObjectGen: objectGen, Header: http.Header{}, Body: w.body}
+	for k, v := range header {
+		if k != "Date" && k != "X-Trans-Id" && k != "X-Openstack-Request-Id" {
+			obj.Header[k] = v
+		}
+	}
+	oc.store.set(ctx, pctx.Cache, key, obj)
+}
+
+func NewObjectCache(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
+	if !config.GetBool("enabled", false) {
+		return func(next http.Handler) http.Handler {
+			return next
+		}, nil
+	}
+	maxObjectSize := config.GetInt("max_object_size", 65536)
+	ttl := config.GetInt("cache_time", 60)
+	gens := &memcacheObjectStore{ttl: int(ttl)}
+	var store objectCacheStore
+	backend := config.GetDefault("backend", "memcache")
+	switch backend {
+	case "memcache":
+		store = gens
+	case "local":
+		store = newLocalObjectStore(config.GetInt("max_cache_size", 64*1024*1024), time.Duration(ttl)*time.Second)
+	default:
+		return nil, fmt.Errorf("invalid object cache backend: %s", backend)
+	}
+	hitMetric := metricsScope.Counter("object_cache_hits")
+	missMetric := metricsScope.Counter("object_cache_misses")
+	return func(next http.Handler) http.Handler {
+		return &objectCache{
+			next:          next,
+			store:         store,
+			gens:          gens,
+			local:         backend == "local",
+			maxObjectSize: maxObjectSize,
+			hitMetric:     hitMetric,
+			missMetric:    missMetric,
+		}
+	}, nil
+}
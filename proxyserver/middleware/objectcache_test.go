@@ -0,0 +1,218 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/client"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/common/test"
+	"go.uber.org/zap"
+)
+
+type objectCacheBackend struct {
+	gets     int
+	body     string
+	deleteAt string
+}
+
+func (b *objectCacheBackend) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case "GET", "HEAD":
+		b.gets++
+		writer.Header().Set("Content-Length", strconv.Itoa(len(b.body)))
+		writer.Header().Set("Etag", "etag-"+b.body)
+		if b.deleteAt != "" {
+			writer.Header().Set("X-Delete-At", b.deleteAt)
+		}
+		writer.WriteHeader(http.StatusOK)
+		if request.Method == "GET" {
+			writer.Write([]byte(b.body))
+		}
+	default:
+		writer.WriteHeader(http.StatusCreated)
+	}
+}
+
+// objectCacheTestHandler is an object cache and the memcache its requests
+// share.
+type objectCacheTestHandler struct {
+	http.Handler
+	mc *mockTokenMemcacheRing
+}
+
+func objectCacheTestRequest(t *testing.T, h *objectCacheTestHandler, method, path string, headers map[string]string, authorize AuthorizeFunc) *httptest.ResponseRecorder {
+	f, err := client.NewProxyClient(staticPolicyList, srv.NewTestConfigLoader(&test.FakeRing{}),
+		nil, "", "", "", "", "", conf.Config{})
+	require.Nil(t, err)
+	req, err := http.NewRequest(method, path, nil)
+	require.Nil(t, err)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req = req.WithContext(context.WithValue(req.Context(), "proxycontext", &ProxyContext{
+		ProxyContextMiddleware: &ProxyContextMiddleware{Cache: h.mc},
+		Logger:                 zap.NewNop(),
+		Authorize:              authorize,
+		C:                      f.NewRequestClient(nil, map[string]*client.ContainerInfo{"container/a/c": {ReadACL: ".r:*"}}, zap.NewNop()),
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func newTestObjectCache(t *testing.T, settings string, backend *objectCacheBackend) *objectCacheTestHandler {
+	config, err := conf.StringConfig("[filter:object_cache]\nenabled = true\nbackend = local\n" + settings)
+	require.Nil(t, err)
+	mid, err := NewObjectCache(config.GetSection("filter:object_cache"), common.NewTestScope())
+	require.Nil(t, err)
+	return &objectCacheTestHandler{Handler: mid(backend), mc: &mockTokenMemcacheRing{MockValues: map[string]*mockValue{}}}
+}
+
+func TestObjectCacheHitAndInvalidate(t *testing.T) {
+	backend := &objectCacheBackend{body: "hello"}
+	h := newTestObjectCache(t, "", backend)
+
+	w := objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", nil, nil)
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, "hello", w.Body.String())
+	w = objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", nil, nil)
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, "hello", w.Body.String())
+	require.Equal(t, "etag-hello", w.Header().Get("Etag"))
+	w = objectCacheTestRequest(t, h, "HEAD", "/v1/a/c/o", nil, nil)
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, "", w.Body.String())
+	require.Equal(t, 1, backend.gets)
+
+	// An object write drops just that object.
+	backend.body = "goodbye"
+	objectCacheTestRequest(t, h, "PUT", "/v1/a/c/o", nil, nil)
+	w = objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", nil, nil)
+	require.Equal(t, "goodbye", w.Body.String())
+	require.Equal(t, 2, backend.gets)
+
+	// A container write drops everything in the container.
+	objectCacheTestRequest(t, h, "GET", "/v1/a/c/o2", nil, nil)
+	require.Equal(t, 3, backend.gets)
+	objectCacheTestRequest(t, h, "POST", "/v1/a/c", nil, nil)
+	objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", nil, nil)
+	objectCacheTestRequest(t, h, "GET", "/v1/a/c/o2", nil, nil)
+	require.Equal(t, 5, backend.gets)
+}
+
+func TestLocalObjectCacheOtherProxiesWrites(t *testing.T) {
+	backend := &objectCacheBackend{body: "hello"}
+	h := newTestObjectCache(t, "", backend)
+	other := newTestObjectCache(t, "", backend)
+	other.mc = h.mc
+
+	objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", nil, nil)
+	objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", nil, nil)
+	require.Equal(t, 1, backend.gets)
+
+	// A write through another proxy drops the object here too.
+	backend.body = "goodbye"
+	objectCacheTestRequest(t, other, "PUT", "/v1/a/c/o", nil, nil)
+	w := objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", nil, nil)
+	require.Equal(t, "goodbye", w.Body.String())
+	require.Equal(t, 2, backend.gets)
+
+	// As does a write to its container.
+	objectCacheTestRequest(t, other, "POST", "/v1/a/c", nil, nil)
+	objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", nil, nil)
+	require.Equal(t, 3, backend.gets)
+	objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", nil, nil)
+	require.Equal(t, 3, backend.gets)
+}
+
+func TestObjectCacheBypass(t *testing.T) {
+	backend := &objectCacheBackend{body: "hello"}
+	h := newTestObjectCache(t, "max_object_size = 4", backend)
+	// Too big to cache.
+	objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", nil, nil)
+	objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", nil, nil)
+	require.Equal(t, 2, backend.gets)
+
+	backend.body = "hi"
+	for _, headers := range []map[string]string{
+		{"X-Auth-Token": "AUTH_tk"},
+		{"Range": "bytes=0-0"},
+		{"If-None-Match": "etag-hi"},
+	} {
+		objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", headers, nil)
+	}
+	objectCacheTestRequest(t, h, "GET", "/v1/a/c/o?multipart-manifest=get", nil, nil)
+	require.Equal(t, 6, backend.gets)
+}
+
+func TestObjectCacheExpired(t *testing.T) {
+	backend := &objectCacheBackend{body: "hello", deleteAt: strconv.FormatInt(time.Now().Unix()-1, 10)}
+	h := newTestObjectCache(t, "", backend)
+	objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", nil, nil)
+	objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", nil, nil)
+	require.Equal(t, 2, backend.gets)
+
+	backend.deleteAt = ""
+	objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", map[string]string{"X-Open-Expired": "true"}, nil)
+	objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", map[string]string{"X-Open-Expired": "true"}, nil)
+	require.Equal(t, 4, backend.gets)
+}
+
+func TestObjectCacheAuthorizes(t *testing.T) {
+	backend := &objectCacheBackend{body: "hello"}
+	h := newTestObjectCache(t, "", backend)
+	objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", nil, nil)
+	var acl string
+	w := objectCacheTestRequest(t, h, "GET", "/v1/a/c/o", nil, func(r *http.Request) (bool, int) {
+		acl = GetProxyContext(r).ACL
+		return false, http.StatusUnauthorized
+	})
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, ".r:*", acl)
+	require.Equal(t, 1, backend.gets)
+}
+
+func TestLocalObjectStoreLRU(t *testing.T) {
+	s := newLocalObjectStore(100, time.Minute)
+	obj := func(size int) *cachedObject { return &cachedObject{Gen: 1, Body: make([]byte, size)} }
+	s.set(nil, nil, "a", obj(40))
+	s.set(nil, nil, "b", obj(40))
+	require.NotNil(t, s.get(nil, nil, "a"))
+	s.set(nil, nil, "c", obj(40))
+	require.NotNil(t, s.get(nil, nil, "a"))
+	require.Nil(t, s.get(nil, nil, "b"))
+	require.NotNil(t, s.get(nil, nil, "c"))
+	s.set(nil, nil, "huge", obj(200))
+	require.Nil(t, s.get(nil, nil, "huge"))
+	s.delete(nil, nil, "a")
+	require.Nil(t, s.get(nil, nil, "a"))
+	require.Equal(t, int64(49), s.size)
+
+	s = newLocalObjectStore(100, -time.Second)
+	s.set(nil, nil, "a", obj(10))
+	require.Nil(t, s.get(nil, nil, "a"))
+	require.Equal(t, int64(0), s.size)
+}
@@ -0,0 +1,132 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/uber-go/tally"
+)
+
+// requestPriorityHeader lets clients put their requests in a lower priority
+// class than interactive, such as tools doing bulk work.
+const requestPriorityHeader = "X-Request-Priority"
+
+type qosClass struct {
+	slots         chan struct{}
+	requestMetric tally.Counter
+	rejectMetric  tally.Counter
+}
+
+// qos sorts requests into priority classes, each with its own concurrency
+// limit in the proxy, and passes the class on to the backends in
+// common.PriorityHeader, so object servers can limit each class per device.
+//
+// A request over its class's limit waits up to queue_timeout for one of the
+// class's requests to finish before it's turned away with a 503. Every class
+// is below interactive, so clients may choose any of them.
+type qos struct {
+	next         http.Handler
+	classes      map[string]*qosClass
+	queueTimeout time.Duration
+	retryAfter   int64
+}
+
+func qosSubrequestCopy(dst, src *http.Request) {
+	if p := src.Header.Get(common.PriorityHeader); p != "" {
+		dst.Header.Set(common.PriorityHeader, p)
+	}
+}
+
+// acquire waits for a slot in the class, returning false if none came free
+// in time.
+func (q *qos) acquire(request *http.Request, class *qosClass) bool {
+	select {
+	case class.slots <- struct{}{}:
+		return true
+	default:
+	}
+	timer := time.NewTimer(q.queueTimeout)
+	defer timer.Stop()
+	select {
+	case class.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-request.Context().Done():
+		return false
+	}
+}
+
+func (q *qos) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	ctx := GetProxyContext(request)
+	if ctx == nil || ctx.depth > 0 {
+		// Subrequests already have their parent's priority, and are covered
+		// by its slot.
+		q.next.ServeHTTP(writer, request)
+		return
+	}
+	priority := strings.ToLower(request.Header.Get(requestPriorityHeader))
+	if !common.ValidPriority(priority) {
+		priority = common.PriorityInteractive
+	}
+	request.Header.Del(requestPriorityHeader)
+	request.Header.Set(common.PriorityHeader, priority)
+	ctx.addSubrequestCopy(qosSubrequestCopy)
+	class := q.classes[priority]
+	class.requestMetric.Inc(1)
+	if class.slots != nil {
+		if !q.acquire(request, class) {
+			class.rejectMetric.Inc(1)
+			writer.Header().Set("Retry-After", strconv.FormatInt(q.retryAfter, 10))
+			srv.StandardResponse(writer, http.StatusServiceUnavailable)
+			return
+		}
+		defer func() { <-class.slots }()
+	}
+	q.next.ServeHTTP(writer, request)
+}
+
+func NewQoS(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
+	classes := map[string]*qosClass{}
+	for _, priority := range common.Priorities {
+		class := &qosClass{
+			requestMetric: metricsScope.Counter("qos_" + priority + "_requests"),
+			rejectMetric:  metricsScope.Counter("qos_" + priority + "_rejected"),
+		}
+		// A <priority>_concurrency of 0 leaves the class unlimited.
+		if limit := config.GetInt(priority+"_concurrency", 0); limit > 0 {
+			class.slots = make(chan struct{}, limit)
+		}
+		classes[priority] = class
+	}
+	queueTimeout := time.Duration(config.GetInt("queue_timeout_ms", 10000)) * time.Millisecond
+	retryAfter := config.GetInt("retry_after", 1)
+	return func(next http.Handler) http.Handler {
+		return &qos{
+			next:         next,
+			classes:      classes,
+			queueTimeout: queueTimeout,
+			retryAfter:   retryAfter,
+		}
+	}, nil
+}
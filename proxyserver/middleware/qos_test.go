@@ -0,0 +1,98 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"go.uber.org/zap"
+)
+
+func newTestQoS(t *testing.T, settings string, next http.Handler) http.Handler {
+	config, err := conf.StringConfig("[filter:qos]\n" + settings)
+	require.Nil(t, err)
+	mid, err := NewQoS(config.GetSection("filter:qos"), common.NewTestScope())
+	require.Nil(t, err)
+	return mid(next)
+}
+
+func qosTestRequest(h http.Handler, priority string) (*httptest.ResponseRecorder, *http.Request) {
+	ctx := &ProxyContext{Logger: zap.NewNop()}
+	req, _ := http.NewRequest("GET", "/v1/a/c/o", nil)
+	if priority != "" {
+		req.Header.Set(requestPriorityHeader, priority)
+	}
+	req = req.WithContext(context.WithValue(req.Context(), "proxycontext", ctx))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w, req
+}
+
+func TestQoSClassify(t *testing.T) {
+	var passed http.Header
+	h := newTestQoS(t, "", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		passed = request.Header
+		writer.WriteHeader(http.StatusOK)
+	}))
+	qosTestRequest(h, "")
+	require.Equal(t, common.PriorityInteractive, passed.Get(common.PriorityHeader))
+	_, req := qosTestRequest(h, "Background")
+	require.Equal(t, common.PriorityBackground, passed.Get(common.PriorityHeader))
+	require.Equal(t, "", passed.Get(requestPriorityHeader))
+	qosTestRequest(h, "urgent")
+	require.Equal(t, common.PriorityInteractive, passed.Get(common.PriorityHeader))
+
+	sub, err := GetProxyContext(req).newSubrequest("HEAD", "/v1/a/c/o2", http.NoBody, req, "test")
+	require.Nil(t, err)
+	require.Equal(t, common.PriorityBackground, sub.Header.Get(common.PriorityHeader))
+}
+
+func TestQoSConcurrency(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{})
+	h := newTestQoS(t, "background_concurrency = 1\nqueue_timeout_ms = 10\nretry_after = 5", http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get(common.PriorityHeader) == common.PriorityBackground {
+			started <- struct{}{}
+			<-block
+		}
+		writer.WriteHeader(http.StatusOK)
+	}))
+	done := make(chan int)
+	go func() {
+		w, _ := qosTestRequest(h, common.PriorityBackground)
+		done <- w.Code
+	}()
+	<-started
+
+	w, _ := qosTestRequest(h, common.PriorityBackground)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "5", w.Header().Get("Retry-After"))
+	// Other classes aren't held up.
+	w, _ = qosTestRequest(h, "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	close(block)
+	require.Equal(t, http.StatusOK, <-done)
+	go func() { <-started }()
+	w, _ = qosTestRequest(h, common.PriorityBackground)
+	require.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,114 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/uber-go/tally"
+)
+
+// readOnly rejects writes when the whole cluster is configured read only, or
+// when an account has been frozen by a reseller admin setting
+// X-Account-Read-Only: true on it.
+type readOnly struct {
+	next          http.Handler
+	clusterFrozen bool
+	allowDeletes  bool
+	retryAfter    int64
+	rejectMetric  tally.Counter
+}
+
+func isWriteMethod(method string) bool {
+	switch method {
+	case "PUT", "POST", "DELETE", "COPY", "PATCH", "APPEND":
+		return true
+	}
+	return false
+}
+
+func (ro *readOnly) reject(writer http.ResponseWriter, msg string) {
+	ro.rejectMetric.Inc(1)
+	writer.Header().Set("Retry-After", strconv.FormatInt(ro.retryAfter, 10))
+	srv.SimpleErrorResponse(writer, http.StatusServiceUnavailable, msg)
+}
+
+func (ro *readOnly) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	apiReq, account, container, _ := getPathParts(request)
+	ctx := GetProxyContext(request)
+	if !apiReq || account == "" || ctx == nil {
+		ro.next.ServeHTTP(writer, request)
+		return
+	}
+	if container == "" && (request.Method == "PUT" || request.Method == "POST") {
+		if value, ok := request.Header["X-Account-Read-Only"]; ok {
+			if ctx.Authorize != nil {
+				if ok, st := ctx.Authorize(request); !ok {
+					srv.StandardResponse(writer, st)
+					return
+				}
+			}
+			if !ctx.ResellerRequest {
+				srv.StandardResponse(writer, http.StatusForbidden)
+				return
+			}
+			request.Header.Del("X-Account-Read-Only")
+			if common.LooksTrue(value[0]) {
+				request.Header.Set("X-Account-Sysmeta-Read-Only", "true")
+			} else {
+				request.Header.Set("X-Account-Sysmeta-Read-Only", "")
+			}
+			if !ro.clusterFrozen {
+				ro.next.ServeHTTP(writer, request)
+				return
+			}
+		}
+	}
+	if !isWriteMethod(request.Method) || (ro.allowDeletes && request.Method == "DELETE") {
+		ro.next.ServeHTTP(writer, request)
+		return
+	}
+	if ro.clusterFrozen {
+		ro.reject(writer, "The cluster is read only.")
+		return
+	}
+	if ai, err := ctx.GetAccountInfo(request.Context(), account); err == nil && common.LooksTrue(ai.SysMetadata["Read-Only"]) {
+		ro.reject(writer, "The account is read only.")
+		return
+	}
+	ro.next.ServeHTTP(writer, request)
+}
+
+func NewReadOnly(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
+	clusterFrozen := config.GetBool("read_only", false)
+	allowDeletes := config.GetBool("allow_deletes", false)
+	retryAfter := config.GetInt("retry_after", 3600)
+	rejectMetric := metricsScope.Counter("read_only_rejected_requests")
+	RegisterInfo("read_only", map[string]interface{}{"read_only": clusterFrozen, "allow_deletes": allowDeletes})
+	return func(next http.Handler) http.Handler {
+		return &readOnly{
+			next:          next,
+			clusterFrozen: clusterFrozen,
+			allowDeletes:  allowDeletes,
+			retryAfter:    retryAfter,
+			rejectMetric:  rejectMetric,
+		}
+	}, nil
+}
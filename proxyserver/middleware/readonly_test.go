@@ -0,0 +1,95 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"go.uber.org/zap"
+)
+
+func readOnlyTestRequest(t *testing.T, settings string, method, path string, frozen, reseller bool) (*httptest.ResponseRecorder, *http.Request) {
+	config, err := conf.StringConfig("[filter:read_only]\n" + settings)
+	require.Nil(t, err)
+	mid, err := NewReadOnly(config.GetSection("filter:read_only"), common.NewTestScope())
+	require.Nil(t, err)
+	var passed *http.Request
+	h := mid(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		passed = request
+		writer.WriteHeader(http.StatusNoContent)
+	}))
+	ai := &AccountInfo{SysMetadata: map[string]string{}}
+	if frozen {
+		ai.SysMetadata["Read-Only"] = "true"
+	}
+	ctx := &ProxyContext{
+		Logger:           zap.NewNop(),
+		ResellerRequest:  reseller,
+		accountInfoCache: map[string]*AccountInfo{"account/a": ai},
+	}
+	req, err := http.NewRequest(method, path, nil)
+	require.Nil(t, err)
+	req = req.WithContext(context.WithValue(req.Context(), "proxycontext", ctx))
+	if method == "POST" {
+		req.Header.Set("X-Account-Read-Only", "true")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w, passed
+}
+
+func TestReadOnlyAccount(t *testing.T) {
+	w, _ := readOnlyTestRequest(t, "", "PUT", "/v1/a/c/o", true, false)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "3600", w.Header().Get("Retry-After"))
+
+	w, _ = readOnlyTestRequest(t, "", "GET", "/v1/a/c/o", true, false)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w, _ = readOnlyTestRequest(t, "", "PUT", "/v1/a/c/o", false, false)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w, _ = readOnlyTestRequest(t, "allow_deletes = true", "DELETE", "/v1/a/c/o", true, false)
+	require.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestReadOnlyCluster(t *testing.T) {
+	w, _ := readOnlyTestRequest(t, "read_only = true\nretry_after = 60", "DELETE", "/v1/a/c", false, false)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	w, _ = readOnlyTestRequest(t, "read_only = true", "HEAD", "/v1/a/c", false, false)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w, _ = readOnlyTestRequest(t, "read_only = true", "POST", "/v1/a", false, true)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestReadOnlySetFlag(t *testing.T) {
+	w, _ := readOnlyTestRequest(t, "", "POST", "/v1/a", false, false)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	w, passed := readOnlyTestRequest(t, "", "POST", "/v1/a", true, true)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "true", passed.Header.Get("X-Account-Sysmeta-Read-Only"))
+	require.Equal(t, "", passed.Header.Get("X-Account-Read-Only"))
+}
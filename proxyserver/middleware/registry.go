@@ -0,0 +1,173 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/uber-go/tally"
+)
+
+// Constructor builds a middleware from its section of the proxy config.
+type Constructor func(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error)
+
+// Registration describes a middleware that can be named in the proxy's
+// pipeline config.
+type Registration struct {
+	Construct Constructor
+	// Section is the config section the middleware is built from. It
+	// defaults to "filter:" and the middleware's name.
+	Section string
+	// After and Before name middlewares that, if they're also in the
+	// pipeline, have to come before or after this one.
+	After  []string
+	Before []string
+}
+
+var (
+	registry     = map[string]Registration{}
+	registryLock sync.Mutex
+)
+
+// Register makes a middleware available to the proxy's pipeline config
+// under name. Middlewares outside this package call it from an init func,
+// so they only need compiling into the proxy binary to be usable.
+// Registering a name twice panics.
+func Register(name string, r Registration) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("middleware %q registered twice", name))
+	}
+	if r.Section == "" {
+		r.Section = "filter:" + name
+	}
+	registry[name] = r
+}
+
+// CheckPipeline returns an error if any of the named middlewares isn't
+// registered or appears more than once, or if their ordering constraints
+// are broken.
+func CheckPipeline(names []string) error {
+	_, err := pipelineRegistrations(names)
+	return err
+}
+
+func pipelineRegistrations(names []string) ([]Registration, error) {
+	registryLock.Lock()
+	regs := make([]Registration, len(names))
+	for i, name := range names {
+		r, ok := registry[name]
+		if !ok {
+			registryLock.Unlock()
+			return nil, fmt.Errorf("Unknown middleware %q in pipeline", name)
+		}
+		regs[i] = r
+	}
+	registryLock.Unlock()
+	position := map[string]int{}
+	for i, name := range names {
+		if _, ok := position[name]; ok {
+			return nil, fmt.Errorf("Middleware %q is in the pipeline more than once", name)
+		}
+		position[name] = i
+	}
+	for i, name := range names {
+		for _, after := range regs[i].After {
+			if j, ok := position[after]; ok && j > i {
+				return nil, fmt.Errorf("Middleware %q has to come after %q in the pipeline", name, after)
+			}
+		}
+		for _, before := range regs[i].Before {
+			if j, ok := position[before]; ok && j < i {
+				return nil, fmt.Errorf("Middleware %q has to come before %q in the pipeline", name, before)
+			}
+		}
+	}
+	return regs, nil
+}
+
+// NewPipeline constructs the named middlewares, outermost first, after
+// checking them with CheckPipeline. Each is timed, as is the handler at the
+// end of the pipeline, as "proxy-server", to the middleware.duration timer
+// tagged with its name and to the request's MiddlewareTimes.
+func NewPipeline(names []string, config conf.Config, metricsScope tally.Scope) ([]func(http.Handler) http.Handler, error) {
+	regs, err := pipelineRegistrations(names)
+	if err != nil {
+		return nil, err
+	}
+	timerScope := metricsScope.SubScope("middleware")
+	timer := func(name string) tally.Timer {
+		return timerScope.Tagged(map[string]string{"middleware": name}).Timer("duration")
+	}
+	mids := make([]func(http.Handler) http.Handler, len(names), len(names)+1)
+	for i, r := range regs {
+		mid, err := r.Construct(config.GetSection(r.Section), metricsScope)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to construct middleware for %s: %v", r.Section, err)
+		}
+		mids[i] = timeMiddleware(names[i], mid, timer(names[i]))
+	}
+	return append(mids, timeHandler("proxy-server", timer("proxy-server"))), nil
+}
+
+func init() {
+	// The auth middlewares leave a request alone if something ahead of them
+	// has already decided how to authorize it.
+	auth := []string{"tempauth", "authtoken", "keystoneauth"}
+	for name, r := range map[string]Registration{
+		"catch_errors":     {Construct: NewCatchError},
+		"healthcheck":      {Construct: NewHealthcheck},
+		"proxy-logging":    {Construct: NewRequestLogger},
+		"requeststats":     {Construct: NewRequestStats, After: []string{"s3api"}},
+		"slowlog":          {Construct: NewSlowRequestLog},
+		"qos":              {Construct: NewQoS},
+		"auth_challenge":   {Construct: NewAuthChallenge, Before: append([]string{"s3auth", "formpost", "tempurl", "cdn", "container_sync"}, auth...)},
+		"s3website":        {Construct: NewS3Website, Section: "filter:s3api", Before: []string{"s3auth"}},
+		"s3auth":           {Construct: NewS3Auth, Section: "filter:s3api", Before: append([]string{"s3api"}, auth...)},
+		"crossdomain":      {Construct: NewCrossDomain},
+		"cors":             {Construct: NewCors},
+		"formpost":         {Construct: NewFormPost, Before: auth},
+		"tempurl":          {Construct: NewTempURL, Before: auth},
+		"cdn":              {Construct: NewCdn},
+		"container_sync":   {Construct: NewContainerSync, Before: auth},
+		"tempauth":         {Construct: NewTempAuth},
+		"authtoken":        {Construct: NewAuthToken},
+		"s3api":            {Construct: NewS3Api},
+		"keystoneauth":     {Construct: NewKeystoneAuth, After: []string{"authtoken"}},
+		"bulk":             {Construct: NewBulk},
+		"multirange":       {Construct: NewMultirange},
+		"ratelimit":        {Construct: NewRatelimiter},
+		"staticweb":        {Construct: NewStaticWeb},
+		"copy":             {Construct: NewCopyMiddleware},
+		"object_cache":     {Construct: NewObjectCache},
+		"cache_control":    {Construct: NewCacheControl},
+		"name_check":       {Construct: NewNameCheck},
+		"read_only":        {Construct: NewReadOnly},
+		"retention":        {Construct: NewRetention},
+		"account-quotas":   {Construct: NewAccountQuota},
+		"container-quotas": {Construct: NewContainerQuota},
+		"versioned_writes": {Construct: NewVersionedWrites},
+		"slo":              {Construct: NewXlo},
+		"resumable":        {Construct: NewResumableUpload},
+		"compression":      {Construct: NewCompression},
+	} {
+		Register(name, r)
+	}
+}
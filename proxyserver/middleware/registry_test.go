@@ -0,0 +1,66 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/uber-go/tally"
+)
+
+func TestRegisterPipeline(t *testing.T) {
+	Register("test_greeting", Registration{
+		Construct: func(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
+			greeting := config.GetDefault("greeting", "hello")
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+					writer.Header().Set("X-Greeting", greeting)
+					next.ServeHTTP(writer, request)
+				})
+			}, nil
+		},
+		After: []string{"catch_errors"},
+	})
+	require.Panics(t, func() { Register("test_greeting", Registration{}) })
+
+	config, err := conf.StringConfig("[filter:test_greeting]\ngreeting = hi\n")
+	require.Nil(t, err)
+	mids, err := NewPipeline([]string{"catch_errors", "test_greeting"}, config, tally.NoopScope)
+	require.Nil(t, err)
+	// The proxy's handlers at the end are timed too.
+	require.Equal(t, 3, len(mids))
+	rec := httptest.NewRecorder()
+	mids[1](http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, "hi", rec.Header().Get("X-Greeting"))
+
+	// Constraints only apply to middlewares that are in the pipeline.
+	_, err = NewPipeline([]string{"test_greeting"}, config, tally.NoopScope)
+	require.Nil(t, err)
+	_, err = NewPipeline([]string{"test_greeting", "catch_errors"}, config, tally.NoopScope)
+	require.Equal(t, `Middleware "test_greeting" has to come after "catch_errors" in the pipeline`, err.Error())
+	_, err = NewPipeline([]string{"tempauth", "tempurl"}, config, tally.NoopScope)
+	require.Equal(t, `Middleware "tempurl" has to come before "tempauth" in the pipeline`, err.Error())
+	_, err = NewPipeline([]string{"requeststats", "s3api"}, config, tally.NoopScope)
+	require.Equal(t, `Middleware "requeststats" has to come after "s3api" in the pipeline`, err.Error())
+	_, err = NewPipeline([]string{"test_greeting", "test_greeting"}, config, tally.NoopScope)
+	require.Equal(t, `Middleware "test_greeting" is in the pipeline more than once`, err.Error())
+	_, err = NewPipeline([]string{"catch_errors", "nope"}, config, tally.NoopScope)
+	require.Equal(t, `Unknown middleware "nope" in pipeline`, err.Error())
+}
@@ -0,0 +1,252 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/uber-go/tally"
+)
+
+// RequestCounts are the totals kept for an account or container.
+type RequestCounts struct {
+	Requests int64 `json:"requests"`
+	Errors   int64 `json:"errors"`
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+}
+
+func (rc *RequestCounts) add(o *RequestCounts) {
+	rc.Requests += o.Requests
+	rc.Errors += o.Errors
+	rc.BytesIn += o.BytesIn
+	rc.BytesOut += o.BytesOut
+}
+
+type RequestStatsEntry struct {
+	Name string `json:"name"`
+	RequestCounts
+}
+
+type requestStatsBucket struct {
+	start      int64
+	accounts   map[string]*RequestCounts
+	containers map[string]*RequestCounts
+}
+
+// requestStats aggregates request counts in this proxy over a rolling window
+// made of a ring of fixed length buckets.
+type requestStats struct {
+	sync.Mutex
+	bucketSeconds int64
+	buckets       []*requestStatsBucket
+	maxEntries    int
+	now           func() time.Time
+}
+
+var (
+	globalRequestStats *requestStats
+	rsl                sync.Mutex
+)
+
+func newRequestStats(bucketSeconds int64, buckets int, maxEntries int) *requestStats {
+	rs := &requestStats{bucketSeconds: bucketSeconds, maxEntries: maxEntries, now: time.Now}
+	for i := 0; i < buckets; i++ {
+		rs.buckets = append(rs.buckets, &requestStatsBucket{start: -1})
+	}
+	return rs
+}
+
+func (rs *requestStats) bucket() *requestStatsBucket {
+	start := rs.now().Unix() / rs.bucketSeconds * rs.bucketSeconds
+	b := rs.buckets[(start/rs.bucketSeconds)%int64(len(rs.buckets))]
+	if b.start != start {
+		b.start = start
+		b.accounts = map[string]*RequestCounts{}
+		b.containers = map[string]*RequestCounts{}
+	}
+	return b
+}
+
+func (rs *requestStats) record(account, container string, counts *RequestCounts) {
+	rs.Lock()
+	defer rs.Unlock()
+	b := rs.bucket()
+	update := func(m map[string]*RequestCounts, key string) {
+		c := m[key]
+		if c == nil {
+			if len(m) >= rs.maxEntries {
+				return
+			}
+			c = &RequestCounts{}
+			m[key] = c
+		}
+		c.add(counts)
+	}
+	update(b.accounts, account)
+	if container != "" {
+		update(b.containers, account+"/"+container)
+	}
+}
+
+// top returns the busiest accounts, or containers, seen in the last window
+// seconds ordered by the given counter.
+func (rs *requestStats) top(containers bool, window int64, by string, limit int) []*RequestStatsEntry {
+	rs.Lock()
+	totals := map[string]*RequestCounts{}
+	oldest := rs.now().Unix() - window
+	for _, b := range rs.buckets {
+		if b.start < 0 || b.start+rs.bucketSeconds <= oldest {
+			continue
+		}
+		m := b.accounts
+		if containers {
+			m = b.containers
+		}
+		for name, c := range m {
+			if totals[name] == nil {
+				totals[name] = &RequestCounts{}
+			}
+			totals[name].add(c)
+		}
+	}
+	rs.Unlock()
+	entries := make([]*RequestStatsEntry, 0, len(totals))
+	for name, c := range totals {
+		entries = append(entries, &RequestStatsEntry{Name: name, RequestCounts: *c})
+	}
+	key := func(e *RequestStatsEntry) int64 {
+		switch by {
+		case "errors":
+			return e.Errors
+		case "bytes_in":
+			return e.BytesIn
+		case "bytes_out":
+			return e.BytesOut
+		case "bytes":
+			return e.BytesIn + e.BytesOut
+		}
+		return e.Requests
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if key(entries[i]) == key(entries[j]) {
+			return entries[i].Name < entries[j].Name
+		}
+		return key(entries[i]) > key(entries[j])
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+// RequestStatsHandler serves the top talkers tracked by the requeststats
+// middleware. Query parameters: type (account or container), by (requests,
+// errors, bytes_in, bytes_out or bytes), window in seconds and limit.
+func RequestStatsHandler(writer http.ResponseWriter, request *http.Request) {
+	rsl.Lock()
+	rs := globalRequestStats
+	rsl.Unlock()
+	if rs == nil {
+		srv.SimpleErrorResponse(writer, http.StatusNotFound, "requeststats is not enabled")
+		return
+	}
+	query := request.URL.Query()
+	maxWindow := rs.bucketSeconds * int64(len(rs.buckets))
+	window := maxWindow
+	if w := query.Get("window"); w != "" {
+		var err error
+		if window, err = strconv.ParseInt(w, 10, 64); err != nil || window <= 0 {
+			srv.SimpleErrorResponse(writer, http.StatusBadRequest, "invalid window")
+			return
+		}
+	}
+	limit := 20
+	if l := query.Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 0 {
+			srv.SimpleErrorResponse(writer, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+	switch query.Get("by") {
+	case "", "requests", "errors", "bytes_in", "bytes_out", "bytes":
+	default:
+		srv.SimpleErrorResponse(writer, http.StatusBadRequest, "invalid by")
+		return
+	}
+	containers := false
+	switch query.Get("type") {
+	case "", "account":
+	case "container":
+		containers = true
+	default:
+		srv.SimpleErrorResponse(writer, http.StatusBadRequest, "invalid type")
+		return
+	}
+	data, err := json.Marshal(rs.top(containers, window, query.Get("by"), limit))
+	if err != nil {
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(data)
+}
+
+func NewRequestStats(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
+	if !config.GetBool("enabled", false) {
+		return func(next http.Handler) http.Handler {
+			return next
+		}, nil
+	}
+	bucketSeconds := config.GetInt("bucket_seconds", 60)
+	buckets := config.GetInt("buckets", 60)
+	if bucketSeconds <= 0 || buckets <= 0 {
+		return nil, fmt.Errorf("requeststats bucket_seconds and buckets must be positive")
+	}
+	rs := newRequestStats(bucketSeconds, int(buckets), int(config.GetInt("max_entries", 100000)))
+	rsl.Lock()
+	globalRequestStats = rs
+	rsl.Unlock()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			apiReq, account, container, _ := getPathParts(request)
+			ctx := GetProxyContext(request)
+			if !apiReq || account == "" || (ctx != nil && ctx.Source != "") {
+				next.ServeHTTP(writer, request)
+				return
+			}
+			newWriter := &srv.WebWriter{ResponseWriter: writer, Status: 500}
+			newReader := &srv.CountingReadCloser{ReadCloser: request.Body}
+			request.Body = newReader
+			next.ServeHTTP(newWriter, request)
+			counts := &RequestCounts{Requests: 1, BytesIn: int64(newReader.ByteCount), BytesOut: int64(newWriter.ByteCount)}
+			if newWriter.Status/100 == 5 {
+				counts.Errors = 1
+			}
+			rs.record(account, container, counts)
+		})
+	}, nil
+}
@@ -0,0 +1,109 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+)
+
+func TestRequestStatsTop(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	rs := newRequestStats(10, 3, 100)
+	rs.now = func() time.Time { return now }
+	rs.record("a", "c", &RequestCounts{Requests: 1, BytesIn: 100})
+	rs.record("b", "", &RequestCounts{Requests: 1, BytesOut: 5})
+	now = now.Add(10 * time.Second)
+	rs.record("b", "c", &RequestCounts{Requests: 1, Errors: 1})
+	top := rs.top(false, 30, "requests", 0)
+	require.Equal(t, 2, len(top))
+	require.Equal(t, "b", top[0].Name)
+	require.Equal(t, int64(2), top[0].Requests)
+	top = rs.top(false, 30, "bytes_in", 1)
+	require.Equal(t, 1, len(top))
+	require.Equal(t, "a", top[0].Name)
+	top = rs.top(true, 30, "errors", 0)
+	require.Equal(t, "b/c", top[0].Name)
+	require.Equal(t, "a/c", top[1].Name)
+	// the first bucket is reused once the ring wraps around
+	now = now.Add(20 * time.Second)
+	rs.record("c", "", &RequestCounts{Requests: 1})
+	top = rs.top(false, 30, "requests", 0)
+	require.Equal(t, 2, len(top))
+	require.Equal(t, "b", top[0].Name)
+	require.Equal(t, int64(1), top[0].Requests)
+	top = rs.top(false, 10, "requests", 0)
+	require.Equal(t, 1, len(top))
+	require.Equal(t, "c", top[0].Name)
+}
+
+func TestRequestStatsMaxEntries(t *testing.T) {
+	rs := newRequestStats(60, 1, 1)
+	rs.record("a", "", &RequestCounts{Requests: 1})
+	rs.record("b", "", &RequestCounts{Requests: 1})
+	rs.record("a", "", &RequestCounts{Requests: 1})
+	top := rs.top(false, 60, "", 0)
+	require.Equal(t, 1, len(top))
+	require.Equal(t, int64(2), top[0].Requests)
+}
+
+func TestRequestStatsMiddleware(t *testing.T) {
+	config, err := conf.StringConfig("[filter:requeststats]\nenabled = true")
+	require.Nil(t, err)
+	mid, err := NewRequestStats(config.GetSection("filter:requeststats"), common.NewTestScope())
+	require.Nil(t, err)
+	h := mid(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ioutil.ReadAll(request.Body)
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		writer.Write([]byte("oops"))
+	}))
+	for _, source := range []string{"", "ssc"} {
+		req, _ := http.NewRequest("PUT", "/v1/a/c/o", bytes.NewReader([]byte("hello")))
+		req = req.WithContext(context.WithValue(req.Context(), "proxycontext", &ProxyContext{
+			Logger:                 zap.NewNop(),
+			Source:                 source,
+			ProxyContextMiddleware: &ProxyContextMiddleware{next: h},
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/requeststats?type=container&by=bytes_in", nil)
+	RequestStatsHandler(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var top []*RequestStatsEntry
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &top))
+	require.Equal(t, 1, len(top))
+	require.Equal(t, "a/c", top[0].Name)
+	require.Equal(t, RequestCounts{Requests: 1, Errors: 1, BytesIn: 5, BytesOut: 4}, top[0].RequestCounts)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/requeststats?by=nope", nil)
+	RequestStatsHandler(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
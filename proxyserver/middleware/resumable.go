@@ -0,0 +1,400 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+// Resumable uploads let clients on unreliable networks send an object in
+// chunks and pick up where they left off after a failure. The chunks are
+// stored in the <container>+segments container and the finished object is a
+// static large object made from them.
+//
+//   POST   /v1/a/c/o?resumable              start an upload; an optional
+//                                           Upload-Length sets the total size.
+//                                           Returns Upload-Id.
+//   HEAD   /v1/a/c/o?resumable=<id>         returns the current Upload-Offset
+//   PATCH  /v1/a/c/o?resumable=<id>         append the body at Upload-Offset
+//   PUT    /v1/a/c/o?resumable=<id>         write the object from the chunks
+//   DELETE /v1/a/c/o?resumable=<id>         abandon the upload
+//
+// To enable, add the following to `/etc/hummingbird/proxy-server.conf`
+//
+//   [filter:resumable]
+//   enabled = true
+
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/containerserver"
+	"github.com/uber-go/tally"
+)
+
+const resumableSysmeta = "X-Object-Sysmeta-Resumable-"
+
+type resumableSession struct {
+	offset      int64
+	length      int64
+	chunks      int
+	contentType string
+}
+
+type resumableUpload struct {
+	next           http.Handler
+	createMetric   tally.Counter
+	patchMetric    tally.Counter
+	completeMetric tally.Counter
+}
+
+type resumableRequest struct {
+	ctx       *ProxyContext
+	request   *http.Request
+	account   string
+	container string
+	object    string
+	uploadId  string
+}
+
+func (rr *resumableRequest) segmentsPath() string {
+	return fmt.Sprintf("/v1/%s/%s+segments", common.Urlencode(rr.account), common.Urlencode(rr.container))
+}
+
+func (rr *resumableRequest) sessionName() string {
+	return fmt.Sprintf("%s-%s", rr.uploadId, rr.object)
+}
+
+func (rr *resumableRequest) chunkName(offset int64) string {
+	return fmt.Sprintf("%s/%016d", rr.sessionName(), offset)
+}
+
+func (rr *resumableRequest) subrequest(method, path string, body io.Reader, header http.Header) *captureWriter {
+	w := NewCaptureWriter()
+	subreq, err := rr.ctx.newSubrequest(method, path, body, rr.request, "resumable")
+	if err != nil {
+		w.status = http.StatusInternalServerError
+		return w
+	}
+	for k := range header {
+		subreq.Header.Set(k, header.Get(k))
+	}
+	if cl, err := strconv.ParseInt(subreq.Header.Get("Content-Length"), 10, 64); err == nil {
+		subreq.ContentLength = cl
+	}
+	rr.ctx.serveHTTPSubrequest(w, subreq)
+	if w.status == 0 {
+		// Nothing answered the subrequest; its status would panic
+		// WriteHeader if it were passed on.
+		w.status = http.StatusInternalServerError
+	}
+	return w
+}
+
+func (rr *resumableRequest) loadSession() (*resumableSession, int) {
+	w := rr.subrequest("HEAD", rr.segmentsPath()+"/"+common.Urlencode(rr.sessionName()), http.NoBody, nil)
+	if w.status/100 != 2 {
+		return nil, w.status
+	}
+	s := &resumableSession{length: -1, contentType: w.Header().Get(resumableSysmeta + "Content-Type")}
+	var err error
+	if s.offset, err = strconv.ParseInt(w.Header().Get(resumableSysmeta+"Offset"), 10, 64); err != nil {
+		return nil, http.StatusNotFound
+	}
+	if s.chunks, err = strconv.Atoi(w.Header().Get(resumableSysmeta + "Chunks")); err != nil {
+		return nil, http.StatusNotFound
+	}
+	if l := w.Header().Get(resumableSysmeta + "Length"); l != "" {
+		if s.length, err = strconv.ParseInt(l, 10, 64); err != nil {
+			return nil, http.StatusNotFound
+		}
+	}
+	return s, http.StatusOK
+}
+
+func (rr *resumableRequest) saveSession(s *resumableSession) int {
+	header := http.Header{
+		"Content-Length":                  {"0"},
+		"Content-Type":                    {"application/octet-stream"},
+		resumableSysmeta + "Offset":       {strconv.FormatInt(s.offset, 10)},
+		resumableSysmeta + "Chunks":       {strconv.Itoa(s.chunks)},
+		resumableSysmeta + "Content-Type": {s.contentType},
+	}
+	if s.length >= 0 {
+		header.Set(resumableSysmeta+"Length", strconv.FormatInt(s.length, 10))
+	}
+	return rr.subrequest("PUT", rr.segmentsPath()+"/"+common.Urlencode(rr.sessionName()), http.NoBody, header).status
+}
+
+func (rr *resumableRequest) listChunks() ([]containerserver.ObjectListingRecord, int) {
+	w := rr.subrequest("GET", fmt.Sprintf("%s?format=json&prefix=%s/", rr.segmentsPath(), common.Urlencode(rr.sessionName())), http.NoBody, nil)
+	if w.status/100 != 2 {
+		return nil, w.status
+	}
+	listing := []containerserver.ObjectListingRecord{}
+	if err := json.Unmarshal(w.body, &listing); err != nil {
+		return nil, http.StatusInternalServerError
+	}
+	return listing, http.StatusOK
+}
+
+func (r *resumableUpload) create(writer http.ResponseWriter, rr *resumableRequest) {
+	r.createMetric.Inc(1)
+	s := &resumableSession{length: -1, contentType: rr.request.Header.Get("Content-Type")}
+	if l := rr.request.Header.Get("Upload-Length"); l != "" {
+		var err error
+		if s.length, err = strconv.ParseInt(l, 10, 64); err != nil || s.length < 0 {
+			srv.SimpleErrorResponse(writer, http.StatusBadRequest, "Invalid Upload-Length")
+			return
+		}
+	}
+	if w := rr.subrequest("PUT", rr.segmentsPath(), http.NoBody, http.Header{"Content-Length": {"0"}}); w.status/100 != 2 {
+		srv.StandardResponse(writer, w.status)
+		return
+	}
+	rr.uploadId = fmt.Sprintf("%x", rand.Int63())
+	if status := rr.saveSession(s); status/100 != 2 {
+		srv.StandardResponse(writer, status)
+		return
+	}
+	writer.Header().Set("Upload-Id", rr.uploadId)
+	writer.Header().Set("Upload-Offset", "0")
+	writer.Header().Set("Location", fmt.Sprintf("%s?resumable=%s", rr.request.URL.Path, rr.uploadId))
+	srv.StandardResponse(writer, http.StatusCreated)
+}
+
+func (r *resumableUpload) status(writer http.ResponseWriter, rr *resumableRequest) {
+	s, status := rr.loadSession()
+	if s == nil {
+		srv.StandardResponse(writer, status)
+		return
+	}
+	writer.Header().Set("Upload-Offset", strconv.FormatInt(s.offset, 10))
+	if s.length >= 0 {
+		writer.Header().Set("Upload-Length", strconv.FormatInt(s.length, 10))
+	}
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (r *resumableUpload) patch(writer http.ResponseWriter, rr *resumableRequest) {
+	r.patchMetric.Inc(1)
+	offset, err := strconv.ParseInt(rr.request.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		srv.SimpleErrorResponse(writer, http.StatusBadRequest, "Invalid Upload-Offset")
+		return
+	}
+	if rr.request.ContentLength < 0 {
+		srv.StandardResponse(writer, http.StatusLengthRequired)
+		return
+	}
+	s, status := rr.loadSession()
+	if s == nil {
+		srv.StandardResponse(writer, status)
+		return
+	}
+	writer.Header().Set("Upload-Offset", strconv.FormatInt(s.offset, 10))
+	if offset != s.offset {
+		srv.SimpleErrorResponse(writer, http.StatusConflict, "Upload-Offset does not match the current offset")
+		return
+	}
+	if s.length >= 0 && offset+rr.request.ContentLength > s.length {
+		srv.SimpleErrorResponse(writer, http.StatusRequestEntityTooLarge, "Chunk extends past Upload-Length")
+		return
+	}
+	if rr.request.ContentLength == 0 {
+		writer.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if s.chunks >= maxManifestLen {
+		srv.SimpleErrorResponse(writer, http.StatusRequestEntityTooLarge, fmt.Sprintf("Uploads are limited to %d chunks", maxManifestLen))
+		return
+	}
+	header := http.Header{
+		"Content-Length": {strconv.FormatInt(rr.request.ContentLength, 10)},
+		"Content-Type":   {"application/octet-stream"},
+	}
+	if etag := rr.request.Header.Get("Etag"); etag != "" {
+		header.Set("Etag", etag)
+	}
+	if w := rr.subrequest("PUT", rr.segmentsPath()+"/"+common.Urlencode(rr.chunkName(offset)), rr.request.Body, header); w.status/100 != 2 {
+		srv.StandardResponse(writer, w.status)
+		return
+	}
+	s.offset += rr.request.ContentLength
+	s.chunks++
+	if status := rr.saveSession(s); status/100 != 2 {
+		srv.StandardResponse(writer, status)
+		return
+	}
+	writer.Header().Set("Upload-Offset", strconv.FormatInt(s.offset, 10))
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (r *resumableUpload) complete(writer http.ResponseWriter, rr *resumableRequest) {
+	r.completeMetric.Inc(1)
+	s, status := rr.loadSession()
+	if s == nil {
+		srv.StandardResponse(writer, status)
+		return
+	}
+	if s.length >= 0 && s.offset != s.length {
+		writer.Header().Set("Upload-Offset", strconv.FormatInt(s.offset, 10))
+		srv.SimpleErrorResponse(writer, http.StatusConflict, "Upload is not complete")
+		return
+	}
+	header := http.Header{"Content-Type": {s.contentType}}
+	for k := range rr.request.Header {
+		if strings.HasPrefix(k, "X-Object-Meta-") {
+			header.Set(k, rr.request.Header.Get(k))
+		}
+	}
+	var w *captureWriter
+	objPath := fmt.Sprintf("/v1/%s/%s/%s", common.Urlencode(rr.account), common.Urlencode(rr.container), common.Urlencode(rr.object))
+	if s.chunks == 0 {
+		header.Set("Content-Length", "0")
+		w = rr.subrequest("PUT", objPath, http.NoBody, header)
+	} else {
+		listing, status := rr.listChunks()
+		if listing == nil {
+			srv.StandardResponse(writer, status)
+			return
+		}
+		manifest := []sloPutManifest{}
+		position := int64(0)
+		for _, rec := range listing {
+			if position == s.offset {
+				// anything left over is from a chunk whose upload was not
+				// recorded; it will be overwritten if the client resumes.
+				break
+			}
+			if rec.Name != rr.chunkName(position) {
+				break
+			}
+			manifest = append(manifest, sloPutManifest{
+				Path:      fmt.Sprintf("/%s+segments/%s", common.Urlencode(rr.container), common.Urlencode(rec.Name)),
+				Etag:      rec.ETag,
+				SizeBytes: rec.Size,
+			})
+			position += rec.Size
+		}
+		if position != s.offset {
+			srv.SimpleErrorResponse(writer, http.StatusServiceUnavailable, "Not all chunks are listed yet; please retry")
+			return
+		}
+		body, err := json.Marshal(manifest)
+		if err != nil {
+			srv.StandardResponse(writer, http.StatusInternalServerError)
+			return
+		}
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+		w = rr.subrequest("PUT", objPath+"?multipart-manifest=put", bytes.NewReader(body), header)
+	}
+	if w.status/100 != 2 {
+		srv.StandardResponse(writer, w.status)
+		return
+	}
+	rr.subrequest("DELETE", rr.segmentsPath()+"/"+common.Urlencode(rr.sessionName()), http.NoBody, nil)
+	writer.Header().Set("Etag", w.Header().Get("Etag"))
+	srv.StandardResponse(writer, http.StatusCreated)
+}
+
+func (r *resumableUpload) abort(writer http.ResponseWriter, rr *resumableRequest) {
+	if s, status := rr.loadSession(); s == nil {
+		srv.StandardResponse(writer, status)
+		return
+	}
+	listing, status := rr.listChunks()
+	if listing == nil {
+		srv.StandardResponse(writer, status)
+		return
+	}
+	for _, rec := range listing {
+		if w := rr.subrequest("DELETE", rr.segmentsPath()+"/"+common.Urlencode(rec.Name), http.NoBody, nil); w.status/100 != 2 && w.status != http.StatusNotFound {
+			srv.StandardResponse(writer, w.status)
+			return
+		}
+	}
+	if w := rr.subrequest("DELETE", rr.segmentsPath()+"/"+common.Urlencode(rr.sessionName()), http.NoBody, nil); w.status/100 != 2 && w.status != http.StatusNotFound {
+		srv.StandardResponse(writer, w.status)
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (r *resumableUpload) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	uploadId, ok := request.URL.Query()["resumable"]
+	if !ok {
+		r.next.ServeHTTP(writer, request)
+		return
+	}
+	apiReq, account, container, object := getPathParts(request)
+	ctx := GetProxyContext(request)
+	if !apiReq || object == "" || ctx == nil {
+		r.next.ServeHTTP(writer, request)
+		return
+	}
+	rr := &resumableRequest{ctx: ctx, request: request, account: account, container: container, object: object, uploadId: uploadId[0]}
+	if rr.uploadId == "" {
+		if request.Method != "POST" {
+			srv.StandardResponse(writer, http.StatusMethodNotAllowed)
+			return
+		}
+		r.create(writer, rr)
+		return
+	}
+	if _, err := strconv.ParseUint(rr.uploadId, 16, 64); err != nil {
+		srv.SimpleErrorResponse(writer, http.StatusBadRequest, "Invalid upload id")
+		return
+	}
+	switch request.Method {
+	case "HEAD", "GET":
+		r.status(writer, rr)
+	case "PATCH":
+		r.patch(writer, rr)
+	case "PUT":
+		r.complete(writer, rr)
+	case "DELETE":
+		r.abort(writer, rr)
+	default:
+		srv.StandardResponse(writer, http.StatusMethodNotAllowed)
+	}
+}
+
+func NewResumableUpload(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
+	if !config.GetBool("enabled", false) {
+		return func(next http.Handler) http.Handler {
+			return next
+		}, nil
+	}
+	RegisterInfo("resumable", map[string]interface{}{"max_chunks": maxManifestLen})
+	createMetric := metricsScope.Counter("resumable_create_requests")
+	patchMetric := metricsScope.Counter("resumable_PATCH_requests")
+	completeMetric := metricsScope.Counter("resumable_complete_requests")
+	return func(next http.Handler) http.Handler {
+		return &resumableUpload{
+			next:           next,
+			createMetric:   createMetric,
+			patchMetric:    patchMetric,
+			completeMetric: completeMetric,
+		}
+	}, nil
+}
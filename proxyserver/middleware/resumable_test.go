@@ -0,0 +1,182 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/containerserver"
+)
+
+type resumableTestObject struct {
+	data   []byte
+	header http.Header
+}
+
+// resumableTestStore is a tiny in-memory cluster for the segments container.
+type resumableTestStore struct {
+	objects  map[string]*resumableTestObject
+	manifest []sloPutManifest
+}
+
+func (s *resumableTestStore) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	path := request.URL.Path
+	if request.URL.Query().Get("multipart-manifest") == "put" {
+		json.NewDecoder(request.Body).Decode(&s.manifest)
+		writer.Header().Set("Etag", "\"manifest-etag\"")
+		writer.WriteHeader(http.StatusCreated)
+		return
+	}
+	if strings.Count(path, "/") == 3 {
+		if request.Method == "GET" {
+			listing := []containerserver.ObjectListingRecord{}
+			prefix := path + "/" + request.URL.Query().Get("prefix")
+			for name, obj := range s.objects {
+				if strings.HasPrefix(name, prefix) {
+					listing = append(listing, containerserver.ObjectListingRecord{Name: name[len(path)+1:], Size: int64(len(obj.data))})
+				}
+			}
+			sort.Slice(listing, func(i, j int) bool { return listing[i].Name < listing[j].Name })
+			writer.WriteHeader(http.StatusOK)
+			json.NewEncoder(writer).Encode(listing)
+			return
+		}
+		writer.WriteHeader(http.StatusCreated)
+		return
+	}
+	switch request.Method {
+	case "PUT":
+		data, _ := ioutil.ReadAll(request.Body)
+		s.objects[path] = &resumableTestObject{data: data, header: request.Header}
+		writer.WriteHeader(http.StatusCreated)
+	case "HEAD":
+		obj, ok := s.objects[path]
+		if !ok {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for k := range obj.header {
+			writer.Header().Set(k, obj.header.Get(k))
+		}
+		writer.WriteHeader(http.StatusOK)
+	case "DELETE":
+		delete(s.objects, path)
+		writer.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func resumableTestHandler(t *testing.T) (http.Handler, *resumableTestStore) {
+	config, err := conf.StringConfig("[filter:resumable]\nenabled = true")
+	require.Nil(t, err)
+	mid, err := NewResumableUpload(config.GetSection("filter:resumable"), common.NewTestScope())
+	require.Nil(t, err)
+	store := &resumableTestStore{objects: map[string]*resumableTestObject{}}
+	return mid(store), store
+}
+
+func resumableRequestTo(h http.Handler, method, url string, body []byte, header map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, bytes.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	req = req.WithContext(context.WithValue(req.Context(), "proxycontext", &ProxyContext{
+		Logger:                 zap.NewNop(),
+		ProxyContextMiddleware: &ProxyContextMiddleware{next: h},
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestResumableUpload(t *testing.T) {
+	h, store := resumableTestHandler(t)
+	w := resumableRequestTo(h, "POST", "/v1/a/c/o?resumable", nil, map[string]string{"Upload-Length": "10", "Content-Type": "text/plain"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	uploadId := w.Header().Get("Upload-Id")
+	require.NotEqual(t, "", uploadId)
+	url := "/v1/a/c/o?resumable=" + uploadId
+
+	w = resumableRequestTo(h, "PATCH", url, []byte("01234"), map[string]string{"Upload-Offset": "0"})
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "5", w.Header().Get("Upload-Offset"))
+
+	w = resumableRequestTo(h, "PATCH", url, []byte("01234"), map[string]string{"Upload-Offset": "0"})
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Equal(t, "5", w.Header().Get("Upload-Offset"))
+
+	w = resumableRequestTo(h, "PUT", url, nil, nil)
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	w = resumableRequestTo(h, "PATCH", url, []byte("5678901"), map[string]string{"Upload-Offset": "5"})
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = resumableRequestTo(h, "HEAD", url, nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "5", w.Header().Get("Upload-Offset"))
+	require.Equal(t, "10", w.Header().Get("Upload-Length"))
+
+	w = resumableRequestTo(h, "PATCH", url, []byte("56789"), map[string]string{"Upload-Offset": "5"})
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "10", w.Header().Get("Upload-Offset"))
+
+	w = resumableRequestTo(h, "PUT", url, nil, nil)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, 2, len(store.manifest))
+	require.Equal(t, "/c+segments/"+uploadId+"-o/0000000000000000", store.manifest[0].Path)
+	require.Equal(t, "/c+segments/"+uploadId+"-o/0000000000000005", store.manifest[1].Path)
+	require.Equal(t, int64(5), store.manifest[1].SizeBytes)
+	require.Nil(t, store.objects["/v1/a/c+segments/"+uploadId+"-o"])
+
+	w = resumableRequestTo(h, "HEAD", url, nil, nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestResumableUploadAbort(t *testing.T) {
+	h, store := resumableTestHandler(t)
+	w := resumableRequestTo(h, "POST", "/v1/a/c/o?resumable", nil, nil)
+	require.Equal(t, http.StatusCreated, w.Code)
+	url := "/v1/a/c/o?resumable=" + w.Header().Get("Upload-Id")
+	for offset := 0; offset < 9; offset += 3 {
+		w = resumableRequestTo(h, "PATCH", url, []byte("abc"), map[string]string{"Upload-Offset": strconv.Itoa(offset)})
+		require.Equal(t, http.StatusNoContent, w.Code)
+	}
+	require.Equal(t, 4, len(store.objects))
+	w = resumableRequestTo(h, "DELETE", url, nil, nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, 0, len(store.objects))
+}
+
+func TestResumableUploadBadRequests(t *testing.T) {
+	h, _ := resumableTestHandler(t)
+	require.Equal(t, http.StatusMethodNotAllowed, resumableRequestTo(h, "PUT", "/v1/a/c/o?resumable", nil, nil).Code)
+	require.Equal(t, http.StatusBadRequest, resumableRequestTo(h, "HEAD", "/v1/a/c/o?resumable=xyz", nil, nil).Code)
+	require.Equal(t, http.StatusNotFound, resumableRequestTo(h, "HEAD", "/v1/a/c/o?resumable=abc", nil, nil).Code)
+	require.Equal(t, http.StatusBadRequest, resumableRequestTo(h, "POST", "/v1/a/c/o?resumable", nil, map[string]string{"Upload-Length": "-1"}).Code)
+}
@@ -0,0 +1,296 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/uber-go/tally"
+)
+
+const (
+	retentionEnabledHeader  = "X-Container-Retention-Enabled"
+	retentionPeriodHeader   = "X-Container-Retention-Period"
+	retainUntilHeader       = "X-Object-Retain-Until"
+	legalHoldHeader         = "X-Object-Legal-Hold"
+	retentionEnabledSysmeta = "X-Container-Sysmeta-Retention-Enabled"
+	retentionPeriodSysmeta  = "X-Container-Sysmeta-Retention-Period"
+	retainUntilSysmeta      = "X-Object-Transient-Sysmeta-Retain-Until"
+	legalHoldSysmeta        = "X-Object-Transient-Sysmeta-Legal-Hold"
+)
+
+// retention keeps objects in containers with X-Container-Retention-Enabled
+// from being overwritten or deleted until their X-Object-Retain-Until has
+// passed, or while X-Object-Legal-Hold is set on them.
+//
+// Objects put into a container with an X-Container-Retention-Period are
+// retained for that many seconds unless they give their own retain-until.
+// Once on, retention can't be turned off, and neither a container's period
+// nor an object's retain-until can be shortened, except for the period by a
+// reseller admin.
+type retention struct {
+	next         http.Handler
+	maxPeriod    int64
+	rejectMetric tally.Counter
+}
+
+// objectRetention is an object's retain-until, as a unix time, and legal hold.
+type objectRetention struct {
+	until int64
+	hold  bool
+}
+
+func (r objectRetention) locked(now time.Time) bool {
+	return r.hold || now.Unix() < r.until
+}
+
+func parseRetainUntil(value string) (int64, error) {
+	t, err := common.ParseDate(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s: %q", retainUntilHeader, value)
+	}
+	return t.Unix(), nil
+}
+
+func retentionFromHeaders(header http.Header) objectRetention {
+	until, _ := strconv.ParseInt(header.Get(retainUntilSysmeta), 10, 64)
+	return objectRetention{until: until, hold: common.LooksTrue(header.Get(legalHoldSysmeta))}
+}
+
+// exposeRetention copies the retention sysmeta in a response's headers to the
+// headers clients see.
+func exposeRetention(header http.Header) {
+	for sysmeta, user := range map[string]string{
+		retentionEnabledSysmeta: retentionEnabledHeader,
+		retentionPeriodSysmeta:  retentionPeriodHeader,
+		retainUntilSysmeta:      retainUntilHeader,
+		legalHoldSysmeta:        legalHoldHeader,
+	} {
+		if v := header.Get(sysmeta); v != "" {
+			header.Set(user, v)
+		}
+	}
+}
+
+func (rt *retention) reject(writer http.ResponseWriter, msg string) {
+	rt.rejectMetric.Inc(1)
+	srv.SimpleErrorResponse(writer, http.StatusForbidden, msg)
+}
+
+func (rt *retention) handleContainer(writer http.ResponseWriter, request *http.Request, account, container string) {
+	ctx := GetProxyContext(request)
+	_, setEnabled := request.Header[retentionEnabledHeader]
+	_, setPeriod := request.Header[retentionPeriodHeader]
+	if (request.Method != "PUT" && request.Method != "POST") || (!setEnabled && !setPeriod) {
+		rt.next.ServeHTTP(writer, request)
+		return
+	}
+	var enabled bool
+	var period, currentPeriod int64
+	if ci, err := ctx.C.GetContainerInfo(request.Context(), account, container); err == nil {
+		enabled = common.LooksTrue(ci.SysMetadata["Retention-Enabled"])
+		currentPeriod, _ = strconv.ParseInt(ci.SysMetadata["Retention-Period"], 10, 64)
+	}
+	if setEnabled {
+		if enabled && !common.LooksTrue(request.Header.Get(retentionEnabledHeader)) {
+			rt.reject(writer, "Retention can't be turned off once it's enabled.")
+			return
+		}
+		enabled = common.LooksTrue(request.Header.Get(retentionEnabledHeader))
+	}
+	period = currentPeriod
+	if setPeriod {
+		var err error
+		if period, err = strconv.ParseInt(request.Header.Get(retentionPeriodHeader), 10, 64); err != nil || period < 0 {
+			srv.SimpleErrorResponse(writer, http.StatusBadRequest, fmt.Sprintf("Invalid %s", retentionPeriodHeader))
+			return
+		}
+		if rt.maxPeriod > 0 && period > rt.maxPeriod {
+			srv.SimpleErrorResponse(writer, http.StatusBadRequest, fmt.Sprintf("%s can be at most %d", retentionPeriodHeader, rt.maxPeriod))
+			return
+		}
+		if enabled && period < currentPeriod && !ctx.ResellerRequest {
+			rt.reject(writer, "The retention period can't be shortened.")
+			return
+		}
+		if period > 0 {
+			enabled = true
+		}
+	}
+	request.Header.Del(retentionEnabledHeader)
+	request.Header.Del(retentionPeriodHeader)
+	if enabled {
+		request.Header.Set(retentionEnabledSysmeta, "true")
+		request.Header.Set(retentionPeriodSysmeta, strconv.FormatInt(period, 10))
+	}
+	rt.next.ServeHTTP(writer, request)
+}
+
+// currentRetention returns the retention of the object as it is now, and
+// whether there is one.
+func (rt *retention) currentRetention(request *http.Request) (objectRetention, bool, error) {
+	ctx := GetProxyContext(request)
+	headReq, err := ctx.newSubrequest("HEAD", request.URL.Path, http.NoBody, request, "retention")
+	if err != nil {
+		return objectRetention{}, false, err
+	}
+	headReq.Header.Set("X-Newest", "true")
+	cap := NewCaptureWriter()
+	ctx.serveHTTPSubrequest(cap, headReq)
+	if cap.status == http.StatusNotFound {
+		return objectRetention{}, false, nil
+	}
+	if cap.status/100 != 2 {
+		return objectRetention{}, false, fmt.Errorf("HEAD for retention returned %d", cap.status)
+	}
+	return retentionFromHeaders(cap.Header()), true, nil
+}
+
+func (rt *retention) handleObject(writer http.ResponseWriter, request *http.Request, period int64) {
+	ctx := GetProxyContext(request)
+	var until int64
+	var err error
+	_, setUntil := request.Header[retainUntilHeader]
+	if setUntil {
+		if until, err = parseRetainUntil(request.Header.Get(retainUntilHeader)); err != nil {
+			srv.SimpleErrorResponse(writer, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	holdValue, setHold := request.Header[legalHoldHeader]
+	request.Header.Del(retainUntilHeader)
+	request.Header.Del(legalHoldHeader)
+	current, exists, err := rt.currentRetention(request)
+	if err != nil {
+		srv.SimpleErrorResponse(writer, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	now := time.Now()
+	if exists && request.Method != "POST" && current.locked(now) {
+		if current.hold {
+			rt.reject(writer, "The object is under legal hold.")
+		} else {
+			rt.reject(writer, fmt.Sprintf("The object is retained until %s.", time.Unix(current.until, 0).UTC().Format(http.TimeFormat)))
+		}
+		return
+	}
+	// An append keeps the object's metadata, its retention included.
+	if request.Method == "DELETE" || request.Method == "APPEND" {
+		rt.next.ServeHTTP(writer, request)
+		return
+	}
+	next := objectRetention{}
+	if request.Method == "POST" {
+		if !exists {
+			rt.next.ServeHTTP(writer, request)
+			return
+		}
+		next = current
+		if setUntil {
+			if until < current.until && !ctx.ResellerRequest {
+				rt.reject(writer, "The object's retention can't be shortened.")
+				return
+			}
+			next.until = until
+		}
+	} else if setUntil {
+		next.until = until
+	} else if period > 0 {
+		next.until = now.Unix() + period
+	}
+	if setHold {
+		next.hold = common.LooksTrue(holdValue[0])
+	}
+	if da := request.Header.Get("X-Delete-At"); da != "" {
+		if deleteAt, err := strconv.ParseInt(da, 10, 64); err == nil && (next.hold || deleteAt < next.until) {
+			srv.SimpleErrorResponse(writer, http.StatusBadRequest, "X-Delete-At is before the object's retention ends.")
+			return
+		}
+	}
+	if da := request.Header.Get("X-Delete-After"); da != "" {
+		if deleteAfter, err := strconv.ParseInt(da, 10, 64); err == nil && (next.hold || now.Unix()+deleteAfter < next.until) {
+			srv.SimpleErrorResponse(writer, http.StatusBadRequest, "X-Delete-After is before the object's retention ends.")
+			return
+		}
+	}
+	// Copies bring their source's retention along; it's replaced here.
+	request.Header.Del(retainUntilSysmeta)
+	request.Header.Del(legalHoldSysmeta)
+	if next.until > 0 {
+		request.Header.Set(retainUntilSysmeta, strconv.FormatInt(next.until, 10))
+	}
+	if next.hold {
+		request.Header.Set(legalHoldSysmeta, "true")
+	}
+	rt.next.ServeHTTP(writer, request)
+}
+
+func (rt *retention) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	apiReq, account, container, object := getPathParts(request)
+	ctx := GetProxyContext(request)
+	if !apiReq || container == "" || ctx == nil {
+		rt.next.ServeHTTP(writer, request)
+		return
+	}
+	if request.Method == "GET" || request.Method == "HEAD" {
+		rt.next.ServeHTTP(srv.NewCustomWriter(writer, func(w http.ResponseWriter, status int) int {
+			if status/100 == 2 || status == http.StatusNotModified {
+				exposeRetention(w.Header())
+			}
+			return status
+		}), request)
+		return
+	}
+	if object == "" {
+		rt.handleContainer(writer, request, account, container)
+		return
+	}
+	if request.Method != "PUT" && request.Method != "POST" && request.Method != "DELETE" && request.Method != "APPEND" {
+		rt.next.ServeHTTP(writer, request)
+		return
+	}
+	ci, err := ctx.C.GetContainerInfo(request.Context(), account, container)
+	if err != nil || !common.LooksTrue(ci.SysMetadata["Retention-Enabled"]) {
+		rt.next.ServeHTTP(writer, request)
+		return
+	}
+	period, _ := strconv.ParseInt(ci.SysMetadata["Retention-Period"], 10, 64)
+	rt.handleObject(writer, request, period)
+}
+
+func NewRetention(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
+	if !config.GetBool("enabled", false) {
+		return func(next http.Handler) http.Handler {
+			return next
+		}, nil
+	}
+	maxPeriod := config.GetInt("max_retention_period", 0)
+	rejectMetric := metricsScope.Counter("retention_rejected_requests")
+	RegisterInfo("retention", map[string]interface{}{"max_retention_period": maxPeriod})
+	return func(next http.Handler) http.Handler {
+		return &retention{
+			next:         next,
+			maxPeriod:    maxPeriod,
+			rejectMetric: rejectMetric,
+		}
+	}, nil
+}
@@ -0,0 +1,188 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/client"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/common/test"
+	"go.uber.org/zap"
+)
+
+// fakeRetentionBackend keeps the headers of the objects put to it, so the
+// retention of earlier requests applies to later ones.
+type fakeRetentionBackend struct {
+	objects map[string]http.Header
+	last    *http.Request
+}
+
+func (b *fakeRetentionBackend) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case "HEAD", "GET":
+		h, ok := b.objects[request.URL.Path]
+		if !ok {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for k, v := range h {
+			writer.Header()[k] = v
+		}
+		writer.WriteHeader(http.StatusOK)
+	case "PUT", "POST":
+		b.last = request
+		b.objects[request.URL.Path] = request.Header
+		writer.WriteHeader(http.StatusCreated)
+	case "APPEND":
+		b.last = request
+		writer.WriteHeader(http.StatusCreated)
+	case "DELETE":
+		b.last = request
+		delete(b.objects, request.URL.Path)
+		writer.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newTestRetention(t *testing.T, settings string) (http.Handler, *fakeRetentionBackend) {
+	config, err := conf.StringConfig("[filter:retention]\nenabled = true\n" + settings)
+	require.Nil(t, err)
+	mid, err := NewRetention(config.GetSection("filter:retention"), common.NewTestScope())
+	require.Nil(t, err)
+	backend := &fakeRetentionBackend{objects: map[string]http.Header{}}
+	return mid(backend), backend
+}
+
+func retentionTestRequest(t *testing.T, h, backend http.Handler, method, path string, headers map[string]string, reseller bool) *httptest.ResponseRecorder {
+	f, err := client.NewProxyClient(staticPolicyList, srv.NewTestConfigLoader(&test.FakeRing{}), nil, "", "", "", "", "", conf.Config{})
+	require.Nil(t, err)
+	ctx := &ProxyContext{
+		ProxyContextMiddleware: &ProxyContextMiddleware{next: backend},
+		Logger:                 zap.NewNop(),
+		ResellerRequest:        reseller,
+		C: f.NewRequestClient(nil, map[string]*client.ContainerInfo{
+			"container/a/c":     {SysMetadata: map[string]string{"Retention-Enabled": "true", "Retention-Period": "3600"}},
+			"container/a/plain": {SysMetadata: map[string]string{}},
+		}, zap.NewNop()),
+	}
+	req, err := http.NewRequest(method, path, nil)
+	require.Nil(t, err)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req = req.WithContext(context.WithValue(req.Context(), "proxycontext", ctx))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestRetentionPeriod(t *testing.T) {
+	h, backend := newTestRetention(t, "")
+	before := time.Now().Unix()
+	w := retentionTestRequest(t, h, backend, "PUT", "/v1/a/c/o", nil, false)
+	require.Equal(t, http.StatusCreated, w.Code)
+	until, err := strconv.ParseInt(backend.last.Header.Get(retainUntilSysmeta), 10, 64)
+	require.Nil(t, err)
+	require.True(t, until >= before+3600)
+
+	w = retentionTestRequest(t, h, backend, "PUT", "/v1/a/c/o", nil, false)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	w = retentionTestRequest(t, h, backend, "APPEND", "/v1/a/c/o", nil, false)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	w = retentionTestRequest(t, h, backend, "DELETE", "/v1/a/c/o", nil, true)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	w = retentionTestRequest(t, h, backend, "HEAD", "/v1/a/c/o", nil, false)
+	require.Equal(t, strconv.FormatInt(until, 10), w.Header().Get(retainUntilHeader))
+
+	// Objects in containers without retention come and go as they please.
+	retentionTestRequest(t, h, backend, "PUT", "/v1/a/plain/o", nil, false)
+	require.Equal(t, "", backend.last.Header.Get(retainUntilSysmeta))
+	w = retentionTestRequest(t, h, backend, "DELETE", "/v1/a/plain/o", nil, false)
+	require.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestRetentionRetainUntil(t *testing.T) {
+	h, backend := newTestRetention(t, "")
+	past := strconv.FormatInt(time.Now().Unix()-10, 10)
+	w := retentionTestRequest(t, h, backend, "PUT", "/v1/a/c/o", map[string]string{retainUntilHeader: past}, false)
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = retentionTestRequest(t, h, backend, "APPEND", "/v1/a/c/o", map[string]string{retainUntilHeader: "0"}, false)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "", backend.last.Header.Get(retainUntilSysmeta))
+	w = retentionTestRequest(t, h, backend, "DELETE", "/v1/a/c/o", nil, false)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w = retentionTestRequest(t, h, backend, "PUT", "/v1/a/c/o", map[string]string{retainUntilHeader: "tomorrow"}, false)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	future := time.Now().Unix() + 100
+	w = retentionTestRequest(t, h, backend, "PUT", "/v1/a/c/o", map[string]string{retainUntilHeader: strconv.FormatInt(future, 10)}, false)
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = retentionTestRequest(t, h, backend, "POST", "/v1/a/c/o", map[string]string{retainUntilHeader: strconv.FormatInt(future-50, 10)}, false)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	w = retentionTestRequest(t, h, backend, "POST", "/v1/a/c/o", map[string]string{"X-Object-Meta-Color": "blue"}, false)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, strconv.FormatInt(future, 10), backend.last.Header.Get(retainUntilSysmeta))
+	w = retentionTestRequest(t, h, backend, "POST", "/v1/a/c/o", map[string]string{"X-Delete-At": strconv.FormatInt(future-50, 10)}, false)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRetentionLegalHold(t *testing.T) {
+	h, backend := newTestRetention(t, "")
+	past := strconv.FormatInt(time.Now().Unix()-10, 10)
+	retentionTestRequest(t, h, backend, "PUT", "/v1/a/c/o", map[string]string{retainUntilHeader: past, legalHoldHeader: "true"}, false)
+	require.Equal(t, "true", backend.last.Header.Get(legalHoldSysmeta))
+	w := retentionTestRequest(t, h, backend, "DELETE", "/v1/a/c/o", nil, false)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	w = retentionTestRequest(t, h, backend, "APPEND", "/v1/a/c/o", nil, false)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	w = retentionTestRequest(t, h, backend, "GET", "/v1/a/c/o", nil, false)
+	require.Equal(t, "true", w.Header().Get(legalHoldHeader))
+
+	w = retentionTestRequest(t, h, backend, "POST", "/v1/a/c/o", map[string]string{legalHoldHeader: "false"}, false)
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = retentionTestRequest(t, h, backend, "DELETE", "/v1/a/c/o", nil, false)
+	require.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestRetentionContainer(t *testing.T) {
+	h, backend := newTestRetention(t, "max_retention_period = 86400")
+	w := retentionTestRequest(t, h, backend, "POST", "/v1/a/plain", map[string]string{retentionPeriodHeader: "60"}, false)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "true", backend.last.Header.Get(retentionEnabledSysmeta))
+	require.Equal(t, "60", backend.last.Header.Get(retentionPeriodSysmeta))
+	require.Equal(t, "", backend.last.Header.Get(retentionPeriodHeader))
+
+	w = retentionTestRequest(t, h, backend, "POST", "/v1/a/c", map[string]string{retentionEnabledHeader: "false"}, false)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	w = retentionTestRequest(t, h, backend, "POST", "/v1/a/c", map[string]string{retentionPeriodHeader: "60"}, false)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	w = retentionTestRequest(t, h, backend, "POST", "/v1/a/c", map[string]string{retentionPeriodHeader: "60"}, true)
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = retentionTestRequest(t, h, backend, "POST", "/v1/a/c", map[string]string{retentionPeriodHeader: "86401"}, true)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = retentionTestRequest(t, h, backend, "POST", "/v1/a/c", map[string]string{retentionPeriodHeader: "-1"}, false)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,222 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/srv"
+)
+
+// Bucket ACLs aren't stored separately from the container's Swift ACLs; they
+// are translated each way as they're read and written, so a change through
+// either API shows in the other. Reads go through the container info cache.
+//
+//   public READ        <-> X-Container-Read .r:*,.rlistings
+//   user READ          <-> X-Container-Read <user>
+//   user WRITE         <-> X-Container-Write <user>
+//
+// The owner always has FULL_CONTROL. Referrer restrictions, which S3 can't
+// express, aren't shown and are kept when an S3 client sets the ACL.
+
+const (
+	s3XmlnsXsi      = "http://www.w3.org/2001/XMLSchema-instance"
+	s3AllUsersURI   = "http://acs.amazonaws.com/groups/global/AllUsers"
+	s3PublicReadACL = ".r:*,.rlistings"
+)
+
+type s3Grantee struct {
+	XmlnsXsi    string `xml:"xmlns:xsi,attr,omitempty"`
+	Type        string `xml:"xsi:type,attr,omitempty"`
+	ID          string `xml:"ID,omitempty"`
+	DisplayName string `xml:"DisplayName,omitempty"`
+	URI         string `xml:"URI,omitempty"`
+}
+
+type s3Grant struct {
+	Grantee    s3Grantee `xml:"Grantee"`
+	Permission string    `xml:"Permission"`
+}
+
+type s3AccessControlPolicy struct {
+	XMLName xml.Name  `xml:"AccessControlPolicy"`
+	Xmlns   string    `xml:"xmlns,attr,omitempty"`
+	Owner   s3Owner   `xml:"Owner"`
+	Grants  []s3Grant `xml:"AccessControlList>Grant"`
+}
+
+func s3UserGrant(id, permission string) s3Grant {
+	return s3Grant{Grantee: s3Grantee{XmlnsXsi: s3XmlnsXsi, Type: "CanonicalUser", ID: id, DisplayName: id}, Permission: permission}
+}
+
+// s3ACLFromSwift translates a container's Swift ACLs into an S3 bucket ACL.
+func s3ACLFromSwift(owner, readACL, writeACL string) *s3AccessControlPolicy {
+	policy := &s3AccessControlPolicy{Xmlns: s3Xmlns, Owner: s3Owner{ID: owner, DisplayName: owner}}
+	policy.Grants = append(policy.Grants, s3UserGrant(owner, "FULL_CONTROL"))
+	referrers, groups := ParseACL(readACL)
+	if common.StringInSlice("*", referrers) && common.StringInSlice(".rlistings", groups) {
+		policy.Grants = append(policy.Grants, s3Grant{Grantee: s3Grantee{XmlnsXsi: s3XmlnsXsi, Type: "Group", URI: s3AllUsersURI}, Permission: "READ"})
+	}
+	for _, group := range groups {
+		if !strings.HasPrefix(group, ".") {
+			policy.Grants = append(policy.Grants, s3UserGrant(group, "READ"))
+		}
+	}
+	_, groups = ParseACL(writeACL)
+	for _, group := range groups {
+		if !strings.HasPrefix(group, ".") {
+			policy.Grants = append(policy.Grants, s3UserGrant(group, "WRITE"))
+		}
+	}
+	return policy
+}
+
+// s3ACLToSwift translates an S3 bucket ACL into Swift read and write ACLs,
+// keeping any referrer restrictions from the current read ACL. It returns
+// false if the ACL grants something Swift ACLs can't express.
+func s3ACLToSwift(owner, currentReadACL string, policy *s3AccessControlPolicy) (string, string, bool) {
+	var read, write []string
+	referrers, _ := ParseACL(currentReadACL)
+	for _, referrer := range referrers {
+		if referrer != "*" {
+			read = append(read, ".r:"+referrer)
+		}
+	}
+	for _, grant := range policy.Grants {
+		switch {
+		case grant.Grantee.URI == s3AllUsersURI && grant.Permission == "READ":
+			if !common.StringInSlice(".r:*", read) {
+				read = append(read, strings.Split(s3PublicReadACL, ",")...)
+			}
+		case grant.Grantee.URI != "" || grant.Grantee.ID == "" || strings.HasPrefix(grant.Grantee.ID, ".") || strings.Contains(grant.Grantee.ID, ","):
+			return "", "", false
+		case grant.Grantee.ID == owner:
+		case grant.Permission == "READ":
+			read = append(read, grant.Grantee.ID)
+		case grant.Permission == "WRITE":
+			write = append(write, grant.Grantee.ID)
+		default:
+			return "", "", false
+		}
+	}
+	return strings.Join(read, ","), strings.Join(write, ","), true
+}
+
+// s3CannedACL returns the S3 bucket ACL named by an X-Amz-Acl header, or
+// false for the canned ACLs Swift ACLs can't express.
+func s3CannedACL(owner, canned string) (*s3AccessControlPolicy, bool) {
+	switch canned {
+	case "", "private":
+		return s3ACLFromSwift(owner, "", ""), true
+	case "public-read":
+		return s3ACLFromSwift(owner, s3PublicReadACL, ""), true
+	}
+	return nil, false
+}
+
+// handleBucketACL serves GetBucketAcl and PutBucketAcl.
+func (s *s3ApiHandler) handleBucketACL(writer http.ResponseWriter, request *http.Request) {
+	ctx := GetProxyContext(request)
+	switch request.Method {
+	case "GET", "PUT":
+	default:
+		srv.StandardResponse(writer, http.StatusMethodNotAllowed)
+		return
+	}
+	ci, err := ctx.C.GetContainerInfo(request.Context(), "AUTH_"+s.account, s.container)
+	if err != nil {
+		// Let the container server say why.
+		cap, err := s.headSubrequest(request)
+		if err != nil {
+			srv.StandardResponse(writer, http.StatusInternalServerError)
+			return
+		}
+		if cap.status == 404 {
+			NoSuchBucketResponse(writer, request)
+			return
+		}
+		srv.StandardResponse(writer, cap.status)
+		return
+	}
+	if request.Method == "GET" {
+		// Only the owner may read the ACL; PUT gets the same check from its
+		// POST subrequest.
+		if ctx.Authorize != nil {
+			authReq, err := http.NewRequest("POST", s.path, nil)
+			if err != nil {
+				srv.StandardResponse(writer, http.StatusInternalServerError)
+				return
+			}
+			acl := ctx.ACL
+			ctx.ACL = ""
+			ok, status := ctx.Authorize(authReq.WithContext(request.Context()))
+			ctx.ACL = acl
+			if !ok {
+				srv.StandardResponse(writer, status)
+				return
+			}
+		}
+		writeS3XML(writer, s3ACLFromSwift(s.account, ci.ReadACL, ci.WriteACL))
+		return
+	}
+	var policy *s3AccessControlPolicy
+	if canned := request.Header.Get("X-Amz-Acl"); canned != "" {
+		var ok bool
+		if policy, ok = s3CannedACL(s.account, canned); !ok {
+			srv.StandardResponse(writer, http.StatusNotImplemented)
+			return
+		}
+	} else {
+		body, err := ioutil.ReadAll(io.LimitReader(request.Body, s3MultipartCompleteBodyLimit))
+		if err != nil {
+			srv.StandardResponse(writer, http.StatusBadRequest)
+			return
+		}
+		policy = &s3AccessControlPolicy{}
+		if err := xml.Unmarshal(body, policy); err != nil {
+			srv.StandardResponse(writer, http.StatusBadRequest)
+			return
+		}
+	}
+	read, write, ok := s3ACLToSwift(s.account, ci.ReadACL, policy)
+	if !ok {
+		srv.StandardResponse(writer, http.StatusNotImplemented)
+		return
+	}
+	newReq, err := ctx.newSubrequest("POST", s.path, http.NoBody, request, "s3api")
+	if err != nil {
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	newReq.Header.Set("X-Container-Read", read)
+	newReq.Header.Set("X-Container-Write", write)
+	cap := NewCaptureWriter()
+	ctx.serveHTTPSubrequest(cap, newReq)
+	if cap.status == 404 {
+		NoSuchBucketResponse(writer, request)
+		return
+	}
+	if cap.status/100 != 2 {
+		srv.StandardResponse(writer, cap.status)
+		return
+	}
+	writer.WriteHeader(200)
+}
@@ -0,0 +1,132 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/client"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/common/test"
+	"go.uber.org/zap"
+)
+
+func TestS3ACLTranslation(t *testing.T) {
+	policy := s3ACLFromSwift("test", ".r:*,.rlistings,other", "other:writer")
+	require.Equal(t, 4, len(policy.Grants))
+	require.Equal(t, "FULL_CONTROL", policy.Grants[0].Permission)
+	require.Equal(t, s3AllUsersURI, policy.Grants[1].Grantee.URI)
+	require.Equal(t, s3Grant{Grantee: s3Grantee{XmlnsXsi: s3XmlnsXsi, Type: "CanonicalUser", ID: "other", DisplayName: "other"}, Permission: "READ"}, policy.Grants[2])
+	require.Equal(t, "other:writer", policy.Grants[3].Grantee.ID)
+	require.Equal(t, "WRITE", policy.Grants[3].Permission)
+
+	read, write, ok := s3ACLToSwift("test", "", policy)
+	require.True(t, ok)
+	require.Equal(t, ".r:*,.rlistings,other", read)
+	require.Equal(t, "other:writer", write)
+
+	// Objects readable by everyone but without listings don't make a public
+	// bucket, and referrer restrictions survive a round trip.
+	policy = s3ACLFromSwift("test", ".r:*,.r:-bad.example.com", "")
+	require.Equal(t, 1, len(policy.Grants))
+	read, write, ok = s3ACLToSwift("test", ".r:*,.r:-bad.example.com", policy)
+	require.True(t, ok)
+	require.Equal(t, ".r:-bad.example.com", read)
+	require.Equal(t, "", write)
+
+	_, _, ok = s3ACLToSwift("test", "", &s3AccessControlPolicy{Grants: []s3Grant{s3UserGrant("other", "FULL_CONTROL")}})
+	require.False(t, ok)
+	_, _, ok = s3ACLToSwift("test", "", &s3AccessControlPolicy{Grants: []s3Grant{s3UserGrant(".r:*", "READ")}})
+	require.False(t, ok)
+	_, _, ok = s3ACLToSwift("test", "", &s3AccessControlPolicy{Grants: []s3Grant{{Grantee: s3Grantee{URI: "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"}, Permission: "READ"}}})
+	require.False(t, ok)
+}
+
+func TestS3BucketACL(t *testing.T) {
+	var posted http.Header
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			posted = r.Header
+		}
+		w.WriteHeader(204)
+	})
+	f, err := client.NewProxyClient(staticPolicyList, srv.NewTestConfigLoader(&test.FakeRing{}), nil, "", "", "", "", "", conf.Config{})
+	require.Nil(t, err)
+	containers := map[string]*client.ContainerInfo{"container/AUTH_test/bucket": {ReadACL: "other"}}
+	ctx := &ProxyContext{
+		ProxyContextMiddleware: &ProxyContextMiddleware{next: next},
+		Logger:                 zap.NewNop(),
+		S3Auth:                 &S3AuthInfo{Account: "test"},
+		C:                      f.NewRequestClient(nil, containers, zap.NewNop()),
+	}
+	s := &s3ApiHandler{ctx: ctx, account: "test", container: "bucket", path: "/v1/AUTH_test/bucket"}
+	do := func(method, canned, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/bucket?acl", strings.NewReader(body))
+		if canned != "" {
+			r.Header.Set("X-Amz-Acl", canned)
+		}
+		r = r.WithContext(context.WithValue(r.Context(), "proxycontext", ctx))
+		w := httptest.NewRecorder()
+		s.handleContainerRequest(newS3ResponseWriterWrapper(w, r), r)
+		return w
+	}
+
+	w := do("GET", "", "")
+	require.Equal(t, 200, w.Code)
+	var policy s3AccessControlPolicy
+	require.Nil(t, xml.Unmarshal(w.Body.Bytes(), &policy))
+	require.Equal(t, "test", policy.Owner.ID)
+	require.Equal(t, 2, len(policy.Grants))
+	require.Equal(t, "other", policy.Grants[1].Grantee.ID)
+	require.Equal(t, "READ", policy.Grants[1].Permission)
+
+	// Someone the bucket's ACL lets read it still can't read the ACL.
+	ctx.ACL = "other"
+	var authPath, authACL string
+	ctx.Authorize = func(r *http.Request) (bool, int) {
+		authPath, authACL = r.URL.Path, ctx.ACL
+		return authACL != "", http.StatusForbidden
+	}
+	require.Equal(t, 403, do("GET", "", "").Code)
+	require.Equal(t, "/v1/AUTH_test/bucket", authPath)
+	require.Equal(t, "", authACL)
+	require.Equal(t, "other", ctx.ACL)
+	ctx.Authorize = nil
+
+	require.Equal(t, 200, do("PUT", "public-read", "").Code)
+	require.Equal(t, ".r:*,.rlistings", posted.Get("X-Container-Read"))
+	require.Equal(t, "", posted.Get("X-Container-Write"))
+
+	posted = nil
+	require.Equal(t, 501, do("PUT", "authenticated-read", "").Code)
+	require.Nil(t, posted)
+	require.Equal(t, 400, do("PUT", "", "<AccessControlPolicy>").Code)
+
+	require.Equal(t, 200, do("PUT", "", `<AccessControlPolicy xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`+
+		`<Owner><ID>test</ID></Owner><AccessControlList>`+
+		`<Grant><Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="CanonicalUser"><ID>test</ID></Grantee><Permission>FULL_CONTROL</Permission></Grant>`+
+		`<Grant><Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="CanonicalUser"><ID>other</ID></Grantee><Permission>WRITE</Permission></Grant>`+
+		`</AccessControlList></AccessControlPolicy>`).Code)
+	require.Equal(t, "", posted.Get("X-Container-Read"))
+	require.Equal(t, "other", posted.Get("X-Container-Write"))
+}
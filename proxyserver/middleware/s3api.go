@@ -41,7 +41,9 @@ package middleware
 
 import (
 	"bytes"
+	"crypto/md5"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
@@ -49,10 +51,13 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"unicode"
 
 	"github.com/troubling/hummingbird/accountserver"
@@ -61,32 +66,48 @@ import (
 	"github.com/troubling/hummingbird/common/srv"
 	"github.com/troubling/hummingbird/containerserver"
 	"github.com/uber-go/tally"
+	"go.uber.org/zap"
 )
 
 const (
 	s3Xmlns                      = "http://s3.amazonaws.com/doc/2006-03-01"
 	s3MultipartCompleteBodyLimit = 65536
 	s3MultipartMaxParts          = 1000
+	s3MaxBuckets                 = 10000
+	s3DeleteMaxKeys              = 1000
+	s3DeleteBodyLimit            = 2 * 1024 * 1024
+	s3DeleteConcurrency          = 10
+	s3ChecksumHeaderPrefix       = "X-Amz-Checksum-"
 )
 
+var s3ChecksumAlgorithms = []string{"Crc32", "Crc32c", "Sha1", "Sha256"}
+
 type s3Response struct {
 	Code    string
 	Message string
 }
 
 var s3Responses = map[int]s3Response{
+	400:   {"InvalidArgument", "Invalid Argument"},
 	403:   {"AccessDenied", "Access Denied"},
 	404:   {"NotFound", "Not Found"}, // TODO: S3 responds with differetn 404 messages
 	405:   {"MethodNotAllowed", "The specified method is not allowed against this resource."},
 	411:   {"MissingContentLength", "You must provide the Content-Length HTTP header."},
+	412:   {"PreconditionFailed", "At least one of the preconditions you specified did not hold."},
 	500:   {"InternalError", "We encountered an internal error. Please try again."},
 	501:   {"NotImplemented", "A header you provided implies functionality that is not implemented."},
 	503:   {"ServiceUnavailable", "Reduce your request rate."},
 	40000: {"InvalidBucketName", "The specified bucket is not valid."},
 	40001: {"BucketAlreadyExists", "The specified bucket is not valid."},
+	40002: {"BadDigest", "The checksum you specified did not match what we received."},
+	40003: {"InvalidDigest", "The Content-MD5 you specified is not valid."},
 	40300: {"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided."},
 	40400: {"NoSuchBucket", "The specified bucket does not exist."},
 	40401: {"NoSuchKey", "The specified key does not exist."},
+	40402: {"ObjectLockConfigurationNotFoundError", "Object Lock configuration does not exist for this bucket."},
+	40403: {"NoSuchObjectLockConfiguration", "The specified object does not have an ObjectLock configuration."},
+	40404: {"NoSuchWebsiteConfiguration", "The specified bucket does not have a website configuration."},
+	41600: {"InvalidPartNumber", "The requested partnumber is not satisfiable."},
 }
 
 type s3Owner struct {
@@ -100,10 +121,12 @@ type s3BucketInfo struct {
 }
 
 type s3BucketList struct {
-	XMLName xml.Name       `xml:"ListAllMyBucketsResult"`
-	Xmlns   string         `xml:"xmlns,attr"`
-	Owner   s3Owner        `xml:"Owner"`
-	Buckets []s3BucketInfo `xml:"Buckets>Bucket"`
+	XMLName           xml.Name       `xml:"ListAllMyBucketsResult"`
+	Xmlns             string         `xml:"xmlns,attr"`
+	Owner             s3Owner        `xml:"Owner"`
+	Buckets           []s3BucketInfo `xml:"Buckets>Bucket"`
+	ContinuationToken string         `xml:"ContinuationToken,omitempty"`
+	Prefix            string         `xml:"Prefix,omitempty"`
 }
 
 type s3InitiateMultipartUploadResult struct {
@@ -131,6 +154,31 @@ type s3CompleteMultipartUpload struct {
 	} `xml:"Part"`
 }
 
+type s3Delete struct {
+	XMLName xml.Name `xml:"Delete"`
+	Quiet   bool     `xml:"Quiet"`
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+type s3Deleted struct {
+	Key string `xml:"Key"`
+}
+
+type s3DeleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+type s3DeleteResult struct {
+	XMLName xml.Name        `xml:"DeleteResult"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Deleted []s3Deleted     `xml:"Deleted"`
+	Errors  []s3DeleteError `xml:"Error"`
+}
+
 type s3ListPartsResultPart struct {
 	PartNumber   int
 	LastModified string
@@ -300,10 +348,39 @@ type ObjectListingRecord struct {
 
 type s3CopyObject struct {
 	XMLName      xml.Name `xml:"CopyObjectResult"`
+	Xmlns        string   `xml:"xmlns,attr"`
 	LastModified string   `xml:"LastModified"`
 	ETag         string   `xml:"ETag"`
 }
 
+type s3ObjectLockConfiguration struct {
+	XMLName           xml.Name          `xml:"ObjectLockConfiguration"`
+	Xmlns             string            `xml:"xmlns,attr"`
+	ObjectLockEnabled string            `xml:"ObjectLockEnabled"`
+	Rule              *s3ObjectLockRule `xml:"Rule,omitempty"`
+}
+
+type s3ObjectLockRule struct {
+	DefaultRetention struct {
+		Mode  string `xml:"Mode"`
+		Days  int64  `xml:"Days,omitempty"`
+		Years int64  `xml:"Years,omitempty"`
+	} `xml:"DefaultRetention"`
+}
+
+type s3Retention struct {
+	XMLName         xml.Name `xml:"Retention"`
+	Xmlns           string   `xml:"xmlns,attr"`
+	Mode            string   `xml:"Mode"`
+	RetainUntilDate string   `xml:"RetainUntilDate"`
+}
+
+type s3LegalHold struct {
+	XMLName xml.Name `xml:"LegalHold"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Status  string   `xml:"Status"`
+}
+
 type s3Error struct {
 	XMLName   xml.Name `xml:"Error"`
 	Code      string   `xml:"Code"`
@@ -319,20 +396,36 @@ func NewS3Error() *s3Error {
 // This will wrap http.ResponseWriter to support s3 style xml responses on errors
 // TODO: This may still need some work for more specific error responses
 type s3ResponseWriterWrapper struct {
-	writer    http.ResponseWriter
-	hijack    bool
-	resource  string
-	requestId string
-	msg       []byte
+	writer            http.ResponseWriter
+	hijack            bool
+	wroteHeader       bool
+	resource          string
+	requestId         string
+	extendedRequestId string
+	msg               []byte
+}
+
+// newS3ExtendedRequestId returns an opaque x-amz-id-2 value, which S3 clients
+// report alongside the request id when asking for support.
+func newS3ExtendedRequestId() string {
+	b := make([]byte, 48)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(b)
 }
 
 func newS3ResponseWriterWrapper(w http.ResponseWriter, r *http.Request) *s3ResponseWriterWrapper {
 	ctx := GetProxyContext(r)
+	extendedRequestId := newS3ExtendedRequestId()
+	// The request id is the transaction id, which is already logged.
+	ctx.Logger = ctx.Logger.With(zap.String("s3ExtendedRequestId", extendedRequestId))
 	return &s3ResponseWriterWrapper{
-		writer:    w,
-		hijack:    false,
-		resource:  r.URL.Path,
-		requestId: ctx.TxId,
+		writer:            w,
+		hijack:            false,
+		resource:          r.URL.Path,
+		requestId:         ctx.TxId,
+		extendedRequestId: extendedRequestId,
 	}
 }
 
@@ -341,7 +434,8 @@ func (w *s3ResponseWriterWrapper) Header() http.Header {
 }
 
 func (w *s3ResponseWriterWrapper) WriteHeader(statusCode int) {
-	w.writer.Header().Set("x-amz-id-2", w.requestId)
+	w.wroteHeader = true
+	w.writer.Header().Set("x-amz-id-2", w.extendedRequestId)
 	w.writer.Header().Set("x-amz-request-id", w.requestId)
 	if statusCode/100 != 2 {
 		// We are going to hijack to return an S3 style result
@@ -372,6 +466,9 @@ func (w *s3ResponseWriterWrapper) WriteHeader(statusCode int) {
 }
 
 func (w *s3ResponseWriterWrapper) Write(buf []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
 	if !w.hijack {
 		return w.writer.Write(buf)
 	} else {
@@ -380,6 +477,12 @@ func (w *s3ResponseWriterWrapper) Write(buf []byte) (int, error) {
 	}
 }
 
+func (w *s3ResponseWriterWrapper) Flush() {
+	if !w.hijack {
+		srv.Flush(w.writer)
+	}
+}
+
 type s3ApiHandler struct {
 	next           http.Handler
 	ctx            *ProxyContext
@@ -517,10 +620,54 @@ func (s *s3ApiHandler) ServeHTTP(writer http.ResponseWriter, request *http.Reque
 	}
 }
 
+// copyChecksums copies the digests of the algorithms S3 supports from the
+// src headers with fromPrefix to dst headers with toPrefix, translating
+// between x-amz-checksum-* and the object checksums Swift stores.
+func copyChecksums(dst, src http.Header, fromPrefix, toPrefix string) {
+	for _, algorithm := range s3ChecksumAlgorithms {
+		if v := src.Get(fromPrefix + algorithm); v != "" {
+			dst.Set(toPrefix+algorithm, v)
+		}
+	}
+}
+
+// s3ToObjectMeta copies the x-amz-meta-* headers in src to object metadata
+// in dst. S3 clients send non-ASCII values as RFC 2047 encoded-words, which
+// are stored decoded.
+func s3ToObjectMeta(dst, src http.Header) {
+	for k, v := range src {
+		if strings.HasPrefix(k, "X-Amz-Meta-") && len(v) > 0 {
+			dst.Set("X-Object-Meta-"+k[len("X-Amz-Meta-"):], common.DecodeMetaValue(v[0]))
+		}
+	}
+}
+
+// s3FromObjectMeta replaces the object metadata in a response's headers with
+// x-amz-meta-* headers, encoding non-ASCII values the way S3 does.
+func s3FromObjectMeta(header http.Header) {
+	for k, v := range header {
+		if strings.HasPrefix(k, "X-Object-Meta-") {
+			delete(header, k)
+			if len(v) > 0 {
+				header.Set("X-Amz-Meta-"+k[len("X-Object-Meta-"):], common.EncodeMetaValue(v[0]))
+			}
+		}
+	}
+}
+
 func (s *s3ApiHandler) handleObjectRequest(writer http.ResponseWriter, request *http.Request) {
 	ctx := GetProxyContext(request)
 	request.ParseForm()
 
+	if _, ok := request.Form["retention"]; ok {
+		s.handleObjectRetention(writer, request)
+		return
+	}
+	if _, ok := request.Form["legal-hold"]; ok {
+		s.handleObjectLegalHold(writer, request)
+		return
+	}
+
 	if request.Method == "GET" || request.Method == "HEAD" {
 		if uploadId := request.Form.Get("uploadId"); uploadId != "" {
 			newReq, err := ctx.newSubrequest("GET", fmt.Sprintf("/v1/AUTH_%s/%s+segments?prefix=%s-%s/", common.Urlencode(s.account),
@@ -573,16 +720,49 @@ func (s *s3ApiHandler) handleObjectRequest(writer http.ResponseWriter, request *
 			writer.Write(output)
 			return
 		}
+		partsCount := 0
+		rangeHeader := request.Header.Get("Range")
+		if pn := request.Form.Get("partNumber"); pn != "" {
+			partNumber, err := strconv.Atoi(pn)
+			if err != nil || partNumber < 1 || partNumber > s3MultipartMaxParts || rangeHeader != "" {
+				srv.StandardResponse(writer, http.StatusBadRequest)
+				return
+			}
+			var status int
+			if rangeHeader, partsCount, status = s.partRange(request, partNumber); status != http.StatusOK {
+				if status == http.StatusNotFound {
+					NoSuchKeyResponse(writer, request)
+					return
+				}
+				srv.StandardResponse(writer, status)
+				return
+			}
+		}
 		newReq, err := ctx.newSubrequest(request.Method, s.path, http.NoBody, request, "s3api")
 		if err != nil {
 			srv.SimpleErrorResponse(writer, http.StatusInternalServerError, err.Error())
 		}
-		newReq.Header.Set("Range", request.Header.Get("Range"))
+		newReq.Header.Set("Range", rangeHeader)
 		newReq.Header.Set("If-Match", request.Header.Get("If-Match"))
 		newReq.Header.Set("If-None-Match", request.Header.Get("If-None-Match"))
 		newReq.Header.Set("If-Modified-Since", request.Header.Get("If-Modified-Since"))
 		newReq.Header.Set("If-UnModified-Since", request.Header.Get("If-UnModified-Since"))
-		ctx.serveHTTPSubrequest(writer, newReq)
+		checksumMode := strings.EqualFold(request.Header.Get("X-Amz-Checksum-Mode"), "ENABLED") && request.Header.Get("Range") == ""
+		ctx.serveHTTPSubrequest(srv.NewCustomWriter(writer, func(w http.ResponseWriter, status int) int {
+			if checksumMode {
+				copyChecksums(w.Header(), w.Header(), common.ChecksumHeaderPrefix, s3ChecksumHeaderPrefix)
+			}
+			RemoveItemsWithPrefix(w.Header(), common.ChecksumHeaderPrefix)
+			if etag := w.Header().Get(s3EtagSysmeta); etag != "" {
+				w.Header().Set("Etag", "\""+etag+"\"")
+			}
+			if partsCount > 0 {
+				w.Header().Set(s3PartsCountHeader, strconv.Itoa(partsCount))
+			}
+			s3FromObjectLockHeaders(w.Header())
+			s3FromObjectMeta(w.Header())
+			return status
+		}), newReq)
 		return
 	}
 
@@ -642,6 +822,7 @@ func (s *s3ApiHandler) handleObjectRequest(writer http.ResponseWriter, request *
 	}
 
 	if request.Method == "PUT" {
+		destContainer, destObject := s.container, s.object
 		if uploadId := request.Form.Get("uploadId"); uploadId != "" {
 			if partNumber, err := strconv.Atoi(request.Form.Get("partNumber")); err != nil || partNumber < 1 || partNumber > s3MultipartMaxParts {
 				srv.StandardResponse(writer, http.StatusBadRequest)
@@ -649,56 +830,48 @@ func (s *s3ApiHandler) handleObjectRequest(writer http.ResponseWriter, request *
 			} else {
 				s.path = fmt.Sprintf("/v1/AUTH_%s/%s+segments/%s-%s/%08d", common.Urlencode(s.account),
 					common.Urlencode(s.container), common.Urlencode(uploadId), common.Urlencode(s.object), partNumber)
+				destContainer = s.container + "+segments"
+				destObject = fmt.Sprintf("%s-%s/%08d", uploadId, s.object, partNumber)
 			}
 		}
-		method := "PUT"
-		dest := ""
 		// Check to see if this is a copy request
-		copySource := request.Header.Get("X-Amz-Copy-Source")
-		if copySource != "" {
-			method = "COPY"
-			dest = s.path
-			c, o := s3PathSplit(copySource)
-			s.path = fmt.Sprintf("/v1/AUTH_%s/%s/%s", s.account, c, o)
-		}
-		newReq, err := ctx.newSubrequest(method, s.path, request.Body, request, "s3api")
+		if copySource := request.Header.Get("X-Amz-Copy-Source"); copySource != "" {
+			s.handleCopyObject(writer, request, copySource, destContainer, destObject)
+			return
+		}
+		newReq, err := ctx.newSubrequest("PUT", s.path, request.Body, request, "s3api")
 		if err != nil {
 			srv.SimpleErrorResponse(writer, http.StatusInternalServerError, err.Error())
 			return
 		}
-		if copySource != "" {
-			pathMap, err := common.ParseProxyPath(dest)
-			if err != nil {
-				srv.SimpleErrorResponse(writer, http.StatusInternalServerError, err.Error())
+		newReq.Header.Set("Content-Length", request.Header.Get("Content-Length"))
+		newReq.Header.Set("Content-Type", request.Header.Get("Content-Type"))
+		if md5sum := request.Header.Get("Content-Md5"); md5sum != "" {
+			if _, err := common.ContentMD5Etag(md5sum); err != nil {
+				srv.StandardResponse(writer, 40003)
 				return
 			}
-			newReq.Header.Set("Destination", fmt.Sprintf("/%s/%s", pathMap["container"], pathMap["object"]))
+			newReq.Header.Set("Content-Md5", md5sum)
+		}
+		copyChecksums(newReq.Header, request.Header, s3ChecksumHeaderPrefix, common.ChecksumHeaderPrefix)
+		s3ToObjectMeta(newReq.Header, request.Header)
+		if err := s3ToObjectLockHeaders(newReq.Header, request.Header); err != nil {
+			srv.StandardResponse(writer, http.StatusBadRequest)
+			return
 		}
-		newReq.Header.Set("Content-Length", request.Header.Get("Content-Length"))
-		newReq.Header.Set("Content-Type", request.Header.Get("Content-Type"))
 		cap := NewCaptureWriter()
 		ctx.serveHTTPSubrequest(cap, newReq)
-		if cap.status/100 != 2 {
+		if cap.status == http.StatusUnprocessableEntity {
+			srv.StandardResponse(writer, 40002)
+			return
+		} else if cap.status/100 != 2 {
 			srv.StandardResponse(writer, cap.status)
 			return
 		} else {
-			if copySource != "" {
-				copyResult := &s3CopyObject{}
-				copyResult.ETag = "\"" + cap.Header().Get("ETag") + "\""
-				copyResult.LastModified = cap.Header().Get("Last-Modified")
-				output, err := xml.MarshalIndent(copyResult, "", "  ")
-				if err != nil {
-					srv.SimpleErrorResponse(writer, http.StatusInternalServerError, err.Error())
-					return
-				}
-				output = []byte(xml.Header + string(output))
-				writer.WriteHeader(200)
-				writer.Write(output)
-			} else {
-				writer.Header().Set("ETag", "\""+cap.Header().Get("ETag")+"\"")
-				writer.Header().Set("Content-Length", cap.Header().Get("Content-Length"))
-				writer.WriteHeader(200)
-			}
+			copyChecksums(writer.Header(), cap.Header(), common.ChecksumHeaderPrefix, s3ChecksumHeaderPrefix)
+			writer.Header().Set("ETag", "\""+cap.Header().Get("ETag")+"\"")
+			writer.Header().Set("Content-Length", cap.Header().Get("Content-Length"))
+			writer.WriteHeader(200)
 			return
 		}
 	}
@@ -763,11 +936,20 @@ func (s *s3ApiHandler) handleObjectRequest(writer http.ResponseWriter, request *
 
 			type bodyPart struct {
 				Path string `json:"path"`
+				Etag string `json:"etag"`
 			}
 			slobj := []bodyPart{}
+			var partEtags []string
 			for _, part := range completeMU.Parts {
+				// The SLO checks each part's ETag against its segment.
 				slobj = append(slobj, bodyPart{Path: fmt.Sprintf("/%s+segments/%s-%s/%08d", common.Urlencode(s.container),
-					common.Urlencode(uploadId), common.Urlencode(s.object), part.PartNumber)})
+					common.Urlencode(uploadId), common.Urlencode(s.object), part.PartNumber), Etag: strings.Trim(part.ETag, "\"")})
+				partEtags = append(partEtags, part.ETag)
+			}
+			etag, err := s3MultipartEtag(partEtags)
+			if err != nil || len(partEtags) == 0 {
+				srv.StandardResponse(writer, http.StatusBadRequest)
+				return
 			}
 			slobjBody, err := json.Marshal(slobj)
 			if err != nil {
@@ -782,6 +964,7 @@ func (s *s3ApiHandler) handleObjectRequest(writer http.ResponseWriter, request *
 				return
 			}
 			newReq.Header.Set("Content-Length", "0")
+			newReq.Header.Set(s3EtagSysmeta, etag)
 			c := NewCaptureWriter()
 			ctx.serveHTTPSubrequest(c, newReq)
 			if c.status/100 != 2 {
@@ -794,7 +977,7 @@ func (s *s3ApiHandler) handleObjectRequest(writer http.ResponseWriter, request *
 				Location: "", // TODO
 				Bucket:   s.container,
 				Key:      s.object,
-				ETag:     "", // TODO
+				ETag:     "\"" + etag + "\"",
 			}, "", "  ")
 			if err != nil {
 				srv.StandardResponse(writer, http.StatusInternalServerError)
@@ -821,6 +1004,21 @@ func (s *s3ApiHandler) handleContainerRequest(writer http.ResponseWriter, reques
 
 	writer.Header().Set("Location", "/"+s.container)
 
+	if _, ok := request.Form["object-lock"]; ok {
+		s.handleObjectLockConfiguration(writer, request)
+		return
+	}
+
+	if _, ok := request.Form["website"]; ok {
+		s.handleBucketWebsite(writer, request)
+		return
+	}
+
+	if _, ok := request.Form["acl"]; ok {
+		s.handleBucketACL(writer, request)
+		return
+	}
+
 	if request.Method == "HEAD" {
 		newReq, err := ctx.newSubrequest("HEAD", s.path, http.NoBody, request, "s3api")
 		if err != nil {
@@ -861,11 +1059,36 @@ func (s *s3ApiHandler) handleContainerRequest(writer http.ResponseWriter, reques
 	}
 
 	if request.Method == "PUT" {
-		newReq, err := ctx.newSubrequest("PUT", s.path, http.NoBody, request, "s3api")
+		// Re-PUTting an existing container would bump its put timestamp,
+		// which is what we report as the bucket's creation date.
+		headReq, err := ctx.newSubrequest("HEAD", s.path, http.NoBody, request, "s3api")
 		if err != nil {
 			srv.SimpleErrorResponse(writer, http.StatusInternalServerError, err.Error())
+			return
 		}
 		cap := NewCaptureWriter()
+		ctx.serveHTTPSubrequest(cap, headReq)
+		if cap.status/100 == 2 {
+			BucketAlreadyExistsResponse(writer, request)
+			return
+		}
+		newReq, err := ctx.newSubrequest("PUT", s.path, http.NoBody, request, "s3api")
+		if err != nil {
+			srv.SimpleErrorResponse(writer, http.StatusInternalServerError, err.Error())
+			return
+		}
+		policy, ok := s3CannedACL(s.account, request.Header.Get("X-Amz-Acl"))
+		if !ok {
+			srv.StandardResponse(writer, http.StatusNotImplemented)
+			return
+		}
+		if read, _, _ := s3ACLToSwift(s.account, "", policy); read != "" {
+			newReq.Header.Set("X-Container-Read", read)
+		}
+		if strings.EqualFold(request.Header.Get("X-Amz-Bucket-Object-Lock-Enabled"), "true") {
+			newReq.Header.Set(retentionEnabledHeader, "true")
+		}
+		cap = NewCaptureWriter()
 		ctx.serveHTTPSubrequest(cap, newReq)
 		/* Can't overwrite a bucket in s3, so we'll lie about it here. */
 		if cap.status == http.StatusAccepted {
@@ -881,7 +1104,18 @@ func (s *s3ApiHandler) handleContainerRequest(writer http.ResponseWriter, reques
 		}
 	}
 
+	if request.Method == "POST" {
+		if _, del := request.Form["delete"]; del {
+			s.handleDeleteObjects(writer, request)
+			return
+		}
+	}
+
 	if request.Method == "GET" {
+		if _, versions := request.Form["versions"]; versions {
+			s.handleListObjectVersions(writer, request)
+			return
+		}
 		if _, upload := request.Form["uploads"]; upload && request.Form.Get("uploads") == "" {
 			newReq, err := ctx.newSubrequest("GET", fmt.Sprintf("/v1/AUTH_%s/%s+segments?prefix=&delimiter=/", s.account, s.container),
 				http.NoBody, request, "s3api")
@@ -1054,15 +1288,518 @@ func (s *s3ApiHandler) handleContainerRequest(writer http.ResponseWriter, reques
 	srv.StandardResponse(writer, http.StatusMethodNotAllowed)
 }
 
+// handleDeleteObjects implements multi-object delete, deleting up to
+// s3DeleteMaxKeys objects from the bucket, s3DeleteConcurrency at a time.
+func (s *s3ApiHandler) handleDeleteObjects(writer http.ResponseWriter, request *http.Request) {
+	ctx := GetProxyContext(request)
+	body, err := ioutil.ReadAll(io.LimitReader(request.Body, s3DeleteBodyLimit))
+	if err != nil {
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	if md5sum := request.Header.Get("Content-Md5"); md5sum != "" {
+		etag, err := common.ContentMD5Etag(md5sum)
+		if err != nil {
+			srv.StandardResponse(writer, 40003)
+			return
+		}
+		if sum := md5.Sum(body); etag != hex.EncodeToString(sum[:]) {
+			srv.StandardResponse(writer, 40002)
+			return
+		}
+	}
+	del := s3Delete{}
+	if err := xml.Unmarshal(body, &del); err != nil || len(del.Objects) == 0 || len(del.Objects) > s3DeleteMaxKeys {
+		srv.StandardResponse(writer, http.StatusBadRequest)
+		return
+	}
+	statuses := make([]int, len(del.Objects))
+	work := make(chan int)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < s3DeleteConcurrency && i < len(del.Objects); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range work {
+				newReq, err := ctx.newSubrequest("DELETE", fmt.Sprintf("/v1/AUTH_%s/%s/%s", common.Urlencode(s.account),
+					common.Urlencode(s.container), common.Urlencode(del.Objects[j].Key)), http.NoBody, request, "s3api")
+				if err != nil {
+					statuses[j] = http.StatusBadRequest
+					continue
+				}
+				cap := NewCaptureWriter()
+				ctx.serveHTTPSubrequest(cap, newReq)
+				statuses[j] = cap.status
+			}
+		}()
+	}
+	for i := range del.Objects {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	result := &s3DeleteResult{Xmlns: s3Xmlns}
+	for i, obj := range del.Objects {
+		// Deleting a key that isn't there counts as success in S3.
+		if statuses[i]/100 == 2 || statuses[i] == http.StatusNotFound {
+			if !del.Quiet {
+				result.Deleted = append(result.Deleted, s3Deleted{Key: obj.Key})
+			}
+			continue
+		}
+		resp, ok := s3Responses[statuses[i]]
+		if !ok {
+			resp = s3Responses[http.StatusInternalServerError]
+		}
+		result.Errors = append(result.Errors, s3DeleteError{Key: obj.Key, Code: resp.Code, Message: resp.Message})
+	}
+	output, err := xml.MarshalIndent(result, "", "  ")
+	if err != nil {
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	output = []byte(xml.Header + string(output))
+	headers := writer.Header()
+	headers.Set("Content-Type", "application/xml; charset=utf-8")
+	headers.Set("Content-Length", strconv.Itoa(len(output)))
+	writer.WriteHeader(200)
+	writer.Write(output)
+}
+
+// s3CopySourcePrecondition checks the x-amz-copy-source-if-* headers against
+// the source object's headers, returning false if the copy shouldn't happen.
+func s3CopySourcePrecondition(request *http.Request, srcHeader http.Header) bool {
+	etag := strings.Trim(srcHeader.Get("Etag"), "\"")
+	lastModified, lmErr := http.ParseTime(srcHeader.Get("Last-Modified"))
+	matches := func(v string) bool {
+		for _, tag := range strings.Split(v, ",") {
+			if tag = strings.Trim(strings.TrimSpace(tag), "\""); tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+	// A satisfied if-match overrides if-unmodified-since, and a failed
+	// if-none-match overrides if-modified-since, as in S3.
+	if v := request.Header.Get("X-Amz-Copy-Source-If-Match"); v != "" {
+		if !matches(v) {
+			return false
+		}
+	} else if v := request.Header.Get("X-Amz-Copy-Source-If-Unmodified-Since"); v != "" && lmErr == nil {
+		if t, err := http.ParseTime(v); err == nil && lastModified.After(t) {
+			return false
+		}
+	}
+	if v := request.Header.Get("X-Amz-Copy-Source-If-None-Match"); v != "" {
+		if matches(v) {
+			return false
+		}
+	} else if v := request.Header.Get("X-Amz-Copy-Source-If-Modified-Since"); v != "" && lmErr == nil {
+		if t, err := http.ParseTime(v); err == nil && !lastModified.After(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// handleCopyObject copies copySource to destContainer/destObject, either
+// keeping the source's metadata or, with a REPLACE metadata directive, taking
+// it from the request.
+func (s *s3ApiHandler) handleCopyObject(writer http.ResponseWriter, request *http.Request, copySource, destContainer, destObject string) {
+	ctx := GetProxyContext(request)
+	if i := strings.Index(copySource, "?"); i >= 0 {
+		copySource = copySource[:i]
+	}
+	if unescaped, err := url.PathUnescape(copySource); err == nil {
+		copySource = unescaped
+	}
+	srcContainer, srcObject := s3PathSplit(copySource)
+	if srcContainer == "" || srcObject == "" {
+		srv.StandardResponse(writer, http.StatusBadRequest)
+		return
+	}
+	directive := strings.ToUpper(request.Header.Get("X-Amz-Metadata-Directive"))
+	if directive == "" {
+		directive = "COPY"
+	}
+	if directive != "COPY" && directive != "REPLACE" {
+		srv.StandardResponse(writer, http.StatusBadRequest)
+		return
+	}
+	srcPath := fmt.Sprintf("/v1/AUTH_%s/%s/%s", common.Urlencode(s.account), common.Urlencode(srcContainer), common.Urlencode(srcObject))
+	// S3 only allows copying an object onto itself to change its metadata.
+	if directive == "COPY" && destContainer == srcContainer && destObject == srcObject {
+		srv.StandardResponse(writer, http.StatusBadRequest)
+		return
+	}
+
+	headReq, err := ctx.newSubrequest("HEAD", srcPath, http.NoBody, request, "s3api")
+	if err != nil {
+		srv.SimpleErrorResponse(writer, http.StatusInternalServerError, err.Error())
+		return
+	}
+	cap := NewCaptureWriter()
+	ctx.serveHTTPSubrequest(cap, headReq)
+	if cap.status == 404 {
+		NoSuchKeyResponse(writer, request)
+		return
+	}
+	if cap.status/100 != 2 {
+		srv.StandardResponse(writer, cap.status)
+		return
+	}
+	if !s3CopySourcePrecondition(request, cap.Header()) {
+		srv.StandardResponse(writer, http.StatusPreconditionFailed)
+		return
+	}
+
+	newReq, err := ctx.newSubrequest("COPY", srcPath, http.NoBody, request, "s3api")
+	if err != nil {
+		srv.SimpleErrorResponse(writer, http.StatusInternalServerError, err.Error())
+		return
+	}
+	newReq.Header.Set("Destination", fmt.Sprintf("/%s/%s", common.Urlencode(destContainer), common.Urlencode(destObject)))
+	if directive == "REPLACE" {
+		newReq.Header.Set("X-Fresh-Metadata", "true")
+		if ct := request.Header.Get("Content-Type"); ct != "" {
+			newReq.Header.Set("Content-Type", ct)
+		}
+		s3ToObjectMeta(newReq.Header, request.Header)
+	}
+	cap = NewCaptureWriter()
+	ctx.serveHTTPSubrequest(cap, newReq)
+	if cap.status/100 != 2 {
+		srv.StandardResponse(writer, cap.status)
+		return
+	}
+	copyResult := &s3CopyObject{Xmlns: s3Xmlns}
+	copyResult.ETag = "\"" + strings.Trim(cap.Header().Get("ETag"), "\"") + "\""
+	lastModified, err := http.ParseTime(cap.Header().Get("Last-Modified"))
+	if err != nil {
+		lastModified = time.Now()
+	}
+	copyResult.LastModified = lastModified.UTC().Format("2006-01-02T15:04:05.000Z")
+	output, err := xml.MarshalIndent(copyResult, "", "  ")
+	if err != nil {
+		srv.SimpleErrorResponse(writer, http.StatusInternalServerError, err.Error())
+		return
+	}
+	output = []byte(xml.Header + string(output))
+	headers := writer.Header()
+	headers.Set("Content-Type", "application/xml; charset=utf-8")
+	headers.Set("Content-Length", strconv.Itoa(len(output)))
+	writer.WriteHeader(200)
+	writer.Write(output)
+}
+
+// s3ToObjectLockHeaders translates a PUT's x-amz-object-lock headers in src
+// to the retention headers in dst. Swift's retention is always compliance
+// mode, so the lock mode is ignored.
+func s3ToObjectLockHeaders(dst, src http.Header) error {
+	if v := src.Get("X-Amz-Object-Lock-Retain-Until-Date"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return err
+		}
+		dst.Set(retainUntilHeader, strconv.FormatInt(t.Unix(), 10))
+	}
+	switch v := src.Get("X-Amz-Object-Lock-Legal-Hold"); v {
+	case "":
+	case "ON":
+		dst.Set(legalHoldHeader, "true")
+	case "OFF":
+		dst.Set(legalHoldHeader, "false")
+	default:
+		return fmt.Errorf("invalid legal hold status: %q", v)
+	}
+	return nil
+}
+
+// s3FromObjectLockHeaders adds x-amz-object-lock headers for the retention
+// headers of an object's response.
+func s3FromObjectLockHeaders(header http.Header) {
+	if until, err := strconv.ParseInt(header.Get(retainUntilHeader), 10, 64); err == nil && until > 0 {
+		header.Set("X-Amz-Object-Lock-Mode", "COMPLIANCE")
+		header.Set("X-Amz-Object-Lock-Retain-Until-Date", s3FormatUnix(until))
+	}
+	if v := header.Get(legalHoldHeader); v != "" {
+		if common.LooksTrue(v) {
+			header.Set("X-Amz-Object-Lock-Legal-Hold", "ON")
+		} else {
+			header.Set("X-Amz-Object-Lock-Legal-Hold", "OFF")
+		}
+	}
+}
+
+func s3FormatUnix(t int64) string {
+	return time.Unix(t, 0).UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func writeS3XML(writer http.ResponseWriter, v interface{}) {
+	output, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	writer.WriteHeader(200)
+	writer.Write([]byte(xml.Header))
+	writer.Write(output)
+}
+
+func (s *s3ApiHandler) headSubrequest(request *http.Request) (*captureWriter, error) {
+	ctx := GetProxyContext(request)
+	newReq, err := ctx.newSubrequest("HEAD", s.path, http.NoBody, request, "s3api")
+	if err != nil {
+		return nil, err
+	}
+	cap := NewCaptureWriter()
+	ctx.serveHTTPSubrequest(cap, newReq)
+	return cap, nil
+}
+
+func (s *s3ApiHandler) handleObjectLockConfiguration(writer http.ResponseWriter, request *http.Request) {
+	ctx := GetProxyContext(request)
+	switch request.Method {
+	case "GET":
+		cap, err := s.headSubrequest(request)
+		if err != nil {
+			srv.StandardResponse(writer, http.StatusInternalServerError)
+			return
+		}
+		if cap.status == 404 {
+			NoSuchBucketResponse(writer, request)
+			return
+		}
+		if cap.status/100 != 2 {
+			srv.StandardResponse(writer, cap.status)
+			return
+		}
+		if !common.LooksTrue(cap.Header().Get(retentionEnabledHeader)) {
+			writer.WriteHeader(40402)
+			writer.Write(nil)
+			return
+		}
+		config := &s3ObjectLockConfiguration{Xmlns: s3Xmlns, ObjectLockEnabled: "Enabled"}
+		if period, err := strconv.ParseInt(cap.Header().Get(retentionPeriodHeader), 10, 64); err == nil && period > 0 {
+			config.Rule = &s3ObjectLockRule{}
+			config.Rule.DefaultRetention.Mode = "COMPLIANCE"
+			config.Rule.DefaultRetention.Days = (period + 86399) / 86400
+		}
+		writeS3XML(writer, config)
+	case "PUT":
+		body, err := ioutil.ReadAll(io.LimitReader(request.Body, s3MultipartCompleteBodyLimit))
+		if err != nil {
+			srv.StandardResponse(writer, http.StatusBadRequest)
+			return
+		}
+		var config s3ObjectLockConfiguration
+		if err := xml.Unmarshal(body, &config); err != nil || config.ObjectLockEnabled != "Enabled" {
+			srv.StandardResponse(writer, http.StatusBadRequest)
+			return
+		}
+		newReq, err := ctx.newSubrequest("POST", s.path, http.NoBody, request, "s3api")
+		if err != nil {
+			srv.StandardResponse(writer, http.StatusInternalServerError)
+			return
+		}
+		newReq.Header.Set(retentionEnabledHeader, "true")
+		if config.Rule != nil {
+			r := config.Rule.DefaultRetention
+			if r.Mode != "GOVERNANCE" && r.Mode != "COMPLIANCE" {
+				srv.StandardResponse(writer, http.StatusBadRequest)
+				return
+			}
+			switch {
+			case r.Days > 0 && r.Years == 0:
+				newReq.Header.Set(retentionPeriodHeader, strconv.FormatInt(r.Days*86400, 10))
+			case r.Years > 0 && r.Days == 0:
+				newReq.Header.Set(retentionPeriodHeader, strconv.FormatInt(r.Years*365*86400, 10))
+			default:
+				srv.StandardResponse(writer, http.StatusBadRequest)
+				return
+			}
+		}
+		cap := NewCaptureWriter()
+		ctx.serveHTTPSubrequest(cap, newReq)
+		if cap.status == 404 {
+			NoSuchBucketResponse(writer, request)
+			return
+		}
+		if cap.status/100 != 2 {
+			srv.StandardResponse(writer, cap.status)
+			return
+		}
+		writer.WriteHeader(200)
+	default:
+		srv.StandardResponse(writer, http.StatusMethodNotAllowed)
+	}
+}
+
+// postObjectLock changes one of an object's retention headers. A POST
+// replaces an object's metadata, so the rest of it is read and sent along.
+func (s *s3ApiHandler) postObjectLock(writer http.ResponseWriter, request *http.Request, header, value string) {
+	ctx := GetProxyContext(request)
+	head, err := s.headSubrequest(request)
+	if err != nil {
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	if head.status == 404 {
+		NoSuchKeyResponse(writer, request)
+		return
+	}
+	if head.status/100 != 2 {
+		srv.StandardResponse(writer, head.status)
+		return
+	}
+	newReq, err := ctx.newSubrequest("POST", s.path, http.NoBody, request, "s3api")
+	if err != nil {
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	for k, v := range head.Header() {
+		if strings.HasPrefix(k, "X-Object-Meta-") || common.StringInSlice(k, []string{"Content-Type", "Content-Disposition",
+			"Content-Encoding", "Content-Language", "Cache-Control", "Expires", "X-Delete-At"}) {
+			newReq.Header[k] = v
+		}
+	}
+	newReq.Header.Set(header, value)
+	cap := NewCaptureWriter()
+	ctx.serveHTTPSubrequest(cap, newReq)
+	if cap.status/100 != 2 {
+		srv.StandardResponse(writer, cap.status)
+		return
+	}
+	writer.WriteHeader(200)
+}
+
+func (s *s3ApiHandler) handleObjectRetention(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case "GET":
+		cap, err := s.headSubrequest(request)
+		if err != nil {
+			srv.StandardResponse(writer, http.StatusInternalServerError)
+			return
+		}
+		if cap.status == 404 {
+			NoSuchKeyResponse(writer, request)
+			return
+		}
+		if cap.status/100 != 2 {
+			srv.StandardResponse(writer, cap.status)
+			return
+		}
+		until, err := strconv.ParseInt(cap.Header().Get(retainUntilHeader), 10, 64)
+		if err != nil || until <= 0 {
+			writer.WriteHeader(40403)
+			writer.Write(nil)
+			return
+		}
+		writeS3XML(writer, &s3Retention{Xmlns: s3Xmlns, Mode: "COMPLIANCE", RetainUntilDate: s3FormatUnix(until)})
+	case "PUT":
+		body, err := ioutil.ReadAll(io.LimitReader(request.Body, s3MultipartCompleteBodyLimit))
+		if err != nil {
+			srv.StandardResponse(writer, http.StatusBadRequest)
+			return
+		}
+		var r s3Retention
+		if err := xml.Unmarshal(body, &r); err != nil || (r.Mode != "GOVERNANCE" && r.Mode != "COMPLIANCE") {
+			srv.StandardResponse(writer, http.StatusBadRequest)
+			return
+		}
+		until, err := time.Parse(time.RFC3339, r.RetainUntilDate)
+		if err != nil {
+			srv.StandardResponse(writer, http.StatusBadRequest)
+			return
+		}
+		s.postObjectLock(writer, request, retainUntilHeader, strconv.FormatInt(until.Unix(), 10))
+	default:
+		srv.StandardResponse(writer, http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *s3ApiHandler) handleObjectLegalHold(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case "GET":
+		cap, err := s.headSubrequest(request)
+		if err != nil {
+			srv.StandardResponse(writer, http.StatusInternalServerError)
+			return
+		}
+		if cap.status == 404 {
+			NoSuchKeyResponse(writer, request)
+			return
+		}
+		if cap.status/100 != 2 {
+			srv.StandardResponse(writer, cap.status)
+			return
+		}
+		status := "OFF"
+		if common.LooksTrue(cap.Header().Get(legalHoldHeader)) {
+			status = "ON"
+		}
+		writeS3XML(writer, &s3LegalHold{Xmlns: s3Xmlns, Status: status})
+	case "PUT":
+		body, err := ioutil.ReadAll(io.LimitReader(request.Body, s3MultipartCompleteBodyLimit))
+		if err != nil {
+			srv.StandardResponse(writer, http.StatusBadRequest)
+			return
+		}
+		var hold s3LegalHold
+		if err := xml.Unmarshal(body, &hold); err != nil || (hold.Status != "ON" && hold.Status != "OFF") {
+			srv.StandardResponse(writer, http.StatusBadRequest)
+			return
+		}
+		s.postObjectLock(writer, request, legalHoldHeader, strconv.FormatBool(hold.Status == "ON"))
+	default:
+		srv.StandardResponse(writer, http.StatusMethodNotAllowed)
+	}
+}
+
 func (s *s3ApiHandler) handleAccountRequest(writer http.ResponseWriter, request *http.Request) {
 	ctx := GetProxyContext(request)
 	if request.Method == "GET" {
+		q := request.URL.Query()
+		maxBuckets := s3MaxBuckets
+		if v := q.Get("max-buckets"); v != "" {
+			var err error
+			if maxBuckets, err = strconv.Atoi(v); err != nil || maxBuckets < 1 || maxBuckets > s3MaxBuckets {
+				srv.StandardResponse(writer, http.StatusBadRequest)
+				return
+			}
+		}
+		marker := ""
+		if cont := q.Get("continuation-token"); cont != "" {
+			b, err := base64.StdEncoding.DecodeString(cont)
+			if err != nil {
+				srv.StandardResponse(writer, http.StatusBadRequest)
+				return
+			}
+			marker = string(b)
+		}
+		prefix := q.Get("prefix")
 		newReq, err := ctx.newSubrequest("GET", s.path, http.NoBody, request, "s3api")
 		if err != nil {
 			srv.SimpleErrorResponse(writer, http.StatusInternalServerError, err.Error())
 			return
 		}
 		newReq.Header.Set("Accept", "application/json")
+		nq := newReq.URL.Query()
+		// Ask for one more than we need to see if there are more to come,
+		// within the account server's own limit of s3MaxBuckets.
+		limit := maxBuckets + 1
+		if limit > s3MaxBuckets {
+			limit = s3MaxBuckets
+		}
+		nq.Set("limit", strconv.Itoa(limit))
+		if marker != "" {
+			nq.Set("marker", marker)
+		}
+		if prefix != "" {
+			nq.Set("prefix", prefix)
+		}
+		newReq.URL.RawQuery = nq.Encode()
 		cap := NewCaptureWriter()
 		ctx.serveHTTPSubrequest(cap, newReq)
 		if cap.status/100 != 2 {
@@ -1078,11 +1815,21 @@ func (s *s3ApiHandler) handleAccountRequest(writer http.ResponseWriter, request
 		bucketList := NewS3BucketList()
 		bucketList.Owner.ID = ctx.S3Auth.Account
 		bucketList.Owner.DisplayName = ctx.S3Auth.Account
-		// NOTE: The container list api doesn't have a creation date for the container, so we use an "arbitrary" date.
+		bucketList.Prefix = prefix
+		if len(containerListing) >= limit {
+			containerListing = containerListing[:maxBuckets]
+			bucketList.ContinuationToken = base64.StdEncoding.EncodeToString([]byte(containerListing[maxBuckets-1].Name))
+		}
 		for _, c := range containerListing {
+			// The account listing's last_modified is the container's put
+			// timestamp, which bucket PUTs are careful not to bump.
+			creationDate := s3DateString(c.LastModified)
+			if c.LastModified == "" {
+				creationDate = "2009-02-03T16:45:09.000Z"
+			}
 			bucketList.Buckets = append(bucketList.Buckets, s3BucketInfo{
 				Name:         c.Name,
-				CreationDate: "2009-02-03T16:45:09.000Z",
+				CreationDate: creationDate,
 			})
 		}
 		output, err := xml.MarshalIndent(bucketList, "", "  ")
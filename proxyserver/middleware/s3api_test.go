@@ -1,9 +1,18 @@
 package middleware
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
 )
 
 func TestValidBucketName(t *testing.T) {
@@ -28,3 +37,273 @@ func TestS3DateString(t *testing.T) {
 	// Doesn't index out of range.
 	assert.Equal(t, "no", s3DateString("no"))
 }
+
+func TestS3ResponseRequestIds(t *testing.T) {
+	ctx := &ProxyContext{Logger: zap.NewNop(), TxId: "tx123"}
+	r := httptest.NewRequest("GET", "/bucket/obj", nil)
+	r = r.WithContext(context.WithValue(r.Context(), "proxycontext", ctx))
+
+	// Headers are set even if the handler never calls WriteHeader.
+	w := httptest.NewRecorder()
+	ww := newS3ResponseWriterWrapper(w, r)
+	ww.Write([]byte("hello"))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "tx123", w.Header().Get("x-amz-request-id"))
+	id2 := w.Header().Get("x-amz-id-2")
+	assert.NotEqual(t, "", id2)
+	assert.NotEqual(t, "tx123", id2)
+
+	w = httptest.NewRecorder()
+	ww = newS3ResponseWriterWrapper(w, r)
+	ww.WriteHeader(404)
+	ww.Write([]byte("Not Found"))
+	assert.Equal(t, "tx123", w.Header().Get("x-amz-request-id"))
+	assert.NotEqual(t, "", w.Header().Get("x-amz-id-2"))
+	assert.NotEqual(t, id2, w.Header().Get("x-amz-id-2"))
+	assert.Contains(t, w.Body.String(), "<RequestId>tx123</RequestId>")
+}
+
+func TestS3ListBuckets(t *testing.T) {
+	var query url.Values
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.WriteHeader(200)
+		w.Write([]byte(`[{"name":"b1","count":0,"bytes":0,"last_modified":"2018-07-05T18:16:09.295890"},` +
+			`{"name":"b2","count":0,"bytes":0,"last_modified":"2018-07-06T18:16:09.295890"},` +
+			`{"name":"b3","count":0,"bytes":0,"last_modified":"2018-07-07T18:16:09.295890"}]`))
+	})
+	ctx := &ProxyContext{
+		ProxyContextMiddleware: &ProxyContextMiddleware{next: next},
+		Logger:                 zap.NewNop(),
+		S3Auth:                 &S3AuthInfo{Account: "test"},
+	}
+	s := &s3ApiHandler{ctx: ctx, account: "test", path: "/v1/AUTH_test"}
+
+	r := httptest.NewRequest("GET", "/?max-buckets=2&prefix=b", nil)
+	r = r.WithContext(context.WithValue(r.Context(), "proxycontext", ctx))
+	w := httptest.NewRecorder()
+	s.handleAccountRequest(w, r)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "3", query.Get("limit"))
+	assert.Equal(t, "b", query.Get("prefix"))
+	list := &s3BucketList{}
+	assert.Nil(t, xml.Unmarshal(w.Body.Bytes(), list))
+	assert.Equal(t, "test", list.Owner.ID)
+	assert.Equal(t, "b", list.Prefix)
+	assert.Equal(t, []s3BucketInfo{
+		{Name: "b1", CreationDate: "2018-07-05T18:16:09.295Z"},
+		{Name: "b2", CreationDate: "2018-07-06T18:16:09.295Z"},
+	}, list.Buckets)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("b2")), list.ContinuationToken)
+
+	r = httptest.NewRequest("GET", "/?continuation-token="+url.QueryEscape(list.ContinuationToken), nil)
+	r = r.WithContext(context.WithValue(r.Context(), "proxycontext", ctx))
+	w = httptest.NewRecorder()
+	s.handleAccountRequest(w, r)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "b2", query.Get("marker"))
+	assert.Equal(t, "10000", query.Get("limit"))
+	list = &s3BucketList{}
+	assert.Nil(t, xml.Unmarshal(w.Body.Bytes(), list))
+	assert.Equal(t, 3, len(list.Buckets))
+	assert.Equal(t, "", list.ContinuationToken)
+
+	for _, q := range []string{"max-buckets=0", "max-buckets=10001", "max-buckets=x", "continuation-token=%21"} {
+		r = httptest.NewRequest("GET", "/?"+q, nil)
+		r = r.WithContext(context.WithValue(r.Context(), "proxycontext", ctx))
+		w = httptest.NewRecorder()
+		s.handleAccountRequest(w, r)
+		assert.Equal(t, 400, w.Code, q)
+	}
+}
+
+func TestS3DeleteObjects(t *testing.T) {
+	var lock sync.Mutex
+	deleted := map[string]bool{}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		deleted[r.URL.Path] = true
+		lock.Unlock()
+		switch r.URL.Path {
+		case "/v1/AUTH_test/bucket/missing":
+			w.WriteHeader(404)
+		case "/v1/AUTH_test/bucket/locked":
+			w.WriteHeader(403)
+		default:
+			w.WriteHeader(204)
+		}
+	})
+	ctx := &ProxyContext{
+		ProxyContextMiddleware: &ProxyContextMiddleware{next: next},
+		Logger:                 zap.NewNop(),
+		S3Auth:                 &S3AuthInfo{Account: "test"},
+	}
+	s := &s3ApiHandler{ctx: ctx, account: "test", container: "bucket", path: "/v1/AUTH_test/bucket"}
+	deleteRequest := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/bucket?delete", strings.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), "proxycontext", ctx))
+		w := httptest.NewRecorder()
+		s.handleContainerRequest(w, r)
+		return w
+	}
+
+	w := deleteRequest(`<Delete><Object><Key>a b</Key></Object><Object><Key>missing</Key></Object>` +
+		`<Object><Key>locked</Key></Object></Delete>`)
+	assert.Equal(t, 200, w.Code)
+	assert.True(t, deleted["/v1/AUTH_test/bucket/a b"])
+	result := &s3DeleteResult{}
+	assert.Nil(t, xml.Unmarshal(w.Body.Bytes(), result))
+	assert.Equal(t, []s3Deleted{{Key: "a b"}, {Key: "missing"}}, result.Deleted)
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, "locked", result.Errors[0].Key)
+	assert.Equal(t, "AccessDenied", result.Errors[0].Code)
+
+	w = deleteRequest(`<Delete><Quiet>true</Quiet><Object><Key>a</Key></Object><Object><Key>locked</Key></Object></Delete>`)
+	assert.Equal(t, 200, w.Code)
+	result = &s3DeleteResult{}
+	assert.Nil(t, xml.Unmarshal(w.Body.Bytes(), result))
+	assert.Equal(t, 0, len(result.Deleted))
+	assert.Equal(t, 1, len(result.Errors))
+
+	assert.Equal(t, 400, deleteRequest(`<Delete>`).Code)
+	assert.Equal(t, 400, deleteRequest(`<Delete></Delete>`).Code)
+	assert.Equal(t, 400, deleteRequest(`<Delete>`+strings.Repeat(`<Object><Key>a</Key></Object>`, s3DeleteMaxKeys+1)+`</Delete>`).Code)
+}
+
+func TestS3CopySourcePrecondition(t *testing.T) {
+	srcHeader := http.Header{"Etag": {"abc"}, "Last-Modified": {"Thu, 05 Jul 2018 18:16:09 GMT"}}
+	check := func(headers ...string) bool {
+		r := httptest.NewRequest("PUT", "/bucket/obj", nil)
+		for i := 0; i < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		return s3CopySourcePrecondition(r, srcHeader)
+	}
+	assert.True(t, check())
+	assert.True(t, check("X-Amz-Copy-Source-If-Match", `"abc"`))
+	assert.False(t, check("X-Amz-Copy-Source-If-Match", `"def"`))
+	assert.True(t, check("X-Amz-Copy-Source-If-None-Match", `"def"`))
+	assert.False(t, check("X-Amz-Copy-Source-If-None-Match", `"def", "abc"`))
+	assert.True(t, check("X-Amz-Copy-Source-If-Modified-Since", "Wed, 04 Jul 2018 18:16:09 GMT"))
+	assert.False(t, check("X-Amz-Copy-Source-If-Modified-Since", "Thu, 05 Jul 2018 18:16:09 GMT"))
+	assert.True(t, check("X-Amz-Copy-Source-If-Unmodified-Since", "Thu, 05 Jul 2018 18:16:09 GMT"))
+	assert.False(t, check("X-Amz-Copy-Source-If-Unmodified-Since", "Wed, 04 Jul 2018 18:16:09 GMT"))
+	// A matching if-match wins over a failed if-unmodified-since.
+	assert.True(t, check("X-Amz-Copy-Source-If-Match", `"abc"`,
+		"X-Amz-Copy-Source-If-Unmodified-Since", "Wed, 04 Jul 2018 18:16:09 GMT"))
+	// A failed if-none-match wins over a satisfied if-modified-since.
+	assert.False(t, check("X-Amz-Copy-Source-If-None-Match", `"abc"`,
+		"X-Amz-Copy-Source-If-Modified-Since", "Wed, 04 Jul 2018 18:16:09 GMT"))
+}
+
+func TestS3CopyObject(t *testing.T) {
+	var copyReq *http.Request
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "HEAD":
+			if r.URL.Path != "/v1/AUTH_test/src/a b" {
+				w.WriteHeader(404)
+				return
+			}
+			w.Header().Set("Etag", "abc")
+			w.Header().Set("Last-Modified", "Thu, 05 Jul 2018 18:16:09 GMT")
+			w.WriteHeader(200)
+		case "COPY":
+			copyReq = r
+			w.Header().Set("Etag", "abc")
+			w.Header().Set("Last-Modified", "Fri, 06 Jul 2018 18:16:09 GMT")
+			w.WriteHeader(201)
+		}
+	})
+	ctx := &ProxyContext{
+		ProxyContextMiddleware: &ProxyContextMiddleware{next: next},
+		Logger:                 zap.NewNop(),
+		S3Auth:                 &S3AuthInfo{Account: "test"},
+	}
+	copyRequest := func(headers ...string) *httptest.ResponseRecorder {
+		copyReq = nil
+		s := &s3ApiHandler{ctx: ctx, account: "test", container: "dst", object: "obj", path: "/v1/AUTH_test/dst/obj"}
+		r := httptest.NewRequest("PUT", "/dst/obj", nil)
+		r = r.WithContext(context.WithValue(r.Context(), "proxycontext", ctx))
+		for i := 0; i < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		s.handleObjectRequest(newS3ResponseWriterWrapper(w, r), r)
+		return w
+	}
+
+	w := copyRequest("X-Amz-Copy-Source", "/src/a%20b", "X-Amz-Meta-Color", "blue")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "/v1/AUTH_test/src/a b", copyReq.URL.Path)
+	assert.Equal(t, "/dst/obj", copyReq.Header.Get("Destination"))
+	assert.Equal(t, "", copyReq.Header.Get("X-Fresh-Metadata"))
+	assert.Equal(t, "", copyReq.Header.Get("X-Object-Meta-Color"))
+	result := &s3CopyObject{}
+	assert.Nil(t, xml.Unmarshal(w.Body.Bytes(), result))
+	assert.Equal(t, `"abc"`, result.ETag)
+	assert.Equal(t, "2018-07-06T18:16:09.000Z", result.LastModified)
+
+	w = copyRequest("X-Amz-Copy-Source", "src/a%20b", "X-Amz-Metadata-Directive", "REPLACE",
+		"X-Amz-Meta-Color", "blue", "Content-Type", "text/plain")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "true", copyReq.Header.Get("X-Fresh-Metadata"))
+	assert.Equal(t, "blue", copyReq.Header.Get("X-Object-Meta-Color"))
+	assert.Equal(t, "text/plain", copyReq.Header.Get("Content-Type"))
+
+	w = copyRequest("X-Amz-Copy-Source", "/src/a%20b", "X-Amz-Copy-Source-If-Match", "def")
+	assert.Equal(t, 412, w.Code)
+	assert.Nil(t, copyReq)
+
+	assert.Equal(t, 404, copyRequest("X-Amz-Copy-Source", "/src/missing").Code)
+	assert.Equal(t, 400, copyRequest("X-Amz-Copy-Source", "/src/a%20b", "X-Amz-Metadata-Directive", "MERGE").Code)
+	assert.Equal(t, 400, copyRequest("X-Amz-Copy-Source", "/dst/obj").Code)
+}
+
+func TestS3ObjectMetadata(t *testing.T) {
+	stored := http.Header{}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PUT":
+			for k, v := range r.Header {
+				if strings.HasPrefix(k, "X-Object-Meta-") {
+					stored[k] = v
+				}
+			}
+			w.Header().Set("Etag", "abc")
+			w.WriteHeader(201)
+		case "HEAD":
+			for k, v := range stored {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(200)
+		}
+	})
+	ctx := &ProxyContext{
+		ProxyContextMiddleware: &ProxyContextMiddleware{next: next},
+		Logger:                 zap.NewNop(),
+		S3Auth:                 &S3AuthInfo{Account: "test"},
+	}
+	request := func(method string, headers ...string) *httptest.ResponseRecorder {
+		s := &s3ApiHandler{ctx: ctx, account: "test", container: "c", object: "o", path: "/v1/AUTH_test/c/o"}
+		r := httptest.NewRequest(method, "/c/o", nil)
+		r = r.WithContext(context.WithValue(r.Context(), "proxycontext", ctx))
+		for i := 0; i < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		s.handleObjectRequest(newS3ResponseWriterWrapper(w, r), r)
+		return w
+	}
+
+	w := request("PUT", "X-Amz-Meta-Color", "blue", "X-Amz-Meta-Name", "=?UTF-8?B?Y2Fmw6k=?=")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "blue", stored.Get("X-Object-Meta-Color"))
+	assert.Equal(t, "café", stored.Get("X-Object-Meta-Name"))
+
+	w = request("HEAD")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "blue", w.Header().Get("X-Amz-Meta-Color"))
+	assert.Equal(t, "=?UTF-8?b?Y2Fmw6k=?=", w.Header().Get("X-Amz-Meta-Name"))
+	assert.Equal(t, "", w.Header().Get("X-Object-Meta-Name"))
+}
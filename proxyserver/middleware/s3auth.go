@@ -25,6 +25,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/troubling/hummingbird/common"
 	"github.com/troubling/hummingbird/common/conf"
 	"github.com/uber-go/tally"
 )
@@ -34,6 +35,9 @@ type S3AuthInfo struct {
 	Signature    string
 	StringToSign string
 	Account      string
+	// Anonymous is set for unsigned requests mapped to the anonymous_account,
+	// which may only read from buckets with public read ACLs.
+	Anonymous bool
 }
 
 var S3Subresources = map[string]bool{
@@ -78,9 +82,85 @@ func (s *S3AuthInfo) validateSignature(secret []byte) bool {
 }
 
 type s3AuthHandler struct {
-	next           http.Handler
-	ctx            *ProxyContext
-	requestsMetric tally.Counter
+	next             http.Handler
+	ctx              *ProxyContext
+	requestsMetric   tally.Counter
+	anonymousAccount string
+	// adminPaths are the first path segments of the proxy's admin routes,
+	// which are never taken as anonymous S3.
+	adminPaths map[string]bool
+}
+
+// s3NonBucketPaths are first path segments that belong to Swift or the proxy
+// itself, so unsigned requests for them are never taken as anonymous S3.
+var s3NonBucketPaths = map[string]bool{
+	"v1":              true,
+	"V1":              true,
+	"auth":            true,
+	"info":            true,
+	"healthcheck":     true,
+	"crossdomain.xml": true,
+}
+
+// s3ProxyAdminPaths are the first path segments of the proxy's admin routes
+// when obfuscated_prefix is "-", which puts them at the top level.
+var s3ProxyAdminPaths = []string{
+	"metrics", "loglevel", "loglevels", "requeststats", "devicehealth", "policies",
+	"recon", "reload", "restore", "debug", "endpoints",
+}
+
+// s3AdminPaths returns the first path segments the proxy's admin routes are
+// under for the given obfuscated_prefix.
+func s3AdminPaths(obfuscatedPrefix string) map[string]bool {
+	paths := map[string]bool{}
+	switch obfuscatedPrefix {
+	case "":
+	case "-":
+		for _, p := range s3ProxyAdminPaths {
+			paths[p] = true
+		}
+	default:
+		paths[strings.Trim(obfuscatedPrefix, "/")] = true
+	}
+	return paths
+}
+
+// s3AnonymousAuthorize only allows reads that the container's read ACL
+// grants to everyone, such as ".r:*" or ".r:*,.rlistings".
+func s3AnonymousAuthorize(r *http.Request) (bool, int) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false, http.StatusForbidden
+	}
+	pathParts, err := common.ParseProxyPath(r.URL.Path)
+	if err != nil || pathParts["container"] == "" {
+		return false, http.StatusForbidden
+	}
+	referrers, roles := ParseACL(GetProxyContext(r).ACL)
+	if ok, _ := AuthorizeUnconfirmedIdentity(r, pathParts["object"], referrers, roles); ok {
+		return true, http.StatusOK
+	}
+	return false, http.StatusForbidden
+}
+
+// serveAnonymous handles unsigned requests as the anonymous account, if one
+// is configured, returning false if the request isn't an anonymous S3 read.
+func (s *s3AuthHandler) serveAnonymous(writer http.ResponseWriter, request *http.Request) bool {
+	ctx := GetProxyContext(request)
+	if s.anonymousAccount == "" || ctx.S3Auth != nil || ctx.Authorize != nil ||
+		request.Header.Get("X-Auth-Token") != "" || request.Header.Get("X-Storage-Token") != "" {
+		return false
+	}
+	if request.Method != "GET" && request.Method != "HEAD" {
+		return false
+	}
+	bucket, _ := s3PathSplit(request.URL.Path)
+	if bucket == "" || s3NonBucketPaths[bucket] || s.adminPaths[bucket] || !validBucketName(bucket) {
+		return false
+	}
+	ctx.S3Auth = &S3AuthInfo{Account: s.anonymousAccount, Anonymous: true}
+	ctx.Authorize = s3AnonymousAuthorize
+	s.next.ServeHTTP(newS3ResponseWriterWrapper(writer, request), request)
+	return true
 }
 
 func (s *s3AuthHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
@@ -110,6 +190,9 @@ func (s *s3AuthHandler) ServeHTTP(writer http.ResponseWriter, request *http.Requ
 		signature = request.FormValue("Signature")
 	}
 	if key == "" || signature == "" || ctx.S3Auth != nil {
+		if key == "" && signature == "" && s.serveAnonymous(writer, request) {
+			return
+		}
 		// Not an S3 request or already processed
 		s.next.ServeHTTP(writer, request)
 		return
@@ -186,14 +269,16 @@ func NewS3Auth(config conf.Section, metricsScope tally.Scope) (func(http.Handler
 			})
 		}, nil
 	}
-	RegisterInfo("s3Auth", map[string]interface{}{})
-	return s3Auth(metricsScope.Counter("s3Auth_requests")), nil
+	anonymousAccount := config.GetDefault("anonymous_account", "")
+	obfuscatedPrefix, _ := config.GetConfig().Get("app:proxy-server", "obfuscated_prefix")
+	RegisterInfo("s3Auth", map[string]interface{}{"anonymous_access": anonymousAccount != ""})
+	return s3Auth(metricsScope.Counter("s3Auth_requests"), anonymousAccount, s3AdminPaths(obfuscatedPrefix)), nil
 }
 
-func s3Auth(requestsMetric tally.Counter) func(next http.Handler) http.Handler {
+func s3Auth(requestsMetric tally.Counter, anonymousAccount string, adminPaths map[string]bool) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-			(&s3AuthHandler{next: next, requestsMetric: requestsMetric}).ServeHTTP(writer, request)
+			(&s3AuthHandler{next: next, requestsMetric: requestsMetric, anonymousAccount: anonymousAccount, adminPaths: adminPaths}).ServeHTTP(writer, request)
 		})
 	}
 }
@@ -0,0 +1,83 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestS3AnonymousAuthorize(t *testing.T) {
+	authorize := func(method, path, acl string) bool {
+		r := httptest.NewRequest(method, path, nil)
+		r = r.WithContext(context.WithValue(r.Context(), "proxycontext", &ProxyContext{ACL: acl}))
+		ok, _ := s3AnonymousAuthorize(r)
+		return ok
+	}
+	require.True(t, authorize("GET", "/v1/AUTH_test/c/o", ".r:*"))
+	require.True(t, authorize("HEAD", "/v1/AUTH_test/c/o", ".r:*"))
+	require.False(t, authorize("GET", "/v1/AUTH_test/c/o", ""))
+	require.False(t, authorize("PUT", "/v1/AUTH_test/c/o", ".r:*"))
+	require.False(t, authorize("GET", "/v1/AUTH_test/c", ".r:*"))
+	require.True(t, authorize("GET", "/v1/AUTH_test/c", ".r:*,.rlistings"))
+	require.False(t, authorize("GET", "/v1/AUTH_test", ".r:*,.rlistings"))
+}
+
+func TestS3AuthAnonymous(t *testing.T) {
+	serveWithPrefix := func(anonymousAccount, obfuscatedPrefix, method, path string, headers ...string) *ProxyContext {
+		var seen *ProxyContext
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = GetProxyContext(r)
+		})
+		ctx := &ProxyContext{Logger: zap.NewNop()}
+		r := httptest.NewRequest(method, path, nil)
+		r = r.WithContext(context.WithValue(r.Context(), "proxycontext", ctx))
+		for i := 0; i < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		s3Auth(nil, anonymousAccount, s3AdminPaths(obfuscatedPrefix))(next).ServeHTTP(httptest.NewRecorder(), r)
+		require.NotNil(t, seen)
+		return seen
+	}
+	serve := func(anonymousAccount, method, path string, headers ...string) *ProxyContext {
+		return serveWithPrefix(anonymousAccount, "", method, path, headers...)
+	}
+	ctx := serve("test", "GET", "/bucket/obj")
+	require.NotNil(t, ctx.S3Auth)
+	require.True(t, ctx.S3Auth.Anonymous)
+	require.Equal(t, "test", ctx.S3Auth.Account)
+	require.NotNil(t, ctx.Authorize)
+
+	require.Nil(t, serve("", "GET", "/bucket/obj").S3Auth)
+	require.Nil(t, serve("test", "PUT", "/bucket/obj").S3Auth)
+	require.Nil(t, serve("test", "GET", "/v1/AUTH_test/c/o").S3Auth)
+	require.Nil(t, serve("test", "GET", "/info").S3Auth)
+	require.Nil(t, serve("test", "GET", "/").S3Auth)
+	require.Nil(t, serve("test", "GET", "/bucket/obj", "X-Auth-Token", "AUTH_tk").S3Auth)
+	// The proxy's admin routes are left to the router.
+	require.Nil(t, serveWithPrefix("test", "haio", "GET", "/haio/metrics").S3Auth)
+	require.NotNil(t, serveWithPrefix("test", "haio", "GET", "/metrics/obj").S3Auth)
+	require.Nil(t, serveWithPrefix("test", "-", "GET", "/metrics").S3Auth)
+	require.Nil(t, serveWithPrefix("test", "-", "GET", "/endpoints/AUTH_test/c/o").S3Auth)
+	ctx = serve("test", "GET", "/bucket/obj", "Authorization", "AWS test:tester:sig")
+	require.NotNil(t, ctx.S3Auth)
+	require.False(t, ctx.S3Auth.Anonymous)
+}
@@ -0,0 +1,88 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// s3EtagSysmeta holds the S3 ETag of an object completed from a
+	// multipart upload: the MD5 of its parts' MD5s, then "-" and the number
+	// of parts. SDKs check for that form rather than the SLO's own ETag.
+	s3EtagSysmeta       = "X-Object-Sysmeta-S3-Etag"
+	s3PartsCountHeader  = "X-Amz-Mp-Parts-Count"
+	s3InvalidPartNumber = 41600
+)
+
+// s3MultipartEtag returns the S3 ETag of an object made of parts with the
+// given ETags, in order.
+func s3MultipartEtag(partEtags []string) (string, error) {
+	hash := md5.New()
+	for _, etag := range partEtags {
+		sum, err := hex.DecodeString(strings.Trim(etag, "\""))
+		if err != nil || len(sum) != md5.Size {
+			return "", fmt.Errorf("invalid part ETag: %s", etag)
+		}
+		hash.Write(sum)
+	}
+	return fmt.Sprintf("%x-%d", hash.Sum(nil), len(partEtags)), nil
+}
+
+// partRange returns the Range header covering one part of the object, and
+// how many parts the object has. An object that wasn't uploaded in parts has
+// only its whole self as part 1, given as an empty Range and 0 parts. The
+// status is the one to fail with, or 200.
+func (s *s3ApiHandler) partRange(request *http.Request, partNumber int) (string, int, int) {
+	ctx := GetProxyContext(request)
+	newReq, err := ctx.newSubrequest("GET", s.path+"?multipart-manifest=get", http.NoBody, request, "s3api")
+	if err != nil {
+		return "", 0, http.StatusInternalServerError
+	}
+	cap := NewCaptureWriter()
+	ctx.serveHTTPSubrequest(cap, newReq)
+	if cap.status/100 != 2 {
+		return "", 0, cap.status
+	}
+	if cap.Header().Get("X-Static-Large-Object") != "True" {
+		if partNumber != 1 {
+			return "", 0, s3InvalidPartNumber
+		}
+		return "", 0, http.StatusOK
+	}
+	var manifest []segItem
+	if err := json.Unmarshal(cap.body, &manifest); err != nil {
+		return "", 0, http.StatusInternalServerError
+	}
+	if partNumber > len(manifest) {
+		return "", 0, s3InvalidPartNumber
+	}
+	var start int64
+	for _, si := range manifest[:partNumber-1] {
+		length, _ := si.segLenHash()
+		start += length
+	}
+	length, _ := manifest[partNumber-1].segLenHash()
+	if length == 0 {
+		return "", len(manifest), s3InvalidPartNumber
+	}
+	return fmt.Sprintf("bytes=%d-%d", start, start+length-1), len(manifest), http.StatusOK
+}
@@ -0,0 +1,85 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestS3MultipartEtag(t *testing.T) {
+	etag, err := s3MultipartEtag([]string{"\"0cc175b9c0f1b6a831c399e269772661\"", "92eb5ffee6ae2fec3ad71c777531578f"})
+	require.Nil(t, err)
+	require.Equal(t, "96e024ba2074fe77e8e965ba43a704be-2", etag)
+	_, err = s3MultipartEtag([]string{"nope"})
+	require.NotNil(t, err)
+}
+
+func TestS3PartNumber(t *testing.T) {
+	var gotRange string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("multipart-manifest") == "get" {
+			manifest, _ := json.Marshal([]segItem{{Name: "/c+segments/u-o/00000001", Bytes: 5}, {Name: "/c+segments/u-o/00000002", Bytes: 3}})
+			w.Header().Set("X-Static-Large-Object", "True")
+			w.WriteHeader(200)
+			w.Write(manifest)
+			return
+		}
+		gotRange = r.Header.Get("Range")
+		w.Header().Set("Etag", "\"slo-etag\"")
+		w.Header().Set(s3EtagSysmeta, "96e024ba2074fe77e8e965ba43a704be-2")
+		w.WriteHeader(206)
+	})
+	ctx := &ProxyContext{
+		ProxyContextMiddleware: &ProxyContextMiddleware{next: next},
+		Logger:                 zap.NewNop(),
+		S3Auth:                 &S3AuthInfo{Account: "test"},
+	}
+	request := func(query string, headers ...string) *httptest.ResponseRecorder {
+		s := &s3ApiHandler{ctx: ctx, account: "test", container: "c", object: "o", path: "/v1/AUTH_test/c/o"}
+		r := httptest.NewRequest("GET", "/c/o"+query, nil)
+		r = r.WithContext(context.WithValue(r.Context(), "proxycontext", ctx))
+		for i := 0; i < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		s.handleObjectRequest(newS3ResponseWriterWrapper(w, r), r)
+		return w
+	}
+
+	w := request("?partNumber=2")
+	require.Equal(t, 206, w.Code)
+	require.Equal(t, "bytes=5-7", gotRange)
+	require.Equal(t, "\"96e024ba2074fe77e8e965ba43a704be-2\"", w.Header().Get("Etag"))
+	require.Equal(t, "2", w.Header().Get(s3PartsCountHeader))
+
+	w = request("")
+	require.Equal(t, "", gotRange)
+	require.Equal(t, "\"96e024ba2074fe77e8e965ba43a704be-2\"", w.Header().Get("Etag"))
+	require.Equal(t, "", w.Header().Get(s3PartsCountHeader))
+
+	w = request("?partNumber=3")
+	require.Equal(t, 416, w.Code)
+	require.Contains(t, w.Body.String(), "InvalidPartNumber")
+	require.Equal(t, 400, request("?partNumber=1", "Range", "bytes=0-1").Code)
+	require.Equal(t, 400, request("?partNumber=0").Code)
+}
@@ -0,0 +1,417 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/srv"
+)
+
+type s3ListVersionsResult struct {
+	XMLName             xml.Name         `xml:"ListVersionsResult"`
+	Xmlns               string           `xml:"xmlns,attr"`
+	Name                string           `xml:"Name"`
+	Prefix              string           `xml:"Prefix"`
+	KeyMarker           string           `xml:"KeyMarker"`
+	VersionIdMarker     string           `xml:"VersionIdMarker"`
+	NextKeyMarker       string           `xml:"NextKeyMarker,omitempty"`
+	NextVersionIdMarker string           `xml:"NextVersionIdMarker,omitempty"`
+	MaxKeys             int              `xml:"MaxKeys"`
+	Delimiter           string           `xml:"Delimiter,omitempty"`
+	IsTruncated         bool             `xml:"IsTruncated"`
+	Entries             []s3VersionEntry // Version and DeleteMarker elements, in order
+	Prefixes            []s3Prefix       `xml:"CommonPrefixes,omitempty"`
+}
+
+// s3VersionEntry is a Version or, when XMLName says so, a DeleteMarker,
+// which leaves out the ETag, Size and StorageClass.
+type s3VersionEntry struct {
+	XMLName      xml.Name
+	Key          string   `xml:"Key"`
+	VersionId    string   `xml:"VersionId"`
+	IsLatest     bool     `xml:"IsLatest"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag,omitempty"`
+	Size         *int64   `xml:"Size,omitempty"`
+	Owner        *s3Owner `xml:"Owner,omitempty"`
+	StorageClass string   `xml:"StorageClass,omitempty"`
+}
+
+// s3Version is one version of a key: the bucket's current object, or one
+// archived in the bucket's versions container by versioned_writes.
+type s3Version struct {
+	id           string
+	lastModified string
+	etag         string
+	size         int64
+	deleteMarker bool
+}
+
+// s3ParseVersionName splits a versions container object name, which
+// versioned_writes makes from the key's length in hex, the key, and the
+// archived object's timestamp.
+func s3ParseVersionName(name string) (string, string, bool) {
+	if len(name) < 3 {
+		return "", "", false
+	}
+	length, err := strconv.ParseInt(name[:3], 16, 64)
+	if err != nil || int64(len(name)) < 4+length || name[3+length] != '/' {
+		return "", "", false
+	}
+	return name[3 : 3+length], name[4+length:], true
+}
+
+// s3ListingVersionId is the version id of a current object, which is its
+// timestamp, recovered from the listing's last_modified. It's the same name
+// the object is given in the versions container when it's replaced.
+func s3ListingVersionId(lastModified string) string {
+	t, err := time.Parse("2006-01-02T15:04:05.000000", lastModified)
+	if err != nil {
+		return "null"
+	}
+	return common.CanonicalTimestampFromTime(t)
+}
+
+// listingPage fetches one page of a container listing, returning a nil
+// listing and the status if it failed.
+func (s *s3ApiHandler) listingPage(request *http.Request, path string, query url.Values, auth AuthorizeFunc) ([]ObjectListingRecord, int) {
+	ctx := GetProxyContext(request)
+	newReq, err := ctx.newSubrequest("GET", path+"?"+query.Encode(), http.NoBody, request, "s3api")
+	if err != nil {
+		return nil, http.StatusInternalServerError
+	}
+	newReq.Header.Set("Accept", "application/json")
+	if auth != nil {
+		GetProxyContext(newReq).Authorize = auth
+	}
+	cap := NewCaptureWriter()
+	ctx.serveHTTPSubrequest(cap, newReq)
+	if cap.status/100 != 2 {
+		return nil, cap.status
+	}
+	listing := []ObjectListingRecord{}
+	if cap.status != http.StatusNoContent {
+		if err := json.Unmarshal(cap.body, &listing); err != nil {
+			return nil, http.StatusInternalServerError
+		}
+	}
+	return listing, cap.status
+}
+
+// s3VersionPrefix is the prefix versioned_writes gives the names key's
+// versions are archived under in the versions container.
+func s3VersionPrefix(key string) string {
+	return fmt.Sprintf("%03x%s/", len(key), key)
+}
+
+// archivedVersions lists a page of the versions archived for keys past the
+// key marker, by key. Archived names sort by key length before key, so each
+// key length is listed from the marker on its own, a page apiece. If a length
+// had more than a page, its last key's newer versions weren't read, and that
+// key is returned as the one the page has to stop at.
+func (s *s3ApiHandler) archivedVersions(request *http.Request, path, prefix, keyMarker string, limit int) (map[string][]s3Version, string, int) {
+	archived := map[string][]s3Version{}
+	stopKey := ""
+	start := prefix
+	if keyMarker > start {
+		start = keyMarker
+	}
+	length := len(prefix)
+	if length == 0 {
+		length = 1
+	}
+	for length <= 0xfff {
+		marker := fmt.Sprintf("%03x%s", length, start)
+		if start == keyMarker && length == len(keyMarker) {
+			// Past all of the key marker's versions; "0" sorts just after "/".
+			marker += "0"
+		}
+		vq := url.Values{"format": {"json"}, "limit": {strconv.Itoa(limit)}, "marker": {marker}}
+		page, status := s.listingPage(request, path, vq, okAuthFunc)
+		if page == nil {
+			if status == 404 {
+				break
+			}
+			return nil, "", status
+		}
+		if len(page) == 0 {
+			break
+		}
+		if len(page[0].Name) < 3 {
+			return nil, "", http.StatusInternalServerError
+		}
+		if l, err := strconv.ParseInt(page[0].Name[:3], 16, 64); err != nil || int(l) < length {
+			return nil, "", http.StatusInternalServerError
+		} else if int(l) > length {
+			// Nothing more at this length; list the next one from the marker.
+			length = int(l)
+			continue
+		}
+		next, read := length+1, 0
+		for _, o := range page {
+			key, id, ok := s3ParseVersionName(o.Name)
+			if ok && len(key) != length {
+				next = len(key)
+				break
+			}
+			read++
+			if !ok || !strings.HasPrefix(key, prefix) || key <= keyMarker {
+				continue
+			}
+			archived[key] = append(archived[key], s3Version{
+				id:           id,
+				lastModified: s3DateString(o.LastModified),
+				etag:         "\"" + o.ETag + "\"",
+				size:         o.Size,
+				deleteMarker: o.ContentType == DELETE_MARKER_CONTENT_TYPE,
+			})
+		}
+		if read == limit {
+			if key, _, ok := s3ParseVersionName(page[len(page)-1].Name); ok && strings.HasPrefix(key, prefix) && key > keyMarker && (stopKey == "" || key < stopKey) {
+				stopKey = key
+			}
+		}
+		length = next
+	}
+	for _, vs := range archived {
+		sort.Slice(vs, func(i, j int) bool { return vs[i].id > vs[j].id })
+	}
+	return archived, stopKey, http.StatusOK
+}
+
+// keyVersions lists a page of key's archived versions older than
+// versionIdMarker, if given, newest first.
+func (s *s3ApiHandler) keyVersions(request *http.Request, path, key, versionIdMarker string, limit int) ([]s3Version, int) {
+	vq := url.Values{"format": {"json"}, "limit": {strconv.Itoa(limit)}, "prefix": {s3VersionPrefix(key)}, "reverse": {"true"}}
+	if versionIdMarker != "" {
+		vq.Set("marker", s3VersionPrefix(key)+versionIdMarker)
+	}
+	page, status := s.listingPage(request, path, vq, okAuthFunc)
+	if page == nil && status != 404 {
+		return nil, status
+	}
+	var vs []s3Version
+	for _, o := range page {
+		if _, id, ok := s3ParseVersionName(o.Name); ok {
+			vs = append(vs, s3Version{
+				id:           id,
+				lastModified: s3DateString(o.LastModified),
+				etag:         "\"" + o.ETag + "\"",
+				size:         o.Size,
+				deleteMarker: o.ContentType == DELETE_MARKER_CONTENT_TYPE,
+			})
+		}
+	}
+	return vs, http.StatusOK
+}
+
+// handleListObjectVersions lists the bucket's current objects along with the
+// versions and delete markers in its versions container, each key's newest
+// first. Both listings start from the key and version id markers, so only a
+// page of each is read.
+func (s *s3ApiHandler) handleListObjectVersions(writer http.ResponseWriter, request *http.Request) {
+	ctx := GetProxyContext(request)
+	q := request.URL.Query()
+	maxKeys := 1000
+	if v := q.Get("max-keys"); v != "" {
+		var err error
+		if maxKeys, err = strconv.Atoi(v); err != nil || maxKeys < 0 {
+			srv.StandardResponse(writer, http.StatusBadRequest)
+			return
+		}
+		if maxKeys > 1000 {
+			maxKeys = 1000
+		}
+	}
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	keyMarker, versionIdMarker := q.Get("key-marker"), q.Get("version-id-marker")
+	if versionIdMarker != "" && keyMarker == "" {
+		srv.StandardResponse(writer, http.StatusBadRequest)
+		return
+	}
+
+	// Each key has at least one entry, so maxKeys+1 current objects are
+	// enough to fill the page.
+	cq := url.Values{"format": {"json"}, "limit": {strconv.Itoa(maxKeys + 1)}}
+	if prefix != "" {
+		cq.Set("prefix", prefix)
+	}
+	if keyMarker != "" {
+		cq.Set("marker", keyMarker)
+	}
+	current, status := s.listingPage(request, s.path, cq, nil)
+	if current == nil {
+		if status == 404 {
+			NoSuchBucketResponse(writer, request)
+		} else {
+			srv.StandardResponse(writer, status)
+		}
+		return
+	}
+	// Keys past the last current object listed are left for the next page.
+	lastKey := ""
+	if len(current) > maxKeys {
+		lastKey = current[len(current)-1].Name
+	}
+	versions := map[string][]s3Version{}
+	for _, o := range current {
+		versions[o.Name] = append(versions[o.Name], s3Version{
+			id:           s3ListingVersionId(o.LastModified),
+			lastModified: s3DateString(o.LastModified),
+			etag:         "\"" + o.ETag + "\"",
+			size:         o.Size,
+		})
+	}
+
+	ci, err := ctx.C.GetContainerInfo(request.Context(), "AUTH_"+s.account, s.container)
+	if err != nil {
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	stopKey := ""
+	if versionsContainer := ci.SysMetadata["Versions-Location"]; versionsContainer != "" {
+		path := fmt.Sprintf("/v1/AUTH_%s/%s", s.account, versionsContainer)
+		var archived map[string][]s3Version
+		if archived, stopKey, status = s.archivedVersions(request, path, prefix, keyMarker, maxKeys+1); archived == nil {
+			srv.StandardResponse(writer, status)
+			return
+		}
+		// The key marker's remaining versions are older than the version id
+		// marker, so they sort before it.
+		if versionIdMarker != "" {
+			vs, status := s.keyVersions(request, path, keyMarker, versionIdMarker, maxKeys+1)
+			if vs == nil && status/100 != 2 {
+				srv.StandardResponse(writer, status)
+				return
+			}
+			if len(vs) > 0 {
+				archived[keyMarker] = vs
+			}
+		}
+		if stopKey != "" {
+			first := true
+			for key := range versions {
+				first = first && key >= stopKey
+			}
+			for key := range archived {
+				first = first && key >= stopKey
+			}
+			if first {
+				// The page starts with a key with more versions than a
+				// page holds; list them from the newest.
+				vs, status := s.keyVersions(request, path, stopKey, "", maxKeys+1)
+				if vs == nil && status/100 != 2 {
+					srv.StandardResponse(writer, status)
+					return
+				}
+				archived[stopKey] = vs
+			} else {
+				delete(archived, stopKey)
+				delete(versions, stopKey)
+			}
+		}
+		for key, vs := range archived {
+			versions[key] = append(versions[key], vs...)
+		}
+	}
+
+	keys := make([]string, 0, len(versions))
+	for key := range versions {
+		// Keys past the ends of the listings are left for the next page.
+		if (lastKey == "" || key <= lastKey) && (stopKey == "" || key <= stopKey) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	result := &s3ListVersionsResult{
+		Xmlns:           s3Xmlns,
+		Name:            s.container,
+		Prefix:          prefix,
+		KeyMarker:       keyMarker,
+		VersionIdMarker: versionIdMarker,
+		MaxKeys:         maxKeys,
+		Delimiter:       delimiter,
+		IsTruncated:     len(keys) > 0 && (lastKey != "" || stopKey != ""),
+	}
+	owner := &s3Owner{ID: ctx.S3Auth.Account, DisplayName: ctx.S3Auth.Account}
+	count := 0
+	lastPrefix := ""
+	for _, key := range keys {
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				cp := key[:len(prefix)+i+len(delimiter)]
+				if cp == lastPrefix || strings.HasPrefix(keyMarker, cp) {
+					continue
+				}
+				if count == maxKeys {
+					result.IsTruncated = true
+					break
+				}
+				lastPrefix = cp
+				result.Prefixes = append(result.Prefixes, s3Prefix{Prefix: cp})
+				result.NextKeyMarker, result.NextVersionIdMarker = cp, ""
+				count++
+				continue
+			}
+		}
+		for i, v := range versions[key] {
+			if count == maxKeys {
+				result.IsTruncated = true
+				break
+			}
+			// Only the newest version is latest, and a later page may start
+			// partway through a key's versions, after its current object.
+			latest := i == 0 && (key != keyMarker || versionIdMarker == "")
+			entry := s3VersionEntry{
+				XMLName:      xml.Name{Local: "Version"},
+				Key:          key,
+				VersionId:    v.id,
+				IsLatest:     latest,
+				LastModified: v.lastModified,
+				Owner:        owner,
+			}
+			if v.deleteMarker {
+				entry.XMLName.Local = "DeleteMarker"
+			} else {
+				size := v.size
+				entry.ETag, entry.Size, entry.StorageClass = v.etag, &size, "STANDARD"
+			}
+			result.Entries = append(result.Entries, entry)
+			result.NextKeyMarker, result.NextVersionIdMarker = key, v.id
+			count++
+		}
+		if count == maxKeys && result.IsTruncated {
+			break
+		}
+	}
+	if !result.IsTruncated {
+		result.NextKeyMarker, result.NextVersionIdMarker = "", ""
+	} else if result.NextKeyMarker == "" {
+		// Everything on the page was rolled into prefixes already listed.
+		result.NextKeyMarker = keys[len(keys)-1]
+	}
+	writeS3XML(writer, result)
+}
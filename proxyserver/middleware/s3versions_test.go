@@ -0,0 +1,227 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/client"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/common/test"
+	"go.uber.org/zap"
+)
+
+func TestS3ParseVersionName(t *testing.T) {
+	key, id, ok := s3ParseVersionName("003a/b/1530000000.00000")
+	require.True(t, ok)
+	require.Equal(t, "a/b", key)
+	require.Equal(t, "1530000000.00000", id)
+	_, _, ok = s3ParseVersionName("004a/b/1530000000.00000")
+	require.False(t, ok)
+	_, _, ok = s3ParseVersionName("zzza")
+	require.False(t, ok)
+	require.Equal(t, "1530814569.29589", s3ListingVersionId("2018-07-05T18:16:09.295890"))
+}
+
+type testS3ListVersionsResult struct {
+	IsTruncated         bool
+	NextKeyMarker       string
+	NextVersionIdMarker string
+	Versions            []s3VersionEntry `xml:"Version"`
+	DeleteMarkers       []s3VersionEntry `xml:"DeleteMarker"`
+	Prefixes            []s3Prefix       `xml:"CommonPrefixes"`
+}
+
+// testListing lists records, which are in name order, the way a container
+// server would for query.
+func testListing(records []ObjectListingRecord, q url.Values) []ObjectListingRecord {
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil {
+		limit = 10000
+	}
+	listing := []ObjectListingRecord{}
+	marker, prefix, reverse := q.Get("marker"), q.Get("prefix"), q.Get("reverse") == "true"
+	for i := range records {
+		o := records[i]
+		if reverse {
+			o = records[len(records)-1-i]
+		}
+		if len(listing) == limit {
+			break
+		}
+		if strings.HasPrefix(o.Name, prefix) && (marker == "" || (!reverse && o.Name > marker) || (reverse && o.Name < marker)) {
+			listing = append(listing, o)
+		}
+	}
+	return listing
+}
+
+// testS3ListVersions returns a function making ListObjectVersions requests
+// of a bucket with the current and archived objects given, along with the
+// most versions container entries any request read.
+func testS3ListVersions(t *testing.T, current, archived []ObjectListingRecord) func(query string) (*testS3ListVersionsResult, string, int) {
+	read := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		listing := []ObjectListingRecord{}
+		switch r.URL.Path {
+		case "/v1/AUTH_test/bucket":
+			listing = testListing(current, r.URL.Query())
+		case "/v1/AUTH_test/versions":
+			require.NotEqual(t, "", r.URL.Query().Get("limit"))
+			listing = testListing(archived, r.URL.Query())
+			read += len(listing)
+		default:
+			w.WriteHeader(404)
+			return
+		}
+		body, _ := json.Marshal(listing)
+		w.WriteHeader(200)
+		w.Write(body)
+	})
+	f, err := client.NewProxyClient(staticPolicyList, srv.NewTestConfigLoader(&test.FakeRing{}), nil, "", "", "", "", "", conf.Config{})
+	require.Nil(t, err)
+	ctx := &ProxyContext{
+		ProxyContextMiddleware: &ProxyContextMiddleware{next: next},
+		Logger:                 zap.NewNop(),
+		S3Auth:                 &S3AuthInfo{Account: "test"},
+		C: f.NewRequestClient(nil, map[string]*client.ContainerInfo{
+			"container/AUTH_test/bucket": {SysMetadata: map[string]string{"Versions-Location": "versions"}},
+		}, zap.NewNop()),
+	}
+	return func(query string) (*testS3ListVersionsResult, string, int) {
+		read = 0
+		s := &s3ApiHandler{ctx: ctx, account: "test", container: "bucket", path: "/v1/AUTH_test/bucket"}
+		r := httptest.NewRequest("GET", "/bucket?versions&"+query, nil)
+		r = r.WithContext(context.WithValue(r.Context(), "proxycontext", ctx))
+		w := httptest.NewRecorder()
+		s.handleContainerRequest(w, r)
+		require.Equal(t, 200, w.Code)
+		result := &testS3ListVersionsResult{}
+		require.Nil(t, xml.Unmarshal(w.Body.Bytes(), result))
+		return result, w.Body.String(), read
+	}
+}
+
+func TestS3ListObjectVersions(t *testing.T) {
+	current := []ObjectListingRecord{
+		{Name: "a", LastModified: "2018-07-05T18:16:09.295890", ETag: "aaa", Size: 3},
+		{Name: "c", LastModified: "2018-07-06T18:16:09.295890", ETag: "ccc", Size: 3},
+		{Name: "d/x", LastModified: "2018-07-06T18:16:09.295890", ETag: "xxx", Size: 3},
+		{Name: "d/y", LastModified: "2018-07-06T18:16:09.295890", ETag: "yyy", Size: 3},
+	}
+	archived := []ObjectListingRecord{
+		{Name: "001a/1530000000.00000", LastModified: "2018-06-26T08:00:00.000000", ETag: "old", Size: 2},
+		{Name: "001b/1520000000.00000", LastModified: "2018-03-02T14:13:20.000000", ETag: "bbb", Size: 3},
+		{Name: "001b/1525000000.00000", LastModified: "2018-04-29T11:06:40.000000", ContentType: DELETE_MARKER_CONTENT_TYPE},
+	}
+	listVersions := testS3ListVersions(t, current, archived)
+	list := func(query string) (*testS3ListVersionsResult, string) {
+		result, body, _ := listVersions(query)
+		return result, body
+	}
+
+	result, body := list("delimiter=/")
+	require.False(t, result.IsTruncated)
+	require.Equal(t, 4, len(result.Versions))
+	require.Equal(t, []s3Prefix{{Prefix: "d/"}}, result.Prefixes)
+	require.Equal(t, "a", result.Versions[0].Key)
+	require.Equal(t, "1530814569.29589", result.Versions[0].VersionId)
+	require.True(t, result.Versions[0].IsLatest)
+	require.Equal(t, "1530000000.00000", result.Versions[1].VersionId)
+	require.False(t, result.Versions[1].IsLatest)
+	require.Equal(t, "\"old\"", result.Versions[1].ETag)
+	require.Equal(t, 1, len(result.DeleteMarkers))
+	require.Equal(t, "b", result.DeleteMarkers[0].Key)
+	require.True(t, result.DeleteMarkers[0].IsLatest)
+	require.Nil(t, result.DeleteMarkers[0].Size)
+	require.False(t, result.Versions[2].IsLatest)
+	require.Equal(t, "c", result.Versions[3].Key)
+	// The delete marker is listed between a's and b's versions.
+	require.True(t, strings.Index(body, "<DeleteMarker>") > strings.Index(body, "<ETag>&#34;old&#34;</ETag>"))
+	require.True(t, strings.Index(body, "<DeleteMarker>") < strings.Index(body, "<ETag>&#34;bbb&#34;</ETag>"))
+
+	result, _ = list("max-keys=2")
+	require.True(t, result.IsTruncated)
+	require.Equal(t, 2, len(result.Versions))
+	require.Equal(t, "a", result.NextKeyMarker)
+	require.Equal(t, "1530000000.00000", result.NextVersionIdMarker)
+
+	result, _ = list("max-keys=2&key-marker=a&version-id-marker=1530000000.00000")
+	require.True(t, result.IsTruncated)
+	require.Equal(t, 1, len(result.DeleteMarkers))
+	require.Equal(t, 1, len(result.Versions))
+	require.Equal(t, "b", result.Versions[0].Key)
+	require.Equal(t, "b", result.NextKeyMarker)
+	require.Equal(t, "1520000000.00000", result.NextVersionIdMarker)
+
+	result, _ = list("key-marker=b&version-id-marker=1520000000.00000&prefix=d/")
+	require.False(t, result.IsTruncated)
+	require.Equal(t, 2, len(result.Versions))
+	require.Equal(t, "d/x", result.Versions[0].Key)
+}
+
+func TestS3ListObjectVersionsPages(t *testing.T) {
+	current := []ObjectListingRecord{
+		{Name: "k", LastModified: "2018-07-05T18:16:09.295890", ETag: "kkk", Size: 3},
+	}
+	archived := []ObjectListingRecord{
+		{Name: "001k/1500000000.00000", LastModified: "2017-07-14T02:40:00.000000", ETag: "k0", Size: 2},
+		{Name: "001k/1500000001.00000", LastModified: "2017-07-14T02:40:01.000000", ETag: "k1", Size: 2},
+		{Name: "001k/1500000002.00000", LastModified: "2017-07-14T02:40:02.000000", ETag: "k2", Size: 2},
+		{Name: "001k/1500000003.00000", LastModified: "2017-07-14T02:40:03.000000", ETag: "k3", Size: 2},
+		{Name: "001k/1500000004.00000", LastModified: "2017-07-14T02:40:04.000000", ETag: "k4", Size: 2},
+		{Name: "001m/1500000000.00000", LastModified: "2017-07-14T02:40:00.000000", ContentType: DELETE_MARKER_CONTENT_TYPE},
+		{Name: "002zz/1500000000.00000", LastModified: "2017-07-14T02:40:00.000000", ETag: "zz", Size: 2},
+	}
+	list := testS3ListVersions(t, current, archived)
+	var entries []string
+	query := "max-keys=2"
+	for pages := 0; ; pages++ {
+		require.True(t, pages < 10)
+		result, body, read := list(query)
+		// Each key length is read a page at a time, plus a page of the key
+		// the page starts in.
+		require.True(t, read <= 3*3)
+		for {
+			at := strings.Index(body, "<Key>")
+			if at < 0 {
+				break
+			}
+			body = body[at+len("<Key>"):]
+			key := body[:strings.Index(body, "<")]
+			body = body[strings.Index(body, "<VersionId>")+len("<VersionId>"):]
+			entries = append(entries, key+" "+body[:strings.Index(body, "<")])
+		}
+		if !result.IsTruncated {
+			break
+		}
+		query = "max-keys=2&key-marker=" + result.NextKeyMarker + "&version-id-marker=" + result.NextVersionIdMarker
+	}
+	require.Equal(t, []string{
+		"k 1530814569.29589", "k 1500000004.00000", "k 1500000003.00000", "k 1500000002.00000",
+		"k 1500000001.00000", "k 1500000000.00000", "m 1500000000.00000", "zz 1500000000.00000",
+	}, entries)
+}
@@ -0,0 +1,463 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/uber-go/tally"
+)
+
+const (
+	// s3WebsiteSysmeta holds a bucket's website configuration, as XML.  The
+	// index document is also kept in Web-Index, so staticweb serves it.
+	s3WebsiteSysmeta = "X-Container-Sysmeta-S3-Website"
+	s3WebIndexHeader = "X-Container-Meta-Web-Index"
+	// s3WebsiteAccount has a container for each bucket served as a website,
+	// its s3WebsiteOwnerSysmeta naming the account the bucket is in, since
+	// website hostnames only name the bucket.
+	s3WebsiteAccount      = ".s3_websites"
+	s3WebsiteOwnerSysmeta = "X-Container-Sysmeta-S3-Website-Account"
+)
+
+type s3WebsiteConfiguration struct {
+	XMLName               xml.Name              `xml:"WebsiteConfiguration"`
+	Xmlns                 string                `xml:"xmlns,attr,omitempty"`
+	RedirectAllRequestsTo *s3WebsiteRedirectAll `xml:"RedirectAllRequestsTo,omitempty"`
+	IndexDocument         *s3WebsiteIndex       `xml:"IndexDocument,omitempty"`
+	ErrorDocument         *s3WebsiteError       `xml:"ErrorDocument,omitempty"`
+	RoutingRules          []s3RoutingRule       `xml:"RoutingRules>RoutingRule,omitempty"`
+}
+
+type s3WebsiteRedirectAll struct {
+	HostName string `xml:"HostName"`
+	Protocol string `xml:"Protocol,omitempty"`
+}
+
+type s3WebsiteIndex struct {
+	Suffix string `xml:"Suffix"`
+}
+
+type s3WebsiteError struct {
+	Key string `xml:"Key"`
+}
+
+type s3RoutingRule struct {
+	Condition *s3RoutingCondition `xml:"Condition,omitempty"`
+	Redirect  s3RoutingRedirect   `xml:"Redirect"`
+}
+
+type s3RoutingCondition struct {
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals,omitempty"`
+	HttpErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals,omitempty"`
+}
+
+type s3RoutingRedirect struct {
+	HostName             string `xml:"HostName,omitempty"`
+	HttpRedirectCode     string `xml:"HttpRedirectCode,omitempty"`
+	Protocol             string `xml:"Protocol,omitempty"`
+	ReplaceKeyPrefixWith string `xml:"ReplaceKeyPrefixWith,omitempty"`
+	ReplaceKeyWith       string `xml:"ReplaceKeyWith,omitempty"`
+}
+
+func validS3Protocol(p string) bool {
+	return p == "" || p == "http" || p == "https"
+}
+
+// valid checks the configuration the way S3 does: either every request is
+// redirected elsewhere, or there's an index document and optional error
+// document and routing rules.
+func (c *s3WebsiteConfiguration) valid() bool {
+	if c.RedirectAllRequestsTo != nil {
+		return c.IndexDocument == nil && c.ErrorDocument == nil && len(c.RoutingRules) == 0 &&
+			c.RedirectAllRequestsTo.HostName != "" && validS3Protocol(c.RedirectAllRequestsTo.Protocol)
+	}
+	if c.IndexDocument == nil || c.IndexDocument.Suffix == "" || strings.Contains(c.IndexDocument.Suffix, "/") {
+		return false
+	}
+	if c.ErrorDocument != nil && c.ErrorDocument.Key == "" {
+		return false
+	}
+	for _, rule := range c.RoutingRules {
+		r := rule.Redirect
+		if r == (s3RoutingRedirect{}) || !validS3Protocol(r.Protocol) || (r.ReplaceKeyWith != "" && r.ReplaceKeyPrefixWith != "") {
+			return false
+		}
+		if r.HttpRedirectCode != "" {
+			if code, err := strconv.Atoi(r.HttpRedirectCode); err != nil || code/100 != 3 {
+				return false
+			}
+		}
+		if rule.Condition != nil && rule.Condition.HttpErrorCodeReturnedEquals != "" {
+			if code, err := strconv.Atoi(rule.Condition.HttpErrorCodeReturnedEquals); err != nil || code/100 != 4 && code/100 != 5 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// route returns the first routing rule that applies to key, given the status
+// the bucket returned for it, or 0 before it has been looked up.
+func (c *s3WebsiteConfiguration) route(key string, status int) *s3RoutingRule {
+	for i, rule := range c.RoutingRules {
+		cond := rule.Condition
+		if cond == nil {
+			cond = &s3RoutingCondition{}
+		}
+		if !strings.HasPrefix(key, cond.KeyPrefixEquals) {
+			continue
+		}
+		if cond.HttpErrorCodeReturnedEquals != "" {
+			if cond.HttpErrorCodeReturnedEquals != strconv.Itoa(status) {
+				continue
+			}
+		} else if status != 0 {
+			continue
+		}
+		return &c.RoutingRules[i]
+	}
+	return nil
+}
+
+// location is where the rule sends a request for key.
+func (rule *s3RoutingRule) location(request *http.Request, key string) string {
+	r := rule.Redirect
+	protocol, host := r.Protocol, r.HostName
+	if protocol == "" {
+		protocol = "http"
+		if request.TLS != nil {
+			protocol = "https"
+		}
+	}
+	if host == "" {
+		host = request.Host
+	}
+	if r.ReplaceKeyWith != "" {
+		key = r.ReplaceKeyWith
+	} else if r.ReplaceKeyPrefixWith != "" {
+		prefix := ""
+		if rule.Condition != nil {
+			prefix = rule.Condition.KeyPrefixEquals
+		}
+		key = r.ReplaceKeyPrefixWith + strings.TrimPrefix(key, prefix)
+	}
+	return protocol + "://" + host + "/" + (&url.URL{Path: key}).EscapedPath()
+}
+
+func (rule *s3RoutingRule) redirect(writer http.ResponseWriter, request *http.Request, key string) {
+	code, err := strconv.Atoi(rule.Redirect.HttpRedirectCode)
+	if err != nil {
+		code = http.StatusMovedPermanently
+	}
+	writer.Header().Set("Location", rule.location(request, key))
+	srv.StandardResponse(writer, code)
+}
+
+// handleBucketWebsite serves PutBucketWebsite, GetBucketWebsite and
+// DeleteBucketWebsite.
+func (s *s3ApiHandler) handleBucketWebsite(writer http.ResponseWriter, request *http.Request) {
+	ctx := GetProxyContext(request)
+	switch request.Method {
+	case "GET":
+		cap, err := s.headSubrequest(request)
+		if err != nil {
+			srv.StandardResponse(writer, http.StatusInternalServerError)
+			return
+		}
+		if cap.status == 404 {
+			NoSuchBucketResponse(writer, request)
+			return
+		}
+		if cap.status/100 != 2 {
+			srv.StandardResponse(writer, cap.status)
+			return
+		}
+		ci, err := ctx.C.GetContainerInfo(request.Context(), "AUTH_"+s.account, s.container)
+		if err != nil || ci.SysMetadata["S3-Website"] == "" {
+			writer.WriteHeader(40404)
+			writer.Write(nil)
+			return
+		}
+		var config s3WebsiteConfiguration
+		if err := xml.Unmarshal([]byte(ci.SysMetadata["S3-Website"]), &config); err != nil {
+			srv.StandardResponse(writer, http.StatusInternalServerError)
+			return
+		}
+		config.Xmlns = s3Xmlns
+		writeS3XML(writer, &config)
+	case "PUT", "DELETE":
+		owner := "AUTH_" + s.account
+		registered := ""
+		if ri, err := ctx.C.GetContainerInfo(request.Context(), s3WebsiteAccount, s.container); err == nil {
+			registered = ri.SysMetadata["S3-Website-Account"]
+		}
+		var website, index string
+		if request.Method == "PUT" {
+			// Another account's bucket of the same name already has the
+			// website hostname.
+			if registered != "" && registered != owner {
+				BucketAlreadyExistsResponse(writer, request)
+				return
+			}
+			body, err := ioutil.ReadAll(io.LimitReader(request.Body, s3MultipartCompleteBodyLimit))
+			if err != nil {
+				srv.StandardResponse(writer, http.StatusBadRequest)
+				return
+			}
+			var config s3WebsiteConfiguration
+			if err := xml.Unmarshal(body, &config); err != nil || !config.valid() {
+				srv.StandardResponse(writer, http.StatusBadRequest)
+				return
+			}
+			config.Xmlns = ""
+			stored, err := xml.Marshal(&config)
+			if err != nil {
+				srv.StandardResponse(writer, http.StatusInternalServerError)
+				return
+			}
+			website = string(stored)
+			if config.IndexDocument != nil {
+				index = config.IndexDocument.Suffix
+			}
+		}
+		newReq, err := ctx.newSubrequest("POST", s.path, http.NoBody, request, "s3api")
+		if err != nil {
+			srv.StandardResponse(writer, http.StatusInternalServerError)
+			return
+		}
+		newReq.Header.Set(s3WebsiteSysmeta, website)
+		newReq.Header.Set(s3WebIndexHeader, index)
+		cap := NewCaptureWriter()
+		ctx.serveHTTPSubrequest(cap, newReq)
+		if cap.status == 404 {
+			NoSuchBucketResponse(writer, request)
+			return
+		}
+		if cap.status/100 != 2 {
+			srv.StandardResponse(writer, cap.status)
+			return
+		}
+		header := http.Header{"X-Timestamp": {common.GetTimestamp()}, "X-Trans-Id": {ctx.TxId}}
+		if request.Method == "DELETE" {
+			if registered == owner {
+				ctx.C.DeleteContainer(request.Context(), s3WebsiteAccount, s.container, header).Body.Close()
+			}
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if registered != owner {
+			if resp := ctx.C.HeadAccount(request.Context(), s3WebsiteAccount, nil); resp.StatusCode == http.StatusNotFound {
+				ctx.AutoCreateAccount(request.Context(), s3WebsiteAccount, nil)
+			} else {
+				resp.Body.Close()
+			}
+			header.Set(s3WebsiteOwnerSysmeta, owner)
+			resp := ctx.C.PutContainer(request.Context(), s3WebsiteAccount, s.container, header)
+			resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				srv.StandardResponse(writer, resp.StatusCode)
+				return
+			}
+		}
+		writer.WriteHeader(200)
+	default:
+		srv.StandardResponse(writer, http.StatusMethodNotAllowed)
+	}
+}
+
+// s3Website serves buckets as static websites to anonymous requests for
+// <bucket>.<website_domain>.  Requests are rewritten to the bucket's Swift
+// path in the account that registered its website, or else the anonymous
+// account, so the usual ACL checks apply and staticweb serves the index
+// document; this handler applies the bucket's redirects, routing rules and
+// error document around that.
+type s3Website struct {
+	next           http.Handler
+	domain         string
+	account        string
+	requestsMetric tally.Counter
+}
+
+// s3WebsiteWriter passes a response through unless the bucket has something
+// else to say for its status: a routing rule's redirect or the error
+// document. Redirects staticweb sends to the Swift path are turned back into
+// website paths.
+type s3WebsiteWriter struct {
+	http.ResponseWriter
+	request     *http.Request
+	config      *s3WebsiteConfiguration
+	key         string
+	swiftPath   string
+	intercepted bool
+}
+
+func (w *s3WebsiteWriter) WriteHeader(status int) {
+	if status == http.StatusUnauthorized {
+		status = http.StatusForbidden
+	}
+	if status/100 == 3 {
+		if loc := w.Header().Get("Location"); strings.HasPrefix(loc, w.swiftPath) {
+			w.Header().Set("Location", "/"+strings.TrimPrefix(loc, w.swiftPath))
+		}
+	}
+	if status/100 != 4 && status/100 != 5 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if rule := w.config.route(w.key, status); rule != nil {
+		w.intercepted = true
+		w.clearHeaders()
+		rule.redirect(w.ResponseWriter, w.request, w.key)
+		return
+	}
+	if w.config.ErrorDocument == nil || status/100 != 4 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.intercepted = true
+	w.clearHeaders()
+	body, header, subStatus := PipedGet(w.swiftPath+w.config.ErrorDocument.Key, w.request, "s3website", nil)
+	if body != nil {
+		defer body.Close()
+	}
+	if subStatus/100 != 2 {
+		srv.StandardResponse(w.ResponseWriter, status)
+		return
+	}
+	w.Header().Set("Content-Type", header.Get("Content-Type"))
+	w.Header().Set("Content-Length", header.Get("Content-Length"))
+	w.ResponseWriter.WriteHeader(status)
+	io.Copy(w.ResponseWriter, body)
+}
+
+func (w *s3WebsiteWriter) Flush() {
+	if !w.intercepted {
+		srv.Flush(w.ResponseWriter)
+	}
+}
+
+func (w *s3WebsiteWriter) clearHeaders() {
+	for k := range w.Header() {
+		delete(w.Header(), k)
+	}
+}
+
+func (w *s3WebsiteWriter) Write(b []byte) (int, error) {
+	if w.intercepted {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// bucket returns the bucket named by the request's Host, if it's under the
+// website domain.
+func (s *s3Website) bucket(request *http.Request) string {
+	host := request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if !strings.HasSuffix(host, "."+s.domain) {
+		return ""
+	}
+	bucket := strings.TrimSuffix(host, "."+s.domain)
+	if !validBucketName(bucket) {
+		return ""
+	}
+	return bucket
+}
+
+func (s *s3Website) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	bucket := s.bucket(request)
+	if bucket == "" || (request.Method != "GET" && request.Method != "HEAD") ||
+		request.Header.Get("Authorization") != "" || request.Header.Get("X-Auth-Token") != "" {
+		s.next.ServeHTTP(writer, request)
+		return
+	}
+	ctx := GetProxyContext(request)
+	s.requestsMetric.Inc(1)
+	account := ""
+	if ri, err := ctx.C.GetContainerInfo(request.Context(), s3WebsiteAccount, bucket); err == nil {
+		account = ri.SysMetadata["S3-Website-Account"]
+	}
+	if account == "" && s.account != "" {
+		account = "AUTH_" + s.account
+	}
+	if account == "" {
+		srv.StandardResponse(writer, http.StatusNotFound)
+		return
+	}
+	ci, err := ctx.C.GetContainerInfo(request.Context(), account, bucket)
+	if err != nil || ci.SysMetadata["S3-Website"] == "" {
+		srv.StandardResponse(writer, http.StatusNotFound)
+		return
+	}
+	var config s3WebsiteConfiguration
+	if err := xml.Unmarshal([]byte(ci.SysMetadata["S3-Website"]), &config); err != nil {
+		srv.StandardResponse(writer, http.StatusInternalServerError)
+		return
+	}
+	key := strings.TrimPrefix(request.URL.Path, "/")
+	if r := config.RedirectAllRequestsTo; r != nil {
+		rule := &s3RoutingRule{Redirect: s3RoutingRedirect{HostName: r.HostName, Protocol: r.Protocol}}
+		rule.redirect(writer, request, key)
+		return
+	}
+	if rule := config.route(key, 0); rule != nil {
+		rule.redirect(writer, request, key)
+		return
+	}
+	swiftPath := fmt.Sprintf("/v1/%s/%s/", account, bucket)
+	request.URL.Path = swiftPath + key
+	request.URL.RawPath = ""
+	request.RequestURI = request.URL.RequestURI()
+	s.next.ServeHTTP(&s3WebsiteWriter{
+		ResponseWriter: writer,
+		request:        request,
+		config:         &config,
+		key:            key,
+		swiftPath:      swiftPath,
+	}, request)
+}
+
+// NewS3Website serves S3 website buckets when website_domain is set in the
+// s3api section.
+func NewS3Website(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
+	enabled, ok := config.Section["enabled"]
+	domain := strings.ToLower(strings.Trim(config.GetDefault("website_domain", ""), "."))
+	account := config.GetDefault("anonymous_account", "")
+	if !ok || strings.ToLower(enabled) == "false" || domain == "" {
+		return func(next http.Handler) http.Handler {
+			return next
+		}, nil
+	}
+	requestsMetric := metricsScope.Counter("s3Website_requests")
+	return func(next http.Handler) http.Handler {
+		return &s3Website{next: next, domain: domain, account: account, requestsMetric: requestsMetric}
+	}, nil
+}
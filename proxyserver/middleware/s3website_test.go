@@ -0,0 +1,221 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/client/clienttest"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/test"
+	"go.uber.org/zap"
+)
+
+const testS3WebsiteConfig = `<WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">` +
+	`<IndexDocument><Suffix>index.html</Suffix></IndexDocument>` +
+	`<ErrorDocument><Key>error.html</Key></ErrorDocument>` +
+	`<RoutingRules>` +
+	`<RoutingRule><Condition><KeyPrefixEquals>docs/</KeyPrefixEquals></Condition>` +
+	`<Redirect><ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith></Redirect></RoutingRule>` +
+	`<RoutingRule><Condition><KeyPrefixEquals>old/</KeyPrefixEquals><HttpErrorCodeReturnedEquals>404</HttpErrorCodeReturnedEquals></Condition>` +
+	`<Redirect><HostName>example.com</HostName><Protocol>https</Protocol><HttpRedirectCode>302</HttpRedirectCode></Redirect></RoutingRule>` +
+	`</RoutingRules></WebsiteConfiguration>`
+
+func TestS3WebsiteConfigurationValid(t *testing.T) {
+	valid := func(body string) bool {
+		var config s3WebsiteConfiguration
+		require.Nil(t, xml.Unmarshal([]byte(body), &config))
+		return config.valid()
+	}
+	require.True(t, valid(testS3WebsiteConfig))
+	require.True(t, valid(`<WebsiteConfiguration><RedirectAllRequestsTo><HostName>example.com</HostName></RedirectAllRequestsTo></WebsiteConfiguration>`))
+	require.False(t, valid(`<WebsiteConfiguration></WebsiteConfiguration>`))
+	require.False(t, valid(`<WebsiteConfiguration><IndexDocument><Suffix>a/index.html</Suffix></IndexDocument></WebsiteConfiguration>`))
+	require.False(t, valid(`<WebsiteConfiguration><RedirectAllRequestsTo><HostName>example.com</HostName></RedirectAllRequestsTo>`+
+		`<IndexDocument><Suffix>index.html</Suffix></IndexDocument></WebsiteConfiguration>`))
+	require.False(t, valid(`<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument>`+
+		`<RoutingRules><RoutingRule><Redirect><HttpRedirectCode>200</HttpRedirectCode></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`))
+}
+
+func TestS3BucketWebsite(t *testing.T) {
+	store := clienttest.NewStore()
+	rc := clienttest.NewRequestClient(store)
+	rc.PutContainer(context.Background(), "AUTH_test", "bucket", http.Header{})
+	rc.PutContainer(context.Background(), "AUTH_other", "bucket", http.Header{})
+	var posted http.Header
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			posted = r.Header
+			parts := strings.Split(r.URL.Path, "/")
+			rc.PostContainer(r.Context(), parts[2], parts[3], r.Header)
+		}
+		w.WriteHeader(204)
+	})
+	do := func(account, method, body string) *httptest.ResponseRecorder {
+		ctx := &ProxyContext{
+			ProxyContextMiddleware: &ProxyContextMiddleware{next: next, Cache: &test.FakeMemcacheRing{}},
+			Logger:                 zap.NewNop(),
+			S3Auth:                 &S3AuthInfo{Account: account},
+			C:                      rc,
+		}
+		s := &s3ApiHandler{ctx: ctx, account: account, container: "bucket", path: "/v1/AUTH_" + account + "/bucket"}
+		r := httptest.NewRequest(method, "/bucket?website", strings.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), "proxycontext", ctx))
+		w := httptest.NewRecorder()
+		s.handleContainerRequest(newS3ResponseWriterWrapper(w, r), r)
+		return w
+	}
+	owner := func() string {
+		ci, err := rc.GetContainerInfo(context.Background(), s3WebsiteAccount, "bucket")
+		if err != nil {
+			return ""
+		}
+		return ci.SysMetadata["S3-Website-Account"]
+	}
+
+	w := do("test", "GET", "")
+	require.Equal(t, 404, w.Code)
+	require.Contains(t, w.Body.String(), "NoSuchWebsiteConfiguration")
+
+	require.Equal(t, 400, do("test", "PUT", `<WebsiteConfiguration></WebsiteConfiguration>`).Code)
+	require.Nil(t, posted)
+	require.Equal(t, "", owner())
+	require.Equal(t, 200, do("test", "PUT", testS3WebsiteConfig).Code)
+	require.Equal(t, "index.html", posted.Get(s3WebIndexHeader))
+	require.Contains(t, posted.Get(s3WebsiteSysmeta), "<ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith>")
+	require.Equal(t, "AUTH_test", owner())
+
+	w = do("test", "GET", "")
+	require.Equal(t, 200, w.Code)
+	var config s3WebsiteConfiguration
+	require.Nil(t, xml.Unmarshal(w.Body.Bytes(), &config))
+	require.Equal(t, s3Xmlns, config.Xmlns)
+	require.Equal(t, "error.html", config.ErrorDocument.Key)
+	require.Equal(t, 2, len(config.RoutingRules))
+
+	// Another account's bucket of the same name can't take the hostname.
+	posted = nil
+	w = do("other", "PUT", testS3WebsiteConfig)
+	require.Equal(t, 400, w.Code)
+	require.Contains(t, w.Body.String(), "BucketAlreadyExists")
+	require.Nil(t, posted)
+	require.Equal(t, 204, do("other", "DELETE", "").Code)
+	require.Equal(t, "AUTH_test", owner())
+
+	require.Equal(t, 204, do("test", "DELETE", "").Code)
+	_, ok := posted[s3WebsiteSysmeta]
+	require.True(t, ok)
+	require.Equal(t, "", posted.Get(s3WebsiteSysmeta))
+	require.Equal(t, "", posted.Get(s3WebIndexHeader))
+	require.Equal(t, "", owner())
+}
+
+func TestS3WebsiteServe(t *testing.T) {
+	var config s3WebsiteConfiguration
+	require.Nil(t, xml.Unmarshal([]byte(testS3WebsiteConfig), &config))
+	stored, err := xml.Marshal(&config)
+	require.Nil(t, err)
+	rc := clienttest.NewRequestClient(clienttest.NewStore())
+	for _, c := range []struct {
+		account, container, header, value string
+	}{
+		{"AUTH_web", "site", s3WebsiteSysmeta, string(stored)},
+		{"AUTH_web", "plain", "", ""},
+		{"AUTH_user", "site", s3WebsiteSysmeta, string(stored)},
+		{"AUTH_user", "mine", s3WebsiteSysmeta, string(stored)},
+		{s3WebsiteAccount, "mine", s3WebsiteOwnerSysmeta, "AUTH_user"},
+	} {
+		header := http.Header{}
+		if c.header != "" {
+			header.Set(c.header, c.value)
+		}
+		require.Equal(t, 201, rc.PutContainer(context.Background(), c.account, c.container, header).StatusCode)
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/AUTH_web/site/page.html", "/v1/AUTH_user/mine/page.html":
+			w.WriteHeader(200)
+			w.Write([]byte("page"))
+		case "/v1/AUTH_web/site/dir":
+			w.Header().Set("Location", "/v1/AUTH_web/site/dir/")
+			w.WriteHeader(301)
+		case "/v1/AUTH_web/site/error.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(200)
+			w.Write([]byte("oops"))
+		default:
+			w.WriteHeader(404)
+			w.Write([]byte("Not Found"))
+		}
+	})
+	settings, err := conf.StringConfig("[filter:s3api]\nenabled = true\nwebsite_domain = web.example.com\nanonymous_account = web\n")
+	require.Nil(t, err)
+	mid, err := NewS3Website(settings.GetSection("filter:s3api"), common.NewTestScope())
+	require.Nil(t, err)
+	h := mid(next)
+	ctx := &ProxyContext{
+		ProxyContextMiddleware: &ProxyContextMiddleware{next: next},
+		Logger:                 zap.NewNop(),
+		C:                      rc,
+	}
+	get := func(host, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Host = host
+		r = r.WithContext(context.WithValue(r.Context(), "proxycontext", ctx))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("site.web.example.com", "/page.html")
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, "page", w.Body.String())
+
+	w = get("site.web.example.com:8080", "/dir")
+	require.Equal(t, 301, w.Code)
+	require.Equal(t, "/dir/", w.Header().Get("Location"))
+
+	w = get("site.web.example.com", "/docs/a%20b.html")
+	require.Equal(t, 301, w.Code)
+	require.Equal(t, "http://site.web.example.com/documents/a%20b.html", w.Header().Get("Location"))
+
+	w = get("site.web.example.com", "/old/page.html")
+	require.Equal(t, 302, w.Code)
+	require.Equal(t, "https://example.com/old/page.html", w.Header().Get("Location"))
+
+	w = get("site.web.example.com", "/missing.html")
+	require.Equal(t, 404, w.Code)
+	require.Equal(t, "oops", w.Body.String())
+	require.Equal(t, "text/html", w.Header().Get("Content-Type"))
+
+	require.Equal(t, 404, get("plain.web.example.com", "/page.html").Code)
+	// Buckets with their website registered are served from the owner's
+	// account.
+	w = get("mine.web.example.com", "/page.html")
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, "page", w.Body.String())
+	// Other hosts aren't website requests.
+	require.Equal(t, 404, get("site.example.com", "/page.html").Code)
+	require.Equal(t, "Not Found", get("site.example.com", "/page.html").Body.String())
+}
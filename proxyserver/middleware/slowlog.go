@@ -0,0 +1,79 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/common/tracing"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+// NewSlowRequestLog logs client requests taking longer than threshold
+// seconds, along with the timeline of every backend request made for them
+// and the time each middleware after this one took.
+// Only sample_rate of the slow requests are logged.
+func NewSlowRequestLog(config conf.Section, metricsScope tally.Scope) (func(http.Handler) http.Handler, error) {
+	if !config.GetBool("enabled", false) {
+		return func(next http.Handler) http.Handler {
+			return next
+		}, nil
+	}
+	threshold := time.Duration(config.GetFloat("threshold", 1.0) * float64(time.Second))
+	sampleRate := config.GetFloat("sample_rate", 1.0)
+	slowRequestsMetric := metricsScope.Counter("slow_requests")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if ctx := GetProxyContext(request); ctx != nil && ctx.Source != "" {
+				next.ServeHTTP(writer, request)
+				return
+			}
+			timeline := tracing.NewTimeline()
+			request = request.WithContext(tracing.ContextWithTimeline(request.Context(), timeline))
+			start := time.Now()
+			newWriter := &srv.WebWriter{ResponseWriter: writer, Status: 500}
+			next.ServeHTTP(newWriter, request)
+			elapsed := time.Since(start)
+			if elapsed < threshold {
+				return
+			}
+			slowRequestsMetric.Inc(1)
+			if sampleRate < 1 && rand.Float64() >= sampleRate {
+				return
+			}
+			logger := zap.L()
+			var middlewareTimes []MiddlewareTime
+			if ctx := GetProxyContext(request); ctx != nil {
+				logger = ctx.Logger
+				middlewareTimes = ctx.MiddlewareTimes()
+			}
+			logger.Warn("Slow request",
+				zap.String("method", request.Method),
+				zap.String("urlPath", common.Urlencode(request.URL.Path)),
+				zap.Int("status", newWriter.Status),
+				zap.Duration("duration", elapsed),
+				zap.Duration("timeToHeader", newWriter.ResponseStarted.Sub(start)),
+				zap.Any("middleware", middlewareTimes),
+				zap.Any("backend", timeline.Events()))
+		})
+	}, nil
+}
@@ -0,0 +1,72 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/tracing"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func slowLogRequest(t *testing.T, threshold string, source string) *observer.ObservedLogs {
+	config, err := conf.StringConfig("[filter:slowlog]\nenabled = true\nthreshold = " + threshold)
+	require.Nil(t, err)
+	mid, err := NewSlowRequestLog(config.GetSection("filter:slowlog"), common.NewTestScope())
+	require.Nil(t, err)
+	h := mid(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		// stands in for the proxy client fanning out to two object servers
+		ctx := tracing.CopySpanFromContext(request.Context())
+		if timeline := tracing.TimelineFromContext(ctx); timeline != nil {
+			timeline.Add("GET", "http://127.0.0.1:6010/sda/1/a/c/o", 503, nil, time.Now())
+			timeline.Add("GET", "http://127.0.0.2:6010/sdb/1/a/c/o", 200, nil, time.Now())
+		}
+		writer.WriteHeader(http.StatusOK)
+	}))
+	obs, logs := observer.New(zap.InfoLevel)
+	req, _ := http.NewRequest("GET", "/v1/a/c/o", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "proxycontext", &ProxyContext{
+		Logger:                 zap.New(obs),
+		Source:                 source,
+		ProxyContextMiddleware: &ProxyContextMiddleware{next: h},
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	return logs
+}
+
+func TestSlowRequestLog(t *testing.T) {
+	logs := slowLogRequest(t, "0", "")
+	require.Equal(t, 1, logs.Len())
+	entry := logs.All()[0]
+	require.Equal(t, "Slow request", entry.Message)
+	backend := entry.ContextMap()["backend"].([]tracing.TimelineEvent)
+	require.Equal(t, 2, len(backend))
+	require.Equal(t, 503, backend[0].Status)
+	require.Equal(t, "http://127.0.0.2:6010/sdb/1/a/c/o", backend[1].URL)
+}
+
+func TestSlowRequestLogFastAndSubrequests(t *testing.T) {
+	require.Equal(t, 0, slowLogRequest(t, "60", "").Len())
+	require.Equal(t, 0, slowLogRequest(t, "0", "tempurl").Len())
+}
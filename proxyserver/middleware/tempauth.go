@@ -127,6 +127,19 @@ func (ta *tempAuth) getToken(ctx context.Context, proxyCtx *ProxyContext, user,
 	return tUser, token
 }
 
+// tokenGroups returns the groups of the user a token was issued to, or
+// ring.CacheMiss if the token is unknown or expired.
+func (ta *tempAuth) tokenGroups(ctx context.Context, proxyCtx *ProxyContext, token string) ([]string, error) {
+	var ca cachedAuth
+	if err := proxyCtx.Cache.GetStructured(ctx, "auth:"+token, &ca); err != nil {
+		return nil, err
+	}
+	if ca.Expires <= time.Now().Unix() {
+		return nil, ring.CacheMiss
+	}
+	return ca.Groups, nil
+}
+
 func (ta *tempAuth) handleGetToken(writer http.ResponseWriter, request *http.Request) {
 	if request.Method != "GET" {
 		srv.StandardResponse(writer, 400)
@@ -173,16 +186,7 @@ func (ta *tempAuth) handleGetToken(writer http.ResponseWriter, request *http.Req
 }
 
 func (ta *tempAuth) getReseller(account string) (string, bool) {
-	// dosn't handle empty resellers yet
-	if strings.HasPrefix(account, ta.reseller) {
-		return ta.reseller, true
-	}
-	for _, r := range ta.resellers {
-		if strings.HasPrefix(account, r) {
-			return r, true
-		}
-	}
-	return "", false
+	return conf.ResellerPrefix(ta.resellers, account)
 }
 
 func (ta *tempAuth) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
@@ -191,7 +195,7 @@ func (ta *tempAuth) ServeHTTP(writer http.ResponseWriter, request *http.Request)
 		ta.next.ServeHTTP(writer, request)
 		return
 	}
-	if ctx.S3Auth != nil && ctx.Authorize == nil {
+	if ctx.S3Auth != nil && !ctx.S3Auth.Anonymous && ctx.Authorize == nil {
 		// handle S3 auth validation
 		key := ctx.S3Auth.Key
 		parts := strings.Split(key, ":")
@@ -237,10 +241,11 @@ func (ta *tempAuth) ServeHTTP(writer http.ResponseWriter, request *http.Request)
 				}
 				account = pathParts["account"]
 			}
+			// Tokens all start with the first prefix, but are good for
+			// accounts under any of them; the groups decide which.
 			if token != "" && strings.HasPrefix(token, ta.reseller) {
-				if curReseller, ok := ta.getReseller(account); ok && curReseller == ta.reseller {
-					var ca cachedAuth
-					if err := ctx.Cache.GetStructured(request.Context(), "auth:"+token, &ca); err != nil {
+				if _, ok := ta.getReseller(account); ok {
+					if groups, err := ta.tokenGroups(request.Context(), ctx, token); err != nil {
 						s := http.StatusServiceUnavailable
 						if err == ring.CacheMiss {
 							s = http.StatusUnauthorized
@@ -249,19 +254,19 @@ func (ta *tempAuth) ServeHTTP(writer http.ResponseWriter, request *http.Request)
 							return false, s
 						}
 					} else {
-						if st := request.Header.Get("X-Service-Token"); st != "" {
-							var caSt cachedAuth
-							if err := ctx.Cache.GetStructured(request.Context(), "auth:"+st, &caSt); err == nil {
-								for _, g := range caSt.Groups {
-									ca.Groups = append(ca.Groups, g)
-								}
+						// A service token adds its user's groups, so a
+						// <prefix>_require_group can insist on one, like
+						// .service, that end users aren't given.
+						if st := request.Header.Get("X-Service-Token"); st != "" && strings.HasPrefix(st, ta.reseller) {
+							if serviceGroups, err := ta.tokenGroups(request.Context(), ctx, st); err == nil {
+								groups = append(groups, serviceGroups...)
+							} else {
+								ctx.Logger.Debug("Ignoring invalid X-Service-Token", zap.Error(err))
 							}
 						}
-						ctx.RemoteUsers = ca.Groups
+						ctx.RemoteUsers = groups
 						ctx.Authorize = ta.authorize
 					}
-				} else if ok {
-					ctx.Authorize = ta.authorize
 				}
 			} else {
 				if _, ok := ta.getReseller(account); ok {
@@ -297,7 +302,13 @@ func (ta *tempAuth) authorize(r *http.Request) (bool, int) {
 	if common.StringInSlice(".reseller_admin", ctx.RemoteUsers) &&
 		!common.StringInSlice(pathParts["account"], ta.resellers) &&
 		!strings.HasPrefix(pathParts["account"], ".") {
+		// A .reseller_admin user is a reseller admin to the rest of the
+		// pipeline, as keystoneauth's reseller_admin_role is, so the
+		// account quotas, read-only accounts, retention, open-expired
+		// reads, container limits and backend listings that check for one
+		// work the same under either auth middleware.
 		ctx.StorageOwner = true
+		ctx.ResellerRequest = true
 		return true, http.StatusOK
 	}
 	if common.StringInSlice(pathParts["account"], ctx.RemoteUsers) &&
@@ -19,6 +19,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -213,6 +214,7 @@ func TestAuthorize(t *testing.T) {
 	authReq = authReq.WithContext(context.WithValue(authReq.Context(), "proxycontext", fakeContext))
 	ok, st = ta.authorize(authReq)
 	require.Equal(t, 200, st)
+	require.True(t, fakeContext.ResellerRequest)
 
 	fakeContext = NewFakeProxyContext(passthrough)
 	fakeContext.RemoteUsers = []string{"AUTH_test"}
@@ -221,6 +223,8 @@ func TestAuthorize(t *testing.T) {
 	ok, st = ta.authorize(authReq)
 	require.True(t, ok)
 	require.Equal(t, 200, st)
+	// Owning the account isn't being a reseller admin.
+	require.False(t, fakeContext.ResellerRequest)
 	ta.accountRules = map[string]map[string][]string{"AUTH_": {"require_group": {"ops"}}}
 	ok, st = ta.authorize(authReq)
 	require.Equal(t, 403, st)
@@ -340,4 +344,84 @@ func TestServeHTTP(t *testing.T) {
 	ctx := GetProxyContext(authReq)
 	require.False(t, ctx.Authorize == nil)
 	require.Equal(t, "hat", fakeContext.RemoteUsers[0])
+
+	// the token is checked for accounts under any of the prefixes
+	fakeContext.Authorize = nil
+	fakeContext.RemoteUsers = nil
+	authReq, err = http.NewRequest("GET", "/v1/SERVICE_test", nil)
+	require.Nil(t, err)
+	authReq = authReq.WithContext(context.WithValue(authReq.Context(), "proxycontext", fakeContext))
+	authReq.Header.Set("X-Auth-Token", "AUTH_abcde")
+	ta.ServeHTTP(fakeWriter, authReq)
+	require.False(t, fakeContext.Authorize == nil)
+	require.Equal(t, []string{"hat"}, fakeContext.RemoteUsers)
+
+	// and an empty prefix takes any account the others don't
+	fakeContext.Authorize = nil
+	fakeContext.RemoteUsers = nil
+	ta.resellers = []string{"AUTH_", ""}
+	authReq, err = http.NewRequest("GET", "/v1/MOO_test", nil)
+	require.Nil(t, err)
+	authReq = authReq.WithContext(context.WithValue(authReq.Context(), "proxycontext", fakeContext))
+	authReq.Header.Set("X-Auth-Token", "AUTH_abcde")
+	ta.ServeHTTP(fakeWriter, authReq)
+	require.False(t, fakeContext.Authorize == nil)
+	require.Equal(t, []string{"hat"}, fakeContext.RemoteUsers)
+}
+
+func TestServiceToken(t *testing.T) {
+	passthrough := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	})
+	fakeMr := &test.FakeMemcacheRing{}
+	userAuth, _ := json.Marshal(cachedAuth{Groups: []string{"test", "test:tester", "AUTH_test", "SERVICE_test"}, Expires: time.Now().Unix() + 100})
+	serviceAuth, _ := json.Marshal(cachedAuth{Groups: []string{"glance", "glance:glance", ".service"}, Expires: time.Now().Unix() + 100})
+	expiredAuth, _ := json.Marshal(cachedAuth{Groups: []string{".service"}, Expires: time.Now().Unix() - 100})
+	fakeMr.MockGetStructured = map[string][]byte{
+		"auth:AUTH_user":    userAuth,
+		"auth:AUTH_service": serviceAuth,
+		"auth:AUTH_expired": expiredAuth,
+	}
+	ta := &tempAuth{
+		reseller:     "AUTH_",
+		resellers:    []string{"AUTH_", "SERVICE_"},
+		next:         passthrough,
+		accountRules: map[string]map[string][]string{"SERVICE_": {"require_group": {".service"}}},
+	}
+	for _, tc := range []struct {
+		path, serviceToken string
+		status             int
+	}{
+		{"/v1/AUTH_test/c", "", 200},
+		{"/v1/SERVICE_test/c", "", 403},
+		{"/v1/SERVICE_test/c", "AUTH_service", 200},
+		{"/v1/SERVICE_test/c", "AUTH_expired", 403},
+		{"/v1/SERVICE_test/c", "AUTH_unknown", 403},
+	} {
+		fakeContext := NewFakeProxyContext(passthrough)
+		fakeContext.Cache = fakeMr
+		req, err := http.NewRequest("PUT", tc.path, nil)
+		require.Nil(t, err)
+		req = req.WithContext(context.WithValue(req.Context(), "proxycontext", fakeContext))
+		req.Header.Set("X-Auth-Token", "AUTH_user")
+		if tc.serviceToken != "" {
+			req.Header.Set("X-Service-Token", tc.serviceToken)
+		}
+		ta.ServeHTTP(httptest.NewRecorder(), req)
+		require.NotNil(t, fakeContext.Authorize)
+		_, status := fakeContext.Authorize(req)
+		require.Equal(t, tc.status, status, tc.path+" "+tc.serviceToken)
+	}
+
+	// an expired user token is no good either
+	fakeMr.MockGetStructured["auth:AUTH_user"] = expiredAuth
+	fakeContext := NewFakeProxyContext(passthrough)
+	fakeContext.Cache = fakeMr
+	req, err := http.NewRequest("GET", "/v1/AUTH_test", nil)
+	require.Nil(t, err)
+	req = req.WithContext(context.WithValue(req.Context(), "proxycontext", fakeContext))
+	req.Header.Set("X-Auth-Token", "AUTH_user")
+	ta.ServeHTTP(httptest.NewRecorder(), req)
+	_, status := fakeContext.Authorize(req)
+	require.Equal(t, 401, status)
 }
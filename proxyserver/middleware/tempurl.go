@@ -16,6 +16,7 @@
 package middleware
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha1"
 	"encoding/hex"
@@ -74,6 +75,10 @@ func (w *tuWriter) WriteHeader(status int) {
 	w.ResponseWriter.WriteHeader(status)
 }
 
+func (w *tuWriter) Flush() {
+	srv.Flush(w.ResponseWriter)
+}
+
 func checkhmac(key, sig []byte, method, path string, expires time.Time) bool {
 	if method == "HEAD" {
 		for _, meth := range []string{"HEAD", "GET", "POST", "PUT"} {
@@ -91,6 +96,44 @@ func checkhmac(key, sig []byte, method, path string, expires time.Time) bool {
 	}
 }
 
+// tempURLKeys returns the keys in metadata that can sign temporary URLs and
+// form posts. Two keys may be set at once so they can be rotated, and either
+// may come from user metadata or from sysmeta set by other middleware.
+func tempURLKeys(metadata, sysMetadata map[string]string) []string {
+	var keys []string
+	for _, name := range []string{"Temp-Url-Key", "Temp-Url-Key-2"} {
+		if key := metadata[name]; key != "" {
+			keys = append(keys, key)
+		}
+		if key := sysMetadata[name]; key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// tempURLKeyScope returns SCOPE_ACCOUNT or SCOPE_CONTAINER for the first of
+// the account's keys, then the container's, that check accepts.
+func tempURLKeyScope(ctx context.Context, proxyCtx *ProxyContext, account, container string, check func(key []byte) bool) int {
+	ai, err := proxyCtx.GetAccountInfo(ctx, account)
+	if err != nil {
+		return SCOPE_INVALID
+	}
+	for _, key := range tempURLKeys(ai.Metadata, ai.SysMetadata) {
+		if check([]byte(key)) {
+			return SCOPE_ACCOUNT
+		}
+	}
+	if ci, err := proxyCtx.C.GetContainerInfo(ctx, account, container); err == nil {
+		for _, key := range tempURLKeys(ci.Metadata, ci.SysMetadata) {
+			if check([]byte(key)) {
+				return SCOPE_CONTAINER
+			}
+		}
+	}
+	return SCOPE_INVALID
+}
+
 func tempurl(requestsMetric tally.Counter) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
@@ -153,20 +196,9 @@ func tempurl(requestsMetric tally.Counter) func(http.Handler) http.Handler {
 				path = fmt.Sprintf("/v1/%s/%s/%s", account, container, obj)
 			}
 
-			scope := SCOPE_INVALID
-			if ai, err := ctx.GetAccountInfo(request.Context(), account); err == nil {
-				if key, ok := ai.Metadata["Temp-Url-Key"]; ok && checkhmac([]byte(key), sigb, request.Method, path, expires) {
-					scope = SCOPE_ACCOUNT
-				} else if key, ok := ai.Metadata["Temp-Url-Key-2"]; ok && checkhmac([]byte(key), sigb, request.Method, path, expires) {
-					scope = SCOPE_ACCOUNT
-				} else if ci, err := ctx.C.GetContainerInfo(request.Context(), account, container); err == nil {
-					if key, ok := ci.Metadata["Temp-Url-Key"]; ok && checkhmac([]byte(key), sigb, request.Method, path, expires) {
-						scope = SCOPE_CONTAINER
-					} else if key, ok := ci.Metadata["Temp-Url-Key-2"]; ok && checkhmac([]byte(key), sigb, request.Method, path, expires) {
-						scope = SCOPE_CONTAINER
-					}
-				}
-			}
+			scope := tempURLKeyScope(request.Context(), ctx, account, container, func(key []byte) bool {
+				return checkhmac(key, sigb, request.Method, path, expires)
+			})
 			if scope == SCOPE_INVALID {
 				srv.StandardResponse(writer, 401)
 				return
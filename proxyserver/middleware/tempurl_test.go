@@ -328,3 +328,38 @@ func TestTempurlMiddlewareAccountKey(t *testing.T) {
 	mid.ServeHTTP(w, r)
 	require.Equal(t, 200, w.Result().StatusCode)
 }
+
+func TestTempurlMiddlewareSysmetaKey(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/a/c/o?temp_url_sig=f2d61be897a27c03ac9a0dac3a8c4f6ce3a3d623&"+
+		"temp_url_expires=9999999999", nil)
+	f, err := client.NewProxyClient(staticPolicyList, srv.NewTestConfigLoader(&test.FakeRing{}),
+		nil, "", "", "", "", "", conf.Config{})
+	require.Nil(t, err)
+	ctx := &ProxyContext{
+		C: f.NewRequestClient(nil, map[string]*client.ContainerInfo{
+			"container/a/c": {Metadata: map[string]string{"Temp-Url-Key": "oldkey"}, SysMetadata: map[string]string{"Temp-Url-Key-2": "mykey"}},
+		}, zap.NewNop()),
+		accountInfoCache: map[string]*AccountInfo{"account/a": {Metadata: map[string]string{}}},
+	}
+	r = r.WithContext(context.WithValue(r.Context(), "proxycontext", ctx))
+	w := httptest.NewRecorder()
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ctx := GetProxyContext(request)
+		require.NotNil(t, ctx.Authorize)
+		ok, _ := ctx.Authorize(request)
+		require.True(t, ok)
+		ok, _ = ctx.Authorize(httptest.NewRequest("GET", "/v1/a/b/o", nil))
+		require.False(t, ok)
+		writer.WriteHeader(200)
+	})
+	mid := tempurl(common.NewTestScope().Counter("test_tempurl"))(handler)
+	mid.ServeHTTP(w, r)
+	require.Equal(t, 200, w.Result().StatusCode)
+}
+
+func TestTempURLKeys(t *testing.T) {
+	require.Nil(t, tempURLKeys(nil, nil))
+	require.Equal(t, []string{"a", "b", "c"}, tempURLKeys(
+		map[string]string{"Temp-Url-Key": "a", "Temp-Url-Key-2": "c", "Other": "x"},
+		map[string]string{"Temp-Url-Key": "b"}))
+}
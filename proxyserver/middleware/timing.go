@@ -0,0 +1,168 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/uber-go/tally"
+)
+
+// MiddlewareTime is how long one middleware in the pipeline spent on a
+// request, not counting the time spent in the rest of the pipeline after it
+// or in subrequests it made. A subrequest's own times are added to those of
+// the middlewares it passed through.
+type MiddlewareTime struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
+
+type middlewareSpanKey struct{}
+
+// middlewareSpan adds up the time a middleware spends waiting on the rest of
+// the pipeline, including on subrequests it made, which may run at the same
+// time as each other.
+type middlewareSpan struct {
+	downstream int64
+}
+
+func (span *middlewareSpan) add(d time.Duration) {
+	atomic.AddInt64(&span.downstream, int64(d))
+}
+
+// own returns the part of elapsed not spent downstream. Concurrent
+// subrequests can add up to more than elapsed, so it's never negative.
+func (span *middlewareSpan) own(elapsed time.Duration) time.Duration {
+	if own := elapsed - time.Duration(atomic.LoadInt64(&span.downstream)); own > 0 {
+		return own
+	}
+	return 0
+}
+
+// timeMiddleware wraps mid so the time it spends on each request, less the
+// time spent in next, is recorded to timer and the request's ProxyContext.
+func timeMiddleware(name string, mid func(http.Handler) http.Handler, timer tally.Timer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		handler := mid(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			start := time.Now()
+			next.ServeHTTP(writer, request)
+			if span, ok := request.Context().Value(middlewareSpanKey{}).(*middlewareSpan); ok {
+				span.add(time.Since(start))
+			}
+		}))
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			span := &middlewareSpan{}
+			request = request.WithContext(context.WithValue(request.Context(), middlewareSpanKey{}, span))
+			ctx := GetProxyContext(request)
+			i := ctx.startMiddlewareTime(name)
+			start := time.Now()
+			handler.ServeHTTP(writer, request)
+			elapsed := span.own(time.Since(start))
+			timer.Record(elapsed)
+			ctx.endMiddlewareTime(i, elapsed)
+		})
+	}
+}
+
+// timeHandler records the time spent in next, the proxy's own handlers at
+// the end of the pipeline, as if it were one more middleware.
+func timeHandler(name string, timer tally.Timer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			span := &middlewareSpan{}
+			request = request.WithContext(context.WithValue(request.Context(), middlewareSpanKey{}, span))
+			ctx := GetProxyContext(request)
+			i := ctx.startMiddlewareTime(name)
+			start := time.Now()
+			next.ServeHTTP(writer, request)
+			elapsed := span.own(time.Since(start))
+			timer.Record(elapsed)
+			ctx.endMiddlewareTime(i, elapsed)
+		})
+	}
+}
+
+// startMiddlewareTime adds an entry for name, in the order the request
+// reached each middleware, and returns its index for endMiddlewareTime. A
+// subrequest may have got to the middleware first and added the entry
+// already, in which case that one is used.
+func (ctx *ProxyContext) startMiddlewareTime(name string) int {
+	if ctx == nil {
+		return -1
+	}
+	ctx.middlewareTimesLock.Lock()
+	defer ctx.middlewareTimesLock.Unlock()
+	for i := range ctx.middlewareTimes {
+		if ctx.middlewareTimes[i].Name == name {
+			return i
+		}
+	}
+	ctx.middlewareTimes = append(ctx.middlewareTimes, MiddlewareTime{Name: name})
+	return len(ctx.middlewareTimes) - 1
+}
+
+// endMiddlewareTime adds elapsed to the entry, which subrequests passing
+// through the same middleware may have added to already.
+func (ctx *ProxyContext) endMiddlewareTime(i int, elapsed time.Duration) {
+	if ctx == nil || i < 0 {
+		return
+	}
+	ctx.middlewareTimesLock.Lock()
+	ctx.middlewareTimes[i].Duration += elapsed
+	ctx.middlewareTimesLock.Unlock()
+}
+
+// subrequestDone charges a finished subrequest's middleware times to ctx,
+// adding each to ctx's entry for the same middleware, and takes the
+// subrequest's time off the middleware that made it, which the subrequest's
+// request context still points at.
+func (ctx *ProxyContext) subrequestDone(subreq *http.Request, elapsed time.Duration) {
+	if span, ok := subreq.Context().Value(middlewareSpanKey{}).(*middlewareSpan); ok {
+		span.add(elapsed)
+	}
+	subctx := GetProxyContext(subreq)
+	if ctx == nil || subctx == nil || subctx == ctx {
+		return
+	}
+	subTimes := subctx.MiddlewareTimes()
+	ctx.middlewareTimesLock.Lock()
+	defer ctx.middlewareTimesLock.Unlock()
+	for _, st := range subTimes {
+		found := false
+		for i := range ctx.middlewareTimes {
+			if ctx.middlewareTimes[i].Name == st.Name {
+				ctx.middlewareTimes[i].Duration += st.Duration
+				found = true
+				break
+			}
+		}
+		if !found {
+			ctx.middlewareTimes = append(ctx.middlewareTimes, st)
+		}
+	}
+}
+
+// MiddlewareTimes returns how long each middleware has spent on the
+// request so far, in pipeline order. Middlewares still working on it, such
+// as the one asking, show a zero Duration.
+func (ctx *ProxyContext) MiddlewareTimes() []MiddlewareTime {
+	ctx.middlewareTimesLock.Lock()
+	defer ctx.middlewareTimesLock.Unlock()
+	return append([]MiddlewareTime(nil), ctx.middlewareTimes...)
+}
@@ -0,0 +1,102 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+func TestMiddlewareTimes(t *testing.T) {
+	// sleepy takes d before and after calling next.
+	sleepy := func(d time.Duration) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				time.Sleep(d)
+				next.ServeHTTP(writer, request)
+				time.Sleep(d)
+			})
+		}
+	}
+	scope := tally.NewTestScope("", nil)
+	timer := scope.Timer("duration")
+	app := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+	})
+	h := timeMiddleware("a", sleepy(10*time.Millisecond), timer)(
+		timeMiddleware("b", sleepy(5*time.Millisecond), timer)(
+			timeHandler("proxy-server", timer)(app)))
+	ctx := &ProxyContext{}
+	req := httptest.NewRequest("GET", "/v1/a/c/o", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "proxycontext", ctx))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	times := ctx.MiddlewareTimes()
+	require.Equal(t, 3, len(times))
+	require.Equal(t, "a", times[0].Name)
+	require.Equal(t, "b", times[1].Name)
+	require.Equal(t, "proxy-server", times[2].Name)
+	// Each is only charged for its own time, not what comes after it.
+	require.True(t, times[0].Duration >= 20*time.Millisecond && times[0].Duration < 45*time.Millisecond, times[0].Duration.String())
+	require.True(t, times[1].Duration >= 10*time.Millisecond && times[1].Duration < 30*time.Millisecond, times[1].Duration.String())
+	require.True(t, times[2].Duration >= 30*time.Millisecond, times[2].Duration.String())
+	require.Equal(t, 3, len(scope.Snapshot().Timers()["duration+"].Values()))
+
+	// Without a ProxyContext, only the metrics are recorded.
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, 6, len(scope.Snapshot().Timers()["duration+"].Values()))
+}
+
+func TestMiddlewareTimesSubrequests(t *testing.T) {
+	timer := tally.NewTestScope("", nil).Timer("duration")
+	// subrequester takes 10ms itself and makes a subrequest for /v1/a/c/o.
+	subrequester := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			time.Sleep(10 * time.Millisecond)
+			if request.URL.Path == "/v1/a/c/o" {
+				ctx := GetProxyContext(request)
+				subreq, err := ctx.newSubrequest("GET", "/v1/a/c/sub", nil, request, "test")
+				require.Nil(t, err)
+				ctx.serveHTTPSubrequest(httptest.NewRecorder(), subreq)
+			}
+			next.ServeHTTP(writer, request)
+		})
+	}
+	app := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+	})
+	h := timeMiddleware("a", subrequester, timer)(timeHandler("proxy-server", timer)(app))
+	ctx := &ProxyContext{ProxyContextMiddleware: &ProxyContextMiddleware{next: h}, Logger: zap.NewNop()}
+	req := httptest.NewRequest("GET", "/v1/a/c/o", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "proxycontext", ctx))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	times := ctx.MiddlewareTimes()
+	require.Equal(t, 2, len(times))
+	require.Equal(t, "a", times[0].Name)
+	require.Equal(t, "proxy-server", times[1].Name)
+	// The subrequest's time in each middleware is added to the request's,
+	// and not charged to the middleware that made it.
+	require.True(t, times[0].Duration >= 20*time.Millisecond && times[0].Duration < 40*time.Millisecond, times[0].Duration.String())
+	require.True(t, times[1].Duration >= 60*time.Millisecond, times[1].Duration.String())
+}
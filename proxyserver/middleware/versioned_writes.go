@@ -115,6 +115,10 @@ func (vcw *VersionedContainerWriter) WriteHeader(status int) {
 	vcw.ResponseWriter.WriteHeader(status)
 }
 
+func (vcw *VersionedContainerWriter) Flush() {
+	srv.Flush(vcw.ResponseWriter)
+}
+
 func (v *versionedWrites) handleContainer(writer http.ResponseWriter, request *http.Request) {
 	versionsLocs, v_ok := request.Header[CLIENT_VERSIONS_LOC]
 	historyLocs, h_ok := request.Header[CLIENT_HISTORY_LOC]
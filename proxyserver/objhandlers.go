@@ -16,9 +16,11 @@
 package proxyserver
 
 import (
+	"io"
 	"mime"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"go.uber.org/zap"
@@ -29,6 +31,25 @@ import (
 	"github.com/troubling/hummingbird/proxyserver/middleware"
 )
 
+// openExpired turns the client's X-Open-Expired into the backend header that
+// has object servers serve expired objects they haven't removed yet, if
+// allow_open_expired is set or the user is a reseller admin, and returns
+// whether it did.  It has to be called after the request is authorized.
+func (server *ProxyServer) openExpired(ctx *middleware.ProxyContext, request *http.Request) bool {
+	open := common.LooksTrue(request.Header.Get(common.OpenExpiredHeader)) && (server.allowOpenExpired || ctx.ResellerRequest)
+	request.Header.Del(common.OpenExpiredHeader)
+	if open {
+		request.Header.Set(common.BackendOpenExpiredHeader, "true")
+	}
+	return open
+}
+
+// servedExpired returns whether resp is a backend serving an object whose
+// X-Delete-At has passed when the client didn't ask for expired objects.
+func servedExpired(resp *http.Response, open bool) bool {
+	return !open && resp.StatusCode/100 == 2 && common.DeleteAtPassed(resp.Header.Get("X-Delete-At"))
+}
+
 func (server *ProxyServer) ObjectGetHandler(writer http.ResponseWriter, request *http.Request) {
 	vars := srv.GetVars(request)
 	ctx := middleware.GetProxyContext(request)
@@ -61,7 +82,17 @@ func (server *ProxyServer) ObjectGetHandler(writer http.ResponseWriter, request
 			return
 		}
 	}
+	if status, msg := server.checkPolicyAPI(ctx, containerInfo); status != http.StatusOK {
+		srv.SimpleErrorResponse(writer, status, msg)
+		return
+	}
+	open := server.openExpired(ctx, request)
 	resp := ctx.C.GetObject(request.Context(), vars["account"], vars["container"], vars["obj"], request.Header)
+	if servedExpired(resp, open) {
+		resp.Body.Close()
+		srv.StandardResponse(writer, http.StatusNotFound)
+		return
+	}
 	for k := range resp.Header {
 		writer.Header().Set(k, resp.Header.Get(k))
 	}
@@ -102,7 +133,17 @@ func (server *ProxyServer) ObjectHeadHandler(writer http.ResponseWriter, request
 			return
 		}
 	}
+	if status, msg := server.checkPolicyAPI(ctx, containerInfo); status != http.StatusOK {
+		srv.SimpleErrorResponse(writer, status, msg)
+		return
+	}
+	open := server.openExpired(ctx, request)
 	resp := ctx.C.HeadObject(request.Context(), vars["account"], vars["container"], vars["obj"], request.Header)
+	if servedExpired(resp, open) {
+		resp.Body.Close()
+		srv.StandardResponse(writer, http.StatusNotFound)
+		return
+	}
 	for k := range resp.Header {
 		writer.Header().Set(k, resp.Header.Get(k))
 	}
@@ -142,8 +183,17 @@ func (server *ProxyServer) ObjectDeleteHandler(writer http.ResponseWriter, reque
 			return
 		}
 	}
+	if status, msg := server.checkPolicyAPI(ctx, containerInfo); status != http.StatusOK {
+		srv.SimpleErrorResponse(writer, status, msg)
+		return
+	}
+	if status := server.guardTimestamp(ctx, request, vars["account"], vars["container"], vars["obj"]); status != http.StatusOK {
+		srv.StandardResponse(writer, status)
+		return
+	}
 	resp := ctx.C.DeleteObject(request.Context(), vars["account"], vars["container"], vars["obj"], request.Header)
 	resp.Body.Close()
+	server.recordTimestamp(ctx, request, vars["account"], vars["container"], vars["obj"], resp.StatusCode)
 	srv.StandardResponse(writer, resp.StatusCode)
 }
 
@@ -179,14 +229,93 @@ func (server *ProxyServer) ObjectPostHandler(writer http.ResponseWriter, request
 			return
 		}
 	}
+	if status, msg := server.checkPolicyAPI(ctx, containerInfo); status != http.StatusOK {
+		srv.SimpleErrorResponse(writer, status, msg)
+		return
+	}
 	if status, str := common.CheckObjPost(request, vars["obj"]); status != http.StatusOK {
 		writer.Header().Set("Content-Type", "text/plain")
 		writer.WriteHeader(status)
 		writer.Write([]byte(str))
 		return
 	}
+	server.openExpired(ctx, request)
+	if status := server.guardTimestamp(ctx, request, vars["account"], vars["container"], vars["obj"]); status != http.StatusOK {
+		srv.StandardResponse(writer, status)
+		return
+	}
 	resp := ctx.C.PostObject(request.Context(), vars["account"], vars["container"], vars["obj"], request.Header)
 	resp.Body.Close()
+	server.recordTimestamp(ctx, request, vars["account"], vars["container"], vars["obj"], resp.StatusCode)
+	srv.StandardResponse(writer, resp.StatusCode)
+}
+
+// ObjectAppendHandler adds the request body to the end of an existing
+// object. X-Append-Offset, if set, has to match the object's current length,
+// so a client can retry an append without duplicating data.
+func (server *ProxyServer) ObjectAppendHandler(writer http.ResponseWriter, request *http.Request) {
+	vars := srv.GetVars(request)
+	ctx := middleware.GetProxyContext(request)
+	if ctx == nil {
+		server.logger.Error("could not get proxy context")
+		srv.StandardResponse(writer, 500)
+		return
+	}
+	containerInfo, err := ctx.C.GetContainerInfo(request.Context(), vars["account"], vars["container"])
+	if err != nil {
+		ctx.ACL = ""
+		if ctx.Authorize != nil {
+			if ok, s := ctx.Authorize(request); !ok {
+				srv.StandardResponse(writer, s)
+				return
+			}
+		}
+		if err == client.ContainerNotFound {
+			srv.StandardResponse(writer, 404)
+			return
+		}
+		ctx.Logger.Error("object APPEND: container error", zap.String("container", vars["container"]), zap.Error(err))
+		srv.StandardResponse(writer, 500)
+		return
+	}
+	ctx.ACL = containerInfo.WriteACL
+	if ctx.Authorize != nil {
+		if ok, s := ctx.Authorize(request); !ok {
+			srv.StandardResponse(writer, s)
+			return
+		}
+	}
+	if status, msg := server.checkPolicyAPI(ctx, containerInfo); status != http.StatusOK {
+		srv.SimpleErrorResponse(writer, status, msg)
+		return
+	}
+	if request.ContentLength > common.MAX_FILE_SIZE {
+		srv.SimpleErrorResponse(writer, http.StatusRequestEntityTooLarge, "Your request is too large.")
+		return
+	}
+	if request.Header.Get("Content-Length") == "" && !common.StringInSlice("chunked", request.TransferEncoding) {
+		srv.SimpleErrorResponse(writer, http.StatusLengthRequired, "Missing Content-Length header.")
+		return
+	}
+	if offset := request.Header.Get("X-Append-Offset"); offset != "" {
+		if _, err := strconv.ParseInt(offset, 10, 64); err != nil {
+			srv.SimpleErrorResponse(writer, http.StatusBadRequest, "Invalid X-Append-Offset")
+			return
+		}
+	}
+	if status := server.guardTimestamp(ctx, request, vars["account"], vars["container"], vars["obj"]); status != http.StatusOK {
+		srv.StandardResponse(writer, status)
+		return
+	}
+	resp := ctx.C.AppendObject(request.Context(), vars["account"], vars["container"], vars["obj"], request.Header, request.Body)
+	resp.Body.Close()
+	server.recordTimestamp(ctx, request, vars["account"], vars["container"], vars["obj"], resp.StatusCode)
+	writer.Header().Set("Etag", resp.Header.Get("Etag"))
+	for key := range resp.Header {
+		if strings.HasPrefix(key, common.ChecksumHeaderPrefix) {
+			writer.Header().Set(key, resp.Header.Get(key))
+		}
+	}
 	srv.StandardResponse(writer, resp.StatusCode)
 }
 
@@ -227,6 +356,10 @@ func (server *ProxyServer) ObjectPutHandler(writer http.ResponseWriter, request
 			return
 		}
 	}
+	// Only authorized PUTs get to open connections to the object servers.
+	if p, ok := ctx.C.(client.ObjectWritePredialer); ok {
+		p.PredialObjectWrite(vars["account"], vars["container"], vars["obj"])
+	}
 	if request.Header.Get("Content-Type") == "" || common.LooksTrue(request.Header.Get("X-Detect-Content-Type")) {
 		contentType := mime.TypeByExtension(filepath.Ext(vars["obj"]))
 		contentType = strings.Split(contentType, ";")[0] // remove any charset it tried to foist on us
@@ -241,11 +374,56 @@ func (server *ProxyServer) ObjectPutHandler(writer http.ResponseWriter, request
 		writer.Write([]byte(str))
 		return
 	}
-	resp := ctx.C.PutObject(request.Context(), vars["account"], vars["container"], vars["obj"], request.Header, request.Body)
+	if status, msg := server.checkPolicyPut(ctx, request, vars["account"], containerInfo); status != http.StatusOK {
+		srv.SimpleErrorResponse(writer, status, msg)
+		return
+	}
+	var body io.Reader = request.Body
+	// A Content-MD5 is checked here as the body streams through, and passed
+	// on as the ETag so the object servers won't commit a body that fails it.
+	var md5Reader *common.ContentMD5Reader
+	if contentMD5 := request.Header.Get("Content-MD5"); contentMD5 != "" {
+		if md5Reader, err = common.NewContentMD5Reader(request.Body, contentMD5); err != nil {
+			srv.SimpleErrorResponse(writer, http.StatusBadRequest, err.Error())
+			return
+		}
+		if etag := strings.Trim(strings.ToLower(request.Header.Get("Etag")), "\""); etag != "" && etag != md5Reader.Etag() {
+			srv.SimpleErrorResponse(writer, http.StatusBadRequest, "Content-MD5 and ETag don't match")
+			return
+		}
+		request.Header.Set("Etag", md5Reader.Etag())
+		body = md5Reader
+	}
+	if len(request.Trailer) > 0 {
+		body = client.WithTrailer(body, request.Trailer)
+	}
+	if status := server.guardTimestamp(ctx, request, vars["account"], vars["container"], vars["obj"]); status != http.StatusOK {
+		srv.StandardResponse(writer, status)
+		return
+	}
+	resp := ctx.C.PutObject(request.Context(), vars["account"], vars["container"], vars["obj"], request.Header, body)
 	resp.Body.Close()
+	if policySizeExceeded(request.Body) {
+		srv.SimpleErrorResponse(writer, http.StatusRequestEntityTooLarge, "Your request is too large for the container's storage policy.")
+		return
+	}
+	if md5Reader != nil && md5Reader.Mismatch() {
+		srv.SimpleErrorResponse(writer, http.StatusUnprocessableEntity, common.ErrContentMD5Mismatch.Error())
+		return
+	}
+	if handoffs, err := strconv.Atoi(resp.Header.Get("X-Backend-Handoff-Used")); err == nil {
+		server.metricsScope.Counter("object_put_handoffs").Inc(int64(handoffs))
+		ctx.Logger.Info("Object PUT used handoffs", zap.Int("handoffs", handoffs), zap.Int("status", resp.StatusCode))
+	}
 	writer.Header().Set("Etag", resp.Header.Get("Etag"))
+	for key := range resp.Header {
+		if strings.HasPrefix(key, common.ChecksumHeaderPrefix) {
+			writer.Header().Set(key, resp.Header.Get(key))
+		}
+	}
 	if modified, err := common.ParseDate(request.Header.Get("X-Timestamp")); err == nil {
 		writer.Header().Set("Last-Modified", common.FormatLastModified(modified))
 	}
+	server.recordTimestamp(ctx, request, vars["account"], vars["container"], vars["obj"], resp.StatusCode)
 	srv.StandardResponse(writer, resp.StatusCode)
 }
@@ -0,0 +1,145 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package proxyserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/client"
+	"github.com/troubling/hummingbird/client/clienttest"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/proxyserver/middleware"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+func TestOpenExpired(t *testing.T) {
+	for _, tc := range []struct {
+		allow, reseller bool
+		header          string
+		open            bool
+	}{
+		{false, false, "true", false},
+		{true, false, "true", true},
+		{false, true, "yes", true},
+		{true, true, "", false},
+	} {
+		server := &ProxyServer{allowOpenExpired: tc.allow}
+		req, err := http.NewRequest("GET", "/v1/a/c/o", nil)
+		require.Nil(t, err)
+		req.Header.Set("X-Open-Expired", tc.header)
+		require.Equal(t, tc.open, server.openExpired(&middleware.ProxyContext{ResellerRequest: tc.reseller}, req))
+		require.Equal(t, "", req.Header.Get("X-Open-Expired"))
+		require.Equal(t, tc.open, req.Header.Get("X-Backend-Open-Expired") == "true")
+	}
+}
+
+func TestServedExpired(t *testing.T) {
+	past := strconv.FormatInt(time.Now().Unix()-10, 10)
+	future := strconv.FormatInt(time.Now().Unix()+10, 10)
+	resp := &http.Response{StatusCode: 200, Header: http.Header{"X-Delete-At": {past}}}
+	require.True(t, servedExpired(resp, false))
+	require.False(t, servedExpired(resp, true))
+	resp.Header.Set("X-Delete-At", future)
+	require.False(t, servedExpired(resp, false))
+	resp = &http.Response{StatusCode: 404, Header: http.Header{"X-Delete-At": {past}}}
+	require.False(t, servedExpired(resp, false))
+}
+
+func TestObjectPutContentMD5(t *testing.T) {
+	store := clienttest.NewStore()
+	rc := clienttest.NewRequestClient(store)
+	require.Equal(t, 201, rc.PutContainer(context.Background(), "a", "c", http.Header{}).StatusCode)
+	server := &ProxyServer{logger: zap.NewNop(), metricsScope: tally.NoopScope}
+	for _, tc := range []struct {
+		contentMD5, etag string
+		status           int
+	}{
+		{"XUFAKrxLKna5cZ2REBfFkg==", "", 201},
+		{"XUFAKrxLKna5cZ2REBfFkg==", "5d41402abc4b2a76b9719d911017c592", 201},
+		{"XUFAKrxLKna5cZ2REBfFkg==", "00000000000000000000000000000000", 400},
+		{"XUFAKrxLKna5", "", 400},
+		{"1B2M2Y8AsgTpgAmY7PhCfg==", "", 422},
+	} {
+		req, err := http.NewRequest("PUT", "/v1/a/c/o", strings.NewReader("hello"))
+		require.Nil(t, err)
+		req.Header.Set("Content-Length", "5")
+		req.Header.Set("Content-MD5", tc.contentMD5)
+		req.Header.Set("Etag", tc.etag)
+		req = srv.SetVars(req, map[string]string{"account": "a", "container": "c", "obj": "o"})
+		req = req.WithContext(context.WithValue(req.Context(), "proxycontext", &middleware.ProxyContext{C: rc, Logger: zap.NewNop()}))
+		rec := httptest.NewRecorder()
+		server.ObjectPutHandler(rec, req)
+		require.Equal(t, tc.status, rec.Code, tc.contentMD5+" "+tc.etag)
+	}
+	// the container and the two good objects; bad headers are turned away
+	// before anything's sent, and the mismatched body fails as it's read,
+	// so the store never takes it
+	require.Equal(t, 3, store.Requests["PUT"])
+	resp := rc.HeadObject(context.Background(), "a", "c", "o", http.Header{})
+	require.Equal(t, "5d41402abc4b2a76b9719d911017c592", resp.Header.Get("Etag"))
+}
+
+type predialingClient struct {
+	client.RequestClient
+	predials []string
+}
+
+func (c *predialingClient) PredialObjectWrite(account, container, obj string) {
+	c.predials = append(c.predials, account+"/"+container+"/"+obj)
+}
+
+func TestObjectPutPredialsAfterAuth(t *testing.T) {
+	store := clienttest.NewStore()
+	rc := &predialingClient{RequestClient: clienttest.NewRequestClient(store)}
+	require.Equal(t, 201, rc.PutContainer(context.Background(), "a", "c", http.Header{}).StatusCode)
+	server := &ProxyServer{logger: zap.NewNop(), metricsScope: tally.NoopScope}
+	for _, tc := range []struct {
+		container  string
+		authorized bool
+		status     int
+		predials   int
+	}{
+		{"c", false, 401, 0},
+		{"nope", true, 404, 0},
+		{"c", true, 201, 1},
+	} {
+		rc.predials = nil
+		req, err := http.NewRequest("PUT", "/v1/a/"+tc.container+"/o", strings.NewReader("hello"))
+		require.Nil(t, err)
+		req.Header.Set("Content-Length", "5")
+		req = srv.SetVars(req, map[string]string{"account": "a", "container": tc.container, "obj": "o"})
+		authorized := tc.authorized
+		ctx := &middleware.ProxyContext{C: rc, Logger: zap.NewNop(), Authorize: func(r *http.Request) (bool, int) {
+			if !authorized {
+				return false, 401
+			}
+			return true, 200
+		}}
+		req = req.WithContext(context.WithValue(req.Context(), "proxycontext", ctx))
+		rec := httptest.NewRecorder()
+		server.ObjectPutHandler(rec, req)
+		require.Equal(t, tc.status, rec.Code)
+		require.Equal(t, tc.predials, len(rc.predials))
+	}
+}
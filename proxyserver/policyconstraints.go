@@ -0,0 +1,146 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package proxyserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/troubling/hummingbird/client"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/proxyserver/middleware"
+)
+
+var errPolicyMaxObjectSize = errors.New("object is larger than its storage policy allows")
+
+// policyConstraints returns the constraints of the policy with the given
+// index. They were checked when the policies were loaded.
+func (server *ProxyServer) policyConstraints(index int) conf.PolicyConstraints {
+	if policy := server.policies[index]; policy != nil {
+		c, _ := policy.GetConstraints()
+		return c
+	}
+	return conf.PolicyConstraints{}
+}
+
+// checkPolicyAPI returns 403 if the request came through an API the
+// container's storage policy doesn't allow.
+func (server *ProxyServer) checkPolicyAPI(ctx *middleware.ProxyContext, containerInfo *client.ContainerInfo) (int, string) {
+	api := "swift"
+	if ctx.S3Auth != nil {
+		api = "s3"
+	}
+	if !server.policyConstraints(containerInfo.StoragePolicyIndex).AllowsAPI(api) {
+		return http.StatusForbidden, fmt.Sprintf("The container's storage policy doesn't allow the %s API.", api)
+	}
+	return http.StatusOK, ""
+}
+
+// checkPolicyPut returns an error status and message if an object PUT breaks
+// any constraint of the container's storage policy, or of the policies of an
+// SLO's segments. Chunked uploads can only be checked against the policy's
+// max_object_size as they stream, so their bodies are wrapped in a
+// policySizeReader.
+func (server *ProxyServer) checkPolicyPut(ctx *middleware.ProxyContext, request *http.Request, account string, containerInfo *client.ContainerInfo) (int, string) {
+	if status, msg := server.checkPolicyAPI(ctx, containerInfo); status != http.StatusOK {
+		return status, msg
+	}
+	c := server.policyConstraints(containerInfo.StoragePolicyIndex)
+	if request.Header.Get("X-Object-Manifest") != "" && !c.AllowsAPI("dlo") {
+		return http.StatusForbidden, "The container's storage policy doesn't allow DLO manifests."
+	}
+	if c.MaxObjectSize > 0 {
+		if request.ContentLength > c.MaxObjectSize {
+			return http.StatusRequestEntityTooLarge, fmt.Sprintf("Objects in the container's storage policy may be at most %d bytes.", c.MaxObjectSize)
+		}
+		if request.ContentLength < 0 {
+			request.Body = &policySizeReader{ReadCloser: request.Body, left: c.MaxObjectSize}
+		}
+	}
+	if !common.LooksTrue(request.Header.Get("X-Static-Large-Object")) {
+		return http.StatusOK, ""
+	}
+	if !c.AllowsAPI("slo") {
+		return http.StatusForbidden, "The container's storage policy doesn't allow SLO manifests."
+	}
+	// The SLO middleware has already checked the manifest and written it
+	// out with each segment's actual size.
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return http.StatusBadRequest, "Unable to read manifest."
+	}
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	var manifest []struct {
+		Name  string `json:"name"`
+		Bytes int64  `json:"bytes"`
+		Range string `json:"range"`
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return http.StatusBadRequest, "Invalid manifest."
+	}
+	for i, seg := range manifest {
+		if i == len(manifest)-1 {
+			// The last segment may be as small as it needs to be.
+			break
+		}
+		size := seg.Bytes
+		if seg.Range != "" {
+			if ranges, err := common.ParseRange("bytes="+seg.Range, seg.Bytes); err == nil && len(ranges) == 1 {
+				size = ranges[0].End - ranges[0].Start
+			}
+		}
+		parts := strings.SplitN(strings.TrimPrefix(seg.Name, "/"), "/", 2)
+		segInfo, err := ctx.C.GetContainerInfo(request.Context(), account, parts[0])
+		if err != nil {
+			continue
+		}
+		if min := server.policyConstraints(segInfo.StoragePolicyIndex).MinSegmentSize; size < min {
+			return http.StatusBadRequest, fmt.Sprintf("Index %d: too small; each segment in the storage policy of %s must be at least %d bytes.", i, parts[0], min)
+		}
+	}
+	return http.StatusOK, ""
+}
+
+// policySizeReader fails a chunked upload once it passes its policy's
+// max_object_size.
+type policySizeReader struct {
+	io.ReadCloser
+	left int64
+	over bool
+}
+
+func (r *policySizeReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if r.left -= int64(n); r.left < 0 {
+		r.over = true
+		return n, errPolicyMaxObjectSize
+	}
+	return n, err
+}
+
+// policySizeExceeded returns whether body was cut off for passing its
+// policy's max_object_size.
+func policySizeExceeded(body io.Reader) bool {
+	r, ok := body.(*policySizeReader)
+	return ok && r.over
+}
@@ -0,0 +1,77 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package proxyserver
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/client"
+	"github.com/troubling/hummingbird/client/clienttest"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/proxyserver/middleware"
+	"go.uber.org/zap"
+)
+
+func TestPolicyConstraints(t *testing.T) {
+	rc := clienttest.NewRequestClient(clienttest.NewStore())
+	require.Equal(t, 201, rc.PutContainer(context.Background(), "a", "segs", http.Header{}).StatusCode)
+	server := &ProxyServer{logger: zap.NewNop(), policies: conf.PolicyList{0: {Index: 0, Name: "ec", Default: true,
+		Config: map[string]string{"min_segment_size": "10", "max_object_size": "100", "allowed_apis": "swift, slo"}}}}
+	ctx := &middleware.ProxyContext{C: rc, Logger: zap.NewNop()}
+	ci := &client.ContainerInfo{StoragePolicyIndex: 0}
+	put := func(body string, headers ...string) (*http.Request, int) {
+		req := httptest.NewRequest("PUT", "/v1/a/c/o", strings.NewReader(body))
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		status, _ := server.checkPolicyPut(ctx, req, "a", ci)
+		return req, status
+	}
+
+	_, status := put("data")
+	require.Equal(t, 200, status)
+	_, status = put(strings.Repeat("x", 101))
+	require.Equal(t, 413, status)
+	_, status = put("", "X-Object-Manifest", "segs/o")
+	require.Equal(t, 403, status)
+
+	req, status := put(`[{"name":"/segs/1","bytes":5},{"name":"/segs/2","bytes":3}]`, "X-Static-Large-Object", "True")
+	require.Equal(t, 400, status)
+	req, status = put(`[{"name":"/segs/1","bytes":50,"range":"0-9"},{"name":"/segs/2","bytes":3}]`, "X-Static-Large-Object", "True")
+	require.Equal(t, 200, status)
+	body, err := ioutil.ReadAll(req.Body)
+	require.Nil(t, err)
+	require.Contains(t, string(body), "/segs/2")
+
+	// Chunked uploads are cut off as they pass the limit.
+	req = httptest.NewRequest("PUT", "/v1/a/c/o", strings.NewReader(strings.Repeat("x", 150)))
+	req.ContentLength = -1
+	status, _ = server.checkPolicyPut(ctx, req, "a", ci)
+	require.Equal(t, 200, status)
+	_, err = ioutil.ReadAll(req.Body)
+	require.NotNil(t, err)
+	require.True(t, policySizeExceeded(req.Body))
+
+	ctx.S3Auth = &middleware.S3AuthInfo{Account: "a"}
+	status, _ = server.checkPolicyAPI(ctx, ci)
+	require.Equal(t, 403, status)
+}
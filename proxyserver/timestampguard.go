@@ -0,0 +1,87 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package proxyserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/proxyserver/middleware"
+	"go.uber.org/zap"
+)
+
+// The smallest step between two X-Timestamps.
+const timestampTick = 0.00001
+
+func timestampGuardKey(account, container, obj string) string {
+	return fmt.Sprintf("objts/%s/%s/%s", account, container, obj)
+}
+
+// guardTimestamp checks an object write's X-Timestamp against the newest
+// one a proxy has written the object with. If this proxy's clock is behind,
+// the write would otherwise lose to the older one on the object servers. In
+// "adjust" mode the X-Timestamp is moved just past the last one; in "reject"
+// mode the write fails with 409 Conflict. Container sync writes keep the
+// timestamps from their source cluster, so they aren't checked.
+func (server *ProxyServer) guardTimestamp(ctx *middleware.ProxyContext, request *http.Request, account, container, obj string) int {
+	if server.timestampGuard == "" || ctx.Cache == nil || request.Header.Get("X-Container-Sync-Auth") != "" {
+		return http.StatusOK
+	}
+	ts, err := strconv.ParseFloat(request.Header.Get("X-Timestamp"), 64)
+	if err != nil {
+		return http.StatusOK
+	}
+	var lastStr string
+	if err := ctx.Cache.GetStructured(request.Context(), timestampGuardKey(account, container, obj), &lastStr); err != nil {
+		return http.StatusOK
+	}
+	last, err := strconv.ParseFloat(lastStr, 64)
+	if err != nil || ts > last {
+		return http.StatusOK
+	}
+	if server.timestampGuard == "reject" {
+		server.metricsScope.Counter("timestamp_guard_rejects").Inc(1)
+		ctx.Logger.Info("Rejecting write with a timestamp behind the object's last", zap.String("timestamp", request.Header.Get("X-Timestamp")), zap.String("last", lastStr))
+		return http.StatusConflict
+	}
+	server.metricsScope.Counter("timestamp_guard_adjusts").Inc(1)
+	ctx.Logger.Info("Adjusting write with a timestamp behind the object's last", zap.String("timestamp", request.Header.Get("X-Timestamp")), zap.String("last", lastStr))
+	request.Header.Set("X-Timestamp", common.CanonicalTimestamp(last+timestampTick))
+	return http.StatusOK
+}
+
+// recordTimestamp notes the X-Timestamp a write succeeded with, for
+// guardTimestamp to check later writes against. A newer timestamp already
+// recorded, by a write that finished first, is kept.
+func (server *ProxyServer) recordTimestamp(ctx *middleware.ProxyContext, request *http.Request, account, container, obj string, status int) {
+	if server.timestampGuard == "" || ctx.Cache == nil || status/100 != 2 {
+		return
+	}
+	ts, err := strconv.ParseFloat(request.Header.Get("X-Timestamp"), 64)
+	if err != nil {
+		return
+	}
+	key := timestampGuardKey(account, container, obj)
+	var lastStr string
+	if err := ctx.Cache.GetStructured(request.Context(), key, &lastStr); err == nil {
+		if last, err := strconv.ParseFloat(lastStr, 64); err == nil && last >= ts {
+			return
+		}
+	}
+	ctx.Cache.Set(request.Context(), key, request.Header.Get("X-Timestamp"), server.timestampGuardTTL)
+}
@@ -0,0 +1,89 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package proxyserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common/test"
+	"github.com/troubling/hummingbird/proxyserver/middleware"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+func TestGuardTimestamp(t *testing.T) {
+	mc := &test.FakeMemcacheRing{MockGetStructured: map[string][]byte{
+		"objts/a/c/o": []byte(`"1500000000.00000"`),
+	}}
+	ctx := &middleware.ProxyContext{
+		ProxyContextMiddleware: &middleware.ProxyContextMiddleware{Cache: mc},
+		Logger:                 zap.NewNop(),
+	}
+	server := &ProxyServer{logger: zap.NewNop(), metricsScope: tally.NoopScope}
+	guard := func(obj, timestamp string, header http.Header) (int, string) {
+		req := httptest.NewRequest("PUT", "/v1/a/c/"+obj, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req.Header.Set("X-Timestamp", timestamp)
+		status := server.guardTimestamp(ctx, req, "a", "c", obj)
+		return status, req.Header.Get("X-Timestamp")
+	}
+
+	// off by default
+	status, ts := guard("o", "1499999999.00000", nil)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "1499999999.00000", ts)
+
+	server.timestampGuard = "adjust"
+	status, ts = guard("o", "1499999999.00000", nil)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "1500000000.00001", ts)
+	status, ts = guard("o", "1500000000.00000", nil)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "1500000000.00001", ts)
+	status, ts = guard("o", "1500000001.00000", nil)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "1500000001.00000", ts)
+	status, ts = guard("other", "1499999999.00000", nil)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "1499999999.00000", ts)
+	// container sync keeps the source cluster's timestamps
+	status, ts = guard("o", "1499999999.00000", http.Header{"X-Container-Sync-Auth": {"US nonce sig"}})
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "1499999999.00000", ts)
+
+	server.timestampGuard = "reject"
+	status, _ = guard("o", "1499999999.00000", nil)
+	require.Equal(t, http.StatusConflict, status)
+	status, _ = guard("o", "1500000001.00000", nil)
+	require.Equal(t, http.StatusOK, status)
+
+	req := httptest.NewRequest("PUT", "/v1/a/c/o", nil)
+	req.Header.Set("X-Timestamp", "1500000002.00000")
+	server.recordTimestamp(ctx, req, "a", "c", "o", http.StatusServiceUnavailable)
+	require.Equal(t, 0, len(mc.MockSetValues))
+	server.recordTimestamp(ctx, req, "a", "c", "o", http.StatusCreated)
+	require.Equal(t, []interface{}{"1500000002.00000"}, mc.MockSetValues)
+	// A write finishing after a newer one doesn't move the timestamp back.
+	mc.MockSetValues = nil
+	req.Header.Set("X-Timestamp", "1499999999.00000")
+	server.recordTimestamp(ctx, req, "a", "c", "o", http.StatusCreated)
+	require.Equal(t, 0, len(mc.MockSetValues))
+}
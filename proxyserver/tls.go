@@ -0,0 +1,121 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package proxyserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/troubling/hummingbird/common/conf"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// getTLSConfig returns the TLS config for the proxy's listener from the tls_*
+// and acme_* settings in [app:proxy-server], or nil if there are none, in
+// which case the DEFAULT cert_file and key_file are served as before. Extra
+// certificates in tls_sni_certs are chosen by the client's SNI server name,
+// tls_client_ca_file requires client certificates, and hosts in acme_domains
+// get their certificates from an ACME CA such as Let's Encrypt.
+func getTLSConfig(serverconf conf.Config, certFile, keyFile string) (*tls.Config, error) {
+	section := "app:proxy-server"
+	tlsCertFile := serverconf.GetDefault(section, "tls_cert_file", "")
+	tlsKeyFile := serverconf.GetDefault(section, "tls_key_file", "")
+	sniCerts := serverconf.GetDefault(section, "tls_sni_certs", "")
+	clientCAFile := serverconf.GetDefault(section, "tls_client_ca_file", "")
+	acmeDomains := serverconf.GetDefault(section, "acme_domains", "")
+	if tlsCertFile == "" && sniCerts == "" && clientCAFile == "" && acmeDomains == "" {
+		return nil, nil
+	}
+	if tlsCertFile != "" || tlsKeyFile != "" {
+		certFile, keyFile = tlsCertFile, tlsKeyFile
+	}
+	tlsConf := &tls.Config{
+		PreferServerCipherSuites: true,
+		MinVersion:               tls.VersionTLS12,
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading TLS certificate %s: %v", certFile, err)
+		}
+		tlsConf.Certificates = append(tlsConf.Certificates, cert)
+	}
+	for _, pair := range strings.Split(sniCerts, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		files := strings.SplitN(pair, ":", 2)
+		if len(files) != 2 {
+			return nil, fmt.Errorf("Invalid tls_sni_certs entry %q, should be cert_file:key_file", pair)
+		}
+		cert, err := tls.LoadX509KeyPair(files[0], files[1])
+		if err != nil {
+			return nil, fmt.Errorf("Error loading TLS certificate %s: %v", files[0], err)
+		}
+		tlsConf.Certificates = append(tlsConf.Certificates, cert)
+	}
+	if clientCAFile != "" {
+		pem, err := ioutil.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading tls_client_ca_file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in tls_client_ca_file %s", clientCAFile)
+		}
+		tlsConf.ClientCAs = pool
+		tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if acmeDomains != "" {
+		var domains []string
+		for _, domain := range strings.Split(acmeDomains, ",") {
+			if domain = strings.TrimSpace(domain); domain != "" {
+				domains = append(domains, domain)
+			}
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(domains...),
+			Cache:      autocert.DirCache(serverconf.GetDefault(section, "acme_cache_dir", "/var/cache/hummingbird/acme")),
+			Email:      serverconf.GetDefault(section, "acme_email", ""),
+		}
+		if url := serverconf.GetDefault(section, "acme_directory_url", ""); url != "" {
+			manager.Client = &acme.Client{DirectoryURL: url}
+		}
+		acmeHosts := make(map[string]bool, len(domains))
+		for _, domain := range domains {
+			acmeHosts[strings.ToLower(domain)] = true
+		}
+		// Challenges and the ACME hosts go to the manager; anything else
+		// falls through to the configured certificates.
+		tlsConf.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if acmeHosts[strings.ToLower(hello.ServerName)] || (len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acme.ALPNProto) {
+				return manager.GetCertificate(hello)
+			}
+			return nil, nil
+		}
+		tlsConf.NextProtos = append(tlsConf.NextProtos, acme.ALPNProto)
+	}
+	if len(tlsConf.Certificates) == 0 && tlsConf.GetCertificate == nil {
+		return nil, fmt.Errorf("TLS is configured without any certificates")
+	}
+	return tlsConf, nil
+}
@@ -0,0 +1,126 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package proxyserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common/conf"
+)
+
+func writeTestCert(t *testing.T, dir, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	require.Nil(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.Nil(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
+
+func TestGetTLSConfigNotConfigured(t *testing.T) {
+	config, err := conf.StringConfig("[app:proxy-server]\n")
+	require.Nil(t, err)
+	tlsConf, err := getTLSConfig(config, "/some/cert", "/some/key")
+	require.Nil(t, err)
+	require.Nil(t, tlsConf)
+}
+
+func TestGetTLSConfigSNI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCert(t, dir, "default.example.com")
+	sniCert, sniKey := writeTestCert(t, dir, "other.example.com")
+	caCert, _ := writeTestCert(t, dir, "ca.example.com")
+	config, err := conf.StringConfig(fmt.Sprintf("[app:proxy-server]\ntls_sni_certs = %s:%s\ntls_client_ca_file = %s\n", sniCert, sniKey, caCert))
+	require.Nil(t, err)
+	tlsConf, err := getTLSConfig(config, certFile, keyFile)
+	require.Nil(t, err)
+	require.NotNil(t, tlsConf)
+	require.Equal(t, 2, len(tlsConf.Certificates))
+	require.Equal(t, tls.RequireAndVerifyClientCert, tlsConf.ClientAuth)
+	require.NotNil(t, tlsConf.ClientCAs)
+	require.Nil(t, tlsConf.GetCertificate)
+
+	tlsConf.BuildNameToCertificate()
+	require.Equal(t, &tlsConf.Certificates[1], tlsConf.NameToCertificate["other.example.com"])
+	require.Equal(t, &tlsConf.Certificates[0], tlsConf.NameToCertificate["default.example.com"])
+}
+
+func TestGetTLSConfigErrors(t *testing.T) {
+	config, err := conf.StringConfig("[app:proxy-server]\ntls_cert_file = /nonexistent/cert\ntls_key_file = /nonexistent/key\n")
+	require.Nil(t, err)
+	_, err = getTLSConfig(config, "", "")
+	require.NotNil(t, err)
+
+	config, err = conf.StringConfig("[app:proxy-server]\ntls_sni_certs = justonefile\n")
+	require.Nil(t, err)
+	_, err = getTLSConfig(config, "", "")
+	require.NotNil(t, err)
+
+	config, err = conf.StringConfig("[app:proxy-server]\ntls_client_ca_file = /nonexistent/ca\n")
+	require.Nil(t, err)
+	_, err = getTLSConfig(config, "", "")
+	require.NotNil(t, err)
+}
+
+func TestGetTLSConfigACME(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCert(t, dir, "default.example.com")
+	config, err := conf.StringConfig(fmt.Sprintf("[app:proxy-server]\nacme_domains = swift.example.com\nacme_cache_dir = %s\n", dir))
+	require.Nil(t, err)
+	tlsConf, err := getTLSConfig(config, certFile, keyFile)
+	require.Nil(t, err)
+	require.NotNil(t, tlsConf.GetCertificate)
+	require.Contains(t, tlsConf.NextProtos, "acme-tls/1")
+	// Names that aren't ACME domains are left to the static certificates.
+	cert, err := tlsConf.GetCertificate(&tls.ClientHelloInfo{ServerName: "default.example.com"})
+	require.Nil(t, err)
+	require.Nil(t, cert)
+}
@@ -99,6 +99,9 @@ type testDispersionClient struct {
 func (c *testDispersionClient) SetUserAgent(v string) {
 }
 
+func (c *testDispersionClient) SetPriority(v string) {
+}
+
 func (c *testDispersionClient) PutAccount(ctx context.Context, account string, headers http.Header) *http.Response {
 	return nectarutil.ResponseStub(200, "")
 }
@@ -163,12 +166,20 @@ func (c *testDispersionClient) DeleteContainer(ctx context.Context, account stri
 	return nectarutil.ResponseStub(200, "")
 }
 
+func (c *testDispersionClient) RestoreContainer(ctx context.Context, account string, container string, headers http.Header) *http.Response {
+	return nectarutil.ResponseStub(200, "")
+}
+
 func (c *testDispersionClient) PutObject(ctx context.Context, account string, container string, obj string, headers http.Header, src io.Reader) *http.Response {
 	fmt.Println("PutObject", account, container, obj)
 	c.objPuts++
 	return nectarutil.ResponseStub(200, "")
 }
 
+func (c *testDispersionClient) AppendObject(ctx context.Context, account string, container string, obj string, headers http.Header, src io.Reader) *http.Response {
+	return nectarutil.ResponseStub(200, "")
+}
+
 func (c *testDispersionClient) PostObject(ctx context.Context, account string, container string, obj string, headers http.Header) *http.Response {
 	return nectarutil.ResponseStub(200, "")
 }
@@ -188,6 +199,18 @@ func (c *testDispersionClient) DeleteObject(ctx context.Context, account string,
 	return nectarutil.ResponseStub(200, "")
 }
 
+func (c *testDispersionClient) GetObjectInPolicy(ctx context.Context, policy int, account string, container string, obj string, headers http.Header) *http.Response {
+	return nectarutil.ResponseStub(200, "")
+}
+
+func (c *testDispersionClient) DeleteObjectInPolicy(ctx context.Context, policy int, account string, container string, obj string, headers http.Header) *http.Response {
+	return nectarutil.ResponseStub(200, "")
+}
+
+func (c *testDispersionClient) ObjectReplicaMetadata(ctx context.Context, account string, container string, obj string) ([]*client.ReplicaMetadata, *http.Response) {
+	return nil, nectarutil.ResponseStub(200, "")
+}
+
 func (c *testDispersionClient) ObjectRingFor(ctx context.Context, account string, container string) (ring.Ring, *http.Response) {
 	return c.objRing, nil //nectarutil.ResponseStub(200, "")
 }
@@ -164,7 +164,7 @@ func (dsc *dispersionScanContainers) runOnce() time.Duration {
 			time.Sleep(dsc.delay)
 			devices := ctx.ring.GetNodes(partition)
 			for _, device := range devices {
-				service := fmt.Sprintf("%s://%s:%d", device.Scheme, device.Ip, device.Port)
+				service := fmt.Sprintf("%s://%s:%d%s", device.Scheme, device.Ip, device.Port, device.PathPrefix)
 				serviceChan := serviceChans[service]
 				if serviceChan == nil {
 					serviceChan = make(chan *checkInfo, queuedPerDevice)
@@ -235,7 +235,7 @@ func (dso *dispersionScanObjects) scanDispersionObjects(logger *zap.Logger, poli
 			time.Sleep(dso.delay)
 			devices := objectRing.GetNodes(partition)
 			for shard, device := range devices {
-				service := fmt.Sprintf("%s://%s:%d", device.Scheme, device.Ip, device.Port)
+				service := fmt.Sprintf("%s://%s:%d%s", device.Scheme, device.Ip, device.Port, device.PathPrefix)
 				serviceChan := serviceChans[service]
 				if serviceChan == nil {
 					serviceChan = make(chan *checkInfo, queuedPerDevice)
@@ -0,0 +1,216 @@
+package tools
+
+// The object expirer deletes objects once their X-Delete-At passes. The proxy
+// queues each object written with an X-Delete-At in the .expiring_objects
+// account, in a container for the hour it expires in, named by the Unix time
+// the hour starts. Entries are named by the zero padded X-Delete-At and the
+// object's path, so listing a container gives them in the order they're due.
+//
+// Each pass works through the containers for hours that have started, up to
+// the first entry not yet due, deleting the objects concurrently. Each DELETE
+// carries X-If-Delete-At, so an object rewritten or given another
+// X-Delete-At since it was queued is left alone. Entries whose DELETE fails
+// stay queued and are retried on the next pass. A container is removed once
+// its hour is over and its entries are gone.
+//
+// In /etc/hummingbird/andrewd-server.conf:
+// [object-expirer]
+// interval = 300         # seconds between the starts of passes
+// report_interval = 600  # seconds between progress reports
+// concurrency = 8        # objects deleted at once
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/containerserver"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+type expirer struct {
+	aa               *AutoAdmin
+	interval         time.Duration
+	reportInterval   time.Duration
+	concurrency      int
+	passesMetric     tally.Timer
+	entriesMetric    tally.Counter
+	deletedMetric    tally.Counter
+	errorsMetric     tally.Counter
+	backlogMetric    tally.Gauge
+	backlogAgeMetric tally.Gauge
+}
+
+func newExpirer(aa *AutoAdmin) *expirer {
+	e := &expirer{
+		aa:               aa,
+		interval:         time.Duration(aa.serverconf.GetInt("object-expirer", "interval", 300)) * time.Second,
+		reportInterval:   time.Duration(aa.serverconf.GetInt("object-expirer", "report_interval", 600)) * time.Second,
+		concurrency:      int(aa.serverconf.GetInt("object-expirer", "concurrency", 8)),
+		passesMetric:     aa.metricsScope.Timer("expirer_passes"),
+		entriesMetric:    aa.metricsScope.Counter("expirer_entries"),
+		deletedMetric:    aa.metricsScope.Counter("expirer_deleted"),
+		errorsMetric:     aa.metricsScope.Counter("expirer_errors"),
+		backlogMetric:    aa.metricsScope.Gauge("expirer_backlog"),
+		backlogAgeMetric: aa.metricsScope.Gauge("expirer_backlog_age"),
+	}
+	if e.interval < 0 {
+		e.interval = time.Second
+	}
+	if e.reportInterval < 0 {
+		e.reportInterval = time.Second
+	}
+	if e.concurrency < 1 {
+		e.concurrency = 1
+	}
+	return e
+}
+
+func (e *expirer) runForever() {
+	for {
+		sleepFor := e.runOnce()
+		if sleepFor < 0 {
+			break
+		}
+		time.Sleep(sleepFor)
+	}
+}
+
+func (e *expirer) runOnce() time.Duration {
+	defer e.passesMetric.Start().Stop()
+	start := time.Now()
+	now := start.Unix()
+	logger := e.aa.logger.With(zap.String("process", "object expirer"))
+	logger.Debug("starting pass")
+	if err := e.aa.db.startProcessPass("object expirer", "", 0); err != nil {
+		logger.Error("startProcessPass", zap.Error(err))
+	}
+	var entries, errors, deleted int64
+	cancel := make(chan struct{})
+	progressDone := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-cancel:
+				close(progressDone)
+				return
+			case <-time.After(e.reportInterval):
+				n := atomic.LoadInt64(&entries)
+				f := atomic.LoadInt64(&errors)
+				d := atomic.LoadInt64(&deleted)
+				logger.Debug("progress", zap.Int64("entries so far", n))
+				if err := e.aa.db.progressProcessPass("object expirer", "", 0, fmt.Sprintf("%d entries, %d errors, %d deleted", n, f, d)); err != nil {
+					logger.Error("progressProcessPass", zap.Error(err))
+				}
+			}
+		}
+	}()
+	// The backlog is what was due when the pass started; its age is how long
+	// the oldest of it has been due.
+	var backlog, oldest int64
+	currentContainer := common.ExpiringQueueContainer(now)
+	for _, container := range queueContainers(e.aa, logger, common.ExpiringObjectsAccount) {
+		if hour, err := strconv.ParseInt(container, 10, 64); err == nil && hour > now {
+			break
+		}
+		work := make(chan *containerserver.ObjectListingRecord)
+		wg := &sync.WaitGroup{}
+		for i := 0; i < e.concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for olr := range work {
+					atomic.AddInt64(&entries, 1)
+					e.entriesMetric.Inc(1)
+					entryLogger := logger.With(zap.String("container", container), zap.String("entry", olr.Name))
+					expired, err := e.expire(entryLogger, container, olr)
+					if err != nil {
+						entryLogger.Error("expire", zap.Error(err))
+						atomic.AddInt64(&errors, 1)
+						e.errorsMetric.Inc(1)
+						continue
+					}
+					if expired {
+						atomic.AddInt64(&deleted, 1)
+						e.deletedMetric.Inc(1)
+					}
+				}
+			}()
+		}
+		for _, olr := range queueEntries(e.aa, logger, common.ExpiringObjectsAccount, container) {
+			if deleteAt, _, _, _, err := common.ParseExpiringQueueEntry(olr.Name); err == nil {
+				if deleteAt > now {
+					break
+				}
+				if backlog == 0 {
+					oldest = deleteAt
+				}
+				backlog++
+			}
+			work <- olr
+		}
+		close(work)
+		wg.Wait()
+		// The current hour's container may still be getting entries; older
+		// ones with entries left fail with a 409 and are tried again later.
+		if container < currentContainer {
+			resp := e.aa.hClient.DeleteContainer(context.Background(), common.ExpiringObjectsAccount, container, http.Header{})
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}
+	close(cancel)
+	<-progressDone
+	e.backlogMetric.Update(float64(backlog))
+	if backlog > 0 {
+		e.backlogAgeMetric.Update(float64(now - oldest))
+	} else {
+		e.backlogAgeMetric.Update(0)
+	}
+	sleepFor := time.Until(start.Add(e.interval))
+	if sleepFor < 0 {
+		sleepFor = 0
+	}
+	logger.Debug("pass complete", zap.Int64("entries", entries), zap.Int64("errors", errors), zap.Int64("deleted", deleted), zap.String("sleep for", sleepFor.String()))
+	if err := e.aa.db.progressProcessPass("object expirer", "", 0, fmt.Sprintf("%d entries, %d errors, %d deleted", entries, errors, deleted)); err != nil {
+		logger.Error("progressProcessPass", zap.Error(err))
+	}
+	if err := e.aa.db.completeProcessPass("object expirer", "", 0); err != nil {
+		logger.Error("completeProcessPass", zap.Error(err))
+	}
+	return sleepFor
+}
+
+// expire deletes the object a due queue entry names and removes the entry,
+// returning whether there was an object to delete. Entries are left queued
+// on error.
+func (e *expirer) expire(logger *zap.Logger, queueContainer string, olr *containerserver.ObjectListingRecord) (bool, error) {
+	deleteAt, account, container, obj, err := common.ParseExpiringQueueEntry(olr.Name)
+	if err != nil {
+		logger.Debug("odd entry name", zap.Error(err))
+		return false, dequeueEntry(e.aa, common.ExpiringObjectsAccount, queueContainer, olr)
+	}
+	resp := e.aa.hClient.DeleteObject(context.Background(), account, container, obj, http.Header{
+		"X-Timestamp":    {common.CanonicalTimestamp(float64(deleteAt))},
+		"X-If-Delete-At": {strconv.FormatInt(deleteAt, 10)},
+	})
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode/100 == 2:
+		logger.Debug("expired object")
+		return true, dequeueEntry(e.aa, common.ExpiringObjectsAccount, queueContainer, olr)
+	case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusConflict, resp.StatusCode == http.StatusPreconditionFailed:
+		// Already gone, rewritten since, or no longer expiring at this time.
+		return false, dequeueEntry(e.aa, common.ExpiringObjectsAccount, queueContainer, olr)
+	}
+	return false, fmt.Errorf("DELETE: status %d", resp.StatusCode)
+}
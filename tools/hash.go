@@ -0,0 +1,130 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package tools
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/objectserver"
+)
+
+// ObjectLocation is where one replica of an object lives under one storage
+// policy.
+type ObjectLocation struct {
+	Policy    *conf.Policy
+	Partition uint64
+	Replica   int
+	Device    *ring.Device
+	// Path is the object's hash directory for replication policies. Other
+	// policy types keep their objects in an index db, so it's the db's path.
+	Path string
+}
+
+// ObjectHash is where an object's name puts it in every storage policy.
+type ObjectHash struct {
+	Hash      string
+	Locations []ObjectLocation
+	// Warnings are problems with the hash path config or rings that may
+	// make Hash or Locations differ from what the servers use.
+	Warnings []string
+}
+
+// HashObject works out an object's hash and, for every storage policy whose
+// ring can be loaded, its partition and the on-disk path of each replica
+// under driveRoot.
+func HashObject(cnf srv.ConfigLoader, driveRoot, account, container, object string) (*ObjectHash, error) {
+	if account == "" || container == "" || object == "" {
+		return nil, fmt.Errorf("An account, container and object are required")
+	}
+	prefix, suffix, err := cnf.GetHashPrefixAndSuffix()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get the hash path prefix and suffix: %v", err)
+	}
+	policies, err := cnf.GetPolicies()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load the storage policies: %v", err)
+	}
+	oh := &ObjectHash{}
+	if prefix == "" && suffix == "" {
+		oh.Warnings = append(oh.Warnings, "swift_hash_path_prefix and swift_hash_path_suffix are both empty")
+	}
+	if prefix == "changeme" {
+		oh.Warnings = append(oh.Warnings, "swift_hash_path_prefix is still the default \"changeme\"")
+	}
+	if suffix == "changeme" {
+		oh.Warnings = append(oh.Warnings, "swift_hash_path_suffix is still the default \"changeme\"")
+	}
+	vars := map[string]string{"account": account, "container": container, "obj": object}
+	oh.Hash = objectserver.ObjHash(vars, prefix, suffix)
+	for _, index := range sortedPolicyIndexes(policies) {
+		policy := policies[index]
+		r, err := cnf.GetRing("object", prefix, suffix, index)
+		if err != nil {
+			oh.Warnings = append(oh.Warnings, fmt.Sprintf("Unable to load the object ring for storage-policy:%d: %v", index, err))
+			continue
+		}
+		partition := r.GetPartition(account, container, object)
+		for i, dev := range r.GetNodes(partition) {
+			var path string
+			if policy.Type == "replication" {
+				vars["device"] = dev.Device
+				vars["partition"] = strconv.FormatUint(partition, 10)
+				path = objectserver.ObjHashDir(vars, driveRoot, prefix, suffix, index)
+			} else {
+				path = filepath.Join(driveRoot, dev.Device, objectserver.PolicyDir(index), policy.Type+".db")
+			}
+			oh.Locations = append(oh.Locations, ObjectLocation{Policy: policy, Partition: partition, Replica: i, Device: dev, Path: path})
+		}
+	}
+	return oh, nil
+}
+
+func Hash(flags *flag.FlagSet, cnf srv.ConfigLoader) {
+	var account, container, object string
+	if flags.NArg() == 1 {
+		account, container, object = parseArg0(flags.Arg(0))
+	} else {
+		account, container, object = flags.Arg(0), flags.Arg(1), flags.Arg(2)
+	}
+	driveRoot := flags.Lookup("d").Value.(flag.Getter).Get().(string)
+	oh, err := HashObject(cnf, driveRoot, account, container, object)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for _, w := range oh.Warnings {
+		fmt.Println("WARNING:", w)
+	}
+	fmt.Printf("Account  \t%v\n", account)
+	fmt.Printf("Container\t%v\n", container)
+	fmt.Printf("Object   \t%v\n", object)
+	fmt.Printf("Hash     \t%v\n", oh.Hash)
+	var last *conf.Policy
+	for _, loc := range oh.Locations {
+		if loc.Policy != last {
+			last = loc.Policy
+			fmt.Printf("\nPolicy %d (%s, %s)\tPartition %d\n", loc.Policy.Index, loc.Policy.Name, loc.Policy.Type, loc.Partition)
+		}
+		fmt.Printf("  Replica %d\t%s:%d/%s\t%s\n", loc.Replica, loc.Device.Ip, loc.Device.Port, loc.Device.Device, loc.Path)
+	}
+}
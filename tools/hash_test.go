@@ -0,0 +1,62 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package tools
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/common/test"
+)
+
+func TestHashObject(t *testing.T) {
+	policies := conf.PolicyList{
+		0: {Index: 0, Type: "replication", Name: "gold", Default: true},
+		1: {Index: 1, Type: "hec", Name: "silver"},
+		2: {Index: 2, Type: "replication", Name: "bronze"},
+	}
+	r := &test.FakeRing{MockDevices: verifyTestDevs(6000, "sda", "sdb", "sdc")}
+	cnf := &srv.TestConfigLoader{
+		GetHashPrefixAndSuffixFunc: func() (string, string, error) { return "", "changeme", nil },
+		GetPoliciesFunc:            func() (conf.PolicyList, error) { return policies, nil },
+		GetRingFunc: func(ringType, prefix, suffix string, policy int) (ring.Ring, error) {
+			if policy == 2 {
+				return nil, errors.New("no such ring")
+			}
+			return r, nil
+		},
+	}
+	oh, err := HashObject(cnf, "/srv/node", "a", "c", "o")
+	require.Nil(t, err)
+	require.Equal(t, "2f714cd91b0e5d803cde2012b01d7099", oh.Hash)
+	require.Equal(t, []string{
+		`swift_hash_path_suffix is still the default "changeme"`,
+		"Unable to load the object ring for storage-policy:2: no such ring",
+	}, oh.Warnings)
+	require.Equal(t, 6, len(oh.Locations))
+	require.Equal(t, "/srv/node/sda/objects/0/099/2f714cd91b0e5d803cde2012b01d7099", oh.Locations[0].Path)
+	require.Equal(t, "/srv/node/sdc/objects/0/099/2f714cd91b0e5d803cde2012b01d7099", oh.Locations[2].Path)
+	require.Equal(t, 2, oh.Locations[2].Replica)
+	require.Equal(t, "/srv/node/sdb/objects-1/hec.db", oh.Locations[4].Path)
+	require.Equal(t, "silver", oh.Locations[4].Policy.Name)
+
+	_, err = HashObject(cnf, "/srv/node", "a", "c", "")
+	require.NotNil(t, err)
+}
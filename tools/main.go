@@ -22,6 +22,7 @@
 // key_file =                       # path to tls key, if tls is desired
 // service_error_expiration = 3600  # seconds of no errors before error count is cleared
 // device_error_expiration = 3600   # seconds of no errors before error count is cleared
+// ring_dir = /etc/hummingbird      # rings served at /rings/ for ring distribution
 
 package tools
 
@@ -616,6 +617,7 @@ type AutoAdmin struct {
 	runningForever    bool
 	db                *dbInstance
 	fastRingScan      chan struct{}
+	ringDir           string
 }
 
 func (server *AutoAdmin) Type() string {
@@ -657,7 +659,12 @@ func (server *AutoAdmin) GetHandler(config conf.Config, metricsPrefix string) ht
 	router.Get("/metrics", prometheus.Handler())
 	router.Get("/loglevel", server.logLevel)
 	router.Put("/loglevel", server.logLevel)
+	router.Get("/loglevel/:component", http.HandlerFunc(srv.LogLevelHandler))
+	router.Put("/loglevel/:component", http.HandlerFunc(srv.LogLevelHandler))
+	router.Get("/loglevels", http.HandlerFunc(srv.LogLevelsHandler))
 	router.Get("/healthcheck", commonHandlers.ThenFunc(server.HealthcheckHandler))
+	router.Get("/recon/hummingbirdtime", http.HandlerFunc(middleware.TimeHandler))
+	router.Get("/rings/:file", commonHandlers.ThenFunc(server.ringsHandler))
 	router.Get("/debug/pprof/:parm", http.DefaultServeMux)
 	router.Post("/debug/pprof/:parm", http.DefaultServeMux)
 	return alice.New(middleware.Metrics(metricsScope)).Then(router)
@@ -700,6 +707,8 @@ func (a *AutoAdmin) RunForever() {
 	go newReplication(a).runForever()
 	go newRingMonitor(a).runForever()
 	go newRingScan(a).runForever()
+	go newReconciler(a).runForever()
+	go newExpirer(a).runForever()
 }
 
 func NewAdmin(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader) (ipPort *srv.IpPort, server srv.Server, logger srv.LowLevelLogger, err error) {
@@ -747,7 +756,7 @@ func NewAdmin(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader)
 		}
 	}
 	httpClient := &http.Client{
-		Transport: transport,
+		Transport: srv.NewSigningTransport(transport),
 		Timeout:   10 * time.Second,
 	}
 	a := &AutoAdmin{
@@ -762,8 +771,10 @@ func NewAdmin(serverconf conf.Config, flags *flag.FlagSet, cnf srv.ConfigLoader)
 		logger:       logger,
 		logLevel:     logLevel,
 		fastRingScan: make(chan struct{}, 32), // 32 just "because"; gives some room for a bunch of ring changes to get queued up before blocking.
+		ringDir:      serverconf.GetDefault("andrewd", "ring_dir", "/etc/hummingbird"),
 	}
 	a.hClient.SetUserAgent("Andrewd")
+	a.hClient.SetPriority(common.PriorityBackground)
 	a.db, err = newDB(&serverconf, "")
 	if err != nil {
 		return ipPort, nil, nil, err
@@ -189,7 +189,7 @@ func (qh *quarantineHistory) quarantineHistoryDeleteURLs() []string {
 					if !dev.Active() {
 						continue
 					}
-					urlMap[fmt.Sprintf("%s://%s:%d/recon/%s/quarantinedhistory/%ss/%d", dev.Scheme, dev.Ip, dev.Port, dev.Device, typ, qh.keepHistoryDays)] = struct{}{}
+					urlMap[fmt.Sprintf("%s://%s:%d%s/recon/%s/quarantinedhistory/%ss/%d", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, typ, qh.keepHistoryDays)] = struct{}{}
 				}
 			}
 		} else {
@@ -198,7 +198,7 @@ func (qh *quarantineHistory) quarantineHistoryDeleteURLs() []string {
 				if !dev.Active() {
 					continue
 				}
-				urlMap[fmt.Sprintf("%s://%s:%d/recon/%s/quarantinedhistory/%ss/%d", dev.Scheme, dev.Ip, dev.Port, dev.Device, typ, qh.keepHistoryDays)] = struct{}{}
+				urlMap[fmt.Sprintf("%s://%s:%d%s/recon/%s/quarantinedhistory/%ss/%d", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, typ, qh.keepHistoryDays)] = struct{}{}
 			}
 		}
 	}
@@ -185,7 +185,7 @@ func (qr *quarantineRepair) quarantineDetailURLs() map[string]*ippInstance {
 					if !dev.Active() {
 						continue
 					}
-					urls[fmt.Sprintf("%s://%s:%d/recon/quarantineddetail", dev.Scheme, dev.Ip, dev.Port)] = &ippInstance{scheme: dev.Scheme, ip: dev.Ip, port: dev.Port}
+					urls[fmt.Sprintf("%s://%s:%d%s/recon/quarantineddetail", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix)] = &ippInstance{scheme: dev.Scheme, ip: dev.Ip, port: dev.Port, pathPrefix: dev.PathPrefix}
 				}
 			}
 		} else {
@@ -194,7 +194,7 @@ func (qr *quarantineRepair) quarantineDetailURLs() map[string]*ippInstance {
 				if !dev.Active() {
 					continue
 				}
-				urls[fmt.Sprintf("%s://%s:%d/recon/quarantineddetail", dev.Scheme, dev.Ip, dev.Port)] = &ippInstance{scheme: dev.Scheme, ip: dev.Ip, port: dev.Port}
+				urls[fmt.Sprintf("%s://%s:%d%s/recon/quarantineddetail", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix)] = &ippInstance{scheme: dev.Scheme, ip: dev.Ip, port: dev.Port, pathPrefix: dev.PathPrefix}
 			}
 		}
 	}
@@ -240,7 +240,7 @@ func (qr *quarantineRepair) repairHECObject(logger *zap.Logger, policy int, ring
 	partition := ringg.GetPartition(account, container, object)
 	logger = logger.With(zap.Uint64("partition", partition))
 	for _, device := range ringg.GetNodes(partition) {
-		url := fmt.Sprintf("%s://%s:%d/ec-reconstruct/%s/%s/%s/%s", device.Scheme, device.Ip, device.Port, device.Device, account, container, object)
+		url := fmt.Sprintf("%s://%s:%d%s/ec-reconstruct/%s/%s/%s/%s", device.Scheme, device.Ip, device.Port, device.PathPrefix, device.Device, account, container, object)
 		logger.Debug("Trying reconstruct", zap.String("url", url))
 		req, err := http.NewRequest("PUT", url, nil)
 		if err != nil {
@@ -315,7 +315,7 @@ func (qr *quarantineRepair) repairObject(logger *zap.Logger, typ string, policy
 	logger = logger.With(zap.Uint64("partition", partition))
 	var have, notfound, unsure []*ring.Device
 	for _, device := range ringg.GetNodes(partition) {
-		url := fmt.Sprintf("%s://%s:%d/%s/%d/%s", device.Scheme, device.Ip, device.Port, device.Device, partition, account)
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s", device.Scheme, device.Ip, device.Port, device.PathPrefix, device.Device, partition, account)
 		if container != "" {
 			url += "/" + container
 			if object != "" {
@@ -352,7 +352,7 @@ func (qr *quarantineRepair) repairObject(logger *zap.Logger, typ string, policy
 		logger.Debug("couldn't find anyone with the item yet, but not everyone reported in, so just skip for now")
 		return false
 	}
-	fromURL := fmt.Sprintf("%s://%s:%d/%s/%d/%s", have[0].Scheme, have[0].Ip, have[0].Port, have[0].Device, partition, account)
+	fromURL := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s", have[0].Scheme, have[0].Ip, have[0].Port, have[0].PathPrefix, have[0].Device, partition, account)
 	if container != "" {
 		fromURL += "/" + container
 		if object != "" {
@@ -377,7 +377,7 @@ func (qr *quarantineRepair) repairObject(logger *zap.Logger, typ string, policy
 			logger.Debug("StatusCode", zap.Int("StatusCode", fromResp.StatusCode), zap.Error(err))
 			return false
 		}
-		toURL := fmt.Sprintf("%s://%s:%d/%s/%d/%s", device.Scheme, device.Ip, device.Port, device.Device, partition, account)
+		toURL := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s", device.Scheme, device.Ip, device.Port, device.PathPrefix, device.Device, partition, account)
 		if container != "" {
 			toURL += "/" + container
 			if object != "" {
@@ -447,9 +447,10 @@ func (qr *quarantineRepair) queuePartitionReplication(logger *zap.Logger, typ st
 }
 
 type ippInstance struct {
-	scheme string
-	ip     string
-	port   int
+	scheme     string
+	ip         string
+	port       int
+	pathPrefix string
 }
 
 type entryInstance struct {
@@ -462,7 +463,7 @@ func (qr *quarantineRepair) clearQuarantine(logger *zap.Logger, ipp *ippInstance
 	if policy != 0 {
 		reconType += fmt.Sprintf("-%d", policy)
 	}
-	url := fmt.Sprintf("%s://%s:%d/", ipp.scheme, ipp.ip, ipp.port) + path.Join("recon", device, "quarantined", reconType, nameOnDevice)
+	url := fmt.Sprintf("%s://%s:%d%s/", ipp.scheme, ipp.ip, ipp.port, ipp.pathPrefix) + path.Join("recon", device, "quarantined", reconType, nameOnDevice)
 	logger = logger.With(zap.String("method", "DELETE"), zap.String("url", url))
 	req, err := http.NewRequest("DELETE", url, nil)
 	if err != nil {
@@ -0,0 +1,371 @@
+package tools
+
+// The container reconciler works through the queue of misplaced objects that
+// the container replicators build in the .misplaced_objects account. An
+// object is misplaced when it was written under a storage policy other than
+// its container's, which happens when replicas of a container were created
+// with different policies. Each queued object is copied into its container's
+// policy and then removed from the policy it was found in; queued deletes are
+// simply replayed against the container's policy.
+//
+// In /etc/hummingbird/andrewd-server.conf:
+// [container-reconciler]
+// interval = 300         # seconds between the starts of passes
+// report_interval = 600  # seconds between progress reports
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/troubling/hummingbird/client"
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/containerserver"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+// reconcilerCopyHeaders are copied from the misplaced object to its
+// replacement, along with any header starting with reconcilerCopyPrefixes.
+var reconcilerCopyHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Content-Encoding",
+	"Content-Disposition",
+	"Etag",
+	"X-Delete-At",
+	"X-Object-Manifest",
+	"X-Static-Large-Object",
+}
+
+var reconcilerCopyPrefixes = []string{
+	"X-Object-Meta-",
+	"X-Object-Sysmeta-",
+	"X-Object-Transient-Sysmeta-",
+}
+
+type reconciler struct {
+	aa             *AutoAdmin
+	interval       time.Duration
+	reportInterval time.Duration
+	passesMetric   tally.Timer
+	entriesMetric  tally.Counter
+	movedMetric    tally.Counter
+	deletedMetric  tally.Counter
+	errorsMetric   tally.Counter
+}
+
+func newReconciler(aa *AutoAdmin) *reconciler {
+	r := &reconciler{
+		aa:             aa,
+		interval:       time.Duration(aa.serverconf.GetInt("container-reconciler", "interval", 300)) * time.Second,
+		reportInterval: time.Duration(aa.serverconf.GetInt("container-reconciler", "report_interval", 600)) * time.Second,
+		passesMetric:   aa.metricsScope.Timer("reconciler_passes"),
+		entriesMetric:  aa.metricsScope.Counter("reconciler_entries"),
+		movedMetric:    aa.metricsScope.Counter("reconciler_moved"),
+		deletedMetric:  aa.metricsScope.Counter("reconciler_deleted"),
+		errorsMetric:   aa.metricsScope.Counter("reconciler_errors"),
+	}
+	if r.interval < 0 {
+		r.interval = time.Second
+	}
+	if r.reportInterval < 0 {
+		r.reportInterval = time.Second
+	}
+	return r
+}
+
+func (r *reconciler) runForever() {
+	for {
+		sleepFor := r.runOnce()
+		if sleepFor < 0 {
+			break
+		}
+		time.Sleep(sleepFor)
+	}
+}
+
+func (r *reconciler) runOnce() time.Duration {
+	defer r.passesMetric.Start().Stop()
+	start := time.Now()
+	logger := r.aa.logger.With(zap.String("process", "container reconciler"))
+	logger.Debug("starting pass")
+	if err := r.aa.db.startProcessPass("container reconciler", "", 0); err != nil {
+		logger.Error("startProcessPass", zap.Error(err))
+	}
+	var entries, errors, moved, deleted int64
+	cancel := make(chan struct{})
+	progressDone := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-cancel:
+				close(progressDone)
+				return
+			case <-time.After(r.reportInterval):
+				n := atomic.LoadInt64(&entries)
+				e := atomic.LoadInt64(&errors)
+				m := atomic.LoadInt64(&moved)
+				d := atomic.LoadInt64(&deleted)
+				logger.Debug("progress", zap.Int64("entries so far", n))
+				if err := r.aa.db.progressProcessPass("container reconciler", "", 0, fmt.Sprintf("%d entries, %d errors, %d moved, %d deletes replayed", n, e, m, d)); err != nil {
+					logger.Error("progressProcessPass", zap.Error(err))
+				}
+			}
+		}
+	}()
+	for _, container := range queueContainers(r.aa, logger, containerserver.MisplacedObjectsAccount) {
+		for _, olr := range queueEntries(r.aa, logger, containerserver.MisplacedObjectsAccount, container) {
+			atomic.AddInt64(&entries, 1)
+			r.entriesMetric.Inc(1)
+			entryLogger := logger.With(zap.String("container", container), zap.String("entry", olr.Name))
+			op, err := r.reconcile(entryLogger, container, olr)
+			if err != nil {
+				entryLogger.Error("reconcile", zap.Error(err))
+				atomic.AddInt64(&errors, 1)
+				r.errorsMetric.Inc(1)
+				continue
+			}
+			switch op {
+			case containerserver.MisplacedPut:
+				atomic.AddInt64(&moved, 1)
+				r.movedMetric.Inc(1)
+			case containerserver.MisplacedDelete:
+				atomic.AddInt64(&deleted, 1)
+				r.deletedMetric.Inc(1)
+			}
+		}
+		// Only older queue containers are removed; the current one may still
+		// be getting entries. Containers with entries left fail with a 409.
+		if container < containerserver.MisplacedQueueContainer(common.GetTimestamp()) {
+			resp := r.aa.hClient.DeleteContainer(context.Background(), containerserver.MisplacedObjectsAccount, container, http.Header{})
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}
+	close(cancel)
+	<-progressDone
+	sleepFor := time.Until(start.Add(r.interval))
+	if sleepFor < 0 {
+		sleepFor = 0
+	}
+	logger.Debug("pass complete", zap.Int64("entries", entries), zap.Int64("errors", errors), zap.Int64("moved", moved), zap.Int64("deletes replayed", deleted), zap.String("sleep for", sleepFor.String()))
+	if err := r.aa.db.progressProcessPass("container reconciler", "", 0, fmt.Sprintf("%d entries, %d errors, %d moved, %d deletes replayed", entries, errors, moved, deleted)); err != nil {
+		logger.Error("progressProcessPass", zap.Error(err))
+	}
+	if err := r.aa.db.completeProcessPass("container reconciler", "", 0); err != nil {
+		logger.Error("completeProcessPass", zap.Error(err))
+	}
+	return sleepFor
+}
+
+// queueContainers lists the containers of a queue account, oldest first.
+func queueContainers(aa *AutoAdmin, logger *zap.Logger, account string) []string {
+	var containers []string
+	var marker string
+	for {
+		resp := aa.hClient.GetAccountRaw(context.Background(), account, map[string]string{
+			"format": "json",
+			"marker": marker,
+		}, http.Header{})
+		if resp.StatusCode == http.StatusNotFound {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+			return containers
+		}
+		if resp.StatusCode/100 != 2 {
+			logger.Error("GET", zap.String("account", account), zap.String("marker", marker), zap.Int("status", resp.StatusCode))
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+			return containers
+		}
+		var clrs []struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&clrs); err != nil {
+			logger.Error("GET got bad JSON", zap.String("account", account), zap.String("marker", marker), zap.Error(err))
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+			return containers
+		}
+		resp.Body.Close()
+		if len(clrs) == 0 {
+			return containers
+		}
+		for _, clr := range clrs {
+			containers = append(containers, clr.Name)
+		}
+		marker = clrs[len(clrs)-1].Name
+	}
+}
+
+// queueEntries lists the entries of a queue container.
+func queueEntries(aa *AutoAdmin, logger *zap.Logger, account, container string) []*containerserver.ObjectListingRecord {
+	var olrs []*containerserver.ObjectListingRecord
+	var marker string
+	for {
+		resp := aa.hClient.GetContainerRaw(context.Background(), account, container, map[string]string{
+			"format": "json",
+			"marker": marker,
+		}, http.Header{})
+		if resp.StatusCode/100 != 2 {
+			if resp.StatusCode != http.StatusNotFound {
+				logger.Error("GET", zap.String("container", container), zap.String("marker", marker), zap.Int("status", resp.StatusCode))
+			}
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+			return olrs
+		}
+		var page []*containerserver.ObjectListingRecord
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			logger.Error("GET got bad JSON", zap.String("container", container), zap.String("marker", marker), zap.Error(err))
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+			return olrs
+		}
+		resp.Body.Close()
+		if len(page) == 0 {
+			return olrs
+		}
+		olrs = append(olrs, page...)
+		marker = page[len(page)-1].Name
+	}
+}
+
+// reconcile handles one queue entry, returning which kind it was once the
+// entry has been dealt with and removed from the queue, or "" if there turned
+// out to be nothing to do. Entries are left queued on error.
+func (r *reconciler) reconcile(logger *zap.Logger, queueContainer string, olr *containerserver.ObjectListingRecord) (string, error) {
+	ctx := context.Background()
+	policy, account, container, obj, err := containerserver.ParseMisplacedQueueEntry(olr.Name)
+	if err != nil {
+		logger.Debug("odd entry name", zap.Error(err))
+		return "", r.dequeue(queueContainer, olr)
+	}
+	timestamp := olr.ETag
+	ci, err := r.aa.hClient.GetContainerInfo(ctx, account, container)
+	if err != nil {
+		if err == client.ContainerNotFound {
+			return "", r.dequeue(queueContainer, olr)
+		}
+		return "", err
+	}
+	if ci.StoragePolicyIndex == policy {
+		return "", r.dequeue(queueContainer, olr)
+	}
+	if olr.ContentType == containerserver.MisplacedDelete {
+		resp := r.aa.hClient.DeleteObject(ctx, account, container, obj, http.Header{"X-Timestamp": {timestamp}})
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusConflict {
+			return "", fmt.Errorf("DELETE in policy %d: status %d", ci.StoragePolicyIndex, resp.StatusCode)
+		}
+		logger.Debug("replayed delete", zap.Int("policy", ci.StoragePolicyIndex))
+		return containerserver.MisplacedDelete, r.dequeue(queueContainer, olr)
+	}
+	resp := r.aa.hClient.GetObjectInPolicy(ctx, policy, account, container, obj, http.Header{})
+	if resp.StatusCode == http.StatusNotFound {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		return "", r.dequeue(queueContainer, olr)
+	}
+	if resp.StatusCode/100 != 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		return "", fmt.Errorf("GET in policy %d: status %d", policy, resp.StatusCode)
+	}
+	// The copy goes in two offsets after the misplaced object, and the
+	// misplaced object is deleted at one offset after it, so both sort
+	// after the original but before any newer write from a client.
+	sourceTimestamp := resp.Header.Get("X-Timestamp")
+	if sourceTimestamp == "" {
+		sourceTimestamp = timestamp
+	}
+	putTimestamp, err := common.OffsetTimestamp(sourceTimestamp, 2)
+	if err != nil {
+		resp.Body.Close()
+		return "", err
+	}
+	deleteTimestamp, err := common.OffsetTimestamp(sourceTimestamp, 1)
+	if err != nil {
+		resp.Body.Close()
+		return "", err
+	}
+	headers := http.Header{"X-Timestamp": {putTimestamp}}
+	for _, h := range reconcilerCopyHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			headers.Set(h, v)
+		}
+	}
+	for h, v := range resp.Header {
+		for _, prefix := range reconcilerCopyPrefixes {
+			if strings.HasPrefix(h, prefix) {
+				headers[h] = v
+			}
+		}
+	}
+	putResp := r.aa.hClient.PutObject(ctx, account, container, obj, headers, resp.Body)
+	resp.Body.Close()
+	io.Copy(ioutil.Discard, putResp.Body)
+	putResp.Body.Close()
+	if putResp.StatusCode/100 != 2 && putResp.StatusCode != http.StatusConflict {
+		return "", fmt.Errorf("PUT in policy %d: status %d", ci.StoragePolicyIndex, putResp.StatusCode)
+	}
+	delResp := r.aa.hClient.DeleteObjectInPolicy(ctx, policy, account, container, obj, http.Header{"X-Timestamp": {deleteTimestamp}})
+	io.Copy(ioutil.Discard, delResp.Body)
+	delResp.Body.Close()
+	if delResp.StatusCode/100 != 2 && delResp.StatusCode != http.StatusNotFound && delResp.StatusCode != http.StatusConflict {
+		return "", fmt.Errorf("DELETE in policy %d: status %d", policy, delResp.StatusCode)
+	}
+	logger.Debug("moved object", zap.Int("from policy", policy), zap.Int("to policy", ci.StoragePolicyIndex), zap.Int("PUT status", putResp.StatusCode))
+	return containerserver.MisplacedPut, r.dequeue(queueContainer, olr)
+}
+
+// dequeue removes the entry from the misplaced objects queue.
+func (r *reconciler) dequeue(queueContainer string, olr *containerserver.ObjectListingRecord) error {
+	return dequeueEntry(r.aa, containerserver.MisplacedObjectsAccount, queueContainer, olr)
+}
+
+// dequeueEntry deletes a queue entry directly from its container's replicas,
+// just after the entry's timestamp, kept in its etag, so a newer copy of the
+// same entry survives. It needs a majority to succeed.
+func dequeueEntry(aa *AutoAdmin, account, queueContainer string, olr *containerserver.ObjectListingRecord) error {
+	timestamp, err := common.OffsetTimestamp(olr.ETag, 1)
+	if err != nil {
+		timestamp = common.GetTimestamp()
+	}
+	containerRing := aa.hClient.ContainerRing()
+	partition := containerRing.GetPartition(account, queueContainer, "")
+	devices := containerRing.GetNodes(partition)
+	successes := 0
+	for _, dev := range devices {
+		url := fmt.Sprintf("%s://%s:%d%s/%s/%d/%s/%s/%s", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix, dev.Device, partition,
+			common.Urlencode(account), common.Urlencode(queueContainer), common.Urlencode(olr.Name))
+		req, err := http.NewRequest("DELETE", url, nil)
+		if err != nil {
+			continue
+		}
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Backend-Storage-Policy-Index", "0")
+		req.Header.Set("User-Agent", "Andrewd")
+		resp, err := aa.client.Do(req)
+		if err != nil {
+			continue
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode/100 == 2 || resp.StatusCode == http.StatusNotFound {
+			successes++
+		}
+	}
+	if successes < len(devices)/2+1 {
+		return fmt.Errorf("dequeue %s/%s: %d of %d successes", queueContainer, olr.Name, successes, len(devices))
+	}
+	return nil
+}
@@ -21,10 +21,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gholt/brimtext"
@@ -38,6 +42,7 @@ import (
 
 type ipPort struct {
 	ip, scheme      string
+	pathPrefix      string
 	port            int
 	replicationPort int
 }
@@ -62,7 +67,7 @@ func getDistinctIPServers(errors []string) ([]*ipPort, []string) {
 			if dev == nil || dev.Weight < 0 {
 				continue
 			}
-			serversMap[dev.Ip] = &ipPort{ip: dev.Ip, port: dev.Port, scheme: dev.Scheme, replicationPort: dev.ReplicationPort}
+			serversMap[dev.Ip] = &ipPort{ip: dev.Ip, port: dev.Port, scheme: dev.Scheme, pathPrefix: dev.PathPrefix, replicationPort: dev.ReplicationPort}
 		}
 	}
 	if r, err := ring.GetRing("account", prefix, suffix, 0); err != nil {
@@ -101,7 +106,7 @@ func getDistinctObjectReplicationServers(errors []string) ([]*ipPort, []string)
 			if dev == nil || dev.Weight < 0 {
 				continue
 			}
-			serversMap[serverId(dev.Ip, dev.ReplicationPort)] = &ipPort{ip: dev.Ip, port: dev.Port, scheme: dev.Scheme, replicationPort: dev.ReplicationPort}
+			serversMap[serverId(dev.Ip, dev.ReplicationPort)] = &ipPort{ip: dev.Ip, port: dev.Port, scheme: dev.Scheme, pathPrefix: dev.PathPrefix, replicationPort: dev.ReplicationPort}
 		}
 	}
 	if policies, err := conf.GetPolicies(); err != nil {
@@ -123,7 +128,7 @@ func getDistinctObjectReplicationServers(errors []string) ([]*ipPort, []string)
 }
 
 func queryHostRecon(client common.HTTPClient, s *ipPort, endpoint string) ([]byte, error) {
-	serverUrl := fmt.Sprintf("%s://%s:%d/recon/%s", s.scheme, s.ip, s.port, endpoint)
+	serverUrl := fmt.Sprintf("%s://%s:%d%s/recon/%s", s.scheme, s.ip, s.port, s.pathPrefix, endpoint)
 	req, err := http.NewRequest("GET", serverUrl, nil)
 	if err != nil {
 		return nil, err
@@ -140,7 +145,7 @@ func queryHostRecon(client common.HTTPClient, s *ipPort, endpoint string) ([]byt
 }
 
 func queryHostReplication(client common.HTTPClient, s *ipPort) (map[string]objectserver.DeviceStats, error) {
-	serverUrl := fmt.Sprintf("http://%s:%d/progress/object-replicator", s.ip, s.replicationPort)
+	serverUrl := fmt.Sprintf("http://%s:%d%s/progress/object-replicator", s.ip, s.replicationPort, s.pathPrefix)
 	req, err := http.NewRequest("GET", serverUrl, nil)
 	if err != nil {
 		return nil, err
@@ -219,7 +224,7 @@ func getRingMD5Report(client common.HTTPClient, ringMap map[string]string, typeT
 						m = map[string]*ipPort{}
 						typeToServers[serverId(dev.Ip, dev.Port)] = m
 					}
-					m["account.ring.gz"] = &ipPort{ip: dev.Ip, port: dev.Port, scheme: dev.Scheme, replicationPort: dev.ReplicationPort}
+					m["account.ring.gz"] = &ipPort{ip: dev.Ip, port: dev.Port, scheme: dev.Scheme, pathPrefix: dev.PathPrefix, replicationPort: dev.ReplicationPort}
 				}
 			}
 		}
@@ -233,7 +238,7 @@ func getRingMD5Report(client common.HTTPClient, ringMap map[string]string, typeT
 						m = map[string]*ipPort{}
 						typeToServers[serverId(dev.Ip, dev.Port)] = m
 					}
-					m["container.ring.gz"] = &ipPort{ip: dev.Ip, port: dev.Port, scheme: dev.Scheme, replicationPort: dev.ReplicationPort}
+					m["container.ring.gz"] = &ipPort{ip: dev.Ip, port: dev.Port, scheme: dev.Scheme, pathPrefix: dev.PathPrefix, replicationPort: dev.ReplicationPort}
 				}
 			}
 		}
@@ -252,9 +257,9 @@ func getRingMD5Report(client common.HTTPClient, ringMap map[string]string, typeT
 								typeToServers[serverId(dev.Ip, dev.Port)] = m
 							}
 							if policy.Index == 0 {
-								m["object.ring.gz"] = &ipPort{ip: dev.Ip, port: dev.Port, scheme: dev.Scheme, replicationPort: dev.ReplicationPort}
+								m["object.ring.gz"] = &ipPort{ip: dev.Ip, port: dev.Port, scheme: dev.Scheme, pathPrefix: dev.PathPrefix, replicationPort: dev.ReplicationPort}
 							} else {
-								m[fmt.Sprintf("object-%d.ring.gz", policy.Index)] = &ipPort{ip: dev.Ip, port: dev.Port, scheme: dev.Scheme, replicationPort: dev.ReplicationPort}
+								m[fmt.Sprintf("object-%d.ring.gz", policy.Index)] = &ipPort{ip: dev.Ip, port: dev.Port, scheme: dev.Scheme, pathPrefix: dev.PathPrefix, replicationPort: dev.ReplicationPort}
 							}
 						}
 					}
@@ -485,7 +490,10 @@ type timeReport struct {
 	Pass      bool
 	Servers   int
 	Successes int
-	Errors    []string
+	// MaxSkew is the largest difference, in seconds, between a server's
+	// clock and the local one, less half the round trip of asking it.
+	MaxSkew float64
+	Errors  []string
 }
 
 func (r *timeReport) Passed() bool {
@@ -502,31 +510,56 @@ func (r *timeReport) String() string {
 		s += fmt.Sprintf("!! %s\n", e)
 	}
 	s += fmt.Sprintf(
-		"%d/%d hosts matched, %d error[s] while checking hosts.\n",
-		r.Successes, r.Servers, len(r.Errors),
+		"%d/%d hosts matched, %d error[s] while checking hosts. Largest skew %.3fs.\n",
+		r.Successes, r.Servers, len(r.Errors), r.MaxSkew,
 	)
 	return s
 }
 
-func getTimeReport(client common.HTTPClient, servers []*ipPort) *timeReport {
+// parseTimeHosts parses scheme://ip:port[/path_prefix] URLs of servers
+// outside the rings, such as proxies, to add to the time check.
+func parseTimeHosts(hosts string) ([]*ipPort, error) {
+	var servers []*ipPort
+	for _, h := range strings.Split(hosts, ",") {
+		if h = strings.TrimSpace(h); h == "" {
+			continue
+		}
+		u, err := url.Parse(h)
+		if err != nil {
+			return nil, err
+		}
+		host, portStr, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", h, err)
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid port", h)
+		}
+		servers = append(servers, &ipPort{scheme: u.Scheme, ip: host, port: port, pathPrefix: strings.TrimSuffix(u.Path, "/")})
+	}
+	return servers, nil
+}
+
+func getTimeReport(client common.HTTPClient, servers []*ipPort, maxSkew time.Duration, extra ...*ipPort) *timeReport {
 	// servers parameter is for overriding for tests, leave nil normally
 	report := &timeReport{
-		Name:    "Time Sync Report",
-		Time:    time.Now().UTC(),
-		Servers: len(servers),
+		Name: "Time Sync Report",
+		Time: time.Now().UTC(),
 	}
 	if servers == nil {
 		servers, report.Errors = getDistinctIPServers(report.Errors)
-		report.Servers = len(servers)
 	}
+	servers = append(servers, extra...)
+	report.Servers = len(servers)
 	for _, server := range servers {
-		preCall := time.Now().Round(time.Microsecond)
+		preCall := time.Now()
 		rBytes, err := queryHostRecon(client, server, "hummingbirdtime")
 		if err != nil {
 			report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", server, err))
 			continue
 		}
-		postCall := time.Now().Round(time.Microsecond)
+		postCall := time.Now()
 		var rData map[string]time.Time
 		if err := json.Unmarshal(rBytes, &rData); err != nil {
 			report.Errors = append(report.Errors, fmt.Sprintf("%s: %s - %q", server, err, string(rBytes)))
@@ -536,16 +569,30 @@ func getTimeReport(client common.HTTPClient, servers []*ipPort) *timeReport {
 			report.Errors = append(report.Errors, fmt.Sprintf("%s: time was zeroed", server))
 			continue
 		}
-		remoteTime := rData["time"].Round(time.Microsecond)
-		if remoteTime.Before(preCall) || remoteTime.After(postCall) {
+		// The server read its clock somewhere in the round trip, so its skew
+		// is only known to within half of it.
+		halfTrip := postCall.Sub(preCall) / 2
+		skew := rData["time"].Sub(preCall.Add(halfTrip))
+		if skew < 0 {
+			skew = -skew
+		}
+		skew -= halfTrip
+		if skew < 0 {
+			skew = 0
+		}
+		if skew.Seconds() > report.MaxSkew {
+			report.MaxSkew = skew.Seconds()
+		}
+		if skew > maxSkew {
 			report.Errors = append(report.Errors, fmt.Sprintf(
-				"%s://%s:%d/recon/hummingbirdtime current time is %s but remote time is %s, differs by %.2f nsecs",
+				"%s://%s:%d%s/recon/hummingbirdtime current time is %s but remote time is %s, skewed by at least %s",
 				server.scheme,
 				server.ip,
 				server.port,
+				server.pathPrefix,
 				postCall.Format(time.StampMicro),
-				remoteTime.Format(time.StampMicro),
-				float64(postCall.Sub(remoteTime)),
+				rData["time"].Format(time.StampMicro),
+				skew,
 			))
 		} else {
 			report.Successes++
@@ -884,7 +931,7 @@ func getAsyncReport(client common.HTTPClient) *asyncReport {
 			}
 			sId := serverId(dev.Ip, dev.Port)
 			if _, ok := servers[sId]; !ok {
-				servers[sId] = &ipPort{ip: dev.Ip, port: dev.Port, scheme: dev.Scheme, replicationPort: dev.ReplicationPort}
+				servers[sId] = &ipPort{ip: dev.Ip, port: dev.Port, scheme: dev.Scheme, pathPrefix: dev.PathPrefix, replicationPort: dev.ReplicationPort}
 			}
 		}
 		getAsyncReportHelper(client, report, servers, policy.Index)
@@ -1539,7 +1586,13 @@ func ReconClient(flags *flag.FlagSet, cnf srv.ConfigLoader) bool {
 		reports = append(reports, getHummingbirdMD5Report(client, nil))
 	}
 	if flags.Lookup("time").Value.(flag.Getter).Get().(bool) {
-		reports = append(reports, getTimeReport(client, nil))
+		maxSkew := time.Duration(flags.Lookup("max-skew").Value.(flag.Getter).Get().(float64) * float64(time.Second))
+		extra, err := parseTimeHosts(flags.Lookup("time-hosts").Value.(flag.Getter).Get().(string))
+		if err != nil {
+			fmt.Printf("Invalid -time-hosts: %v\n", err)
+			return false
+		}
+		reports = append(reports, getTimeReport(client, nil, maxSkew, extra...))
 	}
 	if flags.Lookup("q").Value.(flag.Getter).Get().(bool) {
 		reports = append(reports, getQuarantineReport(client, nil))
@@ -54,7 +54,7 @@ func TestReconReportTimeFail(t *testing.T) {
 
 	servers := []*ipPort{{ip: host, port: port}}
 	client := &http.Client{Timeout: 10 * time.Second}
-	require.Equal(t, false, getTimeReport(client, servers).Passed())
+	require.Equal(t, false, getTimeReport(client, servers, time.Second).Passed())
 }
 
 func TestReconReportTimePass(t *testing.T) {
@@ -81,7 +81,42 @@ func TestReconReportTimePass(t *testing.T) {
 
 	servers := []*ipPort{{ip: host, port: port, scheme: "http"}}
 	client := &http.Client{Timeout: 10 * time.Second}
-	require.Equal(t, true, getTimeReport(client, servers).Passed())
+	require.Equal(t, true, getTimeReport(client, servers, time.Second).Passed())
+}
+
+func TestReconReportTimeSkew(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		content := map[string]time.Time{"time": time.Now().Add(-5 * time.Second)}
+		serialized, _ := json.MarshalIndent(content, "", "  ")
+		w.Write(serialized)
+	}))
+	defer ts.Close()
+
+	servers, err := parseTimeHosts(ts.URL + ",")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(servers))
+	client := &http.Client{Timeout: 10 * time.Second}
+	report := getTimeReport(client, servers, 10*time.Second)
+	require.True(t, report.Passed())
+	require.InDelta(t, 5, report.MaxSkew, 0.5)
+	report = getTimeReport(client, servers, time.Second)
+	require.False(t, report.Passed())
+	require.Equal(t, 1, len(report.Errors))
+	require.Contains(t, report.Errors[0], "skewed by at least")
+}
+
+func TestParseTimeHosts(t *testing.T) {
+	servers, err := parseTimeHosts("https://10.0.0.1:443/op/, http://10.0.0.2:8080")
+	require.Nil(t, err)
+	require.Equal(t, []*ipPort{
+		{scheme: "https", ip: "10.0.0.1", port: 443, pathPrefix: "/op"},
+		{scheme: "http", ip: "10.0.0.2", port: 8080},
+	}, servers)
+	_, err = parseTimeHosts("http://10.0.0.1")
+	require.NotNil(t, err)
 }
 
 func TestReconReportRingMd5Fail(t *testing.T) {
@@ -31,11 +31,11 @@ import (
 
 func PrintDevs(devs []*ring.RingBuilderDevice) {
 	data := make([][]string, 0)
-	data = append(data, []string{"ID", "REGION", "ZONE", "SCHEME", "IP ADDRESS", "PORT", "REPLICATION IP", "REPLICATION PORT", "NAME", "WEIGHT", "PARTITIONS", "META"})
+	data = append(data, []string{"ID", "REGION", "ZONE", "SCHEME", "IP ADDRESS", "PORT", "REPLICATION IP", "REPLICATION PORT", "NAME", "WEIGHT", "PARTITIONS", "META", "TAGS"})
 	data = append(data, nil)
 	for _, dev := range devs {
 		if dev != nil {
-			data = append(data, []string{strconv.FormatInt(dev.Id, 10), strconv.FormatInt(dev.Region, 10), strconv.FormatInt(dev.Zone, 10), dev.Scheme, dev.Ip, strconv.FormatInt(dev.Port, 10), dev.ReplicationIp, strconv.FormatInt(dev.ReplicationPort, 10), dev.Device, strconv.FormatFloat(dev.Weight, 'f', -1, 64), strconv.FormatInt(dev.Parts, 10), dev.Meta})
+			data = append(data, []string{strconv.FormatInt(dev.Id, 10), strconv.FormatInt(dev.Region, 10), strconv.FormatInt(dev.Zone, 10), dev.Scheme, dev.Ip, strconv.FormatInt(dev.Port, 10), dev.ReplicationIp, strconv.FormatInt(dev.ReplicationPort, 10), dev.Device, strconv.FormatFloat(dev.Weight, 'f', -1, 64), strconv.FormatInt(dev.Parts, 10), dev.Meta, ring.FormatTags(dev.Tags)})
 		}
 	}
 	fmt.Println(brimtext.Align(data, brimtext.NewSimpleAlignOptions()))
@@ -107,6 +107,14 @@ func RingBuildCmd(flags *flag.FlagSet) {
 		ring.PretendMinPartHoursPassed(pth)
 		return
 
+	case "increase_partition_power":
+		if err := ring.IncreasePartPower(pth); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("Partition power increased. Run the relinker on every object server before writing the ring.")
+		return
+
 	case "search":
 		searchFlags := flag.NewFlagSet("search", flag.ExitOnError)
 		region := searchFlags.Int64("region", -1, "Device region.")
@@ -165,10 +173,16 @@ func RingBuildCmd(flags *flag.FlagSet) {
 		newRepPort := changeFlags.Int64("change-replication-port", -1, "New replication port.")
 		newDevice := changeFlags.String("change-device", "", "New device name.")
 		newMeta := changeFlags.String("change-meta", "", "New meta data.")
+		newTags := changeFlags.String("change-tags", "", "Tags to set, as key=value,key=value; an empty value removes the tag.")
 		if err := changeFlags.Parse(args[2:]); err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
+		tags, err := ring.ParseTags(*newTags)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 		devs, err := ring.Search(pth, *region, *zone, *ip, *port, *repIp, *repPort, *device, *weight, *meta, *scheme)
 		if err != nil {
 			fmt.Println(err)
@@ -193,7 +207,7 @@ func RingBuildCmd(flags *flag.FlagSet) {
 					return
 				}
 			}
-			err := ring.SetInfo(pth, devs, *newIp, *newPort, *newRepIp, *newRepPort, *newDevice, *newMeta, *newScheme)
+			err := ring.SetInfo(pth, devs, *newIp, *newPort, *newRepIp, *newRepPort, *newDevice, *newMeta, *newScheme, tags)
 			if err != nil {
 				fmt.Println(err)
 			} else {
@@ -213,6 +227,7 @@ func RingBuildCmd(flags *flag.FlagSet) {
 		device := weightFlags.String("device", "", "Device name.")
 		weight := weightFlags.Float64("weight", -1.0, "Device weight.")
 		meta := weightFlags.String("meta", "", "Metadata.")
+		ramp := weightFlags.Int("ramp", 0, "Reach the new weight gradually over this many rebalances.")
 		yes := weightFlags.Bool("yes", false, "Force yes.")
 		if err := weightFlags.Parse(args[2:]); err != nil {
 			fmt.Println(err)
@@ -252,10 +267,16 @@ func RingBuildCmd(flags *flag.FlagSet) {
 					return
 				}
 			}
-			err := ring.SetWeight(pth, devs, newWeight)
+			if *ramp > 0 {
+				err = ring.RampWeight(pth, devs, newWeight, *ramp)
+			} else {
+				err = ring.SetWeight(pth, devs, newWeight)
+			}
 			if err != nil {
 				fmt.Println(err)
 				os.Exit(1)
+			} else if *ramp > 0 {
+				fmt.Printf("Weight will reach %.2f over the next %d rebalances.\n", newWeight, *ramp)
 			} else {
 				fmt.Println("Weight updated successfully.")
 			}
@@ -378,10 +399,27 @@ func RingBuildCmd(flags *flag.FlagSet) {
 			fmt.Println(err)
 			os.Exit(1)
 		}
-		id, err := ring.AddDevice(pth, -1, region, zone, scheme, ip, port, replicationIp, replicationPort, device, weight, debug)
+		addFlags := flag.NewFlagSet("add", flag.ExitOnError)
+		ramp := addFlags.Int("ramp", 0, "Add the device with no weight and reach the given weight over this many rebalances.")
+		if err := addFlags.Parse(args[4:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		initialWeight := weight
+		if *ramp > 0 {
+			initialWeight = 0
+		}
+		id, err := ring.AddDevice(pth, -1, region, zone, scheme, ip, port, replicationIp, replicationPort, device, initialWeight, debug)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
+		}
+		if *ramp > 0 {
+			if err := ring.RampWeight(pth, []*ring.RingBuilderDevice{{Id: id}}, weight, *ramp); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			fmt.Printf("Device %s added with id %d; its weight will reach %.2f over the next %d rebalances\n", device, id, weight, *ramp)
 		} else {
 			fmt.Printf("Device %s with %.2f weight added with id %d\n", device, weight, id)
 		}
@@ -434,6 +472,11 @@ func RingBuildCmd(flags *flag.FlagSet) {
 			// TODO: Figure out how to do ring comparisons
 
 			PrintDevs(builder.Devs)
+			for _, dev := range builder.Devs {
+				if dev != nil && dev.RampSteps > 0 {
+					fmt.Printf("Device %d is ramping to weight %.2f over %d more rebalances\n", dev.Id, dev.TargetWeight, dev.RampSteps)
+				}
+			}
 		}
 
 	case "analyze":
@@ -0,0 +1,81 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package tools
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/srv"
+	"go.uber.org/zap"
+)
+
+// ringsHandler serves the rings andrewd's ring builders write, and a signed
+// manifest of them, to nodes fetching rings with [ring-distribution] source
+// pointed at andrewd's /rings.
+func (server *AutoAdmin) ringsHandler(writer http.ResponseWriter, request *http.Request) {
+	_, key, _ := conf.GetRingDistribution()
+	name := srv.GetVars(request)["file"]
+	if key == "" {
+		srv.StandardResponse(writer, http.StatusNotFound)
+		return
+	}
+	if name == ring.RingManifestName {
+		manifest, err := ring.BuildRingManifest(server.ringDir, key)
+		if err != nil {
+			server.logger.Error("Unable to build ring manifest", zap.String("dir", server.ringDir), zap.Error(err))
+			srv.StandardResponse(writer, http.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(manifest)
+		return
+	}
+	if !ring.ValidRingFileName(name) {
+		srv.StandardResponse(writer, http.StatusNotFound)
+		return
+	}
+	http.ServeFile(writer, request, filepath.Join(server.ringDir, name))
+}
+
+// RingManifest prints the signed manifest of the rings in a directory, for
+// uploading along with them to wherever nodes fetch rings from.
+func RingManifest(flags *flag.FlagSet) bool {
+	dir := flags.Lookup("d").Value.(flag.Getter).Get().(string)
+	_, key, _ := conf.GetRingDistribution()
+	if key == "" {
+		fmt.Fprintln(os.Stderr, "No [ring-distribution] key in hummingbird.conf to sign the manifest with")
+		return false
+	}
+	manifest, err := ring.BuildRingManifest(dir, key)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	byts, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	fmt.Println(string(byts))
+	return true
+}
@@ -351,7 +351,7 @@ func (rs *ringScan) ringMD5URLs() []string {
 					if !dev.Active() {
 						continue
 					}
-					urlMap[dev.Ip] = fmt.Sprintf("%s://%s:%d/recon/ringmd5", dev.Scheme, dev.Ip, dev.Port)
+					urlMap[dev.Ip] = fmt.Sprintf("%s://%s:%d%s/recon/ringmd5", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix)
 				}
 			}
 		} else {
@@ -360,7 +360,7 @@ func (rs *ringScan) ringMD5URLs() []string {
 				if !dev.Active() {
 					continue
 				}
-				urlMap[dev.Ip] = fmt.Sprintf("%s://%s:%d/recon/ringmd5", dev.Scheme, dev.Ip, dev.Port)
+				urlMap[dev.Ip] = fmt.Sprintf("%s://%s:%d%s/recon/ringmd5", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix)
 			}
 		}
 	}
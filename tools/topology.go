@@ -0,0 +1,343 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package tools
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/srv"
+)
+
+type topologyDevice struct {
+	Id     int     `json:"id"`
+	Device string  `json:"device"`
+	Weight float64 `json:"weight"`
+	// Mounted is nil if the device's server didn't answer recon.
+	Mounted *bool   `json:"mounted,omitempty"`
+	Size    int64   `json:"size"`
+	Used    int64   `json:"used"`
+	Fill    float64 `json:"fill"`
+}
+
+type topologyServer struct {
+	Ip        string            `json:"ip"`
+	Port      int               `json:"port"`
+	Reachable bool              `json:"reachable"`
+	Weight    float64           `json:"weight"`
+	Size      int64             `json:"size"`
+	Used      int64             `json:"used"`
+	Fill      float64           `json:"fill"`
+	Devices   []*topologyDevice `json:"devices"`
+}
+
+type topologyZone struct {
+	Zone    int               `json:"zone"`
+	Weight  float64           `json:"weight"`
+	Size    int64             `json:"size"`
+	Used    int64             `json:"used"`
+	Fill    float64           `json:"fill"`
+	Servers []*topologyServer `json:"servers"`
+}
+
+type topologyRegion struct {
+	Region int             `json:"region"`
+	Weight float64         `json:"weight"`
+	Size   int64           `json:"size"`
+	Used   int64           `json:"used"`
+	Fill   float64         `json:"fill"`
+	Zones  []*topologyZone `json:"zones"`
+}
+
+type ringTopology struct {
+	Ring    string            `json:"ring"`
+	Weight  float64           `json:"weight"`
+	Size    int64             `json:"size"`
+	Used    int64             `json:"used"`
+	Fill    float64           `json:"fill"`
+	Regions []*topologyRegion `json:"regions"`
+}
+
+// clusterTopology is the cluster ring by ring, as regions, zones, servers and
+// devices. Weights are summed up the tree; Size and Used are too, from the
+// servers' /recon/diskusage, so each level's Fill is how full its devices
+// that answered are.
+type clusterTopology struct {
+	Time   time.Time       `json:"time"`
+	Rings  []*ringTopology `json:"rings"`
+	Errors []string        `json:"errors,omitempty"`
+}
+
+// topologyUsage is one device's entry in a server's /recon/diskusage.
+type topologyUsage struct {
+	Device  string `json:"device"`
+	Mounted bool   `json:"mounted"`
+	Size    int64  `json:"size"`
+	Used    int64  `json:"used"`
+}
+
+func topologyFill(size, used int64) float64 {
+	if size <= 0 {
+		return 0
+	}
+	return float64(used) / float64(size)
+}
+
+// buildRingTopology arranges a ring's devices into regions, zones and
+// servers. usage is keyed by serverId; a server missing from it didn't
+// answer recon.
+func buildRingTopology(name string, r ring.Ring, usage map[string][]topologyUsage) *ringTopology {
+	rt := &ringTopology{Ring: name}
+	regions := map[int]*topologyRegion{}
+	zones := map[[2]int]*topologyZone{}
+	servers := map[string]*topologyServer{}
+	for _, dev := range r.AllDevices() {
+		if dev == nil || dev.Weight < 0 {
+			continue
+		}
+		region := regions[dev.Region]
+		if region == nil {
+			region = &topologyRegion{Region: dev.Region}
+			regions[dev.Region] = region
+			rt.Regions = append(rt.Regions, region)
+		}
+		zone := zones[[2]int{dev.Region, dev.Zone}]
+		if zone == nil {
+			zone = &topologyZone{Zone: dev.Zone}
+			zones[[2]int{dev.Region, dev.Zone}] = zone
+			region.Zones = append(region.Zones, zone)
+		}
+		id := serverId(dev.Ip, dev.Port)
+		server := servers[id]
+		if server == nil {
+			_, reachable := usage[id]
+			server = &topologyServer{Ip: dev.Ip, Port: dev.Port, Reachable: reachable}
+			servers[id] = server
+			zone.Servers = append(zone.Servers, server)
+		}
+		td := &topologyDevice{Id: dev.Id, Device: dev.Device, Weight: dev.Weight}
+		if server.Reachable {
+			mounted := false
+			for _, u := range usage[id] {
+				if u.Device == dev.Device {
+					mounted = u.Mounted
+					td.Size, td.Used = u.Size, u.Used
+					break
+				}
+			}
+			td.Mounted = &mounted
+		}
+		td.Fill = topologyFill(td.Size, td.Used)
+		server.Devices = append(server.Devices, td)
+		for _, w := range []*float64{&server.Weight, &zone.Weight, &region.Weight, &rt.Weight} {
+			*w += dev.Weight
+		}
+		for _, s := range []*int64{&server.Size, &zone.Size, &region.Size, &rt.Size} {
+			*s += td.Size
+		}
+		for _, u := range []*int64{&server.Used, &zone.Used, &region.Used, &rt.Used} {
+			*u += td.Used
+		}
+	}
+	rt.Fill = topologyFill(rt.Size, rt.Used)
+	sort.Slice(rt.Regions, func(i, j int) bool { return rt.Regions[i].Region < rt.Regions[j].Region })
+	for _, region := range rt.Regions {
+		region.Fill = topologyFill(region.Size, region.Used)
+		sort.Slice(region.Zones, func(i, j int) bool { return region.Zones[i].Zone < region.Zones[j].Zone })
+		for _, zone := range region.Zones {
+			zone.Fill = topologyFill(zone.Size, zone.Used)
+			sort.Slice(zone.Servers, func(i, j int) bool {
+				if zone.Servers[i].Ip == zone.Servers[j].Ip {
+					return zone.Servers[i].Port < zone.Servers[j].Port
+				}
+				return zone.Servers[i].Ip < zone.Servers[j].Ip
+			})
+			for _, server := range zone.Servers {
+				server.Fill = topologyFill(server.Size, server.Used)
+				sort.Slice(server.Devices, func(i, j int) bool { return server.Devices[i].Device < server.Devices[j].Device })
+			}
+		}
+	}
+	return rt
+}
+
+// getClusterTopology builds the topology of the account and container rings
+// and each storage policy's object ring. If client is nil, recon isn't
+// queried and no device has usage data.
+func getClusterTopology(cnf srv.ConfigLoader, client common.HTTPClient) *clusterTopology {
+	ct := &clusterTopology{Time: time.Now().UTC()}
+	prefix, suffix, err := cnf.GetHashPrefixAndSuffix()
+	if err != nil {
+		ct.Errors = append(ct.Errors, fmt.Sprintf("Unable to get the hash path prefix and suffix: %v", err))
+		return ct
+	}
+	type namedRing struct {
+		name string
+		r    ring.Ring
+	}
+	var rings []namedRing
+	addRing := func(name, ringType string, policy int) {
+		if r, err := cnf.GetRing(ringType, prefix, suffix, policy); err != nil {
+			ct.Errors = append(ct.Errors, fmt.Sprintf("Unable to load the %s ring: %v", name, err))
+		} else {
+			rings = append(rings, namedRing{name, r})
+		}
+	}
+	addRing("account", "account", 0)
+	addRing("container", "container", 0)
+	if policies, err := cnf.GetPolicies(); err != nil {
+		ct.Errors = append(ct.Errors, fmt.Sprintf("Unable to load the storage policies: %v", err))
+	} else {
+		for _, index := range sortedPolicyIndexes(policies) {
+			if index == 0 {
+				addRing("object", "object", 0)
+			} else {
+				addRing(fmt.Sprintf("object-%d", index), "object", index)
+			}
+		}
+	}
+	usage := map[string][]topologyUsage{}
+	if client != nil {
+		queried := map[string]bool{}
+		for _, nr := range rings {
+			for _, dev := range nr.r.AllDevices() {
+				if dev == nil || dev.Weight < 0 {
+					continue
+				}
+				id := serverId(dev.Ip, dev.Port)
+				if queried[id] {
+					continue
+				}
+				queried[id] = true
+				data, err := queryHostRecon(client, &ipPort{ip: dev.Ip, port: dev.Port, scheme: dev.Scheme, pathPrefix: dev.PathPrefix}, "diskusage")
+				if err != nil {
+					ct.Errors = append(ct.Errors, fmt.Sprintf("%s: %v", id, err))
+					continue
+				}
+				var u []topologyUsage
+				if err := json.Unmarshal(data, &u); err != nil {
+					ct.Errors = append(ct.Errors, fmt.Sprintf("%s: invalid diskusage: %v", id, err))
+					continue
+				}
+				usage[id] = u
+			}
+		}
+	}
+	for _, nr := range rings {
+		ct.Rings = append(ct.Rings, buildRingTopology(nr.name, nr.r, usage))
+	}
+	return ct
+}
+
+// writeTopologyDot writes the topology as a graphviz digraph, one cluster
+// per ring. Unmounted devices and unreachable servers are drawn in red, and
+// devices more than 90% full in orange.
+func writeTopologyDot(w io.Writer, ct *clusterTopology) {
+	quote := func(s string) string {
+		return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+	}
+	node := func(id, label, color string) {
+		attrs := "label=" + quote(label)
+		if color != "" {
+			attrs += ", style=filled, fillcolor=" + color
+		}
+		fmt.Fprintf(w, "    %s [%s];\n", quote(id), attrs)
+	}
+	edge := func(from, to string) {
+		fmt.Fprintf(w, "    %s -> %s;\n", quote(from), quote(to))
+	}
+	usageLabel := func(size int64, f float64) string {
+		if size <= 0 {
+			return ""
+		}
+		return fmt.Sprintf("\\n%.1f%% full", f*100)
+	}
+	fmt.Fprintln(w, "digraph hummingbird {")
+	fmt.Fprintln(w, "    rankdir=LR;")
+	fmt.Fprintln(w, "    node [shape=box];")
+	for _, rt := range ct.Rings {
+		fmt.Fprintf(w, "  subgraph %s {\n", quote("cluster_"+rt.Ring))
+		fmt.Fprintf(w, "    label=%s;\n", quote(rt.Ring))
+		node(rt.Ring, fmt.Sprintf("%s\\nweight %g%s", rt.Ring, rt.Weight, usageLabel(rt.Size, rt.Fill)), "")
+		for _, region := range rt.Regions {
+			rid := fmt.Sprintf("%s/r%d", rt.Ring, region.Region)
+			node(rid, fmt.Sprintf("region %d\\nweight %g%s", region.Region, region.Weight, usageLabel(region.Size, region.Fill)), "")
+			edge(rt.Ring, rid)
+			for _, zone := range region.Zones {
+				zid := fmt.Sprintf("%sz%d", rid, zone.Zone)
+				node(zid, fmt.Sprintf("zone %d\\nweight %g%s", zone.Zone, zone.Weight, usageLabel(zone.Size, zone.Fill)), "")
+				edge(rid, zid)
+				for _, server := range zone.Servers {
+					sid := fmt.Sprintf("%s/%s", zid, serverId(server.Ip, server.Port))
+					color := ""
+					if !server.Reachable {
+						color = "red"
+					}
+					node(sid, fmt.Sprintf("%s\\nweight %g%s", serverId(server.Ip, server.Port), server.Weight, usageLabel(server.Size, server.Fill)), color)
+					edge(zid, sid)
+					for _, dev := range server.Devices {
+						did := fmt.Sprintf("%s/%s", sid, dev.Device)
+						color := ""
+						if dev.Mounted != nil && !*dev.Mounted {
+							color = "red"
+						} else if dev.Fill > 0.9 {
+							color = "orange"
+						}
+						node(did, fmt.Sprintf("%s (%d)\\nweight %g%s", dev.Device, dev.Id, dev.Weight, usageLabel(dev.Size, dev.Fill)), color)
+						edge(sid, did)
+					}
+				}
+			}
+		}
+		fmt.Fprintln(w, "  }")
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func Topology(flags *flag.FlagSet, cnf srv.ConfigLoader) bool {
+	var client common.HTTPClient
+	if !flags.Lookup("norecon").Value.(flag.Getter).Get().(bool) {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	ct := getClusterTopology(cnf, client)
+	for _, e := range ct.Errors {
+		fmt.Fprintln(os.Stderr, "WARNING:", e)
+	}
+	switch format := flags.Lookup("format").Value.(flag.Getter).Get().(string); format {
+	case "json":
+		byts, err := json.MarshalIndent(ct, "", "    ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return false
+		}
+		fmt.Println(string(byts))
+	case "dot":
+		writeTopologyDot(os.Stdout, ct)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown format %q; use json or dot\n", format)
+		return false
+	}
+	return len(ct.Rings) > 0
+}
@@ -0,0 +1,98 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/common/test"
+)
+
+func TestBuildRingTopology(t *testing.T) {
+	r := &verifyTestRing{FakeRing: &test.FakeRing{}, devs: []*ring.Device{
+		{Id: 0, Region: 1, Zone: 1, Ip: "10.0.0.2", Port: 6000, Device: "sdb", Weight: 100},
+		{Id: 1, Region: 1, Zone: 1, Ip: "10.0.0.2", Port: 6000, Device: "sda", Weight: 100},
+		{Id: 2, Region: 1, Zone: 2, Ip: "10.0.0.1", Port: 6000, Device: "sda", Weight: 200},
+		{Id: 3, Region: 0, Zone: 1, Ip: "10.0.1.1", Port: 6000, Device: "sda", Weight: 50},
+		nil,
+		{Id: 5, Region: 0, Zone: 1, Ip: "10.0.1.1", Port: 6000, Device: "sdz", Weight: -1},
+	}}
+	usage := map[string][]topologyUsage{
+		"10.0.0.2:6000": {
+			{Device: "sda", Mounted: true, Size: 1000, Used: 950},
+			{Device: "sdb", Mounted: false},
+		},
+		"10.0.0.1:6000": {{Device: "sda", Mounted: true, Size: 2000, Used: 500}},
+	}
+	rt := buildRingTopology("object", r, usage)
+	require.Equal(t, float64(450), rt.Weight)
+	require.Equal(t, int64(3000), rt.Size)
+	require.Equal(t, int64(1450), rt.Used)
+	require.Equal(t, 2, len(rt.Regions))
+	require.Equal(t, 0, rt.Regions[0].Region)
+	require.False(t, rt.Regions[0].Zones[0].Servers[0].Reachable)
+	require.Nil(t, rt.Regions[0].Zones[0].Servers[0].Devices[0].Mounted)
+	require.Equal(t, 1, len(rt.Regions[0].Zones[0].Servers[0].Devices))
+
+	region := rt.Regions[1]
+	require.Equal(t, float64(400), region.Weight)
+	require.Equal(t, 2, len(region.Zones))
+	server := region.Zones[0].Servers[0]
+	require.True(t, server.Reachable)
+	require.Equal(t, float64(200), server.Weight)
+	require.Equal(t, 0.95, server.Fill)
+	require.Equal(t, "sda", server.Devices[0].Device)
+	require.True(t, *server.Devices[0].Mounted)
+	require.False(t, *server.Devices[1].Mounted)
+	require.Equal(t, 0.25, region.Zones[1].Fill)
+
+	buf := &bytes.Buffer{}
+	writeTopologyDot(buf, &clusterTopology{Rings: []*ringTopology{rt}})
+	dot := buf.String()
+	require.Contains(t, dot, `"object/r1z1" -> "object/r1z1/10.0.0.2:6000";`)
+	require.Contains(t, dot, `"object/r1z1/10.0.0.2:6000/sda" [label="sda (1)\nweight 100\n95.0% full", style=filled, fillcolor=orange];`)
+	require.Contains(t, dot, `"object/r1z1/10.0.0.2:6000/sdb" [label="sdb (0)\nweight 100", style=filled, fillcolor=red];`)
+	require.Contains(t, dot, `"object/r0z1/10.0.1.1:6000" [label="10.0.1.1:6000\nweight 50", style=filled, fillcolor=red];`)
+}
+
+func TestGetClusterTopology(t *testing.T) {
+	r := &verifyTestRing{FakeRing: &test.FakeRing{}, devs: verifyTestDevs(6000, "sda", "sdb")}
+	cnf := &srv.TestConfigLoader{
+		GetHashPrefixAndSuffixFunc: func() (string, string, error) { return "", "changeme", nil },
+		GetPoliciesFunc: func() (conf.PolicyList, error) {
+			return conf.PolicyList{0: {Index: 0, Name: "gold"}, 1: {Index: 1, Name: "silver"}}, nil
+		},
+		GetRingFunc: func(ringType, prefix, suffix string, policy int) (ring.Ring, error) {
+			if ringType == "container" {
+				return nil, errors.New("no such ring")
+			}
+			return r, nil
+		},
+	}
+	ct := getClusterTopology(cnf, nil)
+	require.Equal(t, []string{"Unable to load the container ring: no such ring"}, ct.Errors)
+	require.Equal(t, 3, len(ct.Rings))
+	require.Equal(t, "account", ct.Rings[0].Ring)
+	require.Equal(t, "object", ct.Rings[1].Ring)
+	require.Equal(t, "object-1", ct.Rings[2].Ring)
+	require.Equal(t, 2, len(ct.Rings[2].Regions[0].Zones[0].Servers[0].Devices))
+}
@@ -228,7 +228,7 @@ func (um *unmountedMonitor) reconUnmountedEndpoints() map[string]*endpointIPPort
 					if dev == nil || dev.Weight < 0 {
 						continue
 					}
-					endpointMap[fmt.Sprintf("%s://%s:%d/recon/diskusage", dev.Scheme, dev.Ip, dev.Port)] = &endpointIPPort{ip: dev.Ip, port: dev.Port}
+					endpointMap[fmt.Sprintf("%s://%s:%d%s/recon/diskusage", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix)] = &endpointIPPort{ip: dev.Ip, port: dev.Port}
 				}
 			}
 		} else {
@@ -237,7 +237,7 @@ func (um *unmountedMonitor) reconUnmountedEndpoints() map[string]*endpointIPPort
 				if dev == nil || dev.Weight < 0 {
 					continue
 				}
-				endpointMap[fmt.Sprintf("%s://%s:%d/recon/diskusage", dev.Scheme, dev.Ip, dev.Port)] = &endpointIPPort{ip: dev.Ip, port: dev.Port}
+				endpointMap[fmt.Sprintf("%s://%s:%d%s/recon/diskusage", dev.Scheme, dev.Ip, dev.Port, dev.PathPrefix)] = &endpointIPPort{ip: dev.Ip, port: dev.Port}
 			}
 		}
 	}
@@ -0,0 +1,296 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package tools
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/troubling/hummingbird/common"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/objectserver"
+)
+
+// configProblem is something verify-config found. Warnings are for things
+// that are odd but might be intended, like a ring device on a port no local
+// server listens on, which is normal if that server is on another machine.
+type configProblem struct {
+	warning bool
+	msg     string
+}
+
+type configProblems []configProblem
+
+func (p *configProblems) errorf(format string, args ...interface{}) {
+	*p = append(*p, configProblem{msg: fmt.Sprintf(format, args...)})
+}
+
+func (p *configProblems) warnf(format string, args ...interface{}) {
+	*p = append(*p, configProblem{warning: true, msg: fmt.Sprintf(format, args...)})
+}
+
+// serverPorts is the section and key of each server's listening port, and
+// its default.
+var serverPorts = []struct {
+	server, section string
+	dfl             int
+}{
+	{"proxy", "DEFAULT", common.DefaultProxyServerPort},
+	{"object", "app:object-server", common.DefaultObjectServerPort},
+	{"object", "object-replicator", common.DefaultObjectReplicatorPort},
+	{"container", "app:container-server", common.DefaultContainerServerPort},
+	{"container", "container-replicator", common.DefaultContainerReplicatorPort},
+	{"account", "app:account-server", common.DefaultAccountServerPort},
+	{"account", "account-replicator", common.DefaultAccountReplicatorPort},
+	{"andrewd", "andrewd", common.DefaultAndrewdPort},
+}
+
+// serverConfigPaths finds the configs for a server in dir, the same ways
+// the server's -c flag can point at them: one file, a .conf.d directory, or
+// a directory of several, as on an all-in-one.
+func serverConfigPaths(dir, server string) []string {
+	base := filepath.Join(dir, server+"-server")
+	var paths []string
+	for _, p := range []string{base + ".conf", base + ".conf.d"} {
+		if _, err := os.Stat(p); err == nil {
+			paths = append(paths, p)
+		}
+	}
+	if fi, err := os.Stat(base); err == nil && fi.IsDir() {
+		confs, _ := filepath.Glob(filepath.Join(base, "*.conf"))
+		dirs, _ := filepath.Glob(filepath.Join(base, "*.conf.d"))
+		paths = append(paths, confs...)
+		paths = append(paths, dirs...)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// verifyServerConfigs loads each server's configs from dir, returning the
+// ports they listen on by "server" or "server-replicator".
+func verifyServerConfigs(dir string, problems *configProblems) map[string]map[int]bool {
+	ports := map[string]map[int]bool{}
+	users := map[int][]string{}
+	found := false
+	for _, server := range []string{"proxy", "object", "container", "account", "andrewd"} {
+		for _, path := range serverConfigPaths(dir, server) {
+			found = true
+			config, err := conf.LoadConfig(path)
+			if err != nil {
+				problems.errorf("Unable to load %s: %v", path, err)
+				continue
+			}
+			for _, sp := range serverPorts {
+				if sp.server != server || (sp.section != "DEFAULT" && !config.HasSection(sp.section)) {
+					continue
+				}
+				port := int(config.GetInt(sp.section, "bind_port", int64(sp.dfl)))
+				kind := server
+				if strings.HasSuffix(sp.section, "-replicator") {
+					kind = sp.section
+				}
+				if ports[kind] == nil {
+					ports[kind] = map[int]bool{}
+				}
+				ports[kind][port] = true
+				users[port] = append(users[port], fmt.Sprintf("%s [%s]", path, sp.section))
+			}
+		}
+	}
+	if !found {
+		problems.warnf("No server configs found in %s", dir)
+	}
+	var bound []int
+	for port := range users {
+		bound = append(bound, port)
+	}
+	sort.Ints(bound)
+	for _, port := range bound {
+		if len(users[port]) > 1 {
+			problems.errorf("Port %d is bound by more than one server: %s", port, strings.Join(users[port], ", "))
+		}
+	}
+	return ports
+}
+
+// verifyPolicies checks the storage policies can all be served.
+func verifyPolicies(policies conf.PolicyList, problems *configProblems) {
+	names := map[string]int{}
+	var defaults []string
+	for _, index := range sortedPolicyIndexes(policies) {
+		p := policies[index]
+		if _, err := objectserver.FindEngine(p.Type); err != nil {
+			problems.errorf("storage-policy:%d has an unknown policy_type %q", p.Index, p.Type)
+		}
+		for _, name := range append([]string{p.Name}, p.Aliases...) {
+			key := strings.ToLower(name)
+			if other, ok := names[key]; ok && other != p.Index {
+				problems.errorf("storage-policy:%d and storage-policy:%d are both named %q", other, p.Index, name)
+			}
+			names[key] = p.Index
+		}
+		if p.Default {
+			defaults = append(defaults, fmt.Sprintf("storage-policy:%d", p.Index))
+			if p.Deprecated {
+				problems.errorf("storage-policy:%d is the default but is deprecated", p.Index)
+			}
+		}
+	}
+	if len(defaults) == 0 {
+		problems.errorf("No storage policy is set as the default")
+	} else if len(defaults) > 1 {
+		problems.errorf("More than one storage policy is set as the default: %s", strings.Join(defaults, ", "))
+	}
+}
+
+func sortedPolicyIndexes(policies conf.PolicyList) []int {
+	var indexes []int
+	for index := range policies {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// verifyRing checks one ring on its own, returning the ip:ports its devices
+// use.
+func verifyRing(name string, r ring.Ring, problems *configProblems) map[string]bool {
+	addrs := map[string]bool{}
+	seen := map[string]bool{}
+	active := 0
+	for _, dev := range r.AllDevices() {
+		if !dev.Active() {
+			continue
+		}
+		active++
+		addr := fmt.Sprintf("%s:%d", dev.Ip, dev.Port)
+		addrs[addr] = true
+		key := addr + "/" + dev.Device
+		if seen[key] {
+			problems.errorf("The %s ring has %s more than once", name, key)
+		}
+		seen[key] = true
+	}
+	if active == 0 {
+		problems.errorf("The %s ring has no devices", name)
+	} else if uint64(active) < r.ReplicaCount() {
+		problems.warnf("The %s ring has %d replicas but only %d devices", name, r.ReplicaCount(), active)
+	}
+	return addrs
+}
+
+// verifyRingPorts warns about devices whose ports don't match what the
+// local configs listen on.
+func verifyRingPorts(name, server string, r ring.Ring, ports map[string]map[int]bool, problems *configProblems) {
+	if ports[server] == nil {
+		return
+	}
+	for _, dev := range r.AllDevices() {
+		if !dev.Active() {
+			continue
+		}
+		if !ports[server][dev.Port] {
+			problems.warnf("The %s ring's device %s:%d/%s uses a port no local %s-server config binds", name, dev.Ip, dev.Port, dev.Device, server)
+		}
+		if rports := ports[server+"-replicator"]; rports != nil && !rports[dev.ReplicationPort] {
+			problems.warnf("The %s ring's device %s:%d/%s has replication port %d, which no local %s-replicator config binds", name, dev.Ip, dev.Port, dev.Device, dev.ReplicationPort, server)
+		}
+	}
+}
+
+// verifyConfig checks the cluster-wide config, the rings, and the server
+// configs in dir against each other.
+func verifyConfig(dir string, cnf srv.ConfigLoader) configProblems {
+	var problems configProblems
+	prefix, suffix, err := cnf.GetHashPrefixAndSuffix()
+	if err != nil {
+		problems.errorf("Unable to get the hash path prefix and suffix: %v", err)
+	} else if prefix == "" && suffix == "" {
+		problems.errorf("swift_hash_path_prefix and swift_hash_path_suffix are both empty")
+	}
+	policies, err := cnf.GetPolicies()
+	if err != nil {
+		problems.errorf("Unable to load the storage policies: %v", err)
+	} else {
+		verifyPolicies(policies, &problems)
+	}
+	ports := verifyServerConfigs(dir, &problems)
+
+	// A server can't be two kinds of server at once, so an ip:port should
+	// only be in one kind of ring; the object rings can share theirs.
+	addrKinds := map[string]map[string]bool{}
+	checkRing := func(name, server string, policy int) {
+		r, err := cnf.GetRing(server, prefix, suffix, policy)
+		if err != nil {
+			problems.errorf("Unable to load the %s ring: %v", name, err)
+			return
+		}
+		for addr := range verifyRing(name, r, &problems) {
+			if addrKinds[addr] == nil {
+				addrKinds[addr] = map[string]bool{}
+			}
+			addrKinds[addr][server] = true
+		}
+		verifyRingPorts(name, server, r, ports, &problems)
+	}
+	checkRing("account", "account", 0)
+	checkRing("container", "container", 0)
+	for _, index := range sortedPolicyIndexes(policies) {
+		checkRing(fmt.Sprintf("object (storage-policy:%d)", index), "object", index)
+	}
+	var addrs []string
+	for addr := range addrKinds {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		if len(addrKinds[addr]) > 1 {
+			var kinds []string
+			for kind := range addrKinds[addr] {
+				kinds = append(kinds, kind)
+			}
+			sort.Strings(kinds)
+			problems.errorf("%s is used by devices in the %s rings", addr, strings.Join(kinds, " and "))
+		}
+	}
+	return problems
+}
+
+// VerifyConfig prints any problems with the configs and rings, returning
+// false if any of them would keep the cluster from working.
+func VerifyConfig(flags *flag.FlagSet, cnf srv.ConfigLoader) bool {
+	dir := flags.Lookup("d").Value.(flag.Getter).Get().(string)
+	pass := true
+	problems := verifyConfig(dir, cnf)
+	for _, p := range problems {
+		if p.warning {
+			fmt.Println("WARNING:", p.msg)
+		} else {
+			fmt.Println("ERROR:", p.msg)
+			pass = false
+		}
+	}
+	if len(problems) == 0 {
+		fmt.Println("No problems found.")
+	}
+	return pass
+}
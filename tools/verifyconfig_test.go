@@ -0,0 +1,105 @@
+//  Copyright (c) 2018 Rackspace
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+//  implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package tools
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/troubling/hummingbird/common/conf"
+	"github.com/troubling/hummingbird/common/ring"
+	"github.com/troubling/hummingbird/common/srv"
+	"github.com/troubling/hummingbird/common/test"
+)
+
+type verifyTestRing struct {
+	*test.FakeRing
+	devs []*ring.Device
+}
+
+func (r *verifyTestRing) AllDevices() []*ring.Device {
+	return r.devs
+}
+
+func verifyTestDevs(port int, names ...string) []*ring.Device {
+	var devs []*ring.Device
+	for i, name := range names {
+		devs = append(devs, &ring.Device{Id: i, Ip: "127.0.0.1", Port: port, ReplicationIp: "127.0.0.1", ReplicationPort: port + 500, Device: name})
+	}
+	return devs
+}
+
+func TestVerifyConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "object-server.conf"), []byte("[app:object-server]\nbind_port = 6000\n[object-replicator]\nbind_port = 6500\n"), 0600))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "container-server.conf"), []byte("[app:container-server]\nbind_port = 6001\n"), 0600))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "account-server.conf"), []byte("[app:account-server]\nbind_port = 6002\n"), 0600))
+
+	policies := conf.PolicyList{
+		0: {Index: 0, Type: "replication", Name: "gold", Default: true},
+		1: {Index: 1, Type: "replication", Name: "silver"},
+	}
+	rings := map[string]ring.Ring{
+		"account":   &verifyTestRing{FakeRing: &test.FakeRing{}, devs: verifyTestDevs(6002, "sda", "sdb", "sdc")},
+		"container": &verifyTestRing{FakeRing: &test.FakeRing{}, devs: verifyTestDevs(6001, "sda", "sdb", "sdc")},
+		"object":    &verifyTestRing{FakeRing: &test.FakeRing{}, devs: verifyTestDevs(6000, "sda", "sdb", "sdc")},
+	}
+	cnf := &srv.TestConfigLoader{
+		GetHashPrefixAndSuffixFunc: func() (string, string, error) { return "", "changeme", nil },
+		GetPoliciesFunc:            func() (conf.PolicyList, error) { return policies, nil },
+		GetRingFunc: func(ringType, prefix, suffix string, policy int) (ring.Ring, error) {
+			if ringType == "object" && policy > 1 {
+				return nil, errors.New("no such ring")
+			}
+			return rings[ringType], nil
+		},
+	}
+	require.Equal(t, 0, len(verifyConfig(dir, cnf)))
+
+	policies[1].Default = true
+	policies[1].Aliases = []string{"Gold"}
+	policies[2] = &conf.Policy{Index: 2, Type: "nope", Name: "bronze"}
+	rings["container"] = &verifyTestRing{FakeRing: &test.FakeRing{}, devs: append(verifyTestDevs(6000, "sdd", "sdd"), verifyTestDevs(6001, "sda")...)}
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "proxy-server.conf"), []byte("[DEFAULT]\nbind_port = 6001\n"), 0600))
+	problems := verifyConfig(dir, cnf)
+	var errs, warnings []string
+	for _, p := range problems {
+		if p.warning {
+			warnings = append(warnings, p.msg)
+		} else {
+			errs = append(errs, p.msg)
+		}
+	}
+	require.Equal(t, []string{
+		`storage-policy:0 and storage-policy:1 are both named "Gold"`,
+		`storage-policy:2 has an unknown policy_type "nope"`,
+		"More than one storage policy is set as the default: storage-policy:0, storage-policy:1",
+		"Port 6001 is bound by more than one server: " + filepath.Join(dir, "proxy-server.conf") + " [DEFAULT], " + filepath.Join(dir, "container-server.conf") + " [app:container-server]",
+		"The container ring has 127.0.0.1:6000/sdd more than once",
+		"Unable to load the object (storage-policy:2) ring: no such ring",
+		"127.0.0.1:6000 is used by devices in the container and object rings",
+	}, errs)
+	require.Equal(t, []string{
+		"The container ring's device 127.0.0.1:6000/sdd uses a port no local container-server config binds",
+		"The container ring's device 127.0.0.1:6000/sdd uses a port no local container-server config binds",
+	}, warnings)
+}